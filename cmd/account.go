@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+	"tradingbot/internal/database"
+	"tradingbot/internal/market"
+	"tradingbot/internal/reconcile"
+)
+
+// runBalance implements `tradingbot balance`.
+func runBalance(args []string) error {
+	fs := flag.NewFlagSet("balance", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	exch, err := connectExchange(cfg)
+	if err != nil {
+		return err
+	}
+
+	balance, err := exch.GetBalance()
+	if err != nil {
+		return err
+	}
+	fmt.Println(balance)
+	return nil
+}
+
+// runPositions implements `tradingbot positions`.
+func runPositions(args []string) error {
+	fs := flag.NewFlagSet("positions", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	exch, err := connectExchange(cfg)
+	if err != nil {
+		return err
+	}
+
+	positions, err := exch.GetPositions()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tNAME\tQTY\tAVG PRICE\tPRICE\tP/L")
+	for _, p := range positions {
+		fmt.Fprintf(w, "%s\t%s\t%.0f\t%.2f\t%.2f\t%.0f\n", p.StockCode, p.Name, p.Quantity, p.AvgPrice, p.CurrentPrice, p.ProfitLoss)
+	}
+	return w.Flush()
+}
+
+// runOrders implements `tradingbot orders`.
+func runOrders(args []string) error {
+	fs := flag.NewFlagSet("orders", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	limit := fs.Int("limit", 20, "number of orders to show")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	orders, err := db.ListOrders(*limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME (KST)\tPAIR\tSIDE\tTYPE\tAMOUNT\tPRICE\tSTATUS")
+	for _, o := range orders {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%g\t%g\t%s\n",
+			o.ID, o.Timestamp.In(market.KST).Format("2006-01-02 15:04:05"), o.Pair, o.Side, o.Type, o.Amount, o.Price, o.Status)
+	}
+	return w.Flush()
+}
+
+// runQuote implements `tradingbot quote <code>`.
+func runQuote(args []string) error {
+	fs := flag.NewFlagSet("quote", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: tradingbot quote <code>")
+	}
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	exch, err := connectExchange(cfg)
+	if err != nil {
+		return err
+	}
+
+	data, err := exch.GetMarketData(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\n", fs.Arg(0), data.StckPrpr)
+	return nil
+}
+
+// runReconcile implements `tradingbot reconcile`.
+func runReconcile(args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	importTrades := fs.Bool("import", false, "store trades made outside the bot as orders")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	exch, err := connectExchange(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := reconcile.Run(exch, db, time.Now(), *importTrades)
+	if err != nil {
+		return err
+	}
+	if report.Clean() {
+		fmt.Printf("%d positions match the broker\n", report.Matched)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(report.Positions) > 0 {
+		fmt.Fprintln(w, "CODE\tLOCAL\tBROKER\tDIFF\tAVG PRICE\tPRICE")
+		for _, d := range report.Positions {
+			fmt.Fprintf(w, "%s\t%g\t%g\t%+g\t%.2f\t%.2f\n", d.Symbol, d.Local, d.Broker, d.Quantity(), d.AvgPrice, d.Price)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(report.Unknown) > 0 {
+		fmt.Fprintln(w, "ORDER NO\tCODE\tSIDE\tQTY\tREMAINING\tPRICE")
+		for _, o := range report.Unknown {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.0f\t%.0f\t%.2f\n", o.OrderNo, o.StockCode, o.Side, o.Quantity, o.RemainingQty, o.Price)
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(report.Imported) > 0 {
+		fmt.Printf("Imported %d outside trades as %q orders\n", len(report.Imported), reconcile.Strategy)
+	} else if len(report.Positions) > 0 {
+		fmt.Println("Run with -import to store the differences as orders")
+	}
+	return nil
+}
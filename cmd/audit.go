@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"tradingbot/internal/audit"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+)
+
+// runAudit implements `tradingbot audit`.
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	file := fs.String("file", "", "audit log to read (default: audit.path from the config)")
+	symbol := fs.String("symbol", "", "only show decisions for this symbol")
+	action := fs.String("action", "", "only show decisions with this action (hold, rejected, ordered, failed, error)")
+	since := fs.String("since", "", "only show decisions after this date (YYYY-MM-DD, KST) or duration ago (e.g. 24h)")
+	asJSON := fs.Bool("json", false, "print raw JSON records")
+	verify := fs.Bool("verify", false, "check the hash chain instead of listing records")
+	fs.Parse(args)
+
+	path := *file
+	if path == "" {
+		cfg, err := config.LoadProfile(cf.path, cf.profile)
+		if err != nil {
+			return err
+		}
+		path = cfg.Audit.Path
+	}
+	if path == "" {
+		return fmt.Errorf("no audit log configured; set audit.path or pass -file")
+	}
+
+	if *verify {
+		n, err := audit.Verify(path)
+		if err != nil {
+			return fmt.Errorf("audit log verification failed: %v", err)
+		}
+		fmt.Printf("%s: %d records, hash chain intact\n", path, n)
+		return nil
+	}
+
+	filter := audit.Filter{Symbol: *symbol, Action: *action}
+	if *since != "" {
+		t, err := parseSince(*since, time.Now())
+		if err != nil {
+			return err
+		}
+		filter.Since = t
+	}
+
+	records, err := audit.Read(path, filter)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQ\tTIME (KST)\tSYMBOL\tSOURCE\tPRICE\tSIGNAL\tCHECKS\tACTION\tINDICATORS\tERROR")
+	for _, r := range records {
+		signal := "-"
+		if r.Signal != nil {
+			signal = fmt.Sprintf("%s %g", r.Signal.Type, r.Signal.Amount)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Seq, r.Time.In(market.KST).Format("2006-01-02 15:04:05"), r.Symbol, r.Source, r.Price, signal,
+			formatChecks(r), r.Action, formatIndicators(r.Indicators), r.Error)
+	}
+	return w.Flush()
+}
+
+// parseSince accepts a date, from midnight KST, or a duration before now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, market.KST)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -since %q: want YYYY-MM-DD or a duration", s)
+	}
+	return t, nil
+}
+
+func formatChecks(r audit.Record) string {
+	if len(r.Checks) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(r.Checks))
+	for i, c := range r.Checks {
+		result := "ok"
+		if !c.Passed {
+			result = "FAIL"
+		}
+		parts[i] = c.Name + "=" + result
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatIndicators(indicators map[string]float64) string {
+	names := make([]string, 0, len(indicators))
+	for name := range indicators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%g", name, indicators[name])
+	}
+	return strings.Join(parts, " ")
+}
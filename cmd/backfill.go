@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"tradingbot/internal/backfill"
+	"tradingbot/internal/config"
+	"tradingbot/internal/database"
+	"tradingbot/internal/engine"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/market"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// KIS allows 20 requests per second on live accounts and far fewer on paper
+// ones; the defaults stay below both.
+const (
+	backfillRateLive  = 15
+	backfillRatePaper = 2
+)
+
+// runBackfill implements `tradingbot backfill`.
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	symbols := fs.String("symbol", "", "comma-separated stock codes (default: the configured symbols)")
+	fromFlag := fs.String("from", "", "first date to backfill, YYYY-MM-DD (required)")
+	toFlag := fs.String("to", "", "last date to backfill, YYYY-MM-DD (default: today)")
+	timeframe := fs.String("timeframe", "1d", "candle timeframe; KIS keeps history of daily candles only")
+	rate := fs.Float64("rate", 0, "requests per second (default: 15 live, 2 paper)")
+	fs.Parse(args)
+
+	if *fromFlag == "" {
+		return fmt.Errorf("usage: backfill -from YYYY-MM-DD [flags]")
+	}
+	if tf, err := config.ParseTimeframe(*timeframe); err != nil || tf != 24*time.Hour {
+		return fmt.Errorf("unsupported timeframe %q: only 1d history can be backfilled", *timeframe)
+	}
+	from, err := time.ParseInLocation("2006-01-02", *fromFlag, market.KST)
+	if err != nil {
+		return errors.Wrap(err, "invalid -from")
+	}
+	to := time.Now().In(market.KST)
+	if *toFlag != "" {
+		if to, err = time.ParseInLocation("2006-01-02", *toFlag, market.KST); err != nil {
+			return errors.Wrap(err, "invalid -to")
+		}
+	}
+	if to.Before(from) {
+		return fmt.Errorf("-to %s is before -from %s", to.Format("2006-01-02"), *fromFlag)
+	}
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	codes := cfg.TradingSymbols()
+	if *symbols != "" {
+		codes = strings.Split(*symbols, ",")
+	}
+	cal, err := market.NewCalendar(cfg.Market)
+	if err != nil {
+		return err
+	}
+
+	// Backfill into the database the database market data provider reads.
+	url := cfg.MarketData.DatabaseURL
+	if url == "" {
+		url = cfg.DatabaseURL
+	}
+	db, err := database.NewConnection(url)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	exch, err := connectExchange(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize exchange")
+	}
+
+	if *rate <= 0 {
+		*rate = backfillRateLive
+		if exch.Paper {
+			*rate = backfillRatePaper
+		}
+	}
+	b := backfill.New(exch, db, cal)
+	b.Interval = time.Duration(float64(time.Second) / *rate)
+	b.Progress = printProgress
+
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		result, err := b.Run(code, from, to)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return errors.Wrapf(err, "backfill of %s stopped; run the command again to resume", code)
+		}
+		log.WithFields(logrus.Fields{"symbol": code, "saved": result.Saved, "requests": result.Requests, "missing": result.Missing}).Info("Backfill complete")
+	}
+	return nil
+}
+
+// printProgress draws a progress bar for p on stderr.
+func printProgress(p backfill.Progress) {
+	const width = 30
+	filled := width
+	if p.Total > 0 {
+		filled = width * p.Done / p.Total
+	}
+	fmt.Fprintf(os.Stderr, "\r%s [%s%s] %d/%d days, back to %s",
+		p.Symbol, strings.Repeat("#", filled), strings.Repeat(".", width-filled), p.Done, p.Total, p.Date.Format("2006-01-02"))
+}
+
+// resumeCandles fills the gap between the last candle the engine processed
+// before it was stopped and now, so that the strategies and the stored
+// candles carry on where they left off.
+func resumeCandles(cfg *config.Config, db *database.DB, exch *exchange.KISExchange, eng *engine.Engine) error {
+	cal, err := market.NewCalendar(cfg.Market)
+	if err != nil {
+		return err
+	}
+	b := backfill.New(exch, db, cal)
+	b.Interval = time.Second / backfillRateLive
+	if exch.Paper {
+		b.Interval = time.Second / backfillRatePaper
+	}
+
+	now := time.Now()
+	for _, symbol := range cfg.TradingSymbols() {
+		last, err := db.LatestCandle(symbol, cfg.ParsedTimeframe)
+		if err != nil {
+			return err
+		}
+		if last == nil {
+			log.WithField("symbol", symbol).Info("No candles processed before; nothing to resume")
+			continue
+		}
+		missed, err := b.Missed(symbol, cfg.ParsedTimeframe, last.End(), now)
+		if err != nil {
+			return err
+		}
+		log.WithFields(logrus.Fields{"symbol": symbol, "since": last.End(), "candles": len(missed)}).Info("Filling the gap since the last run")
+		eng.Resume(missed)
+	}
+	return nil
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/database"
+	"tradingbot/internal/hedge"
+	"tradingbot/internal/market"
+	"tradingbot/internal/marketdata"
+	"tradingbot/internal/models"
+	"tradingbot/internal/rebalance"
+	"tradingbot/internal/report"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// runBacktestCommand implements `tradingbot backtest`.
+func runBacktestCommand(args []string) error {
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	code := fs.String("code", "", "stock code to backtest (default: trading_pair)")
+	days := fs.Int("days", 100, "number of days of history")
+	balance := fs.Float64("balance", 10000000, "initial balance in KRW")
+	commission := fs.Float64("commission", 0.0025, "commission rate per trade")
+	notional := fs.Float64("notional", 0, "KRW to spend on each buy in whole shares (default: the whole balance)")
+	seed := fs.Int64("seed", 0, "seed of the random slippage, to repeat a run (default: backtest.seed)")
+	htmlOut := fs.String("html", "", "also write an HTML report to this file")
+	csvIn := fs.String("csv", "", "stream the bars from this candle export instead of fetching -days of history")
+	timeframe := fs.String("timeframe", "", "stream stored candles of this timeframe, e.g. 1m, instead of fetching -days of history")
+	from := fs.String("from", "", "with -timeframe, first KST date, YYYY-MM-DD (default: the beginning)")
+	to := fs.String("to", "", "with -timeframe, last KST date, YYYY-MM-DD (default: today)")
+	chunk := fs.Int("chunk", backtesting.DefaultChunk, "with -timeframe, candles to load at a time")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	if *code == "" {
+		*code = cfg.TradingPair
+	}
+
+	log.Info("Starting backtesting...")
+
+	// Streamed bars are read as the backtest goes, so that histories larger
+	// than memory can be backtested; otherwise -days of history are fetched
+	// up front.
+	var historicalData []models.MarketData
+	var bars backtesting.Bars
+	var provider marketdata.Provider
+	switch {
+	case *csvIn != "":
+		f, err := os.Open(*csvIn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		bars = backtesting.CSVBars(bufio.NewReaderSize(f, 1<<20), *code)
+	case *timeframe != "":
+		tf, err := config.ParseTimeframe(*timeframe)
+		if err != nil {
+			return err
+		}
+		start, end, err := report.ParsePeriod(*from, *to, time.Now())
+		if err != nil {
+			return err
+		}
+		// Candles are read from the database the database market data
+		// provider reads.
+		url := cfg.MarketData.DatabaseURL
+		if url == "" {
+			url = cfg.DatabaseURL
+		}
+		db, err := database.NewConnection(url)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		bars = backtesting.CandleStream(func(after time.Time, limit int) ([]candle.Candle, error) {
+			return db.ListCandlesAfter(*code, tf, after, end, limit)
+		}, start.Add(-time.Nanosecond), *chunk)
+	default:
+		if provider, err = connectMarketData(cfg, nil); err != nil {
+			return err
+		}
+		historicalData, err = provider.GetHistoricalData(*code, *days)
+		if err != nil {
+			return errors.Wrap(err, "failed to get historical data")
+		}
+	}
+
+	strat, err := newStrategy(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize strategy")
+	}
+
+	backtester := backtesting.NewBacktester(strat, historicalData, *balance, *commission)
+	backtester.Bars = bars
+	backtester.OrderNotional = *notional
+	backtester.StopLoss = cfg.Backtest.StopLoss
+	backtester.TakeProfit = cfg.Backtest.TakeProfit
+	backtester.Intrabar = cfg.Backtest.Intrabar
+	backtester.Slippage = cfg.Backtest.Slippage
+	backtester.Seed = cfg.Backtest.Seed
+	if *seed != 0 {
+		backtester.Seed = *seed
+	}
+	if cfg.CashSweep.Enabled {
+		backtester.SweepYield = cfg.CashSweep.AnnualYield
+	}
+	if cfg.Margin.Enabled {
+		backtester.MarginRequirement = cfg.Margin.Requirement
+		backtester.MarginInterest = cfg.Margin.InterestRate
+	}
+
+	if cfg.Hedger.Enabled {
+		backtester.Hedger = hedge.New(cfg.Hedger)
+		if index := cfg.Hedger.Index; bars != nil && index != "" && index != *code {
+			log.Warn("The hedge index is not streamed; backtesting without index prices")
+		} else if index != "" && index != *code {
+			indexData, err := provider.GetHistoricalData(index, *days)
+			if err != nil {
+				return errors.Wrap(err, "failed to get index history")
+			}
+			backtester.HedgeIndex = alignCloses(indexData, len(historicalData))
+		}
+	}
+
+	if cfg.Backtest.Dividends && bars != nil {
+		log.Warn("Dividends are not streamed; backtesting without them")
+	} else if cfg.Backtest.Dividends {
+		exch, err := connectExchange(cfg)
+		if err != nil {
+			return errors.Wrap(err, "failed to initialize exchange")
+		}
+		cal, err := market.NewCalendar(cfg.Market)
+		if err != nil {
+			return err
+		}
+		// Bars are trading days ending with the latest; a year's margin
+		// covers the weekends and holidays among them.
+		now := time.Now()
+		dividends, err := exch.GetDividendHistory(*code, now.AddDate(-1, 0, -*days*7/5), now)
+		if err != nil {
+			return errors.Wrap(err, "failed to get dividend history")
+		}
+		backtester.Dividends = alignDividends(dividends, len(historicalData), cal, now)
+		backtester.DividendTax = cfg.Backtest.DividendTax
+	}
+
+	result := backtester.Run()
+	if backtester.Err != nil {
+		return errors.Wrap(backtester.Err, "failed to read bars")
+	}
+
+	log.WithFields(logrus.Fields{
+		"TotalTrades":       result.TotalTrades,
+		"WinningTrades":     result.WinningTrades,
+		"LosingTrades":      result.LosingTrades,
+		"TotalProfit":       result.TotalProfit,
+		"MaxDrawdown":       result.MaxDrawdown * 100,
+		"WinRate":           result.WinRate * 100,
+		"AvgProfitPerTrade": result.AverageProfitPerTrade,
+		"AvgWin":            result.AverageWin,
+		"AvgLoss":           result.AverageLoss,
+		"SweepIncome":       result.SweepIncome,
+		"StopExits":         result.StopExits,
+		"TargetExits":       result.TargetExits,
+		"InterestCost":      result.InterestCost,
+		"HedgeProfit":       result.HedgeProfit,
+		"DividendIncome":    result.DividendIncome,
+		"Seed":              result.Seed,
+	}).Info("Backtesting results")
+
+	saveBacktest(cfg, *code, backtester, result, *days)
+
+	if *htmlOut != "" {
+		if err := writeBacktestReport(*htmlOut, report.Backtest{
+			Symbol:   *code,
+			Strategy: cfg.Strategy,
+			Days:     *days,
+			Balance:  *balance,
+			Result:   result,
+		}); err != nil {
+			return errors.Wrap(err, "failed to write HTML report")
+		}
+		log.WithField("file", *htmlOut).Info("HTML report written")
+	}
+	return nil
+}
+
+// alignCloses returns the closes of data aligned with n bars ending on the
+// same day; bars before data starts have none.
+func alignCloses(data []models.MarketData, n int) []float64 {
+	closes := make([]float64, n)
+	for i := 0; i < len(data) && i < n; i++ {
+		closes[n-1-i], _ = strconv.ParseFloat(data[len(data)-1-i].StckPrpr, 64)
+	}
+	return closes
+}
+
+// alignDividends returns the dividend per share of n daily bars ending with
+// the last trading day up to now, set on the bars of the ex-dividend dates:
+// the trading day before each record date.
+func alignDividends(dividends []models.Dividend, n int, cal *market.Calendar, now time.Time) []float64 {
+	bars := make(map[string]int, n)
+	day := now.In(market.KST)
+	for i := n - 1; i >= 0; day = day.AddDate(0, 0, -1) {
+		if _, ok := cal.SessionOn(day); ok {
+			bars[day.Format("2006-01-02")] = i
+			i--
+		}
+	}
+
+	perShare := make([]float64, n)
+	for _, d := range dividends {
+		ex := d.RecordDate.In(market.KST).AddDate(0, 0, -1)
+		for _, ok := cal.SessionOn(ex); !ok; _, ok = cal.SessionOn(ex) {
+			ex = ex.AddDate(0, 0, -1)
+		}
+		if i, ok := bars[ex.Format("2006-01-02")]; ok {
+			perShare[i] += d.PerShare
+		}
+	}
+	return perShare
+}
+
+// saveBacktest stores the run in the database so it can be compared later. A
+// database that cannot be reached only costs the record, not the backtest.
+func saveBacktest(cfg *config.Config, symbol string, bt *backtesting.Backtester, result backtesting.BacktestResult, days int) {
+	params := map[string]interface{}{
+		"days":        days,
+		"balance":     bt.InitialBalance,
+		"commission":  bt.CommissionRate,
+		"notional":    bt.OrderNotional,
+		"stop_loss":   bt.StopLoss,
+		"take_profit": bt.TakeProfit,
+		"intrabar":    bt.Intrabar,
+		"slippage":    bt.Slippage,
+		"sweep_yield": bt.SweepYield,
+		"seed":        result.Seed,
+	}
+	if bt.MarginRequirement > 0 {
+		params["margin_requirement"] = bt.MarginRequirement
+		params["margin_interest"] = bt.MarginInterest
+	}
+	if bt.Dividends != nil {
+		params["dividend_tax"] = bt.DividendTax
+	}
+	if bt.Hedger != nil {
+		params["hedge_ratio"] = cfg.Hedger.Ratio
+		params["hedge_max_drawdown"] = cfg.Hedger.MaxDrawdown
+		params["hedge_max_volatility"] = cfg.Hedger.MaxVolatility
+	}
+	strategyParams, _ := cfg.StrategyParamsFor(cfg.Strategy)
+	for name, v := range strategyParams {
+		params[name] = v
+	}
+	run := &models.BacktestRun{
+		CreatedAt:  time.Now(),
+		Symbol:     symbol,
+		Strategy:   cfg.Strategy,
+		Params:     params,
+		DataFrom:   result.StartDate,
+		DataTo:     result.EndDate,
+		Metrics:    result.Metrics(),
+		GitHash:    gitRevision(),
+		ConfigHash: configHash(cfg, strategyParams),
+	}
+	storeBacktest(cfg, run)
+}
+
+// storeBacktest saves run in the database, logging instead of failing when it
+// cannot.
+func storeBacktest(cfg *config.Config, run *models.BacktestRun) {
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		log.WithError(err).Warn("Backtest not saved")
+		return
+	}
+	defer db.Close()
+	id, err := db.SaveBacktest(run)
+	if err != nil {
+		log.WithError(err).Warn("Backtest not saved")
+		return
+	}
+	log.WithField("id", id).Info("Backtest saved")
+}
+
+// runBacktestRebalance implements `tradingbot backtest rebalance`.
+func runBacktestRebalance(args []string) error {
+	fs := flag.NewFlagSet("backtest rebalance", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	days := fs.Int("days", 250, "number of days of history")
+	balance := fs.Float64("balance", 10000000, "initial balance in KRW")
+	commission := fs.Float64("commission", 0.0025, "commission rate per trade")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Rebalance.Weights) == 0 {
+		return fmt.Errorf("no target weights; set rebalance.weights")
+	}
+	provider, err := connectMarketData(cfg, nil)
+	if err != nil {
+		return err
+	}
+	data := make(map[string][]models.MarketData, len(cfg.Rebalance.Weights))
+	symbols := make([]string, 0, len(cfg.Rebalance.Weights))
+	for symbol := range cfg.Rebalance.Weights {
+		history, err := provider.GetHistoricalData(symbol, *days)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get %s history", symbol)
+		}
+		data[symbol] = history
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	bt := backtesting.NewRebalanceBacktester(cfg.Rebalance, data, *balance, *commission)
+	bt.Constraints = cfg.Backtest.Portfolio
+	if len(bt.Constraints.SectorCaps) > 0 {
+		master, err := loadMaster(cfg, symbols)
+		if err != nil {
+			return errors.Wrap(err, "failed to load sectors")
+		}
+		bt.Sectors = make(map[string]string, len(symbols))
+		for _, symbol := range symbols {
+			if s, ok := master.Lookup(symbol); ok {
+				bt.Sectors[symbol] = s.Sector
+			}
+		}
+	}
+	result := bt.Run()
+	log.WithFields(logrus.Fields{
+		"Rebalances":      result.Rebalances,
+		"TotalTrades":     result.TotalTrades,
+		"TotalProfit":     result.TotalProfit,
+		"MaxDrawdown":     result.MaxDrawdown * 100,
+		"PositionsCapped": result.PositionsCapped,
+		"SectorsCapped":   result.SectorsCapped,
+		"TurnoverCapped":  result.TurnoverCapped,
+		"SkippedTrades":   result.SkippedTrades,
+	}).Info("Rebalancing backtest results")
+
+	params := map[string]interface{}{
+		"days":       *days,
+		"balance":    *balance,
+		"commission": *commission,
+		"trigger":    cfg.Rebalance.Trigger,
+		"period":     cfg.Rebalance.Period,
+		"band":       cfg.Rebalance.Band,
+	}
+	for symbol, w := range cfg.Rebalance.Weights {
+		params["weight_"+symbol] = w
+	}
+	if c := cfg.Backtest.Portfolio; c.MaxPositions > 0 || c.MaxTurnover > 0 || c.MinTradeValue > 0 {
+		params["max_positions"] = c.MaxPositions
+		params["max_turnover"] = c.MaxTurnover
+		params["min_trade_value"] = c.MinTradeValue
+	}
+	for sector, w := range cfg.Backtest.Portfolio.SectorCaps {
+		params["sector_cap_"+sector] = w
+	}
+	storeBacktest(cfg, &models.BacktestRun{
+		CreatedAt:  time.Now(),
+		Symbol:     strings.Join(symbols, ","),
+		Strategy:   rebalance.Strategy,
+		Params:     params,
+		DataFrom:   result.StartDate,
+		DataTo:     result.EndDate,
+		Metrics:    result.Metrics(),
+		GitHash:    gitRevision(),
+		ConfigHash: configHash(cfg, nil),
+	})
+	return nil
+}
+
+// gitRevision returns the VCS revision the binary was built from, marked
+// "-dirty" for modified trees, or "unknown".
+func gitRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, dirty := "unknown", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if dirty {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// configHash identifies the parts of the configuration that shape a backtest.
+func configHash(cfg *config.Config, strategyParams config.StrategyParams) string {
+	data, _ := json.Marshal(struct {
+		Strategy  string
+		Params    config.StrategyParams
+		Backtest  config.BacktestConfig
+		CashSweep config.CashSweepConfig
+	}{cfg.Strategy, strategyParams, cfg.Backtest, cfg.CashSweep})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// runBacktestList implements `tradingbot backtest list`.
+func runBacktestList(args []string) error {
+	fs := flag.NewFlagSet("backtest list", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	limit := fs.Int("limit", 20, "number of runs to show")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runs, err := db.ListBacktests(*limit)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME (KST)\tSYMBOL\tSTRATEGY\tPROFIT\tTRADES\tMAX DD\tGIT\tCONFIG")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%.0f\t%s\t%.7s\t%s\n", r.ID, report.FormatKST(r.CreatedAt, "2006-01-02 15:04"), r.Symbol, r.Strategy,
+			report.FormatKRW(r.Metrics["total_profit"]), r.Metrics["total_trades"], report.FormatPercent(r.Metrics["max_drawdown"]), r.GitHash, r.ConfigHash)
+	}
+	return w.Flush()
+}
+
+// runBacktestCompare implements `tradingbot backtest compare <id1> <id2>`.
+func runBacktestCompare(args []string) error {
+	fs := flag.NewFlagSet("backtest compare", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: tradingbot backtest compare <id1> <id2>")
+	}
+	var ids [2]int64
+	for i := range ids {
+		id, err := strconv.ParseInt(fs.Arg(i), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid backtest id %q", fs.Arg(i))
+		}
+		ids[i] = id
+	}
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	a, err := db.GetBacktest(ids[0])
+	if err != nil {
+		return err
+	}
+	b, err := db.GetBacktest(ids[1])
+	if err != nil {
+		return err
+	}
+	c := report.CompareBacktests(*a, *b)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\t#%d\t#%d\t\n", a.ID, b.ID)
+	fmt.Fprintf(w, "symbol\t%s\t%s\t\n", a.Symbol, b.Symbol)
+	fmt.Fprintf(w, "strategy\t%s\t%s\t\n", a.Strategy, b.Strategy)
+	fmt.Fprintf(w, "data\t%s – %s\t%s – %s\t\n", report.FormatKST(a.DataFrom, "2006-01-02"), report.FormatKST(a.DataTo, "2006-01-02"),
+		report.FormatKST(b.DataFrom, "2006-01-02"), report.FormatKST(b.DataTo, "2006-01-02"))
+	fmt.Fprintf(w, "git\t%.7s\t%.7s\t\n", a.GitHash, b.GitHash)
+	fmt.Fprintf(w, "config\t%s\t%s\t\n", a.ConfigHash, b.ConfigHash)
+	fmt.Fprintln(w, "\t\t\t")
+	fmt.Fprintln(w, "METRIC\tA\tB\tDIFF")
+	for _, m := range c.Metrics {
+		fmt.Fprintf(w, "%s\t%g\t%g\t%+g\n", m.Name, m.A, m.B, m.Diff)
+	}
+	if len(c.Params) > 0 {
+		fmt.Fprintln(w, "\t\t\t")
+		fmt.Fprintln(w, "PARAMETER\tA\tB\t")
+		for _, p := range c.Params {
+			fmt.Fprintf(w, "%s\t%s\t%s\t\n", p.Name, p.A, p.B)
+		}
+	}
+	return w.Flush()
+}
+
+func writeBacktestReport(filename string, b report.Backtest) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteBacktest(f, b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"tradingbot/internal/events"
+	"tradingbot/internal/killswitch"
+	"tradingbot/internal/models"
+)
+
+const (
+	controlCycle   = "cycle"
+	controlFlatten = "flatten"
+	controlSignal  = "signal"
+	controlManual  = "manual"
+	controlClose   = "close"
+	controlRecord  = "record"
+	controlApprove = "approve"
+	controlTuning  = "tuning"
+)
+
+// controlRequest asks the trading loop to perform an action between cycles.
+type controlRequest struct {
+	action string
+	signal *models.Signal
+	symbol string
+	order  *models.Order
+	reply  chan error
+}
+
+// controller implements api.Controller. Pausing only flips a flag checked by the
+// loop; actions that touch the exchange are handed to the loop so they never
+// overlap with a trading cycle.
+type controller struct {
+	paused   int32
+	requests chan controlRequest
+	done     chan struct{}
+	// kill, if set, stops trading until restart; it acts at once rather than
+	// between cycles.
+	kill *killswitch.Switch
+}
+
+func newController() *controller {
+	return &controller{requests: make(chan controlRequest), done: make(chan struct{})}
+}
+
+// stop makes pending and future requests fail once the trading loop has exited.
+func (c *controller) stop() { close(c.done) }
+
+func (c *controller) Pause()       { atomic.StoreInt32(&c.paused, 1) }
+func (c *controller) Paused() bool { return atomic.LoadInt32(&c.paused) == 1 }
+
+// Resume unpauses trading, unless the kill switch stopped it.
+func (c *controller) Resume() {
+	if c.kill != nil && c.kill.Killed() {
+		log.Warn("Trading was stopped by the kill switch and stays paused until restart")
+		return
+	}
+	atomic.StoreInt32(&c.paused, 0)
+}
+
+func (c *controller) Kill(source, reason string) (events.KillEvent, error) {
+	if c.kill == nil {
+		return events.KillEvent{}, errors.New("kill switch is not available")
+	}
+	return c.kill.Kill(source, reason), nil
+}
+
+func (c *controller) TriggerCycle() error { return c.do(controlRequest{action: controlCycle}) }
+func (c *controller) Flatten() error      { return c.do(controlRequest{action: controlFlatten}) }
+
+func (c *controller) SubmitSignal(signal *models.Signal) error {
+	if c.Paused() {
+		return errors.New("trading is paused")
+	}
+	return c.do(controlRequest{action: controlSignal, signal: signal})
+}
+
+// InjectSignal submits a manual signal; unlike SubmitSignal it is accepted
+// while paused, as the pause only stops the automated trading.
+func (c *controller) InjectSignal(signal *models.Signal) error {
+	return c.do(controlRequest{action: controlManual, signal: signal})
+}
+
+func (c *controller) ClosePosition(symbol string) error {
+	return c.do(controlRequest{action: controlClose, symbol: symbol})
+}
+
+func (c *controller) RecordTrade(order *models.Order) error {
+	return c.do(controlRequest{action: controlRecord, order: order})
+}
+
+func (c *controller) ApproveLive() error   { return c.do(controlRequest{action: controlApprove}) }
+func (c *controller) ApproveTuning() error { return c.do(controlRequest{action: controlTuning}) }
+
+func (c *controller) do(req controlRequest) error {
+	req.reply = make(chan error, 1)
+	select {
+	case c.requests <- req:
+		return <-req.reply
+	case <-c.done:
+		return errors.New("trading loop has stopped")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"tradingbot/internal/secrets"
+)
+
+// runConfigEncrypt implements `tradingbot config encrypt`: it prompts for the API
+// credentials and writes them to an encrypted credentials file, so they never
+// have to be stored in plaintext.
+func runConfigEncrypt(args []string) error {
+	fs := flag.NewFlagSet("config encrypt", flag.ExitOnError)
+	out := fs.String("out", "credentials.enc", "path of the encrypted credentials file to write")
+	keyfile := fs.String("keyfile", "", "file holding the passphrase (default: prompt or "+secrets.PassphraseEnv+")")
+	fs.Parse(args)
+
+	in := bufio.NewReader(os.Stdin)
+	values := map[string]string{}
+	for _, key := range []string{secrets.KeyAppKey, secrets.KeyAppSecret, secrets.KeyDBPassword} {
+		value, err := secrets.Prompt(in, key+" (leave empty to skip)")
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", key, err)
+		}
+		if value != "" {
+			values[key] = value
+		}
+	}
+
+	passphrase, err := secrets.ReadPassphrase(*keyfile, in)
+	if err != nil {
+		return err
+	}
+
+	data, err := secrets.Encrypt(values, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*out, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials file: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote encrypted credentials to %s\n", *out)
+	return nil
+}
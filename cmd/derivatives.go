@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/derivatives"
+	"tradingbot/internal/exchange"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// runDerivatives implements `tradingbot derivatives`.
+func runDerivatives(args []string) error {
+	fs := flag.NewFlagSet("derivatives", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	exch, err := connectDerivatives(cfg)
+	if err != nil {
+		return err
+	}
+
+	margin, err := exch.GetDerivativesMargin()
+	if err != nil {
+		return err
+	}
+	positions, err := exch.GetDerivativePositions()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deposit %.0f, orderable %.0f, initial margin %.0f, maintenance margin %.0f\n\n",
+		margin.Deposit, margin.Orderable, margin.Initial, margin.Maintenance)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tNAME\tKIND\tSIDE\tQTY\tAVG PRICE\tPRICE\tP/L")
+	for _, p := range positions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.0f\t%.2f\t%.2f\t%.0f\n", p.Code, p.Name, p.Kind, p.Side, p.Quantity, p.AvgPrice, p.CurrentPrice, p.ProfitLoss)
+	}
+	return w.Flush()
+}
+
+// runDerivativesPlan implements `tradingbot derivatives plan`.
+func runDerivativesPlan(args []string) error {
+	fs := flag.NewFlagSet("derivatives plan", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	place := fs.Bool("place", false, "place the planned orders")
+	live := addLiveFlag(fs)
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	if !cfg.Derivatives.Enabled {
+		return fmt.Errorf("derivatives are not enabled; set derivatives.enabled")
+	}
+	exch, err := connectDerivatives(cfg)
+	if err != nil {
+		return err
+	}
+
+	holdings, err := exch.GetPositions()
+	if err != nil {
+		return errors.Wrap(err, "failed to get stock positions")
+	}
+	positions, err := exch.GetDerivativePositions()
+	if err != nil {
+		return errors.Wrap(err, "failed to get derivatives positions")
+	}
+	orders, err := derivatives.Plan(cfg.Derivatives, holdings, positions, exch, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(orders) == 0 {
+		fmt.Println("Derivatives positions are in line with the holdings")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tSIDE\tQTY\tPRICE\tREASON")
+	for _, o := range orders {
+		fmt.Fprintf(w, "%s\t%s\t%.0f\t%.2f\t%s\n", o.Code, o.Side, o.Quantity, o.Price, o.Reason)
+	}
+	if err := w.Flush(); err != nil || !*place {
+		return err
+	}
+	if err := checkLive(cfg, *live); err != nil {
+		return err
+	}
+	if err := confirmLive(cfg, exch, os.Stdin); err != nil {
+		return err
+	}
+	if !exch.Armed() {
+		return fmt.Errorf("derivatives plan needs exchange.approval %s to place live orders", config.LiveApprovalPrompt)
+	}
+
+	for _, o := range orders {
+		orderNo, err := exch.PlaceDerivativeOrder(o)
+		if err != nil {
+			return err
+		}
+		log.WithFields(logrus.Fields{"code": o.Code, "side": o.Side, "quantity": o.Quantity, "order_no": orderNo}).Info("Derivative order placed")
+	}
+	return nil
+}
+
+// connectDerivatives connects to the exchange using the futures and options
+// account.
+func connectDerivatives(cfg *config.Config) (*exchange.KISExchange, error) {
+	exch, err := connectExchange(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exch.DerivativesAccountNo = cfg.Derivatives.AccountNo
+	return exch, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/database"
+	"tradingbot/internal/export"
+	"tradingbot/internal/report"
+
+	"github.com/pkg/errors"
+)
+
+// exportFlags are shared by the export commands.
+type exportFlags struct {
+	config *configFlags
+	from   *string
+	to     *string
+	format *string
+	output *string
+}
+
+func addExportFlags(fs *flag.FlagSet) *exportFlags {
+	return &exportFlags{
+		config: addConfigFlags(fs),
+		from:   fs.String("from", "", "first KST date, YYYY-MM-DD (default: the beginning)"),
+		to:     fs.String("to", "", "last KST date, YYYY-MM-DD (default: today)"),
+		format: fs.String("format", export.FormatCSV, "output format: csv or xlsx"),
+		output: fs.String("o", "", "output file (default: stdout for csv, <table>.xlsx for xlsx)"),
+	}
+}
+
+// period returns the configuration and the period to export.
+func (f *exportFlags) period() (*config.Config, time.Time, time.Time, error) {
+	if *f.format != export.FormatCSV && *f.format != export.FormatXLSX {
+		return nil, time.Time{}, time.Time{}, fmt.Errorf("unknown format %q, want csv or xlsx", *f.format)
+	}
+	start, end, err := report.ParsePeriod(*f.from, *f.to, time.Now())
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, err
+	}
+	cfg, _, err := loadConfig(f.config)
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, err
+	}
+	return cfg, start, end, nil
+}
+
+// write writes table to the output file, or to stdout.
+func (f *exportFlags) write(table export.Table) error {
+	output := *f.output
+	if output == "" && *f.format == export.FormatXLSX {
+		output = table.Name + ".xlsx"
+	}
+	if output == "" {
+		return export.Write(os.Stdout, table, *f.format)
+	}
+	if err := writeFile(output, func(w io.Writer) error { return export.Write(w, table, *f.format) }); err != nil {
+		return errors.Wrapf(err, "failed to write %s", output)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d %s rows to %s\n", len(table.Rows), table.Name, output)
+	return nil
+}
+
+// runExportTrades implements `tradingbot export trades`.
+func runExportTrades(args []string) error {
+	fs := flag.NewFlagSet("export trades", flag.ExitOnError)
+	ef := addExportFlags(fs)
+	fs.Parse(args)
+
+	cfg, start, end, err := ef.period()
+	if err != nil {
+		return err
+	}
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	orders, err := db.ListOrdersBetween(start, end)
+	if err != nil {
+		return err
+	}
+	return ef.write(export.Trades(orders))
+}
+
+// runExportCandles implements `tradingbot export candles`.
+func runExportCandles(args []string) error {
+	fs := flag.NewFlagSet("export candles", flag.ExitOnError)
+	ef := addExportFlags(fs)
+	symbols := fs.String("symbol", "", "comma-separated stock codes (default: the configured symbols)")
+	timeframe := fs.String("timeframe", "1d", "candle timeframe, e.g. 1d or 5m")
+	fs.Parse(args)
+
+	tf, err := config.ParseTimeframe(*timeframe)
+	if err != nil {
+		return err
+	}
+	cfg, start, end, err := ef.period()
+	if err != nil {
+		return err
+	}
+	codes := cfg.TradingSymbols()
+	if *symbols != "" {
+		codes = strings.Split(*symbols, ",")
+	}
+
+	// Candles are read from the database the database market data provider
+	// reads.
+	url := cfg.MarketData.DatabaseURL
+	if url == "" {
+		url = cfg.DatabaseURL
+	}
+	db, err := database.NewConnection(url)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	table := export.Candles(nil)
+	for _, code := range codes {
+		candles, err := db.ListCandles(code, tf, start, end)
+		if err != nil {
+			return err
+		}
+		table.Rows = append(table.Rows, export.Candles(candles).Rows...)
+	}
+	return ef.write(table)
+}
+
+// runExportEquity implements `tradingbot export equity`.
+func runExportEquity(args []string) error {
+	fs := flag.NewFlagSet("export equity", flag.ExitOnError)
+	ef := addExportFlags(fs)
+	fs.Parse(args)
+
+	cfg, start, end, err := ef.period()
+	if err != nil {
+		return err
+	}
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	snapshots, err := db.ListAccountSnapshots(start, end)
+	if err != nil {
+		return err
+	}
+	table, err := export.Equity(snapshots)
+	if err != nil {
+		return err
+	}
+	return ef.write(table)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"tradingbot/internal/database"
+	"tradingbot/internal/importer"
+	"tradingbot/internal/market"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// runImport implements `tradingbot import`.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	symbol := fs.String("symbol", "", "stock code of files without a code column (default: from a Yahoo file name, e.g. 005930.KS.csv)")
+	dateFlag := fs.String("date", "", "trading day of files without a date column, YYYY-MM-DD, e.g. a KRX export of all stocks")
+	fx := fs.Float64("fx", 1, "KRW per unit of the file's currency")
+	raw := fs.Bool("raw", false, "keep the file's prices instead of scaling them to the adjusted close")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: import [flags] <file.csv>...")
+	}
+	if *fx <= 0 {
+		return fmt.Errorf("-fx must be positive")
+	}
+	opts := importer.Options{FX: *fx, Raw: *raw}
+	if *dateFlag != "" {
+		date, err := time.ParseInLocation("2006-01-02", *dateFlag, market.KST)
+		if err != nil {
+			return errors.Wrap(err, "invalid -date")
+		}
+		opts.Date = date
+	}
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	// Import into the database the database market data provider reads.
+	url := cfg.MarketData.DatabaseURL
+	if url == "" {
+		url = cfg.DatabaseURL
+	}
+	db, err := database.NewConnection(url)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, path := range fs.Args() {
+		opts.Symbol = *symbol
+		if opts.Symbol == "" {
+			opts.Symbol = symbolFromFile(path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		result, err := importer.Read(f, opts)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		if err := db.SaveDailyCandles(result.Candles); err != nil {
+			return errors.Wrapf(err, "failed to import %s", path)
+		}
+		log.WithFields(logrus.Fields{"file": path, "candles": len(result.Candles), "skipped": result.Skipped}).Info("Imported daily candles")
+	}
+	return nil
+}
+
+// symbolFromFile returns the stock code a Yahoo Finance download is named
+// after, without the exchange suffix: 005930 for 005930.KS.csv. Other names
+// give no code.
+func symbolFromFile(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for _, suffix := range []string{".KS", ".KQ"} {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return ""
+}
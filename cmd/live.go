@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"tradingbot/internal/config"
+	"tradingbot/internal/exchange"
+
+	"github.com/sirupsen/logrus"
+)
+
+// liveFlag must be given to every command that can send orders in live mode.
+const liveFlag = "i-understand-live-trading"
+
+func addLiveFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool(liveFlag, false, "allow real orders when exchange.mode is live")
+}
+
+// checkLive refuses to go on in live mode without the live flag, before
+// anything is connected. Observers send no orders and need no flag.
+func checkLive(cfg *config.Config, understood bool) error {
+	if cfg.Exchange.Mode != config.ModeLive || cfg.Exchange.Observe || understood {
+		return nil
+	}
+	return fmt.Errorf("exchange.mode is live: rerun with -%s to send real orders, or use a paper profile", liveFlag)
+}
+
+// confirmLive arms exch for live orders once they are confirmed. With
+// config.LiveApprovalAPI the exchange is left disarmed until someone approves
+// the run through the API; otherwise the account number must be typed on in.
+func confirmLive(cfg *config.Config, exch *exchange.KISExchange, in io.Reader) error {
+	if exch.Armed() || exch.Observing() {
+		return nil
+	}
+	if cfg.Exchange.Approval == config.LiveApprovalAPI {
+		log.WithField("account", cfg.Exchange.AccountNo).Warn("Live orders are held until approved with POST /control/live/approve")
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "LIVE TRADING: real orders will be sent for account %s.\nType the account number to confirm: ", cfg.Exchange.AccountNo)
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("live trading not confirmed: %v", err)
+	}
+	if strings.TrimSpace(line) != cfg.Exchange.AccountNo {
+		return fmt.Errorf("live trading not confirmed: account number does not match")
+	}
+	exch.Arm()
+	log.WithFields(logrus.Fields{"account": cfg.Exchange.AccountNo}).Warn("Live trading confirmed")
+	return nil
+}
@@ -1,171 +1,278 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"os"
-	"time"
-	"tradingbot/internal/backtesting"
+	"runtime/debug"
+	"strings"
 	"tradingbot/internal/config"
 	"tradingbot/internal/database"
 	"tradingbot/internal/exchange"
-	"tradingbot/internal/models"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/marketdata"
+	"tradingbot/internal/secrets"
 	"tradingbot/internal/strategy"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-var log = logrus.New()
+var log = logging.New()
 
-func init() {
-	log.SetOutput(os.Stdout)
-	log.SetLevel(logrus.InfoLevel)
-	log.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
+const defaultConfigPath = "config.yaml"
+
+// command is a node in the CLI tree. Leaf commands have run set; groups such as
+// `config` only hold subcommands. A command with both, such as `backtest`, runs
+// itself unless its first argument names a subcommand.
+type command struct {
+	name        string
+	args        string
+	summary     string
+	run         func(args []string) error
+	subcommands []*command
+}
+
+var commands = []*command{
+	{name: "run", summary: "run the live trading loop", run: runTrading},
+	{name: "backtest", summary: "backtest the configured strategy on historical data", run: runBacktestCommand, subcommands: []*command{
+		{name: "list", summary: "list stored backtest runs", run: runBacktestList},
+		{name: "rebalance", summary: "backtest the rebalancing of the target weights", run: runBacktestRebalance},
+		{name: "compare", args: "<id1> <id2>", summary: "show the metric and parameter differences of two backtest runs", run: runBacktestCompare},
+	}},
+	{name: "replay", summary: "run the trading pipeline on recorded prices with a paper exchange", run: runReplay},
+	{name: "soak", summary: "run the trading pipeline on synthetic prices at a high rate and report latency and leaks", run: runSoak},
+	{name: "backfill", summary: "download daily candle history from KIS into the market data tables", run: runBackfill},
+	{name: "import", args: "<file>...", summary: "import daily candles from Yahoo Finance or KRX CSV files", run: runImport},
+	{name: "quality", args: "[code...]", summary: "report missing sessions, bad prices, duplicates and jumps in daily candles", run: runQuality},
+	{name: "optimize", summary: "search strategy parameters with backtests", run: runOptimize},
+	{name: "balance", summary: "show the account cash balance", run: runBalance},
+	{name: "positions", summary: "show current holdings", run: runPositions},
+	{name: "orders", summary: "list recently saved orders", run: runOrders},
+	{name: "manual", summary: "intervene in the running bot; interventions are recorded in the audit log", subcommands: []*command{
+		{name: "signal", args: "<buy|sell> <code>", summary: "submit a one-off signal through the risk checks", run: runManualSignal},
+		{name: "close", args: "<code>", summary: "sell a whole position, bypassing the risk checks", run: runManualClose},
+		{name: "record", args: "<buy|sell> <code> <qty> <price>", summary: "record a trade made outside the bot", run: runManualRecord},
+		{name: "approve", summary: "approve the orders of a live run started with exchange.approval api", run: runManualApprove},
+	}},
+	{name: "reconcile", summary: "compare stored orders with the broker's positions and open orders", run: runReconcile},
+	{name: "report", summary: "attribute PnL, fees and turnover to strategies and symbols", run: runReport, subcommands: []*command{
+		{name: "execution", summary: "report the implementation shortfall of fills against signal prices and closes", run: runReportExecution},
+	}},
+	{name: "export", summary: "export history as CSV or Excel for spreadsheets and pandas", subcommands: []*command{
+		{name: "trades", summary: "export the saved orders", run: runExportTrades},
+		{name: "candles", summary: "export stored candles of the configured symbols", run: runExportCandles},
+		{name: "equity", summary: "export the daily account equity from the account snapshots", run: runExportEquity},
+	}},
+	{name: "tax", summary: "track tax lots and report realized gains for tax filing", subcommands: []*command{
+		{name: "gains", summary: "report a year's realized gains and losses per symbol, optionally as CSV", run: runTaxGains},
+		{name: "lots", summary: "list the open tax lots", run: runTaxLots},
+		{name: "select", args: "<sell-id> <lot-id>=<qty>...", summary: "select the lots a sell disposes of, for specific identification", run: runTaxSelect},
+	}},
+	{name: "screen", summary: "screen a symbol universe by price, volume, volatility and indicators", run: runScreen},
+	{name: "symbols", args: "[code...]", summary: "show symbol master data or the members of a universe", run: runSymbols},
+	{name: "derivatives", summary: "show futures and options positions and margin", run: runDerivatives, subcommands: []*command{
+		{name: "plan", summary: "plan covered calls and futures hedges for the stock holdings", run: runDerivativesPlan},
+	}},
+	{name: "quote", args: "<code>", summary: "show the current price of a stock", run: runQuote},
+	{name: "audit", summary: "query or verify the audit log of trading decisions", run: runAudit},
+	{name: "metrics", summary: "monitoring of the bot with Prometheus and Grafana", subcommands: []*command{
+		{name: "export-dashboards", summary: "write the Grafana dashboard and Prometheus alert rules of the bot's metrics", run: runMetricsExportDashboards},
+	}},
+	{name: "config", summary: "inspect and manage configuration", subcommands: []*command{
+		{name: "validate", args: "[file]", summary: "validate a config file and print the effective configuration", run: runConfigValidate},
+		{name: "encrypt", summary: "write API credentials to an encrypted file", run: runConfigEncrypt},
+	}},
 }
 
 func main() {
+	defer logging.Close()
+	// The components of `run` recover from their own panics; see
+	// supervisor.Supervisor. One reaching here leaves the bot in an unknown
+	// state, so it exits with an error for its process manager to restart it.
 	defer func() {
 		if r := recover(); r != nil {
-			log.WithField("panic", r).Error("Recovered from panic")
+			log.WithField("panic", r).Errorf("Recovered from panic\n%s", debug.Stack())
+			logging.Close()
+			os.Exit(2)
 		}
 	}()
 
-	log.Info("Starting trading bot...")
-
-	cfg, db, exch, strat, err := initialize("config.yaml")
-	if err != nil {
-		log.WithError(err).Fatal("Initialization failed")
+	if err := dispatch(commands, os.Args[1:], "tradingbot"); err != nil {
+		log.WithError(err).Fatal("Command failed")
 	}
-	defer db.Close()
-
-	// Run backtesting
-	runBacktest(cfg)
+}
 
-	// Initial market check
-	marketData, err := exch.GetSamsungPrice()
-	if !logAndCheckError(err, "Samsung Electronics Stock Price", logrus.Fields{"price": marketData.StckPrpr}) {
-		log.WithField("price", marketData.StckPrpr).Info("Samsung Electronics Stock Price")
+func dispatch(cmds []*command, args []string, prefix string) error {
+	if len(args) == 0 {
+		printUsage(cmds, prefix)
+		return fmt.Errorf("missing command")
 	}
-
-	// Initial balance check
-	balance, err := exch.GetBalance()
-	if !logAndCheckError(err, "Account Balance", logrus.Fields{"balance": balance}) {
-		log.WithField("balance", balance).Info("Account Balance")
+	if args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		printUsage(cmds, prefix)
+		return nil
 	}
 
-	log.Info("Entering main loop...")
-	for {
-		if err := runTradingCycle(cfg, exch, strat, db); err != nil {
-			log.WithError(err).Error("Error in trading cycle")
+	for _, cmd := range cmds {
+		if cmd.name != args[0] {
+			continue
 		}
-
-		log.WithField("interval", cfg.ParsedInterval).Info("Sleeping")
-		time.Sleep(cfg.ParsedInterval)
+		if cmd.run != nil && (len(args) < 2 || !hasCommand(cmd.subcommands, args[1])) {
+			return cmd.run(args[1:])
+		}
+		return dispatch(cmd.subcommands, args[1:], prefix+" "+cmd.name)
 	}
+
+	printUsage(cmds, prefix)
+	return fmt.Errorf("unknown command %q", strings.Join(append([]string{prefix}, args[0]), " "))
 }
 
-func runTradingCycle(cfg *config.Config, exch *exchange.KISExchange, strat *strategy.MovingAverage, db *database.DB) error {
-	marketData, err := exch.GetMarketData(cfg.TradingPair)
-	if err != nil {
-		return errors.Wrap(err, "failed to get market data")
+func hasCommand(cmds []*command, name string) bool {
+	for _, cmd := range cmds {
+		if cmd.name == name {
+			return true
+		}
 	}
+	return false
+}
 
-	signal := strat.Analyze(marketData)
-	log.WithField("signal", signal.Type).Info("Strategy analysis result")
-
-	if signal.Type != models.HoldSignal {
-		log.WithFields(logrus.Fields{
-			"type":   signal.Type,
-			"amount": signal.Amount,
-		}).Info("Signal generated")
-
-		order, err := exch.PlaceOrder(signal)
-		if err != nil {
-			return errors.Wrap(err, "failed to place order")
-		}
+func printUsage(cmds []*command, prefix string) {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", prefix)
+	for _, cmd := range cmds {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", prefix)
+}
 
-		log.WithField("order", order).Info("Order placed")
+// configFlags are accepted by every command that needs the configuration.
+type configFlags struct {
+	path    string
+	profile string
+}
 
-		if err := db.SaveOrder(order); err != nil {
-			return errors.Wrap(err, "failed to save order")
-		}
-	} else {
-		log.Info("No trading action needed")
-	}
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	f := &configFlags{}
+	fs.StringVar(&f.path, "config", defaultConfigPath, "path of the config file (YAML, JSON or TOML)")
+	fs.StringVar(&f.profile, "profile", "", "config profile to use (e.g. paper, live)")
+	return f
+}
 
-	return nil
+// credentials tracks the secrets manager and the values last fetched from it.
+type credentials struct {
+	provider secrets.Provider
+	values   map[string]string
 }
 
-func runBacktest(cfg *config.Config) {
-	log.Info("Starting backtesting...")
+// loadConfig loads the configuration and resolves credentials from the configured
+// secrets provider.
+func loadConfig(f *configFlags) (*config.Config, credentials, error) {
+	var creds credentials
 
-	exch, err := exchange.New(cfg.Exchange)
+	cfg, err := config.LoadProfile(f.path, f.profile)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to initialize exchange")
+		return nil, creds, err
+	}
+	if err := logging.Configure(cfg.Logging, cfg.LogLevel); err != nil {
+		return nil, creds, errors.Wrap(err, "failed to configure logging")
 	}
 
-	stockCode := "041510"
-	days := 100 // 100일 데이터
-
-	historicalData, err := exch.GetHistoricalData(stockCode, days)
+	creds.provider, err = secrets.New(cfg.Secrets)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to get historical data")
+		return nil, creds, err
 	}
-
-	strategyConfig := models.StrategyConfig{
-		ShortPeriod: cfg.Strategy.ShortPeriod,
-		LongPeriod:  cfg.Strategy.LongPeriod,
-		Threshold:   cfg.Strategy.Threshold,
+	if creds.provider != nil {
+		creds.values, err = creds.provider.Fetch(context.Background())
+		if err != nil {
+			return nil, creds, errors.Wrap(err, "failed to fetch secrets")
+		}
+		if err := secrets.Apply(cfg, creds.values); err != nil {
+			return nil, creds, err
+		}
 	}
-	strat := strategy.NewMovingAverage(strategyConfig)
 
-	backtester := backtesting.NewBacktester(strat, historicalData, 10000000, 0.0025)
-
-	result := backtester.Run()
-
-	log.WithFields(logrus.Fields{
-		"TotalTrades":       result.TotalTrades,
-		"WinningTrades":     result.WinningTrades,
-		"LosingTrades":      result.LosingTrades,
-		"TotalProfit":       result.TotalProfit,
-		"MaxDrawdown":       result.MaxDrawdown * 100,
-		"WinRate":           result.WinRate * 100,
-		"AvgProfitPerTrade": result.AverageProfitPerTrade,
-	}).Info("Backtesting results")
+	return cfg, creds, nil
 }
 
-func initialize(cfgPath string) (*config.Config, *database.DB, *exchange.KISExchange, *strategy.MovingAverage, error) {
-	cfg, err := config.Load(cfgPath)
+func connectExchange(cfg *config.Config) (*exchange.KISExchange, error) {
+	// Get access token dynamically
+	accessToken, err := exchange.GetAccessToken(cfg.Exchange)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, errors.Wrap(err, "failed to get access token")
 	}
+	cfg.Exchange.AccessToken = accessToken
+
+	return exchange.New(cfg.Exchange)
+}
 
-	db, err := database.NewConnection(cfg.DatabaseURL)
+// connectMarketData returns the configured market data provider. With the
+// default provider it connects to the exchange, or returns exch when already
+// connected. A configured fallback serves quotes while the provider fails,
+// configured quality checks vet the history it returns and a configured cache
+// keeps the checked candles.
+func connectMarketData(cfg *config.Config, exch *exchange.KISExchange) (marketdata.Provider, error) {
+	provider, err := connectProvider(cfg, exch)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, err
+	}
+	if cfg.MarketData.Fallback == config.MarketDataNaver {
+		provider = marketdata.NewFallback(provider, marketdata.NewNaver(cfg.MarketData), cfg.MarketData)
+	}
+	if cfg.MarketData.Quality != "" {
+		cal, err := market.NewCalendar(cfg.Market)
+		if err != nil {
+			return nil, err
+		}
+		provider = marketdata.NewChecked(provider, cfg.MarketData, cal)
 	}
+	if cfg.MarketData.Cache.Enabled {
+		provider = marketdata.NewCached(provider, cfg.MarketData.Cache)
+	}
+	return provider, nil
+}
 
-	// Get access token dynamically
-	accessToken, err := exchange.GetAccessToken(cfg.Exchange.AppKey, cfg.Exchange.AppSecret)
+func connectProvider(cfg *config.Config, exch *exchange.KISExchange) (marketdata.Provider, error) {
+	switch cfg.MarketData.Provider {
+	case config.MarketDataDatabase:
+		url := cfg.MarketData.DatabaseURL
+		if url == "" {
+			url = cfg.DatabaseURL
+		}
+		db, err := database.NewConnection(url)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to connect market data database")
+		}
+		return marketdata.Candles{CandleSource: db}, nil
+	case config.MarketDataHTTP:
+		return marketdata.NewHTTP(cfg.MarketData), nil
+	}
+	if exch != nil {
+		return exch, nil
+	}
+	exch, err := connectExchange(cfg)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrap(err, "failed to get access token")
+		return nil, errors.Wrap(err, "failed to initialize exchange")
 	}
-	cfg.Exchange.AccessToken = accessToken
+	return exch, nil
+}
 
-	exch, err := exchange.New(cfg.Exchange)
+func newStrategy(cfg *config.Config) (strategy.Strategy, error) {
+	params, err := cfg.StrategyParamsFor(cfg.Strategy)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, err
 	}
+	return strategy.New(cfg.Strategy, params)
+}
 
-	strategyConfig := models.StrategyConfig{
-		ShortPeriod: cfg.Strategy.ShortPeriod,
-		LongPeriod:  cfg.Strategy.LongPeriod,
-		Threshold:   cfg.Strategy.Threshold,
+// setLogLevel applies the configured log level, keeping the current one when unset or invalid.
+func setLogLevel(level string) {
+	if level == "" {
+		return
+	}
+	if err := logging.SetLevel(level); err != nil {
+		log.WithError(err).Warn("Invalid log level, keeping current")
 	}
-	strat := strategy.NewMovingAverage(strategyConfig)
-
-	return cfg, db, exch, strat, nil
 }
 
 func logAndCheckError(err error, message string, fields logrus.Fields) bool {
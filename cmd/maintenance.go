@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"tradingbot/internal/backfill"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/cron"
+	"tradingbot/internal/database"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/market"
+	"tradingbot/internal/notify"
+	"tradingbot/internal/scheduler"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBackfillDays = 30
+	defaultPruneDays    = 90
+)
+
+// maintenanceDB is the database connection of the maintenance tasks, swapped
+// by the main loop when the database password is rotated.
+type maintenanceDB struct {
+	mu sync.Mutex
+	db *database.DB
+}
+
+func (m *maintenanceDB) get() *database.DB {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db
+}
+
+func (m *maintenanceDB) set(db *database.DB) {
+	m.mu.Lock()
+	m.db = db
+	m.mu.Unlock()
+}
+
+// newMaintenance creates a runner for the configured maintenance tasks. email
+// is the daily report notifier, nil when email is disabled; with a
+// report_email task it no longer sends reports on its own.
+func newMaintenance(cfg *config.Config, db *maintenanceDB, exch *exchange.KISExchange, email *notify.EmailNotifier) (*scheduler.CronRunner, error) {
+	m := cfg.Maintenance
+	zone := m.Timezone
+	if zone == "" {
+		zone = config.DefaultMaintenanceTimezone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := market.NewCalendar(cfg.Market)
+	if err != nil {
+		return nil, err
+	}
+	symbols := cfg.TradingSymbols()
+
+	runner := scheduler.NewCronRunner(clock.Real)
+	for _, t := range m.Tasks {
+		schedule, err := cron.Parse(t.Schedule, loc)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance task %s: %v", t.Task, err)
+		}
+		run, err := maintenanceTask(t, db, exch, email, cal, symbols)
+		if err != nil {
+			return nil, err
+		}
+		if t.TradingDays {
+			run = onTradingDays(cal, run)
+		}
+		runner.Add(t.Task, schedule, run)
+		log.WithFields(logrus.Fields{"task": t.Task, "schedule": schedule.String(), "timezone": loc}).Info("Maintenance task scheduled")
+	}
+	return runner, nil
+}
+
+// maintenanceTask returns the function carrying out t.
+func maintenanceTask(t config.MaintenanceTask, db *maintenanceDB, exch *exchange.KISExchange, email *notify.EmailNotifier, cal *market.Calendar, symbols []string) (func() error, error) {
+	switch t.Task {
+	case config.TaskTokenRefresh:
+		return exch.RefreshToken, nil
+
+	case config.TaskEODSnapshot:
+		return func() error {
+			cash, err := exch.GetBalance()
+			if err != nil {
+				return err
+			}
+			positions, err := exch.GetPositions()
+			if err != nil {
+				return err
+			}
+			return db.get().SaveAccountSnapshot(time.Now(), cash, positions)
+		}, nil
+
+	case config.TaskBackfill:
+		days := t.Days
+		if days == 0 {
+			days = defaultBackfillDays
+		}
+		return func() error {
+			b := backfill.New(exch, db.get(), cal)
+			b.Interval = time.Second / backfillRateLive
+			if exch.Paper {
+				b.Interval = time.Second / backfillRatePaper
+			}
+			to := time.Now().In(market.KST)
+			from := to.AddDate(0, 0, -days)
+			var failed []string
+			for _, symbol := range symbols {
+				symbol = strings.TrimSpace(symbol)
+				if _, err := b.Run(symbol, from, to); err != nil {
+					log.WithError(err).WithField("symbol", symbol).Warn("Backfill failed")
+					failed = append(failed, symbol)
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("backfill failed for %s", strings.Join(failed, ", "))
+			}
+			return nil
+		}, nil
+
+	case config.TaskReportEmail:
+		if email == nil {
+			return nil, fmt.Errorf("maintenance task %s requires notify.email.enabled", t.Task)
+		}
+		email.SendOnDemand()
+		return func() error {
+			email.SendNow()
+			return nil
+		}, nil
+
+	case config.TaskPrune:
+		days := t.Days
+		if days == 0 {
+			days = defaultPruneDays
+		}
+		return func() error {
+			n, err := db.get().PruneCandles(time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				return err
+			}
+			log.WithFields(logrus.Fields{"deleted": n, "days": days}).Info("Old candles pruned")
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown maintenance task %q", t.Task)
+}
+
+// onTradingDays wraps run to do nothing on days without a session.
+func onTradingDays(cal *market.Calendar, run func() error) func() error {
+	return func() error {
+		if _, ok := cal.SessionOn(time.Now()); !ok {
+			log.Debug("No session today, maintenance task skipped")
+			return nil
+		}
+		return run()
+	}
+}
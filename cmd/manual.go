@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"tradingbot/internal/apiclient"
+	"tradingbot/internal/config"
+
+	"github.com/pkg/errors"
+)
+
+// The manual commands are carried out by the running bot, through its API, so
+// they go through its trading loop and end up in its audit trail.
+
+// runManualSignal implements `tradingbot manual signal`.
+func runManualSignal(args []string) error {
+	fs := flag.NewFlagSet("manual signal", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	quantity := fs.Float64("quantity", 0, "number of shares")
+	notional := fs.Float64("notional", 0, "KRW amount, converted into whole shares at the current price")
+	price := fs.Float64("price", 0, "limit price (default: market order)")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: manual signal [flags] <buy|sell> <code>")
+	}
+
+	req := &apiclient.SignalRequest{Action: fs.Arg(0), Symbol: fs.Arg(1), Quantity: *quantity, Notional: *notional, Price: *price}
+	return callManual(cf, func(ctx context.Context, c *apiclient.Client) (interface{}, error) {
+		return c.InjectSignal(ctx, req)
+	})
+}
+
+// runManualClose implements `tradingbot manual close`.
+func runManualClose(args []string) error {
+	fs := flag.NewFlagSet("manual close", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: manual close <code>")
+	}
+	return callManual(cf, func(ctx context.Context, c *apiclient.Client) (interface{}, error) {
+		return c.ClosePosition(ctx, &apiclient.CloseRequest{Symbol: fs.Arg(0)})
+	})
+}
+
+// runManualRecord implements `tradingbot manual record`.
+func runManualRecord(args []string) error {
+	fs := flag.NewFlagSet("manual record", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	at := fs.String("time", "", "time of the trade, RFC 3339 (default: now)")
+	fs.Parse(args)
+	if fs.NArg() != 4 {
+		return fmt.Errorf("usage: manual record [flags] <buy|sell> <code> <quantity> <price>")
+	}
+
+	req := &apiclient.TradeRequest{Side: fs.Arg(0), Symbol: fs.Arg(1)}
+	if _, err := fmt.Sscan(fs.Arg(2), &req.Quantity); err != nil {
+		return fmt.Errorf("invalid quantity %q", fs.Arg(2))
+	}
+	if _, err := fmt.Sscan(fs.Arg(3), &req.Price); err != nil {
+		return fmt.Errorf("invalid price %q", fs.Arg(3))
+	}
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return errors.Wrap(err, "invalid -time")
+		}
+		req.Time = &t
+	}
+	return callManual(cf, func(ctx context.Context, c *apiclient.Client) (interface{}, error) {
+		return c.RecordTrade(ctx, req)
+	})
+}
+
+// runManualApprove implements `tradingbot manual approve`.
+func runManualApprove(args []string) error {
+	fs := flag.NewFlagSet("manual approve", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+	return callManual(cf, func(ctx context.Context, c *apiclient.Client) (interface{}, error) {
+		return c.ApproveLive(ctx)
+	})
+}
+
+// callManual runs call against the running bot's API and prints the reply.
+func callManual(cf *configFlags, call func(ctx context.Context, c *apiclient.Client) (interface{}, error)) error {
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	if !cfg.API.Enabled {
+		return fmt.Errorf("manual commands need the API of the running bot; set api.enabled")
+	}
+
+	reply, err := call(context.Background(), apiclient.New(apiURL(cfg.API), cfg.API.Token))
+	if err != nil {
+		if _, ok := err.(*apiclient.Error); ok {
+			return err
+		}
+		return errors.Wrap(err, "failed to reach the running bot")
+	}
+	return json.NewEncoder(os.Stdout).Encode(reply)
+}
+
+// apiURL is the base URL of the API listening at cfg.Listen, on this host if
+// the address has none.
+func apiURL(cfg config.APIConfig) string {
+	addr := cfg.Listen
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"tradingbot/internal/metrics"
+
+	"github.com/pkg/errors"
+)
+
+// Files written by `tradingbot metrics export-dashboards`.
+const (
+	dashboardFile  = "tradingbot-dashboard.json"
+	alertRulesFile = "tradingbot-alerts.yml"
+)
+
+// runMetricsExportDashboards implements `tradingbot metrics export-dashboards`.
+func runMetricsExportDashboards(args []string) error {
+	fs := flag.NewFlagSet("metrics export-dashboards", flag.ExitOnError)
+	dir := fs.String("o", ".", "directory to write the dashboard and alert rules to")
+	fs.Parse(args)
+
+	dashboard, err := metrics.Dashboard()
+	if err != nil {
+		return errors.Wrap(err, "failed to build the dashboard")
+	}
+	rules, err := metrics.AlertRules()
+	if err != nil {
+		return errors.Wrap(err, "failed to build the alert rules")
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	for name, data := range map[string][]byte{dashboardFile: dashboard, alertRulesFile: rules} {
+		path := filepath.Join(*dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Println("Wrote", path)
+	}
+	fmt.Println("Import the dashboard in Grafana and add the rules to rule_files in prometheus.yml;")
+	fmt.Println("scrape /metrics of the API with the API token or a read key as bearer token.")
+	return nil
+}
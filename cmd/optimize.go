@@ -0,0 +1,295 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	stdlog "log"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+	"tradingbot/internal/optimizer"
+
+	"github.com/pkg/errors"
+)
+
+// defaultGeneticSpace is searched by -method genetic when no -param is given.
+var defaultGeneticSpace = map[string][]string{
+	"moving_average": {"short_period=2:30", "long_period=10:120", "threshold=0:0.02"},
+	"nav_deviation":  {"entry_discount=0.005:0.05", "exit_premium=-0.01:0.03"},
+}
+
+// paramFlags collects repeated -param flags.
+type paramFlags []optimizer.Param
+
+func (p *paramFlags) String() string { return fmt.Sprint(*p) }
+
+func (p *paramFlags) Set(s string) error {
+	param, err := optimizer.ParseParam(s)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, param)
+	return nil
+}
+
+// runOptimize implements `tradingbot optimize`: a grid search over the moving
+// average periods, or a genetic search over any strategy's parameters,
+// backtested on the same historical data.
+func runOptimize(args []string) error {
+	fs := flag.NewFlagSet("optimize", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	method := fs.String("method", "grid", "search method: grid (moving_average periods) or genetic")
+	code := fs.String("code", "", "stock code to optimize on (default: trading_pair)")
+	days := fs.Int("days", 100, "number of days of history")
+	shortMin := fs.Int("short-min", 2, "smallest short period")
+	shortMax := fs.Int("short-max", 10, "largest short period")
+	longMin := fs.Int("long-min", 10, "smallest long period")
+	longMax := fs.Int("long-max", 40, "largest long period")
+	step := fs.Int("step", 1, "period step")
+	top := fs.Int("top", 10, "number of results to print")
+	balance := fs.Float64("balance", 10000000, "initial balance in KRW")
+	commission := fs.Float64("commission", 0.0025, "commission rate per trade")
+	name := fs.String("strategy", "", "strategy to search with -method genetic (default: strategy)")
+	var space paramFlags
+	fs.Var(&space, "param", "parameter range name=min:max for -method genetic, repeatable; integer unless a bound has a decimal point")
+	population := fs.Int("population", 40, "parameter sets per generation")
+	generations := fs.Int("generations", 30, "most generations to breed")
+	patience := fs.Int("patience", 5, "stop after this many generations without improvement (negative: never)")
+	mutation := fs.Float64("mutation", 0.2, "chance of mutating each parameter of a child")
+	fitness := fs.String("fitness", "sharpe", "fitness of -method genetic: sharpe, calmar or profit")
+	penalty := fs.Float64("penalty", 1, "drawdown penalty of -fitness profit")
+	seed := fs.Int64("seed", 1, "random seed of -method genetic")
+	holdout := fs.Float64("holdout", 0.3, "fraction of the history held out to cross-validate the results on (0: none)")
+	folds := fs.Int("folds", 2, "number of periods the held-out history is split into")
+	warmup := fs.Int("warmup", -1, "bars before each held-out period that only prime the strategy (default: the largest searched integer parameter)")
+	collapse := fs.Float64("collapse", 0.5, "flag results whose held-out Sharpe ratio falls below this fraction of the in-sample one")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	if *code == "" {
+		*code = cfg.TradingPair
+	}
+
+	if *method != "grid" && *method != "genetic" {
+		return fmt.Errorf("unknown method %q (want grid or genetic)", *method)
+	}
+	var fit optimizer.Fitness
+	switch *fitness {
+	case "sharpe":
+		fit = optimizer.FitnessSharpe
+	case "calmar":
+		fit = optimizer.FitnessCalmar
+	case "profit":
+		fit = optimizer.FitnessProfit(*penalty)
+	default:
+		return fmt.Errorf("unknown fitness %q (want sharpe, calmar or profit)", *fitness)
+	}
+	if *name == "" {
+		*name = cfg.Strategy
+	}
+	if *method == "genetic" && len(space) == 0 {
+		for _, s := range defaultGeneticSpace[*name] {
+			space.Set(s)
+		}
+		if len(space) == 0 {
+			return fmt.Errorf("no default parameters to search for strategy %q, use -param", *name)
+		}
+	}
+
+	var ma models.MovingAverageConfig
+	if params, err := cfg.StrategyParamsFor("moving_average"); err == nil {
+		if err := params.Decode(&ma); err != nil {
+			return err
+		}
+	}
+
+	provider, err := connectMarketData(cfg, nil)
+	if err != nil {
+		return err
+	}
+	data, err := provider.GetHistoricalData(*code, *days)
+	if err != nil {
+		return errors.Wrap(err, "failed to get historical data")
+	}
+	if *warmup < 0 {
+		*warmup = *longMax
+		if *method == "genetic" {
+			*warmup = 0
+			for _, p := range space {
+				if p.Integer && int(p.Max) > *warmup {
+					*warmup = int(p.Max)
+				}
+			}
+		}
+	}
+	var held []optimizer.Fold
+	if *holdout > 0 {
+		if data, held, err = optimizer.HoldOut(data, *holdout, *folds, *warmup); err != nil {
+			return err
+		}
+	}
+	cv := crossValidation{folds: held, balance: *balance, commission: *commission, collapse: *collapse}
+
+	// The strategy logs every bar; silence it while running hundreds of backtests.
+	stdlog.SetOutput(ioutil.Discard)
+	defer stdlog.SetOutput(os.Stderr)
+
+	if *method == "genetic" {
+		base, _ := cfg.StrategyParamsFor(*name)
+		cv.strategy, cv.base = *name, base
+		result := optimizer.Genetic(optimizer.GeneticConfig{
+			Population:   *population,
+			Generations:  *generations,
+			Patience:     *patience,
+			MutationRate: *mutation,
+			Seed:         *seed,
+		}, space, optimizer.BacktestStrategy(*name, base, data, *balance, *commission), fit, *balance, len(data))
+		return printGenetic(result, space, *top, len(data), cv)
+	}
+
+	results := optimizer.GridSearchMovingAverage(data,
+		optimizer.Range{Min: *shortMin, Max: *shortMax, Step: *step},
+		optimizer.Range{Min: *longMin, Max: *longMax, Step: *step},
+		ma.Threshold, *balance, *commission)
+	if len(results) == 0 {
+		return fmt.Errorf("no valid parameter combinations")
+	}
+	if *top > 0 && len(results) > *top {
+		results = results[:*top]
+	}
+	base, _ := cfg.StrategyParamsFor("moving_average")
+	cv.strategy, cv.base = "moving_average", base
+	if len(cv.folds) > 0 {
+		cv.printHeader(len(data))
+		fmt.Println()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SHORT\tLONG\tTRADES\tWIN RATE\tPROFIT\tMAX DD\tSHARPE"+cv.columns())
+	overfit := 0
+	for _, r := range results {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%.1f%%\t%.0f\t%.1f%%\t%.2f",
+			r.Params.ShortPeriod, r.Params.LongPeriod, r.Backtest.TotalTrades,
+			r.Backtest.WinRate*100, r.Backtest.TotalProfit, r.Backtest.MaxDrawdown*100, r.Backtest.SharpeRatio)
+		params := config.StrategyParams{"short_period": r.Params.ShortPeriod, "long_period": r.Params.LongPeriod, "threshold": r.Params.Threshold}
+		if cv.printRow(w, params, r.Backtest) {
+			overfit++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	cv.printSummary(overfit, len(results))
+	return nil
+}
+
+// crossValidation checks optimizer results on the held-out folds.
+type crossValidation struct {
+	folds      []optimizer.Fold
+	strategy   string
+	base       config.StrategyParams
+	balance    float64
+	commission float64
+	collapse   float64
+}
+
+func (cv crossValidation) printHeader(inSample int) {
+	if len(cv.folds) == 0 {
+		return
+	}
+	heldOut := 0
+	for _, f := range cv.folds {
+		heldOut += len(f.Data) - f.Warmup
+	}
+	fmt.Printf("Searched on %d bars, cross-validated on %d held-out bars in %d periods\n", inSample, heldOut, len(cv.folds))
+}
+
+func (cv crossValidation) columns() string {
+	if len(cv.folds) == 0 {
+		return ""
+	}
+	return "\tOOS SHARPE\tOOS SD\tWORST OOS PROFIT\t"
+}
+
+// printRow ends the row of params, whose in-sample backtest is inSample, with
+// their held-out stability and reports whether they look overfit.
+func (cv crossValidation) printRow(w io.Writer, params config.StrategyParams, inSample backtesting.BacktestResult) bool {
+	if len(cv.folds) == 0 {
+		fmt.Fprintln(w)
+		return false
+	}
+	merged := make(config.StrategyParams, len(cv.base)+len(params))
+	for k, v := range cv.base {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	s, err := optimizer.CrossValidate(cv.strategy, merged, inSample, cv.folds, cv.balance, cv.commission, cv.collapse)
+	if err != nil {
+		fmt.Fprintf(w, "\t%v\n", err)
+		return false
+	}
+	flag := ""
+	if s.Overfit {
+		flag = "OVERFIT"
+	}
+	fmt.Fprintf(w, "\t%.2f\t%.2f\t%.0f\t%s\n", s.MeanSharpe, s.SharpeStdDev, s.WorstProfit, flag)
+	return s.Overfit
+}
+
+func (cv crossValidation) printSummary(overfit, shown int) {
+	if len(cv.folds) == 0 {
+		return
+	}
+	fmt.Printf("\n%d of %d results flagged OVERFIT: held-out Sharpe ratio below %.0f%% of in-sample\n", overfit, shown, cv.collapse*100)
+}
+
+// printGenetic prints the best top parameter sets of a genetic search.
+func printGenetic(result optimizer.GeneticResult, space []optimizer.Param, top, bars int, cv crossValidation) error {
+	candidates := result.Candidates
+	for len(candidates) > 0 && math.IsInf(candidates[len(candidates)-1].Fitness, -1) {
+		candidates = candidates[:len(candidates)-1]
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no valid parameter combinations")
+	}
+	fmt.Printf("%d parameter sets evaluated in %d generations\n", len(result.Candidates), result.Generations)
+	cv.printHeader(bars)
+	fmt.Println()
+	if top > 0 && len(candidates) > top {
+		candidates = candidates[:top]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, p := range space {
+		fmt.Fprintf(w, "%s\t", strings.ToUpper(p.Name))
+	}
+	fmt.Fprintln(w, "FITNESS\tSHARPE\tTRADES\tWIN RATE\tPROFIT\tMAX DD"+cv.columns())
+	overfit := 0
+	for _, c := range candidates {
+		for _, p := range space {
+			fmt.Fprintf(w, "%v\t", c.Params[p.Name])
+		}
+		fmt.Fprintf(w, "%.3f\t%.2f\t%d\t%.1f%%\t%.0f\t%.1f%%",
+			c.Fitness, c.Backtest.SharpeRatio, c.Backtest.TotalTrades,
+			c.Backtest.WinRate*100, c.Backtest.TotalProfit, c.Backtest.MaxDrawdown*100)
+		if cv.printRow(w, c.Params, c.Backtest) {
+			overfit++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	cv.printSummary(overfit, len(candidates))
+	return nil
+}
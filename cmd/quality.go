@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"tradingbot/internal/market"
+	"tradingbot/internal/marketdata"
+
+	"github.com/pkg/errors"
+)
+
+// runQuality implements `tradingbot quality`.
+func runQuality(args []string) error {
+	fs := flag.NewFlagSet("quality", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	days := fs.Int("days", 250, "number of daily candles to check")
+	verbose := fs.Bool("v", false, "list every issue, not only the counts")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	codes := fs.Args()
+	if len(codes) == 0 {
+		codes = cfg.TradingSymbols()
+	}
+	cal, err := market.NewCalendar(cfg.Market)
+	if err != nil {
+		return err
+	}
+	// Check what the provider itself returns, before any repair.
+	provider, err := connectProvider(cfg, nil)
+	if err != nil {
+		return err
+	}
+	checker := marketdata.NewChecker(cfg.MarketData, cal)
+
+	var reports []marketdata.Report
+	for _, code := range codes {
+		candles, err := provider.GetDailyCandles(code, *days)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get candles of %s", code)
+		}
+		_, report := checker.Check(code, candles)
+		reports = append(reports, report)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tCANDLES\tMISSING\tBAD PRICE\tDUPLICATE\tJUMP")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", r.Symbol, r.Candles,
+			r.Count(marketdata.IssueMissing), r.Count(marketdata.IssueBadPrice), r.Count(marketdata.IssueDuplicate), r.Count(marketdata.IssueJump))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if *verbose {
+		for _, r := range reports {
+			for _, issue := range r.Issues {
+				fmt.Printf("%s %s\n", r.Symbol, issue)
+			}
+		}
+	}
+	return nil
+}
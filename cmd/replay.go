@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+	"tradingbot/internal/allocation"
+	"tradingbot/internal/audit"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/engine"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/paper"
+	"tradingbot/internal/replay"
+	"tradingbot/internal/rules"
+	"tradingbot/internal/strategy"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// runReplay implements `tradingbot replay`. It runs recorded prices through the
+// same engine, strategies and risk checks as `run`, against a paper exchange
+// and a simulated clock, so the result can be compared with a backtest.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	file := fs.String("file", "", "CSV file of recorded prices with columns time,symbol,price (required)")
+	speed := fs.Float64("speed", 0, "replay speed relative to recorded time, e.g. 60 replays a minute per second (0: as fast as possible)")
+	balance := fs.Float64("balance", 10000000, "initial balance in KRW")
+	commission := fs.Float64("commission", 0.0025, "commission rate per trade")
+	auditPath := fs.String("audit", "", "also write the decisions to this audit log")
+	compare := fs.Bool("compare", false, "also backtest each symbol on the same prices")
+	statePath := fs.String("state", "", "start the strategies from this state snapshot, e.g. a checkpoint of an earlier replay or the bot's strategy_state")
+	checkpoint := fs.String("checkpoint", "", "save the strategies' state to this snapshot at the end of the replay (.json for JSON, else gob)")
+	checkpointEvery := fs.Duration("checkpoint-every", 0, "with -checkpoint, also save it every this much of recorded time")
+	var faults paper.Faults
+	fs.Float64Var(&faults.RejectRate, "reject-rate", 0, "chance of an order being rejected by the broker")
+	fs.Float64Var(&faults.ServerErrorRate, "error-rate", 0, "chance of an order failing with a server error")
+	fs.Float64Var(&faults.StaleTokenRate, "stale-token-rate", 0, "chance of an order failing with an expired token")
+	fs.Float64Var(&faults.PartialFillRate, "partial-rate", 0, "chance of an order being filled only in part")
+	fs.DurationVar(&faults.FillDelay, "fill-delay", 0, "fill orders this long after they are placed")
+	fs.Int64Var(&faults.Seed, "fault-seed", 1, "seed of the injected faults")
+	fs.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	cfg, err := config.LoadProfile(cf.path, cf.profile)
+	if err != nil {
+		return err
+	}
+	if err := logging.Configure(cfg.Logging, cfg.LogLevel); err != nil {
+		return errors.Wrap(err, "failed to configure logging")
+	}
+
+	ticks, err := replay.Load(*file)
+	if err != nil {
+		return err
+	}
+	ticks, skipped, err := tradedTicks(cfg, ticks)
+	if err != nil {
+		return err
+	}
+	if len(ticks) == 0 {
+		return fmt.Errorf("%s has no prices for the trading symbols during trading hours", *file)
+	}
+
+	strategies, err := syncStrategies(cfg, nil, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize strategies")
+	}
+	if *statePath != "" {
+		snap, err := strategy.LoadSnapshot(*statePath)
+		if err != nil {
+			return err
+		}
+		symbols, err := snap.Restore(cfg.Strategy, strategies)
+		if err != nil {
+			return errors.Wrapf(err, "failed to restore %s", *statePath)
+		}
+		log.WithFields(logrus.Fields{"file": *statePath, "taken": snap.Taken, "symbols": symbols}).Info("Strategy state restored")
+	}
+
+	clk := clock.NewSimulated(ticks[0].Time)
+	exch := paper.New(*balance, *commission, clk)
+	if cfg.Margin.Enabled {
+		exch.SetMarginRequirement(cfg.Margin.Requirement)
+	}
+	exch.SetFaults(faults)
+	eng := engine.New(cfg, exch, discardStore{}, strategies)
+	eng.SetClock(clk)
+	if len(cfg.SignalRules) > 0 {
+		eng.SetRules(rules.New(cfg.SignalRules))
+	}
+	if len(cfg.Allocation.Sleeves) > 0 {
+		alloc, err := allocation.New(cfg)
+		if err != nil {
+			return err
+		}
+		eng.SetAllocator(alloc)
+	}
+
+	if *auditPath != "" {
+		auditLog, err := audit.Open(*auditPath)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
+		auditLog.Subscribe(eng.Bus)
+	}
+
+	log.WithFields(logrus.Fields{
+		"ticks":   len(ticks),
+		"skipped": skipped,
+		"from":    ticks[0].Time,
+		"to":      ticks[len(ticks)-1].Time,
+		"speed":   *speed,
+	}).Info("Starting replay...")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	replayer := replay.New(eng, exch, clk, *speed)
+	if *checkpoint != "" {
+		format := strategy.FormatGob
+		if filepath.Ext(*checkpoint) == ".json" {
+			format = strategy.FormatJSON
+		}
+		replayer.CheckpointEvery = *checkpointEvery
+		replayer.Checkpoint = func(at time.Time) {
+			if err := strategy.SaveSnapshot(*checkpoint, format, strategy.TakeSnapshot(cfg.Strategy, strategies, at)); err != nil {
+				log.WithError(err).Error("Failed to save checkpoint")
+			}
+		}
+	}
+	cycles, err := replayer.Run(ctx, ticks)
+	if err != nil {
+		log.WithError(err).Warn("Replay interrupted")
+	}
+
+	orders := exch.Orders()
+	equity := exch.Equity()
+	log.WithFields(logrus.Fields{
+		"Cycles":      cycles,
+		"Orders":      len(orders),
+		"Cash":        exch.Cash(),
+		"Equity":      equity,
+		"TotalProfit": equity - *balance,
+		"Return":      (equity/(*balance) - 1) * 100,
+	}).Info("Replay results")
+
+	if *compare {
+		compareWithBacktest(cfg, ticks, orders, *balance, *commission)
+	}
+	return nil
+}
+
+// tradedTicks keeps the ticks of traded symbols that fall within trading hours,
+// which are the ones the live loop would have run a cycle for. It also returns
+// how many were dropped.
+func tradedTicks(cfg *config.Config, ticks []replay.Tick) ([]replay.Tick, int, error) {
+	traded := make(map[string]bool)
+	for _, symbol := range cfg.TradingSymbols() {
+		traded[symbol] = true
+	}
+	var cal *market.Calendar
+	if cfg.Market.Enabled {
+		var err error
+		if cal, err = market.NewCalendar(cfg.Market); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var kept []replay.Tick
+	for _, tick := range ticks {
+		if !traded[tick.Symbol] || (cal != nil && !cal.IsTrading(tick.Time, cfg.Market.ExtendedSessions)) {
+			continue
+		}
+		kept = append(kept, tick)
+	}
+	return kept, len(ticks) - len(kept), nil
+}
+
+// compareWithBacktest backtests every symbol on the replayed prices and logs the
+// result next to the orders the replay placed for it.
+func compareWithBacktest(cfg *config.Config, ticks []replay.Tick, orders []models.Order, balance, commission float64) {
+	data := make(map[string][]models.MarketData)
+	for _, tick := range ticks {
+		data[tick.Symbol] = append(data[tick.Symbol], models.MarketData{StckPrpr: strconv.FormatFloat(tick.Price, 'f', -1, 64)})
+	}
+	placed := make(map[string]int)
+	for _, order := range orders {
+		placed[order.Pair]++
+	}
+
+	for _, symbol := range cfg.TradingSymbols() {
+		if len(data[symbol]) == 0 {
+			continue
+		}
+		strat, err := newStrategy(cfg)
+		if err != nil {
+			log.WithError(err).Error("Failed to initialize strategy for backtest")
+			return
+		}
+		result := backtesting.NewBacktester(strat, data[symbol], balance, commission).Run()
+		log.WithFields(logrus.Fields{
+			"pair":           symbol,
+			"ReplayOrders":   placed[symbol],
+			"BacktestTrades": result.TotalTrades,
+			"BacktestProfit": result.TotalProfit,
+		}).Info("Replay vs backtest")
+	}
+}
+
+// discardStore stands in for the database during a replay; the paper exchange
+// keeps the orders.
+type discardStore struct{}
+
+func (discardStore) SaveOrder(order *models.Order) error { return nil }
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+	"tradingbot/internal/database"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/report"
+
+	"github.com/pkg/errors"
+)
+
+// runReport implements `tradingbot report`.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	from := fs.String("from", "", "first KST date of the period, YYYY-MM-DD (default: first order)")
+	to := fs.String("to", "", "last KST date of the period, YYYY-MM-DD (default: today)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	offline := fs.Bool("offline", false, "do not fetch current prices; unrealized PnL is left out")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	start, end, err := report.ParsePeriod(*from, *to, time.Now())
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	orders, err := db.ListOrdersBefore(end)
+	if err != nil {
+		return err
+	}
+
+	var prices map[string]float64
+	var dividends []models.Dividend
+	if !*offline {
+		exch, err := connectExchange(cfg)
+		if err != nil {
+			return errors.Wrap(err, "failed to initialize exchange")
+		}
+		positions, err := exch.GetPositions()
+		if err != nil {
+			return errors.Wrap(err, "failed to get current prices")
+		}
+		prices = report.Prices(positions)
+		if dividends, err = exch.GetDividends(report.DividendsFrom(start, orders), end); err != nil {
+			return errors.Wrap(err, "failed to get dividends")
+		}
+	}
+
+	costs := report.Costs{CommissionRate: cfg.Fees.CommissionRate, SellTaxRate: cfg.Fees.SellTaxRate}
+	pnl := report.Attribute(orders, dividends, start, end, prices, costs, cfg.Strategy)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pnl)
+	}
+
+	period := "all orders"
+	if !start.IsZero() {
+		period = start.Format("2006-01-02")
+	}
+	fmt.Printf("PnL %s – %s KST\n\n", period, end.AddDate(0, 0, -1).Format("2006-01-02"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STRATEGY\tSYMBOL\tTRADES\tTURNOVER\tFEES\tDIVIDENDS\tREALIZED\tUNREALIZED\tTOTAL\tHELD")
+	printRow := func(strategy, symbol string, a report.Attribution) {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%g\n", strategy, symbol, a.Trades,
+			report.FormatKRW(a.Turnover), report.FormatKRW(a.Fees), report.FormatKRW(a.Dividends), report.FormatKRW(a.RealizedPnL),
+			report.FormatKRW(a.UnrealizedPnL), report.FormatKRW(a.TotalPnL()), a.Quantity)
+	}
+	for _, a := range pnl.Rows {
+		printRow(a.Strategy, a.Symbol, a)
+	}
+	fmt.Fprintln(w, "\t\t\t\t\t\t\t\t\t")
+	for _, a := range pnl.ByStrategy {
+		printRow(a.Strategy, "*", a)
+	}
+	for _, a := range pnl.BySymbol {
+		printRow("*", a.Symbol, a)
+	}
+	printRow("*", "*", pnl.Total)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if pnl.Unpriced > 0 {
+		fmt.Printf("\n%d orders without a price were left out.\n", pnl.Unpriced)
+	}
+	return nil
+}
+
+// runReportExecution implements `tradingbot report execution`.
+func runReportExecution(args []string) error {
+	fs := flag.NewFlagSet("report execution", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	from := fs.String("from", "", "first KST date of the period, YYYY-MM-DD (default: first order)")
+	to := fs.String("to", "", "last KST date of the period, YYYY-MM-DD (default: today)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	offline := fs.Bool("offline", false, "do not fetch daily closes; only signal prices are compared")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	start, end, err := report.ParsePeriod(*from, *to, time.Now())
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	orders, err := db.ListOrdersBefore(end)
+	if err != nil {
+		return err
+	}
+
+	closes := make(map[string]map[string]float64)
+	if !*offline && len(orders) > 0 {
+		exch, err := connectExchange(cfg)
+		if err != nil {
+			return errors.Wrap(err, "failed to initialize exchange")
+		}
+		first := start
+		if first.IsZero() {
+			first = orders[0].Timestamp
+		}
+		for _, o := range orders {
+			if _, ok := closes[o.Pair]; ok || o.Timestamp.Before(start) {
+				continue
+			}
+			candles, err := exch.GetDailyCandlesBetween(o.Pair, first, end)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get %s closes", o.Pair)
+			}
+			closes[o.Pair] = make(map[string]float64, len(candles))
+			for _, c := range candles {
+				closes[o.Pair][c.Start.In(market.KST).Format("2006-01-02")] = c.Close
+			}
+		}
+	}
+
+	ex := report.ExecutionQuality(orders, closes, start, end, cfg.Strategy)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ex)
+	}
+
+	period := "all orders"
+	if !start.IsZero() {
+		period = start.Format("2006-01-02")
+	}
+	fmt.Printf("Execution quality %s – %s KST: fills against the signal price and the day's close (positive is a cost)\n\n", period, end.AddDate(0, 0, -1).Format("2006-01-02"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STRATEGY\tSYMBOL\tORDERS\tVALUE\tVS SIGNAL\tBPS\tVS CLOSE\tBPS")
+	printRow := func(strategy, symbol string, s report.Shortfall) {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%.1f\t%s\t%.1f\n", strategy, symbol, s.Orders, report.FormatKRW(s.Value),
+			report.FormatKRW(s.SignalCost), s.SignalBps, report.FormatKRW(s.CloseCost), s.CloseBps)
+	}
+	for _, s := range ex.Rows {
+		printRow(s.Strategy, s.Symbol, s)
+	}
+	fmt.Fprintln(w, "\t\t\t\t\t\t\t")
+	for _, s := range ex.ByStrategy {
+		printRow(s.Strategy, "*", s)
+	}
+	for _, s := range ex.BySymbol {
+		printRow("*", s.Symbol, s)
+	}
+	printRow("*", "*", ex.Total)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if ex.Unpriced > 0 {
+		fmt.Printf("\n%d orders without a fill price were left out.\n", ex.Unpriced)
+	}
+	return nil
+}
@@ -0,0 +1,1058 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"tradingbot/internal/allocation"
+	"tradingbot/internal/api"
+	"tradingbot/internal/audit"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/correlation"
+	"tradingbot/internal/database"
+	"tradingbot/internal/earnings"
+	"tradingbot/internal/engine"
+	"tradingbot/internal/events"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/experiment"
+	"tradingbot/internal/hedge"
+	"tradingbot/internal/intraday"
+	"tradingbot/internal/killswitch"
+	"tradingbot/internal/lock"
+	"tradingbot/internal/market"
+	"tradingbot/internal/marketdata"
+	"tradingbot/internal/models"
+	"tradingbot/internal/monitor"
+	"tradingbot/internal/news"
+	"tradingbot/internal/notify"
+	"tradingbot/internal/outbox"
+	"tradingbot/internal/rebalance"
+	"tradingbot/internal/reconcile"
+	"tradingbot/internal/rules"
+	"tradingbot/internal/scheduler"
+	"tradingbot/internal/screener"
+	"tradingbot/internal/secrets"
+	"tradingbot/internal/shadow"
+	"tradingbot/internal/sizing"
+	"tradingbot/internal/strategy"
+	"tradingbot/internal/supervisor"
+	"tradingbot/internal/sweep"
+	"tradingbot/internal/telegram"
+	"tradingbot/internal/tuning"
+	"tradingbot/internal/watchdog"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	configPollInterval     = 10 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+	watchdogExitDelay      = 5 * time.Second
+	// defaultCycleBudget is the part of the polling interval a cycle may
+	// take before a warning.
+	defaultCycleBudget = 0.8
+	// earningsRefreshInterval is how often the earnings calendar is rebuilt.
+	earningsRefreshInterval = 24 * time.Hour
+	// defaultLockCheckInterval is how often the account lock is checked.
+	defaultLockCheckInterval = 30 * time.Second
+)
+
+// runTrading implements `tradingbot run`: the live trading loop.
+func runTrading(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	live := addLiveFlag(fs)
+	fs.Parse(args)
+
+	log.Info("Starting trading bot...")
+
+	cfg, creds, err := loadConfig(cf)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+	log.WithFields(logrus.Fields{
+		"profile": cfg.Profile,
+		"mode":    cfg.Exchange.Mode,
+	}).Info("Configuration loaded")
+	if err := checkLive(cfg, *live); err != nil {
+		return err
+	}
+
+	if cfg.KillSwitch.File != "" {
+		if _, err := os.Stat(cfg.KillSwitch.File); err == nil {
+			return fmt.Errorf("kill file %s exists; remove it to start trading", cfg.KillSwitch.File)
+		}
+	}
+
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+	defer func() { db.Close() }()
+
+	accountLock, err := acquireLock(cfg, db)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+	if accountLock != nil {
+		defer func() {
+			if err := accountLock.Release(); err != nil {
+				log.WithError(err).Warn("Failed to release the account lock")
+			}
+		}()
+	}
+
+	exch, err := connectExchange(cfg)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+	if exch.Observing() {
+		log.Warn("Observer mode: signals are computed and recorded, no orders are sent")
+	}
+	if err := confirmLive(cfg, exch, os.Stdin); err != nil {
+		return err
+	}
+
+	master, err := checkSymbols(cfg, exch)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+
+	if cfg.Reconcile.Enabled {
+		report, err := reconcile.Run(exch, db, time.Now(), cfg.Reconcile.Import)
+		if err != nil {
+			log.WithError(err).Warn("Reconciliation failed")
+		}
+		if report != nil {
+			report.Log()
+		}
+	}
+
+	strategies, err := syncStrategies(cfg, nil, false)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+	if cfg.StrategyState.Enabled {
+		restoreStrategyState(cfg, strategies, time.Now())
+	}
+	provider, err := connectMarketData(cfg, exch)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+	var store engine.OrderStore = db
+	var box *outbox.Outbox
+	if cfg.Outbox.Enabled {
+		box, err = outbox.Open(cfg.Outbox.Path, db)
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		if n := box.Pending(); n > 0 {
+			log.WithField("pending", n).Warn("Orders of a previous run are waiting to be saved")
+		}
+		defer func() {
+			if box.Pending() > 0 {
+				if err := box.Flush(); err != nil {
+					log.WithError(err).WithField("pending", box.Pending()).Warn("Orders left in the outbox")
+				}
+			}
+		}()
+		store = box
+	}
+	eng := engine.New(cfg, exch, store, strategies)
+	if p := cfg.MarketData.Provider; (p != "" && p != config.MarketDataKIS) || cfg.MarketData.Fallback != "" {
+		eng.SetMarketData(provider)
+		log.WithFields(logrus.Fields{"provider": cfg.MarketData.Provider, "fallback": cfg.MarketData.Fallback}).Info("Market data provider")
+	}
+	if master != nil {
+		eng.SetLotSizes(master.LotSizes())
+		eng.SetInstruments(master.Types())
+		eng.SetSectors(master.Sectors())
+	}
+	if cfg.CashSweep.Enabled {
+		eng.SetSweeper(sweep.New(cfg.CashSweep, exch))
+	}
+	if len(cfg.SignalRules) > 0 {
+		eng.SetRules(rules.New(cfg.SignalRules))
+	}
+	var alloc *allocation.Allocator
+	if len(cfg.Allocation.Sleeves) > 0 {
+		alloc, err = allocation.New(cfg)
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		orders, err := db.ListStrategyOrders()
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		alloc.Restore(orders)
+		eng.SetAllocator(alloc)
+		logAllocation(alloc)
+	}
+
+	if cfg.Monitor.Enabled {
+		monitor.New(cfg.Monitor, cfg.Strategy, monitorBaselines(cfg, db), eng).Subscribe(eng.Bus)
+	}
+
+	if len(cfg.Kelly.Strategies) > 0 {
+		kelly := sizing.NewKelly(cfg.Kelly, cfg.Strategy, kellyEdges(cfg, db))
+		kelly.Subscribe(eng.Bus)
+		eng.SetKelly(kelly)
+	}
+	// The liquidity limit and the correlation check are set up even when
+	// disabled, so that a reload can enable them.
+	liquidity := sizing.NewLiquidity(cfg.Liquidity, db)
+	now := time.Now().In(market.KST)
+	todays, err := db.ListOrdersBetween(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, market.KST), now)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+	liquidity.Restore(todays)
+	liquidity.Subscribe(eng.Bus)
+	eng.SetLiquidity(liquidity)
+	correlations := correlation.New(cfg.Correlation, db)
+	eng.SetCorrelations(correlations)
+
+	if cfg.Audit.Enabled {
+		auditLog, err := audit.Open(cfg.Audit.Path)
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		defer auditLog.Close()
+		auditLog.Subscribe(eng.Bus)
+	}
+
+	if cfg.ParsedTimeframe > 0 {
+		if err := resumeCandles(cfg, db, exch, eng); err != nil {
+			log.WithError(err).Warn("Could not fill the gap since the last run")
+		}
+	}
+
+	ctl := newController()
+	defer ctl.stop()
+	ctl.kill = killswitch.New(exch, eng.Bus, ctl.Pause)
+	var server *api.Server
+	if cfg.API.Enabled {
+		server = api.NewServer(cfg, eng.Bus, exch, ctl)
+		server.SetOrderHistory(db)
+		if master != nil {
+			server.SetSectors(master.Sectors())
+		}
+		server.Start()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+	}
+	var adaptive *scheduler.Adaptive
+	if cfg.AdaptivePolling.Enabled {
+		adaptive = scheduler.NewAdaptive(cfg.AdaptivePolling, cfg.ParsedInterval)
+		eng.Bus.Subscribe(func(ev events.Event) {
+			md := ev.(events.MarketDataEvent)
+			price, _ := strconv.ParseFloat(md.Data.StckPrpr, 64)
+			spread, hasSpread := md.Data.Spread()
+			adaptive.Observe(md.Symbol, md.Time, price, spread, hasSpread)
+		}, events.KindMarketData)
+	}
+	phases := newPhaseTimes(eng.Bus)
+	// Components run supervised: a panic, e.g. of the strategy of one symbol,
+	// is reported on the bus and the component retried after a backoff.
+	sup := supervisor.New(eng.Bus)
+	runCycle := func() {
+		start := time.Now()
+		phases.reset()
+		// Errors are published on the engine's bus and logged there.
+		scheduler.ForEach(cfg.TradingSymbols(), cfg.MaxParallel, func(symbol string) {
+			sup.Do("cycle", symbol, func() { eng.RunCycle(symbol) })
+		})
+		sup.Do("sweep", "", eng.Sweep)
+		checkCycleBudget(cfg, time.Since(start), phases.reset())
+		if cfg.StrategyState.Enabled {
+			saveStrategyState(cfg, strategies)
+		}
+	}
+
+	// Initial market check
+	marketData, err := provider.GetMarketData(cfg.TradingPair)
+	if err != nil {
+		log.WithError(err).Error("Current price")
+	} else {
+		log.WithFields(logrus.Fields{"pair": cfg.TradingPair, "price": marketData.StckPrpr}).Info("Current price")
+	}
+
+	// Initial balance check
+	balance, err := exch.GetBalance()
+	logAndCheckError(err, "Account Balance", logrus.Fields{"balance": balance})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	reloads := config.Watch(cf.path, cfg.Profile, configPollInterval, stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if box != nil {
+		interval, _ := time.ParseDuration(cfg.Outbox.RetryInterval)
+		sup.Go(ctx, "outbox", func(ctx context.Context) { box.Run(ctx, interval) })
+	}
+	if accountLock != nil {
+		interval := defaultLockCheckInterval
+		if cfg.Lock.CheckInterval != "" {
+			interval, _ = time.ParseDuration(cfg.Lock.CheckInterval)
+		}
+		sup.Go(ctx, "lock", func(ctx context.Context) {
+			lock.Watch(ctx, accountLock, interval, func(err error) {
+				ctl.Pause()
+				log.WithError(err).Error("Lost the account lock, trading paused")
+			}, func() {
+				log.Warn("Account lock taken back; trading stays paused until resumed")
+			})
+		})
+	}
+	if cfg.KillSwitch.File != "" {
+		interval, _ := time.ParseDuration(cfg.KillSwitch.CheckInterval)
+		sup.Go(ctx, "kill_switch", func(ctx context.Context) { ctl.kill.WatchFile(ctx, cfg.KillSwitch.File, interval) })
+	}
+	var bot *telegram.Bot
+	if cfg.Telegram.Enabled {
+		bot = telegram.New(cfg, ctl, exch, db)
+		sup.Go(ctx, "telegram", bot.Run)
+	}
+	var secretUpdates <-chan secrets.Update
+	if creds.provider != nil && cfg.Secrets.RefreshInterval != "" {
+		interval, _ := time.ParseDuration(cfg.Secrets.RefreshInterval)
+		secretUpdates = secrets.Watch(ctx, creds.provider, creds.values, interval)
+	}
+
+	var email *notify.EmailNotifier
+	if cfg.Notify.Email.Enabled {
+		cal, err := market.NewCalendar(cfg.Market)
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		email = notify.NewEmail(cfg.Notify.Email, cal, eng.Bus, exch)
+	}
+	maintDB := &maintenanceDB{db: db}
+	maintenance, err := newMaintenance(cfg, maintDB, exch, email)
+	if err != nil {
+		return errors.Wrap(err, "initialization failed")
+	}
+	sup.Go(ctx, "maintenance", maintenance.Run)
+	var notifications *notify.Outbox
+	if cfg.Notify.Outbox.Enabled {
+		notifications = notify.NewOutbox(cfg.Notify.Outbox, db)
+	}
+	if email != nil {
+		if notifications != nil {
+			email.SetOutbox(notifications)
+		}
+		sup.Go(ctx, "email", email.Run)
+	}
+	for _, hc := range cfg.Notify.Webhooks {
+		hook := notify.NewWebhook(hc, eng.Bus)
+		if notifications != nil {
+			hook.SetOutbox(notifications)
+		}
+		sup.Go(ctx, "webhook", hook.Run)
+	}
+	if notifications != nil {
+		sup.Go(ctx, "notify_outbox", notifications.Run)
+	}
+
+	if cfg.Earnings.Enabled {
+		var source earnings.Source
+		if cfg.Earnings.DartAPIKey != "" {
+			source = earnings.NewDART(cfg.Earnings.DartAPIKey)
+		}
+		symbols := cfg.TradingSymbols()
+		loadEarnings := func() {
+			cal, err := earnings.Load(cfg.Earnings, source, symbols, time.Now())
+			if err != nil {
+				log.WithError(err).Warn("Earnings calendar incomplete")
+			}
+			if cal != nil {
+				eng.SetEarnings(cal)
+			}
+		}
+		loadEarnings()
+		sup.Go(ctx, "earnings", func(ctx context.Context) {
+			ticker := time.NewTicker(earningsRefreshInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					loadEarnings()
+				}
+			}
+		})
+	}
+
+	if cfg.News.Enabled {
+		sources, err := news.NewSources(cfg.News)
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		scorer, err := news.NewScorer(cfg.News)
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		tracker := news.NewTracker(cfg.News, sources, scorer)
+		eng.SetSentiment(tracker)
+		interval, _ := time.ParseDuration(cfg.News.PollInterval)
+		symbols := cfg.TradingSymbols()
+		sup.Go(ctx, "news", func(ctx context.Context) { tracker.Run(ctx, symbols, interval) })
+	}
+
+	if cfg.Shadow.Enabled {
+		runner, err := shadow.New(cfg)
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		if err := runner.Restore(strategy.TakeSnapshot(cfg.Strategy, strategies, time.Now())); err != nil {
+			log.WithError(err).Warn("Shadow books start without the live strategy state")
+		}
+		sup.Go(ctx, "shadow", func(ctx context.Context) { runner.Run(ctx, eng.Bus) })
+		defer runner.Log()
+		if server != nil {
+			server.SetShadow(runner)
+		}
+		log.WithFields(logrus.Fields{"incumbent": cfg.Strategy, "candidate": cfg.Shadow.Strategy}).Info("Shadow trading enabled")
+	}
+
+	if cfg.Experiment.Enabled {
+		test := experiment.New(cfg.Experiment, alloc)
+		test.Subscribe(eng.Bus)
+		if server != nil {
+			server.SetExperiment(test)
+		}
+		log.WithFields(logrus.Fields{"a": cfg.Experiment.A, "b": cfg.Experiment.B}).Info("A/B test enabled")
+	}
+
+	if cached, ok := provider.(*marketdata.Cached); ok {
+		cached.Subscribe(eng.Bus)
+		if server != nil {
+			server.SetCandles(cached.Cache())
+		}
+	}
+
+	if cfg.Intraday.Enabled {
+		tracker := intraday.NewTracker(cfg.Intraday, exch)
+		eng.SetVWAP(tracker)
+		if server != nil {
+			server.SetIntraday(tracker)
+		}
+		interval, _ := time.ParseDuration(cfg.Intraday.PollInterval)
+		symbols := cfg.TradingSymbols()
+		sup.Go(ctx, "intraday", func(ctx context.Context) { tracker.Run(ctx, symbols, interval) })
+	}
+
+	if cfg.Hedger.Enabled {
+		var exclude []string
+		if cfg.CashSweep.Enabled {
+			exclude = append(exclude, cfg.CashSweep.Symbol)
+		}
+		exch.DerivativesAccountNo = cfg.Derivatives.AccountNo
+		hedger := hedge.NewRunner(cfg, exch, exch, eng, exch, exclude)
+		interval, _ := time.ParseDuration(cfg.Hedger.CheckInterval)
+		sup.Go(ctx, "hedger", func(ctx context.Context) { hedger.Run(ctx, interval) })
+		log.WithFields(logrus.Fields{"instrument": cfg.Hedger.Instrument, "ratio": cfg.Hedger.Ratio}).Info("Portfolio hedging enabled")
+	}
+
+	if cfg.Rebalance.Enabled {
+		rebalancer := rebalance.NewRunner(cfg, exch, eng)
+		interval, _ := time.ParseDuration(cfg.Rebalance.CheckInterval)
+		sup.Go(ctx, "rebalancer", func(ctx context.Context) { rebalancer.Run(ctx, interval) })
+		log.WithFields(logrus.Fields{"trigger": cfg.Rebalance.Trigger, "weights": cfg.Rebalance.Weights}).Info("Rebalancing enabled")
+	}
+
+	var wd *watchdog.Watchdog
+	if cfg.Watchdog.Enabled {
+		var cal *market.Calendar
+		if cfg.Market.Enabled {
+			if cal, err = market.NewCalendar(cfg.Market); err != nil {
+				return errors.Wrap(err, "initialization failed")
+			}
+		}
+		wd = watchdog.New(cfg.Watchdog, cal, cfg.Market.ExtendedSessions, exch, eng.Bus)
+		wd.OnRestart(watchdog.MarketData, func() error {
+			go ctl.TriggerCycle()
+			return nil
+		})
+		wd.OnRestart(watchdog.Token, func() error {
+			return exch.SetCredentials(cfg.Exchange.AppKey, cfg.Exchange.AppSecret)
+		})
+		wd.OnRestart(watchdog.Loop, func() error {
+			// Leave the notifiers time to deliver the alert before exiting.
+			go func() {
+				time.Sleep(watchdogExitDelay)
+				log.Error("Main loop stalled, exiting for a restart")
+				os.Exit(1)
+			}()
+			return nil
+		})
+		sup.Go(ctx, "watchdog", wd.Run)
+	}
+
+	var screenUpdates <-chan screener.Update
+	if cfg.Screen.Schedule != "" {
+		scr, err := screener.New(cfg.Screen)
+		if err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+		var cal *market.Calendar
+		if cfg.Market.Enabled {
+			if cal, err = market.NewCalendar(cfg.Market); err != nil {
+				return errors.Wrap(err, "initialization failed")
+			}
+		}
+		screenUpdates = screener.Watch(ctx, clock.Real, cfg.Screen.Schedule, cal, func() ([]screener.Result, error) {
+			return screen(cfg, scr, exch, provider, cfg.Screen.Universe)
+		})
+	}
+
+	var tuner *tuning.Tuner
+	var tuningUpdates <-chan tuning.Update
+	var proposal *tuning.Proposal
+	if cfg.Tuning.Enabled {
+		if tuner, tuningUpdates, err = startTuning(ctx, cfg, provider); err != nil {
+			return errors.Wrap(err, "initialization failed")
+		}
+	}
+
+	// A signal received mid-cycle only takes effect once the cycle has finished.
+	sigCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	log.Info("Entering main loop...")
+	clk := clock.Real
+	interval := cfg.ParsedInterval
+	for {
+		var timer clock.Timer
+		if next, closed := marketClosed(cfg, clk.Now()); closed {
+			timer = clk.NewTimer(next.Sub(clk.Now()))
+			log.WithField("next_open", next).Info("Market closed, sleeping until next session")
+		} else {
+			if ctl.Paused() {
+				log.Info("Trading paused, skipping cycle")
+			} else {
+				runCycle()
+			}
+
+			if wd != nil {
+				wd.Beat()
+			}
+
+			next := cfg.ParsedInterval
+			if adaptive != nil {
+				next = adaptive.Interval()
+			}
+			if adaptive != nil && next != interval {
+				log.WithFields(logrus.Fields{"from": interval, "to": next}).Info("Polling interval adapted to market activity")
+			}
+			interval = next
+
+			var at time.Time
+			timer, at = scheduler.NextTimer(clk, interval)
+			log.WithField("next_cycle", at).Info("Sleeping")
+		}
+
+	wait:
+		for {
+			select {
+			case <-sigCtx.Done():
+				timer.Stop()
+				// Restore default signal handling so a second Ctrl-C exits immediately.
+				stopSignals()
+				shutdown(cfg, eng, exch)
+				if cfg.StrategyState.Enabled {
+					saveStrategyState(cfg, strategies)
+				}
+				log.Info("Trading bot stopped")
+				return nil
+			case <-timer.C():
+				break wait
+			case reload := <-reloads:
+				if reload.Err == nil {
+					if err := secrets.Apply(reload.Config, creds.values); err != nil {
+						log.WithError(err).Error("Failed to apply secrets to reloaded config")
+					}
+				}
+				strategies = applyReload(cfg, strategies, reload)
+				eng.SetStrategies(strategies)
+				liquidity.SetConfig(cfg.Liquidity)
+				correlations.SetConfig(cfg.Correlation)
+				if tuner != nil {
+					if params, err := movingAverageParams(cfg); err == nil {
+						tuner.SetCurrent(params)
+					}
+				}
+			case req := <-ctl.requests:
+				switch req.action {
+				case controlCycle:
+					runCycle()
+					req.reply <- nil
+				case controlFlatten:
+					cancelOpenOrders(exch)
+					req.reply <- flattenPositions(eng, exch, "manual")
+				case controlSignal:
+					if next, closed := marketClosed(cfg, clk.Now()); closed {
+						req.reply <- fmt.Errorf("%w until %s", models.ErrMarketClosed, next.Format(time.RFC3339))
+						break
+					}
+					eng.Submit("tradingview", req.signal)
+					req.reply <- nil
+				case controlManual:
+					if next, closed := marketClosed(cfg, clk.Now()); closed {
+						req.reply <- fmt.Errorf("%w until %s", models.ErrMarketClosed, next.Format(time.RFC3339))
+						break
+					}
+					req.signal.Strategy = engine.ManualStrategy
+					eng.Submit("manual", req.signal)
+					req.reply <- nil
+				case controlClose:
+					req.reply <- eng.ClosePosition("manual", req.symbol)
+				case controlApprove:
+					if exch.Armed() {
+						req.reply <- fmt.Errorf("orders are already enabled")
+						break
+					}
+					exch.Arm()
+					log.WithField("account", cfg.Exchange.AccountNo).Warn("Live trading approved via API")
+					req.reply <- nil
+				case controlRecord:
+					req.order.Strategy = engine.ManualStrategy
+					eng.Record("manual", req.order)
+					req.reply <- nil
+				case controlTuning:
+					if proposal == nil {
+						req.reply <- fmt.Errorf("no tuned parameters await approval")
+						break
+					}
+					next, err := applyTuning(cfg, eng.Bus, tuner, strategies, proposal)
+					if err != nil {
+						req.reply <- err
+						break
+					}
+					strategies = next
+					eng.SetStrategies(strategies)
+					proposal = nil
+					req.reply <- nil
+				}
+			case update := <-tuningUpdates:
+				strategies, proposal = handleTuning(cfg, eng.Bus, tuner, strategies, update)
+				eng.SetStrategies(strategies)
+			case update := <-screenUpdates:
+				strategies = applyScreen(cfg, eng.Bus, strategies, update)
+				eng.SetStrategies(strategies)
+			case update := <-secretUpdates:
+				db = applySecretUpdate(cfg, exch, db, &creds, update)
+				if box != nil {
+					box.SetStore(db)
+				} else {
+					eng.SetStore(db)
+				}
+				maintDB.set(db)
+				if l, ok := accountLock.(*database.Lock); ok {
+					l.SetDB(db)
+				}
+				liquidity.SetVolumes(db)
+				correlations.SetCandles(db)
+				if notifications != nil {
+					notifications.SetStore(db)
+				}
+				if server != nil {
+					server.SetCredentials(cfg.API)
+					server.SetOrderHistory(db)
+				}
+				if bot != nil {
+					bot.SetOrderHistory(db)
+				}
+			}
+		}
+	}
+}
+
+// phaseTimes sums the phases of the symbols' cycles of one loop iteration.
+type phaseTimes struct {
+	mu     sync.Mutex
+	phases map[string]time.Duration
+}
+
+func newPhaseTimes(bus *events.Bus) *phaseTimes {
+	p := &phaseTimes{phases: make(map[string]time.Duration)}
+	bus.Subscribe(func(ev events.Event) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for phase, d := range ev.(events.CycleEvent).Phases {
+			p.phases[phase] += d
+		}
+	}, events.KindCycle)
+	return p
+}
+
+// reset returns the sums so far and starts over.
+func (p *phaseTimes) reset() map[string]time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phases := p.phases
+	p.phases = make(map[string]time.Duration)
+	return phases
+}
+
+// checkCycleBudget warns when the cycles of all symbols took more than the
+// budgeted part of the polling interval, with the time spent in each phase.
+func checkCycleBudget(cfg *config.Config, elapsed time.Duration, phases map[string]time.Duration) {
+	budget := cfg.CycleBudget
+	if budget == 0 {
+		budget = defaultCycleBudget
+	}
+	fields := logrus.Fields{"elapsed": elapsed.Round(time.Millisecond), "interval": cfg.ParsedInterval}
+	for phase, d := range phases {
+		fields[phase] = d.Round(time.Millisecond)
+	}
+	if limit := time.Duration(budget * float64(cfg.ParsedInterval)); cfg.ParsedInterval > 0 && elapsed > limit {
+		log.WithFields(fields).Warn("Trading cycle is close to the polling interval")
+		return
+	}
+	log.WithFields(fields).Debug("Trading cycle timing")
+}
+
+// logAllocation logs the book of every allocation sleeve.
+func logAllocation(alloc *allocation.Allocator) {
+	for _, b := range alloc.Books() {
+		log.WithFields(logrus.Fields{
+			"strategy":  b.Strategy,
+			"weight":    b.Weight,
+			"cash":      b.Cash,
+			"positions": len(b.Positions),
+			"realized":  b.Realized,
+		}).Info("Strategy sleeve")
+	}
+}
+
+// monitorBaselines loads the backtest each traded strategy is monitored
+// against: monitor.baseline, or the latest run of the strategy. Sleeves are
+// held to a backtest of their strategy. Strategies without a backtest are not
+// judged.
+func monitorBaselines(cfg *config.Config, db *database.DB) map[string]monitor.Baseline {
+	baselines := make(map[string]monitor.Baseline)
+	for name, run := range strategyBacktests(cfg, db, cfg.Monitor.Baseline, "performance not monitored") {
+		baselines[name] = monitor.BaselineFromRun(run)
+		log.WithFields(logrus.Fields{
+			"strategy":   name,
+			"backtest":   run.ID,
+			"win_rate":   baselines[name].WinRate,
+			"expectancy": baselines[name].Expectancy,
+		}).Info("Monitoring strategy performance")
+	}
+	return baselines
+}
+
+// kellyEdges loads the backtested edge of the strategies sized by Kelly until
+// they have enough closed trades of their own.
+func kellyEdges(cfg *config.Config, db *database.DB) map[string]sizing.Edge {
+	edges := make(map[string]sizing.Edge)
+	for name, run := range strategyBacktests(cfg, db, 0, "Kelly sizing waits for closed trades") {
+		edges[name] = sizing.EdgeFromRun(run)
+	}
+	for _, name := range cfg.Kelly.Strategies {
+		edge := edges[name]
+		log.WithFields(logrus.Fields{
+			"strategy": name,
+			"trades":   edge.Trades,
+			"win_rate": edge.WinRate,
+			"avg_win":  edge.AvgWin,
+			"avg_loss": edge.AvgLoss,
+			"kelly":    edge.Kelly(),
+		}).Info("Sizing strategy by Kelly")
+	}
+	return edges
+}
+
+// strategyBacktests loads the backtest run runID, or when zero the latest run
+// of its strategy, for every traded strategy or sleeve, keyed by its name.
+// Strategies without one are logged with missing and left out.
+func strategyBacktests(cfg *config.Config, db *database.DB, runID int64, missing string) map[string]*models.BacktestRun {
+	strategies := map[string]string{cfg.Strategy: cfg.Strategy}
+	if len(cfg.Allocation.Sleeves) > 0 {
+		strategies = map[string]string{}
+		for _, sc := range cfg.Allocation.Sleeves {
+			strategies[sc.Name] = sc.Strategy
+			if sc.Strategy == "" {
+				strategies[sc.Name] = cfg.Strategy
+			}
+		}
+	}
+
+	runs := make(map[string]*models.BacktestRun)
+	for name, strat := range strategies {
+		var run *models.BacktestRun
+		var err error
+		if runID > 0 {
+			run, err = db.GetBacktest(runID)
+		} else {
+			run, err = db.LatestBacktest(strat)
+		}
+		if err != nil {
+			log.WithError(err).WithField("strategy", name).Warn("No backtest baseline, " + missing)
+			continue
+		}
+		runs[name] = run
+	}
+	return runs
+}
+
+// marketClosed reports whether trading hours are enforced and neither the regular
+// nor an enabled extended session is in progress at now, together with the next
+// session start.
+func marketClosed(cfg *config.Config, now time.Time) (time.Time, bool) {
+	if !cfg.Market.Enabled {
+		return time.Time{}, false
+	}
+	cal, err := market.NewCalendar(cfg.Market)
+	if err != nil {
+		log.WithError(err).Error("Invalid market calendar, ignoring trading hours")
+		return time.Time{}, false
+	}
+	if cal.IsTrading(now, cfg.Market.ExtendedSessions) {
+		return time.Time{}, false
+	}
+	return cal.NextTrading(now, cfg.Market.ExtendedSessions), true
+}
+
+// shutdown applies the configured position-safety action before exit, giving up
+// after the shutdown timeout. Database writes are synchronous, so closing the
+// connection afterwards is all that is needed to flush them. Positions are
+// flattened through the engine, so the sells are recorded like any other order.
+func shutdown(cfg *config.Config, eng *engine.Engine, exch *exchange.KISExchange) {
+	action := cfg.Shutdown.Action
+	if action == "" || action == config.ShutdownActionNone || exch.Observing() {
+		return
+	}
+
+	timeout := defaultShutdownTimeout
+	if cfg.Shutdown.Timeout != "" {
+		timeout, _ = time.ParseDuration(cfg.Shutdown.Timeout)
+	}
+	log.WithFields(logrus.Fields{"action": action, "timeout": timeout}).Info("Shutting down")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cancelOpenOrders(exch)
+		if action == config.ShutdownActionFlatten {
+			flattenPositions(eng, exch, "shutdown")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warn("Shutdown action timed out, some orders or positions may remain open")
+	}
+}
+
+func cancelOpenOrders(exch *exchange.KISExchange) {
+	orders, err := exch.GetOpenOrders()
+	if err != nil {
+		log.WithError(err).Error("Failed to list open orders")
+		return
+	}
+	for _, order := range orders {
+		if err := exch.CancelOrder(order); err != nil {
+			log.WithError(err).WithField("order", order.OrderNo).Error("Failed to cancel order")
+			continue
+		}
+		log.WithField("order", order.OrderNo).Info("Order cancelled")
+	}
+}
+
+// flattenPositions sells every position held in the account through the
+// engine, skipping empty rows, and returns the sells that failed.
+func flattenPositions(eng *engine.Engine, exch *exchange.KISExchange, source string) error {
+	positions, err := exch.GetPositions()
+	if err != nil {
+		log.WithError(err).Error("Failed to list positions")
+		return fmt.Errorf("failed to list positions: %w", err)
+	}
+	var symbols []string
+	seen := map[string]bool{}
+	for _, p := range positions {
+		if p.Quantity <= 0 || seen[p.StockCode] {
+			continue
+		}
+		seen[p.StockCode] = true
+		symbols = append(symbols, p.StockCode)
+	}
+
+	var failed []string
+	for _, symbol := range symbols {
+		if err := eng.ClosePosition(source, symbol); err != nil {
+			log.WithError(err).WithField("pair", symbol).Error("Failed to flatten position")
+			failed = append(failed, fmt.Sprintf("%s: %v", symbol, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to flatten %d of %d positions: %s", len(failed), len(symbols), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// applyReload applies the runtime-safe part of a reloaded configuration and
+// returns the per-symbol strategies to use from now on.
+func applyReload(cfg *config.Config, strategies map[string]strategy.Strategy, reload config.Reload) map[string]strategy.Strategy {
+	if reload.Err != nil {
+		log.WithError(reload.Err).Error("Failed to reload config, keeping current settings")
+		return strategies
+	}
+
+	safe, unsafe := config.Changes(cfg, reload.Config)
+	if len(unsafe) > 0 {
+		log.WithField("keys", unsafe).Warn("Config changes require a restart and were not applied")
+	}
+	if len(safe) == 0 {
+		return strategies
+	}
+
+	strategyChanged := cfg.Strategy != reload.Config.Strategy
+	cfg.ApplySafe(reload.Config)
+	setLogLevel(cfg.LogLevel)
+
+	next, err := syncStrategies(cfg, strategies, strategyChanged)
+	if err != nil {
+		log.WithError(err).Error("Failed to apply strategy settings")
+		return strategies
+	}
+
+	log.WithField("keys", safe).Info("Config reloaded")
+	return next
+}
+
+// syncStrategies returns one strategy per trading symbol. Strategies of symbols
+// that are still traded are reconfigured in place so they keep their price
+// history, unless rebuild is set or they don't support it.
+func syncStrategies(cfg *config.Config, current map[string]strategy.Strategy, rebuild bool) (map[string]strategy.Strategy, error) {
+	params, err := cfg.StrategyParamsFor(cfg.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	next := make(map[string]strategy.Strategy)
+	for _, symbol := range cfg.TradingSymbols() {
+		if strat, ok := current[symbol]; ok && !rebuild {
+			if r, ok := strat.(strategy.Reconfigurable); ok {
+				if err := r.Reconfigure(params); err != nil {
+					return nil, err
+				}
+				next[symbol] = strat
+				continue
+			}
+		}
+
+		strat, err := strategy.New(cfg.Strategy, params)
+		if err != nil {
+			return nil, err
+		}
+		next[symbol] = strat
+	}
+	return next, nil
+}
+
+// restoreStrategyState restores the strategies from the snapshot configured
+// in cfg.StrategyState, unless there is none or it is too old. A snapshot
+// that cannot be restored only costs the state: the strategies start afresh.
+func restoreStrategyState(cfg *config.Config, strategies map[string]strategy.Strategy, now time.Time) {
+	path := cfg.StrategyState.Path
+	snap, err := strategy.LoadSnapshot(path)
+	if os.IsNotExist(err) {
+		log.WithField("file", path).Info("No strategy state saved before")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Warn("Strategy state not restored")
+		return
+	}
+	if maxAge, _ := time.ParseDuration(cfg.StrategyState.MaxAge); maxAge > 0 && now.Sub(snap.Taken) > maxAge {
+		log.WithFields(logrus.Fields{"file": path, "taken": snap.Taken}).Warn("Strategy state too old, not restored")
+		return
+	}
+	symbols, err := snap.Restore(cfg.Strategy, strategies)
+	if err != nil {
+		log.WithError(err).Warn("Strategy state not restored")
+		return
+	}
+	log.WithFields(logrus.Fields{"file": path, "taken": snap.Taken, "symbols": symbols}).Info("Strategy state restored")
+}
+
+// saveStrategyState saves the state of the strategies to the snapshot
+// configured in cfg.StrategyState. It must not run during a cycle.
+func saveStrategyState(cfg *config.Config, strategies map[string]strategy.Strategy) {
+	snap := strategy.TakeSnapshot(cfg.Strategy, strategies, time.Now())
+	if err := strategy.SaveSnapshot(cfg.StrategyState.Path, cfg.StrategyState.Format, snap); err != nil {
+		log.WithError(err).Warn("Strategy state not saved")
+	}
+}
+
+// acquireLock takes the account lock configured in cfg.Lock, or returns nil
+// when none is.
+func acquireLock(cfg *config.Config, db *database.DB) (lock.Lock, error) {
+	switch cfg.Lock.Backend {
+	case config.LockDatabase:
+		l, err := db.AcquireLock("tradingbot:" + cfg.Exchange.AccountNo)
+		if err != nil {
+			return nil, err
+		}
+		return l, nil
+	case config.LockFile:
+		l, err := lock.AcquireFile(cfg.Lock.Path, cfg.Exchange.AccountNo)
+		if err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+// applySecretUpdate applies rotated credentials to the running exchange client and,
+// when the database password changed, swaps in a new connection. It returns the
+// database connection to use from now on.
+func applySecretUpdate(cfg *config.Config, exch *exchange.KISExchange, db *database.DB, creds *credentials, update secrets.Update) *database.DB {
+	if update.Err != nil {
+		log.WithError(update.Err).Error("Failed to refresh secrets, keeping current credentials")
+		return db
+	}
+
+	oldDatabaseURL := cfg.DatabaseURL
+	if err := secrets.Apply(cfg, update.Values); err != nil {
+		log.WithError(err).Error("Failed to apply rotated secrets")
+		return db
+	}
+	creds.values = update.Values
+
+	if err := exch.SetCredentials(cfg.Exchange.AppKey, cfg.Exchange.AppSecret); err != nil {
+		log.WithError(err).Error("Failed to re-authenticate with rotated credentials")
+	}
+
+	if cfg.DatabaseURL != oldDatabaseURL {
+		next, err := database.NewConnection(cfg.DatabaseURL)
+		if err != nil {
+			log.WithError(err).Error("Failed to reconnect database with rotated password")
+			return db
+		}
+		db.Close()
+		db = next
+	}
+
+	log.Info("Secrets rotated")
+	return db
+}
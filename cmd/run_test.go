@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/engine"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/exchange/exchangetest"
+	"tradingbot/internal/models"
+)
+
+// savedOrders is an engine order store kept in memory.
+type savedOrders struct {
+	mu     sync.Mutex
+	orders []*models.Order
+}
+
+func (s *savedOrders) SaveOrder(order *models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders = append(s.orders, order)
+	return nil
+}
+
+func (s *savedOrders) all() []*models.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*models.Order(nil), s.orders...)
+}
+
+func TestShutdownActions(t *testing.T) {
+	tests := []struct {
+		action   string
+		position float64 // left in 005930 after shutdown
+		saved    int
+	}{
+		{config.ShutdownActionNone, 10, 0},
+		{config.ShutdownActionCancelOrders, 10, 0},
+		{config.ShutdownActionFlatten, 0, 1},
+	}
+	for _, tt := range tests {
+		kis := exchangetest.NewServer(10000000)
+		kis.SetPrice("005930", 70000)
+		exch, err := exchange.New(config.ExchangeConfig{BaseURL: kis.URL, AppKey: "key", AppSecret: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := exch.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 10}); err != nil {
+			t.Fatal(err)
+		}
+		cfg := &config.Config{Shutdown: config.ShutdownConfig{Action: tt.action}}
+		store := &savedOrders{}
+		eng := engine.New(cfg, exch, store, nil)
+
+		shutdown(cfg, eng, exch)
+		if got := kis.Position("005930"); got != tt.position {
+			t.Errorf("%s: position %v after shutdown, want %v", tt.action, got, tt.position)
+		}
+		saved := store.all()
+		if len(saved) != tt.saved {
+			t.Errorf("%s: saved %d orders, want %d", tt.action, len(saved), tt.saved)
+		} else if tt.saved > 0 && (saved[0].Side != models.OrderSideSell || saved[0].Amount != 10) {
+			t.Errorf("%s: saved %+v, want a sell of 10", tt.action, saved[0])
+		}
+		cancelled := tt.action != config.ShutdownActionNone
+		if n := kis.Requests("/uapi/domestic-stock/v1/trading/inquire-psbl-rvsecncl"); (n > 0) != cancelled {
+			t.Errorf("%s: listed open orders %d times", tt.action, n)
+		}
+		kis.Close()
+	}
+}
+
+func TestShutdownGivesUpAfterTimeout(t *testing.T) {
+	release := make(chan struct{})
+	kis := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/tokenP" {
+			fmt.Fprint(w, `{"access_token":"token"}`)
+			return
+		}
+		<-release
+		fmt.Fprint(w, `{"rt_cd":"0","output":[]}`)
+	}))
+	defer kis.Close()
+	defer close(release)
+	exch, err := exchange.New(config.ExchangeConfig{BaseURL: kis.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Shutdown: config.ShutdownConfig{Action: config.ShutdownActionFlatten, Timeout: "50ms"}}
+	eng := engine.New(cfg, exch, &savedOrders{}, nil)
+
+	start := time.Now()
+	shutdown(cfg, eng, exch)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("shutdown took %v with a timeout of 50ms", elapsed)
+	}
+}
+
+func TestFlattenPositionsReportsFailedSells(t *testing.T) {
+	kis := exchangetest.NewServer(10000000)
+	defer kis.Close()
+	kis.SetPrice("005930", 70000)
+	kis.SetPrice("000660", 185000)
+	exch, err := exchange.New(config.ExchangeConfig{BaseURL: kis.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, symbol := range []string{"005930", "000660"} {
+		if _, err := exch.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: symbol, Amount: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Sells fail in observer mode, so every position is reported.
+	cfg := &config.Config{Exchange: config.ExchangeConfig{Observe: true}}
+	err = flattenPositions(engine.New(cfg, exch, &savedOrders{}, nil), exch, "manual")
+	if err == nil || !strings.Contains(err.Error(), "2 of 2") || !strings.Contains(err.Error(), "005930") || !strings.Contains(err.Error(), "000660") {
+		t.Errorf("err = %v, want both failed sells", err)
+	}
+
+	store := &savedOrders{}
+	if err := flattenPositions(engine.New(&config.Config{}, exch, store, nil), exch, "manual"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.all()); n != 2 {
+		t.Errorf("saved %d orders, want 2", n)
+	}
+	// Nothing is left to sell.
+	if err := flattenPositions(engine.New(&config.Config{}, exch, store, nil), exch, "manual"); err != nil {
+		t.Errorf("flatten with no positions: %v", err)
+	}
+}
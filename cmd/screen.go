@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/notify"
+	"tradingbot/internal/report"
+	"tradingbot/internal/screener"
+	"tradingbot/internal/strategy"
+	"tradingbot/internal/universe"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// runScreen implements `tradingbot screen`.
+func runScreen(args []string) error {
+	fs := flag.NewFlagSet("screen", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	name := fs.String("universe", "", "universe definition to screen (default: screen.universe)")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	notifyHooks := fs.Bool("notify", false, "post the results to the webhooks configured for screen events")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		*name = cfg.Screen.Universe
+	}
+	if *name == "" {
+		return fmt.Errorf("no universe to screen; set screen.universe or pass -universe")
+	}
+	scr, err := screener.New(cfg.Screen)
+	if err != nil {
+		return err
+	}
+
+	exch, err := connectExchange(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize exchange")
+	}
+	provider, err := connectMarketData(cfg, exch)
+	if err != nil {
+		return err
+	}
+	results, err := screen(cfg, scr, exch, provider, *name)
+	if err != nil {
+		return err
+	}
+
+	if *notifyHooks {
+		ev := events.ScreenEvent{Universe: *name, Symbols: screener.Codes(results), Time: time.Now()}
+		bus := events.NewBus()
+		for _, hook := range cfg.Notify.Webhooks {
+			if err := notify.NewWebhook(hook, bus).Send(context.Background(), ev); err != nil {
+				log.WithError(err).WithField("url", hook.URL).Error("Failed to deliver screen results")
+			}
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tNAME\tMARKET\tCLOSE\tAVG VOLUME\tVOLATILITY")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.0f\t%.2f%%\n", r.Symbol.Code, r.Symbol.Name, r.Symbol.Market,
+			report.FormatKRW(r.Close), r.AvgVolume, r.Volatility*100)
+	}
+	return w.Flush()
+}
+
+// screen loads the members of the named universe and screens them on the
+// candles of source.
+func screen(cfg *config.Config, scr *screener.Screener, exch *exchange.KISExchange, source screener.DailySource, name string) ([]screener.Result, error) {
+	master, err := universe.Load(cfg.Universe, exch, nil)
+	if err != nil {
+		return nil, err
+	}
+	members, err := master.Universe(cfg.Universe, name)
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(logrus.Fields{"universe": name, "symbols": len(members)}).Info("Screening universe...")
+	return scr.Run(source, members)
+}
+
+// applyScreen publishes the outcome of a scheduled screen and, with screen.apply
+// set, makes the passing symbols the traded ones. It returns the strategies to
+// use from now on.
+func applyScreen(cfg *config.Config, bus *events.Bus, strategies map[string]strategy.Strategy, update screener.Update) map[string]strategy.Strategy {
+	if update.Err != nil {
+		log.WithError(update.Err).Error("Scheduled screen failed")
+		return strategies
+	}
+	codes := screener.Codes(update.Results)
+	log.WithFields(logrus.Fields{"universe": cfg.Screen.Universe, "symbols": codes}).Info("Screen finished")
+	bus.Publish(events.ScreenEvent{Universe: cfg.Screen.Universe, Symbols: codes, Time: update.Time})
+
+	if !cfg.Screen.Apply {
+		return strategies
+	}
+	if len(codes) == 0 {
+		log.Warn("No symbols passed the screen, keeping the current trading symbols")
+		return strategies
+	}
+	previous := cfg.Symbols
+	cfg.Symbols = codes
+	next, err := syncStrategies(cfg, strategies, false)
+	if err != nil {
+		cfg.Symbols = previous
+		log.WithError(err).Error("Failed to apply screened symbols")
+		return strategies
+	}
+	return next
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	stdlog "log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"tradingbot/internal/allocation"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/engine"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/paper"
+	"tradingbot/internal/rules"
+	"tradingbot/internal/soak"
+
+	"github.com/pkg/errors"
+)
+
+// runSoak implements `tradingbot soak`. It runs the pipeline of `replay` on
+// synthetic prices, as many cycles per second as asked for as long as asked
+// for, and reports the latency of the cycles and the growth of the heap and
+// of the goroutines. It fails when the growth exceeds the limits, so that it
+// can gate a release.
+func runSoak(args []string) error {
+	fs := flag.NewFlagSet("soak", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	symbols := fs.Int("symbols", 0, "trade this many synthetic symbols instead of the configured ones")
+	rate := fs.Float64("rate", 10, "cycles per second, each running every symbol (0: as fast as possible)")
+	duration := fs.Duration("duration", time.Hour, "how long to run")
+	cycles := fs.Int("cycles", 0, "stop after this many cycles (0: run for -duration)")
+	interval := fs.Duration("interval", soak.DefaultInterval, "simulated time between cycles")
+	volatility := fs.Float64("volatility", soak.DefaultVolatility, "standard deviation of the price change per cycle")
+	seed := fs.Int64("seed", 1, "seed of the synthetic prices")
+	warmup := fs.Int("warmup", 1000, "cycles to run before the baseline of the heap and goroutines is taken")
+	sampleEvery := fs.Duration("sample", time.Minute, "how often to sample and print the heap, goroutines and latency")
+	maxHeapGrowth := fs.Int("max-heap-growth", 64, "fail when the live heap grows by more MiB after the warm-up (0: no limit)")
+	maxGoroutineGrowth := fs.Int("max-goroutine-growth", 10, "fail when more goroutines than this are left running after the warm-up (-1: no limit)")
+	balance := fs.Float64("balance", 10000000, "initial balance in KRW")
+	commission := fs.Float64("commission", 0.0025, "commission rate per trade")
+	logLevel := fs.String("log-level", "warn", "log level of the pipeline during the run; info logs every signal and order")
+	fs.Parse(args)
+
+	cfg, err := config.LoadProfile(cf.path, cf.profile)
+	if err != nil {
+		return err
+	}
+	if err := logging.Configure(cfg.Logging, *logLevel); err != nil {
+		return errors.Wrap(err, "failed to configure logging")
+	}
+	if *symbols > 0 {
+		// Synthetic codes do not belong to any sleeve.
+		cfg.Symbols = make([]string, *symbols)
+		for i := range cfg.Symbols {
+			cfg.Symbols[i] = fmt.Sprintf("%06d", 900000+i)
+		}
+		cfg.Allocation.Sleeves = nil
+	}
+	// The synthetic prices trade around the clock.
+	cfg.Market.Enabled = false
+
+	strategies, err := syncStrategies(cfg, nil, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize strategies")
+	}
+	clk := clock.NewSimulated(time.Date(2026, 1, 2, 9, 0, 0, 0, market.KST))
+	exch := paper.New(*balance, *commission, clk)
+	eng := engine.New(cfg, exch, discardStore{}, strategies)
+	eng.SetClock(clk)
+	if len(cfg.SignalRules) > 0 {
+		eng.SetRules(rules.New(cfg.SignalRules))
+	}
+	if len(cfg.Allocation.Sleeves) > 0 {
+		alloc, err := allocation.New(cfg)
+		if err != nil {
+			return err
+		}
+		eng.SetAllocator(alloc)
+	}
+	var published int64
+	eng.Bus.Subscribe(func(events.Event) { atomic.AddInt64(&published, 1) }, events.KindError)
+
+	h := soak.New(eng, exch, clk, soak.Options{
+		Symbols:     cfg.TradingSymbols(),
+		Rate:        *rate,
+		Duration:    *duration,
+		Cycles:      *cycles,
+		Interval:    *interval,
+		MaxParallel: cfg.MaxParallel,
+		Volatility:  *volatility,
+		Seed:        *seed,
+		Warmup:      *warmup,
+		SampleEvery: *sampleEvery,
+	})
+	if *cycles > 0 {
+		h.Duration = 0
+	}
+	fmt.Printf("Soaking %d symbols at %g cycles/s for %s\n\n", len(h.Symbols), *rate, runLength(h.Options))
+	// The rows are printed as they come, so the columns have fixed widths.
+	fmt.Printf("%10s %10s %10s %10s %12s\n", "ELAPSED", "CYCLES", "HEAP MiB", "GOROUTINES", "P99")
+	h.OnSample = func(s soak.Sample) {
+		fmt.Printf("%10s %10d %10.1f %10d %12s\n", s.Elapsed.Round(time.Second), s.Cycles, mib(int64(s.HeapAlloc)), s.Goroutines, s.P99.Round(time.Microsecond))
+	}
+	// The strategies log every signal with the standard logger.
+	stdlog.SetOutput(ioutil.Discard)
+	defer stdlog.SetOutput(os.Stderr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := h.Run(ctx)
+	if err != nil && err != context.Canceled {
+		return err
+	}
+
+	fmt.Printf("\n%d cycles in %s, %d orders, %d errors, %d overruns\n", report.Cycles, report.Elapsed.Round(time.Second),
+		len(exch.Orders()), atomic.LoadInt64(&published), report.Overruns)
+	fmt.Printf("Cycle latency: p50 %s, p90 %s, p99 %s, max %s\n", report.P50.Round(time.Microsecond), report.P90.Round(time.Microsecond),
+		report.P99.Round(time.Microsecond), report.Max.Round(time.Microsecond))
+	fmt.Printf("Heap: %+.1f MiB after the warm-up, trend %+.1f MiB/h\n", mib(report.HeapGrowth()), report.HeapGrowthPerHour()/(1<<20))
+	fmt.Printf("Goroutines: %+d after the warm-up\n", report.GoroutineGrowth())
+
+	if *maxHeapGrowth > 0 && report.HeapGrowth() > int64(*maxHeapGrowth)<<20 {
+		return fmt.Errorf("heap grew by %.1f MiB, more than %d MiB", mib(report.HeapGrowth()), *maxHeapGrowth)
+	}
+	if *maxGoroutineGrowth >= 0 && report.GoroutineGrowth() > *maxGoroutineGrowth {
+		return fmt.Errorf("%d goroutines leaked, more than %d", report.GoroutineGrowth(), *maxGoroutineGrowth)
+	}
+	return nil
+}
+
+// runLength describes when a run stops.
+func runLength(opts soak.Options) string {
+	if opts.Cycles > 0 {
+		return fmt.Sprintf("%d cycles", opts.Cycles)
+	}
+	return opts.Duration.String()
+}
+
+func mib(bytes int64) float64 {
+	return float64(bytes) / (1 << 20)
+}
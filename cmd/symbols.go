@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+	"tradingbot/internal/universe"
+
+	"github.com/pkg/errors"
+)
+
+// runSymbols implements `tradingbot symbols`.
+func runSymbols(args []string) error {
+	fs := flag.NewFlagSet("symbols", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	name := fs.String("universe", "", "list the members of this universe definition")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	if cfg.Universe.Source == "" {
+		return fmt.Errorf("no symbol master configured; set universe.source")
+	}
+
+	codes := fs.Args()
+	if len(codes) == 0 && *name == "" {
+		codes = cfg.TradingSymbols()
+	}
+	master, err := loadMaster(cfg, codes)
+	if err != nil {
+		return err
+	}
+
+	var symbols []models.Symbol
+	if *name != "" {
+		if symbols, err = master.Universe(cfg.Universe, *name); err != nil {
+			return err
+		}
+	} else {
+		for _, code := range codes {
+			s, ok := master.Lookup(code)
+			if !ok {
+				s = models.Symbol{Code: code, Status: "unknown"}
+			}
+			symbols = append(symbols, s)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tNAME\tMARKET\tTYPE\tSECTOR\tLOT\tSTATUS\tINDEXES")
+	for _, s := range symbols {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			s.Code, s.Name, s.Market, s.Type, s.Sector, s.LotSize, s.Status, strings.Join(s.Indexes, ","))
+	}
+	return w.Flush()
+}
+
+// loadMaster loads the configured symbol master, connecting to KIS only when it
+// is the source.
+func loadMaster(cfg *config.Config, codes []string) (*universe.Master, error) {
+	var source universe.SymbolSource
+	if cfg.Universe.Source == config.UniverseSourceKIS {
+		exch, err := connectExchange(cfg)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize exchange")
+		}
+		source = exch
+	}
+	return universe.Load(cfg.Universe, source, codes)
+}
+
+// checkSymbols verifies the traded symbols against the symbol master, when one
+// is configured, and returns the master, or nil without one.
+func checkSymbols(cfg *config.Config, source universe.SymbolSource) (*universe.Master, error) {
+	if cfg.Universe.Source == "" {
+		return nil, nil
+	}
+	master, err := universe.Load(cfg.Universe, source, cfg.TradingSymbols())
+	if err != nil {
+		return nil, err
+	}
+	if err := master.Validate(cfg.TradingSymbols()); err != nil {
+		return nil, err
+	}
+	log.WithField("symbols", master.Len()).Info("Trading symbols checked against the symbol master")
+	return master, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/database"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/report"
+
+	"github.com/pkg/errors"
+)
+
+// runTaxGains implements `tradingbot tax gains`.
+func runTaxGains(args []string) error {
+	fs := flag.NewFlagSet("tax gains", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	year := fs.Int("year", time.Now().In(market.KST).Year()-1, "calendar year of the sells (default: last year)")
+	csvOut := fs.String("csv", "", "write the per-symbol gains as CSV to this file")
+	lotsOut := fs.String("lots-csv", "", "write the lots sold as CSV to this file")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	lots, err := trackLots(cfg, time.Date(*year+1, 1, 1, 0, 0, 0, 0, market.KST))
+	if err != nil {
+		return err
+	}
+	gains := report.YearlyGains(lots.Disposals, *year)
+
+	if *csvOut != "" {
+		if err := writeFile(*csvOut, gains.WriteCSV); err != nil {
+			return errors.Wrap(err, "failed to write gains CSV")
+		}
+	}
+	if *lotsOut != "" {
+		if err := writeFile(*lotsOut, gains.WriteDisposalsCSV); err != nil {
+			return errors.Wrap(err, "failed to write lots CSV")
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(gains)
+	}
+
+	method := cfg.Tax.LotMethod
+	if method == "" {
+		method = config.LotFIFO
+	}
+	fmt.Printf("Realized gains %d (%s lots)\n\n", *year, method)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SYMBOL\tQTY\tPROCEEDS\tCOST\tEXPENSES\tGAIN")
+	printRow := func(symbol string, g report.RealizedGain) {
+		fmt.Fprintf(w, "%s\t%g\t%s\t%s\t%s\t%s\n", symbol, g.Quantity,
+			report.FormatKRW(g.Proceeds), report.FormatKRW(g.Cost), report.FormatKRW(g.Expenses), report.FormatKRW(g.Gain))
+	}
+	for _, g := range gains.Rows {
+		printRow(g.Symbol, g)
+	}
+	printRow("*", gains.Total)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if gains.Total.Unmatched > 0 {
+		fmt.Printf("\n%g shares were sold without a stored buy; their acquisition cost is counted as zero.\n", gains.Total.Unmatched)
+	}
+	if lots.Unpriced > 0 {
+		fmt.Printf("\n%d orders without a price were left out.\n", lots.Unpriced)
+	}
+	return nil
+}
+
+// runTaxLots implements `tradingbot tax lots`.
+func runTaxLots(args []string) error {
+	fs := flag.NewFlagSet("tax lots", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	lots, err := trackLots(cfg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LOT\tSYMBOL\tACQUIRED\tQTY\tPRICE\tCOST/SHARE")
+	for _, l := range lots.Open {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%g\t%g\t%.2f\n", l.ID, l.Symbol, l.Acquired.In(market.KST).Format("2006-01-02"), l.Quantity, l.Price, l.Cost)
+	}
+	return w.Flush()
+}
+
+// runTaxSelect implements `tradingbot tax select`.
+func runTaxSelect(args []string) error {
+	fs := flag.NewFlagSet("tax select", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage: tax select <sell-id> <lot-id>=<qty>...")
+	}
+
+	sellID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sell order ID %q", fs.Arg(0))
+	}
+	var selections []models.LotSelection
+	for _, arg := range fs.Args()[1:] {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid lot %q, want <lot-id>=<qty>", arg)
+		}
+		lotID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid lot ID %q", parts[0])
+		}
+		qty, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || qty <= 0 {
+			return fmt.Errorf("invalid quantity %q", parts[1])
+		}
+		selections = append(selections, models.LotSelection{SellID: sellID, LotID: lotID, Quantity: qty})
+	}
+
+	cfg, _, err := loadConfig(cf)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := db.SaveLotSelections(sellID, selections); err != nil {
+		return err
+	}
+	if cfg.Tax.LotMethod != config.LotSpecific {
+		fmt.Printf("Saved; selections are only used with tax.lot_method %s\n", config.LotSpecific)
+	}
+	return nil
+}
+
+// trackLots matches the orders stored before end to tax lots with the
+// configured method.
+func trackLots(cfg *config.Config, end time.Time) (report.TaxLots, error) {
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		return report.TaxLots{}, err
+	}
+	defer db.Close()
+	orders, err := db.ListOrdersBefore(end)
+	if err != nil {
+		return report.TaxLots{}, err
+	}
+	var selections []models.LotSelection
+	if cfg.Tax.LotMethod == config.LotSpecific {
+		if selections, err = db.ListLotSelections(); err != nil {
+			return report.TaxLots{}, err
+		}
+	}
+	costs := report.Costs{CommissionRate: cfg.Fees.CommissionRate, SellTaxRate: cfg.Fees.SellTaxRate}
+	return report.TrackLots(orders, cfg.Tax.LotMethod, selections, costs)
+}
+
+func writeFile(filename string, write func(io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
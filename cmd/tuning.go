@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	stdlog "log"
+	"os"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+	"tradingbot/internal/strategy"
+	"tradingbot/internal/tuning"
+
+	"github.com/sirupsen/logrus"
+)
+
+// startTuning schedules the parameter tuner and returns it with the channel
+// its proposals arrive on.
+func startTuning(ctx context.Context, cfg *config.Config, source tuning.Source) (*tuning.Tuner, <-chan tuning.Update, error) {
+	zone := cfg.Maintenance.Timezone
+	if zone == "" {
+		zone = config.DefaultMaintenanceTimezone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, nil, err
+	}
+	schedule, err := tuning.Schedule(cfg.Tuning, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := movingAverageParams(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tuner := tuning.New(cfg.Tuning, cfg.TradingPair, source)
+	tuner.SetCurrent(current)
+	updates := tuning.Watch(ctx, clock.Real, schedule, func(now time.Time) (*tuning.Proposal, error) {
+		// The strategy logs every bar; silence it while running hundreds of
+		// backtests, as `optimize` does.
+		stdlog.SetOutput(ioutil.Discard)
+		defer stdlog.SetOutput(os.Stderr)
+		return tuner.Propose(now)
+	})
+	log.WithFields(logrus.Fields{"schedule": schedule.String(), "timezone": loc, "apply": cfg.Tuning.Apply}).Info("Parameter tuning scheduled")
+	return tuner, updates, nil
+}
+
+// movingAverageParams returns the configured moving average parameters.
+func movingAverageParams(cfg *config.Config) (models.MovingAverageConfig, error) {
+	var params models.MovingAverageConfig
+	raw, err := cfg.StrategyParamsFor(cfg.Strategy)
+	if err != nil {
+		return params, err
+	}
+	return params, raw.Decode(&params)
+}
+
+// handleTuning announces a proposal and applies it at once when tuning.apply
+// is "auto". It returns the strategies to trade with and the proposal still
+// waiting for approval, if any.
+func handleTuning(cfg *config.Config, bus *events.Bus, tuner *tuning.Tuner, strategies map[string]strategy.Strategy, update tuning.Update) (map[string]strategy.Strategy, *tuning.Proposal) {
+	if update.Err != nil {
+		log.WithError(update.Err).Error("Parameter tuning failed")
+		return strategies, nil
+	}
+	if update.Proposal == nil {
+		return strategies, nil
+	}
+	p := update.Proposal
+	bus.Publish(tuningEvent(p, false))
+	if cfg.Tuning.Apply != config.TuningApplyAuto {
+		log.WithFields(logrus.Fields{"short_period": p.Proposed.ShortPeriod, "long_period": p.Proposed.LongPeriod}).
+			Warn("New strategy parameters await approval: POST /control/tuning/approve")
+		return strategies, p
+	}
+	next, err := applyTuning(cfg, bus, tuner, strategies, p)
+	if err != nil {
+		log.WithError(err).Error("Failed to apply tuned parameters")
+		return strategies, nil
+	}
+	return next, nil
+}
+
+// applyTuning switches the strategies to the proposed parameters.
+func applyTuning(cfg *config.Config, bus *events.Bus, tuner *tuning.Tuner, strategies map[string]strategy.Strategy, p *tuning.Proposal) (map[string]strategy.Strategy, error) {
+	current, err := cfg.StrategyParamsFor(cfg.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	previous := cfg.Strategies
+	cfg.Strategies = make(map[string]config.StrategyParams, len(previous))
+	for name, params := range previous {
+		cfg.Strategies[name] = params
+	}
+	cfg.Strategies[cfg.Strategy] = p.Params(current)
+
+	next, err := syncStrategies(cfg, strategies, false)
+	if err != nil {
+		cfg.Strategies = previous
+		return nil, err
+	}
+	tuner.SetCurrent(p.Proposed)
+	bus.Publish(tuningEvent(p, true))
+	log.WithFields(logrus.Fields{"short_period": p.Proposed.ShortPeriod, "long_period": p.Proposed.LongPeriod}).
+		Info("Tuned strategy parameters applied until the config is reloaded")
+	return next, nil
+}
+
+func tuningEvent(p *tuning.Proposal, applied bool) events.TuningEvent {
+	return events.TuningEvent{
+		Symbol:         p.Symbol,
+		Days:           p.Days,
+		Current:        p.Current,
+		Proposed:       p.Proposed,
+		CurrentProfit:  p.CurrentProfit,
+		ProposedProfit: p.ProposedProfit,
+		Applied:        applied,
+		Time:           time.Now(),
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"tradingbot/internal/config"
+)
+
+// runConfigValidate implements `tradingbot config validate [file]`: it loads the
+// file with all overrides applied and prints the effective configuration, or the
+// list of problems when it is invalid.
+func runConfigValidate(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	fs.Parse(args)
+
+	path := cf.path
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	cfg, err := config.LoadProfile(path, cf.profile)
+	if err != nil {
+		return err
+	}
+
+	out, err := cfg.Redacted().Marshal()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s is valid. Effective configuration:\n", path)
+	os.Stdout.Write(out)
+	return nil
+}
@@ -0,0 +1,459 @@
+package allocation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/sizing"
+	"tradingbot/internal/strategy"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const (
+	defaultLookback = 20
+	// maxHistory is the number of daily returns kept per sleeve, a year of
+	// trading days.
+	maxHistory = 250
+)
+
+// Holding is a sleeve's position in one symbol.
+type Holding struct {
+	Quantity float64 `json:"quantity"`
+	AvgPrice float64 `json:"avg_price"`
+}
+
+// Book is the isolated account of a sleeve.
+type Book struct {
+	Strategy  string             `json:"strategy"`
+	Weight    float64            `json:"weight"`
+	Cash      float64            `json:"cash"`
+	Positions map[string]Holding `json:"positions"`
+	Realized  float64            `json:"realized_pnl"`
+	// Equity is cash plus the positions valued at the last seen prices.
+	Equity float64 `json:"equity"`
+}
+
+// Analysis is a sized signal of one sleeve together with the indicators
+// behind it.
+type Analysis struct {
+	Signal     *models.Signal
+	Indicators map[string]float64
+}
+
+type sleeve struct {
+	name       string
+	symbols    []string
+	weight     float64
+	strategies map[string]strategy.Strategy
+	book       Book
+	// equity holds the sleeve's equity at the end of each of the last days.
+	equity []float64
+	// open is the sleeve's equity at the start of the current day, after
+	// rebalancing, and returns its daily returns since.
+	open    float64
+	returns []float64
+}
+
+// Allocator runs the strategies of the configured sleeves, sizes their signals
+// from each sleeve's capital and keeps every sleeve's positions and PnL apart.
+// It is safe for concurrent use.
+type Allocator struct {
+	scheme   string
+	lookback int
+
+	mu        sync.Mutex
+	sleeves   []*sleeve
+	prices    map[string]float64
+	day       time.Time
+	sentiment strategy.Sentiment
+	vwap      strategy.VWAP
+	kelly     *sizing.Kelly
+}
+
+// New builds one strategy per sleeve and symbol and splits the capital by the
+// sleeve weights.
+func New(cfg *config.Config) (*Allocator, error) {
+	a := &Allocator{
+		scheme:   cfg.Allocation.Scheme,
+		lookback: cfg.Allocation.Lookback,
+		prices:   map[string]float64{},
+	}
+	if a.scheme == "" {
+		a.scheme = config.AllocationFixed
+	}
+	if a.lookback == 0 {
+		a.lookback = defaultLookback
+	}
+
+	for _, sc := range cfg.Allocation.Sleeves {
+		name := sc.Strategy
+		if name == "" {
+			name = cfg.Strategy
+		}
+		params, err := cfg.StrategyParamsFor(name)
+		if err != nil {
+			return nil, fmt.Errorf("sleeve %s: %v", sc.Name, err)
+		}
+		s := &sleeve{
+			name:       sc.Name,
+			symbols:    sc.Symbols,
+			weight:     sc.Weight,
+			strategies: map[string]strategy.Strategy{},
+			book:       Book{Strategy: sc.Name, Positions: map[string]Holding{}},
+		}
+		if len(s.symbols) == 0 {
+			s.symbols = cfg.TradingSymbols()
+		}
+		for _, symbol := range s.symbols {
+			if s.strategies[symbol], err = strategy.New(name, params); err != nil {
+				return nil, fmt.Errorf("sleeve %s: %v", sc.Name, err)
+			}
+		}
+		a.sleeves = append(a.sleeves, s)
+	}
+
+	weights := a.fixedWeights()
+	for i, s := range a.sleeves {
+		s.book.Weight = weights[i]
+		s.book.Cash = cfg.Allocation.Capital * weights[i]
+	}
+	return a, nil
+}
+
+// SetSentiment feeds the news sentiment of source to sleeve strategies that
+// use it.
+func (a *Allocator) SetSentiment(source strategy.Sentiment) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sentiment = source
+}
+
+// SetKelly sizes the buys of the sleeves k is configured for by fractional
+// Kelly of the sleeve's equity instead of its per-symbol budget.
+func (a *Allocator) SetKelly(k *sizing.Kelly) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.kelly = k
+}
+
+// SetVWAP feeds the session VWAP of source to sleeve strategies that use it.
+func (a *Allocator) SetVWAP(source strategy.VWAP) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.vwap = source
+}
+
+// Analyze runs the strategy of every sleeve trading symbol on data and returns
+// their signals, sized to the sleeve: a buy spends the sleeve's per-symbol
+// budget, or its Kelly fraction (see SetKelly), when it holds none of symbol,
+// and a sell closes the sleeve's
+// position. Signals that cannot be sized become holds. The first price seen on
+// a new KST day closes the previous day and rebalances the sleeves.
+func (a *Allocator) Analyze(symbol string, data *models.MarketData, now time.Time) []Analysis {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	price, err := strconv.ParseFloat(data.StckPrpr, 64)
+	if err == nil && price > 0 {
+		a.prices[symbol] = price
+	}
+	a.roll(now)
+
+	var out []Analysis
+	for _, s := range a.sleeves {
+		strat, ok := s.strategies[symbol]
+		if !ok {
+			continue
+		}
+		strategy.FeedSentiment(strat, a.sentiment, symbol)
+		strategy.FeedVWAP(strat, a.vwap, symbol)
+		signal := strat.Analyze(data)
+		signal.Pair = symbol
+		signal.Strategy = s.name
+		a.size(s, signal, price)
+
+		var indicators map[string]float64
+		if explainer, ok := strat.(strategy.Explainer); ok {
+			indicators = explainer.Indicators()
+		}
+		out = append(out, Analysis{Signal: signal, Indicators: indicators})
+	}
+	return out
+}
+
+func (a *Allocator) size(s *sleeve, signal *models.Signal, price float64) {
+	held := s.book.Positions[signal.Pair].Quantity
+	switch signal.Type {
+	case models.BuySignal:
+		budget := math.Min(a.equity(s)/float64(len(s.symbols)), s.book.Cash)
+		if a.kelly != nil {
+			if fraction, _, ok := a.kelly.Fraction(s.name); ok {
+				budget = math.Min(a.equity(s)*fraction, s.book.Cash)
+			}
+		}
+		signal.Amount = 0
+		if held == 0 && price > 0 {
+			signal.Amount = math.Floor(budget / price)
+		}
+	case models.SellSignal:
+		signal.Amount = held
+	}
+	if signal.Type != models.HoldSignal && signal.Amount < 1 {
+		signal.Type = models.HoldSignal
+		signal.Amount = 0
+	}
+}
+
+// Record books a placed order of a sleeve. The order's quantity and price are
+// used when the exchange reported them, otherwise those of the signal and the
+// price it was generated at.
+func (a *Allocator) Record(order *models.Order, signal *models.Signal, price float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fill := *order
+	fill.Strategy = signal.Strategy
+	if fill.Amount == 0 {
+		fill.Amount = signal.Amount
+	}
+	if fill.Price == 0 {
+		fill.Price = price
+	}
+	if fill.Side == "" {
+		fill.Side = models.OrderSide(signal.Type)
+	}
+	a.apply(fill)
+}
+
+// Restore rebuilds the books from stored sleeve orders, oldest first, e.g.
+// after a restart. Orders of unknown sleeves are ignored.
+func (a *Allocator) Restore(orders []models.Order) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, order := range orders {
+		a.apply(order)
+	}
+}
+
+func (a *Allocator) apply(order models.Order) {
+	s := a.find(order.Strategy)
+	if s == nil {
+		return
+	}
+	h := s.book.Positions[order.Pair]
+	value := order.Amount * order.Price
+	switch order.Side {
+	case models.OrderSideBuy:
+		h.AvgPrice = (h.AvgPrice*h.Quantity + value) / (h.Quantity + order.Amount)
+		h.Quantity += order.Amount
+		s.book.Cash -= value
+	case models.OrderSideSell:
+		quantity := math.Min(order.Amount, h.Quantity)
+		s.book.Realized += (order.Price - h.AvgPrice) * quantity
+		s.book.Cash += value
+		h.Quantity -= quantity
+	}
+	if h.Quantity > 0 {
+		s.book.Positions[order.Pair] = h
+	} else {
+		delete(s.book.Positions, order.Pair)
+	}
+	if _, ok := a.prices[order.Pair]; !ok && order.Price > 0 {
+		a.prices[order.Pair] = order.Price
+	}
+}
+
+func (a *Allocator) find(name string) *sleeve {
+	for _, s := range a.sleeves {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Books returns a snapshot of every sleeve's book, in configuration order.
+func (a *Allocator) Books() []Book {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	books := make([]Book, len(a.sleeves))
+	for i, s := range a.sleeves {
+		b := s.book
+		b.Positions = make(map[string]Holding, len(s.book.Positions))
+		for symbol, h := range s.book.Positions {
+			b.Positions[symbol] = h
+		}
+		b.Equity = a.equity(s)
+		books[i] = b
+	}
+	return books
+}
+
+func (a *Allocator) equity(s *sleeve) float64 {
+	equity := s.book.Cash
+	for symbol, h := range s.book.Positions {
+		price, ok := a.prices[symbol]
+		if !ok {
+			price = h.AvgPrice
+		}
+		equity += h.Quantity * price
+	}
+	return equity
+}
+
+// roll closes the previous day when now falls on a new KST day: it records each
+// sleeve's equity and rebalances.
+func (a *Allocator) roll(now time.Time) {
+	local := now.In(market.KST)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, market.KST)
+	if a.day.IsZero() {
+		a.day = day
+		a.open()
+		return
+	}
+	if !day.After(a.day) {
+		return
+	}
+	a.day = day
+
+	for _, s := range a.sleeves {
+		equity := a.equity(s)
+		s.equity = append(s.equity, equity)
+		if len(s.equity) > a.lookback+1 {
+			s.equity = s.equity[len(s.equity)-a.lookback-1:]
+		}
+		if s.open > 0 {
+			s.returns = append(s.returns, equity/s.open-1)
+			if len(s.returns) > maxHistory {
+				s.returns = s.returns[len(s.returns)-maxHistory:]
+			}
+		}
+	}
+	a.rebalance()
+	a.open()
+}
+
+// open starts a day for every sleeve at its current equity.
+func (a *Allocator) open() {
+	for _, s := range a.sleeves {
+		s.open = a.equity(s)
+	}
+}
+
+// DailyReturns returns the returns of every sleeve on the days closed since
+// the first price it saw, oldest first, by sleeve name. Cash moved between
+// sleeves by rebalancing does not count as a return. At most the last 250
+// days are kept.
+func (a *Allocator) DailyReturns() map[string][]float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[string][]float64, len(a.sleeves))
+	for _, s := range a.sleeves {
+		out[s.name] = append([]float64(nil), s.returns...)
+	}
+	return out
+}
+
+// rebalance moves cash between sleeves so that each holds its target share of
+// the combined equity. Open positions are kept; a sleeve whose positions
+// exceed its share simply cannot buy until they are sold.
+func (a *Allocator) rebalance() {
+	weights := a.weights()
+	total := 0.0
+	for _, s := range a.sleeves {
+		total += a.equity(s)
+	}
+	fields := logrus.Fields{"scheme": a.scheme}
+	for i, s := range a.sleeves {
+		s.book.Weight = weights[i]
+		s.book.Cash += total*weights[i] - a.equity(s)
+		fields[s.name] = fmt.Sprintf("%.1f%%", weights[i]*100)
+	}
+	log.WithFields(fields).Info("Sleeves rebalanced")
+}
+
+// weights returns the target share of each sleeve. The dynamic schemes fall
+// back to the fixed weights until every sleeve has two days of returns.
+func (a *Allocator) weights() []float64 {
+	if a.scheme == config.AllocationFixed {
+		return a.fixedWeights()
+	}
+	scores := make([]float64, len(a.sleeves))
+	for i, s := range a.sleeves {
+		returns := dailyReturns(s.equity)
+		if len(returns) < 2 {
+			return a.fixedWeights()
+		}
+		switch a.scheme {
+		case config.AllocationInverseVolatility:
+			if sd := stddev(returns); sd > 0 {
+				scores[i] = 1 / sd
+			}
+		case config.AllocationPerformance:
+			// Losing sleeves keep a small share so they can recover.
+			total := s.equity[len(s.equity)-1]/s.equity[0] - 1
+			scores[i] = math.Max(total, 0) + 0.01
+		}
+	}
+	return normalize(scores, a.fixedWeights())
+}
+
+func (a *Allocator) fixedWeights() []float64 {
+	weights := make([]float64, len(a.sleeves))
+	for i, s := range a.sleeves {
+		weights[i] = s.weight
+	}
+	equal := make([]float64, len(a.sleeves))
+	for i := range equal {
+		equal[i] = 1
+	}
+	return normalize(weights, normalize(equal, nil))
+}
+
+// normalize scales scores to sum to one, or returns fallback when they sum to
+// zero.
+func normalize(scores, fallback []float64) []float64 {
+	total := 0.0
+	for _, v := range scores {
+		total += v
+	}
+	if total <= 0 {
+		return fallback
+	}
+	out := make([]float64, len(scores))
+	for i, v := range scores {
+		out[i] = v / total
+	}
+	return out
+}
+
+func dailyReturns(equity []float64) []float64 {
+	var returns []float64
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1] > 0 {
+			returns = append(returns, equity[i]/equity[i-1]-1)
+		}
+	}
+	return returns
+}
+
+func stddev(values []float64) float64 {
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)-1))
+}
@@ -0,0 +1,132 @@
+package allocation
+
+import (
+	"math"
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+func testConfig(scheme string) *config.Config {
+	return &config.Config{
+		TradingPair: "005930",
+		Strategy:    "moving_average",
+		Strategies: map[string]config.StrategyParams{
+			"moving_average": {"short_period": 1, "long_period": 2, "threshold": 0.0},
+		},
+		Allocation: config.AllocationConfig{
+			Scheme:  scheme,
+			Capital: 1000000,
+			Sleeves: []config.SleeveConfig{
+				{Name: "big", Weight: 3},
+				{Name: "small", Weight: 1},
+			},
+		},
+	}
+}
+
+func analyze(a *Allocator, price string, now time.Time) map[string]*models.Signal {
+	out := make(map[string]*models.Signal)
+	for _, r := range a.Analyze("005930", &models.MarketData{StckPrpr: price}, now) {
+		out[r.Signal.Strategy] = r.Signal
+	}
+	return out
+}
+
+func TestSleevesKeepPositionsApart(t *testing.T) {
+	a, err := New(testConfig(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+
+	analyze(a, "100", now)
+	buys := analyze(a, "110", now)
+	if buys["big"].Type != models.BuySignal || buys["big"].Amount != 6818 {
+		t.Fatalf("big = %+v, want a buy of 6818", buys["big"])
+	}
+	if buys["small"].Type != models.BuySignal || buys["small"].Amount != 2272 {
+		t.Fatalf("small = %+v, want a buy of 2272", buys["small"])
+	}
+	for _, signal := range buys {
+		a.Record(&models.Order{Pair: "005930", Side: models.OrderSideBuy}, signal, 110)
+	}
+
+	// Holding sleeves do not buy again.
+	if again := analyze(a, "120", now); again["big"].Type != models.HoldSignal {
+		t.Errorf("big = %+v, want hold while holding", again["big"])
+	}
+
+	sells := analyze(a, "100", now)
+	if sells["small"].Type != models.SellSignal || sells["small"].Amount != 2272 {
+		t.Fatalf("small = %+v, want a sale of its own 2272 shares", sells["small"])
+	}
+	a.Record(&models.Order{Pair: "005930", Side: models.OrderSideSell, Amount: 2272, Price: 100}, sells["small"], 100)
+
+	books := a.Books()
+	if books[1].Realized != -22720 || len(books[1].Positions) != 0 {
+		t.Errorf("small book = %+v, want realized -22720 and no positions", books[1])
+	}
+	if books[0].Realized != 0 || books[0].Positions["005930"].Quantity != 6818 {
+		t.Errorf("big book = %+v, want its 6818 shares untouched", books[0])
+	}
+}
+
+func TestRestoreRebuildsBooks(t *testing.T) {
+	a, _ := New(testConfig(""))
+	a.Restore([]models.Order{
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Price: 1000, Strategy: "small"},
+		{Pair: "005930", Side: models.OrderSideSell, Amount: 4, Price: 1500, Strategy: "small"},
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 1, Price: 1000, Strategy: "removed"},
+	})
+	b := a.Books()[1]
+	if b.Realized != 2000 || b.Positions["005930"].Quantity != 6 || b.Cash != 250000-10000+6000 {
+		t.Errorf("book = %+v, want realized 2000, 6 shares and cash 246000", b)
+	}
+}
+
+func TestDynamicWeights(t *testing.T) {
+	a, _ := New(testConfig(config.AllocationInverseVolatility))
+	if w := a.weights(); w[0] != 0.75 || w[1] != 0.25 {
+		t.Errorf("weights without history = %v, want the fixed weights", w)
+	}
+
+	a.sleeves[0].equity = []float64{100, 102, 100, 102} // ±2% a day
+	a.sleeves[1].equity = []float64{100, 101, 100, 101} // ±1% a day
+	w := a.weights()
+	if math.Abs(w[1]-2*w[0]) > 0.01 {
+		t.Errorf("inverse volatility weights = %v, want the calmer sleeve at about twice the share", w)
+	}
+
+	a.scheme = config.AllocationPerformance
+	a.sleeves[0].equity = []float64{100, 105, 110}
+	a.sleeves[1].equity = []float64{100, 95, 90}
+	if w := a.weights(); w[0] < 0.9 {
+		t.Errorf("performance weights = %v, want most capital on the winning sleeve", w)
+	}
+}
+
+func TestRebalanceOnNewDay(t *testing.T) {
+	a, _ := New(testConfig(""))
+	day := time.Date(2026, 10, 15, 15, 0, 0, 0, market.KST)
+	analyze(a, "100", day)
+	a.sleeves[1].book.Cash += 100000 // the small sleeve made money
+
+	analyze(a, "100", day.Add(18*time.Hour))
+	books := a.Books()
+	if books[0].Cash != 825000 || books[1].Cash != 275000 {
+		t.Errorf("cash = %g / %g, want 825000 / 275000 after rebalancing", books[0].Cash, books[1].Cash)
+	}
+
+	// The cash moved by rebalancing is not a return of the next day.
+	analyze(a, "100", day.Add(42*time.Hour))
+	returns := a.DailyReturns()
+	if r := returns["small"]; len(r) != 2 || math.Abs(r[0]-0.4) > 1e-9 || r[1] != 0 {
+		t.Errorf("small returns = %v, want [0.4 0]", r)
+	}
+	if r := returns["big"]; len(r) != 2 || r[0] != 0 || r[1] != 0 {
+		t.Errorf("big returns = %v, want [0 0]", r)
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"tradingbot/internal/config"
+)
+
+// roleRanks orders the roles; a role may do whatever lower ones may.
+var roleRanks = map[string]int{config.RoleRead: 1, config.RoleOperator: 2, config.RoleAdmin: 3}
+
+// caller is who a request authenticated as.
+type caller struct {
+	Name string
+	Role string
+}
+
+type callerKey struct{}
+
+// callerOf returns the caller stored in the request's context by authenticate.
+func callerOf(r *http.Request) (caller, bool) {
+	c, ok := r.Context().Value(callerKey{}).(caller)
+	return c, ok
+}
+
+// identify returns the caller a bearer credential belongs to: the API token,
+// one of the API keys, or a JWT.
+func (s *Server) identify(credential string, now time.Time) (caller, bool) {
+	if credential == "" {
+		return caller{}, false
+	}
+	s.mu.Lock()
+	api := s.creds
+	s.mu.Unlock()
+	if api.Token != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(api.Token)) == 1 {
+		return caller{Name: "token", Role: config.RoleAdmin}, true
+	}
+	for _, k := range api.Keys {
+		if k.Key != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(k.Key)) == 1 {
+			return caller{Name: k.Name, Role: k.Role}, true
+		}
+	}
+	if api.JWT.Secret != "" && strings.Count(credential, ".") == 2 {
+		c, err := verifyJWT(credential, api.JWT, now)
+		if err != nil {
+			log.WithError(err).Warn("Rejected API JWT")
+			return caller{}, false
+		}
+		return c, true
+	}
+	return caller{}, false
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := s.identify(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), time.Now())
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	})
+}
+
+// require only lets callers with at least role through.
+func require(role string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := callerOf(r)
+		if !ok || roleRanks[c.Role] < roleRanks[role] {
+			writeError(w, http.StatusForbidden, "requires the "+role+" role")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// verifyJWT checks an HS256 JWT against cfg and returns the caller it names.
+func verifyJWT(token string, cfg config.JWTConfig, now time.Time) (caller, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return caller{}, err
+	}
+	// Only HS256 is accepted, whatever the token claims, so that "none" or
+	// an asymmetric algorithm cannot be used to forge one.
+	if header.Alg != "HS256" {
+		return caller{}, errors.New("unsupported JWT algorithm " + header.Alg)
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.Secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return caller{}, errors.New("invalid JWT signature")
+	}
+
+	var claims struct {
+		Sub  string  `json:"sub"`
+		Role string  `json:"role"`
+		Iss  string  `json:"iss"`
+		Exp  float64 `json:"exp"`
+		Nbf  float64 `json:"nbf"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return caller{}, err
+	}
+	unix := float64(now.Unix())
+	if claims.Exp != 0 && unix >= claims.Exp {
+		return caller{}, errors.New("JWT expired")
+	}
+	if claims.Nbf != 0 && unix < claims.Nbf {
+		return caller{}, errors.New("JWT not valid yet")
+	}
+	if cfg.Issuer != "" && claims.Iss != cfg.Issuer {
+		return caller{}, errors.New("JWT issued by " + claims.Iss)
+	}
+	if roleRanks[claims.Role] == 0 {
+		return caller{}, errors.New("JWT has unknown role " + claims.Role)
+	}
+	name := claims.Sub
+	if name == "" {
+		name = "jwt"
+	}
+	return caller{Name: name, Role: claims.Role}, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("malformed JWT")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("malformed JWT")
+	}
+	return nil
+}
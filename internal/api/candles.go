@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+)
+
+// defaultCandleLimit is how many candles /candles returns without a limit.
+const defaultCandleLimit = 200
+
+// CandleSource provides the recent candles served at /candles, e.g. a
+// candle.Cache.
+type CandleSource interface {
+	Last(symbol string, timeframe time.Duration, n int) ([]candle.Candle, time.Time, bool)
+}
+
+// Candle is a candle as served by /candles.
+type Candle struct {
+	Start  time.Time `json:"start"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+}
+
+// SetCandles sets where /candles reads candles from. Without it the endpoint
+// is unavailable.
+func (s *Server) SetCandles(source CandleSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles = source
+}
+
+// handleCandles returns the cached candles of a symbol, oldest first. The
+// timeframe defaults to the trading loop's, or 1d without one.
+func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	source := s.candles
+	s.mu.Unlock()
+	if source == nil {
+		writeError(w, http.StatusServiceUnavailable, "candle cache not enabled")
+		return
+	}
+
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		writeError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+	timeframe := s.cfg.ParsedTimeframe
+	if tf := q.Get("timeframe"); tf != "" {
+		var err error
+		if timeframe, err = config.ParseTimeframe(tf); err != nil || timeframe <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid timeframe "+strconv.Quote(tf))
+			return
+		}
+	}
+	if timeframe == 0 {
+		timeframe = 24 * time.Hour
+	}
+	limit := defaultCandleLimit
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit "+strconv.Quote(l))
+			return
+		}
+		limit = n
+	}
+
+	candles, _, _ := source.Last(symbol, timeframe, limit)
+	out := make([]Candle, len(candles))
+	for i, c := range candles {
+		out[i] = Candle{Start: c.Start, Open: c.Open, High: c.High, Low: c.Low, Close: c.Close, Volume: c.Volume}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"time"
+	"tradingbot/internal/events"
+)
+
+// cyclePhase is the key of the whole cycle in the latency metrics.
+const cyclePhase = "cycle"
+
+// PhaseLatency sums the durations of a cycle phase over the cycles run since
+// startup, in milliseconds.
+type PhaseLatency struct {
+	Count  int     `json:"count"`
+	LastMs float64 `json:"last_ms"`
+	MeanMs float64 `json:"mean_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+func (p *PhaseLatency) add(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	p.MeanMs = (p.MeanMs*float64(p.Count) + ms) / float64(p.Count+1)
+	p.Count++
+	p.LastMs = ms
+	if ms > p.MaxMs {
+		p.MaxMs = ms
+	}
+}
+
+// recordCycle adds the phases of a finished cycle; s.mu must be held.
+func (s *Server) recordCycle(c events.CycleEvent) {
+	add := func(phase string, d time.Duration) {
+		p := s.latency[phase]
+		if p == nil {
+			p = &PhaseLatency{}
+			s.latency[phase] = p
+		}
+		p.add(d)
+	}
+	add(cyclePhase, c.Duration)
+	for phase, d := range c.Phases {
+		add(phase, d)
+	}
+}
+
+// handleLatency serves the time spent in each phase of the trading cycles,
+// to tell which one slows them down.
+func (s *Server) handleLatency(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	latency := make(map[string]PhaseLatency, len(s.latency))
+	for phase, p := range s.latency {
+		latency[phase] = *p
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, latency)
+}
+
+// handleMetrics serves the Prometheus metrics. The account's value is read
+// at scrape time; if the exchange cannot be reached the last one is kept.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	paused := 0.0
+	if s.control.Paused() {
+		paused = 1
+	}
+	s.metrics.Set("tradingbot_paused", paused)
+	if equity, positions, err := s.valuation(); err != nil {
+		log.WithError(err).Warn("Failed to get equity for the metrics")
+	} else {
+		s.metrics.Set("tradingbot_equity_krw", equity.Equity)
+		s.metrics.Set("tradingbot_cash_krw", equity.Cash)
+		s.mu.Lock()
+		known := s.sectors != nil
+		s.mu.Unlock()
+		if known {
+			for _, e := range s.sectorExposures(equity, positions) {
+				s.metrics.Set("tradingbot_sector_exposure_ratio", e.Exposure, e.Sector)
+				if e.Limit > 0 {
+					s.metrics.Set("tradingbot_sector_limit_ratio", e.Limit, e.Sector)
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := s.metrics.WriteText(w); err != nil {
+		log.WithError(err).Warn("Failed to write metrics")
+	}
+}
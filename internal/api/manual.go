@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// closeRequest is the body of /control/close.
+type closeRequest struct {
+	Symbol string `json:"symbol"`
+}
+
+// tradeRequest is the body of /trades: a trade made outside the bot, e.g. in
+// the broker's app, to be recorded as if the bot had placed it.
+type tradeRequest struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Quantity float64 `json:"quantity"`
+	Price    float64 `json:"price"`
+	// Time defaults to now.
+	Time time.Time `json:"time"`
+}
+
+func (t tradeRequest) order() (*models.Order, error) {
+	symbol := strings.ToUpper(strings.TrimSpace(t.Symbol))
+	if symbol == "" {
+		return nil, errors.New("symbol is required")
+	}
+	order := &models.Order{Pair: symbol, Type: models.OrderTypeMarket, Amount: t.Quantity, Price: t.Price, Status: models.OrderStatusClosed, Timestamp: t.Time}
+	switch strings.ToLower(strings.TrimSpace(t.Side)) {
+	case "buy":
+		order.Side = models.OrderSideBuy
+	case "sell":
+		order.Side = models.OrderSideSell
+	default:
+		return nil, errors.New("side must be buy or sell")
+	}
+	if t.Quantity <= 0 {
+		return nil, errors.New("quantity must be a positive number")
+	}
+	if t.Price <= 0 {
+		return nil, errors.New("price must be a positive number")
+	}
+	return order, nil
+}
+
+// decodeBody decodes a JSON request body into v, writing a 400 response and
+// returning false if it is invalid.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAlertSize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// handleSignal injects a one-off signal, which goes through the same risk
+// checks as the strategies' signals, for any symbol.
+func (s *Server) handleSignal(w http.ResponseWriter, r *http.Request) {
+	var req signalRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	signal, err := req.signal(nil)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(logrus.Fields{"pair": signal.Pair, "type": signal.Type, "amount": signal.Amount, "notional": signal.Notional}).Warn("Manual signal received via API")
+	if err := s.control.InjectSignal(signal); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "submitted"})
+}
+
+// handleClose sells the whole position in a symbol, bypassing the risk checks.
+func (s *Server) handleClose(w http.ResponseWriter, r *http.Request) {
+	var req closeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	symbol := strings.ToUpper(strings.TrimSpace(req.Symbol))
+	if symbol == "" {
+		writeError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	log.WithField("pair", symbol).Warn("Position close requested via API")
+	if err := s.control.ClosePosition(symbol); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "closed"})
+}
+
+// handleRecordTrade stores a trade made outside the bot.
+func (s *Server) handleRecordTrade(w http.ResponseWriter, r *http.Request) {
+	var req tradeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	order, err := req.order()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(logrus.Fields{"pair": order.Pair, "side": order.Side, "amount": order.Amount, "price": order.Price}).Warn("Manual trade recorded via API")
+	if err := s.control.RecordTrade(order); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "recorded"})
+}
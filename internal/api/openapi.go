@@ -0,0 +1,18 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec is the OpenAPI 3 description of the API, served at /openapi.yaml. The
+// client in package apiclient is generated from it.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// handleSpec serves Spec. It holds nothing secret, so it needs no token.
+func (s *Server) handleSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(Spec)
+}
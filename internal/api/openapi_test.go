@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"tradingbot/internal/openapi"
+)
+
+// exampleBodies are valid request bodies of the operations that take one.
+var exampleBodies = map[string]string{
+	"/control/kill":        `{"reason": "test"}`,
+	"/control/signal":      `{"symbol": "005930", "action": "buy", "quantity": 1}`,
+	"/control/close":       `{"symbol": "005930"}`,
+	"/trades":              `{"symbol": "005930", "side": "buy", "quantity": 1, "price": 70000}`,
+	"/webhook/tradingview": `{"passphrase": "tv-passphrase-1234", "symbol": "005930", "action": "buy", "quantity": 1}`,
+}
+
+func loadSpec(t *testing.T) *openapi.Document {
+	doc, err := openapi.Load(Spec)
+	if err != nil {
+		t.Fatalf("Load(openapi.yaml): %v", err)
+	}
+	return doc
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	s, _, _ := newTestServer()
+	doc := loadSpec(t)
+
+	served := map[string]Route{}
+	for _, r := range s.Routes() {
+		served[r.Method+" "+r.Path] = r
+	}
+	for _, op := range doc.Operations() {
+		key := op.Method + " " + op.Path
+		r, ok := served[key]
+		if !ok {
+			t.Errorf("%s is in the specification but not served", key)
+			continue
+		}
+		delete(served, key)
+		if op.Role != r.Role && r.Role != "" {
+			t.Errorf("%s: x-role = %q, but the server requires %q", key, op.Role, r.Role)
+		}
+	}
+	for key := range served {
+		t.Errorf("%s is served but not in the specification", key)
+	}
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	s, _, _ := newTestServer()
+	doc := loadSpec(t)
+
+	for _, op := range doc.Operations() {
+		if op.Path == "/stream" {
+			continue
+		}
+		req := httptest.NewRequest(op.Method, op.Path, strings.NewReader(exampleBodies[op.Path]))
+		req.Header.Set("Authorization", "Bearer secret-token-1234")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+
+		code := strconv.Itoa(rec.Code)
+		if _, ok := op.Responses[code]; !ok {
+			t.Errorf("%s %s returned %d, which the specification does not declare: %s", op.Method, op.Path, rec.Code, rec.Body)
+			continue
+		}
+		schema := doc.JSONResponse(op, code)
+		if schema == nil {
+			continue
+		}
+		if err := doc.ValidateJSON(schema, rec.Body.Bytes()); err != nil {
+			t.Errorf("%s %s: %d response does not match the specification: %v", op.Method, op.Path, rec.Code, err)
+		}
+	}
+
+	// Error responses shared by every authenticated operation.
+	op := doc.Paths["/control/live/approve"]["post"]
+	for _, token := range []string{"", "read-key-12345678"} {
+		rec := do(t, s, http.MethodPost, "/control/live/approve", token)
+		schema := doc.JSONResponse(op, strconv.Itoa(rec.Code))
+		if schema == nil {
+			t.Fatalf("status %d is not declared", rec.Code)
+		}
+		if err := doc.ValidateJSON(schema, rec.Body.Bytes()); err != nil {
+			t.Errorf("%d response: %v", rec.Code, err)
+		}
+	}
+}
+
+func TestServerServesSpec(t *testing.T) {
+	s, _, _ := newTestServer()
+	rec := do(t, s, http.MethodGet, "/openapi.yaml", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != string(Spec) {
+		t.Errorf("GET /openapi.yaml: status = %d, want 200 with the specification", rec.Code)
+	}
+}
@@ -0,0 +1,646 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/experiment"
+	"tradingbot/internal/intraday"
+	"tradingbot/internal/killswitch"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/metrics"
+	"tradingbot/internal/models"
+	"tradingbot/internal/report"
+	"tradingbot/internal/shadow"
+	"tradingbot/internal/universe"
+)
+
+var log = logging.New()
+
+const recentSignalsSize = 100
+
+// Account is the read-only view of the brokerage account served by the API.
+type Account interface {
+	GetBalance() (string, error)
+	GetPositions() ([]models.Position, error)
+	GetOpenOrders() ([]models.OpenOrder, error)
+}
+
+// DividendSource is implemented by accounts that report the dividends
+// credited to them, which the PnL report then attributes.
+type DividendSource interface {
+	GetDividends(from, to time.Time) ([]models.Dividend, error)
+}
+
+// OrderHistory provides the stored orders behind the PnL report.
+type OrderHistory interface {
+	ListOrdersBefore(t time.Time) ([]models.Order, error)
+}
+
+// IntradaySource provides the session VWAP and volume profiles served at
+// /intraday.
+type IntradaySource interface {
+	Profiles() []intraday.Profile
+}
+
+// ShadowSource provides the side-by-side paper performance served at
+// /shadow.
+type ShadowSource interface {
+	Report() shadow.Report
+}
+
+// ExperimentSource provides the A/B test report served at /experiment.
+type ExperimentSource interface {
+	Report() experiment.Report
+}
+
+// Controller carries out control requests in the trading loop.
+type Controller interface {
+	Pause()
+	Resume()
+	Paused() bool
+	TriggerCycle() error
+	Flatten() error
+	SubmitSignal(signal *models.Signal) error
+	// InjectSignal, ClosePosition and RecordTrade carry out manual
+	// interventions, which are tagged as such in the audit trail.
+	InjectSignal(signal *models.Signal) error
+	ClosePosition(symbol string) error
+	RecordTrade(order *models.Order) error
+	// ApproveLive lets a live run that is waiting for approval send orders.
+	ApproveLive() error
+	// ApproveTuning applies the strategy parameters proposed by the tuner.
+	ApproveTuning() error
+	// Kill triggers the kill switch: new orders are halted until restart and
+	// open orders cancelled. Unlike Pause, Resume does not undo it.
+	Kill(source, reason string) (events.KillEvent, error)
+}
+
+// Server is the HTTP status and control API.
+type Server struct {
+	cfg     *config.Config
+	account Account
+	control Controller
+
+	mu         sync.Mutex
+	signals    []events.SignalEvent
+	lastCycle  time.Time
+	lastError  *events.ErrorEvent
+	circuit    string
+	tuning     *events.TuningEvent
+	history    OrderHistory
+	intraday   IntradaySource
+	shadow     ShadowSource
+	experiment ExperimentSource
+	candles    CandleSource
+	sectors    map[string]string
+	clients    map[chan []byte]struct{}
+	latency    map[string]*PhaseLatency
+	routes     []Route
+	metrics    *metrics.Registry
+	creds      config.APIConfig
+
+	srv      *http.Server
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewServer creates the API server and subscribes it to bus to track recent
+// signals, cycle times and latencies and errors, to keep the Prometheus
+// metrics and to stream events to WebSocket clients.
+func NewServer(cfg *config.Config, bus *events.Bus, account Account, control Controller) *Server {
+	s := &Server{
+		cfg:     cfg,
+		account: account,
+		control: control,
+		circuit: "closed",
+		clients: map[chan []byte]struct{}{},
+		latency: map[string]*PhaseLatency{},
+		metrics: metrics.NewRegistry(),
+		done:    make(chan struct{}),
+	}
+	s.SetCredentials(cfg.API)
+	s.metrics.Subscribe(bus)
+	bus.Subscribe(s.record, events.KindMarketData, events.KindSignal, events.KindError, events.KindCircuit, events.KindCycle, events.KindTuning)
+	go s.runStream(bus.Channel(streamBuffer, streamKinds...))
+
+	// Every authenticated caller may read; control routes check the role.
+	// TradingView cannot send headers, so its alerts bypass bearer
+	// authentication and carry a passphrase in the body instead, and /stream
+	// authenticates itself to accept a token in the query.
+	mux := http.NewServeMux()
+	s.handle(mux, http.MethodGet, "/status", config.RoleRead, s.handleStatus)
+	s.handle(mux, http.MethodGet, "/positions", config.RoleRead, s.handlePositions)
+	s.handle(mux, http.MethodGet, "/equity", config.RoleRead, s.handleEquity)
+	s.handle(mux, http.MethodGet, "/orders/open", config.RoleRead, s.handleOpenOrders)
+	s.handle(mux, http.MethodGet, "/signals", config.RoleRead, s.handleSignals)
+	s.handle(mux, http.MethodGet, "/risk", config.RoleRead, s.handleRisk)
+	s.handle(mux, http.MethodGet, "/reports/pnl", config.RoleRead, s.handlePnL)
+	s.handle(mux, http.MethodGet, "/metrics/latency", config.RoleRead, s.handleLatency)
+	s.handle(mux, http.MethodGet, "/metrics", config.RoleRead, s.handleMetrics)
+	s.handle(mux, http.MethodGet, "/tuning", config.RoleRead, s.handleTuning)
+	s.handle(mux, http.MethodGet, "/intraday", config.RoleRead, s.handleIntraday)
+	s.handle(mux, http.MethodGet, "/shadow", config.RoleRead, s.handleShadow)
+	s.handle(mux, http.MethodGet, "/experiment", config.RoleRead, s.handleExperiment)
+	s.handle(mux, http.MethodGet, "/candles", config.RoleRead, s.handleCandles)
+	s.handle(mux, http.MethodPost, "/control/pause", config.RoleOperator, s.handlePause)
+	s.handle(mux, http.MethodPost, "/control/resume", config.RoleOperator, s.handleResume)
+	s.handle(mux, http.MethodPost, "/control/flatten", config.RoleOperator, s.handleFlatten)
+	s.handle(mux, http.MethodPost, "/control/kill", config.RoleOperator, s.handleKill)
+	s.handle(mux, http.MethodPost, "/control/cycle", config.RoleOperator, s.handleCycle)
+	s.handle(mux, http.MethodPost, "/control/live/approve", config.RoleAdmin, s.handleApproveLive)
+	s.handle(mux, http.MethodPost, "/control/tuning/approve", config.RoleAdmin, s.handleApproveTuning)
+	s.handle(mux, http.MethodPost, "/control/signal", config.RoleOperator, s.handleSignal)
+	s.handle(mux, http.MethodPost, "/control/close", config.RoleOperator, s.handleClose)
+	s.handle(mux, http.MethodPost, "/trades", config.RoleOperator, s.handleRecordTrade)
+
+	root := http.NewServeMux()
+	root.Handle("/", s.authenticate(mux))
+	s.handle(root, http.MethodGet, "/stream", "", s.handleStream)
+	s.handle(root, http.MethodGet, "/openapi.yaml", "", s.handleSpec)
+	if cfg.API.TradingView.Enabled {
+		s.handle(root, http.MethodPost, "/webhook/tradingview", "", s.handleTradingView)
+	}
+
+	s.srv = &http.Server{Addr: cfg.API.Listen, Handler: root}
+	return s
+}
+
+// SetCredentials sets the token, keys and JWT secret callers authenticate
+// with, e.g. after they were rotated. The server keeps a copy: rotations
+// rewrite those of the config in place.
+func (s *Server) SetCredentials(api config.APIConfig) {
+	api.Keys = append([]config.APIKey(nil), api.Keys...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds = api
+}
+
+// SetOrderHistory sets where the PnL report reads orders from, e.g. after a
+// database reconnect. Without it the report is unavailable.
+func (s *Server) SetOrderHistory(h OrderHistory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = h
+}
+
+// SetIntraday sets where /intraday reads the session profiles from. Without
+// it the endpoint is unavailable.
+func (s *Server) SetIntraday(source IntradaySource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.intraday = source
+}
+
+// SetShadow sets where /shadow reads the shadow trading report from. Without
+// it the endpoint is unavailable.
+func (s *Server) SetShadow(source ShadowSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shadow = source
+}
+
+// SetExperiment sets where /experiment reads the A/B test report from.
+// Without it the endpoint is unavailable.
+func (s *Server) SetExperiment(source ExperimentSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.experiment = source
+}
+
+// Handler returns the HTTP handler serving all routes, mainly for tests.
+func (s *Server) Handler() http.Handler {
+	return s.srv.Handler
+}
+
+// Start serves the API in the background until Shutdown is called.
+func (s *Server) Start() {
+	go func() {
+		log.WithField("listen", s.srv.Addr).Info("Starting API server")
+		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("API server stopped")
+		}
+	}()
+}
+
+// Shutdown stops the server, waiting for in-flight requests until ctx is done,
+// and disconnects stream clients.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.done) })
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) record(ev events.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch e := ev.(type) {
+	case events.MarketDataEvent:
+		s.lastCycle = e.Time
+	case events.SignalEvent:
+		s.signals = append(s.signals, e)
+		if len(s.signals) > recentSignalsSize {
+			s.signals = s.signals[len(s.signals)-recentSignalsSize:]
+		}
+	case events.ErrorEvent:
+		s.lastError = &e
+	case events.CircuitEvent:
+		s.circuit = e.To
+	case events.CycleEvent:
+		s.recordCycle(e)
+	case events.TuningEvent:
+		s.tuning = &e
+	}
+}
+
+// Route is an endpoint of the API. Role is the least role allowed to call
+// it, or empty for the endpoints that authenticate themselves.
+type Route struct {
+	Method string
+	Path   string
+	Role   string
+}
+
+// Routes returns the endpoints served, in the order they were registered.
+func (s *Server) Routes() []Route {
+	return append([]Route(nil), s.routes...)
+}
+
+// handle registers h on mux for method requests to path by callers with at
+// least role.
+func (s *Server) handle(mux *http.ServeMux, method, path, role string, h http.HandlerFunc) {
+	s.routes = append(s.routes, Route{Method: method, Path: path, Role: role})
+	if role != "" {
+		h = require(role, h)
+	}
+	mux.HandleFunc(path, methodOnly(method, h))
+}
+
+func methodOnly(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := map[string]interface{}{
+		"mode":       s.cfg.Exchange.Mode,
+		"profile":    s.cfg.Profile,
+		"symbols":    s.cfg.TradingSymbols(),
+		"strategy":   s.cfg.Strategy,
+		"paused":     s.control.Paused(),
+		"circuit":    s.circuit,
+		"last_cycle": s.lastCycle,
+	}
+	if s.lastError != nil {
+		status["last_error"] = map[string]interface{}{
+			"source": s.lastError.Source,
+			"symbol": s.lastError.Symbol,
+			"error":  s.lastError.Err.Error(),
+			"time":   s.lastError.Time,
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handlePositions(w http.ResponseWriter, r *http.Request) {
+	positions, err := s.account.GetPositions()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if positions == nil {
+		positions = []models.Position{}
+	}
+	writeJSON(w, http.StatusOK, positions)
+}
+
+func (s *Server) handleEquity(w http.ResponseWriter, r *http.Request) {
+	equity, err := s.equity()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, equity)
+}
+
+func (s *Server) handleOpenOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := s.account.GetOpenOrders()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if orders == nil {
+		orders = []models.OpenOrder{}
+	}
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (s *Server) handleSignals(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	out := make([]map[string]interface{}, 0, len(s.signals))
+	for i := len(s.signals) - 1; i >= 0; i-- {
+		e := s.signals[i]
+		out = append(out, map[string]interface{}{
+			"symbol": e.Symbol,
+			"type":   e.Signal.Type,
+			"amount": e.Signal.Amount,
+			"time":   e.Time,
+		})
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleRisk serves the risk limits and, when the sectors of the symbols are
+// known, the exposure to each sector. The exposure is left out when the
+// account cannot be read, so that the rest is still served.
+func (s *Server) handleRisk(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	circuit := s.circuit
+	sectors := s.sectors
+	s.mu.Unlock()
+
+	risk := map[string]interface{}{
+		"paused":           s.control.Paused(),
+		"circuit":          circuit,
+		"max_order_amount": s.cfg.Risk.MaxOrderAmount,
+		"market_hours":     s.cfg.Market.Enabled,
+	}
+	if sectors != nil {
+		if equity, positions, err := s.valuation(); err != nil {
+			log.WithError(err).Warn("Failed to get positions for the sector exposure")
+		} else {
+			risk["sectors"] = s.sectorExposures(equity, positions)
+		}
+	}
+	writeJSON(w, http.StatusOK, risk)
+}
+
+// SectorExposure is the value of the positions in a sector and its share of
+// the equity, with the sector's limit if it has one.
+type SectorExposure struct {
+	Sector   string  `json:"sector"`
+	Value    float64 `json:"value"`
+	Exposure float64 `json:"exposure"`
+	Limit    float64 `json:"limit,omitempty"`
+}
+
+// SetSectors sets the sector of each symbol, to report the exposure to each
+// sector in /risk and /metrics. Without it the exposure is not reported.
+func (s *Server) SetSectors(sectors map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sectors = sectors
+}
+
+// sectorExposures returns the exposure to every sector of the symbols, and
+// of every sector with a limit, sorted by sector.
+func (s *Server) sectorExposures(equity Equity, positions []models.Position) []SectorExposure {
+	s.mu.Lock()
+	sectors := s.sectors
+	s.mu.Unlock()
+	values := universe.SectorValues(positions, sectors)
+	// Sectors not held are reported at zero, which also resets their gauge.
+	for _, sector := range sectors {
+		if _, ok := values[sector]; !ok {
+			values[sector] = 0
+		}
+	}
+	for sector := range s.cfg.Exposure.SectorLimits {
+		if _, ok := values[sector]; !ok {
+			values[sector] = 0
+		}
+	}
+	out := make([]SectorExposure, 0, len(values))
+	for sector, value := range values {
+		e := SectorExposure{Sector: sector, Value: value, Limit: s.cfg.Exposure.SectorLimit(sector)}
+		if equity.Equity > 0 {
+			e.Exposure = value / equity.Equity
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Sector < out[j].Sector })
+	return out
+}
+
+// handlePnL attributes PnL to strategies and symbols over the KST dates given by
+// the from and to query parameters, both optional and inclusive.
+func (s *Server) handlePnL(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	history := s.history
+	s.mu.Unlock()
+	if history == nil {
+		writeError(w, http.StatusServiceUnavailable, "order history not available")
+		return
+	}
+
+	from, to, err := report.ParsePeriod(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	orders, err := history.ListOrdersBefore(to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	positions, err := s.account.GetPositions()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	var dividends []models.Dividend
+	if source, ok := s.account.(DividendSource); ok {
+		if dividends, err = source.GetDividends(report.DividendsFrom(from, orders), to); err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+	}
+
+	costs := report.Costs{CommissionRate: s.cfg.Fees.CommissionRate, SellTaxRate: s.cfg.Fees.SellTaxRate}
+	writeJSON(w, http.StatusOK, report.Attribute(orders, dividends, from, to, report.Prices(positions), costs, s.cfg.Strategy))
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.control.Pause()
+	log.Warn("Trading paused via API")
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": true})
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.control.Resume()
+	if s.control.Paused() {
+		writeError(w, http.StatusConflict, "trading was stopped by the kill switch; restart to resume")
+		return
+	}
+	log.Warn("Trading resumed via API")
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": false})
+}
+
+// handleKill triggers the kill switch. The body, optional, may give a reason.
+func (s *Server) handleKill(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 && !decodeBody(w, r, &req) {
+		return
+	}
+	log.WithField("reason", req.Reason).Warn("Kill switch triggered via API")
+	ev, err := s.control.Kill(killswitch.SourceAPI, req.Reason)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	resp := map[string]interface{}{
+		"status":    "killed",
+		"source":    ev.Source,
+		"cancelled": ev.Cancelled,
+		"failed":    ev.Failed,
+		"time":      ev.Time,
+	}
+	if ev.Err != nil {
+		resp["error"] = ev.Err.Error()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleFlatten(w http.ResponseWriter, r *http.Request) {
+	log.Warn("Flatten requested via API")
+	if err := s.control.Flatten(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "flattened"})
+}
+
+func (s *Server) handleCycle(w http.ResponseWriter, r *http.Request) {
+	if err := s.control.TriggerCycle(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cycle completed"})
+}
+
+func (s *Server) handleApproveLive(w http.ResponseWriter, r *http.Request) {
+	c, _ := callerOf(r)
+	log.WithField("remote", r.RemoteAddr).WithField("caller", c.Name).Warn("Live trading approval requested via API")
+	if err := s.control.ApproveLive(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "approved"})
+}
+
+// handleTuning shows the latest proposal of the parameter tuner and whether
+// it has been applied.
+func (s *Server) handleTuning(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	e := s.tuning
+	s.mu.Unlock()
+
+	if e == nil {
+		writeError(w, http.StatusNotFound, "no parameters proposed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"symbol":          e.Symbol,
+		"days":            e.Days,
+		"current":         map[string]int{"short_period": e.Current.ShortPeriod, "long_period": e.Current.LongPeriod},
+		"proposed":        map[string]int{"short_period": e.Proposed.ShortPeriod, "long_period": e.Proposed.LongPeriod},
+		"current_profit":  e.CurrentProfit,
+		"proposed_profit": e.ProposedProfit,
+		"applied":         e.Applied,
+		"time":            e.Time,
+	})
+}
+
+// handleIntraday serves the session VWAP and volume profile of the traded
+// symbols, or of the one given by the symbol query parameter.
+func (s *Server) handleIntraday(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	source := s.intraday
+	s.mu.Unlock()
+	if source == nil {
+		writeError(w, http.StatusServiceUnavailable, "intraday tracking not enabled")
+		return
+	}
+
+	profiles := source.Profiles()
+	if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+		for _, p := range profiles {
+			if p.Symbol == symbol {
+				writeJSON(w, http.StatusOK, p)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "no session data for "+symbol)
+		return
+	}
+	if profiles == nil {
+		profiles = []intraday.Profile{}
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+// handleShadow compares the paper performance of the traded strategy and the
+// shadow candidate.
+func (s *Server) handleShadow(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	source := s.shadow
+	s.mu.Unlock()
+	if source == nil {
+		writeError(w, http.StatusServiceUnavailable, "shadow trading not enabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, source.Report())
+}
+
+// handleExperiment compares the variants of the A/B test.
+func (s *Server) handleExperiment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	source := s.experiment
+	s.mu.Unlock()
+	if source == nil {
+		writeError(w, http.StatusServiceUnavailable, "A/B test not enabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, source.Report())
+}
+
+func (s *Server) handleApproveTuning(w http.ResponseWriter, r *http.Request) {
+	c, _ := callerOf(r)
+	log.WithField("remote", r.RemoteAddr).WithField("caller", c.Name).Info("Tuned parameters approved via API")
+	if err := s.control.ApproveTuning(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "applied"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Warn("Failed to write API response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,550 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+type fakeAccount struct{}
+
+func (fakeAccount) GetBalance() (string, error) { return "1000000", nil }
+func (fakeAccount) GetPositions() ([]models.Position, error) {
+	return []models.Position{{StockCode: "005930", Quantity: 10, CurrentPrice: 70000}}, nil
+}
+func (fakeAccount) GetOpenOrders() ([]models.OpenOrder, error) { return nil, nil }
+
+type fakeController struct {
+	paused  bool
+	cycles  int
+	signals []*models.Signal
+	manual  []*models.Signal
+	closed  []string
+	trades  []*models.Order
+	live    bool
+	tuned   int
+	killed  bool
+}
+
+func (c *fakeController) Pause()              { c.paused = true }
+func (c *fakeController) Resume()             { c.paused = c.killed }
+func (c *fakeController) Paused() bool        { return c.paused }
+func (c *fakeController) TriggerCycle() error { c.cycles++; return nil }
+func (c *fakeController) Flatten() error      { return nil }
+func (c *fakeController) SubmitSignal(signal *models.Signal) error {
+	c.signals = append(c.signals, signal)
+	return nil
+}
+func (c *fakeController) InjectSignal(signal *models.Signal) error {
+	c.manual = append(c.manual, signal)
+	return nil
+}
+func (c *fakeController) ClosePosition(symbol string) error {
+	c.closed = append(c.closed, symbol)
+	return nil
+}
+func (c *fakeController) RecordTrade(order *models.Order) error {
+	c.trades = append(c.trades, order)
+	return nil
+}
+func (c *fakeController) ApproveLive() error {
+	if c.live {
+		return errors.New("orders are already enabled")
+	}
+	c.live = true
+	return nil
+}
+
+func (c *fakeController) ApproveTuning() error {
+	c.tuned++
+	return nil
+}
+
+func (c *fakeController) Kill(source, reason string) (events.KillEvent, error) {
+	c.killed, c.paused = true, true
+	return events.KillEvent{Source: source, Reason: reason, Cancelled: 2}, nil
+}
+
+func newTestServer() (*Server, *fakeController, *events.Bus) {
+	cfg := &config.Config{
+		TradingPair: "005930",
+		API: config.APIConfig{
+			Token: "secret-token-1234",
+			Keys: []config.APIKey{
+				{Name: "dashboard", Key: "read-key-12345678", Role: config.RoleRead},
+				{Name: "ops", Key: "operator-key-1234", Role: config.RoleOperator},
+			},
+			JWT:         config.JWTConfig{Secret: "jwt-secret-0123456789abcdef0123456789", Issuer: "ops"},
+			TradingView: config.TradingViewConfig{Enabled: true, Passphrase: "tv-passphrase-1234"},
+		},
+	}
+	bus := events.NewBus()
+	ctl := &fakeController{}
+	return NewServer(cfg, bus, fakeAccount{}, ctl), ctl, bus
+}
+
+func do(t *testing.T, s *Server, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	s, _, _ := newTestServer()
+
+	if rec := do(t, s, "GET", "/status", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", rec.Code)
+	}
+	if rec := do(t, s, "GET", "/status", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", rec.Code)
+	}
+	if rec := do(t, s, "GET", "/status", "secret-token-1234"); rec.Code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestServerRotatedCredentials(t *testing.T) {
+	s, _, _ := newTestServer()
+
+	// A rotation rewrites the config in place; the server goes on with its
+	// copy until it is given the new credentials.
+	s.cfg.API.Token = "rotated-token-5678"
+	s.cfg.API.Keys[0].Key = "rotated-key-87654321"
+	if rec := do(t, s, "GET", "/status", "read-key-12345678"); rec.Code != http.StatusOK {
+		t.Errorf("key before SetCredentials: status = %d, want 200", rec.Code)
+	}
+
+	s.SetCredentials(s.cfg.API)
+	for token, want := range map[string]int{
+		"secret-token-1234":    http.StatusUnauthorized,
+		"read-key-12345678":    http.StatusUnauthorized,
+		"rotated-token-5678":   http.StatusOK,
+		"rotated-key-87654321": http.StatusOK,
+	} {
+		if rec := do(t, s, "GET", "/status", token); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", token, rec.Code, want)
+		}
+	}
+	s.cfg.API.Keys[0].Key = "next-key-000000000"
+	if rec := do(t, s, "GET", "/status", "rotated-key-87654321"); rec.Code != http.StatusOK {
+		t.Errorf("keys shared with the config: status = %d, want 200", rec.Code)
+	}
+}
+
+func signJWT(secret, alg string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestServerRoles(t *testing.T) {
+	s, ctl, _ := newTestServer()
+	const secret = "jwt-secret-0123456789abcdef0123456789"
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"read key reads", "GET", "/status", "read-key-12345678", http.StatusOK},
+		{"read key cannot pause", "POST", "/control/pause", "read-key-12345678", http.StatusForbidden},
+		{"operator pauses", "POST", "/control/pause", "operator-key-1234", http.StatusOK},
+		{"operator cannot approve live", "POST", "/control/live/approve", "operator-key-1234", http.StatusForbidden},
+		{"jwt operator pauses", "POST", "/control/pause",
+			signJWT(secret, "HS256", map[string]interface{}{"sub": "kim", "role": "operator", "iss": "ops", "exp": exp}), http.StatusOK},
+		{"jwt admin approves live", "POST", "/control/live/approve",
+			signJWT(secret, "HS256", map[string]interface{}{"sub": "kim", "role": "admin", "iss": "ops", "exp": exp}), http.StatusOK},
+		{"jwt read cannot pause", "POST", "/control/pause",
+			signJWT(secret, "HS256", map[string]interface{}{"role": "read", "iss": "ops"}), http.StatusForbidden},
+		{"expired jwt", "GET", "/status",
+			signJWT(secret, "HS256", map[string]interface{}{"role": "read", "iss": "ops", "exp": 1}), http.StatusUnauthorized},
+		{"wrong issuer", "GET", "/status",
+			signJWT(secret, "HS256", map[string]interface{}{"role": "read", "iss": "other"}), http.StatusUnauthorized},
+		{"wrong secret", "GET", "/status",
+			signJWT("another-secret-0123456789abcdef012345", "HS256", map[string]interface{}{"role": "read", "iss": "ops"}), http.StatusUnauthorized},
+		{"unknown role", "GET", "/status",
+			signJWT(secret, "HS256", map[string]interface{}{"role": "root", "iss": "ops"}), http.StatusUnauthorized},
+		{"alg none", "GET", "/status",
+			signJWT(secret, "none", map[string]interface{}{"role": "admin", "iss": "ops"}), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if rec := do(t, s, tt.method, tt.path, tt.token); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+	if !ctl.live {
+		t.Error("admin JWT did not approve live trading")
+	}
+}
+
+func TestServerControlAndStatus(t *testing.T) {
+	s, ctl, bus := newTestServer()
+	const token = "secret-token-1234"
+
+	if rec := do(t, s, "GET", "/control/pause", token); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET pause: status = %d, want 405", rec.Code)
+	}
+	do(t, s, "POST", "/control/pause", token)
+	if !ctl.paused {
+		t.Errorf("controller not paused")
+	}
+	do(t, s, "POST", "/control/cycle", token)
+	if ctl.cycles != 1 {
+		t.Errorf("cycles = %d, want 1", ctl.cycles)
+	}
+	if rec := do(t, s, "POST", "/control/live/approve", token); rec.Code != http.StatusOK || !ctl.live {
+		t.Errorf("approve live: status = %d, want 200", rec.Code)
+	}
+	if rec := do(t, s, "POST", "/control/live/approve", token); rec.Code != http.StatusConflict {
+		t.Errorf("approve live twice: status = %d, want 409", rec.Code)
+	}
+	if rec := do(t, s, "POST", "/control/kill", token); rec.Code != http.StatusOK || !ctl.killed {
+		t.Errorf("kill: status = %d, want 200", rec.Code)
+	}
+	if rec := do(t, s, "POST", "/control/resume", token); rec.Code != http.StatusConflict || !ctl.paused {
+		t.Errorf("resume after kill: status = %d, want 409", rec.Code)
+	}
+
+	if rec := do(t, s, "GET", "/tuning", token); rec.Code != http.StatusNotFound {
+		t.Errorf("tuning before a proposal: status = %d, want 404", rec.Code)
+	}
+	bus.Publish(events.TuningEvent{Symbol: "005930", Days: 120, Current: models.MovingAverageConfig{ShortPeriod: 5, LongPeriod: 20},
+		Proposed: models.MovingAverageConfig{ShortPeriod: 3, LongPeriod: 12}, CurrentProfit: 1000, ProposedProfit: 5000})
+	var tuning struct {
+		Proposed map[string]int `json:"proposed"`
+		Applied  bool           `json:"applied"`
+	}
+	json.Unmarshal(do(t, s, "GET", "/tuning", token).Body.Bytes(), &tuning)
+	if tuning.Proposed["short_period"] != 3 || tuning.Proposed["long_period"] != 12 || tuning.Applied {
+		t.Errorf("tuning = %+v, want 3/12 not yet applied", tuning)
+	}
+	if rec := do(t, s, "POST", "/control/tuning/approve", token); rec.Code != http.StatusOK || ctl.tuned != 1 {
+		t.Errorf("approve tuning: status = %d, want 200", rec.Code)
+	}
+
+	bus.Publish(events.SignalEvent{Symbol: "005930", Signal: &models.Signal{Type: models.BuySignal, Amount: 1}})
+	var signals []map[string]interface{}
+	json.Unmarshal(do(t, s, "GET", "/signals", token).Body.Bytes(), &signals)
+	if len(signals) != 1 || signals[0]["symbol"] != "005930" {
+		t.Errorf("signals = %v, want one for 005930", signals)
+	}
+
+	bus.Publish(events.CycleEvent{Symbol: "005930", Duration: 300 * time.Millisecond, Phases: map[string]time.Duration{events.PhaseData: 200 * time.Millisecond}})
+	bus.Publish(events.CycleEvent{Symbol: "005930", Duration: 100 * time.Millisecond, Phases: map[string]time.Duration{events.PhaseData: 50 * time.Millisecond}})
+	var latency map[string]PhaseLatency
+	json.Unmarshal(do(t, s, "GET", "/metrics/latency", token).Body.Bytes(), &latency)
+	if got, want := latency["cycle"], (PhaseLatency{Count: 2, LastMs: 100, MeanMs: 200, MaxMs: 300}); got != want {
+		t.Errorf("cycle latency = %+v, want %+v", got, want)
+	}
+	if got := latency["data"]; got.Count != 2 || got.MaxMs != 200 {
+		t.Errorf("data latency = %+v", got)
+	}
+
+	var equity map[string]float64
+	json.Unmarshal(do(t, s, "GET", "/equity", token).Body.Bytes(), &equity)
+	if equity["equity"] != 1700000 {
+		t.Errorf("equity = %v, want 1700000", equity)
+	}
+}
+
+func TestServerTradingViewAlert(t *testing.T) {
+	s, ctl, _ := newTestServer()
+
+	post := func(body string) int {
+		req := httptest.NewRequest("POST", "/webhook/tradingview", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"passphrase": "wrong", "symbol": "005930", "action": "buy", "quantity": 1}`, http.StatusUnauthorized},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "000660", "action": "buy", "quantity": 1}`, http.StatusBadRequest},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "005930", "action": "hold", "quantity": 1}`, http.StatusBadRequest},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "005930", "action": "buy", "quantity": 0}`, http.StatusBadRequest},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "005930", "action": "buy", "quantity": 1, "notional": 100000}`, http.StatusBadRequest},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "005930", "action": "buy", "notional": -5}`, http.StatusBadRequest},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "KRX:005930", "action": "SELL", "quantity": "3"}`, http.StatusOK},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "005930", "action": "buy", "quantity": 1, "price": 0}`, http.StatusBadRequest},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "005930", "action": "buy", "notional": 500000}`, http.StatusOK},
+		{`{"passphrase": "tv-passphrase-1234", "symbol": "005930", "action": "buy", "quantity": 2, "price": 70050}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := post(tt.body); got != tt.want {
+			t.Errorf("POST %s: status = %d, want %d", tt.body, got, tt.want)
+		}
+	}
+
+	if len(ctl.signals) != 3 {
+		t.Fatalf("submitted %d signals, want 3", len(ctl.signals))
+	}
+	if got := *ctl.signals[0]; got != (models.Signal{Type: models.SellSignal, Pair: "005930", Amount: 3}) {
+		t.Errorf("signal = %+v", got)
+	}
+	if got := *ctl.signals[1]; got != (models.Signal{Type: models.BuySignal, Pair: "005930", Notional: 500000}) {
+		t.Errorf("notional signal = %+v", got)
+	}
+	if got := *ctl.signals[2]; got != (models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 2, LimitPrice: 70050}) {
+		t.Errorf("limit signal = %+v", got)
+	}
+}
+
+func TestServerManualInterventions(t *testing.T) {
+	s, ctl, _ := newTestServer()
+
+	post := func(path, body string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret-token-1234")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		// Manual signals are not limited to the traded symbols.
+		{"/control/signal", `{"symbol": "000660", "action": "buy", "quantity": 2}`, http.StatusOK},
+		{"/control/signal", `{"symbol": "", "action": "buy", "quantity": 2}`, http.StatusBadRequest},
+		{"/control/close", `{"symbol": "005930"}`, http.StatusOK},
+		{"/control/close", `{}`, http.StatusBadRequest},
+		{"/trades", `{"symbol": "035720", "side": "sell", "quantity": 5, "price": 41000, "time": "2024-03-04T10:00:00+09:00"}`, http.StatusOK},
+		{"/trades", `{"symbol": "035720", "side": "sell", "quantity": 5}`, http.StatusBadRequest},
+		{"/trades", `not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if got := post(tt.path, tt.body); got != tt.want {
+			t.Errorf("POST %s %s: status = %d, want %d", tt.path, tt.body, got, tt.want)
+		}
+	}
+
+	if len(ctl.manual) != 1 || *ctl.manual[0] != (models.Signal{Type: models.BuySignal, Pair: "000660", Amount: 2}) || len(ctl.signals) != 0 {
+		t.Errorf("manual signals = %v, want one buy of 000660", ctl.manual)
+	}
+	if len(ctl.closed) != 1 || ctl.closed[0] != "005930" {
+		t.Errorf("closed = %v, want [005930]", ctl.closed)
+	}
+	if len(ctl.trades) != 1 {
+		t.Fatalf("recorded %d trades, want 1", len(ctl.trades))
+	}
+	if o := ctl.trades[0]; o.Pair != "035720" || o.Side != models.OrderSideSell || o.Amount != 5 || o.Price != 41000 || o.Timestamp.IsZero() {
+		t.Errorf("trade = %+v", o)
+	}
+}
+
+type fakeHistory []models.Order
+
+func (h fakeHistory) ListOrdersBefore(t time.Time) ([]models.Order, error) { return h, nil }
+
+func TestServerPnLReport(t *testing.T) {
+	s, _, _ := newTestServer()
+	const token = "secret-token-1234"
+
+	if rec := do(t, s, "GET", "/reports/pnl", token); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without history: status = %d, want 503", rec.Code)
+	}
+
+	s.SetOrderHistory(fakeHistory{
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Price: 60000, Timestamp: time.Now().Add(-time.Hour)},
+	})
+	if rec := do(t, s, "GET", "/reports/pnl?from=yesterday", token); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad date: status = %d, want 400", rec.Code)
+	}
+
+	var pnl struct {
+		Total struct {
+			UnrealizedPnL float64 `json:"unrealized_pnl"`
+			Turnover      float64 `json:"turnover"`
+		} `json:"total"`
+	}
+	rec := do(t, s, "GET", "/reports/pnl", token)
+	json.Unmarshal(rec.Body.Bytes(), &pnl)
+	if rec.Code != http.StatusOK || pnl.Total.UnrealizedPnL != 100000 || pnl.Total.Turnover != 600000 {
+		t.Errorf("status %d, pnl = %+v; want 100000 unrealized on 600000 turnover", rec.Code, pnl)
+	}
+}
+
+// dialStream opens a WebSocket to the /stream endpoint of srv.
+func dialStream(t *testing.T, srv *httptest.Server, query string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	fmt.Fprintf(conn, "GET /stream%s HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", query)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake response = %d %v", resp.StatusCode, resp.Header)
+	}
+	return conn, r
+}
+
+// readMessage reads one unfragmented, unmasked text frame.
+func readMessage(t *testing.T, conn net.Conn, r *bufio.Reader) StreamMessage {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	n := uint64(head[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	var msg StreamMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatalf("message %s: %v", payload, err)
+	}
+	return msg
+}
+
+func TestServerStreamsEvents(t *testing.T) {
+	s, _, bus := newTestServer()
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	defer s.Shutdown(context.Background())
+
+	if rec := do(t, s, "GET", "/stream", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("stream without token: status = %d, want 401", rec.Code)
+	}
+
+	conn, r := dialStream(t, srv, "?token=secret-token-1234")
+	defer conn.Close()
+	if msg := readMessage(t, conn, r); msg.Event != "equity" {
+		t.Fatalf("first message = %+v, want equity", msg)
+	}
+
+	bus.Publish(events.MarketDataEvent{Symbol: "005930", Data: &models.MarketData{StckPrpr: "70000"}, Time: time.Now()})
+	order := &models.Order{Pair: "005930", Side: models.OrderSideBuy, Amount: 1}
+	bus.Publish(events.OrderEvent{Order: order, Signal: &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1}, Time: time.Now()})
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		msg := readMessage(t, conn, r)
+		got = append(got, msg.Event)
+		if msg.Event == "quote" && msg.Data.(map[string]interface{})["price"] != 70000.0 {
+			t.Errorf("quote = %+v", msg.Data)
+		}
+	}
+	if strings.Join(got, ",") != "quote,order,equity" {
+		t.Errorf("events = %v, want quote, order, equity", got)
+	}
+}
+
+func TestServerCandles(t *testing.T) {
+	s, _, _ := newTestServer()
+	if rec := do(t, s, "GET", "/candles?symbol=005930", "read-key-12345678"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a cache: status = %d, want 503", rec.Code)
+	}
+
+	cache := candle.NewCache(100)
+	start := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		cache.Add(candle.Candle{Symbol: "005930", Start: start.AddDate(0, 0, i), Timeframe: 24 * time.Hour, Close: float64(69000 + 500*i)})
+	}
+	cache.Add(candle.Candle{Symbol: "005930", Start: start, Timeframe: 5 * time.Minute, Close: 1})
+	s.SetCandles(cache)
+
+	rec := do(t, s, "GET", "/candles?symbol=005930&limit=2", "read-key-12345678")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	doc := loadSpec(t)
+	if err := doc.ValidateJSON(doc.JSONResponse(doc.Paths["/candles"]["get"], "200"), rec.Body.Bytes()); err != nil {
+		t.Errorf("response does not match the specification: %v", err)
+	}
+	var candles []Candle
+	if err := json.NewDecoder(rec.Body).Decode(&candles); err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 || candles[0].Close != 69500 || candles[1].Close != 70000 {
+		t.Errorf("candles = %+v, want the last two daily candles", candles)
+	}
+
+	rec = do(t, s, "GET", "/candles?symbol=005930&timeframe=5m", "read-key-12345678")
+	if !strings.Contains(rec.Body.String(), `"close":1,`) {
+		t.Errorf("5m candles = %s", rec.Body)
+	}
+	for _, path := range []string{"/candles", "/candles?symbol=005930&timeframe=x", "/candles?symbol=005930&limit=0"} {
+		if rec := do(t, s, "GET", path, "read-key-12345678"); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400", path, rec.Code)
+		}
+	}
+}
+
+func TestServerSectorExposure(t *testing.T) {
+	s, _, _ := newTestServer()
+	var risk struct {
+		Sectors []SectorExposure `json:"sectors"`
+	}
+	json.Unmarshal(do(t, s, "GET", "/risk", "read-key-12345678").Body.Bytes(), &risk)
+	if risk.Sectors != nil {
+		t.Errorf("sectors reported without the symbols' sectors: %+v", risk.Sectors)
+	}
+
+	s.cfg.Exposure = config.ExposureConfig{SectorLimits: map[string]float64{"반도체": 0.3}}
+	s.SetSectors(map[string]string{"005930": "반도체", "035720": "서비스업"})
+	rec := do(t, s, "GET", "/risk", "read-key-12345678")
+	doc := loadSpec(t)
+	if err := doc.ValidateJSON(doc.JSONResponse(doc.Paths["/risk"]["get"], "200"), rec.Body.Bytes()); err != nil {
+		t.Errorf("response does not match the specification: %v", err)
+	}
+	json.Unmarshal(rec.Body.Bytes(), &risk)
+	// ₩700,000 of 005930 on ₩1,700,000 of equity.
+	want := []SectorExposure{{Sector: "반도체", Value: 700000, Exposure: 700000.0 / 1700000, Limit: 0.3}, {Sector: "서비스업"}}
+	if !reflect.DeepEqual(risk.Sectors, want) {
+		t.Errorf("sectors = %+v, want %+v", risk.Sectors, want)
+	}
+
+	metrics := do(t, s, "GET", "/metrics", "read-key-12345678").Body.String()
+	for _, line := range []string{
+		`tradingbot_sector_exposure_ratio{sector="반도체"} 0.4117647058823529`,
+		`tradingbot_sector_exposure_ratio{sector="서비스업"} 0`,
+		`tradingbot_sector_limit_ratio{sector="반도체"} 0.3`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("metrics lack %s:\n%s", line, metrics)
+		}
+	}
+}
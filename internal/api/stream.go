@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+	"tradingbot/internal/notify"
+)
+
+const (
+	streamBuffer       = 256
+	streamClientBuffer = 64
+)
+
+// streamKinds are the bus events sent to /stream clients.
+var streamKinds = []events.Kind{events.KindMarketData, events.KindSignal, events.KindOrder, events.KindFill}
+
+// StreamMessage is a JSON message sent to /stream clients. Event is "quote",
+// "signal", "order", "fill" or "equity"; signals, orders and fills carry the
+// same data as webhook payloads.
+type StreamMessage struct {
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+type quoteData struct {
+	Symbol     string             `json:"symbol"`
+	Price      float64            `json:"price"`
+	MarketData *models.MarketData `json:"market_data"`
+}
+
+// Equity is the account value reported by /equity and streamed after every
+// order and fill.
+type Equity struct {
+	Cash     float64 `json:"cash"`
+	Holdings float64 `json:"holdings"`
+	Equity   float64 `json:"equity"`
+}
+
+func (s *Server) equity() (Equity, error) {
+	equity, _, err := s.valuation()
+	return equity, err
+}
+
+// valuation returns the account value and the positions it holds.
+func (s *Server) valuation() (Equity, []models.Position, error) {
+	balance, err := s.account.GetBalance()
+	if err != nil {
+		return Equity{}, nil, err
+	}
+	cash, _ := strconv.ParseFloat(balance, 64)
+	positions, err := s.account.GetPositions()
+	if err != nil {
+		return Equity{}, nil, err
+	}
+	holdings := 0.0
+	for _, p := range positions {
+		holdings += p.Quantity * p.CurrentPrice
+	}
+	return Equity{Cash: cash, Holdings: holdings, Equity: cash + holdings}, positions, nil
+}
+
+// runStream forwards bus events to the connected stream clients until the
+// server shuts down. Clients that fall behind are disconnected.
+func (s *Server) runStream(ch <-chan events.Event) {
+	for {
+		select {
+		case <-s.done:
+			s.mu.Lock()
+			for client := range s.clients {
+				delete(s.clients, client)
+				close(client)
+			}
+			s.mu.Unlock()
+			return
+		case ev := <-ch:
+			s.mu.Lock()
+			listening := len(s.clients) > 0
+			s.mu.Unlock()
+			if !listening {
+				continue
+			}
+			if msg, ok := streamMessage(ev); ok {
+				s.broadcast(msg)
+			}
+			if kind := ev.Kind(); kind == events.KindOrder || kind == events.KindFill {
+				if msg, ok := s.equityMessage(); ok {
+					s.broadcast(msg)
+				}
+			}
+		}
+	}
+}
+
+func streamMessage(ev events.Event) (StreamMessage, bool) {
+	if md, ok := ev.(events.MarketDataEvent); ok {
+		price, _ := strconv.ParseFloat(md.Data.StckPrpr, 64)
+		return StreamMessage{Event: "quote", Time: md.Time, Data: quoteData{Symbol: md.Symbol, Price: price, MarketData: md.Data}}, true
+	}
+	payload, err := notify.NewPayload(ev)
+	if err != nil {
+		log.WithError(err).Debug("Event not streamed")
+		return StreamMessage{}, false
+	}
+	return StreamMessage{Event: string(payload.Event), Time: payload.Time, Data: payload.Data}, true
+}
+
+func (s *Server) equityMessage() (StreamMessage, bool) {
+	equity, err := s.equity()
+	if err != nil {
+		log.WithError(err).Warn("Failed to get equity for the event stream")
+		return StreamMessage{}, false
+	}
+	return StreamMessage{Event: "equity", Time: time.Now(), Data: equity}, true
+}
+
+func (s *Server) broadcast(msg StreamMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.WithError(err).Error("Failed to encode stream message")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		select {
+		case client <- data:
+		default:
+			log.Warn("Stream client too slow, disconnecting")
+			delete(s.clients, client)
+			close(client)
+		}
+	}
+}
+
+func (s *Server) removeClient(client chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[client]; ok {
+		delete(s.clients, client)
+		close(client)
+	}
+}
+
+// handleStream upgrades the request to a WebSocket and streams events to it,
+// starting with the current equity. Browsers cannot set headers on WebSocket
+// requests, so the token may also be passed as the token query parameter.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if _, ok := s.identify(token, time.Now()); !ok {
+		writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer conn.Close()
+	log.WithField("remote", r.RemoteAddr).Info("Stream client connected")
+
+	client := make(chan []byte, streamClientBuffer)
+	if msg, ok := s.equityMessage(); ok {
+		data, _ := json.Marshal(msg)
+		client <- data
+	}
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return
+	default:
+		s.clients[client] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	go func() {
+		conn.ReadLoop()
+		s.removeClient(client)
+	}()
+	for data := range client {
+		if err := conn.WriteText(data); err != nil {
+			s.removeClient(client)
+			break
+		}
+	}
+	log.WithField("remote", r.RemoteAddr).Info("Stream client disconnected")
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const maxAlertSize = 64 << 10
+
+// tradingViewAlert is the JSON message to configure in a TradingView alert, e.g.
+//
+//	{"passphrase": "...", "symbol": "{{ticker}}", "action": "{{strategy.order.action}}", "quantity": {{strategy.order.contracts}}}
+//
+// Instead of a quantity, an alert may give a KRW notional, which is converted
+// into whole shares at the current price, and a "price" to place a limit
+// order instead of a market order.
+type tradingViewAlert struct {
+	Passphrase string `json:"passphrase"`
+	signalRequest
+}
+
+// signalRequest is a buy or sell request, sent by a TradingView alert or by
+// hand to /control/signal.
+type signalRequest struct {
+	Symbol   string      `json:"symbol"`
+	Action   string      `json:"action"`
+	Quantity json.Number `json:"quantity"`
+	Notional json.Number `json:"notional"`
+	Price    json.Number `json:"price"`
+}
+
+// signal validates the request and converts it into a trading signal for one
+// of the symbols in symbols, or for any symbol if symbols is nil.
+func (a signalRequest) signal(symbols []string) (*models.Signal, error) {
+	// {{exchange}}:{{ticker}} style symbols such as "KRX:005930" are accepted too.
+	symbol := strings.ToUpper(strings.TrimSpace(a.Symbol))
+	if i := strings.LastIndex(symbol, ":"); i >= 0 {
+		symbol = symbol[i+1:]
+	}
+	if symbol == "" {
+		return nil, errors.New("symbol is required")
+	}
+	traded := symbols == nil
+	for _, s := range symbols {
+		traded = traded || s == symbol
+	}
+	if !traded {
+		return nil, fmt.Errorf("symbol %q is not traded by this bot", a.Symbol)
+	}
+
+	var signalType models.SignalType
+	switch strings.ToLower(strings.TrimSpace(a.Action)) {
+	case "buy":
+		signalType = models.BuySignal
+	case "sell":
+		signalType = models.SellSignal
+	default:
+		return nil, errors.New("action must be buy or sell")
+	}
+
+	signal := &models.Signal{Type: signalType, Pair: symbol}
+	switch {
+	case a.Quantity != "" && a.Notional != "":
+		return nil, errors.New("give either quantity or notional, not both")
+	case a.Notional != "":
+		notional, err := a.Notional.Float64()
+		if err != nil || notional <= 0 {
+			return nil, errors.New("notional must be a positive number")
+		}
+		signal.Notional = notional
+	default:
+		quantity, err := a.Quantity.Float64()
+		if err != nil || quantity <= 0 {
+			return nil, errors.New("quantity must be a positive number")
+		}
+		signal.Amount = quantity
+	}
+	if a.Price != "" {
+		price, err := a.Price.Float64()
+		if err != nil || price <= 0 {
+			return nil, errors.New("price must be a positive number")
+		}
+		signal.LimitPrice = price
+	}
+	return signal, nil
+}
+
+func (s *Server) handleTradingView(w http.ResponseWriter, r *http.Request) {
+	var alert tradingViewAlert
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAlertSize)).Decode(&alert); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid alert JSON: "+err.Error())
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(alert.Passphrase), []byte(s.cfg.API.TradingView.Passphrase)) != 1 {
+		log.WithField("remote", r.RemoteAddr).Warn("Rejected TradingView alert with invalid passphrase")
+		writeError(w, http.StatusUnauthorized, "invalid passphrase")
+		return
+	}
+
+	signal, err := alert.signal(s.cfg.TradingSymbols())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(logrus.Fields{"pair": signal.Pair, "type": signal.Type, "amount": signal.Amount, "notional": signal.Notional}).Info("TradingView alert received")
+	if err := s.control.SubmitSignal(signal); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "submitted"})
+}
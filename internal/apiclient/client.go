@@ -0,0 +1,94 @@
+// Package apiclient is a Go client of the bot's status and control API. The
+// types and methods in generated.go are generated from the OpenAPI
+// specification in internal/api/openapi.yaml.
+package apiclient
+
+//go:generate go run ./gen ../api/openapi.yaml generated.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the API at a base URL, such as "http://127.0.0.1:8080",
+// authenticating with a bearer credential: the API token, an API key or a JWT.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// New creates a client of the API at baseURL. token may be empty for the
+// endpoints that need none.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: time.Minute},
+	}
+}
+
+// SetHTTPClient replaces the HTTP client requests are sent with, e.g. to
+// change the timeout or the transport.
+func (c *Client) SetHTTPClient(h *http.Client) {
+	c.http = h
+}
+
+// Error is a response other than 200 OK. Message is the error the API gave,
+// or the HTTP status if it gave none.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// do sends a request with body, if not nil, encoded as JSON and decodes the
+// JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr ErrorBody
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return &Error{StatusCode: resp.StatusCode, Message: apiErr.Error}
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: fmt.Sprintf("API returned %s", resp.Status)}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
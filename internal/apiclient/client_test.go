@@ -0,0 +1,89 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"tradingbot/internal/api"
+	"tradingbot/internal/openapi"
+)
+
+func TestGeneratedCodeIsUpToDate(t *testing.T) {
+	doc, err := openapi.Load(api.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := doc.Generate("apiclient", "internal/api/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("generated.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("generated.go is out of date with internal/api/openapi.yaml; run go generate ./internal/apiclient")
+	}
+}
+
+func TestClientSendsRequests(t *testing.T) {
+	var got struct {
+		method, path, query, auth string
+		body                      map[string]interface{}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.method, got.path, got.query = r.Method, r.URL.Path, r.URL.RawQuery
+		got.auth = r.Header.Get("Authorization")
+		got.body = nil
+		json.NewDecoder(r.Body).Decode(&got.body)
+
+		switch r.URL.Path {
+		case "/reports/pnl":
+			w.Write([]byte(`{"from": "2024-01-02T00:00:00+09:00", "total": {"realized_pnl": 1500, "trades": 2}}`))
+		case "/control/close":
+			w.Write([]byte(`{"status": "closed"}`))
+		case "/control/live/approve":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "requires the admin role"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL+"/", "key-1234567890ab")
+	ctx := context.Background()
+
+	pnl, err := c.GetPnL(ctx, "2024-01-02", "")
+	if err != nil {
+		t.Fatalf("GetPnL: %v", err)
+	}
+	if got.method != "GET" || got.path != "/reports/pnl" || got.query != "from=2024-01-02" || got.auth != "Bearer key-1234567890ab" {
+		t.Errorf("GetPnL sent %s %s?%s with %q", got.method, got.path, got.query, got.auth)
+	}
+	if pnl.Total.RealizedPnL != 1500 || pnl.Total.Trades != 2 || pnl.From.IsZero() {
+		t.Errorf("GetPnL = %+v", pnl)
+	}
+
+	res, err := c.ClosePosition(ctx, &CloseRequest{Symbol: "005930"})
+	if err != nil || res.Status != "closed" {
+		t.Fatalf("ClosePosition = %+v, %v", res, err)
+	}
+	if got.method != "POST" || got.body["symbol"] != "005930" {
+		t.Errorf("ClosePosition sent %s with body %v", got.method, got.body)
+	}
+
+	_, err = c.ApproveLive(ctx)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || apiErr.Message != "requires the admin role" {
+		t.Errorf("ApproveLive error = %v, want the 403 from the API", err)
+	}
+	_, err = c.Flatten(ctx)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Flatten error = %v, want a 500 error", err)
+	}
+}
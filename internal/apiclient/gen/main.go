@@ -0,0 +1,40 @@
+// Command gen generates the types and methods of package apiclient from the
+// OpenAPI specification of the API:
+//
+//	go run ./gen <spec> <output>
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"tradingbot/internal/openapi"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: gen <spec> <output>")
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2]); err != nil {
+		fmt.Fprintln(os.Stderr, "gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(specPath, outPath string) error {
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+	doc, err := openapi.Load(data)
+	if err != nil {
+		return err
+	}
+	src, err := doc.Generate("apiclient", "internal/api/"+filepath.Base(specPath))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, src, 0o644)
+}
@@ -0,0 +1,618 @@
+// Code generated from internal/api/openapi.yaml; DO NOT EDIT.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// Attribution is the PnL, fees and turnover of one strategy and symbol, or a sum of them.
+type Attribution struct {
+	Dividends float64 `json:"dividends"`
+	Fees      float64 `json:"fees"`
+	// Position held at the end of the period.
+	Quantity float64 `json:"quantity"`
+	// Realized PnL, fees and dividends included.
+	RealizedPnL   float64 `json:"realized_pnl"`
+	Strategy      string  `json:"strategy,omitempty"`
+	Symbol        string  `json:"symbol,omitempty"`
+	Trades        int     `json:"trades"`
+	Turnover      float64 `json:"turnover"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+}
+
+// Bin is the volume traded in a price range of a Profile.
+type Bin struct {
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Volume float64 `json:"volume"`
+}
+
+// Book is the paper performance of one strategy.
+type Book struct {
+	Capital     float64 `json:"capital"`
+	Equity      float64 `json:"equity"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+	Orders      int     `json:"orders"`
+	Return      float64 `json:"return"`
+	// One of incumbent, candidate.
+	Role     string  `json:"role"`
+	Strategy string  `json:"strategy"`
+	Trades   int     `json:"trades"`
+	WinRate  float64 `json:"win_rate"`
+}
+
+// Candle is an OHLCV bar starting at start.
+type Candle struct {
+	Close  float64   `json:"close"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Open   float64   `json:"open"`
+	Start  time.Time `json:"start"`
+	Volume float64   `json:"volume"`
+}
+
+// CloseRequest names the position to sell.
+type CloseRequest struct {
+	Symbol string `json:"symbol"`
+}
+
+// Equity is the value of the account.
+type Equity struct {
+	Cash     float64 `json:"cash"`
+	Equity   float64 `json:"equity"`
+	Holdings float64 `json:"holdings"`
+}
+
+// ErrorBody is the body of every error response.
+type ErrorBody struct {
+	Error string `json:"error"`
+}
+
+// ExperimentReport compares the variants of the A/B test.
+type ExperimentReport struct {
+	A        Variant `json:"a"`
+	B        Variant `json:"b"`
+	Complete bool    `json:"complete"`
+	Days     int     `json:"days"`
+	// Mean daily return of A minus that of B.
+	Difference  float64 `json:"difference"`
+	PValue      float64 `json:"p_value"`
+	Required    int     `json:"required"`
+	Significant bool    `json:"significant"`
+	T           float64 `json:"t"`
+	Winner      string  `json:"winner,omitempty"`
+}
+
+// KillRequest optionally gives the reason for triggering the kill switch.
+type KillRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// KillResult reports what the kill switch did.
+type KillResult struct {
+	// Open orders cancelled.
+	Cancelled int `json:"cancelled"`
+	// Set when the open orders could not be listed.
+	Error string `json:"error,omitempty"`
+	// Open orders that could not be cancelled.
+	Failed int       `json:"failed"`
+	Source string    `json:"source"`
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// LastError is the last error raised in the trading loop.
+type LastError struct {
+	Error  string    `json:"error"`
+	Source string    `json:"source"`
+	Symbol string    `json:"symbol"`
+	Time   time.Time `json:"time"`
+}
+
+// MovingAverage is a set of moving average crossover parameters.
+type MovingAverage struct {
+	LongPeriod  int `json:"long_period"`
+	ShortPeriod int `json:"short_period"`
+}
+
+// OpenOrder is an order not yet filled or cancelled.
+type OpenOrder struct {
+	BranchNo     string  `json:"branch_no"`
+	OrderNo      string  `json:"order_no"`
+	Price        float64 `json:"price"`
+	Quantity     float64 `json:"quantity"`
+	RemainingQty float64 `json:"remaining_qty"`
+	// One of buy, sell.
+	Side      string `json:"side"`
+	StockCode string `json:"stock_code"`
+}
+
+// PauseState tells whether the trading loop is paused.
+type PauseState struct {
+	Paused bool `json:"paused"`
+}
+
+// PhaseLatency sums the durations of a cycle phase since startup, in milliseconds.
+type PhaseLatency struct {
+	Count  int     `json:"count"`
+	LastMs float64 `json:"last_ms"`
+	MaxMs  float64 `json:"max_ms"`
+	MeanMs float64 `json:"mean_ms"`
+}
+
+// PnL attributes PnL to strategies and symbols over a period.
+type PnL struct {
+	ByStrategy []Attribution `json:"by_strategy"`
+	BySymbol   []Attribution `json:"by_symbol"`
+	From       time.Time     `json:"from"`
+	// One entry per strategy and symbol traded or held in the period.
+	Rows  []Attribution `json:"rows"`
+	To    time.Time     `json:"to"`
+	Total Attribution   `json:"total"`
+	// Orders stored without a price, which are left out.
+	Unpriced int `json:"unpriced"`
+}
+
+// Position is a position held in the account.
+type Position struct {
+	AvgPrice     float64 `json:"avg_price"`
+	CurrentPrice float64 `json:"current_price"`
+	// Credit loan outstanding on the position.
+	Loan float64 `json:"loan,omitempty"`
+	// Day the loan was taken out, YYYYMMDD.
+	LoanDate   string  `json:"loan_date,omitempty"`
+	Name       string  `json:"name"`
+	ProfitLoss float64 `json:"profit_loss"`
+	Quantity   float64 `json:"quantity"`
+	StockCode  string  `json:"stock_code"`
+}
+
+// Profile is the session VWAP and volume profile of one symbol.
+type Profile struct {
+	Bins    []Bin   `json:"bins"`
+	High    float64 `json:"high"`
+	Low     float64 `json:"low"`
+	Minutes int     `json:"minutes"`
+	// Point of control, the middle of the bin with the most volume.
+	POC     float64   `json:"poc"`
+	Symbol  string    `json:"symbol"`
+	Updated time.Time `json:"updated"`
+	Volume  float64   `json:"volume"`
+	VWAP    float64   `json:"vwap"`
+}
+
+// Result reports that a control request was carried out.
+type Result struct {
+	Status string `json:"status"`
+}
+
+// Risk is the risk limits and whether trading is paused.
+type Risk struct {
+	Circuit        string  `json:"circuit"`
+	MarketHours    bool    `json:"market_hours"`
+	MaxOrderAmount float64 `json:"max_order_amount"`
+	Paused         bool    `json:"paused"`
+	// Exposure to each sector, when the sectors of the symbols are known and the account could be read.
+	Sectors []SectorExposure `json:"sectors,omitempty"`
+}
+
+// SectorExposure is the value of the positions in a sector and its share of the equity, with the sector's limit if it has one.
+type SectorExposure struct {
+	Exposure float64 `json:"exposure"`
+	Limit    float64 `json:"limit,omitempty"`
+	Sector   string  `json:"sector"`
+	Value    float64 `json:"value"`
+}
+
+// ShadowReport compares the traded and shadow strategies since Since.
+type ShadowReport struct {
+	Books []Book    `json:"books"`
+	Since time.Time `json:"since"`
+}
+
+// SignalEntry is a signal emitted by a strategy.
+type SignalEntry struct {
+	Amount float64   `json:"amount"`
+	Symbol string    `json:"symbol"`
+	Time   time.Time `json:"time"`
+	// One of buy, sell, hold.
+	Type string `json:"type"`
+}
+
+// SignalRequest is a buy or sell request.
+type SignalRequest struct {
+	// One of buy, sell.
+	Action string `json:"action"`
+	// KRW amount, converted into whole shares at the current price.
+	Notional float64 `json:"notional,omitempty"`
+	// Limit price; a market order is placed without it.
+	Price float64 `json:"price,omitempty"`
+	// Number of shares; give either quantity or notional.
+	Quantity float64 `json:"quantity,omitempty"`
+	Symbol   string  `json:"symbol"`
+}
+
+// Status is the mode, symbols and state of the trading loop.
+type Status struct {
+	// State of the exchange circuit breaker, closed, open or half-open.
+	Circuit   string     `json:"circuit"`
+	LastCycle time.Time  `json:"last_cycle"`
+	LastError *LastError `json:"last_error,omitempty"`
+	// paper or live.
+	Mode     string   `json:"mode"`
+	Paused   bool     `json:"paused"`
+	Profile  string   `json:"profile"`
+	Strategy string   `json:"strategy"`
+	Symbols  []string `json:"symbols"`
+}
+
+// StreamMessage is a message sent to /stream clients.
+type StreamMessage struct {
+	// The event's payload; signals, orders and fills carry the same data as webhook payloads.
+	Data json.RawMessage `json:"data"`
+	// One of quote, signal, order, fill, equity.
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+}
+
+// TradeRequest is a trade made outside the bot.
+type TradeRequest struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+	// One of buy, sell.
+	Side   string `json:"side"`
+	Symbol string `json:"symbol"`
+	// Time of the trade (default now).
+	Time *time.Time `json:"time,omitempty"`
+}
+
+// TradingViewAlert is the JSON message to configure in a TradingView alert.
+type TradingViewAlert struct {
+	// One of buy, sell.
+	Action     string  `json:"action"`
+	Notional   float64 `json:"notional,omitempty"`
+	Passphrase string  `json:"passphrase"`
+	Price      float64 `json:"price,omitempty"`
+	Quantity   float64 `json:"quantity,omitempty"`
+	Symbol     string  `json:"symbol"`
+}
+
+// Tuning is the latest proposal of the parameter tuner.
+type Tuning struct {
+	Applied        bool          `json:"applied"`
+	Current        MovingAverage `json:"current"`
+	CurrentProfit  float64       `json:"current_profit"`
+	Days           int           `json:"days"`
+	Proposed       MovingAverage `json:"proposed"`
+	ProposedProfit float64       `json:"proposed_profit"`
+	Symbol         string        `json:"symbol"`
+	Time           time.Time     `json:"time"`
+}
+
+// Variant is the performance of one sleeve of the A/B test.
+type Variant struct {
+	Equity      float64 `json:"equity"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+	MeanDaily   float64 `json:"mean_daily"`
+	Orders      int     `json:"orders"`
+	Return      float64 `json:"return"`
+	Sharpe      float64 `json:"sharpe"`
+	Sleeve      string  `json:"sleeve"`
+	Volatility  float64 `json:"volatility"`
+	Weight      float64 `json:"weight"`
+}
+
+// GetCandles lists the cached recent candles of a symbol.
+//
+// GET /candles, role read.
+// symbol: Symbol to return the candles of.
+// timeframe: Timeframe such as 5m or 1d (default the configured timeframe, or 1d).
+// limit: Most candles to return, the latest (default 200).
+func (c *Client) GetCandles(ctx context.Context, symbol string, timeframe string, limit string) ([]Candle, error) {
+	query := url.Values{}
+	if symbol != "" {
+		query.Set("symbol", symbol)
+	}
+	if timeframe != "" {
+		query.Set("timeframe", timeframe)
+	}
+	if limit != "" {
+		query.Set("limit", limit)
+	}
+	var out []Candle
+	if err := c.do(ctx, "GET", "/candles", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ClosePosition sells the whole position in a symbol, bypassing the risk checks.
+//
+// POST /control/close, role operator.
+func (c *Client) ClosePosition(ctx context.Context, req *CloseRequest) (*Result, error) {
+	var body interface{}
+	if req != nil {
+		body = req
+	}
+	var out Result
+	if err := c.do(ctx, "POST", "/control/close", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TriggerCycle runs a trading cycle now.
+//
+// POST /control/cycle, role operator.
+func (c *Client) TriggerCycle(ctx context.Context) (*Result, error) {
+	var out Result
+	if err := c.do(ctx, "POST", "/control/cycle", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Flatten sells every position.
+//
+// POST /control/flatten, role operator.
+func (c *Client) Flatten(ctx context.Context) (*Result, error) {
+	var out Result
+	if err := c.do(ctx, "POST", "/control/flatten", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Kill triggers the kill switch, halting orders until restart and cancelling open ones.
+//
+// POST /control/kill, role operator.
+func (c *Client) Kill(ctx context.Context, req *KillRequest) (*KillResult, error) {
+	var body interface{}
+	if req != nil {
+		body = req
+	}
+	var out KillResult
+	if err := c.do(ctx, "POST", "/control/kill", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ApproveLive lets a live run waiting for approval send orders.
+//
+// POST /control/live/approve, role admin.
+func (c *Client) ApproveLive(ctx context.Context) (*Result, error) {
+	var out Result
+	if err := c.do(ctx, "POST", "/control/live/approve", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Pause pauses the trading loop.
+//
+// POST /control/pause, role operator.
+func (c *Client) Pause(ctx context.Context) (*PauseState, error) {
+	var out PauseState
+	if err := c.do(ctx, "POST", "/control/pause", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Resume resumes the trading loop, unless the kill switch stopped it.
+//
+// POST /control/resume, role operator.
+func (c *Client) Resume(ctx context.Context) (*PauseState, error) {
+	var out PauseState
+	if err := c.do(ctx, "POST", "/control/resume", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// InjectSignal injects a one-off signal, which goes through the risk checks, for any symbol.
+//
+// POST /control/signal, role operator.
+func (c *Client) InjectSignal(ctx context.Context, req *SignalRequest) (*Result, error) {
+	var body interface{}
+	if req != nil {
+		body = req
+	}
+	var out Result
+	if err := c.do(ctx, "POST", "/control/signal", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ApproveTuning applies the parameters proposed by the tuner.
+//
+// POST /control/tuning/approve, role admin.
+func (c *Client) ApproveTuning(ctx context.Context) (*Result, error) {
+	var out Result
+	if err := c.do(ctx, "POST", "/control/tuning/approve", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetEquity reports the account's cash, holdings and total value.
+//
+// GET /equity, role read.
+func (c *Client) GetEquity(ctx context.Context) (*Equity, error) {
+	var out Equity
+	if err := c.do(ctx, "GET", "/equity", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetExperiment compares the variants of the A/B test.
+//
+// GET /experiment, role read.
+func (c *Client) GetExperiment(ctx context.Context) (*ExperimentReport, error) {
+	var out ExperimentReport
+	if err := c.do(ctx, "GET", "/experiment", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetIntraday reports the session VWAP and volume profiles.
+//
+// GET /intraday, role read.
+// symbol: Symbol to return the profile of.
+func (c *Client) GetIntraday(ctx context.Context, symbol string) (json.RawMessage, error) {
+	query := url.Values{}
+	if symbol != "" {
+		query.Set("symbol", symbol)
+	}
+	var out json.RawMessage
+	if err := c.do(ctx, "GET", "/intraday", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetLatency reports the time spent in each phase of the trading cycles.
+//
+// GET /metrics/latency, role read.
+func (c *Client) GetLatency(ctx context.Context) (map[string]PhaseLatency, error) {
+	var out map[string]PhaseLatency
+	if err := c.do(ctx, "GET", "/metrics/latency", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetOpenOrders lists the orders not yet filled or cancelled.
+//
+// GET /orders/open, role read.
+func (c *Client) GetOpenOrders(ctx context.Context) ([]OpenOrder, error) {
+	var out []OpenOrder
+	if err := c.do(ctx, "GET", "/orders/open", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetPositions lists the positions held in the account.
+//
+// GET /positions, role read.
+func (c *Client) GetPositions(ctx context.Context) ([]Position, error) {
+	var out []Position
+	if err := c.do(ctx, "GET", "/positions", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetPnL attributes PnL to strategies and symbols over a period.
+//
+// GET /reports/pnl, role read.
+// from: First KST date, YYYY-MM-DD, of the period (default today).
+// to: Last KST date, YYYY-MM-DD, of the period (default today).
+func (c *Client) GetPnL(ctx context.Context, from string, to string) (*PnL, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	var out PnL
+	if err := c.do(ctx, "GET", "/reports/pnl", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRisk reports the risk limits, whether trading is paused and the exposure to each sector.
+//
+// GET /risk, role read.
+func (c *Client) GetRisk(ctx context.Context) (*Risk, error) {
+	var out Risk
+	if err := c.do(ctx, "GET", "/risk", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetShadow compares the paper performance of the traded and shadow strategies.
+//
+// GET /shadow, role read.
+func (c *Client) GetShadow(ctx context.Context) (*ShadowReport, error) {
+	var out ShadowReport
+	if err := c.do(ctx, "GET", "/shadow", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSignals lists the last 100 signals, newest first.
+//
+// GET /signals, role read.
+func (c *Client) GetSignals(ctx context.Context) ([]SignalEntry, error) {
+	var out []SignalEntry
+	if err := c.do(ctx, "GET", "/signals", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetStatus reports the mode, symbols and state of the trading loop.
+//
+// GET /status, role read.
+func (c *Client) GetStatus(ctx context.Context) (*Status, error) {
+	var out Status
+	if err := c.do(ctx, "GET", "/status", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecordTrade records a trade made outside the bot as if the bot had placed it.
+//
+// POST /trades, role operator.
+func (c *Client) RecordTrade(ctx context.Context, req *TradeRequest) (*Result, error) {
+	var body interface{}
+	if req != nil {
+		body = req
+	}
+	var out Result
+	if err := c.do(ctx, "POST", "/trades", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTuning reports the latest parameters proposed by the tuner.
+//
+// GET /tuning, role read.
+func (c *Client) GetTuning(ctx context.Context) (*Tuning, error) {
+	var out Tuning
+	if err := c.do(ctx, "GET", "/tuning", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TradingViewAlert receives a TradingView alert, served when api.tradingview.enabled is set.
+//
+// POST /webhook/tradingview, role none.
+func (c *Client) TradingViewAlert(ctx context.Context, req *TradingViewAlert) (*Result, error) {
+	var body interface{}
+	if req != nil {
+		body = req
+	}
+	var out Result
+	if err := c.do(ctx, "POST", "/webhook/tradingview", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
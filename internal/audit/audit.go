@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+)
+
+var log = logging.New()
+
+// Record is one line of the audit log: a single trading decision with its inputs.
+// Records are chained by hash, so editing or removing a line breaks Verify.
+type Record struct {
+	Seq        int64              `json:"seq"`
+	Time       time.Time          `json:"time"`
+	Symbol     string             `json:"symbol"`
+	Source     string             `json:"source"`
+	Price      string             `json:"price,omitempty"`
+	Indicators map[string]float64 `json:"indicators,omitempty"`
+	Signal     *models.Signal     `json:"signal,omitempty"`
+	Checks     []events.RiskCheck `json:"checks,omitempty"`
+	Action     string             `json:"action"`
+	Order      *models.Order      `json:"order,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	PrevHash   string             `json:"prev_hash"`
+	Hash       string             `json:"hash"`
+}
+
+// NewRecord converts a decision event into an unchained record.
+func NewRecord(d events.DecisionEvent) Record {
+	r := Record{
+		Time:       d.Time,
+		Symbol:     d.Symbol,
+		Source:     d.Source,
+		Indicators: d.Indicators,
+		Signal:     d.Signal,
+		Checks:     d.Checks,
+		Action:     d.Action,
+		Order:      d.Order,
+	}
+	if d.MarketData != nil {
+		r.Price = d.MarketData.StckPrpr
+	}
+	if d.Err != nil {
+		r.Error = d.Err.Error()
+	}
+	return r
+}
+
+// computeHash hashes the record contents together with the previous hash.
+func (r Record) computeHash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is an append-only audit file. Each record is written and synced before
+// Append returns.
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	seq      int64
+	lastHash string
+}
+
+// Open opens the audit log at path, creating it if needed, and continues the
+// hash chain of the existing records. It fails if the existing chain is broken.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %v", err)
+	}
+
+	l := &Log{}
+	if f, err := os.Open(path); err == nil {
+		last, err := verify(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("audit log %s is corrupt: %v", path, err)
+		}
+		l.seq, l.lastHash = last.Seq, last.Hash
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	l.file = f
+	return l, nil
+}
+
+// Append chains r to the log and writes it.
+func (l *Log) Append(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq + 1
+	r.PrevHash = l.lastHash
+	hash, err := r.computeHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash audit record: %v", err)
+	}
+	r.Hash = hash
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %v", err)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %v", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %v", err)
+	}
+	l.seq, l.lastHash = r.Seq, r.Hash
+	return nil
+}
+
+// Subscribe records every decision published on bus. Records are written
+// synchronously, so a cycle is not finished before its decisions are on disk.
+func (l *Log) Subscribe(bus *events.Bus) {
+	bus.Subscribe(func(ev events.Event) {
+		if err := l.Append(NewRecord(ev.(events.DecisionEvent))); err != nil {
+			log.WithError(err).Error("Failed to write audit record")
+		}
+	}, events.KindDecision)
+}
+
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Filter selects records in Read. Zero values match everything.
+type Filter struct {
+	Symbol string
+	Action string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (f Filter) match(r Record) bool {
+	return (f.Symbol == "" || r.Symbol == f.Symbol) &&
+		(f.Action == "" || r.Action == f.Action) &&
+		(f.Since.IsZero() || !r.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || r.Time.Before(f.Until))
+}
+
+// Read returns the records at path matching filter, oldest first.
+func Read(path string, filter Filter) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var out []Record
+	err = scan(f, func(r Record) error {
+		if filter.match(r) {
+			out = append(out, r)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Verify checks the hash chain of the audit log at path and returns the number
+// of records.
+func Verify(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	last, err := verify(f)
+	return last.Seq, err
+}
+
+func verify(r io.Reader) (Record, error) {
+	var last Record
+	err := scan(r, func(rec Record) error {
+		if rec.Seq != last.Seq+1 {
+			return fmt.Errorf("record %d follows record %d", rec.Seq, last.Seq)
+		}
+		if rec.PrevHash != last.Hash {
+			return fmt.Errorf("record %d does not chain to record %d", rec.Seq, last.Seq)
+		}
+		hash, err := rec.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != rec.Hash {
+			return fmt.Errorf("record %d has been modified", rec.Seq)
+		}
+		last = rec
+		return nil
+	})
+	return last, err
+}
+
+func scan(r io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+func TestLogAppendReadVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "decisions.jsonl")
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	bus := events.NewBus()
+	l.Subscribe(bus)
+	bus.Publish(events.DecisionEvent{
+		Symbol:     "005930",
+		Source:     "strategy",
+		MarketData: &models.MarketData{StckPrpr: "70000"},
+		Indicators: map[string]float64{"short_sma": 70100, "long_sma": 69000},
+		Signal:     &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1},
+		Checks:     []events.RiskCheck{{Name: "max_order_amount", Passed: true}},
+		Action:     events.ActionOrdered,
+		Order:      &models.Order{Pair: "005930", Amount: 1},
+		Time:       start,
+	})
+	l.Close()
+
+	// Reopening continues the chain.
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen returned error: %v", err)
+	}
+	l.Append(NewRecord(events.DecisionEvent{Symbol: "000660", Action: events.ActionError, Err: errors.New("timeout"), Time: start.Add(time.Minute)}))
+	l.Close()
+
+	if n, err := Verify(path); err != nil || n != 2 {
+		t.Fatalf("Verify = %d, %v; want 2, nil", n, err)
+	}
+
+	records, err := Read(path, Filter{Symbol: "005930"})
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if len(records) != 1 || records[0].Price != "70000" || records[0].Indicators["short_sma"] != 70100 {
+		t.Errorf("records = %+v, want the 005930 decision", records)
+	}
+	records, _ = Read(path, Filter{Since: start.Add(30 * time.Second)})
+	if len(records) != 1 || records[0].Error != "timeout" {
+		t.Errorf("records since 09:00:30 = %+v, want the error decision", records)
+	}
+
+	// Editing a record breaks the chain.
+	data, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, []byte(strings.Replace(string(data), `"action":"ordered"`, `"action":"hold"`, 1)), 0640)
+	if _, err := Verify(path); err == nil || !strings.Contains(err.Error(), "record 1 has been modified") {
+		t.Errorf("Verify after tampering = %v, want modification error", err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Errorf("Open succeeded on a tampered log")
+	}
+}
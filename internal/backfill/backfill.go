@@ -0,0 +1,224 @@
+package backfill
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+// Source pages through the daily candle history, e.g. the KIS client. It
+// returns the latest candles of the range it can in one request, oldest first.
+type Source interface {
+	GetDailyCandlesBetween(stockCode string, from, to time.Time) ([]candle.Candle, error)
+}
+
+// Store holds the backfilled candles, e.g. the daily_candles table.
+type Store interface {
+	DailyCandleDates(stockCode string, from, to time.Time) ([]time.Time, error)
+	SaveDailyCandles(candles []candle.Candle) error
+}
+
+// Progress reports how many of the trading days missing when a backfill
+// started have been requested so far, and the oldest date reached.
+type Progress struct {
+	Symbol string
+	Done   int
+	Total  int
+	Date   time.Time
+}
+
+// Result sums up a backfill.
+type Result struct {
+	Requests int
+	Saved    int
+	// Missing are the trading days that still have no candle, because they
+	// are before the listing or holidays the calendar does not know.
+	Missing int
+}
+
+const (
+	defaultRetries    = 3
+	defaultRetryDelay = time.Second
+)
+
+// Backfiller fills the gaps in the stored daily candles of a stock from the
+// source. Candles are saved page by page, so an interrupted backfill resumes
+// where it stopped when run again.
+type Backfiller struct {
+	source Source
+	store  Store
+	cal    *market.Calendar
+	clock  clock.Clock
+
+	// Interval is the least time between two requests, to stay within the
+	// source's rate limit.
+	Interval time.Duration
+	// Retries is how often a failed request is retried, waiting RetryDelay,
+	// doubled every time, in between.
+	Retries    int
+	RetryDelay time.Duration
+	// Progress, when set, is called after every page.
+	Progress func(Progress)
+
+	last time.Time
+}
+
+// New creates a backfiller reading from source into store, with the trading
+// days of cal.
+func New(source Source, store Store, cal *market.Calendar) *Backfiller {
+	return &Backfiller{source: source, store: store, cal: cal, clock: clock.Real, Retries: defaultRetries, RetryDelay: defaultRetryDelay}
+}
+
+// SetClock replaces the clock used to wait between requests.
+func (b *Backfiller) SetClock(c clock.Clock) {
+	b.clock = c
+}
+
+// Gaps returns the trading days from from to to that have no stored candle,
+// oldest first.
+func (b *Backfiller) Gaps(symbol string, from, to time.Time) ([]time.Time, error) {
+	stored, err := b.store.DailyCandleDates(symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+	have := map[string]bool{}
+	for _, d := range stored {
+		have[d.In(market.KST).Format("2006-01-02")] = true
+	}
+
+	var missing []time.Time
+	for _, day := range b.tradingDays(from, to) {
+		if !have[day.Format("2006-01-02")] {
+			missing = append(missing, day)
+		}
+	}
+	return missing, nil
+}
+
+// Run backfills the daily candles of symbol from from to to. Each run of
+// consecutive missing trading days is requested from its end backwards, one
+// page at a time, newest run first.
+func (b *Backfiller) Run(symbol string, from, to time.Time) (Result, error) {
+	var result Result
+	missing, err := b.Gaps(symbol, from, to)
+	if err != nil {
+		return result, err
+	}
+	log.WithFields(logrus.Fields{"symbol": symbol, "missing": len(missing)}).Info("Backfilling daily candles")
+
+	runs := consecutive(b.tradingDays(from, to), missing)
+	done := 0
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		first, end := run[0], run[len(run)-1]
+		for {
+			page, err := b.fetch(symbol, first, end)
+			result.Requests++
+			if err != nil {
+				result.Missing = len(missing) - result.Saved
+				return result, fmt.Errorf("failed to get candles of %s up to %s: %v", symbol, end.Format("2006-01-02"), err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			if err := b.store.SaveDailyCandles(page); err != nil {
+				result.Missing = len(missing) - result.Saved
+				return result, err
+			}
+			result.Saved += len(page)
+
+			oldest := date(page[0].Start)
+			if b.Progress != nil {
+				reached := len(run) - sort.Search(len(run), func(j int) bool { return !run[j].Before(oldest) })
+				b.Progress(Progress{Symbol: symbol, Done: done + reached, Total: len(missing), Date: oldest})
+			}
+			if !oldest.After(first) {
+				break
+			}
+			end = oldest.AddDate(0, 0, -1)
+		}
+		done += len(run)
+		if b.Progress != nil {
+			b.Progress(Progress{Symbol: symbol, Done: done, Total: len(missing), Date: first})
+		}
+	}
+
+	result.Missing = len(missing) - result.Saved
+	if result.Missing < 0 {
+		result.Missing = 0
+	}
+	return result, nil
+}
+
+// fetch requests a page of daily candles.
+func (b *Backfiller) fetch(symbol string, from, to time.Time) ([]candle.Candle, error) {
+	return b.request(symbol, func() ([]candle.Candle, error) { return b.source.GetDailyCandlesBetween(symbol, from, to) })
+}
+
+// request makes a request for a page, keeping to Interval and retrying
+// failures.
+func (b *Backfiller) request(symbol string, get func() ([]candle.Candle, error)) ([]candle.Candle, error) {
+	delay := b.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if wait := b.Interval - b.clock.Now().Sub(b.last); !b.last.IsZero() && wait > 0 {
+			b.clock.Sleep(wait)
+		}
+		b.last = b.clock.Now()
+
+		page, err := get()
+		if err == nil || attempt >= b.Retries {
+			return page, err
+		}
+		log.WithError(err).WithField("symbol", symbol).Warnf("Candle request failed, retrying in %v", delay)
+		b.clock.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// tradingDays returns the trading days from from to to.
+func (b *Backfiller) tradingDays(from, to time.Time) []time.Time {
+	var days []time.Time
+	for day := date(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if _, ok := b.cal.SessionOn(day); ok {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// consecutive splits missing into runs of days that follow each other in
+// days.
+func consecutive(days, missing []time.Time) [][]time.Time {
+	var runs [][]time.Time
+	m := 0
+	var run []time.Time
+	for _, day := range days {
+		if m < len(missing) && missing[m].Equal(day) {
+			run = append(run, day)
+			m++
+			continue
+		}
+		if len(run) > 0 {
+			runs = append(runs, run)
+			run = nil
+		}
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// date returns midnight KST of the day of t.
+func date(t time.Time) time.Time {
+	t = t.In(market.KST)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, market.KST)
+}
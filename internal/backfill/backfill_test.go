@@ -0,0 +1,190 @@
+package backfill
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+)
+
+// fakeHistory serves a candle for every trading day from listed on, at most
+// pageSize per request, and fails the requests listed in failing.
+type fakeHistory struct {
+	cal      *market.Calendar
+	listed   time.Time
+	pageSize int
+	requests int
+	failing  map[int]bool
+}
+
+func (f *fakeHistory) GetDailyCandlesBetween(stockCode string, from, to time.Time) ([]candle.Candle, error) {
+	f.requests++
+	if f.failing[f.requests] {
+		return nil, errors.New("초당 거래건수를 초과하였습니다")
+	}
+	var page []candle.Candle
+	for day := date(to); !day.Before(date(from)) && !day.Before(f.listed) && len(page) < f.pageSize; day = day.AddDate(0, 0, -1) {
+		if _, ok := f.cal.SessionOn(day); ok {
+			page = append([]candle.Candle{{Symbol: stockCode, Start: day, Timeframe: 24 * time.Hour, Close: 70000}}, page...)
+		}
+	}
+	return page, nil
+}
+
+type fakeStore map[string]candle.Candle
+
+func (s fakeStore) DailyCandleDates(stockCode string, from, to time.Time) ([]time.Time, error) {
+	var dates []time.Time
+	for day := date(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if _, ok := s[day.Format("2006-01-02")]; ok {
+			dates = append(dates, day)
+		}
+	}
+	return dates, nil
+}
+
+func (s fakeStore) SaveDailyCandles(candles []candle.Candle) error {
+	for _, c := range candles {
+		s[c.Start.Format("2006-01-02")] = c
+	}
+	return nil
+}
+
+func newBackfiller(t *testing.T, source Source, store Store) (*Backfiller, *clock.Simulated) {
+	cal, err := market.NewCalendar(config.MarketConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 18, 0, 0, 0, market.KST))
+	b := New(source, store, cal)
+	b.SetClock(clk)
+	b.Interval = 500 * time.Millisecond
+	return b, clk
+}
+
+func TestRunFillsGapsInPages(t *testing.T) {
+	cal, _ := market.NewCalendar(config.MarketConfig{})
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, market.KST)
+	to := time.Date(2025, 12, 31, 0, 0, 0, 0, market.KST)
+	source := &fakeHistory{cal: cal, listed: time.Date(2025, 3, 1, 0, 0, 0, 0, market.KST), pageSize: 100}
+	store := fakeStore{}
+	// July is already stored.
+	july, _ := source.GetDailyCandlesBetween("005930", time.Date(2025, 7, 1, 0, 0, 0, 0, market.KST), time.Date(2025, 7, 31, 0, 0, 0, 0, market.KST))
+	store.SaveDailyCandles(july)
+	source.requests = 0
+
+	b, clk := newBackfiller(t, source, store)
+	start := clk.Now()
+	var progress []Progress
+	b.Progress = func(p Progress) { progress = append(progress, p) }
+	result, err := b.Run("005930", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing, _ := b.Gaps("005930", from, to)
+	for _, day := range missing {
+		if !day.Before(source.listed) {
+			t.Fatalf("%s still missing after the backfill", day.Format("2006-01-02"))
+		}
+	}
+	if result.Missing != len(missing) || result.Missing == 0 {
+		t.Errorf("result.Missing = %d, want the %d days before the listing", result.Missing, len(missing))
+	}
+	// August to December takes two pages; January to June one reaching back
+	// to the listing and one finding nothing before it.
+	if result.Requests != 4 || source.requests != 4 {
+		t.Errorf("requests = %d (source saw %d), want 4", result.Requests, source.requests)
+	}
+	if waited := clk.Now().Sub(start); waited != 3*b.Interval {
+		t.Errorf("waited %v between 4 requests, want %v", waited, 3*b.Interval)
+	}
+	last := progress[len(progress)-1]
+	if last.Done != last.Total || last.Total != result.Saved+result.Missing {
+		t.Errorf("last progress = %+v, want all of %d days done", last, result.Saved+result.Missing)
+	}
+
+	// Everything after the listing is stored, so a rerun only looks before it.
+	source.requests = 0
+	if result, err := b.Run("005930", from, to); err != nil || result.Saved != 0 || source.requests != 1 {
+		t.Errorf("rerun = %+v, %v with %d requests; want one empty request", result, err, source.requests)
+	}
+}
+
+func TestRunRetriesAndResumes(t *testing.T) {
+	cal, _ := market.NewCalendar(config.MarketConfig{})
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, market.KST)
+	to := time.Date(2025, 12, 31, 0, 0, 0, 0, market.KST)
+	source := &fakeHistory{cal: cal, listed: from, pageSize: 100, failing: map[int]bool{2: true, 3: true, 5: true, 6: true, 7: true, 8: true}}
+	store := fakeStore{}
+	b, clk := newBackfiller(t, source, store)
+	b.Retries = 3
+
+	// The second page succeeds on its third attempt, after waits of one and
+	// two seconds; the third page fails for good.
+	start := clk.Now()
+	result, err := b.Run("005930", from, to)
+	if err == nil {
+		t.Fatal("Run succeeded although a page failed four times")
+	}
+	if result.Saved != 200 || len(store) != 200 {
+		t.Errorf("saved %d (stored %d), want the 2 pages before the failure", result.Saved, len(store))
+	}
+	if waited := clk.Now().Sub(start); waited < 3*time.Second {
+		t.Errorf("waited %v, want the retry delays of at least 3s", waited)
+	}
+
+	result, err = b.Run("005930", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Missing != 0 || result.Saved+200 != len(store) {
+		t.Errorf("resumed run = %+v with %d stored, want the rest of the year", result, len(store))
+	}
+}
+
+// GetMinuteCandles serves the 1m candles of the current day's session, up to
+// 30 per request.
+func (f *fakeHistory) GetMinuteCandles(stockCode string, until time.Time) ([]candle.Candle, error) {
+	f.requests++
+	session, _ := f.cal.SessionOn(until)
+	var page []candle.Candle
+	for m := until.Truncate(time.Minute); !m.Before(session.Open) && len(page) < 30; m = m.Add(-time.Minute) {
+		page = append([]candle.Candle{{Symbol: stockCode, Start: m, Timeframe: time.Minute, Close: 70000}}, page...)
+	}
+	return page, nil
+}
+
+func TestMissedSinceLastRun(t *testing.T) {
+	cal, _ := market.NewCalendar(config.MarketConfig{})
+	source := &fakeHistory{cal: cal, listed: time.Date(2025, 1, 1, 0, 0, 0, 0, market.KST), pageSize: 100}
+	b, _ := newBackfiller(t, source, fakeStore{})
+
+	// Stopped after the 15:00 candle of Thursday, restarted at 10:00 on
+	// Friday: the end of Thursday's session is lost, Friday's morning is not.
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	missed, err := b.Missed("005930", 5*time.Minute, time.Date(2026, 10, 15, 15, 0, 0, 0, market.KST), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missed) != 61 || !missed[0].Start.Equal(time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)) || !missed[60].Start.Equal(now) {
+		t.Errorf("got %d minute candles from %v to %v, want 61 from 09:00 to 10:00", len(missed), missed[0].Start, missed[len(missed)-1].Start)
+	}
+	if source.requests != 3 {
+		t.Errorf("%d requests, want 3 pages of up to 30", source.requests)
+	}
+
+	// Daily candles are paged back to the day after the last one.
+	source.requests = 0
+	missed, err = b.Missed("005930", 24*time.Hour, time.Date(2025, 6, 2, 0, 0, 0, 0, market.KST), time.Date(2025, 12, 31, 16, 0, 0, 0, market.KST))
+	if err != nil {
+		t.Fatal(err)
+	}
+	days, _ := b.Gaps("005930", time.Date(2025, 6, 2, 0, 0, 0, 0, market.KST), time.Date(2025, 12, 31, 0, 0, 0, 0, market.KST))
+	if len(missed) != len(days) || source.requests != 2 {
+		t.Errorf("got %d daily candles in %d requests, want %d in 2", len(missed), source.requests, len(days))
+	}
+}
@@ -0,0 +1,99 @@
+package backfill
+
+import (
+	"fmt"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/market"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MinuteSource is implemented by sources of intraday history, e.g. the KIS
+// client, which keeps the 1m candles of the current day only. It returns the
+// latest candles up to the minute of until it can in one request, oldest
+// first.
+type MinuteSource interface {
+	GetMinuteCandles(stockCode string, until time.Time) ([]candle.Candle, error)
+}
+
+// Missed returns the candles a bot polling symbol for candles of timeframe
+// missed from since, the end of the last candle it processed, until now,
+// oldest first: daily candles for daily timeframes and 1m candles for shorter
+// ones, which the caller aggregates. Minute candles of sessions before today's
+// cannot be recovered; that part of the gap is logged and skipped.
+func (b *Backfiller) Missed(symbol string, timeframe time.Duration, since, now time.Time) ([]candle.Candle, error) {
+	if timeframe >= 24*time.Hour {
+		return b.missedDays(symbol, since, now)
+	}
+	minutes, ok := b.source.(MinuteSource)
+	if !ok {
+		return nil, fmt.Errorf("no intraday history to fill the gap since %s", since.Format(time.RFC3339))
+	}
+
+	session, ok := b.cal.SessionOn(now)
+	if !ok || now.Before(session.Open) {
+		b.logLost(symbol, since, now)
+		return nil, nil
+	}
+	from := since
+	if from.Before(session.Open) {
+		b.logLost(symbol, since, session.Open)
+		from = session.Open
+	}
+
+	until := now
+	if until.After(session.Close) {
+		until = session.Close
+	}
+	var candles []candle.Candle
+	for until.After(from) {
+		page, err := b.request(symbol, func() ([]candle.Candle, error) { return minutes.GetMinuteCandles(symbol, until) })
+		if err != nil {
+			return nil, fmt.Errorf("failed to get minute candles of %s up to %s: %v", symbol, until.Format("15:04"), err)
+		}
+		var kept []candle.Candle
+		for _, c := range page {
+			if !c.Start.Before(from) && c.Start.Before(until.Add(time.Minute)) {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == 0 {
+			break
+		}
+		candles = append(kept, candles...)
+		until = kept[0].Start.Add(-time.Second)
+	}
+	return candles, nil
+}
+
+// missedDays pages back through the daily candles from now to since.
+func (b *Backfiller) missedDays(symbol string, since, now time.Time) ([]candle.Candle, error) {
+	first := date(since)
+	end := now
+	var candles []candle.Candle
+	for !end.Before(first) {
+		page, err := b.fetch(symbol, first, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get candles of %s up to %s: %v", symbol, end.Format("2006-01-02"), err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		candles = append(page, candles...)
+		end = date(page[0].Start).AddDate(0, 0, -1)
+	}
+	return candles, nil
+}
+
+// logLost warns about the sessions from from to to whose minute candles are
+// no longer available.
+func (b *Backfiller) logLost(symbol string, from, to time.Time) {
+	for day := date(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if session, ok := b.cal.SessionOn(day); ok && session.Close.After(from) && session.Open.Before(to) {
+			log.WithFields(logrus.Fields{"symbol": symbol, "from": from.In(market.KST), "to": to.In(market.KST)}).
+				Warn("Candles missed while stopped cannot be recovered: KIS keeps minute candles of the current day only")
+			return
+		}
+	}
+}
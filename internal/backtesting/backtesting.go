@@ -2,8 +2,15 @@ package backtesting
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"strconv"
+	"strings"
 	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/hedge"
+	"tradingbot/internal/market"
 	"tradingbot/internal/models"
 	"tradingbot/internal/strategy"
 )
@@ -16,8 +23,72 @@ type BacktestResult struct {
 	MaxDrawdown           float64
 	WinRate               float64
 	AverageProfitPerTrade float64
-	StartDate             time.Time
-	EndDate               time.Time
+	// AverageWin and AverageLoss are the average return in percent of the
+	// winning trades and the average loss in percent of the others.
+	AverageWin  float64
+	AverageLoss float64
+	// StartDate and EndDate are the days, at midnight KST, the data is taken
+	// to span: the bars end on the day of the clock.
+	StartDate time.Time
+	EndDate   time.Time
+	// SweepIncome is the net return of the cash sweep, included in TotalProfit.
+	SweepIncome float64
+	// StopExits and TargetExits count the positions closed by the stop loss
+	// and the take-profit target.
+	StopExits   int
+	TargetExits int
+	// Seed is the seed of the run's randomness.
+	Seed int64
+	// InterestCost is the interest paid on margin loans, included in
+	// TotalProfit.
+	InterestCost float64
+	// HedgeProfit is the return of the portfolio hedge after commission,
+	// included in TotalProfit, and HedgedBars the number of bars it was on.
+	HedgeProfit float64
+	HedgedBars  int
+	// DividendIncome is the cash dividends earned after tax, included in
+	// TotalProfit. It is kept apart and not reinvested.
+	DividendIncome float64
+	// Rebalances counts the rebalances of a RebalanceBacktester run, and
+	// PositionsCapped, SectorsCapped and TurnoverCapped those the position
+	// limit, a sector cap and the turnover limit changed. SkippedTrades counts
+	// the trades below the minimum trade value.
+	Rebalances      int
+	PositionsCapped int
+	SectorsCapped   int
+	TurnoverCapped  int
+	SkippedTrades   int
+	// SharpeRatio is the annualized mean over the standard deviation of the
+	// bar-to-bar returns of the equity, without a risk-free rate.
+	SharpeRatio float64
+}
+
+// Metrics returns the result's figures by name, as stored with a backtest run.
+func (r BacktestResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"total_profit":         r.TotalProfit,
+		"total_trades":         float64(r.TotalTrades),
+		"winning_trades":       float64(r.WinningTrades),
+		"losing_trades":        float64(r.LosingTrades),
+		"win_rate":             r.WinRate,
+		"max_drawdown":         r.MaxDrawdown,
+		"avg_profit_per_trade": r.AverageProfitPerTrade,
+		"avg_win":              r.AverageWin,
+		"avg_loss":             r.AverageLoss,
+		"sweep_income":         r.SweepIncome,
+		"stop_exits":           float64(r.StopExits),
+		"target_exits":         float64(r.TargetExits),
+		"interest_cost":        r.InterestCost,
+		"hedge_profit":         r.HedgeProfit,
+		"hedged_bars":          float64(r.HedgedBars),
+		"dividend_income":      r.DividendIncome,
+		"rebalances":           float64(r.Rebalances),
+		"positions_capped":     float64(r.PositionsCapped),
+		"sectors_capped":       float64(r.SectorsCapped),
+		"turnover_capped":      float64(r.TurnoverCapped),
+		"skipped_trades":       float64(r.SkippedTrades),
+		"sharpe_ratio":         r.SharpeRatio,
+	}
 }
 
 type Backtester struct {
@@ -25,14 +96,66 @@ type Backtester struct {
 	Data           []models.MarketData
 	InitialBalance float64
 	CommissionRate float64
+	Clock          clock.Clock
+	// Bars, when set, streams the bars instead of Data, e.g. minute candles
+	// loaded from the database a chunk at a time, so that memory does not
+	// grow with the length of the history. The result's dates are then those
+	// of the first and last bars after the warmup.
+	Bars Bars
+	// SweepYield is the annual return of the money-market ETF that cash is
+	// parked in while no position is open; zero disables the cash sweep. Every
+	// move into and out of the ETF pays CommissionRate.
+	SweepYield float64
+	// OrderNotional is the KRW spent on each buy, rounded down to whole shares
+	// including commission; cash left over stays in the balance. Zero spends
+	// the whole balance on fractional shares.
+	OrderNotional float64
+	// StopLoss and TakeProfit close a position when the price falls or rises
+	// by that fraction of the entry price; zero disables them. Intrabar is one
+	// of the config.Intrabar* modes and decides how bars are evaluated.
+	StopLoss   float64
+	TakeProfit float64
+	Intrabar   string
+	// Slippage is the largest adverse price move of a fill, as a fraction of
+	// the price; every fill slips by a random part of it. Seed seeds that
+	// randomness, and zero picks a seed from the clock. The seed used is
+	// recorded in the result, so any run can be repeated exactly.
+	Slippage float64
+	Seed     int64
+	// MarginRequirement, when set, buys on margin: only that fraction of a
+	// position is paid from the balance and the rest is borrowed, paying
+	// MarginInterest a year until the position is closed.
+	MarginRequirement float64
+	MarginInterest    float64
+	// Hedger, when set, hedges the open position: while it is active, its
+	// target exposure is held short in the index whose daily closes are
+	// HedgeIndex, aligned with Data. Bars without an index close use the
+	// traded symbol's. Every change of the hedge pays CommissionRate.
+	Hedger     *hedge.Hedger
+	HedgeIndex []float64
+	// Dividends holds the cash dividend per share of each bar that is an
+	// ex-dividend date, aligned with Data. A position held into such a bar
+	// earns it, less DividendTax withheld.
+	Dividends   []float64
+	DividendTax float64
+	// Warmup is the number of leading bars that only prime the strategy: their
+	// signals are not traded and the result covers the bars after them.
+	Warmup int
+	// Err is set by Run when reading Bars failed; the result then covers
+	// the bars read before.
+	Err error
 }
 
+// tradingDaysPerYear converts SweepYield to a per-bar return; data is daily.
+const tradingDaysPerYear = 252
+
 func NewBacktester(strat strategy.Strategy, data []models.MarketData, initialBalance, commissionRate float64) *Backtester {
 	return &Backtester{
 		Strategy:       strat,
 		Data:           data,
 		InitialBalance: initialBalance,
 		CommissionRate: commissionRate,
+		Clock:          clock.Real,
 	}
 }
 
@@ -41,40 +164,157 @@ func (b *Backtester) Run() BacktestResult {
 	balance := b.InitialBalance
 	position := 0.0
 	entryPrice := 0.0
-	result := BacktestResult{
-		StartDate: time.Now().AddDate(0, 0, -len(b.Data)),
-		EndDate:   time.Now(),
+	entryCost := 0.0
+	// loan is borrowed for the open position and interest accrued on it.
+	loan, interest := 0.0, 0.0
+	interestRate := b.MarginInterest / tradingDaysPerYear
+	now := b.Clock.Now()
+	result := BacktestResult{}
+	bars := b.Bars
+	if bars == nil {
+		bars = SliceBars(b.Data)
 	}
+	// traded counts the bars after the warmup, first and last is when the
+	// first and last of them were current and lastPrice is the last's.
+	traded := 0
+	var first, last time.Time
+	lastPrice := ""
 	maxBalance := balance
+	// prevBalance and the sums of the equity's returns give the Sharpe ratio.
+	prevBalance := balance
+	var returns, sumReturns, sumSquares float64
+	parked := false
+	sweepRate := math.Pow(1+b.SweepYield, 1.0/tradingDaysPerYear) - 1
+
+	result.Seed = b.Seed
+	if result.Seed == 0 {
+		result.Seed = now.UnixNano()
+	}
+	rng := rand.New(rand.NewSource(result.Seed))
+	// slip moves price against the trade, up for buys (side 1) and down for
+	// sells (side -1), by a random part of Slippage.
+	slip := func(price, side float64) float64 {
+		if b.Slippage <= 0 {
+			return price
+		}
+		return price * (1 + side*rng.Float64()*b.Slippage)
+	}
+
+	// hedged is the KRW exposure held short against the position, at the
+	// index close prevIndex.
+	hedged, prevIndex := 0.0, 0.0
 
-	for _, data := range b.Data {
+	for i := 0; ; i++ {
+		data, ok := bars.Next()
+		if !ok {
+			break
+		}
 		signal := b.Strategy.Analyze(&data)
+		if i < b.Warmup {
+			continue
+		}
+		traded++
+		if first.IsZero() {
+			first = data.QuoteTime
+		}
+		last, lastPrice = data.QuoteTime, data.StckPrpr
 		currentPrice, err := parsePrice(data.StckPrpr)
 		if err != nil {
 			fmt.Printf("Warning: %v\n", err)
 			continue
 		}
+		index := currentPrice
+		if i < len(b.HedgeIndex) && b.HedgeIndex[i] > 0 {
+			index = b.HedgeIndex[i]
+		}
+		if hedged > 0 && prevIndex > 0 {
+			result.HedgeProfit -= hedged * (index/prevIndex - 1)
+			result.HedgedBars++
+		}
+		prevIndex = index
+
+		if position > 0 && i < len(b.Dividends) && b.Dividends[i] > 0 {
+			result.DividendIncome += position * b.Dividends[i] * (1 - b.DividendTax)
+		}
+
+		sell := func(price float64) {
+			price = slip(price, -1)
+			if b.OrderNotional > 0 {
+				balance += b.sellNotional(position, price, entryPrice, entryCost, interest, &result) - loan
+			} else {
+				balance = b.executeSell(position, price)
+				balance = b.closePosition(price, entryPrice, interest, &result)
+			}
+			position = 0
+			entryPrice = 0
+			loan, interest = 0, 0
+		}
+
+		if position > 0 {
+			if price, exit := b.protectiveExit(data, currentPrice, entryPrice, &result); exit {
+				sell(price)
+				// The signal of the bar that closed the position is not acted on.
+				signal.Type = models.HoldSignal
+			}
+		}
 
 		switch signal.Type {
 		case models.BuySignal:
 			if position == 0 {
-				position, balance = b.executeBuy(balance, currentPrice)
-				entryPrice = currentPrice
-				result.TotalTrades++
+				if parked {
+					balance = b.sweepFee(balance, &result)
+					parked = false
+				}
+				price := slip(currentPrice, 1)
+				if b.OrderNotional > 0 {
+					position, entryCost = b.buyNotional(balance, price)
+					loan = entryCost * (1 - 1/b.leverage())
+					balance -= entryCost - loan
+				} else {
+					loan = balance * (b.leverage() - 1)
+					position, balance = b.executeBuy(balance, price)
+				}
+				if position > 0 {
+					entryPrice = price
+					result.TotalTrades++
+				}
 			}
 		case models.SellSignal:
 			if position > 0 {
-				balance = b.executeSell(position, currentPrice)
-				balance = b.closePosition(currentPrice, entryPrice, &result)
-				position = 0
-				entryPrice = 0
+				sell(currentPrice)
 			}
 		}
 
-		currentBalance := balance
-		if position > 0 {
-			currentBalance = position * currentPrice
+		if position == 0 && b.SweepYield != 0 {
+			if !parked {
+				balance = b.sweepFee(balance, &result)
+				parked = true
+			}
+			income := balance * sweepRate
+			balance += income
+			result.SweepIncome += income
+		}
+
+		if position > 0 && loan > 0 {
+			charge := loan * interestRate
+			interest += charge
+			result.InterestCost += charge
+		}
+
+		currentBalance := balance + position*currentPrice - loan - interest + result.HedgeProfit + result.DividendIncome
+		if b.Hedger != nil {
+			b.Hedger.Observe(currentBalance, index)
+			target := b.Hedger.Target(position * currentPrice)
+			result.HedgeProfit -= math.Abs(target-hedged) * b.CommissionRate
+			hedged = target
 		}
+		if prevBalance > 0 {
+			r := currentBalance/prevBalance - 1
+			returns++
+			sumReturns += r
+			sumSquares += r * r
+		}
+		prevBalance = currentBalance
 		if currentBalance > maxBalance {
 			maxBalance = currentBalance
 		}
@@ -84,51 +324,191 @@ func (b *Backtester) Run() BacktestResult {
 		}
 	}
 
+	b.Err = bars.Err()
+	result.StartDate, result.EndDate = dataDates(now, traded)
+	if b.Bars != nil && !first.IsZero() {
+		result.StartDate, result.EndDate = barDay(first), barDay(last)
+	}
+
 	// 마지막 포지션 청산
 	if position > 0 {
-		finalPrice, err := parsePrice(b.Data[len(b.Data)-1].StckPrpr)
-		if err == nil {
-			balance = b.closePosition(finalPrice, entryPrice, &result)
-		} else {
+		finalPrice, err := parsePrice(lastPrice)
+		if err != nil {
 			fmt.Printf("Warning: %v\n", err)
+		} else if b.OrderNotional > 0 {
+			balance += b.sellNotional(position, slip(finalPrice, -1), entryPrice, entryCost, interest, &result) - loan
+		} else {
+			balance = b.closePosition(slip(finalPrice, -1), entryPrice, interest, &result)
 		}
 	}
 
+	result.TotalProfit += result.SweepIncome + result.HedgeProfit + result.DividendIncome
+
 	if result.TotalTrades > 0 {
 		result.WinRate = float64(result.WinningTrades) / float64(result.TotalTrades)
 		result.AverageProfitPerTrade /= float64(result.TotalTrades)
 	}
+	if result.WinningTrades > 0 {
+		result.AverageWin /= float64(result.WinningTrades)
+	}
+	if result.LosingTrades > 0 {
+		result.AverageLoss /= float64(result.LosingTrades)
+	}
+	if returns > 1 {
+		mean := sumReturns / returns
+		if variance := (sumSquares - returns*mean*mean) / (returns - 1); variance > 0 {
+			result.SharpeRatio = mean / math.Sqrt(variance) * math.Sqrt(tradingDaysPerYear)
+		}
+	}
 
 	return result
 }
 
+// parsePrice parses a price of the market data. Prices exported from the web
+// carry thousands separators and may be padded, which is accepted; NaN,
+// infinities and negative prices are not.
 func parsePrice(priceStr string) (float64, error) {
-	price, err := strconv.ParseFloat(priceStr, 64)
+	price, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(priceStr), ",", ""), 64)
 	if err != nil {
 		return 0, fmt.Errorf("failed to parse price: %v", err)
 	}
+	if math.IsNaN(price) || math.IsInf(price, 0) || price < 0 {
+		return 0, fmt.Errorf("invalid price %q", priceStr)
+	}
 	return price, nil
 }
 
-func (b *Backtester) closePosition(finalPrice, entryPrice float64, result *BacktestResult) float64 {
-	balance := b.InitialBalance * finalPrice / entryPrice
-	profit := balance - b.InitialBalance
+// closePosition returns the balance after closing a position entered with the
+// whole initial balance, levered on margin and less the interest paid.
+func (b *Backtester) closePosition(finalPrice, entryPrice, interest float64, result *BacktestResult) float64 {
+	balance := b.InitialBalance*(1+b.leverage()*(finalPrice/entryPrice-1)) - interest
+	b.recordTrade(balance-b.InitialBalance, finalPrice, entryPrice, result)
+	return balance
+}
+
+// leverage is the position bought per unit of balance: 1 without margin.
+func (b *Backtester) leverage() float64 {
+	if b.MarginRequirement <= 0 {
+		return 1
+	}
+	return 1 / b.MarginRequirement
+}
+
+func (b *Backtester) recordTrade(profit, exitPrice, entryPrice float64, result *BacktestResult) {
 	result.TotalProfit += profit
 	result.TotalTrades++
+	r := (exitPrice - entryPrice) / entryPrice * 100
 	if profit > 0 {
 		result.WinningTrades++
+		result.AverageWin += r
 	} else {
 		result.LosingTrades++
+		result.AverageLoss -= r
 	}
-	result.AverageProfitPerTrade += (finalPrice - entryPrice) / entryPrice * 100
-	return balance
+	result.AverageProfitPerTrade += r
+}
+
+// buyNotional returns the whole shares OrderNotional buys at price, capped at
+// what balance pays for on margin, and what they cost including commission.
+func (b *Backtester) buyNotional(balance, price float64) (float64, float64) {
+	spend := math.Min(b.OrderNotional, balance*b.leverage())
+	shares := math.Floor(spend / (price * (1 + b.CommissionRate)))
+	return shares, shares * price * (1 + b.CommissionRate)
+}
+
+// sellNotional closes a position bought with buyNotional, records the trade and
+// returns the proceeds after commission and interest.
+func (b *Backtester) sellNotional(position, price, entryPrice, entryCost, interest float64, result *BacktestResult) float64 {
+	proceeds := position*price*(1-b.CommissionRate) - interest
+	b.recordTrade(proceeds-entryCost, price, entryPrice, result)
+	return proceeds
+}
+
+// protectiveExit reports whether the stop loss or the take-profit target of a
+// position entered at entryPrice is hit during bar, and at what price. With
+// config.IntrabarClose only the close is checked and exits are at the close.
+// Otherwise a bar that opens beyond a level exits at the open, and one whose
+// low or high reaches a level exits at the level; when a bar reaches both, the
+// pessimistic mode assumes the stop was hit first and the optimistic mode the
+// target. Bars without an open, high and low fall back to the close.
+func (b *Backtester) protectiveExit(bar models.MarketData, closePrice, entryPrice float64, result *BacktestResult) (float64, bool) {
+	if b.StopLoss <= 0 && b.TakeProfit <= 0 {
+		return 0, false
+	}
+	stop := math.Inf(-1)
+	if b.StopLoss > 0 {
+		stop = entryPrice * (1 - b.StopLoss)
+	}
+	target := math.Inf(1)
+	if b.TakeProfit > 0 {
+		target = entryPrice * (1 + b.TakeProfit)
+	}
+
+	open, high, low := closePrice, closePrice, closePrice
+	if b.Intrabar != config.IntrabarClose {
+		open = barPrice(bar.StckOprc, closePrice)
+		high = barPrice(bar.StckHgpr, closePrice)
+		low = barPrice(bar.StckLwpr, closePrice)
+	}
+	switch {
+	case open <= stop:
+		result.StopExits++
+		return open, true
+	case open >= target:
+		result.TargetExits++
+		return open, true
+	}
+	hitStop, hitTarget := low <= stop, high >= target
+	if hitStop && hitTarget {
+		hitStop = b.Intrabar != config.IntrabarOptimistic
+		hitTarget = !hitStop
+	}
+	switch {
+	case hitStop:
+		result.StopExits++
+		return stop, true
+	case hitTarget:
+		result.TargetExits++
+		return target, true
+	}
+	return 0, false
+}
+
+// barPrice parses an optional price of a bar, falling back to fallback.
+func barPrice(s string, fallback float64) float64 {
+	if price, err := strconv.ParseFloat(s, 64); err == nil && price > 0 {
+		return price
+	}
+	return fallback
+}
+
+// sweepFee charges the commission of moving balance into or out of the sweep ETF.
+func (b *Backtester) sweepFee(balance float64, result *BacktestResult) float64 {
+	fee := balance * b.CommissionRate
+	result.SweepIncome -= fee
+	return balance - fee
 }
 
 func (b *Backtester) executeBuy(balance, currentPrice float64) (float64, float64) {
-	position := (balance * (1 - b.CommissionRate)) / currentPrice
+	position := (balance * b.leverage() * (1 - b.CommissionRate)) / currentPrice
 	return position, 0 // 포지션을 열고, 잔고를 0으로 설정
 }
 
 func (b *Backtester) executeSell(position, currentPrice float64) float64 {
 	return position * currentPrice * (1 - b.CommissionRate) // 포지션을 닫고 잔고 갱신
 }
+
+// dataDates returns the days, at midnight KST, of n daily bars ending on the
+// day of now.
+func dataDates(now time.Time, n int) (time.Time, time.Time) {
+	local := now.In(market.KST)
+	end := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, market.KST)
+	return end.AddDate(0, 0, -n), end
+}
+
+// barDay returns the day, at midnight KST, of the bar that ended at end; a
+// daily bar ends at midnight of the next day.
+func barDay(end time.Time) time.Time {
+	local := end.Add(-time.Nanosecond).In(market.KST)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, market.KST)
+}
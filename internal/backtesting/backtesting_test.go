@@ -4,9 +4,17 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
 	"tradingbot/internal/config"
+	"tradingbot/internal/hedge"
+	"tradingbot/internal/market"
 	"tradingbot/internal/models"
 	"tradingbot/internal/strategy"
 )
@@ -40,8 +48,8 @@ func TestBacktestingWithMinuteData(t *testing.T) {
 
 	// 헤더 설정
 	req.Header.Set("Authorization", "Bearer "+cfg.Exchange.AccessToken)
-	req.Header.Set("appkey", cfg.Exchange.APIKey)
-	req.Header.Set("appsecret", cfg.Exchange.APISecret)
+	req.Header.Set("appkey", cfg.Exchange.AppKey)
+	req.Header.Set("appsecret", cfg.Exchange.AppSecret)
 	req.Header.Set("tr_id", "FHKST03010200")
 	req.Header.Set("custtype", "P")
 
@@ -89,7 +97,14 @@ func TestBacktestingWithMinuteData(t *testing.T) {
 	log.Printf("Retrieved %d minute data points", len(output2))
 
 	// 전략 테스트
-	strat := strategy.NewMovingAverage(cfg.Strategy)
+	params, err := cfg.StrategyParamsFor(cfg.Strategy)
+	if err != nil {
+		t.Fatalf("Failed to get strategy params: %v", err)
+	}
+	strat, err := strategy.New(cfg.Strategy, params)
+	if err != nil {
+		t.Fatalf("Failed to create strategy: %v", err)
+	}
 	totalTrades := 0
 	for _, data := range output2 {
 		dataMap := data.(map[string]interface{})
@@ -108,3 +123,354 @@ func TestBacktestingWithMinuteData(t *testing.T) {
 	}
 
 }
+
+type holdStrategy struct{}
+
+func (holdStrategy) Analyze(*models.MarketData) *models.Signal {
+	return &models.Signal{Type: models.HoldSignal}
+}
+
+func TestCashSweepEarnsYieldWhileFlat(t *testing.T) {
+	data := make([]models.MarketData, 252)
+	for i := range data {
+		data[i] = models.MarketData{StckPrpr: "10000"}
+	}
+
+	bt := NewBacktester(holdStrategy{}, data, 10000000, 0.001)
+	if result := bt.Run(); result.TotalProfit != 0 || result.SweepIncome != 0 {
+		t.Fatalf("without a sweep: profit %g, sweep income %g; want 0", result.TotalProfit, result.SweepIncome)
+	}
+
+	bt.SweepYield = 0.03
+	result := bt.Run()
+	// A year of 3% on the balance left after the 0.1% commission to buy the ETF.
+	want := 10000000*0.999*1.03 - 10000000
+	if math.Abs(result.SweepIncome-want) > 1 || result.TotalProfit != result.SweepIncome {
+		t.Errorf("sweep income %g, total profit %g; want %g", result.SweepIncome, result.TotalProfit, want)
+	}
+}
+
+type scriptedStrategy []models.SignalType
+
+func (s *scriptedStrategy) Analyze(*models.MarketData) *models.Signal {
+	signal := &models.Signal{Type: (*s)[0]}
+	*s = (*s)[1:]
+	return signal
+}
+
+func TestOrderNotionalBuysWholeShares(t *testing.T) {
+	data := []models.MarketData{{StckPrpr: "10000"}, {StckPrpr: "12000"}}
+	strat := scriptedStrategy{models.BuySignal, models.SellSignal}
+
+	bt := NewBacktester(&strat, data, 10000000, 0.001)
+	bt.OrderNotional = 1000000
+	result := bt.Run()
+
+	// 99 shares at 10,010 including commission, sold at 12,000 less commission.
+	want := 99*12000*0.999 - 99*10010.0
+	if math.Abs(result.TotalProfit-want) > 0.01 {
+		t.Errorf("total profit %g, want %g", result.TotalProfit, want)
+	}
+	if result.WinningTrades != 1 {
+		t.Errorf("winning trades %d, want 1", result.WinningTrades)
+	}
+}
+
+func TestWarmupBarsOnlyPrimeStrategy(t *testing.T) {
+	data := []models.MarketData{{StckPrpr: "10000"}, {StckPrpr: "11000"}, {StckPrpr: "12000"}}
+	strat := scriptedStrategy{models.BuySignal, models.BuySignal, models.SellSignal}
+
+	bt := NewBacktester(&strat, data, 1100000, 0)
+	bt.OrderNotional = 1100000
+	bt.Warmup = 1
+	result := bt.Run()
+
+	// The buy of the warm-up bar is ignored; 100 shares are bought at 11,000.
+	if len(strat) != 0 || result.WinningTrades != 1 || math.Abs(result.TotalProfit-100000) > 0.01 {
+		t.Errorf("%d signals left, %d winning trades, profit %g; want 0, 1 and 100000", len(strat), result.WinningTrades, result.TotalProfit)
+	}
+}
+
+func TestDividendsPaidOnPositionsHeldOverExDate(t *testing.T) {
+	data := []models.MarketData{{StckPrpr: "10000"}, {StckPrpr: "9500"}, {StckPrpr: "9500"}, {StckPrpr: "9500"}}
+	strat := scriptedStrategy{models.BuySignal, models.HoldSignal, models.HoldSignal, models.SellSignal}
+
+	bt := NewBacktester(&strat, data, 1000000, 0)
+	bt.OrderNotional = 1000000
+	// The first dividend goes ex on the day of the buy and is not earned.
+	bt.Dividends = []float64{300, 500}
+	bt.DividendTax = 0.154
+	result := bt.Run()
+
+	// The 500 dividend on 100 shares makes up for most of the ex-dividend
+	// drop of the price.
+	if math.Abs(result.DividendIncome-42300) > 0.01 {
+		t.Errorf("dividend income %g, want 42300", result.DividendIncome)
+	}
+	if math.Abs(result.TotalProfit+7700) > 0.01 {
+		t.Errorf("total profit %g, want -7700", result.TotalProfit)
+	}
+}
+
+func TestMarginLeversAndChargesInterest(t *testing.T) {
+	data := []models.MarketData{{StckPrpr: "10000"}, {StckPrpr: "10000"}, {StckPrpr: "12000"}}
+	strat := scriptedStrategy{models.BuySignal, models.HoldSignal, models.SellSignal}
+
+	bt := NewBacktester(&strat, data, 1000000, 0.001)
+	bt.OrderNotional = 2000000
+	bt.MarginRequirement = 0.5
+	bt.MarginInterest = 0.252
+	result := bt.Run()
+
+	// Half of the balance buys 199 shares at 10,010; the other half is
+	// borrowed and pays 0.1% a bar for the two bars it is held.
+	cost := 199 * 10010.0
+	interest := 2 * cost / 2 * 0.001
+	if math.Abs(result.InterestCost-interest) > 0.01 {
+		t.Errorf("interest cost %g, want %g", result.InterestCost, interest)
+	}
+	want := 199*12000*0.999 - cost - interest
+	if math.Abs(result.TotalProfit-want) > 0.01 {
+		t.Errorf("total profit %g, want %g", result.TotalProfit, want)
+	}
+}
+
+func TestHedgeOffsetsDecline(t *testing.T) {
+	data := []models.MarketData{{StckPrpr: "10000"}, {StckPrpr: "9000"}, {StckPrpr: "8000"}, {StckPrpr: "8000"}}
+	strat := scriptedStrategy{models.BuySignal, models.HoldSignal, models.HoldSignal, models.SellSignal}
+
+	bt := NewBacktester(&strat, data, 1000000, 0)
+	bt.OrderNotional = 1000000
+	bt.Hedger = hedge.New(config.HedgerConfig{MaxDrawdown: 0.05, Ratio: 1})
+	result := bt.Run()
+
+	// The 10% drawdown hedges the 900,000 position, which then earns
+	// 100,000 as the price falls another 1,000.
+	if math.Abs(result.HedgeProfit-100000) > 0.01 || result.HedgedBars != 2 {
+		t.Errorf("hedge profit %g over %d bars, want 100000 over 2", result.HedgeProfit, result.HedgedBars)
+	}
+	if math.Abs(result.TotalProfit+100000) > 0.01 {
+		t.Errorf("total profit %g, want -100000", result.TotalProfit)
+	}
+	if result.MaxDrawdown != 0.1 {
+		t.Errorf("max drawdown %g, want 0.1", result.MaxDrawdown)
+	}
+}
+
+func TestStopsEvaluatedIntrabar(t *testing.T) {
+	bars := []models.MarketData{
+		{StckPrpr: "10000"},
+		{StckOprc: "9900", StckHgpr: "11200", StckLwpr: "9400", StckPrpr: "10500"},
+	}
+	gap := []models.MarketData{
+		{StckPrpr: "10000"},
+		{StckOprc: "9000", StckHgpr: "9200", StckLwpr: "8800", StckPrpr: "9100"},
+	}
+	tests := []struct {
+		name     string
+		data     []models.MarketData
+		intrabar string
+		profit   float64
+		stops    int
+		targets  int
+	}{
+		{"pessimistic", bars, config.IntrabarPessimistic, -50000, 1, 0},
+		{"default is pessimistic", bars, "", -50000, 1, 0},
+		{"optimistic", bars, config.IntrabarOptimistic, 100000, 0, 1},
+		{"close only", bars, config.IntrabarClose, 50000, 0, 0},
+		{"gap through the stop", gap, config.IntrabarOptimistic, -100000, 1, 0},
+	}
+	for _, tt := range tests {
+		strat := scriptedStrategy{models.BuySignal, models.HoldSignal}
+		bt := NewBacktester(&strat, tt.data, 10000000, 0)
+		bt.OrderNotional = 1000000
+		bt.StopLoss = 0.05
+		bt.TakeProfit = 0.1
+		bt.Intrabar = tt.intrabar
+
+		result := bt.Run()
+		if math.Abs(result.TotalProfit-tt.profit) > 0.01 || result.StopExits != tt.stops || result.TargetExits != tt.targets {
+			t.Errorf("%s: profit %g with %d stop and %d target exits, want %g with %d and %d",
+				tt.name, result.TotalProfit, result.StopExits, result.TargetExits, tt.profit, tt.stops, tt.targets)
+		}
+	}
+}
+
+func TestSlippageReproducibleWithSeed(t *testing.T) {
+	data := []models.MarketData{{StckPrpr: "10000"}, {StckPrpr: "11000"}, {StckPrpr: "10500"}, {StckPrpr: "12000"}}
+	run := func(seed int64) BacktestResult {
+		strat := scriptedStrategy{models.BuySignal, models.SellSignal, models.BuySignal, models.SellSignal}
+		bt := NewBacktester(&strat, data, 10000000, 0.001)
+		bt.Clock = clock.NewSimulated(time.Date(2026, 10, 16, 16, 0, 0, 0, time.UTC))
+		bt.Slippage = 0.01
+		bt.Seed = seed
+		return bt.Run()
+	}
+
+	first, again, other := run(42), run(42), run(7)
+	if first != again {
+		t.Errorf("runs with the same seed differ: %+v and %+v", first, again)
+	}
+	if first.TotalProfit == other.TotalProfit {
+		t.Error("runs with different seeds have the same profit")
+	}
+	if first.Seed != 42 {
+		t.Errorf("seed %d recorded, want 42", first.Seed)
+	}
+	if unseeded := run(0); unseeded.Seed == 0 || run(unseeded.Seed).TotalProfit != unseeded.TotalProfit {
+		t.Error("an unseeded run cannot be repeated from its recorded seed")
+	}
+}
+
+func TestRebalanceKeepsTargetWeights(t *testing.T) {
+	bars := func(prices ...string) []models.MarketData {
+		out := make([]models.MarketData, len(prices))
+		for i, p := range prices {
+			out[i] = models.MarketData{StckPrpr: p}
+		}
+		return out
+	}
+	// The extra first bar of 148070 is cut off to align the histories.
+	data := map[string][]models.MarketData{
+		"069500": bars("100", "100", "200", "200"),
+		"148070": bars("50", "100", "100", "100", "100"),
+	}
+	cfg := config.RebalanceConfig{
+		Weights: map[string]float64{"069500": 0.5, "148070": 0.5},
+		Trigger: config.RebalanceThreshold,
+		Band:    0.1,
+	}
+
+	// The cash is invested at the first bar; doubling 069500 drifts it to two
+	// thirds, so 1250 shares are sold and 2500 of 148070 bought.
+	result := NewRebalanceBacktester(cfg, data, 1000000, 0).Run()
+	if result.Rebalances != 2 || result.TotalTrades != 4 {
+		t.Errorf("%d rebalances with %d trades, want 2 with 4", result.Rebalances, result.TotalTrades)
+	}
+	if math.Abs(result.TotalProfit-500000) > 0.01 {
+		t.Errorf("total profit %g, want 500000", result.TotalProfit)
+	}
+
+	// Weekly, the drift comes within the week of the first rebalance.
+	cfg.Trigger, cfg.Period = config.RebalanceCalendar, config.RebalanceWeekly
+	if result := NewRebalanceBacktester(cfg, data, 1000000, 0).Run(); result.Rebalances != 1 {
+		t.Errorf("%d weekly rebalances, want 1", result.Rebalances)
+	}
+}
+
+func TestResultDatesAreKSTDays(t *testing.T) {
+	data := []models.MarketData{{StckPrpr: "10000"}, {StckPrpr: "10100"}, {StckPrpr: "10200"}}
+	strat := scriptedStrategy{models.HoldSignal, models.HoldSignal, models.HoldSignal}
+	bt := NewBacktester(&strat, data, 10000000, 0.001)
+	// 01:00 on October 17 in Seoul.
+	bt.Clock = clock.NewSimulated(time.Date(2026, 10, 16, 16, 0, 0, 0, time.UTC))
+	result := bt.Run()
+
+	if want := time.Date(2026, 10, 17, 0, 0, 0, 0, market.KST); !result.EndDate.Equal(want) || result.EndDate.Location() != market.KST {
+		t.Errorf("end date %s, want %s", result.EndDate, want)
+	}
+	if want := time.Date(2026, 10, 14, 0, 0, 0, 0, market.KST); !result.StartDate.Equal(want) {
+		t.Errorf("start date %s, want %s", result.StartDate, want)
+	}
+}
+
+func TestCandleStreamLoadsInChunks(t *testing.T) {
+	start := time.Date(2026, 10, 14, 9, 0, 0, 0, market.KST)
+	var stored []candle.Candle
+	for i := 0; i < 7; i++ {
+		stored = append(stored, candle.Candle{Symbol: "005930", Timeframe: time.Minute, Start: start.Add(time.Duration(i) * time.Minute), Close: float64(10000 + i)})
+	}
+	var loads []int
+	load := func(after time.Time, limit int) ([]candle.Candle, error) {
+		var out []candle.Candle
+		for _, c := range stored {
+			if c.Start.After(after) && len(out) < limit {
+				out = append(out, c)
+			}
+		}
+		loads = append(loads, len(out))
+		return out, nil
+	}
+
+	bars := CandleStream(load, start.Add(-time.Nanosecond), 3)
+	var closes []string
+	for data, ok := bars.Next(); ok; data, ok = bars.Next() {
+		closes = append(closes, data.StckPrpr)
+	}
+	if bars.Err() != nil || len(closes) != 7 || closes[0] != "10000" || closes[6] != "10006" {
+		t.Fatalf("closes %v, error %v; want 10000 to 10006", closes, bars.Err())
+	}
+	// The third chunk is short, so the stream ends without another query.
+	if len(loads) != 3 || loads[2] != 1 {
+		t.Errorf("loaded chunks of %v, want 3, 3 and 1", loads)
+	}
+}
+
+func TestCSVBarsReadsCandleExport(t *testing.T) {
+	in := `symbol,start,timeframe,open,high,low,close,volume
+005930,2026-10-14,24h0m0s,10000,10500,9900,10200,1000
+000660,2026-10-14,24h0m0s,50000,51000,49000,50500,200
+005930,2026-10-15,24h0m0s,10200,10300,10000,10100,800
+`
+	bars := CSVBars(strings.NewReader(in), "005930")
+	var got []models.MarketData
+	for data, ok := bars.Next(); ok; data, ok = bars.Next() {
+		got = append(got, data)
+	}
+	if bars.Err() != nil || len(got) != 2 {
+		t.Fatalf("%d bars, error %v; want 2", len(got), bars.Err())
+	}
+	if got[1].StckPrpr != "10100" || got[1].StckHgpr != "10300" || !got[1].QuoteTime.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, market.KST)) {
+		t.Errorf("second bar %+v, want the close of 10100 at the end of October 15", got[1])
+	}
+
+	bars = CSVBars(strings.NewReader("005930,2026-10-14,24h0m0s,10000,x,9900,10200,1000\n"), "005930")
+	if _, ok := bars.Next(); ok || bars.Err() == nil {
+		t.Error("invalid high read without an error")
+	}
+}
+
+func TestStreamedBarsMatchSlice(t *testing.T) {
+	data := []models.MarketData{{StckPrpr: "10000"}, {StckPrpr: "12000"}, {StckPrpr: "11000"}}
+	for i := range data {
+		data[i].QuoteTime = time.Date(2026, 10, 15+i, 0, 0, 0, 0, market.KST)
+	}
+	signals := []models.SignalType{models.BuySignal, models.SellSignal, models.BuySignal}
+
+	strat := scriptedStrategy(append([]models.SignalType(nil), signals...))
+	want := NewBacktester(&strat, data, 10000000, 0.001).Run()
+
+	strat = scriptedStrategy(append([]models.SignalType(nil), signals...))
+	bt := NewBacktester(&strat, nil, 10000000, 0.001)
+	bt.Bars = SliceBars(data)
+	got := bt.Run()
+
+	if got.TotalProfit != want.TotalProfit || got.TotalTrades != want.TotalTrades {
+		t.Errorf("streamed profit %g over %d trades, want %g over %d", got.TotalProfit, got.TotalTrades, want.TotalProfit, want.TotalTrades)
+	}
+	// The dates are those of the bars: the first ended at midnight of October 15.
+	if !got.StartDate.Equal(time.Date(2026, 10, 14, 0, 0, 0, 0, market.KST)) || !got.EndDate.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, market.KST)) {
+		t.Errorf("dates %s to %s, want October 14 to 16", got.StartDate, got.EndDate)
+	}
+}
+
+// FuzzParsePrice checks that parsePrice never panics, returns only finite,
+// non-negative prices and reads back the prices it formats.
+func FuzzParsePrice(f *testing.F) {
+	for _, s := range []string{"71200", "71,200", " 71200 ", "352.45", "", "-", "NaN", "-Inf", "1e309", "0x1p-2", "7１200", "71200\x00"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		price, err := parsePrice(s)
+		if err != nil {
+			return
+		}
+		if math.IsNaN(price) || math.IsInf(price, 0) || price < 0 {
+			t.Fatalf("parsePrice(%q) = %v", s, price)
+		}
+		formatted := strconv.FormatFloat(price, 'f', -1, 64)
+		if again, err := parsePrice(formatted); err != nil || again != price {
+			t.Errorf("parsePrice(%q) = %v, %v after parsing %q as %v", formatted, again, err, s, price)
+		}
+	})
+}
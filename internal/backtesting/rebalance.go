@@ -0,0 +1,105 @@
+package backtesting
+
+import (
+	"math"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+	"tradingbot/internal/rebalance"
+)
+
+// RebalanceBacktester backtests a portfolio kept at target weights, as
+// configured in config.RebalanceConfig. Data holds the daily bars of each
+// weighted symbol; the histories are aligned on their last bar and cut to the
+// shortest. Calendar periods are counted in bars, see rebalance.PeriodBars.
+// Trades fill at the close and pay CommissionRate.
+type RebalanceBacktester struct {
+	Config         config.RebalanceConfig
+	Data           map[string][]models.MarketData
+	InitialBalance float64
+	CommissionRate float64
+	Clock          clock.Clock
+	// Constraints limit every rebalance; Sectors maps the symbols to the
+	// sectors their caps apply to.
+	Constraints config.PortfolioConstraints
+	Sectors     map[string]string
+}
+
+func NewRebalanceBacktester(cfg config.RebalanceConfig, data map[string][]models.MarketData, initialBalance, commissionRate float64) *RebalanceBacktester {
+	return &RebalanceBacktester{
+		Config:         cfg,
+		Data:           data,
+		InitialBalance: initialBalance,
+		CommissionRate: commissionRate,
+		Clock:          clock.Real,
+	}
+}
+
+// Run starts from cash, which is invested at the first bar, and values the
+// portfolio at the last bar's closes without selling it.
+func (b *RebalanceBacktester) Run() BacktestResult {
+	n := -1
+	for symbol := range b.Config.Weights {
+		if n < 0 || len(b.Data[symbol]) < n {
+			n = len(b.Data[symbol])
+		}
+	}
+	result := BacktestResult{}
+	result.StartDate, result.EndDate = dataDates(b.Clock.Now(), n)
+	if n <= 0 {
+		return result
+	}
+
+	targets, static := rebalance.ConstrainTargets(b.Config.Weights, b.Constraints, b.Sectors)
+	p := rebalance.Portfolio{Cash: b.InitialBalance, Shares: make(map[string]float64), Prices: make(map[string]float64)}
+	periodBars := rebalance.PeriodBars(b.Config.Period)
+	last := -1
+	maxEquity := b.InitialBalance
+	for i := 0; i < n; i++ {
+		for symbol := range b.Config.Weights {
+			bars := b.Data[symbol]
+			p.Prices[symbol] = barPrice(bars[len(bars)-n+i].StckPrpr, p.Prices[symbol])
+		}
+
+		due := rebalance.Drift(p, targets) > b.Config.Band
+		if b.Config.Trigger == config.RebalanceCalendar && last >= 0 && i/periodBars == last/periodBars {
+			due = false
+		}
+		if due {
+			trades, binding := rebalance.ConstrainTrades(p, rebalance.Plan(p, targets, b.CommissionRate), b.Constraints, b.CommissionRate)
+			result.SkippedTrades += binding.Skipped
+			for _, t := range trades {
+				value := t.Quantity * t.Price
+				if t.Side == models.SellSignal {
+					p.Shares[t.Symbol] -= t.Quantity
+					p.Cash += value * (1 - b.CommissionRate)
+				} else {
+					p.Shares[t.Symbol] += t.Quantity
+					p.Cash -= value * (1 + b.CommissionRate)
+				}
+			}
+			if len(trades) > 0 {
+				result.TotalTrades += len(trades)
+				result.Rebalances++
+				last = i
+				if static.Positions {
+					result.PositionsCapped++
+				}
+				if static.Sectors {
+					result.SectorsCapped++
+				}
+				if binding.Turnover {
+					result.TurnoverCapped++
+				}
+			}
+		}
+
+		equity := p.Equity()
+		maxEquity = math.Max(maxEquity, equity)
+		if drawdown := (maxEquity - equity) / maxEquity; drawdown > result.MaxDrawdown {
+			result.MaxDrawdown = drawdown
+		}
+	}
+	result.TotalProfit = p.Equity() - b.InitialBalance
+	return result
+}
@@ -0,0 +1,172 @@
+package backtesting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/market"
+	"tradingbot/internal/marketdata"
+	"tradingbot/internal/models"
+)
+
+// DefaultChunk is how many candles a CandleStream loads at a time.
+const DefaultChunk = 10000
+
+// Bars is a stream of bars, oldest first. A Backtester reads it one bar at a
+// time, so that years of minute candles can be backtested without holding
+// them in memory.
+type Bars interface {
+	// Next returns the next bar, or false at the end of the stream or when
+	// reading failed, which Err then tells.
+	Next() (models.MarketData, bool)
+	Err() error
+}
+
+// SliceBars streams bars already in memory.
+func SliceBars(data []models.MarketData) Bars {
+	return &sliceBars{data: data}
+}
+
+type sliceBars struct {
+	data []models.MarketData
+	i    int
+}
+
+func (s *sliceBars) Next() (models.MarketData, bool) {
+	if s.i >= len(s.data) {
+		return models.MarketData{}, false
+	}
+	s.i++
+	return s.data[s.i-1], true
+}
+
+func (s *sliceBars) Err() error { return nil }
+
+// CandlePager loads up to limit candles starting after after, oldest first,
+// such as database.DB.ListCandlesAfter for one symbol and timeframe.
+type CandlePager func(after time.Time, limit int) ([]candle.Candle, error)
+
+// CandleStream streams the candles of load after from, chunk candles at a
+// time. Only the current chunk is held in memory.
+func CandleStream(load CandlePager, from time.Time, chunk int) Bars {
+	if chunk <= 0 {
+		chunk = DefaultChunk
+	}
+	return &candleStream{load: load, after: from, chunk: chunk}
+}
+
+type candleStream struct {
+	load  CandlePager
+	after time.Time
+	chunk int
+
+	buf  []candle.Candle
+	i    int
+	done bool
+	err  error
+}
+
+func (s *candleStream) Next() (models.MarketData, bool) {
+	if s.i == len(s.buf) {
+		if s.done {
+			return models.MarketData{}, false
+		}
+		candles, err := s.load(s.after, s.chunk)
+		if err != nil {
+			s.err, s.done = err, true
+			return models.MarketData{}, false
+		}
+		if len(candles) < s.chunk {
+			s.done = true
+		}
+		if len(candles) == 0 {
+			return models.MarketData{}, false
+		}
+		// Replacing buf lets the previous chunk be collected.
+		s.buf, s.i = candles, 0
+		s.after = candles[len(candles)-1].Start
+	}
+	s.i++
+	return marketdata.FromCandle(s.buf[s.i-1]), true
+}
+
+func (s *candleStream) Err() error { return s.err }
+
+// CSVBars streams the candles of symbol from a CSV file in the format of
+// `tradingbot export candles`: symbol, start, timeframe, open, high, low,
+// close and volume, with a header line. Rows of other symbols are skipped.
+// Rows are read one at a time, so the file can be larger than memory.
+func CSVBars(r io.Reader, symbol string) Bars {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	return &csvBars{r: cr, symbol: symbol}
+}
+
+type csvBars struct {
+	r      *csv.Reader
+	symbol string
+	header bool
+	line   int
+	err    error
+}
+
+func (c *csvBars) Next() (models.MarketData, bool) {
+	for c.err == nil {
+		record, err := c.r.Read()
+		if err == io.EOF {
+			break
+		}
+		c.line++
+		if err != nil {
+			c.err = err
+			break
+		}
+		if !c.header {
+			c.header = true
+			if len(record) > 0 && record[0] == "symbol" {
+				continue
+			}
+		}
+		if len(record) < 8 {
+			c.err = fmt.Errorf("line %d: %d columns, want 8", c.line, len(record))
+			break
+		}
+		if record[0] != c.symbol {
+			continue
+		}
+		cd, err := parseCandle(record)
+		if err != nil {
+			c.err = fmt.Errorf("line %d: %v", c.line, err)
+			break
+		}
+		return marketdata.FromCandle(cd), true
+	}
+	return models.MarketData{}, false
+}
+
+func (c *csvBars) Err() error { return c.err }
+
+// parseCandle parses a row of the candle export.
+func parseCandle(record []string) (candle.Candle, error) {
+	cd := candle.Candle{Symbol: record[0]}
+	var err error
+	layout := "2006-01-02 15:04:05"
+	if len(record[1]) == len("2006-01-02") {
+		layout = "2006-01-02"
+	}
+	if cd.Start, err = time.ParseInLocation(layout, record[1], market.KST); err != nil {
+		return cd, fmt.Errorf("invalid start %q", record[1])
+	}
+	if cd.Timeframe, err = time.ParseDuration(record[2]); err != nil {
+		return cd, fmt.Errorf("invalid timeframe %q", record[2])
+	}
+	for i, v := range []*float64{&cd.Open, &cd.High, &cd.Low, &cd.Close, &cd.Volume} {
+		if *v, err = strconv.ParseFloat(record[3+i], 64); err != nil {
+			return cd, fmt.Errorf("invalid number %q", record[3+i])
+		}
+	}
+	return cd, nil
+}
@@ -0,0 +1,187 @@
+package candle
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CandleSize is roughly the memory a cached candle takes, to size
+// config.CandleCacheConfig.MaxCandles from a memory budget.
+const CandleSize = 88
+
+// Cache keeps the recent candles of each symbol and timeframe in memory, so
+// that strategies and the API do not query the database or the exchange for
+// the same candles over and over. It holds at most maxCandles candles in
+// total and evicts the least recently used series first. Cache is safe for
+// concurrent use.
+type Cache struct {
+	maxCandles int
+
+	mu     sync.Mutex
+	series map[key]*list.Element
+	// lru holds the series, most recently used first.
+	lru  *list.List
+	size int
+}
+
+type series struct {
+	key     key
+	candles []Candle // oldest first, one per start
+	// fetched is when Put last stored candles fetched from a source.
+	fetched time.Time
+	// complete tells that the source has no candles before the first one.
+	complete bool
+}
+
+// NewCache creates a cache holding at most maxCandles candles.
+func NewCache(maxCandles int) *Cache {
+	return &Cache{maxCandles: maxCandles, series: map[key]*list.Element{}, lru: list.New()}
+}
+
+// Put stores candles of a symbol and timeframe fetched from a source at now,
+// replacing cached candles with the same start. complete tells that the
+// source has none before them, e.g. because it returned fewer than asked.
+// Candles that do not overlap the cached ones replace them all, so that a
+// series never has a gap where nothing was fetched.
+func (c *Cache) Put(symbol string, timeframe time.Duration, candles []Candle, complete bool, now time.Time) {
+	in := sorted(candles)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.get(key{symbol, timeframe})
+	switch {
+	case len(in) == 0:
+		s.complete = s.complete || complete && len(s.candles) == 0
+	case len(s.candles) == 0 || in[0].Start.After(s.candles[len(s.candles)-1].Start) || in[len(in)-1].Start.Before(s.candles[0].Start):
+		c.size -= len(s.candles)
+		s.candles, s.complete = in, complete
+		c.size += len(in)
+	default:
+		if !in[0].Start.After(s.candles[0].Start) {
+			s.complete = complete
+		}
+		c.merge(s, in)
+	}
+	s.fetched = now
+	c.evict(s)
+}
+
+// Add stores a candle observed live, such as a completed candle of the
+// trading loop, under its own symbol and timeframe.
+func (c *Cache) Add(cd Candle) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.get(key{cd.Symbol, cd.Timeframe})
+	c.merge(s, []Candle{cd})
+	c.evict(s)
+}
+
+// Last returns up to n latest cached candles of a symbol and timeframe,
+// oldest first, when they were last fetched, and whether the cache has all
+// of them: n candles, or fewer because the source has no older ones. n <= 0
+// returns all cached candles.
+func (c *Cache) Last(symbol string, timeframe time.Duration, n int) ([]Candle, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.series[key{symbol, timeframe}]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	c.lru.MoveToFront(el)
+	s := el.Value.(*series)
+	all := n <= 0 || len(s.candles) >= n || s.complete
+	if n <= 0 || n > len(s.candles) {
+		n = len(s.candles)
+	}
+	return append([]Candle(nil), s.candles[len(s.candles)-n:]...), s.fetched, all
+}
+
+// Len returns the number of cached candles.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// get returns the series of k, creating it if needed, as the most recently
+// used.
+func (c *Cache) get(k key) *series {
+	if el, ok := c.series[k]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*series)
+	}
+	s := &series{key: k}
+	c.series[k] = c.lru.PushFront(s)
+	return s
+}
+
+// merge adds sorted candles to s, replacing those with the same start.
+func (c *Cache) merge(s *series, in []Candle) {
+	old := s.candles
+	if n := len(old); n == 0 || in[0].Start.After(old[n-1].Start) {
+		s.candles = append(old, in...)
+		c.size += len(in)
+		return
+	}
+	merged := make([]Candle, 0, len(old)+len(in))
+	i, j := 0, 0
+	for i < len(old) || j < len(in) {
+		switch {
+		case j == len(in) || i < len(old) && old[i].Start.Before(in[j].Start):
+			merged = append(merged, old[i])
+			i++
+		case i == len(old) || in[j].Start.Before(old[i].Start):
+			merged = append(merged, in[j])
+			j++
+		default:
+			merged = append(merged, in[j])
+			i, j = i+1, j+1
+		}
+	}
+	c.size += len(merged) - len(old)
+	s.candles = merged
+}
+
+// evict drops the least recently used series, other than keep, until the
+// cache is within its limit, then the oldest candles of keep if it alone is
+// over it.
+func (c *Cache) evict(keep *series) {
+	for c.size > c.maxCandles {
+		el := c.lru.Back()
+		s := el.Value.(*series)
+		if s == keep {
+			break
+		}
+		c.lru.Remove(el)
+		delete(c.series, s.key)
+		c.size -= len(s.candles)
+	}
+	if over := c.size - c.maxCandles; over > 0 {
+		keep.candles = append([]Candle(nil), keep.candles[over:]...)
+		keep.complete = false
+		c.size -= over
+	}
+	if len(keep.candles) == 0 && keep.fetched.IsZero() {
+		c.lru.Remove(c.series[keep.key])
+		delete(c.series, keep.key)
+	}
+}
+
+// sorted returns a copy of candles sorted by start, keeping the last of
+// candles with the same start.
+func sorted(candles []Candle) []Candle {
+	out := append([]Candle(nil), candles...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	n := 0
+	for i, cd := range out {
+		if n > 0 && out[n-1].Start.Equal(cd.Start) {
+			out[n-1] = cd
+			continue
+		}
+		out[n] = out[i]
+		n++
+	}
+	return out[:n]
+}
@@ -0,0 +1,137 @@
+package candle
+
+import (
+	"sync"
+	"testing"
+	"time"
+	"tradingbot/internal/market"
+)
+
+const day = 24 * time.Hour
+
+func days(symbol string, from time.Time, closes ...float64) []Candle {
+	candles := make([]Candle, len(closes))
+	for i, c := range closes {
+		candles[i] = Candle{Symbol: symbol, Start: from.AddDate(0, 0, i), Timeframe: day, Close: c}
+	}
+	return candles
+}
+
+func closes(candles []Candle) []float64 {
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = c.Close
+	}
+	return out
+}
+
+func equal(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCacheMergesFetches(t *testing.T) {
+	first := time.Date(2026, 10, 12, 0, 0, 0, 0, market.KST)
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	c := NewCache(100)
+
+	c.Put("005930", day, days("005930", first, 1, 2, 3), false, now)
+	// An overlapping fetch replaces the candles with the same start, e.g.
+	// the day's candle while the session is open.
+	c.Put("005930", day, days("005930", first.AddDate(0, 0, 2), 30, 4), false, now.Add(time.Minute))
+	got, fetched, ok := c.Last("005930", day, 3)
+	if !ok || !equal(closes(got), []float64{2, 30, 4}) || !fetched.Equal(now.Add(time.Minute)) {
+		t.Errorf("Last(3) = %v, %s, %v", closes(got), fetched, ok)
+	}
+	if _, _, ok := c.Last("005930", day, 5); ok {
+		t.Error("Last(5) is complete with 4 candles cached")
+	}
+
+	// A fetch that does not overlap leaves no gap: it replaces the series.
+	c.Put("005930", day, days("005930", first.AddDate(0, 0, 10), 10, 11), false, now)
+	if got, _, _ := c.Last("005930", day, 0); !equal(closes(got), []float64{10, 11}) {
+		t.Errorf("after a disjoint fetch: %v, want 10 11", closes(got))
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+
+	// Fewer candles than asked are all there is once the source said so.
+	c.Put("000660", day, days("000660", first, 5, 6), true, now)
+	if got, _, ok := c.Last("000660", day, 10); !ok || len(got) != 2 {
+		t.Errorf("Last(10) of a complete series = %v, %v", closes(got), ok)
+	}
+}
+
+func TestCacheAddsLiveCandles(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	c := NewCache(100)
+	for i := 0; i < 3; i++ {
+		c.Add(Candle{Symbol: "005930", Start: start.Add(time.Duration(i) * 5 * time.Minute), Timeframe: 5 * time.Minute, Close: float64(i)})
+	}
+	got, fetched, _ := c.Last("005930", 5*time.Minute, 2)
+	if !equal(closes(got), []float64{1, 2}) || !fetched.IsZero() {
+		t.Errorf("Last(2) = %v, %s", closes(got), fetched)
+	}
+	if got, _, _ := c.Last("005930", day, 2); got != nil {
+		t.Errorf("Last of another timeframe = %v", closes(got))
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	first := time.Date(2026, 10, 12, 0, 0, 0, 0, market.KST)
+	now := first.AddDate(0, 0, 5)
+	c := NewCache(5)
+	c.Put("A", day, days("A", first, 1, 2), false, now)
+	c.Put("B", day, days("B", first, 1, 2), false, now)
+	c.Last("A", day, 1) // A is now more recently used than B
+	c.Put("C", day, days("C", first, 1, 2), false, now)
+
+	if _, _, ok := c.Last("B", day, 1); ok {
+		t.Error("B was not evicted")
+	}
+	if got, _, _ := c.Last("A", day, 0); len(got) != 2 {
+		t.Errorf("A has %d candles, want 2", len(got))
+	}
+	if c.Len() != 4 {
+		t.Errorf("Len = %d, want 4", c.Len())
+	}
+
+	// A series over the limit on its own keeps its latest candles.
+	c.Put("D", day, days("D", first, 1, 2, 3, 4, 5, 6, 7), true, now)
+	got, _, ok := c.Last("D", day, 0)
+	if !equal(closes(got), []float64{3, 4, 5, 6, 7}) || c.Len() != 5 {
+		t.Errorf("D = %v, Len = %d", closes(got), c.Len())
+	}
+	if _, _, ok = c.Last("D", day, 7); ok {
+		t.Error("a trimmed series is still complete")
+	}
+}
+
+// TestCacheConcurrentUse is meant to be run with -race.
+func TestCacheConcurrentUse(t *testing.T) {
+	first := time.Date(2026, 10, 12, 0, 0, 0, 0, market.KST)
+	c := NewCache(50)
+	var wg sync.WaitGroup
+	for _, symbol := range []string{"A", "B", "C", "D"} {
+		wg.Add(1)
+		go func(symbol string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				c.Put(symbol, day, days(symbol, first.AddDate(0, 0, i), 1, 2, 3), false, first)
+				c.Last(symbol, day, 10)
+			}
+		}(symbol)
+	}
+	wg.Wait()
+	if c.Len() > 50 {
+		t.Errorf("Len = %d, over the limit of 50", c.Len())
+	}
+}
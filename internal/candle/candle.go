@@ -0,0 +1,146 @@
+package candle
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"tradingbot/internal/market"
+)
+
+// Candle is an OHLCV bar covering [Start, Start+Timeframe).
+type Candle struct {
+	Symbol    string
+	Start     time.Time
+	Timeframe time.Duration
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    float64
+}
+
+// End returns when the candle's period ends.
+func (c Candle) End() time.Time {
+	return c.Start.Add(c.Timeframe)
+}
+
+// Start returns the start of the timeframe period containing t. Periods are
+// counted from KST midnight, so 1h candles start on the hour and 1d candles at
+// midnight KST.
+func Start(t time.Time, timeframe time.Duration) time.Time {
+	local := t.In(market.KST)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, market.KST)
+	return midnight.Add(local.Sub(midnight) / timeframe * timeframe)
+}
+
+type key struct {
+	symbol    string
+	timeframe time.Duration
+}
+
+// Aggregator builds candles of one or more timeframes from ticks or smaller
+// candles as they arrive. A candle is complete once input from a later period
+// arrives for its symbol, or when Flush is called past its end. Input must
+// arrive in time order per symbol; late input for a completed period is
+// dropped. Aggregator is safe for concurrent use.
+type Aggregator struct {
+	timeframes []time.Duration
+
+	mu      sync.Mutex
+	current map[key]*Candle
+	// closed is the start of the latest completed period per key.
+	closed map[key]time.Time
+}
+
+// NewAggregator creates an aggregator producing candles of each timeframe.
+func NewAggregator(timeframes ...time.Duration) *Aggregator {
+	return &Aggregator{timeframes: timeframes, current: map[key]*Candle{}, closed: map[key]time.Time{}}
+}
+
+// AddTick adds a trade or price observation and returns the candles it
+// completed.
+func (a *Aggregator) AddTick(symbol string, t time.Time, price, volume float64) []Candle {
+	return a.add(Candle{Symbol: symbol, Start: t, Open: price, High: price, Low: price, Close: price, Volume: volume})
+}
+
+// AddCandle merges a candle of a smaller timeframe, typically 1m, and returns
+// the candles it completed. Its Start decides which period it belongs to.
+func (a *Aggregator) AddCandle(c Candle) []Candle {
+	return a.add(c)
+}
+
+func (a *Aggregator) add(in Candle) []Candle {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var done []Candle
+	for _, tf := range a.timeframes {
+		k := key{in.Symbol, tf}
+		start := Start(in.Start, tf)
+		if last, ok := a.closed[k]; ok && !start.After(last) {
+			continue
+		}
+		cur := a.current[k]
+		if cur != nil && start.Before(cur.Start) {
+			continue
+		}
+		if cur != nil && start.After(cur.Start) {
+			done = append(done, a.complete(k, cur))
+			cur = nil
+		}
+		if cur == nil {
+			c := in
+			c.Start = start
+			c.Timeframe = tf
+			a.current[k] = &c
+			continue
+		}
+		if in.High > cur.High {
+			cur.High = in.High
+		}
+		if in.Low < cur.Low {
+			cur.Low = in.Low
+		}
+		cur.Close = in.Close
+		cur.Volume += in.Volume
+	}
+	sortCandles(done)
+	return done
+}
+
+// Flush completes and returns the candles of symbol whose period has ended by
+// now, so candles close on time even when no further input arrives.
+func (a *Aggregator) Flush(symbol string, now time.Time) []Candle {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var done []Candle
+	for _, tf := range a.timeframes {
+		k := key{symbol, tf}
+		if c, ok := a.current[k]; ok && !c.End().After(now) {
+			done = append(done, a.complete(k, c))
+		}
+	}
+	sortCandles(done)
+	return done
+}
+
+// complete removes the candle under k and records its period as closed. Must be
+// called with mu held.
+func (a *Aggregator) complete(k key, c *Candle) Candle {
+	delete(a.current, k)
+	a.closed[k] = c.Start
+	return *c
+}
+
+// sortCandles orders candles by end time, with shorter timeframes before the
+// longer ones that complete at the same moment.
+func sortCandles(candles []Candle) {
+	sort.Slice(candles, func(i, j int) bool {
+		ei, ej := candles[i].End(), candles[j].End()
+		if !ei.Equal(ej) {
+			return ei.Before(ej)
+		}
+		return candles[i].Timeframe < candles[j].Timeframe
+	})
+}
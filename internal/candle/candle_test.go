@@ -0,0 +1,83 @@
+package candle
+
+import (
+	"testing"
+	"time"
+	"tradingbot/internal/market"
+)
+
+func TestStart(t *testing.T) {
+	at := time.Date(2026, 10, 16, 10, 17, 30, 0, market.KST)
+	tests := []struct {
+		timeframe time.Duration
+		want      time.Time
+	}{
+		{5 * time.Minute, time.Date(2026, 10, 16, 10, 15, 0, 0, market.KST)},
+		{15 * time.Minute, time.Date(2026, 10, 16, 10, 15, 0, 0, market.KST)},
+		{time.Hour, time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)},
+		{24 * time.Hour, time.Date(2026, 10, 16, 0, 0, 0, 0, market.KST)},
+	}
+	for _, tt := range tests {
+		// The result must not depend on the zone of the input.
+		if got := Start(at.UTC(), tt.timeframe); !got.Equal(tt.want) {
+			t.Errorf("Start(%v) = %s, want %s", tt.timeframe, got, tt.want)
+		}
+	}
+}
+
+func TestAggregatorBuildsCandlesFromTicks(t *testing.T) {
+	base := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	a := NewAggregator(5*time.Minute, 15*time.Minute)
+
+	prices := []float64{100, 103, 98, 101, 102, 104, 99, 100, 105, 106, 107, 108, 109, 110, 111}
+	var done []Candle
+	for i, p := range prices {
+		done = append(done, a.AddTick("005930", base.Add(time.Duration(i)*time.Minute), p, 1)...)
+	}
+	if len(done) != 2 {
+		t.Fatalf("completed %d candles, want 2: %+v", len(done), done)
+	}
+	first := done[0]
+	if first.Timeframe != 5*time.Minute || !first.Start.Equal(base) ||
+		first.Open != 100 || first.High != 103 || first.Low != 98 || first.Close != 102 || first.Volume != 5 {
+		t.Errorf("first candle = %+v", first)
+	}
+
+	// The tick at 09:15 completes the third 5m candle and the first 15m candle,
+	// shorter timeframe first.
+	done = a.AddTick("005930", base.Add(15*time.Minute), 112, 1)
+	if len(done) != 2 || done[0].Timeframe != 5*time.Minute || done[1].Timeframe != 15*time.Minute {
+		t.Fatalf("completed %+v, want the 5m and 15m candles", done)
+	}
+	if c := done[1]; c.Open != 100 || c.High != 111 || c.Low != 98 || c.Close != 111 || c.Volume != 15 {
+		t.Errorf("15m candle = %+v", c)
+	}
+
+	// Late input for a completed period is dropped.
+	if done := a.AddTick("005930", base.Add(14*time.Minute), 1, 1); len(done) != 0 {
+		t.Errorf("late tick completed %+v", done)
+	}
+}
+
+func TestAggregatorMergesCandlesAndFlushes(t *testing.T) {
+	base := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	a := NewAggregator(time.Hour)
+
+	a.AddCandle(Candle{Symbol: "005930", Start: base, Timeframe: time.Minute, Open: 100, High: 110, Low: 95, Close: 105, Volume: 10})
+	a.AddCandle(Candle{Symbol: "005930", Start: base.Add(59 * time.Minute), Timeframe: time.Minute, Open: 105, High: 120, Low: 101, Close: 118, Volume: 5})
+	a.AddCandle(Candle{Symbol: "000660", Start: base, Timeframe: time.Minute, Open: 1, High: 1, Low: 1, Close: 1})
+
+	if done := a.Flush("005930", base.Add(59*time.Minute)); len(done) != 0 {
+		t.Fatalf("flushed %+v before the period ended", done)
+	}
+	done := a.Flush("005930", base.Add(time.Hour))
+	if len(done) != 1 {
+		t.Fatalf("flushed %d candles, want 1", len(done))
+	}
+	if c := done[0]; c.Open != 100 || c.High != 120 || c.Low != 95 || c.Close != 118 || c.Volume != 15 || c.Timeframe != time.Hour {
+		t.Errorf("hourly candle = %+v", c)
+	}
+	if done := a.AddTick("005930", base.Add(30*time.Minute), 1, 0); len(done) != 0 {
+		t.Errorf("input for a flushed period completed %+v", done)
+	}
+}
@@ -0,0 +1,137 @@
+package circuit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+)
+
+// State is the state of a circuit breaker.
+type State string
+
+const (
+	// Closed lets every call through.
+	Closed State = "closed"
+	// Open blocks calls until the cooldown has passed.
+	Open State = "open"
+	// HalfOpen lets calls through on trial; the next outcome closes or reopens it.
+	HalfOpen State = "half_open"
+)
+
+// Settings configures a Breaker. A zero LatencyThreshold disables latency checks.
+type Settings struct {
+	FailureThreshold int
+	LatencyThreshold time.Duration
+	Cooldown         time.Duration
+}
+
+// Breaker opens after FailureThreshold consecutive failures, where a call that
+// returns an error or takes longer than LatencyThreshold counts as a failure.
+// After Cooldown it half-opens and the next recorded outcome decides whether it
+// closes again. Breaker is safe for concurrent use.
+type Breaker struct {
+	settings Settings
+	onChange func(from, to State, reason string)
+	clock    clock.Clock
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+}
+
+// New creates a closed breaker. onChange, if not nil, is called after every state
+// transition, outside the breaker's lock.
+func New(settings Settings, onChange func(from, to State, reason string)) *Breaker {
+	return &Breaker{settings: settings, onChange: onChange, clock: clock.Real, state: Closed}
+}
+
+// SetClock replaces the clock used to time the cooldown.
+func (b *Breaker) SetClock(c clock.Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = c
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	state, changed := b.refresh()
+	b.mu.Unlock()
+	b.notify(changed)
+	return state
+}
+
+// Allow reports whether a guarded call may be made now.
+func (b *Breaker) Allow() bool {
+	return b.State() != Open
+}
+
+// Record reports the outcome of a call to the guarded service.
+func (b *Breaker) Record(latency time.Duration, err error) {
+	var reason string
+	switch {
+	case err != nil:
+		reason = err.Error()
+	case b.settings.LatencyThreshold > 0 && latency > b.settings.LatencyThreshold:
+		reason = fmt.Sprintf("latency %v exceeds %v", latency.Round(time.Millisecond), b.settings.LatencyThreshold)
+	}
+
+	b.mu.Lock()
+	_, refreshed := b.refresh()
+	var changed *transition
+	switch b.state {
+	case Closed:
+		if reason == "" {
+			b.failures = 0
+			break
+		}
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			changed = b.open(fmt.Sprintf("%d consecutive failures, last: %s", b.failures, reason))
+		}
+	case HalfOpen:
+		if reason == "" {
+			changed = b.set(Closed, "trial call succeeded")
+			b.failures = 0
+		} else {
+			changed = b.open("trial call failed: " + reason)
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(refreshed)
+	b.notify(changed)
+}
+
+type transition struct {
+	from, to State
+	reason   string
+}
+
+// refresh half-opens the breaker once the cooldown has passed. Must be called
+// with mu held.
+func (b *Breaker) refresh() (State, *transition) {
+	if b.state == Open && b.clock.Now().Sub(b.openedAt) >= b.settings.Cooldown {
+		return HalfOpen, b.set(HalfOpen, "cooldown elapsed")
+	}
+	return b.state, nil
+}
+
+func (b *Breaker) open(reason string) *transition {
+	b.openedAt = b.clock.Now()
+	return b.set(Open, reason)
+}
+
+func (b *Breaker) set(to State, reason string) *transition {
+	t := &transition{from: b.state, to: to, reason: reason}
+	b.state = to
+	return t
+}
+
+func (b *Breaker) notify(t *transition) {
+	if t != nil && b.onChange != nil {
+		b.onChange(t.from, t.to, t.reason)
+	}
+}
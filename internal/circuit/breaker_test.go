@@ -0,0 +1,60 @@
+package circuit
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	var changes []State
+	b := New(Settings{FailureThreshold: 3, LatencyThreshold: time.Second, Cooldown: time.Minute},
+		func(from, to State, reason string) { changes = append(changes, to) })
+	b.SetClock(clk)
+
+	fail := errors.New("503 Service Unavailable")
+	b.Record(10*time.Millisecond, fail)
+	b.Record(10*time.Millisecond, nil) // success resets the count
+	b.Record(10*time.Millisecond, fail)
+	b.Record(2*time.Second, nil) // slow counts as a failure
+	if !b.Allow() {
+		t.Fatalf("breaker opened after 2 consecutive failures, threshold is 3")
+	}
+	b.Record(10*time.Millisecond, fail)
+	if b.Allow() {
+		t.Fatalf("breaker still closed after 3 consecutive failures")
+	}
+
+	clk.Advance(30 * time.Second)
+	b.Record(10*time.Millisecond, nil) // ignored while open
+	if b.State() != Open {
+		t.Fatalf("state = %s before cooldown, want open", b.State())
+	}
+
+	clk.Advance(30 * time.Second)
+	if b.State() != HalfOpen {
+		t.Fatalf("state = %s after cooldown, want half_open", b.State())
+	}
+	b.Record(10*time.Millisecond, fail)
+	if b.State() != Open {
+		t.Fatalf("state = %s after failed trial, want open", b.State())
+	}
+
+	clk.Advance(time.Minute)
+	b.Record(10*time.Millisecond, nil)
+	if b.State() != Closed {
+		t.Fatalf("state = %s after successful trial, want closed", b.State())
+	}
+
+	want := []State{Open, HalfOpen, Open, HalfOpen, Closed}
+	if len(changes) != len(want) {
+		t.Fatalf("transitions = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("transitions = %v, want %v", changes, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits. Components take a Clock instead of calling
+// the time package directly so tests and replays can control time.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	Sleep(d time.Duration)
+}
+
+// Timer fires once on C after its duration, unless stopped.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Real is the system clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                 { return time.Now() }
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+func (realClock) Sleep(d time.Duration)          { time.Sleep(d) }
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
+
+// Simulated is a clock that only moves when told to. Timers fire, in deadline
+// order, as Advance or Set move past them. Sleep advances the clock itself, so
+// code that sleeps runs instantly. It is safe for concurrent use.
+type Simulated struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*simTimer
+}
+
+// NewSimulated returns a simulated clock set to start.
+func NewSimulated(start time.Time) *Simulated {
+	return &Simulated{now: start}
+}
+
+func (s *Simulated) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+func (s *Simulated) NewTimer(d time.Duration) Timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &simTimer{clock: s, deadline: s.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- s.now
+		return t
+	}
+	s.timers = append(s.timers, t)
+	return t
+}
+
+func (s *Simulated) Sleep(d time.Duration) {
+	s.Advance(d)
+}
+
+// Advance moves the clock forward by d.
+func (s *Simulated) Advance(d time.Duration) {
+	s.Set(s.Now().Add(d))
+}
+
+// Set moves the clock to t, firing every timer due by then. Moving backwards is
+// ignored.
+func (s *Simulated) Set(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.Before(s.now) {
+		return
+	}
+
+	sort.Slice(s.timers, func(i, j int) bool { return s.timers[i].deadline.Before(s.timers[j].deadline) })
+	remaining := s.timers[:0]
+	for _, timer := range s.timers {
+		if timer.deadline.After(t) {
+			remaining = append(remaining, timer)
+			continue
+		}
+		s.now = timer.deadline
+		timer.c <- timer.deadline
+	}
+	s.timers = remaining
+	s.now = t
+}
+
+// NextDeadline returns when the earliest pending timer fires.
+func (s *Simulated) NextDeadline() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, timer := range s.timers {
+		if next.IsZero() || timer.deadline.Before(next) {
+			next = timer.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func (s *Simulated) stop(t *simTimer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, timer := range s.timers {
+		if timer == t {
+			s.timers = append(s.timers[:i], s.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type simTimer struct {
+	clock    *Simulated
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (t *simTimer) C() <-chan time.Time { return t.c }
+func (t *simTimer) Stop() bool          { return t.clock.stop(t) }
@@ -0,0 +1,48 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulatedTimers(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	c := NewSimulated(start)
+
+	late := c.NewTimer(2 * time.Minute)
+	early := c.NewTimer(time.Minute)
+	stopped := c.NewTimer(30 * time.Second)
+	if !stopped.Stop() {
+		t.Fatalf("Stop on a pending timer returned false")
+	}
+	if next, ok := c.NextDeadline(); !ok || !next.Equal(start.Add(time.Minute)) {
+		t.Fatalf("NextDeadline = %v, %v; want 09:01", next, ok)
+	}
+
+	c.Advance(90 * time.Second)
+	select {
+	case at := <-early.C():
+		if !at.Equal(start.Add(time.Minute)) {
+			t.Errorf("early fired at %v, want its deadline", at)
+		}
+	default:
+		t.Fatalf("early timer did not fire")
+	}
+	select {
+	case <-late.C():
+		t.Fatalf("late timer fired before its deadline")
+	case <-stopped.C():
+		t.Fatalf("stopped timer fired")
+	default:
+	}
+
+	c.Sleep(time.Minute)
+	if got := c.Now(); !got.Equal(start.Add(150 * time.Second)) {
+		t.Errorf("Now = %v after sleeping, want 09:02:30", got)
+	}
+	select {
+	case <-late.C():
+	default:
+		t.Fatalf("late timer did not fire after Sleep")
+	}
+}
@@ -2,60 +2,1136 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
-	"tradingbot/internal/models"
 
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	DatabaseURL     string                `yaml:"database_url"`
-	Exchange        ExchangeConfig        `yaml:"exchange"`
-	TradingPair     string                `yaml:"trading_pair"`
-	PollingInterval string                `yaml:"polling_interval"`
-	ParsedInterval  time.Duration         `yaml:"-"`
-	Strategy        models.StrategyConfig `yaml:"strategy"`
+	Profile         string                    `yaml:"-"`
+	DatabaseURL     string                    `yaml:"database_url"`
+	Exchange        ExchangeConfig            `yaml:"exchange"`
+	MarketData      MarketDataConfig          `yaml:"market_data"`
+	TradingPair     string                    `yaml:"trading_pair"`
+	Symbols         []string                  `yaml:"symbols"`
+	MaxParallel     int                       `yaml:"max_parallel"`
+	PollingInterval string                    `yaml:"polling_interval"`
+	ParsedInterval  time.Duration             `yaml:"-"`
+	AdaptivePolling AdaptivePollingConfig     `yaml:"adaptive_polling"`
+	CycleBudget     float64                   `yaml:"cycle_budget"`
+	LogLevel        string                    `yaml:"log_level"`
+	Logging         LoggingConfig             `yaml:"logging"`
+	Risk            RiskConfig                `yaml:"risk"`
+	Position        PositionConfig            `yaml:"position"`
+	Kelly           KellyConfig               `yaml:"kelly"`
+	Liquidity       LiquidityConfig           `yaml:"liquidity"`
+	Exposure        ExposureConfig            `yaml:"exposure"`
+	Correlation     CorrelationConfig         `yaml:"correlation"`
+	CashSweep       CashSweepConfig           `yaml:"cash_sweep"`
+	Margin          MarginConfig              `yaml:"margin"`
+	ETF             ETFConfig                 `yaml:"etf"`
+	Derivatives     DerivativesConfig         `yaml:"derivatives"`
+	Hedger          HedgerConfig              `yaml:"hedger"`
+	Rebalance       RebalanceConfig           `yaml:"rebalance"`
+	Fees            FeeConfig                 `yaml:"fees"`
+	Tax             TaxConfig                 `yaml:"tax"`
+	CircuitBreaker  CircuitBreakerConfig      `yaml:"circuit_breaker"`
+	Monitor         MonitorConfig             `yaml:"monitor"`
+	Watchdog        WatchdogConfig            `yaml:"watchdog"`
+	Earnings        EarningsConfig            `yaml:"earnings"`
+	News            NewsConfig                `yaml:"news"`
+	Intraday        IntradayConfig            `yaml:"intraday"`
+	Secrets         SecretsConfig             `yaml:"secrets"`
+	Shutdown        ShutdownConfig            `yaml:"shutdown"`
+	Market          MarketConfig              `yaml:"market"`
+	API             APIConfig                 `yaml:"api"`
+	KillSwitch      KillSwitchConfig          `yaml:"kill_switch"`
+	Telegram        TelegramConfig            `yaml:"telegram"`
+	Notify          NotifyConfig              `yaml:"notify"`
+	Audit           AuditConfig               `yaml:"audit"`
+	Outbox          OutboxConfig              `yaml:"outbox"`
+	StrategyState   StrategyStateConfig       `yaml:"strategy_state"`
+	Lock            LockConfig                `yaml:"lock"`
+	Reconcile       ReconcileConfig           `yaml:"reconcile"`
+	Universe        UniverseConfig            `yaml:"universe"`
+	Screen          ScreenConfig              `yaml:"screen"`
+	Maintenance     MaintenanceConfig         `yaml:"maintenance"`
+	Tuning          TuningConfig              `yaml:"tuning"`
+	Shadow          ShadowConfig              `yaml:"shadow"`
+	Experiment      ExperimentConfig          `yaml:"experiment"`
+	SignalRules     []SignalRule              `yaml:"signal_rules"`
+	Strategy        string                    `yaml:"strategy"`
+	Timeframe       string                    `yaml:"timeframe"`
+	ParsedTimeframe time.Duration             `yaml:"-"`
+	BarClose        bool                      `yaml:"bar_close"`
+	Strategies      map[string]StrategyParams `yaml:"strategies"`
+	Allocation      AllocationConfig          `yaml:"allocation"`
+	Backtest        BacktestConfig            `yaml:"backtest"`
 }
 
 type ExchangeConfig struct {
-	Name        string `yaml:"name"`
-	AccountNo   string `yaml:"account_no"`
-	AppKey      string `yaml:"-"`
-	AppSecret   string `yaml:"-"`
-	AccessToken string `yaml:"-"`
+	Name      string `yaml:"name"`
+	Mode      string `yaml:"mode"`
+	BaseURL   string `yaml:"base_url"`
+	AccountNo string `yaml:"account_no"`
+	// Approval is how a live run is confirmed before it sends orders: typing
+	// the account number at startup, or a POST to /control/live/approve.
+	// Paper mode needs no approval.
+	Approval string `yaml:"approval"`
+	// Observe runs the bot as an observer: it streams data, computes and
+	// records signals, but sends, cancels and amends no orders at all.
+	Observe     bool       `yaml:"observe"`
+	HTTP        HTTPConfig `yaml:"http"`
+	AppKey      string     `yaml:"-"`
+	AppSecret   string     `yaml:"-"`
+	AccessToken string     `yaml:"-"`
+}
+
+// HTTPConfig tunes the connections of the exchange client, e.g. to go through
+// a corporate proxy or a TLS-intercepting firewall.
+type HTTPConfig struct {
+	UserAgent string `yaml:"user_agent"`
+	// Proxy is the URL of an HTTP or SOCKS5 proxy. Without one the
+	// HTTPS_PROXY and NO_PROXY environment variables are honoured.
+	Proxy string `yaml:"proxy"`
+	// CAFile is a PEM bundle of certificate authorities trusted in addition
+	// to the system's, such as a firewall's.
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are a PEM client certificate and key to present.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// MinTLSVersion is "1.2" (the default) or "1.3".
+	MinTLSVersion string `yaml:"min_tls_version"`
+	// InsecureSkipVerify accepts any server certificate. For debugging only.
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	Timeout            string `yaml:"timeout"`
+}
+
+const (
+	LiveApprovalPrompt = "prompt"
+	LiveApprovalAPI    = "api"
+)
+
+const (
+	LogSinkStdout = "stdout"
+	LogSinkStderr = "stderr"
+	LogSinkFile   = "file"
+	LogSinkSyslog = "syslog"
+)
+
+// LoggingConfig routes log output to one or more sinks. Without sinks everything
+// goes to stdout at log_level.
+type LoggingConfig struct {
+	Sinks []LogSinkConfig `yaml:"sinks"`
+}
+
+// LogSinkConfig is a single log destination. Level defaults to log_level and
+// Format ("text" or "json") to text. File sinks rotate when they exceed
+// MaxSizeMB or MaxAge, keeping MaxBackups old files (0 keeps all). Syslog sinks
+// log to the local daemon, which journald also reads, unless Address is set.
+type LogSinkConfig struct {
+	Type       string `yaml:"type"`
+	Level      string `yaml:"level"`
+	Format     string `yaml:"format"`
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxAge     string `yaml:"max_age"`
+	MaxBackups int    `yaml:"max_backups"`
+	Network    string `yaml:"network"`
+	Address    string `yaml:"address"`
+	Tag        string `yaml:"tag"`
+}
+
+// AdaptivePollingConfig varies the polling interval with market activity,
+// between MinInterval and MaxInterval: polling_interval is kept while the
+// realized volatility of the last Window polled prices of the most active
+// symbol, as the standard deviation of its returns per minute, is at
+// TargetVolatility, and scaled by their ratio otherwise, so polls come twice as
+// often at twice the volatility. Quotes carrying the best bid and ask also
+// count their spread, as a fraction of the mid price, against TargetSpread;
+// zero ignores the spread. With TargetSpread set, KIS quotes are completed by
+// a request for the asking price. Window defaults to 20.
+type AdaptivePollingConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	MinInterval      string  `yaml:"min_interval"`
+	MaxInterval      string  `yaml:"max_interval"`
+	Window           int     `yaml:"window"`
+	TargetVolatility float64 `yaml:"target_volatility"`
+	TargetSpread     float64 `yaml:"target_spread"`
+}
+
+// RiskConfig limits what a single trading cycle is allowed to do. Zero means no limit.
+// Orders in halted symbols are always refused, as are buys at the upper price
+// limit or within LimitUpMargin, a fraction of the price, below it.
+type RiskConfig struct {
+	MaxOrderAmount float64 `yaml:"max_order_amount"`
+	LimitUpMargin  float64 `yaml:"limit_up_margin"`
+	// MaxQuoteAge refuses orders on a quote older than this, e.g. the
+	// latest close of the database provider or a vendor's delayed quote.
+	// MaxSpreadBps refuses them when the best ask and bid are further apart
+	// than this many basis points of their mid price; it only applies to
+	// sources that quote them. With either set, KIS quotes, which carry
+	// neither a time nor the ask and bid, are completed by a request for
+	// the asking price. Zero disables either check.
+	MaxQuoteAge  string  `yaml:"max_quote_age"`
+	MaxSpreadBps float64 `yaml:"max_spread_bps"`
+}
+
+// PositionConfig turns strategy signals into orders against the held position.
+// Buy signals scale in by ScaleIn shares at a time until TargetQuantity is held
+// and are ignored at the target; sell signals scale out by ScaleOut shares, or
+// close the whole position when ScaleOut is zero, and are ignored when flat.
+// Zero TargetQuantity uses the signal's amount; zero ScaleIn buys up to the
+// target at once. The *Notional settings give the target and scale-in step in
+// KRW instead, converted to whole lots at the current price.
+type PositionConfig struct {
+	TargetQuantity  float64 `yaml:"target_quantity"`
+	TargetNotional  float64 `yaml:"target_notional"`
+	ScaleIn         float64 `yaml:"scale_in"`
+	ScaleInNotional float64 `yaml:"scale_in_notional"`
+	ScaleOut        float64 `yaml:"scale_out"`
+}
+
+// KellyConfig sizes the buys of the listed strategies, or allocation sleeves,
+// by fractional Kelly instead of the position settings: Fraction of the Kelly
+// fraction W - (1-W)/R, where W is the win rate and R the average win over the
+// average loss, of the account equity (a sleeve's capital), at most Cap of it.
+// W and R are taken from the last Lookback closed trades once there are
+// MinTrades of them, and before that from the latest backtest of the
+// strategy; without either the position settings apply. Defaults are a
+// fraction of 0.5, a cap of 0.2, 50 trades and 20 trades.
+type KellyConfig struct {
+	Strategies []string `yaml:"strategies"`
+	Fraction   float64  `yaml:"fraction"`
+	Cap        float64  `yaml:"cap"`
+	Lookback   int      `yaml:"lookback"`
+	MinTrades  int      `yaml:"min_trades"`
+}
+
+// LiquidityConfig limits the shares of a symbol ordered in a day to
+// MaxADVFraction of its average daily volume over the last ADVDays days, read
+// from the daily candles of the database, so that orders in thin names, e.g.
+// on KOSDAQ, do not move the price. An order beyond the limit is sliced down
+// to what is left of it, the rest following with later signals as with
+// position.scale_in, or refused with Reject. Sells are always sliced, never
+// refused. Symbols without daily candles are not limited. Zero
+// MaxADVFraction disables the limit; ADVDays defaults to 20.
+type LiquidityConfig struct {
+	MaxADVFraction float64 `yaml:"max_adv_fraction"`
+	ADVDays        int     `yaml:"adv_days"`
+	Reject         bool    `yaml:"reject"`
+}
+
+// ExposureConfig limits the share of the equity held in each sector of the
+// symbol master, which needs universe.source: a buy is refused when the
+// positions in its sector, the buy included, would be worth more than
+// SectorLimits of the sector, e.g. {반도체: 0.3}, or MaxSector of the sectors
+// not listed. Sells are never refused, and symbols without a sector are not
+// limited. Zero values disable a limit.
+type ExposureConfig struct {
+	MaxSector    float64            `yaml:"max_sector"`
+	SectorLimits map[string]float64 `yaml:"sector_limits"`
+}
+
+// SectorLimit returns the limit of sector, or zero when it has none.
+func (e ExposureConfig) SectorLimit(sector string) float64 {
+	if limit, ok := e.SectorLimits[sector]; ok {
+		return limit
+	}
+	return e.MaxSector
+}
+
+// Enabled reports whether any sector is limited.
+func (e ExposureConfig) Enabled() bool {
+	return e.MaxSector > 0 || len(e.SectorLimits) > 0
+}
+
+// CorrelationConfig limits the concentration in names that move together:
+// after a buy, the positions in its symbol and in the held symbols whose
+// daily returns over the last Days days correlate with the symbol's by
+// Threshold or more must be worth at most MaxConcentration of the equity.
+// Beyond it the buy is logged as a warning, or refused with Reject. The
+// returns are taken from the daily candles of the database; pairs with fewer
+// than half of Days returns in common are not counted as correlated. Zero
+// MaxConcentration disables the check; Threshold defaults to 0.7 and Days to
+// 60.
+type CorrelationConfig struct {
+	MaxConcentration float64 `yaml:"max_concentration"`
+	Threshold        float64 `yaml:"threshold"`
+	Days             int     `yaml:"days"`
+	Reject           bool    `yaml:"reject"`
+}
+
+// ShadowConfig runs Strategy, a candidate configured under strategies, next
+// to the traded strategy on the same live prices: both trade on paper with
+// Capital each and the same position, risk and signal rule settings, so
+// their performance can be compared (GET /shadow) before switching. The real
+// account keeps trading the incumbent. Capital defaults to 10,000,000.
+type ShadowConfig struct {
+	Enabled  bool    `yaml:"enabled"`
+	Strategy string  `yaml:"strategy"`
+	Capital  float64 `yaml:"capital"`
+}
+
+// ExperimentConfig runs an A/B test between two allocation sleeves, the
+// variants A and B, e.g. two parameter sets of one strategy; they split the
+// capital by their sleeve weights. Their daily returns are compared with a
+// paired t-test, and after Days trading days the report (GET /experiment) is
+// final and names the better variant when the difference is significant at
+// Significance. Days defaults to 20 and Significance to 0.05.
+type ExperimentConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	A            string  `yaml:"a"`
+	B            string  `yaml:"b"`
+	Days         int     `yaml:"days"`
+	Significance float64 `yaml:"significance"`
+}
+
+// Signal rules, see SignalRule.
+const (
+	SignalRuleConfirm  = "confirm"
+	SignalRuleInvert   = "invert"
+	SignalRuleTranches = "tranches"
+)
+
+// SignalRule changes the signals of the strategies before they are sized and
+// checked; rules apply in the order listed. "confirm" acts on a buy or sell
+// only once it was given again on the next Bars bars (default 1). "invert"
+// turns buys into sells and sells into buys, e.g. for a hedging account.
+// "tranches" builds positions in Tranches steps: each buy signal at least
+// Bars bars after the previous step raises the position to the next fraction
+// of its target (see PositionConfig) until it is complete, and a sell starts
+// over.
+type SignalRule struct {
+	Rule     string `yaml:"rule"`
+	Bars     int    `yaml:"bars"`
+	Tranches int    `yaml:"tranches"`
+}
+
+// FeeConfig holds the trading costs used to attribute PnL, as fractions of the
+// traded value: the broker commission on every trade and the securities
+// transaction tax on sells.
+type FeeConfig struct {
+	CommissionRate float64 `yaml:"commission_rate"`
+	SellTaxRate    float64 `yaml:"sell_tax_rate"`
+}
+
+// Tax lot methods, see TaxConfig.
+const (
+	LotFIFO     = "fifo"
+	LotSpecific = "specific"
+)
+
+// TaxConfig sets how sells are matched to the tax lots of earlier buys for
+// realized gain reports: first in, first out, or by the lots selected for each
+// sell, falling back to FIFO for shares without a selection.
+type TaxConfig struct {
+	LotMethod string `yaml:"lot_method"`
+}
+
+const (
+	IntrabarPessimistic = "pessimistic"
+	IntrabarOptimistic  = "optimistic"
+	IntrabarClose       = "close"
+)
+
+// Market data providers, see MarketDataConfig.
+const (
+	MarketDataKIS      = "kis"
+	MarketDataDatabase = "database"
+	MarketDataHTTP     = "http"
+	MarketDataNaver    = "naver"
+)
+
+// Data quality actions, see MarketDataConfig.
+const (
+	QualityFlag   = "flag"
+	QualityRepair = "repair"
+	QualityReject = "reject"
+)
+
+// MarketDataConfig selects where quotes and price history come from; orders
+// are always executed through the exchange. Provider "kis", the default, uses
+// the exchange itself. "database" reads the daily_candles table of DatabaseURL
+// (default: database_url) and quotes the latest close. "http" queries a vendor
+// API at URL: GET <url>/quotes/<symbol> and GET <url>/candles/<symbol>?days=N,
+// authenticated with APIKey as a bearer token.
+//
+// Fallback "naver" serves quotes and candles from Naver Finance while the
+// provider fails, e.g. when KIS is rate limited or down, and keeps doing so for
+// RetryAfter before trying the provider again. With MaxDeviation set, quotes
+// are cross-checked against the fallback at most once per CheckInterval per
+// symbol and rejected when the prices differ by more than that fraction.
+//
+// Quality checks daily candles and bars for missing sessions, non-positive
+// prices, duplicate dates and closes moving more than MaxJump (default 0.3,
+// the KRX daily limit) before backtests and screens use them: "flag" logs the
+// issues, "repair" also fills gaps and bad candles forward from the previous
+// close, and "reject" fails on any issue. Empty skips the checks.
+//
+// Cache keeps recent daily candles, and the completed candles of the trading
+// loop, in memory; see CandleCacheConfig.
+type MarketDataConfig struct {
+	Provider      string            `yaml:"provider"`
+	DatabaseURL   string            `yaml:"database_url"`
+	URL           string            `yaml:"url"`
+	APIKey        string            `yaml:"api_key"`
+	Timeout       string            `yaml:"timeout"`
+	Fallback      string            `yaml:"fallback"`
+	RetryAfter    string            `yaml:"retry_after"`
+	MaxDeviation  float64           `yaml:"max_deviation"`
+	CheckInterval string            `yaml:"check_interval"`
+	Quality       string            `yaml:"quality"`
+	MaxJump       float64           `yaml:"max_jump"`
+	Cache         CandleCacheConfig `yaml:"cache"`
+}
+
+// CandleCacheConfig enables the in-memory candle cache. Daily candles fetched
+// from the provider are served from it for TTL (default 1m), and the API
+// serves the cached candles at /candles. It holds at most MaxCandles candles
+// (default 100000, about 9 MB), evicting the least recently used symbols
+// first.
+type CandleCacheConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	MaxCandles int    `yaml:"max_candles"`
+	TTL        string `yaml:"ttl"`
+}
+
+// BacktestConfig holds settings used only by the backtester. StopLoss and
+// TakeProfit close a position when the price moves by that fraction of the
+// entry price; zero disables them. Intrabar decides how they are checked
+// against daily bars: against the high and low, assuming the stop is hit first
+// ("pessimistic", the default) or the target ("optimistic") when a bar reaches
+// both, or only against the close ("close"). Slippage is the largest adverse
+// price move of a fill as a fraction of the price, drawn at random; Seed makes
+// the draws reproducible and zero picks a new seed every run. Dividends pays
+// the symbol's historical cash dividends on positions held over their
+// ex-dividend dates, less DividendTax withheld, for total returns. Portfolio
+// constrains the rebalancing backtest.
+type BacktestConfig struct {
+	StopLoss    float64              `yaml:"stop_loss"`
+	TakeProfit  float64              `yaml:"take_profit"`
+	Intrabar    string               `yaml:"intrabar"`
+	Slippage    float64              `yaml:"slippage"`
+	Seed        int64                `yaml:"seed"`
+	Dividends   bool                 `yaml:"dividends"`
+	DividendTax float64              `yaml:"dividend_tax"`
+	Portfolio   PortfolioConstraints `yaml:"portfolio"`
+}
+
+// PortfolioConstraints limit the rebalances of `backtest rebalance`.
+// MaxPositions holds at most that many symbols, those with the largest target
+// weights. SectorCaps caps the total target weight of each sector of the
+// symbol master by scaling its symbols down. MaxTurnover limits the value
+// traded in one rebalance to that fraction of the equity by scaling every
+// trade down, and trades worth less than MinTradeValue KRW are skipped. The
+// weight given up stays in cash; zero values disable a constraint.
+type PortfolioConstraints struct {
+	MaxPositions  int                `yaml:"max_positions"`
+	SectorCaps    map[string]float64 `yaml:"sector_caps"`
+	MaxTurnover   float64            `yaml:"max_turnover"`
+	MinTradeValue float64            `yaml:"min_trade_value"`
+}
+
+// Credit loan types, see MarginConfig.
+const (
+	CreditSelf         = "self"
+	CreditDistribution = "distribution"
+)
+
+// MarginConfig places buys as credit (margin) orders: Requirement is the
+// fraction of a buy paid in cash, the rest being lent by the broker ("self"
+// financing) or a securities finance company ("distribution"), and sells of
+// positions carrying a loan repay it. Buys are rejected once the holdings
+// would exceed MaxLeverage times the equity, cash plus holdings less loans;
+// zero disables the limit. InterestRate is the annual interest on loans, used
+// only by the backtester.
+type MarginConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	CreditType   string  `yaml:"credit_type"`
+	Requirement  float64 `yaml:"requirement"`
+	MaxLeverage  float64 `yaml:"max_leverage"`
+	InterestRate float64 `yaml:"interest_rate"`
+}
+
+// ETFConfig holds the trading rules of ETFs and ETNs, told apart by the symbol
+// master. With NAV, their quotes carry the indicative NAV (iNAV, or IIV for
+// ETNs) for strategies such as nav_deviation. Liquidity providers need not
+// quote in the first minutes of the session nor in the closing auction, so
+// with LPGuard orders are rejected within LPOpenDelay (default 5m) of the open
+// and from the closing auction on. Buys at a premium above MaxBuyPremium and
+// sells at a discount beyond MaxSellDiscount, fractions of the NAV, are
+// rejected; zero disables the limit.
+type ETFConfig struct {
+	NAV             bool    `yaml:"nav"`
+	LPGuard         bool    `yaml:"lp_guard"`
+	LPOpenDelay     string  `yaml:"lp_open_delay"`
+	MaxBuyPremium   float64 `yaml:"max_buy_premium"`
+	MaxSellDiscount float64 `yaml:"max_sell_discount"`
+}
+
+// DerivativesConfig overlays KOSPI200 futures and options on the stock
+// holdings of Symbols, or all holdings when empty, as planned by `tradingbot
+// derivatives plan`. AccountNo is the futures and options account when it
+// differs from exchange.account_no. Future is the futures contract whose
+// price stands for the index level and that Hedge trades; Multiplier is the
+// KRW value of an index point (default 250,000).
+type DerivativesConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	AccountNo   string            `yaml:"account_no"`
+	Future      string            `yaml:"future"`
+	Multiplier  float64           `yaml:"multiplier"`
+	Symbols     []string          `yaml:"symbols"`
+	CoveredCall CoveredCallConfig `yaml:"covered_call"`
+	Hedge       HedgeConfig       `yaml:"hedge"`
+}
+
+// CoveredCallConfig writes a call for every contract's worth of holdings, at
+// the lowest strike at least MinOTM, a fraction of the index, above it.
+// Maturity, YYYYMM, defaults to the nearest one that has not expired.
+type CoveredCallConfig struct {
+	Enabled  bool    `yaml:"enabled"`
+	MinOTM   float64 `yaml:"min_otm"`
+	Maturity string  `yaml:"maturity"`
+}
+
+// HedgeConfig sells futures against Ratio of the holdings' value, scaled by
+// their Beta to the index (default 1).
+type HedgeConfig struct {
+	Enabled bool    `yaml:"enabled"`
+	Ratio   float64 `yaml:"ratio"`
+	Beta    float64 `yaml:"beta"`
+}
+
+// Hedge instruments, see HedgerConfig.
+const (
+	HedgeFuture     = "future"
+	HedgeInverseETF = "inverse_etf"
+)
+
+// HedgerConfig protects the stock holdings while the portfolio's drawdown
+// from its peak exceeds MaxDrawdown or the annualized volatility of Index's
+// daily closes over VolatilityWindow days exceeds MaxVolatility; zero
+// disables either trigger. Ratio of the holdings is then hedged by selling
+// the futures of derivatives.future or by buying InverseETF, which returns
+// Leverage times the inverse daily return (e.g. 1 for 114800, 2 for 252670).
+// The hedge is lifted once both have fallen below Release (default 0.5)
+// times their thresholds. Holdings are checked every CheckInterval.
+type HedgerConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	Instrument       string  `yaml:"instrument"`
+	InverseETF       string  `yaml:"inverse_etf"`
+	Leverage         float64 `yaml:"leverage"`
+	Index            string  `yaml:"index"`
+	MaxDrawdown      float64 `yaml:"max_drawdown"`
+	MaxVolatility    float64 `yaml:"max_volatility"`
+	VolatilityWindow int     `yaml:"volatility_window"`
+	Ratio            float64 `yaml:"ratio"`
+	Release          float64 `yaml:"release"`
+	CheckInterval    string  `yaml:"check_interval"`
+}
+
+// Rebalancing triggers and calendar periods, see RebalanceConfig.
+const (
+	RebalanceCalendar  = "calendar"
+	RebalanceThreshold = "threshold"
+
+	RebalanceWeekly    = "weekly"
+	RebalanceMonthly   = "monthly"
+	RebalanceQuarterly = "quarterly"
+)
+
+// RebalanceConfig keeps the portfolio of Weights, each symbol's target
+// fraction of the cash plus the holdings of these symbols, at its targets;
+// weights summing to less than 1 keep the rest in cash. Holdings are brought
+// back to their targets once a weight drifts more than Band from its target:
+// with the threshold trigger at any check, every CheckInterval, and with the
+// calendar trigger only at the first check of each Period.
+type RebalanceConfig struct {
+	Enabled       bool               `yaml:"enabled"`
+	Weights       map[string]float64 `yaml:"weights"`
+	Trigger       string             `yaml:"trigger"`
+	Period        string             `yaml:"period"`
+	Band          float64            `yaml:"band"`
+	CheckInterval string             `yaml:"check_interval"`
+}
+
+// CashSweepConfig parks idle cash in a money-market ETF, e.g. a CD rate or
+// KOFR ETF. While no other position is open, cash above Reserve buys Symbol;
+// before a buy the cash does not cover, enough of Symbol is sold to pay for
+// it. Purchases below MinAmount KRW are skipped. AnnualYield is the ETF's
+// expected return, used only by the backtester.
+type CashSweepConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Symbol      string  `yaml:"symbol"`
+	Reserve     float64 `yaml:"reserve"`
+	MinAmount   float64 `yaml:"min_amount"`
+	AnnualYield float64 `yaml:"annual_yield"`
+}
+
+// CircuitBreakerConfig stops order placement after FailureThreshold consecutive
+// failed or slower-than-LatencyThreshold exchange calls. Market data keeps being
+// polled, and after Cooldown the next call decides whether trading resumes.
+type CircuitBreakerConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	FailureThreshold int    `yaml:"failure_threshold"`
+	LatencyThreshold string `yaml:"latency_threshold"`
+	Cooldown         string `yaml:"cooldown"`
+}
+
+// Actions taken when a strategy degrades, see MonitorConfig.
+const (
+	MonitorActionAlert = "alert"
+	MonitorActionPause = "pause"
+)
+
+// MonitorConfig watches the live performance of every strategy over its last
+// Window closed trades and compares it with the strategy's backtest: the stored
+// run Baseline, or by default the latest run of the strategy. Once MinTrades
+// trades have closed, a win rate more than MaxWinRateDrop below the baseline's
+// or an expectancy (average return per trade, in percent) more than
+// MaxExpectancyDrop points below it counts as degraded; a zero drop disables
+// its check. A degraded strategy is reported and, with Action "pause", opens no
+// new positions until restart.
+type MonitorConfig struct {
+	Enabled           bool    `yaml:"enabled"`
+	Window            int     `yaml:"window"`
+	MinTrades         int     `yaml:"min_trades"`
+	MaxWinRateDrop    float64 `yaml:"max_win_rate_drop"`
+	MaxExpectancyDrop float64 `yaml:"max_expectancy_drop"`
+	Action            string  `yaml:"action"`
+	Baseline          int64   `yaml:"baseline"`
+}
+
+// WatchdogConfig raises alerts when, during market hours, no market data has
+// arrived for MarketDataTimeout or the main loop has not completed a cycle for
+// LoopTimeout, and when the exchange token cannot be refreshed. Empty timeouts
+// disable their check. With Restart set, a stale feed triggers an immediate
+// cycle, a failed token refresh re-authenticates and a stalled loop exits the
+// process so its supervisor can start it again.
+type WatchdogConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	MarketDataTimeout string `yaml:"market_data_timeout"`
+	LoopTimeout       string `yaml:"loop_timeout"`
+	CheckInterval     string `yaml:"check_interval"`
+	Restart           bool   `yaml:"restart"`
+}
+
+// EarningsConfig keeps strategies from opening positions shortly before a
+// symbol reports earnings. Earnings dates are estimated from the symbol's DART
+// disclosures of the past year, read with DartAPIKey, and taken from Dates
+// (symbol to YYYY-MM-DD dates). Buys are rejected from BlackoutDays before an
+// earnings date until the day itself; Strategies overrides the days per
+// strategy or sleeve name, 0 exempting it.
+type EarningsConfig struct {
+	Enabled      bool                `yaml:"enabled"`
+	DartAPIKey   string              `yaml:"dart_api_key"`
+	BlackoutDays int                 `yaml:"blackout_days"`
+	Strategies   map[string]int      `yaml:"strategies"`
+	Dates        map[string][]string `yaml:"dates"`
+}
+
+// News sources and sentiment scorers, see NewsConfig.
+const (
+	NewsSourceRSS     = "rss"
+	NewsSourceNaver   = "naver"
+	NewsScorerLexicon = "lexicon"
+	NewsScorerHTTP    = "http"
+)
+
+// NewsConfig pulls headlines of the traded symbols every PollInterval and
+// scores their sentiment for strategies that combine it with price signals.
+// Sources are "rss", reading RSSURL with {query} replaced, and "naver", the
+// Naver news search API authenticated with NaverClientID and
+// NaverClientSecret. Queries maps symbols to search terms, usually the company
+// name; others are searched by code. A symbol's sentiment is the average score
+// of its headlines published within Window. Scorer "lexicon", the default,
+// weighs the words of a built-in list extended by Lexicon; "http" posts each
+// headline as {"text": ...} to ScorerURL and reads {"score": ...} back.
+type NewsConfig struct {
+	Enabled           bool               `yaml:"enabled"`
+	Sources           []string           `yaml:"sources"`
+	RSSURL            string             `yaml:"rss_url"`
+	NaverClientID     string             `yaml:"naver_client_id"`
+	NaverClientSecret string             `yaml:"naver_client_secret"`
+	Queries           map[string]string  `yaml:"queries"`
+	PollInterval      string             `yaml:"poll_interval"`
+	Window            string             `yaml:"window"`
+	Scorer            string             `yaml:"scorer"`
+	ScorerURL         string             `yaml:"scorer_url"`
+	Lexicon           map[string]float64 `yaml:"lexicon"`
+}
+
+// IntradayConfig polls the 1m candles of the traded symbols every
+// PollInterval to track their session VWAP and volume profile, which the API
+// serves and strategies can trade around, e.g. moving_average with
+// buy_below_vwap. ProfileBins is the number of price bins of the volume
+// profile, 20 by default.
+type IntradayConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	PollInterval string `yaml:"poll_interval"`
+	ProfileBins  int    `yaml:"profile_bins"`
+}
+
+// APIConfig enables the HTTP status and control API. Every request must carry
+// `Authorization: Bearer <credential>`, except TradingView alerts which
+// authenticate with a passphrase in the body. The credential is Token, which
+// has the admin role, one of Keys, or a JWT signed with JWT.Secret; it decides
+// the role of the request. Keys and the JWT secret can come from the secrets
+// provider instead of the file.
+type APIConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Listen      string            `yaml:"listen"`
+	Token       string            `yaml:"token"`
+	Keys        []APIKey          `yaml:"keys"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	TradingView TradingViewConfig `yaml:"tradingview"`
+}
+
+// API roles, each allowed what the ones before it are: read only reads,
+// operator also pauses, resumes, flattens, kills and trades, and admin also
+// approves live trading and tuning proposals.
+const (
+	RoleRead     = "read"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// APIKey is a named API key with a role. An empty Key is disabled until the
+// secrets provider sets it.
+type APIKey struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+	Role string `yaml:"role"`
+}
+
+// JWTConfig accepts HS256 JWTs signed with Secret. The token's role claim
+// gives its role and its sub claim names the caller; exp, when present, is
+// enforced. Issuer, if set, must match the iss claim.
+type JWTConfig struct {
+	Secret string `yaml:"secret"`
+	Issuer string `yaml:"issuer"`
+}
+
+// KillSwitchConfig stops trading in an emergency: new orders are refused, open
+// orders cancelled and the trading loop paused until the bot is restarted.
+// Positions are kept. It is triggered by creating File, checked every
+// CheckInterval (default 2s), by POST /control/kill, or by a /kill command to
+// the Telegram bot. The bot does not start while File exists.
+type KillSwitchConfig struct {
+	File          string `yaml:"file"`
+	CheckInterval string `yaml:"check_interval"`
+}
+
+// TelegramConfig runs a Telegram bot taking control commands (/status,
+// /positions, /pnl, /pause, /resume, /flatten, /kill) from the chats in
+// ChatIDs. When UserIDs is set, only those users may send commands, which
+// matters in group chats; messages from anyone else are ignored.
+type TelegramConfig struct {
+	Enabled bool    `yaml:"enabled"`
+	Token   string  `yaml:"token"`
+	ChatIDs []int64 `yaml:"chat_ids"`
+	UserIDs []int64 `yaml:"user_ids"`
+}
+
+// TradingViewConfig accepts TradingView alert webhooks at /webhook/tradingview
+// and trades them like strategy signals, for the configured symbols only.
+type TradingViewConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Passphrase string `yaml:"passphrase"`
+}
+
+// ReconcileConfig compares the orders stored by the bot with the broker's
+// positions and open orders at startup and logs the differences. With Import,
+// trades made outside the bot are stored as orders so that local accounting
+// matches the account.
+type ReconcileConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Import  bool `yaml:"import"`
+}
+
+// AuditConfig enables the append-only audit log recording every trading
+// decision with its inputs, risk checks and outcome.
+type AuditConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+}
+
+const (
+	LockDatabase = "database"
+	LockFile     = "file"
+)
+
+// LockConfig keeps a second instance from trading the same account, e.g. after
+// an accidental double launch. The database backend holds a MySQL named lock
+// for the account on a connection of its own, which also guards instances on
+// other hosts; the file backend creates a lock file at Path holding the
+// owner's PID, for a single host. The lock is taken at startup and checked
+// every CheckInterval (default 30s); trading pauses if it is lost. Without a
+// backend nothing is locked.
+type LockConfig struct {
+	Backend       string `yaml:"backend"`
+	Path          string `yaml:"path"`
+	CheckInterval string `yaml:"check_interval"`
+}
+
+// OutboxConfig keeps order records the database failed to save in a local
+// file at Path and retries them, oldest first, every RetryInterval until they
+// are stored. Orders placed meanwhile queue behind them, so records reach the
+// database in order.
+type OutboxConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Path          string `yaml:"path"`
+	RetryInterval string `yaml:"retry_interval"`
+}
+
+// StrategyStateConfig saves the accumulated state of the strategies, such as
+// their price histories, to a snapshot at Path after every cycle and at
+// shutdown, and restores it at startup, so that a restarted bot carries on
+// without first rebuilding its indicators from raw history. Format is "gob"
+// (the default) or "json", which can be read and edited. A snapshot older
+// than MaxAge is ignored; without one any snapshot is restored.
+type StrategyStateConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	Format  string `yaml:"format"`
+	MaxAge  string `yaml:"max_age"`
+}
+
+const (
+	UniverseSourceFile = "file"
+	UniverseSourceKIS  = "kis"
+)
+
+// UniverseConfig selects where KRX symbol master data comes from and defines
+// named symbol universes. With a source set, the traded symbols are checked
+// against the master data at startup. The "kis" source only looks up the
+// symbols that are named in the config.
+type UniverseConfig struct {
+	Source      string                        `yaml:"source"`
+	File        string                        `yaml:"file"`
+	Definitions map[string]UniverseDefinition `yaml:"definitions"`
+}
+
+// UniverseDefinition selects the tradable symbols that match every non-empty
+// criterion. Without Symbols it starts from all symbols in the master data.
+type UniverseDefinition struct {
+	Symbols []string `yaml:"symbols"`
+	Markets []string `yaml:"markets"`
+	Indexes []string `yaml:"indexes"`
+	Sectors []string `yaml:"sectors"`
+	Exclude []string `yaml:"exclude"`
+}
+
+// ScreenConfig configures the stock screener, which filters the members of a
+// universe by daily price, volume, volatility and indicator conditions such as
+// "close > sma(20)". With Schedule set, `run` screens every trading day at that
+// KST time, publishes the result and, with Apply, trades the passing symbols.
+type ScreenConfig struct {
+	Universe       string   `yaml:"universe"`
+	MinPrice       float64  `yaml:"min_price"`
+	MaxPrice       float64  `yaml:"max_price"`
+	MinAvgVolume   float64  `yaml:"min_avg_volume"`
+	VolumeDays     int      `yaml:"volume_days"`
+	MinVolatility  float64  `yaml:"min_volatility"`
+	MaxVolatility  float64  `yaml:"max_volatility"`
+	VolatilityDays int      `yaml:"volatility_days"`
+	Conditions     []string `yaml:"conditions"`
+	MaxResults     int      `yaml:"max_results"`
+	Schedule       string   `yaml:"schedule"`
+	Apply          bool     `yaml:"apply"`
+}
+
+// How tuned parameters are applied, see TuningConfig.
+const (
+	TuningApplyAuto    = "auto"
+	TuningApplyApprove = "approve"
+)
+
+// TuningConfig re-optimizes the moving average periods while `run` trades: on
+// Schedule, a cron expression in the maintenance time zone (default Friday
+// 18:00), the periods in ShortPeriods and LongPeriods are grid-searched with
+// backtests over the last Days daily bars (default 120) of Symbol (default
+// trading_pair). The best set is proposed when its profit beats that of the
+// current periods by more than MinImprovement of Balance. With Apply "auto"
+// it is applied at once; with "approve" (the default) it waits for
+// POST /control/tuning/approve. Applied periods last until the config file is
+// reloaded or the bot restarts.
+type TuningConfig struct {
+	Enabled        bool        `yaml:"enabled"`
+	Schedule       string      `yaml:"schedule"`
+	Symbol         string      `yaml:"symbol"`
+	Days           int         `yaml:"days"`
+	ShortPeriods   TuningRange `yaml:"short_periods"`
+	LongPeriods    TuningRange `yaml:"long_periods"`
+	Balance        float64     `yaml:"balance"`
+	Commission     float64     `yaml:"commission"`
+	MinImprovement float64     `yaml:"min_improvement"`
+	Apply          string      `yaml:"apply"`
+}
+
+// TuningRange is an inclusive range of periods scanned with Step (default 1).
+type TuningRange struct {
+	Min  int `yaml:"min"`
+	Max  int `yaml:"max"`
+	Step int `yaml:"step"`
+}
+
+// Maintenance tasks, see MaintenanceTask.
+const (
+	TaskTokenRefresh = "token_refresh"
+	TaskEODSnapshot  = "eod_snapshot"
+	TaskBackfill     = "backfill"
+	TaskReportEmail  = "report_email"
+	TaskPrune        = "prune"
+)
+
+// DefaultMaintenanceTimezone is the zone schedules are read in by default.
+const DefaultMaintenanceTimezone = "Asia/Seoul"
+
+// MaintenanceConfig schedules housekeeping tasks that `run` carries out next
+// to trading, one at a time. Schedules are read in Timezone, an IANA zone
+// name (default Asia/Seoul).
+type MaintenanceConfig struct {
+	Timezone string            `yaml:"timezone"`
+	Tasks    []MaintenanceTask `yaml:"tasks"`
+}
+
+// MaintenanceTask runs Task on Schedule, a five-field cron expression
+// ("minute hour day-of-month month day-of-week"), and with TradingDays only
+// on KRX trading days. The tasks are "token_refresh", which renews the
+// exchange token, e.g. before the open; "eod_snapshot", which stores the
+// day's cash and positions; "backfill", which fills the daily candles of the
+// traded symbols over the last Days days (default 30); "report_email", which
+// sends the daily report email on this schedule instead of after the close;
+// and "prune", which deletes the intraday candles older than Days days
+// (default 90).
+type MaintenanceTask struct {
+	Task        string `yaml:"task"`
+	Schedule    string `yaml:"schedule"`
+	TradingDays bool   `yaml:"trading_days"`
+	Days        int    `yaml:"days"`
+}
+
+// NotifyConfig configures outbound notifications.
+type NotifyConfig struct {
+	Email    EmailConfig        `yaml:"email"`
+	Webhooks []WebhookConfig    `yaml:"webhooks"`
+	Outbox   NotifyOutboxConfig `yaml:"outbox"`
+}
+
+// NotifyOutboxConfig writes webhook events and emailed reports to the
+// notifications table first and delivers them from there, so they survive
+// network failures and restarts. A failed delivery is retried after
+// RetryDelay, doubling up to MaxDelay, and dead-lettered after MaxAttempts
+// attempts or when the receiver rejects it; later notifications of the same
+// channel wait for it. The defaults are 10 attempts, 10s and 30m.
+type NotifyOutboxConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	MaxAttempts int    `yaml:"max_attempts"`
+	RetryDelay  string `yaml:"retry_delay"`
+	MaxDelay    string `yaml:"max_delay"`
+}
+
+// WebhookConfig posts bus events as JSON to URL. Events selects the event kinds
+// (signal, order, fill, error, circuit, screen, halt); empty means all of them. When Secret is set each
+// request is signed with HMAC-SHA256.
+type WebhookConfig struct {
+	URL        string   `yaml:"url"`
+	Secret     string   `yaml:"secret"`
+	Events     []string `yaml:"events"`
+	Timeout    string   `yaml:"timeout"`
+	MaxRetries int      `yaml:"max_retries"`
+}
+
+// EmailConfig sends an end-of-day report by SMTP once the market has closed.
+// Benchmark is the symbol the day's return is compared against.
+type EmailConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Host      string   `yaml:"host"`
+	Port      int      `yaml:"port"`
+	Username  string   `yaml:"username"`
+	Password  string   `yaml:"password"`
+	From      string   `yaml:"from"`
+	To        []string `yaml:"to"`
+	Benchmark string   `yaml:"benchmark"`
+	SendDelay string   `yaml:"send_delay"`
+}
+
+// MarketConfig controls trading-hours awareness. When enabled the bot only runs
+// trading cycles during KRX sessions and sleeps until the next open otherwise.
+// ExtendedSessions adds the pre-market and after-hours sessions to trade in.
+type MarketConfig struct {
+	Enabled          bool                    `yaml:"enabled"`
+	ExtraHolidays    []string                `yaml:"extra_holidays"`
+	SpecialSessions  map[string]SessionHours `yaml:"special_sessions"`
+	ExtendedSessions []string                `yaml:"extended_sessions"`
+}
+
+// KRX trading sessions. The closing auction ends the regular session; the
+// others are the off-hours sessions that can be listed in
+// MarketConfig.ExtendedSessions.
+const (
+	SessionRegular          = "regular"
+	SessionClosingAuction   = "closing_auction"
+	SessionPreMarket        = "pre_market"
+	SessionAfterHoursClose  = "after_hours_close"
+	SessionAfterHoursSingle = "after_hours_single"
+)
+
+// SessionHours is a session in KST as "HH:MM" open and close times.
+type SessionHours struct {
+	Open  string `yaml:"open"`
+	Close string `yaml:"close"`
+}
+
+const (
+	ShutdownActionNone         = "none"
+	ShutdownActionCancelOrders = "cancel_orders"
+	ShutdownActionFlatten      = "flatten"
+)
+
+// ShutdownConfig controls what happens to open orders and positions when the bot
+// receives SIGINT/SIGTERM. Flatten cancels open orders and sells every holding.
+type ShutdownConfig struct {
+	Action  string `yaml:"action"`
+	Timeout string `yaml:"timeout"`
+}
+
+const (
+	SecretsProviderEnv   = "env"
+	SecretsProviderVault = "vault"
+	SecretsProviderAWS   = "aws"
+	SecretsProviderGCP   = "gcp"
+	SecretsProviderFile  = "file"
+)
+
+// SecretsConfig selects where AppKey/AppSecret and the database password come from.
+// With the default "env" provider they are read from the environment / .env file.
+type SecretsConfig struct {
+	Provider        string `yaml:"provider"`
+	RefreshInterval string `yaml:"refresh_interval"`
+	Vault           struct {
+		Address string `yaml:"address"`
+		Path    string `yaml:"path"`
+		Token   string `yaml:"token"`
+	} `yaml:"vault"`
+	AWS struct {
+		Region   string `yaml:"region"`
+		SecretID string `yaml:"secret_id"`
+	} `yaml:"aws"`
+	GCP struct {
+		Project string `yaml:"project"`
+		Secret  string `yaml:"secret"`
+	} `yaml:"gcp"`
+	File struct {
+		Path    string `yaml:"path"`
+		Keyfile string `yaml:"keyfile"`
+	} `yaml:"file"`
+}
+
+const (
+	AllocationFixed             = "fixed"
+	AllocationInverseVolatility = "inverse_volatility"
+	AllocationPerformance       = "performance"
+)
+
+// AllocationConfig runs several strategies side by side. Each sleeve trades its
+// symbols with its own share of Capital and keeps its own positions and PnL,
+// which are recorded with every order. Shares are rebalanced once a day: by the
+// sleeve weights for the fixed scheme, or from each sleeve's daily returns over
+// the last Lookback days for inverse_volatility and performance. Without
+// sleeves the single configured strategy trades every symbol.
+type AllocationConfig struct {
+	Scheme   string         `yaml:"scheme"`
+	Capital  float64        `yaml:"capital"`
+	Lookback int            `yaml:"lookback"`
+	Sleeves  []SleeveConfig `yaml:"sleeves"`
+}
+
+// SleeveConfig is one strategy of a multi-strategy setup. Strategy names an
+// entry under `strategies` and defaults to `strategy`; Symbols must be trading
+// symbols and default to all of them.
+type SleeveConfig struct {
+	Name     string   `yaml:"name"`
+	Strategy string   `yaml:"strategy"`
+	Symbols  []string `yaml:"symbols"`
+	Weight   float64  `yaml:"weight"`
+}
+
+// StrategyParams holds the raw parameter block of a single entry under `strategies:`.
+// Each strategy decodes it into its own settings type with Decode.
+type StrategyParams map[string]interface{}
+
+// Decode converts the raw parameters into out, which must be a pointer to a struct
+// with yaml tags.
+func (p StrategyParams) Decode(out interface{}) error {
+	raw, err := yaml.Marshal(map[string]interface{}(p))
+	if err != nil {
+		return fmt.Errorf("failed to encode strategy params: %v", err)
+	}
+	if err := yaml.UnmarshalStrict(raw, out); err != nil {
+		return fmt.Errorf("failed to decode strategy params: %v", err)
+	}
+	return nil
 }
 
 func Load(filename string) (*Config, error) {
-	envPath := filepath.Join(filepath.Dir(filename), ".env")
+	return LoadProfile(filename, "")
+}
+
+// LoadProfile loads the config file and overlays the named profile from its
+// `profiles:` section. An empty profile loads the base configuration only.
+// The file may be YAML, JSON (.json) or TOML (.toml).
+func LoadProfile(filename, profile string) (*Config, error) {
+	envPath := envFile(filename, profile)
 	err := godotenv.Load(envPath)
 	if err != nil {
-		fmt.Printf("Warning: Error loading .env file: %v\n", err)
+		fmt.Printf("Warning: Error loading %s file: %v\n", filepath.Base(envPath), err)
 	}
 
-	file, err := os.Open(filename)
+	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open config file: %v", err)
 	}
-	defer file.Close()
+	if data, err = decodeText(data); err != nil {
+		return nil, err
+	}
+	data, err = toYAML(filename, data)
+	if err != nil {
+		return nil, err
+	}
 
 	var config Config
-	decoder := yaml.NewDecoder(file)
-	err = decoder.Decode(&config)
+	err = yaml.Unmarshal(data, &config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode config file: %v", err)
 	}
 
+	if profile != "" {
+		if err := applyProfile(data, profile, &config); err != nil {
+			return nil, err
+		}
+		config.Profile = profile
+	}
+
+	if err := applyEnvOverrides(&config); err != nil {
+		return nil, err
+	}
+	applyExchangeDefaults(&config.Exchange)
+
 	config.Exchange.AppKey = os.Getenv("EXCHANGE_API_KEY")
 	config.Exchange.AppSecret = os.Getenv("EXCHANGE_API_SECRET")
 
-	duration, err := time.ParseDuration(config.PollingInterval)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse polling interval: %v", err)
-	}
-	config.ParsedInterval = duration
+	// An unparsable interval is reported by Validate along with the other problems.
+	config.ParsedInterval, _ = time.ParseDuration(config.PollingInterval)
+	config.ParsedTimeframe, _ = ParseTimeframe(config.Timeframe)
 
 	if err := config.Validate(); err != nil {
 		return nil, err
@@ -64,12 +1140,40 @@ func Load(filename string) (*Config, error) {
 	return &config, nil
 }
 
-func (c *Config) Validate() error {
-	if c.Strategy.ShortPeriod <= 0 || c.Strategy.LongPeriod <= 0 {
-		return fmt.Errorf("strategy periods must be positive")
+// ParseTimeframe parses a candle timeframe such as "5m", "1h" or "1d". Days are
+// accepted in addition to Go durations. An empty timeframe parses as zero.
+func ParseTimeframe(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
 	}
-	if c.Strategy.ShortPeriod >= c.Strategy.LongPeriod {
-		return fmt.Errorf("short period must be less than long period")
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid timeframe %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
 	}
-	return nil
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeframe %q", s)
+	}
+	return d, nil
+}
+
+// TradingSymbols returns the stock codes to trade: the symbols list when set,
+// otherwise the single trading pair.
+func (c *Config) TradingSymbols() []string {
+	if len(c.Symbols) > 0 {
+		return c.Symbols
+	}
+	return []string{c.TradingPair}
+}
+
+// StrategyParamsFor returns the parameter block of the named strategy.
+func (c *Config) StrategyParamsFor(name string) (StrategyParams, error) {
+	params, ok := c.Strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("no configuration for strategy %q", name)
+	}
+	return params, nil
 }
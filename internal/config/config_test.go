@@ -0,0 +1,430 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"tradingbot/internal/models"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestStrategyParamsDecode(t *testing.T) {
+	params := StrategyParams{
+		"short_period": 5,
+		"long_period":  20,
+		"threshold":    0.02,
+	}
+
+	var ma models.MovingAverageConfig
+	if err := params.Decode(&ma); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	want := models.MovingAverageConfig{ShortPeriod: 5, LongPeriod: 20, Threshold: 0.02}
+	if ma != want {
+		t.Errorf("Decode = %+v, want %+v", ma, want)
+	}
+
+	params["unknown"] = true
+	if err := params.Decode(&ma); err == nil {
+		t.Errorf("expected error for unknown parameter")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	old := &Config{
+		DatabaseURL:     "root@tcp(localhost:3306)/tradingbot",
+		Exchange:        ExchangeConfig{Name: "KIS", AccountNo: "1", AccessToken: "token"},
+		TradingPair:     "005930",
+		PollingInterval: "1m",
+		Strategy:        "moving_average",
+		Strategies:      map[string]StrategyParams{"moving_average": {"threshold": 0.01}},
+	}
+	next := &Config{
+		DatabaseURL:     old.DatabaseURL,
+		Exchange:        ExchangeConfig{Name: "KIS", AccountNo: "2"},
+		TradingPair:     "000660",
+		PollingInterval: "1m",
+		Strategy:        "moving_average",
+		Strategies:      map[string]StrategyParams{"moving_average": {"threshold": 0.02}},
+		Exposure:        ExposureConfig{SectorLimits: map[string]float64{"반도체": 0.3}},
+		Liquidity:       LiquidityConfig{MaxADVFraction: 0.05},
+		Correlation:     CorrelationConfig{MaxConcentration: 0.4},
+	}
+
+	safe, unsafe := Changes(old, next)
+	if want := []string{"trading_pair", "strategies", "exposure", "liquidity", "correlation"}; !reflect.DeepEqual(safe, want) {
+		t.Errorf("safe = %v, want %v", safe, want)
+	}
+	if want := []string{"exchange"}; !reflect.DeepEqual(unsafe, want) {
+		t.Errorf("unsafe = %v, want %v", unsafe, want)
+	}
+
+	old.ApplySafe(next)
+	if old.Exposure.SectorLimit("반도체") != 0.3 || old.Liquidity != next.Liquidity || old.Correlation != next.Correlation {
+		t.Errorf("risk limits not applied: %+v, %+v, %+v", old.Exposure, old.Liquidity, old.Correlation)
+	}
+	if old.Exchange.AccountNo != "1" {
+		t.Errorf("exchange applied without a restart: %+v", old.Exchange)
+	}
+}
+
+func TestRedactedMasksSecrets(t *testing.T) {
+	cfg := &Config{
+		DatabaseURL: "bot:db-password@tcp(localhost:3306)/tradingbot",
+		API:         APIConfig{Token: "api-token-1234", Keys: []APIKey{{Name: "ops", Key: "api-key-1234"}}},
+		Telegram:    TelegramConfig{Enabled: true, Token: "123456:telegram-bot-token"},
+	}
+	out, err := cfg.Redacted().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"db-password", "api-token-1234", "api-key-1234", "telegram-bot-token"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("redacted config contains %q:\n%s", secret, out)
+		}
+	}
+	if cfg.Telegram.Token != "123456:telegram-bot-token" || cfg.API.Keys[0].Key != "api-key-1234" {
+		t.Error("Redacted changed the config it was called on")
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	t.Setenv("TRADINGBOT_POLLING_INTERVAL", "5m")
+	t.Setenv("TRADINGBOT_EXCHANGE_ACCOUNT_NO", "12345678")
+	t.Setenv("TRADINGBOT_STRATEGIES_MOVING_AVERAGE_THRESHOLD", "0.05")
+
+	cfg := &Config{
+		PollingInterval: "1m",
+		Exchange:        ExchangeConfig{AccountNo: "1"},
+		Strategies:      map[string]StrategyParams{"moving_average": {"threshold": 0.01}},
+	}
+	if err := applyEnvOverrides(cfg); err != nil {
+		t.Fatalf("applyEnvOverrides returned error: %v", err)
+	}
+
+	if cfg.PollingInterval != "5m" {
+		t.Errorf("PollingInterval = %q, want 5m", cfg.PollingInterval)
+	}
+	if cfg.Exchange.AccountNo != "12345678" {
+		t.Errorf("AccountNo = %q, want 12345678", cfg.Exchange.AccountNo)
+	}
+	if got := cfg.Strategies["moving_average"]["threshold"]; got != 0.05 {
+		t.Errorf("threshold = %v, want 0.05", got)
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	data := []byte(`
+log_level: info
+exchange:
+  name: KIS
+  account_no: "1"
+profiles:
+  live:
+    log_level: warn
+    exchange:
+      mode: live
+`)
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("failed to decode base config: %v", err)
+	}
+	if err := applyProfile(data, "live", &cfg); err != nil {
+		t.Fatalf("applyProfile returned error: %v", err)
+	}
+	applyExchangeDefaults(&cfg.Exchange)
+
+	if cfg.LogLevel != "warn" {
+		t.Errorf("LogLevel = %q, want warn", cfg.LogLevel)
+	}
+	if cfg.Exchange.AccountNo != "1" {
+		t.Errorf("AccountNo = %q, want base value 1", cfg.Exchange.AccountNo)
+	}
+	if cfg.Exchange.BaseURL != LiveBaseURL {
+		t.Errorf("BaseURL = %q, want %q", cfg.Exchange.BaseURL, LiveBaseURL)
+	}
+
+	if err := applyProfile(data, "missing", &cfg); err == nil {
+		t.Errorf("expected error for unknown profile")
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := &Config{
+		DatabaseURL:     "not a dsn",
+		Exchange:        ExchangeConfig{Mode: ModeLive, BaseURL: PaperBaseURL, Approval: LiveApprovalAPI, HTTP: HTTPConfig{Proxy: "proxy:3128"}},
+		TradingPair:     "5930",
+		PollingInterval: "soon",
+		CycleBudget:     1.5,
+		Timeframe:       "7m",
+		Risk:            RiskConfig{LimitUpMargin: 1.5, MaxSpreadBps: -1},
+		Backtest:        BacktestConfig{Intrabar: "worst", DividendTax: 15.4, Portfolio: PortfolioConstraints{MaxTurnover: -0.5}},
+		Monitor:         MonitorConfig{Action: "stop"},
+		Tax:             TaxConfig{LotMethod: "lifo"},
+		MarketData:      MarketDataConfig{Provider: MarketDataHTTP, URL: "vendor", Fallback: "daum", Quality: "fix"},
+		Watchdog:        WatchdogConfig{Enabled: true, CheckInterval: "often"},
+		Margin:          MarginConfig{Enabled: true, Requirement: 0.4, MaxLeverage: 0.5},
+		ETF:             ETFConfig{LPGuard: true, LPOpenDelay: "five minutes"},
+		Rebalance:       RebalanceConfig{Enabled: true, Weights: map[string]float64{"069500": 0.6, "148070": 0.4}, Trigger: RebalanceCalendar, Period: "yearly", CheckInterval: "1h"},
+		Hedger:          HedgerConfig{Enabled: true, Instrument: HedgeInverseETF, InverseETF: "114800", MaxDrawdown: 0.1, Ratio: 1.5, CheckInterval: "1h"},
+		Derivatives:     DerivativesConfig{Enabled: true, Future: "101W12", CoveredCall: CoveredCallConfig{Maturity: "2026-12"}},
+		Kelly:           KellyConfig{Strategies: []string{"momentum"}, Cap: 1.5},
+		Liquidity:       LiquidityConfig{MaxADVFraction: 5, ADVDays: -20},
+		Exposure:        ExposureConfig{MaxSector: 0.3, SectorLimits: map[string]float64{"반도체": 1.5}},
+		Correlation:     CorrelationConfig{MaxConcentration: 0.4, Threshold: 1.2, Days: 2},
+		AdaptivePolling: AdaptivePollingConfig{Enabled: true, MinInterval: "often", MaxInterval: "5m", TargetVolatility: 0.001},
+		Intraday:        IntradayConfig{Enabled: true, PollInterval: "1m", ProfileBins: -1},
+		News:            NewsConfig{Enabled: true, Sources: []string{NewsSourceRSS}, RSSURL: "https://news.example.com/rss", PollInterval: "10m", Window: "24h"},
+		Earnings:        EarningsConfig{Enabled: true, Dates: map[string][]string{"005930": {"10/29"}}},
+		Market:          MarketConfig{ExtendedSessions: []string{"midnight"}},
+		Tuning:          TuningConfig{Enabled: true, Apply: "ask"},
+		Shadow:          ShadowConfig{Enabled: true, Capital: -1},
+		Outbox:          OutboxConfig{Enabled: true, RetryInterval: "30s"},
+		StrategyState:   StrategyStateConfig{Enabled: true, Path: "state.gob", Format: "yaml"},
+		Lock:            LockConfig{Backend: "redis"},
+		API:             APIConfig{Keys: []APIKey{{Name: "ops", Role: "root"}}},
+		Telegram:        TelegramConfig{Enabled: true, Token: "123:abc"},
+		Notify:          NotifyConfig{Outbox: NotifyOutboxConfig{Enabled: true, RetryDelay: "soon"}},
+		Experiment:      ExperimentConfig{Enabled: true, A: "fast", B: "slow", Days: 1},
+		SignalRules:     []SignalRule{{Rule: "delay"}, {Rule: SignalRuleTranches, Tranches: 1}},
+		Maintenance:     MaintenanceConfig{Tasks: []MaintenanceTask{{Task: TaskReportEmail, Schedule: "30 25 * * *"}}},
+		Universe: UniverseConfig{Source: UniverseSourceKIS, Definitions: map[string]UniverseDefinition{
+			"kospi200": {Indexes: []string{"KOSPI200"}, Markets: []string{"NYSE"}},
+		}},
+		Strategy: "rsi",
+		Strategies: map[string]StrategyParams{
+			"moving_average": {"short_period": 10, "long_period": 5, "threshold": 1.5},
+		},
+		Allocation: AllocationConfig{Sleeves: []SleeveConfig{
+			{Name: "fast", Strategy: "macd", Symbols: []string{"000660"}, Weight: 1},
+		}},
+	}
+
+	err := cfg.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate() = %v, want *ValidationError", err)
+	}
+
+	fields := map[string]bool{}
+	for _, fe := range verr.Errors {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{
+		"database_url",
+		"exchange.account_no",
+		"exchange.base_url",
+		"exchange.approval",
+		"exchange.http.proxy",
+		"trading_pair",
+		"polling_interval",
+		"cycle_budget",
+		"timeframe",
+		"risk.max_spread_bps",
+		"risk.limit_up_margin",
+		"market.extended_sessions",
+		"backtest.intrabar",
+		"backtest.portfolio.max_turnover",
+		"backtest.dividend_tax",
+		"monitor.action",
+		"tax.lot_method",
+		"market_data.url",
+		"market_data.fallback",
+		"market_data.quality",
+		"watchdog.check_interval",
+		"margin.max_leverage",
+		"etf.lp_open_delay",
+		"derivatives.covered_call.maturity",
+		"hedger.ratio",
+		"rebalance.period",
+		"earnings.dates.005930",
+		"news.rss_url",
+		"intraday.profile_bins",
+		"kelly.cap",
+		"adaptive_polling.min_interval",
+		"kelly.strategies[0]",
+		"liquidity.max_adv_fraction",
+		"liquidity.adv_days",
+		"exposure.sector_limits.반도체",
+		"correlation.threshold",
+		"correlation.days",
+		"maintenance.tasks[0].task",
+		"tuning.enabled",
+		"tuning.apply",
+		"shadow.strategy",
+		"shadow.capital",
+		"outbox.path",
+		"strategy_state.format",
+		"lock.backend",
+		"api.keys[0].role",
+		"telegram.chat_ids",
+		"notify.outbox.retry_delay",
+		"experiment.b",
+		"experiment.days",
+		"signal_rules[0].rule",
+		"signal_rules[1].tranches",
+		"maintenance.tasks[0].schedule",
+		"universe.definitions.kospi200.symbols",
+		"universe.definitions.kospi200.markets",
+		"allocation.capital",
+		"allocation.sleeves[0].strategy",
+		"allocation.sleeves[0].symbols",
+		"strategy",
+		"strategies.moving_average.short_period",
+		"strategies.moving_average.threshold",
+	} {
+		if !fields[want] {
+			t.Errorf("missing error for %s in %v", want, verr)
+		}
+	}
+}
+
+func TestToYAMLFormats(t *testing.T) {
+	want := map[string]interface{}{
+		"trading_pair": "005930",
+		"exchange":     map[interface{}]interface{}{"account_no": "1", "name": "KIS"},
+		"strategies": map[interface{}]interface{}{
+			"moving_average": map[interface{}]interface{}{"short_period": 5, "threshold": 0.01},
+		},
+	}
+
+	inputs := map[string]string{
+		"config.json": `{"trading_pair": "005930", "exchange": {"name": "KIS", "account_no": "1"},
+			"strategies": {"moving_average": {"short_period": 5, "threshold": 0.01}}}`,
+		"config.toml": `
+trading_pair = "005930" # comment
+exchange = { name = "KIS", account_no = '1' }
+
+[strategies.moving_average]
+short_period = 5
+threshold = 0.01
+`,
+	}
+
+	for name, input := range inputs {
+		out, err := toYAML(name, []byte(input))
+		if err != nil {
+			t.Fatalf("%s: toYAML returned error: %v", name, err)
+		}
+		var got map[string]interface{}
+		if err := yaml.Unmarshal(out, &got); err != nil {
+			t.Fatalf("%s: output is not YAML: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v, want %#v", name, got, want)
+		}
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	for _, input := range []string{
+		"key = ",
+		"key = \"unterminated",
+		"a = 1\na = 2",
+		"[[servers]]",
+	} {
+		if _, err := parseTOML([]byte(input)); err == nil {
+			t.Errorf("parseTOML(%q) succeeded, want error", input)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	want := "trading_pair: \"005930\" # 삼성전자\n"
+	utf16 := func(bigEndian bool) []byte {
+		out := []byte{0xFF, 0xFE}
+		if bigEndian {
+			out = []byte{0xFE, 0xFF}
+		}
+		for _, r := range want {
+			hi, lo := byte(r>>8), byte(r)
+			if bigEndian {
+				out = append(out, hi, lo)
+			} else {
+				out = append(out, lo, hi)
+			}
+		}
+		return out
+	}
+	for name, input := range map[string][]byte{
+		"UTF-8":          []byte(want),
+		"UTF-8 with BOM": append([]byte{0xEF, 0xBB, 0xBF}, want...),
+		"UTF-16LE":       utf16(false),
+		"UTF-16BE":       utf16(true),
+	} {
+		got, err := decodeText(input)
+		if err != nil || string(got) != want {
+			t.Errorf("%s: decodeText = %q, %v", name, got, err)
+		}
+	}
+
+	for _, input := range [][]byte{{0xFF, 0xFE, 'a'}, []byte("symbols: [\xc0]")} {
+		if _, err := decodeText(input); err == nil {
+			t.Errorf("decodeText(%q) succeeded, want error", input)
+		}
+	}
+}
+
+// FuzzLoad feeds config files of every format to Load, which must return an
+// error for those it cannot use instead of panicking.
+func FuzzLoad(f *testing.F) {
+	const yamlConfig = `
+database_url: "root@tcp(localhost:3306)/tradingbot"
+exchange:
+  mode: "paper"
+  account_no: "50000000"
+strategy: "moving_average"
+timeframe: "1m"
+strategies:
+  moving_average:
+    short_period: 5
+    long_period: 20
+    threshold: 0.01
+symbols: ["005930", "000660"]
+polling_interval: "1m"
+`
+	f.Add(uint8(0), []byte(yamlConfig))
+	f.Add(uint8(0), []byte("\ufeff"+yamlConfig))
+	f.Add(uint8(0), []byte("polling_interval: 1,000s\nsymbols: [005930, \"\"]\n"))
+	f.Add(uint8(1), []byte(`{"database_url": "root@tcp(localhost:3306)/tradingbot", "exchange": {"account_no": "50000000"},
+		"strategy": "moving_average", "symbols": ["005930"], "polling_interval": "1m"}`))
+	f.Add(uint8(1), []byte(`{"symbols": [1e999], "max_parallel": "1,000"}`))
+	f.Add(uint8(2), []byte(`
+database_url = "root@tcp(localhost:3306)/tradingbot"
+strategy = "moving_average"
+symbols = ["005930"]
+polling_interval = "1m"
+
+[exchange]
+account_no = '50000000'
+
+[strategies.moving_average]
+short_period = 5
+threshold = 0.01
+`))
+	f.Add(uint8(2), []byte("\xff\xfes\x00y\x00m\x00b\x00o\x00l\x00s\x00 \x00=\x00 \x00[\x00]\x00"))
+	f.Add(uint8(2), []byte("a.b = { c = [1, [2, \"\\u00e9\"]], d = 'x' }\n[a]\n"))
+
+	exts := []string{".yaml", ".json", ".toml"}
+	f.Fuzz(func(t *testing.T, format uint8, data []byte) {
+		dir := t.TempDir()
+		if err := ioutil.WriteFile(filepath.Join(dir, ".env"), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "config"+exts[int(format)%len(exts)])
+		if err := ioutil.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := Load(path)
+		if err != nil {
+			return
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("loaded a config that does not validate: %v", err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is prepended to every environment variable that overrides a config key.
+// The variable name is the upper-cased YAML path joined by underscores, for example
+// TRADINGBOT_POLLING_INTERVAL or TRADINGBOT_EXCHANGE_ACCOUNT_NO.
+const EnvPrefix = "TRADINGBOT"
+
+// applyEnvOverrides replaces config values with those set in the environment.
+func applyEnvOverrides(c *Config) error {
+	if err := overrideStruct(reflect.ValueOf(c).Elem(), EnvPrefix); err != nil {
+		return err
+	}
+
+	// Strategy parameters are free-form, so only keys already present in the
+	// file can be overridden, e.g. TRADINGBOT_STRATEGIES_MOVING_AVERAGE_THRESHOLD.
+	for name, params := range c.Strategies {
+		for key := range params {
+			value, ok := os.LookupEnv(envName(EnvPrefix, "strategies", name, key))
+			if !ok {
+				continue
+			}
+			var parsed interface{}
+			if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+				return fmt.Errorf("invalid value for %s: %v", envName(EnvPrefix, "strategies", name, key), err)
+			}
+			params[key] = parsed
+		}
+	}
+	return nil
+}
+
+func overrideStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := envName(prefix, tag)
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := overrideStruct(fv, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", v.Type())
+		}
+		items := strings.Split(value, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func envName(parts ...string) string {
+	name := strings.Join(parts, "_")
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
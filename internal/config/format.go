@@ -0,0 +1,138 @@
+package config
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v2"
+)
+
+// decodeText returns a config file as UTF-8 without byte order mark. Windows
+// editors save files with a UTF-8 BOM, which JSON and TOML do not allow, or
+// as UTF-16, which none of the formats read; both are converted.
+func decodeText(data []byte) ([]byte, error) {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		order = binary.BigEndian
+	}
+	if order != nil {
+		data = data[2:]
+		if len(data)%2 != 0 {
+			return nil, fmt.Errorf("config file is truncated UTF-16")
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		data = []byte(string(utf16.Decode(units)))
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("config file is not UTF-8 or UTF-16 text")
+	}
+	return data, nil
+}
+
+// toYAML converts a JSON or TOML config file, detected by extension, to YAML so the
+// rest of the loader only deals with one format. YAML input is returned unchanged.
+func toYAML(filename string, data []byte) ([]byte, error) {
+	var doc interface{}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON config: %v", err)
+		}
+	case ".toml":
+		table, err := parseTOML(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode TOML config: %v", err)
+		}
+		doc = table
+	default:
+		return data, nil
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert config to YAML: %v", err)
+	}
+	return out, nil
+}
+
+const redacted = "********"
+
+// Redacted returns a copy of the config with passwords and tokens masked, suitable
+// for printing.
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.Exchange.AppKey = ""
+	out.Exchange.AppSecret = ""
+	out.Exchange.AccessToken = ""
+	if dsn, err := mysql.ParseDSN(c.DatabaseURL); err == nil && dsn.Passwd != "" {
+		dsn.Passwd = redacted
+		out.DatabaseURL = dsn.FormatDSN()
+	}
+	if out.MarketData.APIKey != "" {
+		out.MarketData.APIKey = redacted
+	}
+	if out.Earnings.DartAPIKey != "" {
+		out.Earnings.DartAPIKey = redacted
+	}
+	if out.News.NaverClientSecret != "" {
+		out.News.NaverClientSecret = redacted
+	}
+	if out.Secrets.Vault.Token != "" {
+		out.Secrets.Vault.Token = redacted
+	}
+	if out.API.Token != "" {
+		out.API.Token = redacted
+	}
+	if out.API.JWT.Secret != "" {
+		out.API.JWT.Secret = redacted
+	}
+	if len(c.API.Keys) > 0 {
+		out.API.Keys = make([]APIKey, len(c.API.Keys))
+		for i, k := range c.API.Keys {
+			if k.Key != "" {
+				k.Key = redacted
+			}
+			out.API.Keys[i] = k
+		}
+	}
+	if out.Telegram.Token != "" {
+		out.Telegram.Token = redacted
+	}
+	if out.API.TradingView.Passphrase != "" {
+		out.API.TradingView.Passphrase = redacted
+	}
+	if out.Notify.Email.Password != "" {
+		out.Notify.Email.Password = redacted
+	}
+	if len(c.Notify.Webhooks) > 0 {
+		out.Notify.Webhooks = make([]WebhookConfig, len(c.Notify.Webhooks))
+		for i, w := range c.Notify.Webhooks {
+			if w.Secret != "" {
+				w.Secret = redacted
+			}
+			out.Notify.Webhooks[i] = w
+		}
+	}
+	return &out
+}
+
+// Marshal encodes the config as YAML, the canonical form of the effective configuration.
+func (c *Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(c)
+}
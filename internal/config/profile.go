@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ModePaper = "paper"
+	ModeLive  = "live"
+
+	PaperBaseURL = "https://openapivts.koreainvestment.com:29443"
+	LiveBaseURL  = "https://openapi.koreainvestment.com:9443"
+)
+
+// applyProfile overlays the named entry of the top-level `profiles:` map onto config.
+// Keys missing from the profile keep the values from the base file.
+func applyProfile(data []byte, profile string, config *Config) error {
+	var file struct {
+		Profiles map[string]interface{} `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to decode profiles: %v", err)
+	}
+
+	overlay, ok := file.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found in config file", profile)
+	}
+
+	raw, err := yaml.Marshal(overlay)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile %q: %v", profile, err)
+	}
+	if err := yaml.Unmarshal(raw, config); err != nil {
+		return fmt.Errorf("failed to apply profile %q: %v", profile, err)
+	}
+	return nil
+}
+
+// envFile returns the dotenv file holding credentials for the profile. Each profile
+// has its own file so live credentials are never picked up by a paper profile.
+func envFile(filename, profile string) string {
+	name := ".env"
+	if profile != "" {
+		name += "." + profile
+	}
+	return filepath.Join(filepath.Dir(filename), name)
+}
+
+// applyExchangeDefaults fills in the trading mode and matching base URL when unset.
+func applyExchangeDefaults(c *ExchangeConfig) {
+	if c.Mode == "" {
+		c.Mode = ModePaper
+	}
+	if c.BaseURL == "" {
+		if c.Mode == ModeLive {
+			c.BaseURL = LiveBaseURL
+		} else {
+			c.BaseURL = PaperBaseURL
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML decodes the subset of TOML used by config files: tables, dotted keys,
+// strings, numbers, booleans, arrays and inline tables. Dates are kept as strings.
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	p := &tomlParser{src: []rune(string(data)), line: 1}
+	root := map[string]interface{}{}
+	current := root
+
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			table, err := p.parseTableHeader(root)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			current = table
+		} else {
+			key, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+			if !p.consume('=') {
+				return nil, p.errorf("expected '=' after key %s", strings.Join(key, "."))
+			}
+			p.skipSpaces()
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := setTOMLKey(current, key, value); err != nil {
+				return nil, p.errorf("%v", err)
+			}
+		}
+
+		p.skipSpaces()
+		p.skipComment()
+		if !p.eof() && !p.consume('\n') {
+			return nil, p.errorf("unexpected %q after value", p.peek())
+		}
+	}
+}
+
+type tomlParser struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *tomlParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) consume(r rune) bool {
+	if p.peek() != r || p.eof() {
+		return false
+	}
+	if r == '\n' {
+		p.line++
+	}
+	p.pos++
+	return true
+}
+
+func (p *tomlParser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\r' {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips whitespace, comments and newlines.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpaces()
+		p.skipComment()
+		if !p.consume('\n') {
+			return
+		}
+	}
+}
+
+func (p *tomlParser) parseTableHeader(root map[string]interface{}) (map[string]interface{}, error) {
+	p.pos++ // '['
+	if p.peek() == '[' {
+		return nil, fmt.Errorf("arrays of tables are not supported")
+	}
+	p.skipSpaces()
+	key, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if !p.consume(']') {
+		return nil, fmt.Errorf("expected ']' to close table header")
+	}
+
+	table := root
+	for _, part := range key {
+		next, ok := table[part]
+		if !ok {
+			child := map[string]interface{}{}
+			table[part] = child
+			table = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("key %s is not a table", part)
+		}
+		table = child
+	}
+	return table, nil
+}
+
+func (p *tomlParser) parseKey() ([]string, error) {
+	var parts []string
+	for {
+		p.skipSpaces()
+		var part string
+		switch r := p.peek(); {
+		case r == '"' || r == '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyRune(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected key, found %q", p.peek())
+			}
+			part = string(p.src[start:p.pos])
+		}
+		parts = append(parts, part)
+
+		p.skipSpaces()
+		if !p.consume('.') {
+			return parts, nil
+		}
+	}
+}
+
+func isBareKeyRune(r rune) bool {
+	return r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	switch r := p.peek(); {
+	case r == '"' || r == '\'':
+		return p.parseString()
+	case r == '[':
+		return p.parseArray()
+	case r == '{':
+		return p.parseInlineTable()
+	default:
+		start := p.pos
+		for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", p.peek()) {
+			p.pos++
+		}
+		return parseTOMLScalar(string(p.src[start:p.pos]), p)
+	}
+}
+
+func parseTOMLScalar(token string, p *tomlParser) (interface{}, error) {
+	switch token {
+	case "":
+		return nil, p.errorf("missing value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	clean := strings.Replace(token, "_", "", -1)
+	if i, err := strconv.ParseInt(clean, 0, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(clean, 64); err == nil {
+		return f, nil
+	}
+	if token[0] >= '0' && token[0] <= '9' && strings.ContainsAny(token, "-:") {
+		return token, nil // date or time
+	}
+	return nil, p.errorf("invalid value %q", token)
+}
+
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.peek()
+	p.pos++
+
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		r := p.peek()
+		p.pos++
+		if r == quote {
+			return b.String(), nil
+		}
+		if r != '\\' || quote == '\'' {
+			b.WriteRune(r)
+			continue
+		}
+
+		esc := p.peek()
+		p.pos++
+		switch esc {
+		case 'n':
+			b.WriteRune('\n')
+		case 't':
+			b.WriteRune('\t')
+		case 'r':
+			b.WriteRune('\r')
+		case '"', '\\':
+			b.WriteRune(esc)
+		case 'u', 'U':
+			size := 4
+			if esc == 'U' {
+				size = 8
+			}
+			if p.pos+size > len(p.src) {
+				return "", p.errorf("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(string(p.src[p.pos:p.pos+size]), 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid unicode escape")
+			}
+			b.WriteRune(rune(code))
+			p.pos += size
+		default:
+			return "", p.errorf("invalid escape \\%c", esc)
+		}
+	}
+}
+
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++ // '['
+	items := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.consume(']') {
+			return items, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+
+		p.skipBlank()
+		if p.consume(',') {
+			continue
+		}
+		p.skipBlank()
+		if !p.consume(']') {
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+		return items, nil
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (map[string]interface{}, error) {
+	p.pos++ // '{'
+	table := map[string]interface{}{}
+	p.skipSpaces()
+	if p.consume('}') {
+		return table, nil
+	}
+	for {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.consume('=') {
+			return nil, p.errorf("expected '=' in inline table")
+		}
+		p.skipSpaces()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := setTOMLKey(table, key, value); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+
+		p.skipSpaces()
+		if p.consume('}') {
+			return table, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+		p.skipSpaces()
+	}
+}
+
+func setTOMLKey(table map[string]interface{}, key []string, value interface{}) error {
+	for _, part := range key[:len(key)-1] {
+		next, ok := table[part]
+		if !ok {
+			child := map[string]interface{}{}
+			table[part] = child
+			table = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("key %s is not a table", part)
+		}
+		table = child
+	}
+
+	last := key[len(key)-1]
+	if _, exists := table[last]; exists {
+		return fmt.Errorf("duplicate key %s", strings.Join(key, "."))
+	}
+	table[last] = value
+	return nil
+}
@@ -0,0 +1,1261 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"tradingbot/internal/cron"
+	"tradingbot/internal/models"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+// symbolPattern matches KRX short codes such as 005930 or 0001A0.
+var symbolPattern = regexp.MustCompile(`^[0-9A-Z]{6}$`)
+
+// strategyValidators checks the parameter block of every known strategy.
+var strategyValidators = map[string]func(params StrategyParams, path string, errs *ValidationError){
+	"moving_average": validateMovingAverage,
+	"nav_deviation":  validateNAVDeviation,
+}
+
+// FieldError describes a single invalid config value.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError collects every problem found in a config so they can be fixed at once.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		lines[i] = "  " + fe.Error()
+	}
+	return fmt.Sprintf("invalid config (%d problems):\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks every field of the config and returns a *ValidationError listing
+// all problems, or nil when the config is usable.
+func (c *Config) Validate() error {
+	errs := &ValidationError{}
+
+	if c.DatabaseURL == "" {
+		errs.add("database_url", "must be set")
+	} else if _, err := mysql.ParseDSN(c.DatabaseURL); err != nil {
+		errs.add("database_url", "not a valid MySQL DSN: %v", err)
+	}
+
+	if c.Exchange.AccountNo == "" {
+		errs.add("exchange.account_no", "must be set")
+	}
+	switch c.Exchange.Mode {
+	case ModePaper:
+		if c.Exchange.BaseURL == LiveBaseURL {
+			errs.add("exchange.base_url", "paper mode cannot use the live base URL %s", LiveBaseURL)
+		}
+	case ModeLive:
+		if c.Exchange.BaseURL == PaperBaseURL {
+			errs.add("exchange.base_url", "live mode cannot use the paper base URL %s", PaperBaseURL)
+		}
+	default:
+		errs.add("exchange.mode", "unknown mode %q (want %q or %q)", c.Exchange.Mode, ModePaper, ModeLive)
+	}
+	switch c.Exchange.Approval {
+	case "", LiveApprovalPrompt:
+	case LiveApprovalAPI:
+		if c.Exchange.Mode == ModeLive && !c.API.Enabled {
+			errs.add("exchange.approval", "approval via the API needs api.enabled")
+		}
+	default:
+		errs.add("exchange.approval", "unknown approval %q (want %q or %q)", c.Exchange.Approval, LiveApprovalPrompt, LiveApprovalAPI)
+	}
+
+	if h := c.Exchange.HTTP; h.Proxy != "" {
+		if u, err := url.Parse(h.Proxy); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+			errs.add("exchange.http.proxy", "must be an http, https or socks5 URL")
+		}
+	}
+	if h := c.Exchange.HTTP; (h.CertFile == "") != (h.KeyFile == "") {
+		errs.add("exchange.http.key_file", "cert_file and key_file must be set together")
+	}
+	switch c.Exchange.HTTP.MinTLSVersion {
+	case "", "1.2", "1.3":
+	default:
+		errs.add("exchange.http.min_tls_version", "unknown TLS version %q (want 1.2 or 1.3)", c.Exchange.HTTP.MinTLSVersion)
+	}
+	if t := c.Exchange.HTTP.Timeout; t != "" {
+		if v, err := time.ParseDuration(t); err != nil || v <= 0 {
+			errs.add("exchange.http.timeout", "invalid duration %q", t)
+		}
+	}
+
+	if len(c.Symbols) == 0 && !symbolPattern.MatchString(c.TradingPair) {
+		errs.add("trading_pair", "%q is not a 6-character KRX code", c.TradingPair)
+	}
+	for i, symbol := range c.Symbols {
+		if !symbolPattern.MatchString(symbol) {
+			errs.add(fmt.Sprintf("symbols[%d]", i), "%q is not a 6-character KRX code", symbol)
+		}
+	}
+	switch md := c.MarketData; md.Provider {
+	case "", MarketDataKIS:
+	case MarketDataDatabase:
+		if md.DatabaseURL != "" {
+			if _, err := mysql.ParseDSN(md.DatabaseURL); err != nil {
+				errs.add("market_data.database_url", "not a valid MySQL DSN: %v", err)
+			}
+		}
+	case MarketDataHTTP:
+		if u, err := url.Parse(md.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs.add("market_data.url", "must be an absolute URL for the http provider")
+		}
+	default:
+		errs.add("market_data.provider", "unknown provider %q (want %s, %s or %s)", md.Provider, MarketDataKIS, MarketDataDatabase, MarketDataHTTP)
+	}
+	for _, d := range []struct{ path, value string }{
+		{"market_data.timeout", c.MarketData.Timeout},
+		{"market_data.retry_after", c.MarketData.RetryAfter},
+		{"market_data.check_interval", c.MarketData.CheckInterval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil || v <= 0 {
+			errs.add(d.path, "invalid duration %q", d.value)
+		}
+	}
+	if md := c.MarketData; md.Fallback != "" && md.Fallback != MarketDataNaver {
+		errs.add("market_data.fallback", "unknown fallback %q (want %s)", md.Fallback, MarketDataNaver)
+	}
+	if md := c.MarketData; md.MaxDeviation < 0 || md.MaxDeviation >= 1 {
+		errs.add("market_data.max_deviation", "must be between 0 and 1")
+	} else if md.MaxDeviation > 0 && md.Fallback == "" {
+		errs.add("market_data.max_deviation", "requires a fallback to check prices against")
+	}
+	switch q := c.MarketData.Quality; q {
+	case "", QualityFlag, QualityRepair, QualityReject:
+	default:
+		errs.add("market_data.quality", "unknown action %q (want %s, %s or %s)", q, QualityFlag, QualityRepair, QualityReject)
+	}
+	if j := c.MarketData.MaxJump; j < 0 || j >= 1 {
+		errs.add("market_data.max_jump", "must be between 0 and 1")
+	}
+	if cc := c.MarketData.Cache; cc.Enabled {
+		if cc.MaxCandles < 0 {
+			errs.add("market_data.cache.max_candles", "must not be negative")
+		}
+		if cc.TTL != "" {
+			if v, err := time.ParseDuration(cc.TTL); err != nil || v <= 0 {
+				errs.add("market_data.cache.ttl", "invalid duration %q", cc.TTL)
+			}
+		}
+	}
+	if c.MaxParallel < 0 {
+		errs.add("max_parallel", "must not be negative")
+	}
+	if c.CycleBudget < 0 || c.CycleBudget > 1 {
+		errs.add("cycle_budget", "must be between 0 and 1")
+	}
+
+	interval, err := time.ParseDuration(c.PollingInterval)
+	if err != nil {
+		errs.add("polling_interval", "invalid duration %q", c.PollingInterval)
+	} else if interval <= 0 {
+		errs.add("polling_interval", "must be positive")
+	}
+	if timeframe, err := ParseTimeframe(c.Timeframe); err != nil {
+		errs.add("timeframe", "invalid timeframe %q (e.g. 5m, 1h, 1d)", c.Timeframe)
+	} else if c.Timeframe != "" {
+		switch {
+		case timeframe <= 0:
+			errs.add("timeframe", "must be positive")
+		case (24*time.Hour)%timeframe != 0:
+			errs.add("timeframe", "%s does not divide a day evenly", c.Timeframe)
+		case interval > 0 && timeframe%interval != 0:
+			errs.add("timeframe", "must be a multiple of polling_interval %s", c.PollingInterval)
+		}
+	}
+	if c.BarClose && c.Timeframe == "" {
+		errs.add("bar_close", "needs a timeframe")
+	}
+
+	if a := c.AdaptivePolling; a.Enabled {
+		min, errMin := time.ParseDuration(a.MinInterval)
+		if errMin != nil || min <= 0 {
+			errs.add("adaptive_polling.min_interval", "invalid duration %q", a.MinInterval)
+		}
+		max, errMax := time.ParseDuration(a.MaxInterval)
+		if errMax != nil || max <= 0 {
+			errs.add("adaptive_polling.max_interval", "invalid duration %q", a.MaxInterval)
+		}
+		if errMin == nil && errMax == nil && interval > 0 && (min > interval || max < interval) {
+			errs.add("adaptive_polling", "polling_interval %s must be within min_interval and max_interval", c.PollingInterval)
+		}
+		if timeframe, err := ParseTimeframe(c.Timeframe); err == nil && timeframe > 0 && errMax == nil && max > timeframe {
+			errs.add("adaptive_polling.max_interval", "must not exceed the timeframe %s", c.Timeframe)
+		}
+		if a.Window < 0 || a.Window == 1 {
+			errs.add("adaptive_polling.window", "must be at least 2")
+		}
+		if a.TargetVolatility <= 0 {
+			errs.add("adaptive_polling.target_volatility", "must be positive")
+		}
+		if a.TargetSpread < 0 {
+			errs.add("adaptive_polling.target_spread", "must not be negative")
+		}
+	}
+
+	if c.LogLevel != "" {
+		if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+			errs.add("log_level", "unknown level %q", c.LogLevel)
+		}
+	}
+
+	for i, sink := range c.Logging.Sinks {
+		validateLogSink(sink, fmt.Sprintf("logging.sinks[%d]", i), errs)
+	}
+
+	if c.Risk.MaxOrderAmount < 0 {
+		errs.add("risk.max_order_amount", "must not be negative")
+	}
+	if c.Risk.MaxQuoteAge != "" {
+		if d, err := time.ParseDuration(c.Risk.MaxQuoteAge); err != nil || d <= 0 {
+			errs.add("risk.max_quote_age", "invalid duration %q", c.Risk.MaxQuoteAge)
+		}
+	}
+	if c.Risk.MaxSpreadBps < 0 {
+		errs.add("risk.max_spread_bps", "must not be negative")
+	}
+	if c.Risk.LimitUpMargin < 0 || c.Risk.LimitUpMargin >= 1 {
+		errs.add("risk.limit_up_margin", "must be between 0 and 1")
+	}
+
+	if cb := c.CircuitBreaker; cb.Enabled {
+		if cb.FailureThreshold <= 0 {
+			errs.add("circuit_breaker.failure_threshold", "must be positive")
+		}
+		if cb.LatencyThreshold != "" {
+			if d, err := time.ParseDuration(cb.LatencyThreshold); err != nil || d <= 0 {
+				errs.add("circuit_breaker.latency_threshold", "invalid duration %q", cb.LatencyThreshold)
+			}
+		}
+		if d, err := time.ParseDuration(cb.Cooldown); err != nil || d <= 0 {
+			errs.add("circuit_breaker.cooldown", "invalid duration %q", cb.Cooldown)
+		}
+	}
+
+	if w := c.Watchdog; w.Enabled {
+		for _, d := range []struct{ field, value string }{
+			{"watchdog.market_data_timeout", w.MarketDataTimeout},
+			{"watchdog.loop_timeout", w.LoopTimeout},
+			{"watchdog.check_interval", w.CheckInterval},
+		} {
+			if d.value == "" {
+				continue
+			}
+			if v, err := time.ParseDuration(d.value); err != nil || v <= 0 {
+				errs.add(d.field, "invalid duration %q", d.value)
+			}
+		}
+		if d, err := time.ParseDuration(w.LoopTimeout); err == nil && interval > 0 && d <= interval {
+			errs.add("watchdog.loop_timeout", "%s must exceed the polling interval of %s", w.LoopTimeout, c.PollingInterval)
+		}
+		if d, err := time.ParseDuration(w.LoopTimeout); err == nil && c.AdaptivePolling.Enabled {
+			if max, err := time.ParseDuration(c.AdaptivePolling.MaxInterval); err == nil && d <= max {
+				errs.add("watchdog.loop_timeout", "%s must exceed the adaptive max_interval of %s", w.LoopTimeout, c.AdaptivePolling.MaxInterval)
+			}
+		}
+	}
+
+	if e := c.Earnings; e.Enabled {
+		if e.BlackoutDays < 0 {
+			errs.add("earnings.blackout_days", "must not be negative")
+		}
+		for name, days := range e.Strategies {
+			if days < 0 {
+				errs.add("earnings.strategies."+name, "must not be negative")
+			}
+		}
+		for symbol, dates := range e.Dates {
+			if !symbolPattern.MatchString(symbol) {
+				errs.add("earnings.dates."+symbol, "not a 6-character KRX code")
+			}
+			for _, day := range dates {
+				if _, err := time.Parse("2006-01-02", day); err != nil {
+					errs.add("earnings.dates."+symbol, "invalid date %q, want YYYY-MM-DD", day)
+				}
+			}
+		}
+	}
+
+	if n := c.News; n.Enabled {
+		if len(n.Sources) == 0 {
+			errs.add("news.sources", "must not be empty")
+		}
+		for i, source := range n.Sources {
+			path := fmt.Sprintf("news.sources[%d]", i)
+			switch source {
+			case NewsSourceRSS:
+				if !strings.Contains(n.RSSURL, "{query}") {
+					errs.add("news.rss_url", "must contain {query}")
+				}
+			case NewsSourceNaver:
+				if n.NaverClientID == "" || n.NaverClientSecret == "" {
+					errs.add(path, "naver requires naver_client_id and naver_client_secret")
+				}
+			default:
+				errs.add(path, "unknown source %q (want %s or %s)", source, NewsSourceRSS, NewsSourceNaver)
+			}
+		}
+		for _, d := range []struct{ field, value string }{
+			{"news.poll_interval", n.PollInterval},
+			{"news.window", n.Window},
+		} {
+			if v, err := time.ParseDuration(d.value); err != nil || v <= 0 {
+				errs.add(d.field, "invalid duration %q", d.value)
+			}
+		}
+		switch n.Scorer {
+		case "", NewsScorerLexicon:
+		case NewsScorerHTTP:
+			if u, err := url.Parse(n.ScorerURL); err != nil || u.Scheme == "" || u.Host == "" {
+				errs.add("news.scorer_url", "must be an absolute URL for the http scorer")
+			}
+		default:
+			errs.add("news.scorer", "unknown scorer %q (want %s or %s)", n.Scorer, NewsScorerLexicon, NewsScorerHTTP)
+		}
+		for word, weight := range n.Lexicon {
+			if weight < -1 || weight > 1 {
+				errs.add("news.lexicon."+word, "must be in [-1, 1], got %v", weight)
+			}
+		}
+	}
+
+	if in := c.Intraday; in.Enabled {
+		if v, err := time.ParseDuration(in.PollInterval); err != nil || v <= 0 {
+			errs.add("intraday.poll_interval", "invalid duration %q", in.PollInterval)
+		}
+		if in.ProfileBins < 0 {
+			errs.add("intraday.profile_bins", "must not be negative")
+		}
+	}
+
+	validateSecrets(c.Secrets, errs)
+
+	for _, day := range c.Market.ExtraHolidays {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			errs.add("market.extra_holidays", "invalid date %q, want YYYY-MM-DD", day)
+		}
+	}
+	for day, session := range c.Market.SpecialSessions {
+		path := "market.special_sessions." + day
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			errs.add(path, "invalid date, want YYYY-MM-DD")
+		}
+		open, err1 := time.Parse("15:04", session.Open)
+		close, err2 := time.Parse("15:04", session.Close)
+		if err1 != nil || err2 != nil {
+			errs.add(path, "open and close must be HH:MM")
+		} else if !close.After(open) {
+			errs.add(path, "close must be after open")
+		}
+	}
+	for _, session := range c.Market.ExtendedSessions {
+		if !containsString(extendedSessions, session) {
+			errs.add("market.extended_sessions", "unknown session %q (want %s)", session, strings.Join(extendedSessions, ", "))
+		}
+	}
+
+	if c.API.Enabled {
+		if c.API.Listen == "" {
+			errs.add("api.listen", "must be set when the API is enabled")
+		}
+		if c.API.Token == "" && len(c.API.Keys) == 0 && c.API.JWT.Secret == "" {
+			errs.add("api.token", "must be set, or api.keys or api.jwt, when the API is enabled")
+		}
+		if c.API.Token != "" && len(c.API.Token) < 16 {
+			errs.add("api.token", "must be at least 16 characters")
+		}
+	}
+	keyNames := map[string]bool{}
+	for i, k := range c.API.Keys {
+		path := fmt.Sprintf("api.keys[%d]", i)
+		if k.Name == "" {
+			errs.add(path+".name", "must be set")
+		} else if keyNames[k.Name] {
+			errs.add(path+".name", "duplicate key name %q", k.Name)
+		}
+		keyNames[k.Name] = true
+		if k.Key != "" && len(k.Key) < 16 {
+			errs.add(path+".key", "must be at least 16 characters")
+		}
+		if !containsString(apiRoles, k.Role) {
+			errs.add(path+".role", "unknown role %q (want %s)", k.Role, strings.Join(apiRoles, ", "))
+		}
+	}
+	if s := c.API.JWT.Secret; s != "" && len(s) < 32 {
+		errs.add("api.jwt.secret", "must be at least 32 characters")
+	}
+	if k := c.KillSwitch; k.CheckInterval != "" {
+		if d, err := time.ParseDuration(k.CheckInterval); err != nil || d <= 0 {
+			errs.add("kill_switch.check_interval", "invalid duration %q", k.CheckInterval)
+		}
+	}
+	if t := c.Telegram; t.Enabled {
+		if t.Token == "" {
+			errs.add("telegram.token", "must be set when Telegram is enabled")
+		}
+		if len(t.ChatIDs) == 0 {
+			errs.add("telegram.chat_ids", "must list the chats allowed to send commands")
+		}
+	}
+	if c.API.TradingView.Enabled {
+		if !c.API.Enabled {
+			errs.add("api.tradingview.enabled", "requires api.enabled")
+		}
+		if len(c.API.TradingView.Passphrase) < 16 {
+			errs.add("api.tradingview.passphrase", "must be at least 16 characters when TradingView alerts are enabled")
+		}
+	}
+
+	if c.Audit.Enabled && c.Audit.Path == "" {
+		errs.add("audit.path", "must be set when the audit log is enabled")
+	}
+	switch c.Lock.Backend {
+	case "", LockDatabase:
+	case LockFile:
+		if c.Lock.Path == "" {
+			errs.add("lock.path", "must be set for the file backend")
+		}
+	default:
+		errs.add("lock.backend", "unknown backend %q (want %s or %s)", c.Lock.Backend, LockDatabase, LockFile)
+	}
+	if c.Lock.CheckInterval != "" {
+		if v, err := time.ParseDuration(c.Lock.CheckInterval); err != nil || v <= 0 {
+			errs.add("lock.check_interval", "invalid duration %q", c.Lock.CheckInterval)
+		}
+	}
+	if o := c.Outbox; o.Enabled {
+		if o.Path == "" {
+			errs.add("outbox.path", "must be set when the outbox is enabled")
+		}
+		if v, err := time.ParseDuration(o.RetryInterval); err != nil || v <= 0 {
+			errs.add("outbox.retry_interval", "invalid duration %q", o.RetryInterval)
+		}
+	}
+
+	if st := c.StrategyState; st.Enabled {
+		if st.Path == "" {
+			errs.add("strategy_state.path", "must be set when strategy state is enabled")
+		}
+		if st.Format != "" && st.Format != "gob" && st.Format != "json" {
+			errs.add("strategy_state.format", "must be gob or json, got %q", st.Format)
+		}
+		if st.MaxAge != "" {
+			if v, err := time.ParseDuration(st.MaxAge); err != nil || v <= 0 {
+				errs.add("strategy_state.max_age", "invalid duration %q", st.MaxAge)
+			}
+		}
+	}
+
+	if p := c.Position; p.TargetQuantity < 0 || p.TargetNotional < 0 || p.ScaleIn < 0 || p.ScaleInNotional < 0 || p.ScaleOut < 0 {
+		errs.add("position", "quantities and notionals must not be negative")
+	}
+	if c.Position.TargetQuantity > 0 && c.Position.TargetNotional > 0 {
+		errs.add("position.target_notional", "set either target_quantity or target_notional")
+	}
+	if c.Position.ScaleIn > 0 && c.Position.ScaleInNotional > 0 {
+		errs.add("position.scale_in_notional", "set either scale_in or scale_in_notional")
+	}
+	validateKelly(c, errs)
+	if l := c.Liquidity; l.MaxADVFraction < 0 || l.MaxADVFraction > 1 {
+		errs.add("liquidity.max_adv_fraction", "must be in [0, 1], got %v", l.MaxADVFraction)
+	}
+	if c.Liquidity.ADVDays < 0 {
+		errs.add("liquidity.adv_days", "must not be negative")
+	}
+	validateExposure(c, errs)
+	if cc := c.Correlation; cc.MaxConcentration < 0 || cc.MaxConcentration > 1 {
+		errs.add("correlation.max_concentration", "must be in [0, 1], got %v", cc.MaxConcentration)
+	}
+	if cc := c.Correlation; cc.Threshold < 0 || cc.Threshold > 1 {
+		errs.add("correlation.threshold", "must be in [0, 1], got %v", cc.Threshold)
+	}
+	if d := c.Correlation.Days; d < 0 || d == 1 || d == 2 {
+		errs.add("correlation.days", "must be at least 3")
+	}
+	if c.Fees.CommissionRate < 0 || c.Fees.CommissionRate >= 1 || c.Fees.SellTaxRate < 0 || c.Fees.SellTaxRate >= 1 {
+		errs.add("fees", "rates must be fractions between 0 and 1")
+	}
+	switch c.Tax.LotMethod {
+	case "", LotFIFO, LotSpecific:
+	default:
+		errs.add("tax.lot_method", "unknown lot method %q (want %s or %s)", c.Tax.LotMethod, LotFIFO, LotSpecific)
+	}
+	if c.CashSweep.Enabled {
+		if c.CashSweep.Symbol == "" {
+			errs.add("cash_sweep.symbol", "must be set when the cash sweep is enabled")
+		} else if containsString(c.TradingSymbols(), c.CashSweep.Symbol) {
+			errs.add("cash_sweep.symbol", "%s is also a trading symbol", c.CashSweep.Symbol)
+		}
+	}
+	if c.CashSweep.Reserve < 0 || c.CashSweep.MinAmount < 0 {
+		errs.add("cash_sweep", "reserve and min_amount must not be negative")
+	}
+	if m := c.Margin; m.Enabled {
+		switch m.CreditType {
+		case "", CreditSelf, CreditDistribution:
+		default:
+			errs.add("margin.credit_type", "unknown credit type %q (want %s or %s)", m.CreditType, CreditSelf, CreditDistribution)
+		}
+		if m.Requirement <= 0 || m.Requirement > 1 {
+			errs.add("margin.requirement", "must be greater than 0 and at most 1")
+		}
+		if m.MaxLeverage != 0 && m.MaxLeverage < 1 {
+			errs.add("margin.max_leverage", "must be at least 1, or 0 for no limit")
+		}
+	}
+	if c.Margin.InterestRate < 0 || c.Margin.InterestRate >= 1 {
+		errs.add("margin.interest_rate", "must be between 0 and 1")
+	}
+	if d := c.ETF.LPOpenDelay; d != "" {
+		if parsed, err := time.ParseDuration(d); err != nil || parsed < 0 {
+			errs.add("etf.lp_open_delay", "invalid duration %q", d)
+		}
+	}
+	if c.ETF.MaxBuyPremium < 0 || c.ETF.MaxBuyPremium >= 1 {
+		errs.add("etf.max_buy_premium", "must be between 0 and 1")
+	}
+	if c.ETF.MaxSellDiscount < 0 || c.ETF.MaxSellDiscount >= 1 {
+		errs.add("etf.max_sell_discount", "must be between 0 and 1")
+	}
+	if h := c.Hedger; h.Enabled {
+		switch h.Instrument {
+		case HedgeFuture:
+			if c.Derivatives.Future == "" {
+				errs.add("hedger.instrument", "futures hedging needs derivatives.future")
+			}
+		case HedgeInverseETF:
+			if h.InverseETF == "" {
+				errs.add("hedger.inverse_etf", "must be set for the %s instrument", HedgeInverseETF)
+			}
+			if h.Leverage < 0 {
+				errs.add("hedger.leverage", "must not be negative")
+			}
+		default:
+			errs.add("hedger.instrument", "unknown instrument %q (want %s or %s)", h.Instrument, HedgeFuture, HedgeInverseETF)
+		}
+		if h.MaxDrawdown < 0 || h.MaxDrawdown >= 1 {
+			errs.add("hedger.max_drawdown", "must be between 0 and 1")
+		}
+		if h.MaxVolatility < 0 {
+			errs.add("hedger.max_volatility", "must not be negative")
+		}
+		if h.MaxVolatility > 0 && (h.Index == "" || h.VolatilityWindow < 2) {
+			errs.add("hedger.index", "volatility needs an index and a volatility_window of at least 2 days")
+		}
+		if h.MaxDrawdown == 0 && h.MaxVolatility == 0 {
+			errs.add("hedger", "set max_drawdown or max_volatility")
+		}
+		if h.Ratio <= 0 || h.Ratio > 1 {
+			errs.add("hedger.ratio", "must be greater than 0 and at most 1")
+		}
+		if h.Release < 0 || h.Release >= 1 {
+			errs.add("hedger.release", "must be between 0 and 1")
+		}
+		if d, err := time.ParseDuration(h.CheckInterval); err != nil || d <= 0 {
+			errs.add("hedger.check_interval", "invalid duration %q", h.CheckInterval)
+		}
+	}
+	if r := c.Rebalance; r.Enabled {
+		if len(r.Weights) == 0 {
+			errs.add("rebalance.weights", "must list at least one symbol")
+		}
+		total := 0.0
+		for symbol, w := range r.Weights {
+			if w <= 0 || w > 1 {
+				errs.add("rebalance.weights."+symbol, "must be greater than 0 and at most 1")
+			}
+			total += w
+		}
+		if total > 1+1e-9 {
+			errs.add("rebalance.weights", "sum to %g, more than 1", total)
+		}
+		switch r.Trigger {
+		case RebalanceThreshold:
+		case RebalanceCalendar:
+			switch r.Period {
+			case RebalanceWeekly, RebalanceMonthly, RebalanceQuarterly:
+			default:
+				errs.add("rebalance.period", "unknown period %q (want %s, %s or %s)", r.Period, RebalanceWeekly, RebalanceMonthly, RebalanceQuarterly)
+			}
+		default:
+			errs.add("rebalance.trigger", "unknown trigger %q (want %s or %s)", r.Trigger, RebalanceCalendar, RebalanceThreshold)
+		}
+		if r.Band < 0 || r.Band >= 1 {
+			errs.add("rebalance.band", "must be between 0 and 1")
+		}
+		if d, err := time.ParseDuration(r.CheckInterval); err != nil || d <= 0 {
+			errs.add("rebalance.check_interval", "invalid duration %q", r.CheckInterval)
+		}
+	}
+	if d := c.Derivatives; d.Enabled {
+		if d.Future == "" {
+			errs.add("derivatives.future", "must be set when derivatives are enabled")
+		}
+		if d.Multiplier < 0 {
+			errs.add("derivatives.multiplier", "must not be negative")
+		}
+		if d.CoveredCall.MinOTM < 0 || d.CoveredCall.MinOTM >= 1 {
+			errs.add("derivatives.covered_call.min_otm", "must be between 0 and 1")
+		}
+		if m := d.CoveredCall.Maturity; m != "" {
+			if _, err := time.Parse("200601", m); err != nil {
+				errs.add("derivatives.covered_call.maturity", "invalid month %q (want YYYYMM)", m)
+			}
+		}
+		if d.Hedge.Enabled && (d.Hedge.Ratio <= 0 || d.Hedge.Ratio > 1) {
+			errs.add("derivatives.hedge.ratio", "must be greater than 0 and at most 1")
+		}
+		if d.Hedge.Beta < 0 {
+			errs.add("derivatives.hedge.beta", "must not be negative")
+		}
+	}
+
+	if b := c.Backtest; b.StopLoss < 0 || b.StopLoss >= 1 || b.TakeProfit < 0 {
+		errs.add("backtest", "stop_loss must be between 0 and 1 and take_profit must not be negative")
+	}
+	if c.Backtest.Slippage < 0 || c.Backtest.Slippage >= 1 {
+		errs.add("backtest.slippage", "must be between 0 and 1")
+	}
+	if c.Backtest.DividendTax < 0 || c.Backtest.DividendTax >= 1 {
+		errs.add("backtest.dividend_tax", "must be between 0 and 1")
+	}
+	switch c.Backtest.Intrabar {
+	case "", IntrabarPessimistic, IntrabarOptimistic, IntrabarClose:
+	default:
+		errs.add("backtest.intrabar", "unknown mode %q (want %s, %s or %s)", c.Backtest.Intrabar, IntrabarPessimistic, IntrabarOptimistic, IntrabarClose)
+	}
+	validatePortfolioConstraints(c, errs)
+
+	if m := c.Monitor; m.Window < 0 || m.MinTrades < 0 || m.MaxWinRateDrop < 0 || m.MaxExpectancyDrop < 0 || m.Baseline < 0 {
+		errs.add("monitor", "window, min_trades, drops and baseline must not be negative")
+	}
+	if c.Monitor.Window > 0 && c.Monitor.MinTrades > c.Monitor.Window {
+		errs.add("monitor.min_trades", "%d exceeds the window of %d trades", c.Monitor.MinTrades, c.Monitor.Window)
+	}
+	switch c.Monitor.Action {
+	case "", MonitorActionAlert, MonitorActionPause:
+	default:
+		errs.add("monitor.action", "unknown action %q (want %s or %s)", c.Monitor.Action, MonitorActionAlert, MonitorActionPause)
+	}
+
+	validateAllocation(c, errs)
+	validateUniverse(c.Universe, errs)
+	validateScreen(c.Screen, c.Universe, errs)
+	validateMaintenance(c, errs)
+	validateTuning(c, errs)
+	validateShadow(c, errs)
+	validateExperiment(c, errs)
+	validateSignalRules(c, errs)
+
+	validateEmail(c.Notify.Email, errs)
+	for i, w := range c.Notify.Webhooks {
+		validateWebhook(w, fmt.Sprintf("notify.webhooks[%d]", i), errs)
+	}
+	if o := c.Notify.Outbox; o.Enabled {
+		if o.MaxAttempts < 0 {
+			errs.add("notify.outbox.max_attempts", "must not be negative")
+		}
+		for _, d := range []struct{ field, value string }{
+			{"notify.outbox.retry_delay", o.RetryDelay},
+			{"notify.outbox.max_delay", o.MaxDelay},
+		} {
+			if d.value == "" {
+				continue
+			}
+			if v, err := time.ParseDuration(d.value); err != nil || v <= 0 {
+				errs.add(d.field, "invalid duration %q", d.value)
+			}
+		}
+	}
+
+	switch c.Shutdown.Action {
+	case "", ShutdownActionNone, ShutdownActionCancelOrders, ShutdownActionFlatten:
+	default:
+		errs.add("shutdown.action", "unknown action %q (want %s, %s or %s)",
+			c.Shutdown.Action, ShutdownActionNone, ShutdownActionCancelOrders, ShutdownActionFlatten)
+	}
+	if c.Shutdown.Timeout != "" {
+		if d, err := time.ParseDuration(c.Shutdown.Timeout); err != nil || d <= 0 {
+			errs.add("shutdown.timeout", "invalid duration %q", c.Shutdown.Timeout)
+		}
+	}
+
+	if c.Strategy == "" {
+		errs.add("strategy", "must be set")
+	} else if _, ok := c.Strategies[c.Strategy]; !ok {
+		errs.add("strategy", "no configuration for strategy %q under strategies", c.Strategy)
+	}
+
+	names := make([]string, 0, len(c.Strategies))
+	for name := range c.Strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := "strategies." + name
+		validate, ok := strategyValidators[name]
+		if !ok {
+			errs.add(path, "unknown strategy (known: %s)", strings.Join(KnownStrategies(), ", "))
+			continue
+		}
+		validate(c.Strategies[name], path, errs)
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// KnownStrategies returns the names accepted under `strategies:`.
+func KnownStrategies() []string {
+	names := make([]string, 0, len(strategyValidators))
+	for name := range strategyValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validateKelly(c *Config, errs *ValidationError) {
+	k := c.Kelly
+	if k.Fraction < 0 || k.Fraction > 1 {
+		errs.add("kelly.fraction", "must be in [0, 1], got %v", k.Fraction)
+	}
+	if k.Cap < 0 || k.Cap > 1 {
+		errs.add("kelly.cap", "must be in [0, 1], got %v", k.Cap)
+	}
+	if k.Lookback < 0 {
+		errs.add("kelly.lookback", "must not be negative")
+	}
+	if k.MinTrades < 0 {
+		errs.add("kelly.min_trades", "must not be negative")
+	}
+	if k.Lookback > 0 && k.MinTrades > k.Lookback {
+		errs.add("kelly.min_trades", "must not exceed lookback (%d)", k.Lookback)
+	}
+	for i, name := range k.Strategies {
+		known := name == c.Strategy
+		for _, sc := range c.Allocation.Sleeves {
+			known = known || name == sc.Name
+		}
+		if !known {
+			errs.add(fmt.Sprintf("kelly.strategies[%d]", i), "%q is neither the strategy nor an allocation sleeve", name)
+		}
+	}
+}
+
+// ValidateStrategyParams checks params of the named strategy as Validate does
+// under strategies, returning a *ValidationError or nil.
+func ValidateStrategyParams(name string, params StrategyParams) error {
+	errs := &ValidationError{}
+	validate, ok := strategyValidators[name]
+	if !ok {
+		errs.add("strategies."+name, "unknown strategy (known: %s)", strings.Join(KnownStrategies(), ", "))
+	} else {
+		validate(params, "strategies."+name, errs)
+	}
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// extendedSessions are the sessions accepted in market.extended_sessions.
+var extendedSessions = []string{SessionPreMarket, SessionAfterHoursClose, SessionAfterHoursSingle}
+
+// knownMarkets are the KRX markets accepted in universe definitions.
+var knownMarkets = []string{"KOSPI", "KOSDAQ", "KONEX"}
+
+// apiRoles are the roles accepted in api.keys, from least to most privileged.
+var apiRoles = []string{RoleRead, RoleOperator, RoleAdmin}
+
+func validateUniverse(u UniverseConfig, errs *ValidationError) {
+	switch u.Source {
+	case "", UniverseSourceKIS:
+	case UniverseSourceFile:
+		if u.File == "" {
+			errs.add("universe.file", "must be set when the source is %s", UniverseSourceFile)
+		}
+	default:
+		errs.add("universe.source", "unknown source %q (want %s or %s)", u.Source, UniverseSourceFile, UniverseSourceKIS)
+	}
+	if len(u.Definitions) > 0 && u.Source == "" {
+		errs.add("universe.source", "must be set when universes are defined")
+	}
+
+	names := make([]string, 0, len(u.Definitions))
+	for name := range u.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		def := u.Definitions[name]
+		path := "universe.definitions." + name
+		if u.Source == UniverseSourceKIS && len(def.Symbols) == 0 {
+			errs.add(path+".symbols", "must be set with the %s source, which cannot list all symbols", UniverseSourceKIS)
+		}
+		for _, symbol := range append(append([]string{}, def.Symbols...), def.Exclude...) {
+			if !symbolPattern.MatchString(symbol) {
+				errs.add(path, "%q is not a 6-character KRX code", symbol)
+			}
+		}
+		for _, market := range def.Markets {
+			if !containsString(knownMarkets, market) {
+				errs.add(path+".markets", "unknown market %q (want %s)", market, strings.Join(knownMarkets, ", "))
+			}
+		}
+	}
+}
+
+func validateScreen(s ScreenConfig, u UniverseConfig, errs *ValidationError) {
+	if s.Universe != "" {
+		if _, ok := u.Definitions[s.Universe]; !ok {
+			errs.add("screen.universe", "no universe %q under universe.definitions", s.Universe)
+		}
+	}
+	if s.MinPrice < 0 || s.MaxPrice < 0 || s.MinAvgVolume < 0 || s.MinVolatility < 0 || s.MaxVolatility < 0 {
+		errs.add("screen", "price, volume and volatility limits must not be negative")
+	}
+	if s.MaxPrice > 0 && s.MaxPrice < s.MinPrice {
+		errs.add("screen.max_price", "must not be below min_price")
+	}
+	if s.MaxVolatility > 0 && s.MaxVolatility < s.MinVolatility {
+		errs.add("screen.max_volatility", "must not be below min_volatility")
+	}
+	if s.VolumeDays < 0 || s.VolatilityDays < 0 || s.MaxResults < 0 {
+		errs.add("screen", "volume_days, volatility_days and max_results must not be negative")
+	}
+	if s.Schedule != "" {
+		if _, err := time.Parse("15:04", s.Schedule); err != nil {
+			errs.add("screen.schedule", "invalid time %q, want HH:MM", s.Schedule)
+		}
+		if s.Universe == "" {
+			errs.add("screen.universe", "must be set when the screen is scheduled")
+		}
+	}
+	if s.Apply && s.Schedule == "" {
+		errs.add("screen.apply", "requires screen.schedule")
+	}
+}
+
+func validateMaintenance(c *Config, errs *ValidationError) {
+	m := c.Maintenance
+	zone := m.Timezone
+	if zone == "" {
+		zone = DefaultMaintenanceTimezone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		errs.add("maintenance.timezone", "unknown time zone %q", m.Timezone)
+		loc = time.UTC
+	}
+	for i, t := range m.Tasks {
+		path := fmt.Sprintf("maintenance.tasks[%d]", i)
+		switch t.Task {
+		case TaskTokenRefresh, TaskEODSnapshot, TaskBackfill, TaskPrune:
+		case TaskReportEmail:
+			if !c.Notify.Email.Enabled {
+				errs.add(path+".task", "%s requires notify.email.enabled", t.Task)
+			}
+		default:
+			errs.add(path+".task", "unknown task %q (want %s, %s, %s, %s or %s)", t.Task,
+				TaskTokenRefresh, TaskEODSnapshot, TaskBackfill, TaskReportEmail, TaskPrune)
+		}
+		if _, err := cron.Parse(t.Schedule, loc); err != nil {
+			errs.add(path+".schedule", "%v", err)
+		}
+		if t.Days < 0 {
+			errs.add(path+".days", "must not be negative")
+		}
+	}
+}
+
+func validateShadow(c *Config, errs *ValidationError) {
+	sh := c.Shadow
+	if !sh.Enabled {
+		return
+	}
+	switch _, ok := c.Strategies[sh.Strategy]; {
+	case sh.Strategy == "":
+		errs.add("shadow.strategy", "must be set")
+	case sh.Strategy == c.Strategy:
+		errs.add("shadow.strategy", "must differ from the traded strategy %q", c.Strategy)
+	case !ok:
+		errs.add("shadow.strategy", "no configuration for strategy %q under strategies", sh.Strategy)
+	}
+	if len(c.Allocation.Sleeves) > 0 {
+		errs.add("shadow.enabled", "cannot shadow a multi-strategy allocation")
+	}
+	if sh.Capital < 0 {
+		errs.add("shadow.capital", "must not be negative")
+	}
+}
+
+func validateExperiment(c *Config, errs *ValidationError) {
+	ex := c.Experiment
+	if !ex.Enabled {
+		return
+	}
+	var sleeves []string
+	for _, sleeve := range c.Allocation.Sleeves {
+		sleeves = append(sleeves, sleeve.Name)
+	}
+	for _, v := range []struct{ path, name string }{{"experiment.a", ex.A}, {"experiment.b", ex.B}} {
+		if v.name == "" {
+			errs.add(v.path, "must be set")
+		} else if !containsString(sleeves, v.name) {
+			errs.add(v.path, "no sleeve %q under allocation.sleeves", v.name)
+		}
+	}
+	if ex.A != "" && ex.A == ex.B {
+		errs.add("experiment.b", "must differ from experiment.a")
+	}
+	// A t-test needs two days; the allocator keeps a year of daily returns.
+	if ex.Days < 0 || ex.Days == 1 || ex.Days > 250 {
+		errs.add("experiment.days", "must be between 2 and 250")
+	}
+	if ex.Significance < 0 || ex.Significance >= 1 {
+		errs.add("experiment.significance", "must be between 0 and 1")
+	}
+}
+
+func validateTuning(c *Config, errs *ValidationError) {
+	t := c.Tuning
+	if !t.Enabled {
+		return
+	}
+	if c.Strategy != "moving_average" {
+		errs.add("tuning.enabled", "only the moving_average strategy can be tuned, not %q", c.Strategy)
+	}
+	if t.Schedule != "" {
+		if _, err := cron.Parse(t.Schedule, time.UTC); err != nil {
+			errs.add("tuning.schedule", "%v", err)
+		}
+	}
+	if t.Days < 0 {
+		errs.add("tuning.days", "must not be negative")
+	}
+	for name, r := range map[string]TuningRange{"short_periods": t.ShortPeriods, "long_periods": t.LongPeriods} {
+		if r.Min < 0 || r.Max < r.Min || r.Step < 0 {
+			errs.add("tuning."+name, "want 0 <= min <= max and a non-negative step")
+		}
+	}
+	if t.Balance < 0 || t.Commission < 0 || t.Commission >= 1 {
+		errs.add("tuning", "balance must not be negative and commission must be in [0, 1)")
+	}
+	if t.MinImprovement < 0 {
+		errs.add("tuning.min_improvement", "must not be negative")
+	}
+	switch t.Apply {
+	case "", TuningApplyApprove:
+		if !c.API.Enabled {
+			errs.add("tuning.apply", "%s requires api.enabled to approve proposals", TuningApplyApprove)
+		}
+	case TuningApplyAuto:
+	default:
+		errs.add("tuning.apply", "unknown mode %q (want %s or %s)", t.Apply, TuningApplyAuto, TuningApplyApprove)
+	}
+}
+
+func validateSignalRules(c *Config, errs *ValidationError) {
+	for i, r := range c.SignalRules {
+		path := fmt.Sprintf("signal_rules[%d]", i)
+		switch r.Rule {
+		case SignalRuleConfirm, SignalRuleInvert:
+		case SignalRuleTranches:
+			if r.Tranches < 2 {
+				errs.add(path+".tranches", "must be at least 2")
+			}
+		default:
+			errs.add(path+".rule", "unknown rule %q (want %s, %s or %s)", r.Rule, SignalRuleConfirm, SignalRuleInvert, SignalRuleTranches)
+		}
+		if r.Bars < 0 {
+			errs.add(path+".bars", "must not be negative")
+		}
+	}
+}
+
+func validateAllocation(c *Config, errs *ValidationError) {
+	a := c.Allocation
+	switch a.Scheme {
+	case "", AllocationFixed, AllocationInverseVolatility, AllocationPerformance:
+	default:
+		errs.add("allocation.scheme", "unknown scheme %q (want %s, %s or %s)",
+			a.Scheme, AllocationFixed, AllocationInverseVolatility, AllocationPerformance)
+	}
+	if a.Lookback < 0 {
+		errs.add("allocation.lookback", "must not be negative")
+	}
+	if len(a.Sleeves) == 0 {
+		return
+	}
+	if a.Capital <= 0 {
+		errs.add("allocation.capital", "must be positive when sleeves are configured")
+	}
+
+	seen := make(map[string]bool)
+	for i, sleeve := range a.Sleeves {
+		path := fmt.Sprintf("allocation.sleeves[%d]", i)
+		if sleeve.Name == "" {
+			errs.add(path+".name", "must be set")
+		} else if seen[sleeve.Name] {
+			errs.add(path+".name", "duplicate sleeve %q", sleeve.Name)
+		}
+		seen[sleeve.Name] = true
+		if sleeve.Strategy != "" {
+			if _, ok := c.Strategies[sleeve.Strategy]; !ok {
+				errs.add(path+".strategy", "no configuration for strategy %q under strategies", sleeve.Strategy)
+			}
+		}
+		for _, symbol := range sleeve.Symbols {
+			if !containsString(c.TradingSymbols(), symbol) {
+				errs.add(path+".symbols", "%s is not a trading symbol", symbol)
+			}
+		}
+		if sleeve.Weight < 0 || (sleeve.Weight == 0 && (a.Scheme == "" || a.Scheme == AllocationFixed)) {
+			errs.add(path+".weight", "must be positive for the fixed scheme and not negative otherwise")
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func validateLogSink(s LogSinkConfig, path string, errs *ValidationError) {
+	switch s.Type {
+	case LogSinkStdout, LogSinkStderr, LogSinkSyslog:
+	case LogSinkFile:
+		if s.Path == "" {
+			errs.add(path+".path", "must be set for file sinks")
+		}
+	default:
+		errs.add(path+".type", "unknown sink %q (want %s, %s, %s or %s)", s.Type, LogSinkStdout, LogSinkStderr, LogSinkFile, LogSinkSyslog)
+	}
+	if s.Level != "" {
+		if _, err := logrus.ParseLevel(s.Level); err != nil {
+			errs.add(path+".level", "unknown level %q", s.Level)
+		}
+	}
+	if s.Format != "" && s.Format != "text" && s.Format != "json" {
+		errs.add(path+".format", "unknown format %q (want text or json)", s.Format)
+	}
+	if s.MaxSizeMB < 0 {
+		errs.add(path+".max_size_mb", "must not be negative")
+	}
+	if s.MaxAge != "" {
+		if d, err := time.ParseDuration(s.MaxAge); err != nil || d <= 0 {
+			errs.add(path+".max_age", "invalid duration %q", s.MaxAge)
+		}
+	}
+	if s.MaxBackups < 0 {
+		errs.add(path+".max_backups", "must not be negative")
+	}
+}
+
+func validateEmail(e EmailConfig, errs *ValidationError) {
+	if !e.Enabled {
+		return
+	}
+	if e.Host == "" {
+		errs.add("notify.email.host", "must be set when email reports are enabled")
+	}
+	if e.Port < 0 || e.Port > 65535 {
+		errs.add("notify.email.port", "invalid port %d", e.Port)
+	}
+	if e.From == "" {
+		errs.add("notify.email.from", "must be set when email reports are enabled")
+	}
+	if len(e.To) == 0 {
+		errs.add("notify.email.to", "must list at least one recipient")
+	}
+	if e.Benchmark != "" && !symbolPattern.MatchString(e.Benchmark) {
+		errs.add("notify.email.benchmark", "%q is not a 6-character KRX code", e.Benchmark)
+	}
+	if e.SendDelay != "" {
+		if d, err := time.ParseDuration(e.SendDelay); err != nil || d < 0 {
+			errs.add("notify.email.send_delay", "invalid duration %q", e.SendDelay)
+		}
+	}
+}
+
+// webhookEvents are the event kinds that can be sent to a webhook.
+var webhookEvents = []string{"signal", "order", "fill", "error", "circuit", "screen", "halt", "degradation", "watchdog", "tuning", "kill"}
+
+func validateWebhook(w WebhookConfig, path string, errs *ValidationError) {
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(path+".url", "invalid URL %q", w.URL)
+	}
+	for _, event := range w.Events {
+		known := false
+		for _, e := range webhookEvents {
+			known = known || e == event
+		}
+		if !known {
+			errs.add(path+".events", "unknown event %q (known: %s)", event, strings.Join(webhookEvents, ", "))
+		}
+	}
+	if w.Timeout != "" {
+		if d, err := time.ParseDuration(w.Timeout); err != nil || d <= 0 {
+			errs.add(path+".timeout", "invalid duration %q", w.Timeout)
+		}
+	}
+	if w.MaxRetries < 0 {
+		errs.add(path+".max_retries", "must not be negative")
+	}
+}
+
+func validateSecrets(s SecretsConfig, errs *ValidationError) {
+	required := func(field, value string) {
+		if value == "" {
+			errs.add("secrets."+field, "must be set for provider %q", s.Provider)
+		}
+	}
+
+	switch s.Provider {
+	case "", SecretsProviderEnv:
+		return
+	case SecretsProviderVault:
+		required("vault.address", s.Vault.Address)
+		required("vault.path", s.Vault.Path)
+	case SecretsProviderAWS:
+		required("aws.region", s.AWS.Region)
+		required("aws.secret_id", s.AWS.SecretID)
+	case SecretsProviderGCP:
+		required("gcp.project", s.GCP.Project)
+		required("gcp.secret", s.GCP.Secret)
+	case SecretsProviderFile:
+		required("file.path", s.File.Path)
+	default:
+		errs.add("secrets.provider", "unknown provider %q", s.Provider)
+		return
+	}
+
+	if s.RefreshInterval != "" {
+		if d, err := time.ParseDuration(s.RefreshInterval); err != nil || d <= 0 {
+			errs.add("secrets.refresh_interval", "invalid duration %q", s.RefreshInterval)
+		}
+	}
+}
+
+func validatePortfolioConstraints(c *Config, errs *ValidationError) {
+	p := c.Backtest.Portfolio
+	if p.MaxPositions < 0 {
+		errs.add("backtest.portfolio.max_positions", "must not be negative")
+	}
+	if p.MaxTurnover < 0 {
+		errs.add("backtest.portfolio.max_turnover", "must not be negative")
+	}
+	if p.MinTradeValue < 0 {
+		errs.add("backtest.portfolio.min_trade_value", "must not be negative")
+	}
+	sectors := make([]string, 0, len(p.SectorCaps))
+	for sector := range p.SectorCaps {
+		sectors = append(sectors, sector)
+	}
+	sort.Strings(sectors)
+	for _, sector := range sectors {
+		if w := p.SectorCaps[sector]; w <= 0 || w > 1 {
+			errs.add("backtest.portfolio.sector_caps."+sector, "must be greater than 0 and at most 1")
+		}
+	}
+	if len(p.SectorCaps) > 0 && c.Universe.Source == "" {
+		errs.add("backtest.portfolio.sector_caps", "requires universe.source for the symbols' sectors")
+	}
+}
+
+func validateExposure(c *Config, errs *ValidationError) {
+	e := c.Exposure
+	if e.MaxSector < 0 || e.MaxSector > 1 {
+		errs.add("exposure.max_sector", "must be in [0, 1], got %v", e.MaxSector)
+	}
+	sectors := make([]string, 0, len(e.SectorLimits))
+	for sector := range e.SectorLimits {
+		sectors = append(sectors, sector)
+	}
+	sort.Strings(sectors)
+	for _, sector := range sectors {
+		if w := e.SectorLimits[sector]; w <= 0 || w > 1 {
+			errs.add("exposure.sector_limits."+sector, "must be greater than 0 and at most 1")
+		}
+	}
+	if e.Enabled() && c.Universe.Source == "" {
+		errs.add("exposure", "requires universe.source for the symbols' sectors")
+	}
+}
+
+func validateMovingAverage(params StrategyParams, path string, errs *ValidationError) {
+	var ma models.MovingAverageConfig
+	if err := params.Decode(&ma); err != nil {
+		errs.add(path, "%v", err)
+		return
+	}
+	if ma.ShortPeriod <= 0 {
+		errs.add(path+".short_period", "must be positive")
+	}
+	if ma.LongPeriod <= 0 {
+		errs.add(path+".long_period", "must be positive")
+	}
+	if ma.ShortPeriod > 0 && ma.LongPeriod > 0 && ma.ShortPeriod >= ma.LongPeriod {
+		errs.add(path+".short_period", "must be less than long_period (%d)", ma.LongPeriod)
+	}
+	if ma.Threshold < 0 || ma.Threshold >= 1 {
+		errs.add(path+".threshold", "must be in [0, 1), got %v", ma.Threshold)
+	}
+	for _, p := range []struct {
+		field string
+		value *float64
+	}{{"min_buy_sentiment", ma.MinBuySentiment}, {"sell_sentiment", ma.SellSentiment}} {
+		if p.value != nil && (*p.value < -1 || *p.value > 1) {
+			errs.add(path+"."+p.field, "must be in [-1, 1], got %v", *p.value)
+		}
+	}
+}
+
+func validateNAVDeviation(params StrategyParams, path string, errs *ValidationError) {
+	var nd models.NAVDeviationConfig
+	if err := params.Decode(&nd); err != nil {
+		errs.add(path, "%v", err)
+		return
+	}
+	if nd.EntryDiscount <= 0 || nd.EntryDiscount >= 1 {
+		errs.add(path+".entry_discount", "must be in (0, 1), got %v", nd.EntryDiscount)
+	}
+	if nd.ExitPremium <= -nd.EntryDiscount || nd.ExitPremium >= 1 {
+		errs.add(path+".exit_premium", "must be above -entry_discount and below 1, got %v", nd.ExitPremium)
+	}
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// Reload is delivered by Watch each time the configuration file is re-read.
+// Err is set when the new file could not be loaded; the previous configuration
+// should stay in effect in that case.
+type Reload struct {
+	Config *Config
+	Err    error
+}
+
+// Watch re-reads the configuration file with the given profile whenever its modification
+// time changes (checked every pollInterval) or the process receives SIGHUP, until stop is closed.
+func Watch(filename, profile string, pollInterval time.Duration, stop <-chan struct{}) <-chan Reload {
+	reloads := make(chan Reload, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		defer close(reloads)
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		lastMod := modTime(filename)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hup:
+				lastMod = modTime(filename)
+			case <-ticker.C:
+				mod := modTime(filename)
+				if mod.Equal(lastMod) {
+					continue
+				}
+				lastMod = mod
+			}
+
+			cfg, err := LoadProfile(filename, profile)
+			select {
+			case reloads <- Reload{Config: cfg, Err: err}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return reloads
+}
+
+func modTime(filename string) time.Time {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Changes compares two configurations and returns the keys that differ, split into
+// those that can be applied to a running bot and those that need a restart.
+func Changes(old, new *Config) (safe, unsafe []string) {
+	if old.PollingInterval != new.PollingInterval {
+		safe = append(safe, "polling_interval")
+	}
+	if old.TradingPair != new.TradingPair {
+		safe = append(safe, "trading_pair")
+	}
+	if !reflect.DeepEqual(old.Symbols, new.Symbols) {
+		safe = append(safe, "symbols")
+	}
+	if old.MaxParallel != new.MaxParallel {
+		safe = append(safe, "max_parallel")
+	}
+	if old.CycleBudget != new.CycleBudget {
+		safe = append(safe, "cycle_budget")
+	}
+	if old.Strategy != new.Strategy {
+		safe = append(safe, "strategy")
+	}
+	if !reflect.DeepEqual(old.Strategies, new.Strategies) {
+		safe = append(safe, "strategies")
+	}
+	if old.LogLevel != new.LogLevel {
+		safe = append(safe, "log_level")
+	}
+	if old.Risk != new.Risk {
+		safe = append(safe, "risk")
+	}
+	if old.Position != new.Position {
+		safe = append(safe, "position")
+	}
+	if old.Fees != new.Fees {
+		safe = append(safe, "fees")
+	}
+	if old.Tax != new.Tax {
+		safe = append(safe, "tax")
+	}
+	if old.Margin != new.Margin {
+		safe = append(safe, "margin")
+	}
+	if old.ETF != new.ETF {
+		safe = append(safe, "etf")
+	}
+	if old.Shutdown != new.Shutdown {
+		safe = append(safe, "shutdown")
+	}
+	if !reflect.DeepEqual(old.Market, new.Market) {
+		safe = append(safe, "market")
+	}
+	if !reflect.DeepEqual(old.Exposure, new.Exposure) {
+		safe = append(safe, "exposure")
+	}
+	if old.Liquidity != new.Liquidity {
+		safe = append(safe, "liquidity")
+	}
+	if old.Correlation != new.Correlation {
+		safe = append(safe, "correlation")
+	}
+
+	if old.DatabaseURL != new.DatabaseURL {
+		unsafe = append(unsafe, "database_url")
+	}
+	if !reflect.DeepEqual(old.Logging, new.Logging) {
+		unsafe = append(unsafe, "logging")
+	}
+	// The access token is obtained at startup and never comes from the file.
+	oldExchange, newExchange := old.Exchange, new.Exchange
+	oldExchange.AccessToken, newExchange.AccessToken = "", ""
+	if oldExchange != newExchange {
+		unsafe = append(unsafe, "exchange")
+	}
+	if old.MarketData != new.MarketData {
+		unsafe = append(unsafe, "market_data")
+	}
+	if old.Secrets != new.Secrets {
+		unsafe = append(unsafe, "secrets")
+	}
+	if !reflect.DeepEqual(old.KillSwitch, new.KillSwitch) {
+		unsafe = append(unsafe, "kill_switch")
+	}
+	if !reflect.DeepEqual(old.Telegram, new.Telegram) {
+		unsafe = append(unsafe, "telegram")
+	}
+	if !reflect.DeepEqual(old.API, new.API) {
+		unsafe = append(unsafe, "api")
+	}
+	if old.CircuitBreaker != new.CircuitBreaker {
+		unsafe = append(unsafe, "circuit_breaker")
+	}
+	if old.Watchdog != new.Watchdog {
+		unsafe = append(unsafe, "watchdog")
+	}
+	if !reflect.DeepEqual(old.News, new.News) {
+		unsafe = append(unsafe, "news")
+	}
+	if old.Intraday != new.Intraday {
+		unsafe = append(unsafe, "intraday")
+	}
+	if old.AdaptivePolling != new.AdaptivePolling {
+		unsafe = append(unsafe, "adaptive_polling")
+	}
+	if old.Shadow != new.Shadow {
+		unsafe = append(unsafe, "shadow")
+	}
+	if old.Experiment != new.Experiment {
+		unsafe = append(unsafe, "experiment")
+	}
+	if !reflect.DeepEqual(old.Kelly, new.Kelly) {
+		unsafe = append(unsafe, "kelly")
+	}
+	if !reflect.DeepEqual(old.Earnings, new.Earnings) {
+		unsafe = append(unsafe, "earnings")
+	}
+	if old.Monitor != new.Monitor {
+		unsafe = append(unsafe, "monitor")
+	}
+	if old.CashSweep != new.CashSweep {
+		unsafe = append(unsafe, "cash_sweep")
+	}
+	if !reflect.DeepEqual(old.Derivatives, new.Derivatives) {
+		unsafe = append(unsafe, "derivatives")
+	}
+	if old.Hedger != new.Hedger {
+		unsafe = append(unsafe, "hedger")
+	}
+	if !reflect.DeepEqual(old.Rebalance, new.Rebalance) {
+		unsafe = append(unsafe, "rebalance")
+	}
+	if !reflect.DeepEqual(old.Allocation, new.Allocation) {
+		unsafe = append(unsafe, "allocation")
+	}
+	if old.Timeframe != new.Timeframe {
+		unsafe = append(unsafe, "timeframe")
+	}
+	if old.BarClose != new.BarClose {
+		unsafe = append(unsafe, "bar_close")
+	}
+	if old.Audit != new.Audit {
+		unsafe = append(unsafe, "audit")
+	}
+	if old.Outbox != new.Outbox {
+		unsafe = append(unsafe, "outbox")
+	}
+	if old.StrategyState != new.StrategyState {
+		unsafe = append(unsafe, "strategy_state")
+	}
+	if old.Lock != new.Lock {
+		unsafe = append(unsafe, "lock")
+	}
+	if old.Reconcile != new.Reconcile {
+		unsafe = append(unsafe, "reconcile")
+	}
+	if !reflect.DeepEqual(old.Screen, new.Screen) {
+		unsafe = append(unsafe, "screen")
+	}
+	if !reflect.DeepEqual(old.Universe, new.Universe) {
+		unsafe = append(unsafe, "universe")
+	}
+	if !reflect.DeepEqual(old.Notify, new.Notify) {
+		unsafe = append(unsafe, "notify")
+	}
+	if !reflect.DeepEqual(old.Maintenance, new.Maintenance) {
+		unsafe = append(unsafe, "maintenance")
+	}
+	if old.Tuning != new.Tuning {
+		unsafe = append(unsafe, "tuning")
+	}
+	if !reflect.DeepEqual(old.SignalRules, new.SignalRules) {
+		unsafe = append(unsafe, "signal_rules")
+	}
+	return safe, unsafe
+}
+
+// ApplySafe copies the runtime-adjustable settings of next into c, leaving
+// settings that require a restart untouched.
+func (c *Config) ApplySafe(next *Config) {
+	c.PollingInterval = next.PollingInterval
+	c.ParsedInterval = next.ParsedInterval
+	c.TradingPair = next.TradingPair
+	c.Symbols = next.Symbols
+	c.MaxParallel = next.MaxParallel
+	c.CycleBudget = next.CycleBudget
+	c.Strategy = next.Strategy
+	c.Strategies = next.Strategies
+	c.LogLevel = next.LogLevel
+	c.Risk = next.Risk
+	c.Position = next.Position
+	c.Fees = next.Fees
+	c.Tax = next.Tax
+	c.Margin = next.Margin
+	c.ETF = next.ETF
+	c.Shutdown = next.Shutdown
+	c.Market = next.Market
+	c.Exposure = next.Exposure
+	c.Liquidity = next.Liquidity
+	c.Correlation = next.Correlation
+}
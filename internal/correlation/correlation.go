@@ -0,0 +1,180 @@
+// Package correlation computes rolling correlations between the daily returns
+// of symbols from stored daily candles, for the engine to tell how
+// concentrated the portfolio is in names that move together.
+package correlation
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+)
+
+const (
+	defaultDays      = 60
+	defaultThreshold = 0.7
+)
+
+// Candles is the source of the daily candles, e.g. the daily_candles table of
+// the database.
+type Candles interface {
+	GetDailyCandles(stockCode string, days int) ([]candle.Candle, error)
+}
+
+// Matrix computes the correlations of the daily close-to-close returns of
+// symbols over the last days days before the current one; see
+// config.CorrelationConfig. The returns are read once a day per symbol.
+// Matrix is safe for concurrent use.
+type Matrix struct {
+	mu        sync.Mutex
+	days      int
+	threshold float64
+	candles   Candles
+	day       string
+	returns   map[string]map[time.Time]float64
+}
+
+// New creates a matrix of the returns of the daily candles in candles.
+func New(cfg config.CorrelationConfig, candles Candles) *Matrix {
+	m := &Matrix{candles: candles, returns: map[string]map[time.Time]float64{}}
+	m.SetConfig(cfg)
+	return m
+}
+
+// SetConfig replaces the configuration, e.g. on a reload.
+func (m *Matrix) SetConfig(cfg config.CorrelationConfig) {
+	days, threshold := cfg.Days, cfg.Threshold
+	if days == 0 {
+		days = defaultDays
+	}
+	if threshold == 0 {
+		threshold = defaultThreshold
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if days != m.days {
+		m.returns = map[string]map[time.Time]float64{}
+	}
+	m.days, m.threshold = days, threshold
+}
+
+// SetCandles replaces the source of the daily candles, e.g. after a database
+// reconnect.
+func (m *Matrix) SetCandles(candles Candles) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.candles = candles
+}
+
+// Threshold returns the correlation from which symbols count as correlated.
+func (m *Matrix) Threshold() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.threshold
+}
+
+// Correlated returns the correlation of a and b and whether it reaches the
+// threshold. Symbols whose correlation is not known are not correlated.
+func (m *Matrix) Correlated(a, b string, at time.Time) (rho float64, correlated bool, err error) {
+	rho, ok, err := m.Correlation(a, b, at)
+	return rho, ok && rho >= m.Threshold(), err
+}
+
+// Correlation returns the Pearson correlation of the daily returns of a and b
+// on the days before the day of at that both have. ok is false when they have
+// fewer than half the configured days in common, or either did not move, and
+// their correlation is not known.
+func (m *Matrix) Correlation(a, b string, at time.Time) (rho float64, ok bool, err error) {
+	ra, err := m.dailyReturns(a, at)
+	if err != nil {
+		return 0, false, err
+	}
+	rb, err := m.dailyReturns(b, at)
+	if err != nil {
+		return 0, false, err
+	}
+	var xs, ys []float64
+	for day, x := range ra {
+		if y, found := rb[day]; found {
+			xs = append(xs, x)
+			ys = append(ys, y)
+		}
+	}
+	m.mu.Lock()
+	days := m.days
+	m.mu.Unlock()
+	if 2*len(xs) < days || len(xs) < 2 {
+		return 0, false, nil
+	}
+	return pearson(xs, ys)
+}
+
+// dailyReturns returns the returns of symbol by day, from the cache when it
+// was read on the day of at.
+func (m *Matrix) dailyReturns(symbol string, at time.Time) (map[time.Time]float64, error) {
+	kst := at.In(market.KST)
+	day := kst.Format("2006-01-02")
+	m.mu.Lock()
+	if day != m.day {
+		m.day = day
+		m.returns = map[string]map[time.Time]float64{}
+	}
+	returns, cached := m.returns[symbol]
+	source, days := m.candles, m.days
+	m.mu.Unlock()
+	if cached {
+		return returns, nil
+	}
+
+	// Today's candle, when already stored, is left out; one more is fetched
+	// to make up for it, and one for the first return.
+	candles, err := source.GetDailyCandles(symbol, days+2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily candles of %s: %w", symbol, err)
+	}
+	start := time.Date(kst.Year(), kst.Month(), kst.Day(), 0, 0, 0, 0, market.KST)
+	for len(candles) > 0 && !candles[len(candles)-1].Start.Before(start) {
+		candles = candles[:len(candles)-1]
+	}
+	if len(candles) > days+1 {
+		candles = candles[len(candles)-days-1:]
+	}
+	returns = make(map[time.Time]float64, len(candles))
+	for i := 1; i < len(candles); i++ {
+		if prev := candles[i-1].Close; prev > 0 {
+			returns[candles[i].Start] = candles[i].Close/prev - 1
+		}
+	}
+
+	m.mu.Lock()
+	if day == m.day {
+		m.returns[symbol] = returns
+	}
+	m.mu.Unlock()
+	return returns, nil
+}
+
+func pearson(xs, ys []float64) (float64, bool, error) {
+	n := float64(len(xs))
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= n
+	my /= n
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0, false, nil
+	}
+	return math.Max(-1, math.Min(1, cov/math.Sqrt(vx*vy))), true, nil
+}
@@ -0,0 +1,143 @@
+package correlation
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+)
+
+// walks serves daily candles of closes compounded from daily returns, the
+// last one on last, and then today's candle of the symbols in today.
+type walks struct {
+	last    time.Time
+	returns map[string][]float64
+	today   map[string]float64
+	calls   int
+}
+
+func (w *walks) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	w.calls++
+	returns := w.returns[stockCode]
+	n := len(returns)
+	if r, ok := w.today[stockCode]; ok {
+		returns = append(append([]float64(nil), returns...), r)
+	}
+	candles := make([]candle.Candle, len(returns)+1)
+	price := 10000.0
+	for i := range candles {
+		if i > 0 {
+			price *= 1 + returns[i-1]
+		}
+		candles[i] = candle.Candle{Symbol: stockCode, Start: w.last.AddDate(0, 0, i-n), Close: price}
+	}
+	if len(candles) > days {
+		candles = candles[len(candles)-days:]
+	}
+	return candles, nil
+}
+
+func randomReturns(seed int64, n int) []float64 {
+	r := rand.New(rand.NewSource(seed))
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = 0.02 * r.NormFloat64()
+	}
+	return out
+}
+
+func TestCorrelation(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	base := randomReturns(1, 80)
+	inverse := make([]float64, len(base))
+	for i, r := range base {
+		inverse[i] = -r / 2
+	}
+	w := &walks{last: time.Date(2026, 10, 15, 0, 0, 0, 0, market.KST), returns: map[string][]float64{
+		"005930": base,
+		"000660": base,
+		"114800": inverse,
+		"035720": randomReturns(2, 80),
+		"900100": base[:20],
+		"900200": make([]float64, 80),
+	}, today: map[string]float64{"000660": -0.3}}
+	m := New(config.CorrelationConfig{Days: 60}, w)
+
+	tests := []struct {
+		a, b       string
+		rho        float64
+		ok         bool
+		correlated bool
+	}{
+		// Today's candle of 000660 is left out.
+		{"005930", "000660", 1, true, true},
+		{"005930", "114800", -1, true, false},
+		{"005930", "035720", 0, true, false},
+		// Fewer than 30 of the 60 days in common.
+		{"005930", "900100", 0, false, false},
+		// A flat price has no correlation.
+		{"005930", "900200", 0, false, false},
+	}
+	for _, tt := range tests {
+		rho, ok, err := m.Correlation(tt.a, tt.b, now)
+		if err != nil || ok != tt.ok || math.Abs(rho-tt.rho) > 0.3 {
+			t.Errorf("Correlation(%s, %s) = %.3f, %v, %v, want %.1f, %v", tt.a, tt.b, rho, ok, err, tt.rho, tt.ok)
+		}
+		if _, correlated, _ := m.Correlated(tt.a, tt.b, now); correlated != tt.correlated {
+			t.Errorf("Correlated(%s, %s) = %v", tt.a, tt.b, correlated)
+		}
+	}
+	if rho, _, _ := m.Correlation("005930", "114800", now); math.Abs(rho+1) > 1e-9 {
+		t.Errorf("correlation with the inverse = %v, want -1", rho)
+	}
+
+	if w.calls != 6 {
+		t.Errorf("daily candles read %d times for 6 symbols in a day", w.calls)
+	}
+}
+
+func TestSetCandles(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	last := time.Date(2026, 10, 15, 0, 0, 0, 0, market.KST)
+	base := randomReturns(1, 20)
+	old := &walks{last: last}
+	m := New(config.CorrelationConfig{Days: 20}, old)
+
+	m.SetCandles(&walks{last: last, returns: map[string][]float64{"005930": base, "000660": base}})
+	if _, correlated, err := m.Correlated("005930", "000660", now); err != nil || !correlated {
+		t.Errorf("Correlated = %v, %v", correlated, err)
+	}
+	if old.calls != 0 {
+		t.Errorf("fetched %d times from the old source", old.calls)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	base := randomReturns(1, 80)
+	noisy := randomReturns(2, 80)
+	// Correlated by about 0.7 over 80 days.
+	for i := range noisy {
+		noisy[i] = base[i] + noisy[i]
+	}
+	w := &walks{last: time.Date(2026, 10, 15, 0, 0, 0, 0, market.KST), returns: map[string][]float64{"005930": base, "000660": noisy}}
+	m := New(config.CorrelationConfig{Days: 80, Threshold: 0.99}, w)
+	rho, correlated, err := m.Correlated("005930", "000660", now)
+	if err != nil || correlated {
+		t.Fatalf("Correlated = %.2f, %v, %v at threshold 0.99", rho, correlated, err)
+	}
+
+	m.SetConfig(config.CorrelationConfig{Days: 40, Threshold: rho / 2})
+	if m.Threshold() != rho/2 {
+		t.Errorf("threshold %v after a reload, want %v", m.Threshold(), rho/2)
+	}
+	if _, correlated, _ := m.Correlated("005930", "000660", now); !correlated {
+		t.Error("not correlated after lowering the threshold")
+	}
+	if w.calls != 4 {
+		t.Errorf("daily candles fetched %d times, want them read again for the new days", w.calls)
+	}
+}
@@ -0,0 +1,145 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Schedules name IANA zones such as Asia/Seoul, which hosts without a
+	// zoneinfo database cannot otherwise load.
+	_ "time/tzdata"
+)
+
+// fields are the bounds of the five cron fields.
+var fields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// Schedule is a five-field cron schedule, "minute hour day-of-month month
+// day-of-week", in a time zone. Fields take *, numbers, ranges (1-5), lists
+// (0,30) and steps (*/15, 9-15/2); Sunday is 0 or 7. As in cron, a day
+// matches when either day field does if both are restricted.
+type Schedule struct {
+	spec  string
+	sets  [5]uint64
+	loc   *time.Location
+	anyDM bool
+	anyDW bool
+}
+
+// Parse parses a schedule of times in loc.
+func Parse(spec string, loc *time.Location) (*Schedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: want 5 fields (minute hour day month weekday)", spec)
+	}
+	c := &Schedule{spec: spec, loc: loc, anyDM: parts[2] == "*", anyDW: parts[4] == "*"}
+	for i, field := range parts {
+		max := fields[i].max
+		if i == 4 {
+			// Accept 7 for Sunday.
+			max = 7
+		}
+		set, err := parseField(field, fields[i].min, max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in schedule %q: %v", fields[i].name, spec, err)
+		}
+		c.sets[i] = set
+	}
+	if c.sets[4]&(1<<7) != 0 {
+		c.sets[4] |= 1
+	}
+	return c, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("bad value %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c *Schedule) String() string {
+	return c.spec
+}
+
+// Next returns the first time of the schedule strictly after t.
+func (c *Schedule) Next(t time.Time) time.Time {
+	local := t.In(c.loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.loc)
+	// Every schedule, even Feb 29, comes around within eight years.
+	for i := 0; i < 8*366; i, day = i+1, day.AddDate(0, 0, 1) {
+		if !c.matchesDay(day) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if c.sets[1]&(1<<uint(h)) == 0 {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if c.sets[0]&(1<<uint(m)) == 0 {
+					continue
+				}
+				if at := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, c.loc); at.After(t) {
+					return at
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (c *Schedule) matchesDay(day time.Time) bool {
+	if c.sets[3]&(1<<uint(day.Month())) == 0 {
+		return false
+	}
+	dm := c.sets[2]&(1<<uint(day.Day())) != 0
+	dw := c.sets[4]&(1<<uint(day.Weekday())) != 0
+	switch {
+	case c.anyDM && c.anyDW:
+		return true
+	case c.anyDM:
+		return dw
+	case c.anyDW:
+		return dm
+	}
+	return dm || dw
+}
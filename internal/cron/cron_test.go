@@ -0,0 +1,42 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	kst, err := time.LoadLocation("Asia/Seoul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Friday 2026-10-16.
+	now := time.Date(2026, 10, 16, 9, 3, 10, 0, kst)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"30 8 * * 1-5", time.Date(2026, 10, 19, 8, 30, 0, 0, kst)},
+		{"*/15 9-15 * * *", time.Date(2026, 10, 16, 9, 15, 0, 0, kst)},
+		{"0 3 * * 7", time.Date(2026, 10, 18, 3, 0, 0, 0, kst)},
+		{"0 0 1,20 * *", time.Date(2026, 10, 20, 0, 0, 0, 0, kst)},
+		// Either day field matches when both are restricted.
+		{"0 12 31 * 6", time.Date(2026, 10, 17, 12, 0, 0, 0, kst)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, kst)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec, kst)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.spec, err)
+		}
+		if got := s.Next(now); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "0 8 * * mon", "5-1 * * * *"} {
+		if _, err := Parse(spec, kst); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+}
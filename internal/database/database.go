@@ -1,11 +1,17 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/market"
 	"tradingbot/internal/models"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 type DB struct {
@@ -15,7 +21,16 @@ type DB struct {
 // NewConnection establishes a new connection to the database and returns a DB instance.
 // It verifies the connection by pinging the database.
 func NewConnection(databaseURL string) (*DB, error) {
-	db, err := sql.Open("mysql", databaseURL)
+	dsn, err := mysql.ParseDSN(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database url: %v", err)
+	}
+	// Scan DATETIME columns straight into time.Time. Times are stored in UTC
+	// whatever zone the URL asks for, and read back in KST.
+	dsn.ParseTime = true
+	dsn.Loc = time.UTC
+
+	db, err := sql.Open("mysql", dsn.FormatDSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %v", err)
 	}
@@ -30,12 +45,613 @@ func NewConnection(databaseURL string) (*DB, error) {
 }
 
 // SaveOrder saves a new order record to the database.
-// Returns an error if the insertion fails.
+// Returns an error if the insertion fails. The strategy column holds the
+// allocation sleeve of the order and signal_price the price its signal was
+// acted on at; existing databases need
+// `ALTER TABLE orders ADD COLUMN strategy VARCHAR(64) NOT NULL DEFAULT ”` and
+// `ALTER TABLE orders ADD COLUMN signal_price DOUBLE NOT NULL DEFAULT 0`.
+//
+// An order whose Key was saved before is not saved again, so that retries
+// are safe. Orders without a key are always saved. It needs
+// `ALTER TABLE orders ADD COLUMN order_key CHAR(36) NULL, ADD UNIQUE KEY (order_key)`.
 func (db *DB) SaveOrder(order *models.Order) error {
-	query := `INSERT INTO orders (pair, type, side, amount, price, status, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := db.Exec(query, order.Pair, order.Type, order.Side, order.Amount, order.Price, order.Status, order.Timestamp)
+	key := sql.NullString{String: order.Key, Valid: order.Key != ""}
+	query := `INSERT INTO orders (pair, type, side, amount, price, status, timestamp, strategy, signal_price, order_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = id`
+	_, err := db.Exec(query, order.Pair, order.Type, order.Side, order.Amount, order.Price, order.Status, order.Timestamp.UTC(), order.Strategy, order.SignalPrice, key)
 	if err != nil {
 		return fmt.Errorf("failed to save order: %v", err)
 	}
 	return nil
 }
+
+// ListOrders returns the most recently saved orders, newest first.
+func (db *DB) ListOrders(limit int) ([]models.Order, error) {
+	return db.queryOrders(`SELECT id, pair, type, side, amount, price, status, timestamp, strategy, signal_price FROM orders ORDER BY timestamp DESC LIMIT ?`, limit)
+}
+
+// ListStrategyOrders returns the orders of allocation sleeves, oldest first.
+func (db *DB) ListStrategyOrders() ([]models.Order, error) {
+	return db.queryOrders(`SELECT id, pair, type, side, amount, price, status, timestamp, strategy, signal_price FROM orders WHERE strategy <> '' ORDER BY timestamp, id`)
+}
+
+// ListOrdersBefore returns the orders placed before t, oldest first.
+func (db *DB) ListOrdersBefore(t time.Time) ([]models.Order, error) {
+	return db.queryOrders(`SELECT id, pair, type, side, amount, price, status, timestamp, strategy, signal_price FROM orders WHERE timestamp < ? ORDER BY timestamp, id`, t.UTC())
+}
+
+// ListOrdersBetween returns the orders placed from from until to, oldest
+// first.
+func (db *DB) ListOrdersBetween(from, to time.Time) ([]models.Order, error) {
+	return db.queryOrders(`SELECT id, pair, type, side, amount, price, status, timestamp, strategy, signal_price FROM orders WHERE timestamp >= ? AND timestamp < ? ORDER BY timestamp, id`, from.UTC(), to.UTC())
+}
+
+func (db *DB) queryOrders(query string, args ...interface{}) ([]models.Order, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %v", err)
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		if err := rows.Scan(&order.ID, &order.Pair, &order.Type, &order.Side, &order.Amount, &order.Price, &order.Status, &order.Timestamp, &order.Strategy, &order.SignalPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %v", err)
+		}
+		order.Timestamp = order.Timestamp.In(market.KST)
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list orders: %v", err)
+	}
+	return orders, nil
+}
+
+// SaveBacktest stores a backtest run and returns its ID. It needs
+//
+//	CREATE TABLE backtests (
+//	  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+//	  created_at DATETIME NOT NULL,
+//	  symbol VARCHAR(16) NOT NULL,
+//	  strategy VARCHAR(64) NOT NULL,
+//	  params TEXT NOT NULL,
+//	  data_from DATETIME NOT NULL,
+//	  data_to DATETIME NOT NULL,
+//	  metrics TEXT NOT NULL,
+//	  git_hash VARCHAR(64) NOT NULL,
+//	  config_hash VARCHAR(64) NOT NULL
+//	);
+func (db *DB) SaveBacktest(run *models.BacktestRun) (int64, error) {
+	params, err := json.Marshal(run.Params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode backtest params: %v", err)
+	}
+	metrics, err := json.Marshal(run.Metrics)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode backtest metrics: %v", err)
+	}
+	query := `INSERT INTO backtests (created_at, symbol, strategy, params, data_from, data_to, metrics, git_hash, config_hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.Exec(query, run.CreatedAt.UTC(), run.Symbol, run.Strategy, params, run.DataFrom.UTC(), run.DataTo.UTC(), metrics, run.GitHash, run.ConfigHash)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save backtest: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get backtest id: %v", err)
+	}
+	return id, nil
+}
+
+// GetBacktest returns the backtest run with the given ID.
+func (db *DB) GetBacktest(id int64) (*models.BacktestRun, error) {
+	runs, err := db.queryBacktests(`SELECT id, created_at, symbol, strategy, params, data_from, data_to, metrics, git_hash, config_hash FROM backtests WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no backtest with id %d", id)
+	}
+	return &runs[0], nil
+}
+
+// ListBacktests returns the most recent backtest runs, newest first.
+func (db *DB) ListBacktests(limit int) ([]models.BacktestRun, error) {
+	return db.queryBacktests(`SELECT id, created_at, symbol, strategy, params, data_from, data_to, metrics, git_hash, config_hash FROM backtests ORDER BY id DESC LIMIT ?`, limit)
+}
+
+// LatestBacktest returns the most recent backtest run of a strategy.
+func (db *DB) LatestBacktest(strategy string) (*models.BacktestRun, error) {
+	runs, err := db.queryBacktests(`SELECT id, created_at, symbol, strategy, params, data_from, data_to, metrics, git_hash, config_hash FROM backtests WHERE strategy = ? ORDER BY id DESC LIMIT 1`, strategy)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no backtest of strategy %s", strategy)
+	}
+	return &runs[0], nil
+}
+
+func (db *DB) queryBacktests(query string, args ...interface{}) ([]models.BacktestRun, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backtests: %v", err)
+	}
+	defer rows.Close()
+
+	var runs []models.BacktestRun
+	for rows.Next() {
+		var run models.BacktestRun
+		var params, metrics []byte
+		if err := rows.Scan(&run.ID, &run.CreatedAt, &run.Symbol, &run.Strategy, &params, &run.DataFrom, &run.DataTo, &metrics, &run.GitHash, &run.ConfigHash); err != nil {
+			return nil, fmt.Errorf("failed to scan backtest: %v", err)
+		}
+		run.CreatedAt = run.CreatedAt.In(market.KST)
+		run.DataFrom, run.DataTo = run.DataFrom.In(market.KST), run.DataTo.In(market.KST)
+		if err := json.Unmarshal(params, &run.Params); err != nil {
+			return nil, fmt.Errorf("invalid params of backtest %d: %v", run.ID, err)
+		}
+		if err := json.Unmarshal(metrics, &run.Metrics); err != nil {
+			return nil, fmt.Errorf("invalid metrics of backtest %d: %v", run.ID, err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list backtests: %v", err)
+	}
+	return runs, nil
+}
+
+// GetDailyCandles returns the latest days daily candles of a stock, oldest
+// first, for the database market data provider. It needs
+//
+//	CREATE TABLE daily_candles (
+//	  symbol VARCHAR(16) NOT NULL,
+//	  date DATE NOT NULL,
+//	  open DOUBLE NOT NULL,
+//	  high DOUBLE NOT NULL,
+//	  low DOUBLE NOT NULL,
+//	  close DOUBLE NOT NULL,
+//	  volume DOUBLE NOT NULL,
+//	  PRIMARY KEY (symbol, date)
+//	)
+//
+// with dates in KST.
+func (db *DB) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	rows, err := db.Query(`SELECT date, open, high, low, close, volume FROM daily_candles WHERE symbol = ? ORDER BY date DESC LIMIT ?`, stockCode, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily candles: %v", err)
+	}
+	defer rows.Close()
+
+	var candles []candle.Candle
+	for rows.Next() {
+		c := candle.Candle{Symbol: stockCode, Timeframe: 24 * time.Hour}
+		var date time.Time
+		if err := rows.Scan(&date, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume); err != nil {
+			return nil, fmt.Errorf("failed to scan daily candle: %v", err)
+		}
+		c.Start = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, market.KST)
+		candles = append(candles, c)
+	}
+	for i, j := 0, len(candles)-1; i < j; i, j = i+1, j-1 {
+		candles[i], candles[j] = candles[j], candles[i]
+	}
+	return candles, rows.Err()
+}
+
+// SaveDailyCandles stores daily candles in daily_candles, replacing the ones
+// already stored for the same symbols and dates.
+func (db *DB) SaveDailyCandles(candles []candle.Candle) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save daily candles: %v", err)
+	}
+	defer tx.Rollback()
+	for _, c := range candles {
+		date := c.Start.In(market.KST).Format("2006-01-02")
+		if _, err := tx.Exec(`INSERT INTO daily_candles (symbol, date, open, high, low, close, volume) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE open = VALUES(open), high = VALUES(high), low = VALUES(low), close = VALUES(close), volume = VALUES(volume)`,
+			c.Symbol, date, c.Open, c.High, c.Low, c.Close, c.Volume); err != nil {
+			return fmt.Errorf("failed to save daily candles: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save daily candles: %v", err)
+	}
+	return nil
+}
+
+// DailyCandleDates returns the dates, at midnight KST, from from to to for
+// which a daily candle of a stock is stored, oldest first.
+func (db *DB) DailyCandleDates(stockCode string, from, to time.Time) ([]time.Time, error) {
+	rows, err := db.Query(`SELECT date FROM daily_candles WHERE symbol = ? AND date BETWEEN ? AND ? ORDER BY date`,
+		stockCode, from.In(market.KST).Format("2006-01-02"), to.In(market.KST).Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily candle dates: %v", err)
+	}
+	defer rows.Close()
+
+	var dates []time.Time
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("failed to scan daily candle date: %v", err)
+		}
+		dates = append(dates, time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, market.KST))
+	}
+	return dates, rows.Err()
+}
+
+// SaveCandles stores the candles the engine completed: daily ones in
+// daily_candles, others in
+//
+//	CREATE TABLE candles (
+//	  symbol VARCHAR(16) NOT NULL,
+//	  timeframe INT NOT NULL,
+//	  start DATETIME NOT NULL,
+//	  open DOUBLE NOT NULL,
+//	  high DOUBLE NOT NULL,
+//	  low DOUBLE NOT NULL,
+//	  close DOUBLE NOT NULL,
+//	  volume DOUBLE NOT NULL,
+//	  PRIMARY KEY (symbol, timeframe, start)
+//	)
+//
+// with the timeframe in seconds and start in UTC.
+func (db *DB) SaveCandles(candles []candle.Candle) error {
+	var daily []candle.Candle
+	for _, c := range candles {
+		if c.Timeframe == 24*time.Hour {
+			daily = append(daily, c)
+			continue
+		}
+		if _, err := db.Exec(`INSERT INTO candles (symbol, timeframe, start, open, high, low, close, volume) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE open = VALUES(open), high = VALUES(high), low = VALUES(low), close = VALUES(close), volume = VALUES(volume)`,
+			c.Symbol, int64(c.Timeframe/time.Second), c.Start.UTC(), c.Open, c.High, c.Low, c.Close, c.Volume); err != nil {
+			return fmt.Errorf("failed to save candle: %v", err)
+		}
+	}
+	if len(daily) > 0 {
+		return db.SaveDailyCandles(daily)
+	}
+	return nil
+}
+
+// LatestCandle returns the latest stored candle of a stock and timeframe, or
+// nil when there is none.
+func (db *DB) LatestCandle(stockCode string, timeframe time.Duration) (*candle.Candle, error) {
+	if timeframe == 24*time.Hour {
+		candles, err := db.GetDailyCandles(stockCode, 1)
+		if err != nil || len(candles) == 0 {
+			return nil, err
+		}
+		return &candles[0], nil
+	}
+
+	c := candle.Candle{Symbol: stockCode, Timeframe: timeframe}
+	err := db.QueryRow(`SELECT start, open, high, low, close, volume FROM candles WHERE symbol = ? AND timeframe = ? ORDER BY start DESC LIMIT 1`,
+		stockCode, int64(timeframe/time.Second)).Scan(&c.Start, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest candle: %v", err)
+	}
+	c.Start = c.Start.In(market.KST)
+	return &c, nil
+}
+
+// ListCandles returns the stored candles of a stock and timeframe starting
+// from from until to, oldest first.
+func (db *DB) ListCandles(stockCode string, timeframe time.Duration, from, to time.Time) ([]candle.Candle, error) {
+	var rows *sql.Rows
+	var err error
+	if timeframe == 24*time.Hour {
+		rows, err = db.Query(`SELECT date, open, high, low, close, volume FROM daily_candles WHERE symbol = ? AND date >= ? AND date < ? ORDER BY date`,
+			stockCode, from.In(market.KST).Format("2006-01-02"), to.In(market.KST).Format("2006-01-02"))
+	} else {
+		rows, err = db.Query(`SELECT start, open, high, low, close, volume FROM candles WHERE symbol = ? AND timeframe = ? AND start >= ? AND start < ? ORDER BY start`,
+			stockCode, int64(timeframe/time.Second), from.UTC(), to.UTC())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list candles: %v", err)
+	}
+	defer rows.Close()
+	return scanCandles(rows, stockCode, timeframe, 0)
+}
+
+// ListCandlesAfter returns up to limit stored candles of a stock and
+// timeframe starting after after and before to, oldest first. Paging with the
+// start of the last candle returned reads a long history in bounded chunks.
+func (db *DB) ListCandlesAfter(stockCode string, timeframe time.Duration, after, to time.Time, limit int) ([]candle.Candle, error) {
+	var rows *sql.Rows
+	var err error
+	if timeframe == 24*time.Hour {
+		rows, err = db.Query(`SELECT date, open, high, low, close, volume FROM daily_candles WHERE symbol = ? AND date > ? AND date < ? ORDER BY date LIMIT ?`,
+			stockCode, after.In(market.KST).Format("2006-01-02"), to.In(market.KST).Format("2006-01-02"), limit)
+	} else {
+		rows, err = db.Query(`SELECT start, open, high, low, close, volume FROM candles WHERE symbol = ? AND timeframe = ? AND start > ? AND start < ? ORDER BY start LIMIT ?`,
+			stockCode, int64(timeframe/time.Second), after.UTC(), to.UTC(), limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list candles: %v", err)
+	}
+	defer rows.Close()
+	return scanCandles(rows, stockCode, timeframe, limit)
+}
+
+// scanCandles reads candles of a stock and timeframe from rows of start,
+// open, high, low, close and volume.
+func scanCandles(rows *sql.Rows, stockCode string, timeframe time.Duration, capacity int) ([]candle.Candle, error) {
+	candles := make([]candle.Candle, 0, capacity)
+	for rows.Next() {
+		c := candle.Candle{Symbol: stockCode, Timeframe: timeframe}
+		if err := rows.Scan(&c.Start, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume); err != nil {
+			return nil, fmt.Errorf("failed to scan candle: %v", err)
+		}
+		if timeframe == 24*time.Hour {
+			c.Start = time.Date(c.Start.Year(), c.Start.Month(), c.Start.Day(), 0, 0, 0, 0, market.KST)
+		} else {
+			c.Start = c.Start.In(market.KST)
+		}
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
+
+// SaveLotSelections replaces the tax lots selected for the sell order of the
+// selections. It needs
+//
+//	CREATE TABLE lot_selections (
+//	  sell_id BIGINT NOT NULL,
+//	  lot_id BIGINT NOT NULL,
+//	  quantity DOUBLE NOT NULL,
+//	  PRIMARY KEY (sell_id, lot_id)
+//	)
+func (db *DB) SaveLotSelections(sellID int64, selections []models.LotSelection) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save lot selections: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM lot_selections WHERE sell_id = ?`, sellID); err != nil {
+		return fmt.Errorf("failed to save lot selections: %v", err)
+	}
+	for _, s := range selections {
+		if _, err := tx.Exec(`INSERT INTO lot_selections (sell_id, lot_id, quantity) VALUES (?, ?, ?)`, sellID, s.LotID, s.Quantity); err != nil {
+			return fmt.Errorf("failed to save lot selections: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save lot selections: %v", err)
+	}
+	return nil
+}
+
+// ListLotSelections returns all tax lot selections.
+func (db *DB) ListLotSelections() ([]models.LotSelection, error) {
+	rows, err := db.Query(`SELECT sell_id, lot_id, quantity FROM lot_selections ORDER BY sell_id, lot_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lot selections: %v", err)
+	}
+	defer rows.Close()
+
+	var selections []models.LotSelection
+	for rows.Next() {
+		var s models.LotSelection
+		if err := rows.Scan(&s.SellID, &s.LotID, &s.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan lot selection: %v", err)
+		}
+		selections = append(selections, s)
+	}
+	return selections, rows.Err()
+}
+
+// PruneCandles deletes the intraday candles that started before before and
+// returns how many were deleted. Daily candles are kept.
+func (db *DB) PruneCandles(before time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM candles WHERE start < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune candles: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+// SaveAccountSnapshot stores the cash balance and positions of the account at
+// the end of a day, replacing an earlier snapshot of the same day. It needs
+//
+//	CREATE TABLE account_snapshots (
+//	  date DATE NOT NULL PRIMARY KEY,
+//	  cash VARCHAR(32) NOT NULL,
+//	  positions JSON NOT NULL,
+//	  created_at DATETIME NOT NULL
+//	)
+func (db *DB) SaveAccountSnapshot(at time.Time, cash string, positions []models.Position) error {
+	data, err := json.Marshal(positions)
+	if err != nil {
+		return fmt.Errorf("failed to encode positions: %v", err)
+	}
+	at = at.In(market.KST)
+	if _, err := db.Exec(`INSERT INTO account_snapshots (date, cash, positions, created_at) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE cash = VALUES(cash), positions = VALUES(positions), created_at = VALUES(created_at)`,
+		at.Format("2006-01-02"), cash, data, at.UTC()); err != nil {
+		return fmt.Errorf("failed to save account snapshot: %v", err)
+	}
+	return nil
+}
+
+// ListAccountSnapshots returns the account snapshots of the days from from
+// until to, oldest first.
+func (db *DB) ListAccountSnapshots(from, to time.Time) ([]models.AccountSnapshot, error) {
+	rows, err := db.Query(`SELECT date, cash, positions FROM account_snapshots WHERE date >= ? AND date < ? ORDER BY date`,
+		from.In(market.KST).Format("2006-01-02"), to.In(market.KST).Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list account snapshots: %v", err)
+	}
+	defer rows.Close()
+
+	var snapshots []models.AccountSnapshot
+	for rows.Next() {
+		var s models.AccountSnapshot
+		var date time.Time
+		var positions []byte
+		if err := rows.Scan(&date, &s.Cash, &positions); err != nil {
+			return nil, fmt.Errorf("failed to scan account snapshot: %v", err)
+		}
+		if err := json.Unmarshal(positions, &s.Positions); err != nil {
+			return nil, fmt.Errorf("failed to decode positions of %s: %v", date.Format("2006-01-02"), err)
+		}
+		s.Date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, market.KST)
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// SaveNotification adds a notification to the outbox and sets its ID. It
+// needs
+//
+//	CREATE TABLE notifications (
+//	  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+//	  created_at DATETIME NOT NULL,
+//	  channel VARCHAR(512) NOT NULL,
+//	  kind VARCHAR(32) NOT NULL,
+//	  subject VARCHAR(255) NOT NULL,
+//	  body MEDIUMTEXT NOT NULL,
+//	  status VARCHAR(16) NOT NULL,
+//	  attempts INT NOT NULL,
+//	  next_attempt DATETIME NOT NULL,
+//	  last_error TEXT NOT NULL,
+//	  KEY status_id (status, id)
+//	);
+func (db *DB) SaveNotification(n *models.Notification) error {
+	query := `INSERT INTO notifications (created_at, channel, kind, subject, body, status, attempts, next_attempt, last_error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.Exec(query, n.CreatedAt.UTC(), n.Channel, n.Kind, n.Subject, n.Body, n.Status, n.Attempts, n.NextAttempt.UTC(), n.LastError)
+	if err != nil {
+		return fmt.Errorf("failed to save notification: %v", err)
+	}
+	if n.ID, err = res.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get notification id: %v", err)
+	}
+	return nil
+}
+
+// PendingNotifications returns up to limit undelivered notifications, oldest
+// first.
+func (db *DB) PendingNotifications(limit int) ([]models.Notification, error) {
+	rows, err := db.Query(`SELECT id, created_at, channel, kind, subject, body, status, attempts, next_attempt, last_error FROM notifications WHERE status = ? ORDER BY id LIMIT ?`, models.NotificationPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %v", err)
+	}
+	defer rows.Close()
+
+	var out []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.CreatedAt, &n.Channel, &n.Kind, &n.Subject, &n.Body, &n.Status, &n.Attempts, &n.NextAttempt, &n.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %v", err)
+		}
+		n.CreatedAt, n.NextAttempt = n.CreatedAt.In(market.KST), n.NextAttempt.In(market.KST)
+		out = append(out, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %v", err)
+	}
+	return out, nil
+}
+
+// UpdateNotification stores the delivery state of a notification: its status,
+// attempts, next attempt and last error.
+func (db *DB) UpdateNotification(n *models.Notification) error {
+	query := `UPDATE notifications SET status = ?, attempts = ?, next_attempt = ?, last_error = ? WHERE id = ?`
+	if _, err := db.Exec(query, n.Status, n.Attempts, n.NextAttempt.UTC(), n.LastError, n.ID); err != nil {
+		return fmt.Errorf("failed to update notification %d: %v", n.ID, err)
+	}
+	return nil
+}
+
+// Lock is a MySQL named lock held on a connection of its own; the server
+// releases it when that connection ends, e.g. when the process dies. It
+// implements lock.Lock and is safe for concurrent use.
+type Lock struct {
+	name string
+
+	mu   sync.Mutex
+	db   *DB
+	conn *sql.Conn
+}
+
+// AcquireLock takes the named lock without waiting. It fails, naming the
+// connection holding it, if another session has it.
+func (db *DB) AcquireLock(name string) (*Lock, error) {
+	l := &Lock{db: db, name: name}
+	if err := l.acquire(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetDB replaces the database the lock is taken again on, e.g. after a
+// reconnect with a rotated password. The connection holding the lock is
+// kept until it is lost.
+func (l *Lock) SetDB(db *DB) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.db = db
+}
+
+// acquire takes the lock on a new connection. l.mu must be held, or l not yet
+// shared.
+func (l *Lock) acquire() error {
+	ctx := context.Background()
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open lock connection: %v", err)
+	}
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 0)`, l.name).Scan(&got); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to take lock %s: %v", l.name, err)
+	}
+	if got.Int64 != 1 {
+		var holder sql.NullInt64
+		conn.QueryRowContext(ctx, `SELECT IS_USED_LOCK(?)`, l.name).Scan(&holder)
+		conn.Close()
+		return fmt.Errorf("lock %s is held by database connection %d; another instance is trading this account", l.name, holder.Int64)
+	}
+	l.conn = conn
+	return nil
+}
+
+// Check verifies the lock is still held by its connection, and takes it again
+// on a new connection if that one was lost.
+func (l *Lock) Check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		var held sql.NullBool
+		err := l.conn.QueryRowContext(context.Background(), `SELECT IS_USED_LOCK(?) = CONNECTION_ID()`, l.name).Scan(&held)
+		if err == nil && held.Bool {
+			return nil
+		}
+		l.conn.Close()
+		l.conn = nil
+	}
+	return l.acquire()
+}
+
+// Release gives the lock up and closes its connection.
+func (l *Lock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(context.Background(), `DO RELEASE_LOCK(?)`, l.name)
+	l.conn.Close()
+	l.conn = nil
+	if err != nil {
+		return fmt.Errorf("failed to release lock %s: %v", l.name, err)
+	}
+	return nil
+}
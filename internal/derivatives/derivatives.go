@@ -0,0 +1,162 @@
+package derivatives
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+// DefaultMultiplier is the KRW value of a KOSPI200 index point for futures
+// and options.
+const DefaultMultiplier = 250000
+
+// Market quotes the contracts an overlay trades, e.g. the KIS client.
+type Market interface {
+	GetDerivativeQuote(code string) (*models.DerivativeQuote, error)
+	GetOptionChain(maturity string) ([]models.OptionQuote, error)
+}
+
+// Expiry returns the last trading day of the KOSPI200 contracts maturing in
+// month: its second Thursday. Holidays moving it forward are not considered.
+func Expiry(year int, month time.Month) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, market.KST)
+	offset := (int(time.Thursday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7)
+}
+
+// FrontMonth returns the nearest maturity, YYYYMM, whose contracts still
+// trade on the day of now.
+func FrontMonth(now time.Time) string {
+	now = now.In(market.KST)
+	expiry := Expiry(now.Year(), now.Month())
+	if now.Before(expiry.AddDate(0, 0, 1)) {
+		return expiry.Format("200601")
+	}
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, market.KST).Format("200601")
+}
+
+// Plan returns the orders that bring the covered calls and the futures hedge
+// of cfg in line with the stock holdings, given the open derivatives
+// positions. Options are priced at their last price and futures at theirs.
+func Plan(cfg config.DerivativesConfig, holdings []models.Position, positions []models.DerivativePosition, mkt Market, now time.Time) ([]models.DerivativeOrder, error) {
+	value := 0.0
+	for _, h := range holdings {
+		if len(cfg.Symbols) == 0 || contains(cfg.Symbols, h.StockCode) {
+			value += h.Quantity * h.CurrentPrice
+		}
+	}
+
+	future, err := mkt.GetDerivativeQuote(cfg.Future)
+	if err != nil {
+		return nil, fmt.Errorf("failed to quote %s: %v", cfg.Future, err)
+	}
+	if future.Price <= 0 {
+		return nil, fmt.Errorf("no price for %s", cfg.Future)
+	}
+	multiplier := cfg.Multiplier
+	if multiplier == 0 {
+		multiplier = DefaultMultiplier
+	}
+	contract := future.Price * multiplier
+
+	var orders []models.DerivativeOrder
+	if cfg.CoveredCall.Enabled {
+		calls, err := coveredCalls(cfg.CoveredCall, math.Floor(value/contract), future.Price, positions, mkt, now)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, calls...)
+	}
+	if cfg.Hedge.Enabled {
+		beta := cfg.Hedge.Beta
+		if beta == 0 {
+			beta = 1
+		}
+		target := math.Round(value * cfg.Hedge.Ratio * beta / contract)
+		short := 0.0
+		for _, p := range positions {
+			if p.Code == cfg.Future {
+				short -= p.Signed()
+			}
+		}
+		reason := fmt.Sprintf("hedge ₩%.0f x %g x beta %g with %g contracts", value, cfg.Hedge.Ratio, beta, target)
+		switch {
+		case target > short:
+			orders = append(orders, models.DerivativeOrder{Code: cfg.Future, Side: models.OrderSideSell, Quantity: target - short, Price: future.Price, Reason: reason})
+		case target < short:
+			orders = append(orders, models.DerivativeOrder{Code: cfg.Future, Side: models.OrderSideBuy, Quantity: short - target, Price: future.Price, Reason: reason})
+		}
+	}
+	return orders, nil
+}
+
+// coveredCalls writes calls until wanted are short, or buys back the short
+// calls beyond it.
+func coveredCalls(cfg config.CoveredCallConfig, wanted, index float64, positions []models.DerivativePosition, mkt Market, now time.Time) ([]models.DerivativeOrder, error) {
+	var short []models.DerivativePosition
+	written := 0.0
+	for _, p := range positions {
+		if p.Kind == models.ContractCall && p.Side == models.PositionShort {
+			short = append(short, p)
+			written += p.Quantity
+		}
+	}
+
+	if written > wanted {
+		var orders []models.DerivativeOrder
+		excess := written - wanted
+		for _, p := range short {
+			if excess <= 0 {
+				break
+			}
+			qty := math.Min(excess, p.Quantity)
+			orders = append(orders, models.DerivativeOrder{
+				Code: p.Code, Side: models.OrderSideBuy, Quantity: qty, Price: p.CurrentPrice,
+				Reason: fmt.Sprintf("holdings cover %g calls, %g written", wanted, written),
+			})
+			excess -= qty
+		}
+		return orders, nil
+	}
+	if written == wanted {
+		return nil, nil
+	}
+
+	maturity := cfg.Maturity
+	if maturity == "" {
+		maturity = FrontMonth(now)
+	}
+	chain, err := mkt.GetOptionChain(maturity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get option chain %s: %v", maturity, err)
+	}
+	floor := index * (1 + cfg.MinOTM)
+	var best *models.OptionQuote
+	for i, q := range chain {
+		if q.Kind != models.ContractCall || q.Strike < floor || q.Price <= 0 {
+			continue
+		}
+		if best == nil || q.Strike < best.Strike {
+			best = &chain[i]
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no call in %s at or above strike %.2f", maturity, floor)
+	}
+	return []models.DerivativeOrder{{
+		Code: best.Code, Side: models.OrderSideSell, Quantity: wanted - written, Price: best.Price,
+		Reason: fmt.Sprintf("cover %g contracts of holdings at strike %g, index %g", wanted, best.Strike, index),
+	}}, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
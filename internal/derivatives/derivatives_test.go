@@ -0,0 +1,96 @@
+package derivatives
+
+import (
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+type fakeMarket struct {
+	future float64
+	chain  []models.OptionQuote
+	// maturity is the last maturity the chain was requested for.
+	maturity string
+}
+
+func (f *fakeMarket) GetDerivativeQuote(code string) (*models.DerivativeQuote, error) {
+	return &models.DerivativeQuote{Code: code, Price: f.future}, nil
+}
+
+func (f *fakeMarket) GetOptionChain(maturity string) ([]models.OptionQuote, error) {
+	f.maturity = maturity
+	return f.chain, nil
+}
+
+func TestFrontMonth(t *testing.T) {
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2026, 10, 1, 10, 0, 0, 0, market.KST), "202610"},
+		{time.Date(2026, 10, 8, 15, 0, 0, 0, market.KST), "202610"}, // expiry day
+		{time.Date(2026, 10, 9, 9, 0, 0, 0, market.KST), "202611"},
+		{time.Date(2026, 12, 31, 9, 0, 0, 0, market.KST), "202701"},
+	}
+	for _, tt := range tests {
+		if got := FrontMonth(tt.at); got != tt.want {
+			t.Errorf("FrontMonth(%s) = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+	if got := Expiry(2026, time.November); got.Day() != 12 {
+		t.Errorf("November 2026 expiry = %s, want the 12th", got)
+	}
+}
+
+func TestPlanCoversAndHedgesHoldings(t *testing.T) {
+	mkt := &fakeMarket{future: 400, chain: []models.OptionQuote{
+		{Code: "201WB405", Kind: models.ContractCall, Strike: 405, Price: 3.1},
+		{Code: "201WB410", Kind: models.ContractCall, Strike: 410, Price: 1.5},
+		{Code: "201WB412", Kind: models.ContractCall, Strike: 412.5, Price: 0.9},
+		{Code: "301WB395", Kind: models.ContractPut, Strike: 395, Price: 2.4},
+	}}
+	cfg := config.DerivativesConfig{
+		Future:      "101W12",
+		Symbols:     []string{"069500"},
+		CoveredCall: config.CoveredCallConfig{Enabled: true, MinOTM: 0.02},
+		Hedge:       config.HedgeConfig{Enabled: true, Ratio: 0.5},
+	}
+	// ₩1,000,000,000 of the ETF is ten contracts at 400 points.
+	holdings := []models.Position{
+		{StockCode: "069500", Quantity: 25000, CurrentPrice: 40000},
+		{StockCode: "005930", Quantity: 100, CurrentPrice: 70000},
+	}
+	positions := []models.DerivativePosition{
+		{Code: "201WA410", Kind: models.ContractCall, Side: models.PositionShort, Quantity: 4},
+		{Code: "101W12", Kind: models.ContractFuture, Side: models.PositionShort, Quantity: 7},
+	}
+
+	orders, err := Plan(cfg, holdings, positions, mkt, time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mkt.maturity != "202611" {
+		t.Errorf("chain maturity = %s, want the front month 202611", mkt.maturity)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("orders = %+v, want a call sale and a hedge adjustment", orders)
+	}
+	if o := orders[0]; o.Code != "201WB410" || o.Side != models.OrderSideSell || o.Quantity != 6 || o.Price != 1.5 {
+		t.Errorf("call order = %+v, want 6 of the 410 strike sold at 1.5", o)
+	}
+	if o := orders[1]; o.Code != "101W12" || o.Side != models.OrderSideBuy || o.Quantity != 2 {
+		t.Errorf("hedge order = %+v, want 2 futures bought back to a 5 contract hedge", o)
+	}
+
+	// Once the holdings are sold the calls are bought back.
+	cfg.Hedge.Enabled = false
+	orders, err = Plan(cfg, nil, positions, mkt, time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Code != "201WA410" || orders[0].Side != models.OrderSideBuy || orders[0].Quantity != 4 {
+		t.Errorf("orders = %+v, want the 4 written calls bought back", orders)
+	}
+}
@@ -0,0 +1,182 @@
+package earnings
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tradingbot/internal/market"
+)
+
+const (
+	dartBaseURL  = "https://opendart.fss.or.kr/api"
+	dartPageSize = 100
+
+	// dartNoData is the status of a query without results.
+	dartNoData = "013"
+)
+
+// earningsReports are fragments of the names of earnings filings: preliminary
+// results and the quarterly, half-year and annual reports.
+var earningsReports = []string{"영업(잠정)실적", "분기보고서", "반기보고서", "사업보고서"}
+
+// IsEarnings tells whether a DART report name is an earnings filing.
+func IsEarnings(report string) bool {
+	for _, r := range earningsReports {
+		if strings.Contains(report, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// DART reads disclosures from the Open DART API of the Financial Supervisory
+// Service. Companies are identified there by corporation codes, which it
+// downloads once and maps from stock codes.
+type DART struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+
+	mu        sync.Mutex
+	corpCodes map[string]string
+}
+
+// NewDART creates a DART client using apiKey.
+func NewDART(apiKey string) *DART {
+	return &DART{baseURL: dartBaseURL, apiKey: apiKey, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+type dartCorpCodes struct {
+	List []struct {
+		CorpCode  string `xml:"corp_code"`
+		StockCode string `xml:"stock_code"`
+	} `xml:"list"`
+}
+
+type dartList struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	TotalPage int    `json:"total_page"`
+	List      []struct {
+		ReportName  string `json:"report_nm"`
+		ReceiptDate string `json:"rcept_dt"`
+	} `json:"list"`
+}
+
+// Disclosures returns the filings of symbol received from from to to.
+func (d *DART) Disclosures(symbol string, from, to time.Time) ([]Disclosure, error) {
+	corp, err := d.corpCode(symbol)
+	if err != nil {
+		return nil, err
+	}
+	var disclosures []Disclosure
+	for page := 1; ; page++ {
+		query := url.Values{
+			"crtfc_key":  {d.apiKey},
+			"corp_code":  {corp},
+			"bgn_de":     {from.In(market.KST).Format("20060102")},
+			"end_de":     {to.In(market.KST).Format("20060102")},
+			"page_no":    {strconv.Itoa(page)},
+			"page_count": {strconv.Itoa(dartPageSize)},
+		}
+		body, err := d.get("/list.json?" + query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var resp dartList
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse DART disclosures: %v", err)
+		}
+		if resp.Status == dartNoData {
+			return disclosures, nil
+		}
+		if resp.Status != "000" {
+			return nil, fmt.Errorf("DART disclosures failed: %s %s", resp.Status, resp.Message)
+		}
+		for _, item := range resp.List {
+			date, err := time.ParseInLocation("20060102", item.ReceiptDate, market.KST)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DART receipt date %q", item.ReceiptDate)
+			}
+			disclosures = append(disclosures, Disclosure{Symbol: symbol, Report: strings.TrimSpace(item.ReportName), Date: date})
+		}
+		if page >= resp.TotalPage {
+			return disclosures, nil
+		}
+	}
+}
+
+func (d *DART) corpCode(symbol string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.corpCodes == nil {
+		codes, err := d.loadCorpCodes()
+		if err != nil {
+			return "", err
+		}
+		d.corpCodes = codes
+	}
+	corp, ok := d.corpCodes[symbol]
+	if !ok {
+		return "", fmt.Errorf("no DART corporation code for %s", symbol)
+	}
+	return corp, nil
+}
+
+// loadCorpCodes downloads the zipped corporation code list.
+func (d *DART) loadCorpCodes() (map[string]string, error) {
+	body, err := d.get("/corpCode.xml?" + url.Values{"crtfc_key": {d.apiKey}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		// Errors come back as JSON or XML instead of an archive.
+		return nil, fmt.Errorf("DART corporation codes failed: %s", body)
+	}
+	if len(archive.File) == 0 {
+		return nil, fmt.Errorf("empty DART corporation code archive")
+	}
+	f, err := archive.File[0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var list dartCorpCodes
+	if err := xml.NewDecoder(f).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse DART corporation codes: %v", err)
+	}
+	codes := make(map[string]string, len(list.List))
+	for _, item := range list.List {
+		if stock := strings.TrimSpace(item.StockCode); stock != "" {
+			codes[stock] = item.CorpCode
+		}
+	}
+	return codes, nil
+}
+
+func (d *DART) get(path string) ([]byte, error) {
+	resp, err := d.client.Get(d.baseURL + path)
+	if err != nil {
+		return nil, fmt.Errorf("DART request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DART response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DART request failed, status code: %d", resp.StatusCode)
+	}
+	return body, nil
+}
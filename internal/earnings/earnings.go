@@ -0,0 +1,119 @@
+package earnings
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+)
+
+var log = logging.New()
+
+// lookback is how far back disclosures are read to estimate earnings dates.
+const lookback = 400 * 24 * time.Hour
+
+// Calendar holds the known and expected earnings dates of symbols.
+type Calendar struct {
+	dates map[string][]time.Time
+}
+
+// NewCalendar returns an empty calendar.
+func NewCalendar() *Calendar {
+	return &Calendar{dates: map[string][]time.Time{}}
+}
+
+// Add records an earnings date of symbol; only its KST day counts.
+func (c *Calendar) Add(symbol string, date time.Time) {
+	day := dayOf(date)
+	dates := c.dates[symbol]
+	i := sort.Search(len(dates), func(i int) bool { return !dates[i].Before(day) })
+	if i < len(dates) && dates[i].Equal(day) {
+		return
+	}
+	dates = append(dates, time.Time{})
+	copy(dates[i+1:], dates[i:])
+	dates[i] = day
+	c.dates[symbol] = dates
+}
+
+// Dates returns the earnings dates of symbol in order.
+func (c *Calendar) Dates(symbol string) []time.Time {
+	return c.dates[symbol]
+}
+
+// Upcoming returns the first earnings date of symbol from the day of at up to
+// days days later.
+func (c *Calendar) Upcoming(symbol string, at time.Time, days int) (time.Time, bool) {
+	today := dayOf(at)
+	last := today.AddDate(0, 0, days)
+	for _, date := range c.dates[symbol] {
+		if date.Before(today) {
+			continue
+		}
+		if date.After(last) {
+			break
+		}
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+func dayOf(t time.Time) time.Time {
+	t = t.In(market.KST)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, market.KST)
+}
+
+// Disclosure is a filing of a listed company.
+type Disclosure struct {
+	Symbol string
+	Report string
+	Date   time.Time
+}
+
+// Source lists the disclosures of symbols, e.g. DART.
+type Source interface {
+	Disclosures(symbol string, from, to time.Time) ([]Disclosure, error)
+}
+
+// Load builds the calendar of symbols from the configured dates and, with
+// source set, from their disclosures of the past year: each earnings filing
+// is expected to recur a year later. Symbols whose disclosures cannot be read
+// keep only their configured dates; the returned error lists them.
+func Load(cfg config.EarningsConfig, source Source, symbols []string, now time.Time) (*Calendar, error) {
+	cal := NewCalendar()
+	for symbol, days := range cfg.Dates {
+		for _, day := range days {
+			date, err := time.ParseInLocation("2006-01-02", day, market.KST)
+			if err != nil {
+				return nil, fmt.Errorf("invalid earnings date %q for %s", day, symbol)
+			}
+			cal.Add(symbol, date)
+		}
+	}
+	if source == nil {
+		return cal, nil
+	}
+
+	var failed []string
+	for _, symbol := range symbols {
+		disclosures, err := source.Disclosures(symbol, now.Add(-lookback), now)
+		if err != nil {
+			log.WithError(err).WithField("symbol", symbol).Warn("Failed to read disclosures")
+			failed = append(failed, symbol)
+			continue
+		}
+		for _, d := range disclosures {
+			if !IsEarnings(d.Report) {
+				continue
+			}
+			cal.Add(symbol, d.Date)
+			cal.Add(symbol, d.Date.AddDate(1, 0, 0))
+		}
+	}
+	if len(failed) > 0 {
+		return cal, fmt.Errorf("no disclosures for %v", failed)
+	}
+	return cal, nil
+}
@@ -0,0 +1,120 @@
+package earnings
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+)
+
+func day(s string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02", s, market.KST)
+	return t
+}
+
+type fakeSource map[string][]Disclosure
+
+func (f fakeSource) Disclosures(symbol string, from, to time.Time) ([]Disclosure, error) {
+	d, ok := f[symbol]
+	if !ok {
+		return nil, errors.New("unknown symbol")
+	}
+	return d, nil
+}
+
+func TestLoadEstimatesEarningsDates(t *testing.T) {
+	source := fakeSource{"005930": {
+		{Report: "연결재무제표기준영업(잠정)실적(공정공시)", Date: day("2025-10-14")},
+		{Report: "분기보고서 (2025.09)", Date: day("2025-11-14")},
+		{Report: "임원ㆍ주요주주특정증권등소유상황보고서", Date: day("2025-11-20")},
+	}}
+	cfg := config.EarningsConfig{Dates: map[string][]string{"000660": {"2026-10-23"}}}
+	cal, err := Load(cfg, source, []string{"005930", "035720"}, day("2026-10-01"))
+	if err == nil {
+		t.Error("Load did not report the symbol without disclosures")
+	}
+
+	if got := cal.Dates("005930"); len(got) != 4 || !got[2].Equal(day("2026-10-14")) || !got[3].Equal(day("2026-11-14")) {
+		t.Errorf("dates of 005930 = %v, want the earnings filings and a year later", got)
+	}
+	tests := []struct {
+		symbol string
+		at     string
+		days   int
+		want   string
+	}{
+		{"005930", "2026-10-10", 3, ""},
+		{"005930", "2026-10-11", 3, "2026-10-14"},
+		{"005930", "2026-10-14", 0, "2026-10-14"},
+		{"005930", "2026-10-15", 3, ""},
+		{"000660", "2026-10-20", 5, "2026-10-23"},
+	}
+	for _, tt := range tests {
+		date, ok := cal.Upcoming(tt.symbol, day(tt.at).Add(10*time.Hour), tt.days)
+		if ok != (tt.want != "") || (ok && !date.Equal(day(tt.want))) {
+			t.Errorf("Upcoming(%s, %s, %d) = %v, %v, want %q", tt.symbol, tt.at, tt.days, date, ok, tt.want)
+		}
+	}
+}
+
+func TestDARTDisclosures(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	f, _ := zw.Create("CORPCODE.xml")
+	f.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<result>
+	<list><corp_code>00126380</corp_code><corp_name>삼성전자</corp_name><stock_code>005930</stock_code></list>
+	<list><corp_code>00999999</corp_code><corp_name>비상장</corp_name><stock_code> </stock_code></list>
+</result>`))
+	zw.Close()
+
+	corpRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("crtfc_key") != "dart-key" {
+			w.Write([]byte(`{"status":"010","message":"등록되지 않은 키입니다."}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/corpCode.xml":
+			corpRequests++
+			w.Write(archive.Bytes())
+		case "/list.json":
+			if r.URL.Query().Get("corp_code") != "00126380" || r.URL.Query().Get("bgn_de") != "20251001" {
+				t.Errorf("list query = %s", r.URL.RawQuery)
+			}
+			if r.URL.Query().Get("page_no") == "1" {
+				w.Write([]byte(`{"status":"000","message":"정상","total_page":2,"list":[{"report_nm":"분기보고서 (2025.09)","rcept_dt":"20251114"}]}`))
+			} else {
+				w.Write([]byte(`{"status":"000","message":"정상","total_page":2,"list":[{"report_nm":" 반기보고서 (2026.06)","rcept_dt":"20260814"}]}`))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDART("dart-key")
+	d.baseURL = srv.URL
+	got, err := d.Disclosures("005930", day("2025-10-01"), day("2026-10-01"))
+	if err != nil {
+		t.Fatalf("Disclosures returned error: %v", err)
+	}
+	if len(got) != 2 || got[1].Report != "반기보고서 (2026.06)" || !got[1].Date.Equal(day("2026-08-14")) {
+		t.Errorf("disclosures = %+v", got)
+	}
+	if _, err := d.Disclosures("999999", day("2025-10-01"), day("2026-10-01")); err == nil {
+		t.Error("Disclosures of an unknown symbol succeeded")
+	}
+	if corpRequests != 1 {
+		t.Errorf("corporation codes downloaded %d times, want once", corpRequests)
+	}
+
+	bad := NewDART("wrong")
+	bad.baseURL = srv.URL
+	if _, err := bad.Disclosures("005930", day("2025-10-01"), day("2026-10-01")); err == nil {
+		t.Error("Disclosures with an invalid key succeeded")
+	}
+}
@@ -0,0 +1,1632 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tradingbot/internal/allocation"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/circuit"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/correlation"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/rules"
+	"tradingbot/internal/sizing"
+	"tradingbot/internal/strategy"
+	"tradingbot/internal/sweep"
+	"tradingbot/internal/universe"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+// MarketData is the source of the quotes the engine trades on.
+type MarketData interface {
+	GetMarketData(stockCode string) (*models.MarketData, error)
+}
+
+// Executor places orders.
+type Executor interface {
+	PlaceOrder(signal *models.Signal) (*models.Order, error)
+}
+
+// Exchange is the part of the exchange client the engine needs: by default it
+// provides both quotes and execution.
+type Exchange interface {
+	MarketData
+	Executor
+}
+
+// PositionSource is implemented by exchanges that report the held positions.
+// With one, strategy signals are sized against the position; see
+// config.PositionConfig.
+type PositionSource interface {
+	GetPositions() ([]models.Position, error)
+}
+
+// EarningsCalendar tells when symbols report earnings; see
+// config.EarningsConfig.
+type EarningsCalendar interface {
+	Upcoming(symbol string, at time.Time, days int) (time.Time, bool)
+}
+
+// BalanceSource is implemented by exchanges that report the cash balance.
+// With one, credit buys are checked against the leverage limit; see
+// config.MarginConfig.
+type BalanceSource interface {
+	GetBalance() (string, error)
+}
+
+// NAVSource is implemented by market data sources and exchanges that publish
+// the indicative NAV of ETFs and ETNs; see config.ETFConfig.
+type NAVSource interface {
+	GetNAV(stockCode string) (float64, error)
+}
+
+// AskingPriceSource is implemented by market data sources and exchanges that
+// publish the best ask and bid of a symbol apart from its quote, for the
+// quote age and spread checks; see config.RiskConfig.
+type AskingPriceSource interface {
+	GetAskingPrice(stockCode string) (models.AskingPrice, error)
+}
+
+// OrderStore persists placed orders.
+type OrderStore interface {
+	SaveOrder(order *models.Order) error
+}
+
+// CandleStore is implemented by stores that also keep the completed candles
+// of the configured timeframe, so that a restart can tell where it left off.
+type CandleStore interface {
+	SaveCandles(candles []candle.Candle) error
+}
+
+// Engine wires the trading components together over an event bus:
+//
+//	RunCycle -> MarketDataEvent -> strategy -> SignalEvent -> risk/execution -> OrderEvent -> store
+//
+// With a timeframe configured, market data is first aggregated into candles and
+// the strategies analyze each completed CandleEvent instead of every poll. With
+// bar_close also set, candles whose period began before the symbol's first
+// poll or resumed candle are not analyzed, so the strategies see the same
+// complete bars as in a backtest.
+// Additional subscribers (notifiers, APIs, further strategies) can attach to Bus.
+type Engine struct {
+	Bus *events.Bus
+
+	mu   sync.RWMutex
+	cfg  *config.Config
+	exch Exchange
+	data MarketData
+	// separateData is set when data is not the exchange; its calls then do
+	// not count towards the exchange circuit breaker.
+	separateData bool
+	store        OrderStore
+	strategies   map[string]strategy.Strategy
+	breaker      *circuit.Breaker
+	candles      *candle.Aggregator
+	clock        clock.Clock
+	sweeper      *sweep.Sweeper
+	allocator    *allocation.Allocator
+	lotSizes     map[string]int
+	instruments  map[string]models.InstrumentType
+	sectors      map[string]string
+	paused       map[string]bool
+	earnings     EarningsCalendar
+	sentiment    strategy.Sentiment
+	vwap         strategy.VWAP
+	rules        *rules.Rules
+	kelly        *sizing.Kelly
+	liquidity    *sizing.Liquidity
+	correlations *correlation.Matrix
+
+	// firstSeen is the start of the first input aggregated per symbol; with
+	// bar_close, candles of periods that began earlier are partial.
+	seenMu    sync.Mutex
+	firstSeen map[string]time.Time
+
+	// cycles collects the phase durations of the running cycle of each
+	// symbol, published with its CycleEvent.
+	timingMu sync.Mutex
+	cycles   map[string]map[string]time.Duration
+}
+
+// New creates an engine and subscribes its built-in components to a new bus.
+func New(cfg *config.Config, exch Exchange, store OrderStore, strategies map[string]strategy.Strategy) *Engine {
+	e := &Engine{
+		Bus:        events.NewBus(),
+		cfg:        cfg,
+		exch:       exch,
+		data:       exch,
+		store:      store,
+		strategies: strategies,
+		clock:      clock.Real,
+		cycles:     make(map[string]map[string]time.Duration),
+		firstSeen:  make(map[string]time.Time),
+	}
+
+	if cb := cfg.CircuitBreaker; cb.Enabled {
+		latency, _ := time.ParseDuration(cb.LatencyThreshold)
+		cooldown, _ := time.ParseDuration(cb.Cooldown)
+		e.breaker = circuit.New(circuit.Settings{
+			FailureThreshold: cb.FailureThreshold,
+			LatencyThreshold: latency,
+			Cooldown:         cooldown,
+		}, e.circuitChanged)
+	}
+
+	if cfg.ParsedTimeframe > 0 {
+		e.candles = candle.NewAggregator(cfg.ParsedTimeframe)
+		e.Bus.Subscribe(e.aggregate, events.KindMarketData)
+		e.Bus.Subscribe(e.analyzeCandle, events.KindCandle)
+		e.Bus.Subscribe(e.persistCandle, events.KindCandle)
+	} else {
+		e.Bus.Subscribe(e.analyze, events.KindMarketData)
+	}
+	e.Bus.Subscribe(e.execute, events.KindSignal)
+	e.Bus.Subscribe(e.persist, events.KindOrder)
+	e.Bus.Subscribe(logError, events.KindError)
+	return e
+}
+
+// SetStrategies replaces the per-symbol strategies, e.g. after a config reload.
+func (e *Engine) SetStrategies(strategies map[string]strategy.Strategy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.strategies = strategies
+}
+
+// SetClock replaces the clock used to timestamp events and time exchange calls,
+// e.g. with a simulated clock when replaying recorded sessions.
+func (e *Engine) SetClock(c clock.Clock) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.clock = c
+	if e.breaker != nil {
+		e.breaker.SetClock(c)
+	}
+}
+
+// SetSweeper enables the cash sweep: buys first free up cash parked in the
+// sweep ETF, and Sweep parks idle cash.
+func (e *Engine) SetSweeper(s *sweep.Sweeper) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sweeper = s
+}
+
+// SetAllocator runs the strategies of allocation sleeves instead of the
+// per-symbol strategies, and books their orders.
+func (e *Engine) SetAllocator(a *allocation.Allocator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.allocator = a
+	if e.sentiment != nil {
+		a.SetSentiment(e.sentiment)
+	}
+	if e.vwap != nil {
+		a.SetVWAP(e.vwap)
+	}
+	if e.kelly != nil {
+		a.SetKelly(e.kelly)
+	}
+}
+
+// SetLotSizes sets the trading unit of each symbol, used to convert KRW
+// notionals into quantities. Symbols without one trade in single shares.
+func (e *Engine) SetLotSizes(sizes map[string]int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lotSizes = sizes
+}
+
+// SetInstruments sets the instrument type of each symbol, used to apply the
+// ETF and ETN rules. Symbols without one are treated as stocks.
+func (e *Engine) SetInstruments(types map[string]models.InstrumentType) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.instruments = types
+}
+
+// SetSectors sets the sector of each symbol, used to apply the sector limits
+// of exposure. Symbols without one are not limited.
+func (e *Engine) SetSectors(sectors map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sectors = sectors
+}
+
+func (e *Engine) exchangeTraded(symbol string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	t := e.instruments[symbol]
+	return t == models.InstrumentETF || t == models.InstrumentETN
+}
+
+// PauseStrategy stops a strategy from opening positions: its buy signals are
+// rejected while sells still go through, so it can close what it holds. Sleeves
+// are paused by name; the configured strategy by its strategy name.
+func (e *Engine) PauseStrategy(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.paused == nil {
+		e.paused = map[string]bool{}
+	}
+	e.paused[name] = true
+}
+
+// ResumeStrategy lets a paused strategy open positions again.
+func (e *Engine) ResumeStrategy(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.paused, name)
+}
+
+// pausedCheck rejects buy signals of paused strategies.
+func (e *Engine) pausedCheck(se events.SignalEvent) (events.RiskCheck, bool) {
+	if se.Source != "strategy" || se.Signal.Type != models.BuySignal {
+		return events.RiskCheck{}, false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	name := se.Signal.Strategy
+	if name == "" {
+		name = e.cfg.Strategy
+	}
+	if !e.paused[name] {
+		return events.RiskCheck{}, false
+	}
+	return events.RiskCheck{Name: "strategy_paused", Detail: fmt.Sprintf("strategy %s is paused", name)}, true
+}
+
+// SetEarnings rejects buys shortly before the earnings dates of cal, as
+// configured in earnings.
+func (e *Engine) SetEarnings(cal EarningsCalendar) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.earnings = cal
+}
+
+// earningsCheck checks a buy against the earnings blackout of its strategy;
+// signals from other sources use the default blackout.
+func (e *Engine) earningsCheck(se events.SignalEvent, signal *models.Signal) (events.RiskCheck, bool) {
+	if signal.Type != models.BuySignal {
+		return events.RiskCheck{}, false
+	}
+	e.mu.RLock()
+	cal, cfg := e.earnings, e.cfg.Earnings
+	name := signal.Strategy
+	if name == "" {
+		name = e.cfg.Strategy
+	}
+	e.mu.RUnlock()
+	if cal == nil {
+		return events.RiskCheck{}, false
+	}
+	days := cfg.BlackoutDays
+	if override, ok := cfg.Strategies[name]; ok && se.Source == "strategy" {
+		days = override
+	}
+	if days <= 0 {
+		return events.RiskCheck{}, false
+	}
+	date, soon := cal.Upcoming(signal.Pair, e.clock.Now(), days)
+	check := events.RiskCheck{Name: "earnings_blackout", Passed: !soon, Detail: fmt.Sprintf("no earnings within %d days", days)}
+	if soon {
+		check.Detail = fmt.Sprintf("earnings on %s, within %d days", date.Format("2006-01-02"), days)
+	}
+	return check, true
+}
+
+// SetSentiment feeds the news sentiment of source to strategies that use it.
+func (e *Engine) SetSentiment(source strategy.Sentiment) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sentiment = source
+	if e.allocator != nil {
+		e.allocator.SetSentiment(source)
+	}
+}
+
+// SetKelly sizes the buys of the strategies k is configured for by
+// fractional Kelly instead of the position settings.
+func (e *Engine) SetKelly(k *sizing.Kelly) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kelly = k
+	if e.allocator != nil {
+		e.allocator.SetKelly(k)
+	}
+}
+
+// SetLiquidity limits the orders of a symbol in a day to a fraction of its
+// average daily volume, as configured in liquidity.
+func (e *Engine) SetLiquidity(l *sizing.Liquidity) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.liquidity = l
+}
+
+// SetCorrelations checks the concentration of buys in correlated names, as
+// configured in correlation, with the correlations of m.
+func (e *Engine) SetCorrelations(m *correlation.Matrix) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.correlations = m
+}
+
+// SetVWAP feeds the session VWAP of source to strategies that use it.
+func (e *Engine) SetVWAP(source strategy.VWAP) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.vwap = source
+	if e.allocator != nil {
+		e.allocator.SetVWAP(source)
+	}
+}
+
+// SetRules passes the signals of the strategies through r before they are
+// sized and checked.
+func (e *Engine) SetRules(r *rules.Rules) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = r
+}
+
+// SetMarketData takes quotes from data instead of the exchange, which is then
+// only used to execute orders.
+func (e *Engine) SetMarketData(data MarketData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.data = data
+	e.separateData = true
+}
+
+// getMarketData fetches a quote from the market data source. Quotes of ETFs
+// and ETNs carry their NAV when it is enabled and published.
+func (e *Engine) getMarketData(symbol string) (*models.MarketData, error) {
+	e.mu.RLock()
+	data, separate, withNAV := e.data, e.separateData, e.cfg.ETF.NAV
+	e.mu.RUnlock()
+	start := e.clock.Now()
+	md, err := data.GetMarketData(symbol)
+	if !separate {
+		e.recordCall(start, err)
+	}
+	if err == nil && md.Askp1 == "" && e.needsAskingPrice() {
+		md = e.addAskingPrice(symbol, md)
+	}
+	if err == nil && md.QuoteTime.IsZero() {
+		stamped := *md
+		stamped.QuoteTime = e.clock.Now()
+		md = &stamped
+	}
+	if err != nil || !withNAV || md.Nav != "" || !e.exchangeTraded(symbol) {
+		return md, err
+	}
+	return e.addNAV(symbol, md), nil
+}
+
+// addNAV returns a copy of md with the NAV of symbol from the market data
+// source or, failing that, the exchange. Quotes are traded on without one
+// when neither publishes it.
+func (e *Engine) addNAV(symbol string, md *models.MarketData) *models.MarketData {
+	e.mu.RLock()
+	source, ok := e.data.(NAVSource)
+	if !ok {
+		source, ok = e.exch.(NAVSource)
+	}
+	e.mu.RUnlock()
+	if !ok {
+		return md
+	}
+	nav, err := source.GetNAV(symbol)
+	if err != nil {
+		log.WithError(err).WithField("pair", symbol).Warn("Failed to get NAV")
+		return md
+	}
+	withNAV := *md
+	withNAV.Nav = strconv.FormatFloat(nav, 'f', -1, 64)
+	return &withNAV
+}
+
+// needsAskingPrice tells whether the quote age or spread is checked, or the
+// spread drives adaptive polling.
+func (e *Engine) needsAskingPrice() bool {
+	risk, polling := e.cfg.Risk, e.cfg.AdaptivePolling
+	return risk.MaxQuoteAge != "" || risk.MaxSpreadBps > 0 || (polling.Enabled && polling.TargetSpread > 0)
+}
+
+// addAskingPrice returns a copy of md with the best ask and bid of symbol from
+// the market data source or, failing that, the exchange, and the time of the
+// order book when md has none. Quotes are traded on without them when neither
+// publishes them.
+func (e *Engine) addAskingPrice(symbol string, md *models.MarketData) *models.MarketData {
+	e.mu.RLock()
+	source, ok := e.data.(AskingPriceSource)
+	if !ok {
+		source, ok = e.exch.(AskingPriceSource)
+	}
+	e.mu.RUnlock()
+	if !ok {
+		return md
+	}
+	book, err := source.GetAskingPrice(symbol)
+	if err != nil {
+		log.WithError(err).WithField("pair", symbol).Warn("Failed to get asking price")
+		return md
+	}
+	withBook := *md
+	withBook.Askp1 = strconv.FormatFloat(book.Ask, 'f', -1, 64)
+	withBook.Bidp1 = strconv.FormatFloat(book.Bid, 'f', -1, 64)
+	if withBook.QuoteTime.IsZero() {
+		withBook.QuoteTime = book.Time
+	}
+	return &withBook
+}
+
+// SetStore replaces the order store, e.g. after a database reconnect.
+func (e *Engine) SetStore(store OrderStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.store = store
+}
+
+// RunCycle fetches the latest market data for symbol and publishes it. When it
+// returns, the resulting signal and any order have been fully processed. With a
+// timeframe configured, a candle of symbol whose period has ended is completed
+// and analyzed first. A CycleEvent with the time spent in each phase is
+// published last.
+func (e *Engine) RunCycle(symbol string) error {
+	start := e.clock.Now()
+	e.timingMu.Lock()
+	e.cycles[symbol] = make(map[string]time.Duration)
+	e.timingMu.Unlock()
+	defer e.finishCycle(symbol, start)
+
+	if e.candles != nil {
+		e.publishCandles(e.candles.Flush(symbol, e.clock.Now()))
+	}
+
+	marketData, err := e.getMarketData(symbol)
+	e.timed(symbol, events.PhaseData, e.clock.Now().Sub(start))
+	if err != nil {
+		err = fmt.Errorf("failed to get market data: %w", err)
+		e.publishError("market_data", symbol, err)
+		e.publishDecision(events.DecisionEvent{Symbol: symbol, Source: "strategy", Action: events.ActionError, Err: err})
+		return err
+	}
+
+	e.Bus.Publish(events.MarketDataEvent{Symbol: symbol, Data: marketData, Time: e.clock.Now()})
+	return nil
+}
+
+func (e *Engine) analyze(ev events.Event) {
+	md := ev.(events.MarketDataEvent)
+	e.runStrategy(md.Symbol, md.Data)
+}
+
+// aggregate adds polled prices to the symbol's candle.
+func (e *Engine) aggregate(ev events.Event) {
+	md := ev.(events.MarketDataEvent)
+	price, err := strconv.ParseFloat(md.Data.StckPrpr, 64)
+	if err != nil {
+		e.publishError("candle", md.Symbol, fmt.Errorf("invalid price %q", md.Data.StckPrpr))
+		return
+	}
+	e.seen(md.Symbol, md.Time)
+	e.publishCandles(e.candles.AddTick(md.Symbol, md.Time, price, 0))
+}
+
+func (e *Engine) publishCandles(candles []candle.Candle) {
+	for _, c := range candles {
+		e.Bus.Publish(events.CandleEvent{Candle: c, Time: e.clock.Now()})
+	}
+}
+
+// Resume feeds the candles of a symbol missed while the bot was down, oldest
+// first, through the candle aggregator, e.g. 1m candles for a 5m timeframe.
+// The candles they complete are stored and analyzed by the strategies to catch
+// up their state, but the signals are dropped: no orders are placed on the
+// past. The period in progress is continued by the next polls.
+func (e *Engine) Resume(candles []candle.Candle) {
+	if e.candles == nil {
+		return
+	}
+	var done []candle.Candle
+	for _, c := range candles {
+		e.seen(c.Symbol, c.Start)
+		done = append(done, e.candles.AddCandle(c)...)
+	}
+	if len(done) == 0 {
+		return
+	}
+
+	e.mu.RLock()
+	store, _ := e.store.(CandleStore)
+	strategies, allocator, sentiment := e.strategies, e.allocator, e.sentiment
+	e.mu.RUnlock()
+
+	for _, c := range done {
+		data := &models.MarketData{StckPrpr: strconv.FormatFloat(c.Close, 'f', -1, 64)}
+		if allocator != nil {
+			allocator.Analyze(c.Symbol, data, c.End())
+		} else if strat, ok := strategies[c.Symbol]; ok {
+			strategy.FeedSentiment(strat, sentiment, c.Symbol)
+			strat.Analyze(data)
+		}
+	}
+	if store != nil {
+		if err := store.SaveCandles(done); err != nil {
+			e.publishError("store", done[0].Symbol, fmt.Errorf("failed to save candles: %v", err))
+		}
+	}
+	log.WithFields(logrus.Fields{"pair": done[0].Symbol, "candles": len(done), "from": done[0].Start, "to": done[len(done)-1].End()}).Info("Strategies caught up on missed candles")
+}
+
+// seen records the time of input aggregated for symbol, if it is the first.
+func (e *Engine) seen(symbol string, t time.Time) {
+	e.seenMu.Lock()
+	defer e.seenMu.Unlock()
+	if _, ok := e.firstSeen[symbol]; !ok {
+		e.firstSeen[symbol] = t
+	}
+}
+
+// partial tells whether c is missing the beginning of its period: its first
+// input came more than a polling interval after the period began.
+func (e *Engine) partial(c candle.Candle) bool {
+	e.seenMu.Lock()
+	first, ok := e.firstSeen[c.Symbol]
+	e.seenMu.Unlock()
+	return !ok || first.After(c.Start.Add(e.cfg.ParsedInterval))
+}
+
+// analyzeCandle runs the strategy on a completed candle, presenting its close as
+// the market price.
+func (e *Engine) analyzeCandle(ev events.Event) {
+	c := ev.(events.CandleEvent).Candle
+	if e.cfg.BarClose && e.partial(c) {
+		log.WithFields(logrus.Fields{"pair": c.Symbol, "start": c.Start}).Info("Skipping partial candle")
+		return
+	}
+	e.runStrategy(c.Symbol, &models.MarketData{StckPrpr: strconv.FormatFloat(c.Close, 'f', -1, 64), QuoteTime: c.End()})
+}
+
+func (e *Engine) runStrategy(symbol string, data *models.MarketData) {
+	e.mu.RLock()
+	strat, ok := e.strategies[symbol]
+	allocator, signalRules := e.allocator, e.rules
+	e.mu.RUnlock()
+
+	if allocator != nil {
+		start := e.clock.Now()
+		analyses := allocator.Analyze(symbol, data, start)
+		e.timed(symbol, events.PhaseAnalysis, e.clock.Now().Sub(start))
+		for _, a := range analyses {
+			log.WithFields(logrus.Fields{"pair": symbol, "strategy": a.Signal.Strategy, "signal": a.Signal.Type}).Info("Strategy analysis result")
+			signal := a.Signal
+			if signalRules != nil {
+				signal = signalRules.Apply(symbol, signal)
+			}
+			e.Bus.Publish(events.SignalEvent{
+				Symbol:     symbol,
+				Signal:     signal,
+				Source:     "strategy",
+				MarketData: data,
+				Indicators: a.Indicators,
+				Time:       e.clock.Now(),
+			})
+		}
+		return
+	}
+
+	if !ok {
+		err := fmt.Errorf("no strategy for symbol %s", symbol)
+		e.publishError("strategy", symbol, err)
+		e.publishDecision(events.DecisionEvent{Symbol: symbol, Source: "strategy", MarketData: data, Action: events.ActionError, Err: err})
+		return
+	}
+
+	start := e.clock.Now()
+	e.mu.RLock()
+	sentiment, vwap := e.sentiment, e.vwap
+	e.mu.RUnlock()
+	strategy.FeedSentiment(strat, sentiment, symbol)
+	strategy.FeedVWAP(strat, vwap, symbol)
+	signal := strat.Analyze(data)
+	signal.Pair = symbol
+	log.WithFields(logrus.Fields{"pair": symbol, "signal": signal.Type}).Info("Strategy analysis result")
+	if signalRules != nil {
+		ruled := signalRules.Apply(symbol, signal)
+		if ruled.Type != signal.Type {
+			log.WithFields(logrus.Fields{"pair": symbol, "signal": signal.Type, "acted_on": ruled.Type}).Info("Signal changed by rules")
+		}
+		signal = ruled
+	}
+
+	var indicators map[string]float64
+	if explainer, ok := strat.(strategy.Explainer); ok {
+		indicators = explainer.Indicators()
+	}
+	e.timed(symbol, events.PhaseAnalysis, e.clock.Now().Sub(start))
+
+	e.Bus.Publish(events.SignalEvent{
+		Symbol:     symbol,
+		Signal:     signal,
+		Source:     "strategy",
+		MarketData: data,
+		Indicators: indicators,
+		Time:       e.clock.Now(),
+	})
+}
+
+// Submit feeds an externally generated signal into the pipeline, so it passes the
+// same risk checks and order handling as strategy signals. When it returns, the
+// signal and any order have been fully processed.
+func (e *Engine) Submit(source string, signal *models.Signal) {
+	log.WithFields(logrus.Fields{"pair": signal.Pair, "signal": signal.Type, "source": source}).Info("External signal received")
+	e.Bus.Publish(events.SignalEvent{Symbol: signal.Pair, Signal: signal, Source: source, Time: e.clock.Now()})
+}
+
+func (e *Engine) execute(ev events.Event) {
+	se := ev.(events.SignalEvent)
+	// The risk phase runs until the order is placed or the signal dropped.
+	start, checked := e.clock.Now(), time.Time{}
+	defer func() {
+		if checked.IsZero() {
+			checked = e.clock.Now()
+		}
+		e.timed(se.Symbol, events.PhaseRisk, checked.Sub(start))
+	}()
+	signal := se.Signal
+	decision := events.DecisionEvent{
+		Symbol:     se.Symbol,
+		Source:     se.Source,
+		MarketData: se.MarketData,
+		Indicators: se.Indicators,
+		Signal:     signal,
+	}
+
+	if signal.Type == models.HoldSignal {
+		log.WithField("pair", se.Symbol).Info("No trading action needed")
+		decision.Action = events.ActionHold
+		e.publishDecision(decision)
+		return
+	}
+
+	if check, paused := e.pausedCheck(se); paused {
+		log.WithFields(logrus.Fields{"pair": se.Symbol, "detail": check.Detail}).Warn("Signal of paused strategy, skipping")
+		decision.Checks = []events.RiskCheck{check}
+		decision.Action = events.ActionRejected
+		e.publishDecision(decision)
+		return
+	}
+
+	if se.MarketData == nil {
+		fetch := e.clock.Now()
+		data, err := e.getMarketData(se.Symbol)
+		e.timed(se.Symbol, events.PhaseData, e.clock.Now().Sub(fetch))
+		// The fetch counts as data, not risk.
+		start = start.Add(e.clock.Now().Sub(fetch))
+		if err != nil {
+			err = fmt.Errorf("failed to get market data: %w", err)
+			e.publishError("market_data", se.Symbol, err)
+			decision.Action = events.ActionFailed
+			decision.Err = err
+			e.publishDecision(decision)
+			return
+		}
+		se.MarketData = data
+		decision.MarketData = data
+	}
+
+	sized, checks, err := e.size(se)
+	decision.Checks = checks
+	if err != nil {
+		e.publishError("sizing", se.Symbol, err)
+		decision.Action = events.ActionFailed
+		decision.Err = err
+		e.publishDecision(decision)
+		return
+	}
+	if sized.Type == models.HoldSignal {
+		log.WithFields(logrus.Fields{"pair": se.Symbol, "signal": signal.Type, "detail": checks[len(checks)-1].Detail}).Info("Signal sized to no order")
+		decision.Signal = sized
+		decision.Action = events.ActionHold
+		e.publishDecision(decision)
+		return
+	}
+	signal = sized
+	decision.Signal = signal
+
+	decision.Checks = append(decision.Checks, e.riskChecks(signal, se.MarketData)...)
+	if check, ok := e.earningsCheck(se, signal); ok {
+		decision.Checks = append(decision.Checks, check)
+	}
+	if e.exchangeTraded(signal.Pair) {
+		decision.Checks = append(decision.Checks, e.etfChecks(signal, se.MarketData)...)
+	}
+	if check, ok := e.sectorExposure(se, signal); ok {
+		decision.Checks = append(decision.Checks, check)
+	}
+	if check, ok := e.correlationCheck(se, signal); ok {
+		decision.Checks = append(decision.Checks, check)
+	}
+	for _, check := range decision.Checks {
+		if !check.Passed {
+			log.WithFields(logrus.Fields{
+				"pair":   se.Symbol,
+				"check":  check.Name,
+				"detail": check.Detail,
+			}).Warn("Signal failed risk check, skipping")
+			switch check.Name {
+			case "trading_halt", "price_limit", "quote_age", "spread":
+				e.Bus.Publish(events.HaltEvent{Symbol: se.Symbol, Signal: signal, Check: check.Name, Detail: check.Detail, Time: e.clock.Now()})
+			}
+			decision.Action = events.ActionRejected
+			e.publishDecision(decision)
+			return
+		}
+	}
+
+	if e.cfg.Exchange.Observe {
+		log.WithFields(logrus.Fields{
+			"pair":   se.Symbol,
+			"type":   signal.Type,
+			"amount": signal.Amount,
+		}).Info("Signal observed, no order sent")
+		decision.Action = events.ActionObserved
+		e.publishDecision(decision)
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"pair":   se.Symbol,
+		"type":   signal.Type,
+		"amount": signal.Amount,
+	}).Info("Signal generated")
+
+	checked = e.clock.Now()
+	if signal.Type == models.BuySignal {
+		e.runSweep(se.Symbol, func(s *sweep.Sweeper) (*models.Order, error) { return s.Free(signal) })
+	}
+
+	placed := e.clock.Now()
+	order, err := e.exch.PlaceOrder(signal)
+	e.recordCall(placed, err)
+	e.timed(se.Symbol, events.PhaseOrder, e.clock.Now().Sub(checked))
+	if err != nil {
+		err = fmt.Errorf("failed to place order: %w", err)
+		e.publishError("execution", se.Symbol, err)
+		decision.Action = events.ActionFailed
+		decision.Err = err
+		e.publishDecision(decision)
+		return
+	}
+	log.WithField("order", order).Info("Order placed")
+	order.SignalPrice, _ = e.price(se)
+	if signal.Strategy != "" {
+		order.Strategy = signal.Strategy
+		e.recordAllocation(order, se)
+	}
+
+	e.Bus.Publish(events.OrderEvent{Order: order, Signal: signal, Time: e.clock.Now()})
+	decision.Action = events.ActionOrdered
+	decision.Order = order
+	e.publishDecision(decision)
+}
+
+// recordAllocation books an order of an allocation sleeve.
+func (e *Engine) recordAllocation(order *models.Order, se events.SignalEvent) {
+	e.mu.RLock()
+	allocator := e.allocator
+	e.mu.RUnlock()
+	if allocator == nil {
+		return
+	}
+	var price float64
+	if se.MarketData != nil {
+		price, _ = strconv.ParseFloat(se.MarketData.StckPrpr, 64)
+	}
+	allocator.Record(order, se.Signal, price)
+}
+
+// SweepStrategy is the strategy recorded with cash sweep orders.
+const SweepStrategy = "cash_sweep"
+
+// ManualStrategy is the strategy recorded with manual trades and overrides.
+const ManualStrategy = "manual"
+
+// Record books a trade made outside the bot, e.g. entered by hand: it is
+// stored and audited like the bot's own orders, with source as its source.
+func (e *Engine) Record(source string, order *models.Order) {
+	if order.Timestamp.IsZero() {
+		order.Timestamp = e.clock.Now()
+	}
+	log.WithFields(logrus.Fields{"pair": order.Pair, "side": order.Side, "amount": order.Amount, "price": order.Price, "source": source}).Info("Outside trade recorded")
+	signal := &models.Signal{Type: models.SignalType(order.Side), Pair: order.Pair, Amount: order.Amount, Strategy: order.Strategy}
+	e.Bus.Publish(events.OrderEvent{Order: order, Signal: signal, Time: e.clock.Now()})
+	e.publishDecision(events.DecisionEvent{Symbol: order.Pair, Source: source, Signal: signal, Action: events.ActionRecorded, Order: order})
+}
+
+// ClosePosition sells the whole position in symbol at market as an override
+// by source, bypassing the strategies and the risk checks. The order is
+// published and audited like any other. It fails in observer mode.
+func (e *Engine) ClosePosition(source, symbol string) error {
+	if e.cfg.Exchange.Observe {
+		return fmt.Errorf("orders are disabled in observer mode")
+	}
+	positions, ok := e.exch.(PositionSource)
+	if !ok {
+		return fmt.Errorf("exchange does not report positions")
+	}
+	held, err := positions.GetPositions()
+	if err != nil {
+		return fmt.Errorf("failed to get positions: %w", err)
+	}
+	quantity := 0.0
+	for _, p := range held {
+		if p.StockCode == symbol {
+			quantity += p.Quantity
+		}
+	}
+	if quantity <= 0 {
+		return fmt.Errorf("no position in %s", symbol)
+	}
+
+	signal := &models.Signal{Type: models.SellSignal, Pair: symbol, Amount: quantity, Strategy: ManualStrategy}
+	decision := events.DecisionEvent{
+		Symbol: symbol,
+		Source: source,
+		Signal: signal,
+		Checks: []events.RiskCheck{{Name: "override", Passed: true, Detail: "position closed by hand, risk checks bypassed"}},
+	}
+	start := e.clock.Now()
+	order, err := e.exch.PlaceOrder(signal)
+	e.recordCall(start, err)
+	if err != nil {
+		err = fmt.Errorf("failed to place order: %w", err)
+		e.publishError("execution", symbol, err)
+		decision.Action = events.ActionFailed
+		decision.Err = err
+		e.publishDecision(decision)
+		return err
+	}
+	log.WithFields(logrus.Fields{"pair": symbol, "quantity": quantity, "source": source}).Warn("Position closed by override")
+	order.Strategy = ManualStrategy
+
+	e.Bus.Publish(events.OrderEvent{Order: order, Signal: signal, Time: e.clock.Now()})
+	decision.Action = events.ActionOrdered
+	decision.Order = order
+	e.publishDecision(decision)
+	return nil
+}
+
+// Sweep parks idle cash in the sweep ETF when the cash sweep is enabled and no
+// other position is open. It does nothing while the exchange circuit is open
+// or in observer mode.
+func (e *Engine) Sweep() {
+	e.runSweep("", (*sweep.Sweeper).Park)
+}
+
+// runSweep runs a sweep step and publishes the resulting order like any other,
+// with "sweep" as its source. symbol is the traded symbol it was run for, if any.
+func (e *Engine) runSweep(symbol string, step func(*sweep.Sweeper) (*models.Order, error)) {
+	e.mu.RLock()
+	s := e.sweeper
+	e.mu.RUnlock()
+	if s == nil || e.cfg.Exchange.Observe || e.CircuitState() == circuit.Open {
+		return
+	}
+
+	order, err := step(s)
+	if err != nil {
+		if symbol == "" {
+			symbol = s.Symbol()
+		}
+		e.publishError("sweep", symbol, fmt.Errorf("cash sweep failed: %v", err))
+		return
+	}
+	if order == nil {
+		return
+	}
+	order.Strategy = SweepStrategy
+	signal := &models.Signal{Type: models.SignalType(order.Side), Pair: order.Pair, Amount: order.Amount, Strategy: SweepStrategy}
+	e.Bus.Publish(events.OrderEvent{Order: order, Signal: signal, Time: e.clock.Now()})
+	e.publishDecision(events.DecisionEvent{Symbol: order.Pair, Source: "sweep", Signal: signal, Action: events.ActionOrdered, Order: order})
+}
+
+// size returns the order to place for a signal, as a copy of it that holds
+// when there is nothing to do, and checks describing how it was sized. A KRW
+// notional is converted to whole lots, strategy signals are sized against the
+// held position when the exchange reports positions, orders are sliced to the
+// liquidity limit when one is set, and the result is rounded to what the
+// exchange accepts.
+func (e *Engine) size(se events.SignalEvent) (*models.Signal, []events.RiskCheck, error) {
+	sized := *se.Signal
+	var checks []events.RiskCheck
+
+	if sized.Amount == 0 && sized.Notional > 0 {
+		price, err := e.price(se)
+		if err != nil {
+			return nil, nil, err
+		}
+		sized.Amount = universe.Shares(sized.Notional, price, e.lotSize(sized.Pair))
+		checks = append(checks, events.RiskCheck{
+			Name:   "notional",
+			Passed: true,
+			Detail: fmt.Sprintf("₩%.0f at %g is %g shares", sized.Notional, price, sized.Amount),
+		})
+		if sized.Amount == 0 {
+			sized.Type = models.HoldSignal
+			return &sized, checks, nil
+		}
+	}
+
+	if source, ok := e.exch.(PositionSource); ok && se.Source == "strategy" && sized.Strategy == "" {
+		check, err := e.sizeToPosition(source, se, &sized)
+		if err != nil {
+			return nil, checks, err
+		}
+		checks = append(checks, check)
+		if sized.Type == models.HoldSignal {
+			return &sized, checks, nil
+		}
+	}
+	if e.cfg.Margin.Enabled && sized.Type != models.HoldSignal {
+		check, err := e.margin(se, &sized)
+		if err != nil {
+			return nil, checks, err
+		}
+		if check.Name != "" {
+			checks = append(checks, check)
+		}
+	}
+	if e.cfg.Market.Enabled {
+		price, err := e.price(se)
+		if err != nil {
+			return nil, checks, err
+		}
+		checks = append(checks, e.session(&sized, price))
+	}
+	e.mu.RLock()
+	liquidity := e.liquidity
+	e.mu.RUnlock()
+	if liquidity != nil && e.cfg.Liquidity.MaxADVFraction > 0 && sized.Type != models.HoldSignal {
+		check, err := e.capToLiquidity(liquidity, &sized)
+		if err != nil {
+			return nil, checks, err
+		}
+		checks = append(checks, check)
+		if sized.Type == models.HoldSignal {
+			return &sized, checks, nil
+		}
+	}
+	if check := e.round(&sized); check.Detail != "" {
+		checks = append(checks, check)
+	}
+	return &sized, checks, nil
+}
+
+// capToLiquidity slices sized down to what is left of the daily limit of its
+// symbol in whole lots, or fails the check of a buy beyond it with
+// liquidity.reject. An order left without a whole lot becomes a hold.
+func (e *Engine) capToLiquidity(l *sizing.Liquidity, sized *models.Signal) (events.RiskCheck, error) {
+	limit, ok, err := l.Limit(sized.Pair, e.clock.Now())
+	if err != nil {
+		return events.RiskCheck{}, err
+	}
+	check := events.RiskCheck{Name: "liquidity", Passed: true, Detail: "no daily volume, not limited"}
+	if !ok {
+		return check, nil
+	}
+	check.Detail = fmt.Sprintf("amount %g, %s", sized.Amount, limit)
+	room := limit.Room()
+	if sized.Amount <= room {
+		return check, nil
+	}
+	if e.cfg.Liquidity.Reject && sized.Type == models.BuySignal {
+		check.Passed = false
+		return check, nil
+	}
+	amount := universe.RoundLots(room, e.lotSize(sized.Pair))
+	check.Detail = fmt.Sprintf("amount %g sliced to %g, %s", sized.Amount, amount, limit)
+	sized.Amount = amount
+	if amount <= 0 {
+		sized.Type = models.HoldSignal
+		sized.Amount = 0
+	}
+	return check, nil
+}
+
+// margin makes sized a credit order: buys borrow what the margin requirement
+// does not cover, within the leverage limit, and sells of positions carrying
+// a loan repay it. Sells of positions without one stay cash orders, as do
+// sells when the exchange does not report positions.
+func (e *Engine) margin(se events.SignalEvent, sized *models.Signal) (events.RiskCheck, error) {
+	m := e.cfg.Margin
+	credit := m.CreditType
+	if credit == "" {
+		credit = config.CreditSelf
+	}
+	var positions []models.Position
+	source, hasPositions := e.exch.(PositionSource)
+	if hasPositions {
+		var err error
+		if positions, err = source.GetPositions(); err != nil {
+			return events.RiskCheck{}, fmt.Errorf("failed to get positions: %w", err)
+		}
+	}
+
+	if sized.Type == models.SellSignal {
+		for _, p := range positions {
+			if p.StockCode == sized.Pair && p.Loan > 0 {
+				sized.Credit, sized.LoanDate = credit, p.LoanDate
+				return events.RiskCheck{Name: "margin", Passed: true, Detail: fmt.Sprintf("repays loan of ₩%.0f", p.Loan)}, nil
+			}
+		}
+		return events.RiskCheck{}, nil
+	}
+
+	sized.Credit = credit
+	if m.MaxLeverage <= 0 {
+		return events.RiskCheck{}, nil
+	}
+	check := events.RiskCheck{Name: "max_leverage"}
+	balances, hasBalance := e.exch.(BalanceSource)
+	if !hasPositions || !hasBalance {
+		check.Detail = "exchange does not report positions and balance"
+		return check, nil
+	}
+	balance, err := balances.GetBalance()
+	if err != nil {
+		return events.RiskCheck{}, fmt.Errorf("failed to get balance: %w", err)
+	}
+	cash, _ := strconv.ParseFloat(balance, 64)
+	price, err := e.price(se)
+	if err != nil {
+		return events.RiskCheck{}, err
+	}
+	holdings, loans := 0.0, 0.0
+	for _, p := range positions {
+		holdings += p.Quantity * p.CurrentPrice
+		loans += p.Loan
+	}
+	equity := cash + holdings - loans
+	value := sized.Amount * price
+	leverage := math.Inf(1)
+	if equity > 0 {
+		leverage = (holdings + value) / equity
+	}
+	check.Passed = leverage <= m.MaxLeverage
+	check.Detail = fmt.Sprintf("leverage %.2f after the buy, limit %.2f", leverage, m.MaxLeverage)
+	return check, nil
+}
+
+// session tags sized with the KRX session in progress, failing the check when
+// it is not one the bot trades in. The off-hours closing-price sessions trade
+// at the close, so a limit price is dropped; the after-hours single-price
+// session only takes limit orders, so market orders are priced at price.
+func (e *Engine) session(sized *models.Signal, price float64) events.RiskCheck {
+	check := events.RiskCheck{Name: "session"}
+	cal, err := market.NewCalendar(e.cfg.Market)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	now := e.clock.Now()
+	name, ok := cal.SessionAt(now)
+	if !ok || !cal.IsTrading(now, e.cfg.Market.ExtendedSessions) {
+		check.Detail = "no session open for trading"
+		if ok {
+			check.Detail = name + " session not enabled"
+		}
+		return check
+	}
+	check.Passed = true
+	check.Detail = name
+	switch name {
+	case config.SessionPreMarket, config.SessionAfterHoursClose:
+		sized.LimitPrice = 0
+	case config.SessionAfterHoursSingle:
+		if sized.LimitPrice == 0 {
+			sized.LimitPrice = price
+		}
+	}
+	if name != config.SessionRegular {
+		sized.Session = name
+	}
+	return check
+}
+
+// sizeToPosition sizes sized against the position held in its symbol.
+func (e *Engine) sizeToPosition(source PositionSource, se events.SignalEvent, sized *models.Signal) (events.RiskCheck, error) {
+	positions, err := source.GetPositions()
+	if err != nil {
+		return events.RiskCheck{}, fmt.Errorf("failed to get positions: %w", err)
+	}
+	// A symbol bought both in cash and on credit is reported in a row each.
+	held := 0.0
+	for _, p := range positions {
+		if p.StockCode == sized.Pair {
+			held += p.Quantity
+		}
+	}
+
+	pc := e.cfg.Position
+	check := events.RiskCheck{Name: "position", Passed: true}
+	switch sized.Type {
+	case models.BuySignal:
+		target, step := pc.TargetQuantity, pc.ScaleIn
+		if pc.TargetNotional > 0 || pc.ScaleInNotional > 0 {
+			price, err := e.price(se)
+			if err != nil {
+				return events.RiskCheck{}, err
+			}
+			if pc.TargetNotional > 0 {
+				target = universe.Shares(pc.TargetNotional, price, e.lotSize(sized.Pair))
+			}
+			if pc.ScaleInNotional > 0 {
+				step = universe.Shares(pc.ScaleInNotional, price, e.lotSize(sized.Pair))
+			}
+		}
+		if target == 0 && pc.TargetNotional == 0 {
+			target = sized.Amount
+		}
+		e.mu.RLock()
+		kelly := e.kelly
+		e.mu.RUnlock()
+		var kellyDetail string
+		if kelly != nil {
+			if fraction, edge, ok := kelly.Fraction(sized.Strategy); ok {
+				equity, err := e.equity(positions)
+				if err != nil {
+					return events.RiskCheck{}, err
+				}
+				price, err := e.price(se)
+				if err != nil {
+					return events.RiskCheck{}, err
+				}
+				target = universe.Shares(equity*fraction, price, e.lotSize(sized.Pair))
+				kellyDetail = fmt.Sprintf(", kelly %.1f%% of ₩%.0f (win rate %.0f%%, payoff %.2f over %d trades)",
+					fraction*100, equity, edge.WinRate*100, payoff(edge), edge.Trades)
+			}
+		}
+		if sized.Fraction > 0 && sized.Fraction < 1 {
+			target = math.Ceil(target * sized.Fraction)
+		}
+		sized.Amount = target - held
+		if step > 0 && step < sized.Amount {
+			sized.Amount = step
+		}
+		check.Detail = fmt.Sprintf("held %g, target %g", held, target) + kellyDetail
+	case models.SellSignal:
+		sized.Amount = held
+		if pc.ScaleOut > 0 && pc.ScaleOut < held {
+			sized.Amount = pc.ScaleOut
+		}
+		check.Detail = fmt.Sprintf("held %g", held)
+	}
+	if sized.Amount <= 0 {
+		sized.Type = models.HoldSignal
+		sized.Amount = 0
+	}
+	return check, nil
+}
+
+// equity returns the cash balance plus the value of positions, less their
+// loans.
+func (e *Engine) equity(positions []models.Position) (float64, error) {
+	balances, ok := e.exch.(BalanceSource)
+	if !ok {
+		return 0, fmt.Errorf("exchange does not report the balance")
+	}
+	balance, err := balances.GetBalance()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	equity, _ := strconv.ParseFloat(balance, 64)
+	for _, p := range positions {
+		equity += p.Quantity*p.CurrentPrice - p.Loan
+	}
+	return equity, nil
+}
+
+// payoff returns the average win over the average loss of edge.
+func payoff(edge sizing.Edge) float64 {
+	if edge.AvgLoss <= 0 {
+		return math.Inf(1)
+	}
+	return edge.AvgWin / edge.AvgLoss
+}
+
+// round makes the order of sized exchange-valid: the quantity is rounded down
+// to whole lots and a limit price to the tick size, down for buys and up for
+// sells so the order never trades at a worse price than asked. An order left
+// without a whole lot becomes a hold. The check has no detail when nothing
+// was rounded.
+func (e *Engine) round(sized *models.Signal) events.RiskCheck {
+	lot := e.lotSize(sized.Pair)
+	check := events.RiskCheck{Name: "lot", Passed: true}
+	var details []string
+	if amount := universe.RoundLots(sized.Amount, lot); amount != sized.Amount {
+		details = append(details, fmt.Sprintf("quantity %g rounded to %g", sized.Amount, amount))
+		sized.Amount = amount
+	}
+	if sized.Amount <= 0 {
+		check.Detail = fmt.Sprintf("less than a lot of %d", lot)
+		sized.Type = models.HoldSignal
+		sized.Amount = 0
+		return check
+	}
+	if sized.LimitPrice > 0 {
+		price := universe.RoundToTick(sized.LimitPrice)
+		if sized.Type == models.SellSignal {
+			price = universe.RoundUpToTick(sized.LimitPrice)
+		}
+		if price != sized.LimitPrice {
+			details = append(details, fmt.Sprintf("limit %g rounded to %g", sized.LimitPrice, price))
+			sized.LimitPrice = price
+		}
+	}
+	check.Detail = strings.Join(details, ", ")
+	return check
+}
+
+// price returns the price a signal was generated at, or for external signals
+// the price when it was received.
+func (e *Engine) price(se events.SignalEvent) (float64, error) {
+	price, err := strconv.ParseFloat(se.MarketData.StckPrpr, 64)
+	if err != nil || price <= 0 {
+		return 0, fmt.Errorf("invalid price %q", se.MarketData.StckPrpr)
+	}
+	return price, nil
+}
+
+func (e *Engine) lotSize(symbol string) int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lotSizes[symbol]
+}
+
+// riskChecks evaluates the pre-trade limits for signal at the quote data.
+// Disabled limits, and those data does not allow to check, are not reported.
+func (e *Engine) riskChecks(signal *models.Signal, data *models.MarketData) []events.RiskCheck {
+	var checks []events.RiskCheck
+	if data.TrhtYn != "" || data.TempStopYn != "" {
+		checks = append(checks, events.RiskCheck{
+			Name:   "trading_halt",
+			Passed: !data.Halted(),
+			Detail: fmt.Sprintf("halted %s, temporarily stopped %s", data.TrhtYn, data.TempStopYn),
+		})
+	}
+	if upper := data.UpperLimit(); upper > 0 && signal.Type == models.BuySignal {
+		price, _ := strconv.ParseFloat(data.StckPrpr, 64)
+		ceiling := upper * (1 - e.cfg.Risk.LimitUpMargin)
+		checks = append(checks, events.RiskCheck{
+			Name:   "price_limit",
+			Passed: price < ceiling,
+			Detail: fmt.Sprintf("price %g, upper limit %g", price, upper),
+		})
+	}
+	if maxAge, _ := time.ParseDuration(e.cfg.Risk.MaxQuoteAge); maxAge > 0 && !data.QuoteTime.IsZero() {
+		age := e.clock.Now().Sub(data.QuoteTime)
+		checks = append(checks, events.RiskCheck{
+			Name:   "quote_age",
+			Passed: age <= maxAge,
+			Detail: fmt.Sprintf("quote from %s, %v old, limit %v", data.QuoteTime.In(market.KST).Format("2006-01-02 15:04:05"), age.Round(time.Second), maxAge),
+		})
+	}
+	if spread, ok := data.Spread(); ok && e.cfg.Risk.MaxSpreadBps > 0 {
+		checks = append(checks, events.RiskCheck{
+			Name:   "spread",
+			Passed: spread*10000 <= e.cfg.Risk.MaxSpreadBps,
+			Detail: fmt.Sprintf("spread %.1f bps (ask %s, bid %s), limit %g", spread*10000, data.Askp1, data.Bidp1, e.cfg.Risk.MaxSpreadBps),
+		})
+	}
+	if e.breaker != nil {
+		checks = append(checks, events.RiskCheck{
+			Name:   "circuit_breaker",
+			Passed: e.breaker.Allow(),
+			Detail: "exchange circuit " + string(e.breaker.State()),
+		})
+	}
+	if limit := e.cfg.Risk.MaxOrderAmount; limit > 0 {
+		checks = append(checks, events.RiskCheck{
+			Name:   "max_order_amount",
+			Passed: signal.Amount <= limit,
+			Detail: fmt.Sprintf("amount %g, limit %g", signal.Amount, limit),
+		})
+	}
+	return checks
+}
+
+// sectorExposure checks that a buy keeps the positions in the sector of its
+// symbol within the sector's limit of exposure, as a fraction of the equity.
+// ok is false when the symbol has no sector or the sector no limit.
+func (e *Engine) sectorExposure(se events.SignalEvent, signal *models.Signal) (check events.RiskCheck, ok bool) {
+	if signal.Type != models.BuySignal {
+		return events.RiskCheck{}, false
+	}
+	e.mu.RLock()
+	sectors := e.sectors
+	e.mu.RUnlock()
+	sector := sectors[signal.Pair]
+	limit := e.cfg.Exposure.SectorLimit(sector)
+	if sector == "" || limit <= 0 {
+		return events.RiskCheck{}, false
+	}
+
+	check = events.RiskCheck{Name: "sector_exposure"}
+	source, ok := e.exch.(PositionSource)
+	if !ok {
+		check.Detail = "exchange does not report positions"
+		return check, true
+	}
+	positions, err := source.GetPositions()
+	if err != nil {
+		check.Detail = fmt.Sprintf("failed to get positions: %v", err)
+		return check, true
+	}
+	equity, err := e.equity(positions)
+	if err != nil {
+		check.Detail = err.Error()
+		return check, true
+	}
+	price, err := e.price(se)
+	if err != nil {
+		check.Detail = err.Error()
+		return check, true
+	}
+	value := universe.SectorValues(positions, sectors)[sector] + signal.Amount*price
+	exposure := math.Inf(1)
+	if equity > 0 {
+		exposure = value / equity
+	}
+	check.Passed = exposure <= limit
+	check.Detail = fmt.Sprintf("%s at %.1f%% of equity after the buy, limit %.1f%%", sector, exposure*100, limit*100)
+	return check, true
+}
+
+// correlationCheck checks that after a buy the positions in its symbol and in
+// the held symbols correlated with it are worth at most the configured
+// fraction of the equity. Without correlation.reject the check passes however
+// concentrated the portfolio gets, and a buy beyond the limit is logged as a
+// warning. ok is false when the check is not configured.
+func (e *Engine) correlationCheck(se events.SignalEvent, signal *models.Signal) (check events.RiskCheck, ok bool) {
+	e.mu.RLock()
+	m := e.correlations
+	e.mu.RUnlock()
+	limit := e.cfg.Correlation.MaxConcentration
+	if signal.Type != models.BuySignal || m == nil || limit <= 0 {
+		return events.RiskCheck{}, false
+	}
+
+	reject := e.cfg.Correlation.Reject
+	check = events.RiskCheck{Name: "correlation", Passed: !reject}
+	source, ok := e.exch.(PositionSource)
+	if !ok {
+		check.Detail = "exchange does not report positions"
+		return check, true
+	}
+	positions, err := source.GetPositions()
+	if err != nil {
+		check.Detail = fmt.Sprintf("failed to get positions: %v", err)
+		return check, true
+	}
+	equity, err := e.equity(positions)
+	if err != nil {
+		check.Detail = err.Error()
+		return check, true
+	}
+	price, err := e.price(se)
+	if err != nil {
+		check.Detail = err.Error()
+		return check, true
+	}
+
+	value := signal.Amount * price
+	var correlated []string
+	for _, p := range positions {
+		if p.Quantity <= 0 {
+			continue
+		}
+		if p.StockCode != signal.Pair {
+			rho, ok, err := m.Correlated(signal.Pair, p.StockCode, e.clock.Now())
+			if err != nil {
+				check.Detail = err.Error()
+				return check, true
+			}
+			if !ok {
+				continue
+			}
+			correlated = append(correlated, fmt.Sprintf("%s (%.2f)", p.StockCode, rho))
+		}
+		value += p.Quantity * p.CurrentPrice
+	}
+	concentration := math.Inf(1)
+	if equity > 0 {
+		concentration = value / equity
+	}
+	names := fmt.Sprintf("no names correlated by %.2f or more", m.Threshold())
+	if len(correlated) > 0 {
+		names = fmt.Sprintf("names correlated by %.2f or more: %s", m.Threshold(), strings.Join(correlated, ", "))
+	}
+	check.Detail = fmt.Sprintf("%.1f%% of equity in %s and %s after the buy, limit %.1f%%", concentration*100, signal.Pair, names, limit*100)
+	if concentration <= limit {
+		check.Passed = true
+		return check, true
+	}
+	if !reject {
+		log.WithFields(logrus.Fields{"pair": signal.Pair, "detail": check.Detail}).Warn("Buy concentrates the portfolio in correlated names")
+	}
+	return check, true
+}
+
+// etfChecks evaluates the ETF and ETN rules of config.ETFConfig: no orders
+// while liquidity providers need not quote, and no buys at a high premium or
+// sells at a deep discount to the NAV.
+func (e *Engine) etfChecks(signal *models.Signal, data *models.MarketData) []events.RiskCheck {
+	var checks []events.RiskCheck
+	rules := e.cfg.ETF
+	if rules.LPGuard {
+		checks = append(checks, e.lpQuotes())
+	}
+	premium, ok := data.Premium()
+	if !ok {
+		return checks
+	}
+	if limit := rules.MaxBuyPremium; limit > 0 && signal.Type == models.BuySignal {
+		checks = append(checks, events.RiskCheck{
+			Name:   "nav_premium",
+			Passed: premium <= limit,
+			Detail: fmt.Sprintf("premium %.4f to NAV %g, limit %g", premium, data.NAV(), limit),
+		})
+	}
+	if limit := rules.MaxSellDiscount; limit > 0 && signal.Type == models.SellSignal {
+		checks = append(checks, events.RiskCheck{
+			Name:   "nav_discount",
+			Passed: -premium <= limit,
+			Detail: fmt.Sprintf("discount %.4f to NAV %g, limit %g", -premium, data.NAV(), limit),
+		})
+	}
+	return checks
+}
+
+// lpQuotes checks that liquidity providers are obliged to quote: not within
+// the open delay of the session open, nor in the closing auction or any
+// off-hours session.
+func (e *Engine) lpQuotes() events.RiskCheck {
+	check := events.RiskCheck{Name: "lp_quotes"}
+	cal, err := market.NewCalendar(e.cfg.Market)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	delay := 5 * time.Minute
+	if d, err := time.ParseDuration(e.cfg.ETF.LPOpenDelay); err == nil {
+		delay = d
+	}
+	now := e.clock.Now()
+	name, ok := cal.SessionAt(now)
+	switch {
+	case !ok || name != config.SessionRegular:
+		check.Detail = "no LP quotes outside the regular session"
+		if ok {
+			check.Detail = "no LP quotes in the " + name + " session"
+		}
+	default:
+		session, _ := cal.SessionOn(now)
+		if quoting := session.Open.Add(delay); now.Before(quoting) {
+			check.Detail = fmt.Sprintf("no LP quotes until %s", quoting.In(market.KST).Format("15:04"))
+		} else {
+			check.Passed = true
+			check.Detail = "LP quotes required"
+		}
+	}
+	return check
+}
+
+func (e *Engine) persist(ev events.Event) {
+	oe := ev.(events.OrderEvent)
+
+	e.mu.RLock()
+	store := e.store
+	e.mu.RUnlock()
+
+	start := e.clock.Now()
+	err := store.SaveOrder(oe.Order)
+	e.timed(oe.Order.Pair, events.PhaseStore, e.clock.Now().Sub(start))
+	if err != nil {
+		e.publishError("store", oe.Order.Pair, fmt.Errorf("failed to save order: %v", err))
+	}
+}
+
+// persistCandle stores a completed candle when the store keeps candles.
+func (e *Engine) persistCandle(ev events.Event) {
+	c := ev.(events.CandleEvent).Candle
+
+	e.mu.RLock()
+	store, ok := e.store.(CandleStore)
+	e.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if err := store.SaveCandles([]candle.Candle{c}); err != nil {
+		e.publishError("store", c.Symbol, fmt.Errorf("failed to save candle: %v", err))
+	}
+}
+
+// timed adds d to phase of the running cycle of symbol, if any.
+func (e *Engine) timed(symbol, phase string, d time.Duration) {
+	e.timingMu.Lock()
+	defer e.timingMu.Unlock()
+	if phases, ok := e.cycles[symbol]; ok {
+		phases[phase] += d
+	}
+}
+
+// finishCycle publishes the CycleEvent of the cycle of symbol begun at start.
+func (e *Engine) finishCycle(symbol string, start time.Time) {
+	e.timingMu.Lock()
+	phases := e.cycles[symbol]
+	delete(e.cycles, symbol)
+	e.timingMu.Unlock()
+	now := e.clock.Now()
+	e.Bus.Publish(events.CycleEvent{Symbol: symbol, Duration: now.Sub(start), Phases: phases, Time: now})
+}
+
+func (e *Engine) publishError(source, symbol string, err error) {
+	e.Bus.Publish(events.ErrorEvent{Source: source, Symbol: symbol, Err: err, Time: e.clock.Now()})
+}
+
+// CircuitState returns the state of the exchange circuit breaker, or Closed
+// when it is disabled.
+func (e *Engine) CircuitState() circuit.State {
+	if e.breaker == nil {
+		return circuit.Closed
+	}
+	return e.breaker.State()
+}
+
+func (e *Engine) recordCall(start time.Time, err error) {
+	if e.breaker != nil {
+		// An order the broker refused was answered, and one the exchange did
+		// not send was never asked; only calls the exchange failed to answer
+		// count against it.
+		if models.OrderRefused(err) || errors.Is(err, models.ErrOrdersDisabled) {
+			err = nil
+		}
+		e.breaker.Record(e.clock.Now().Sub(start), err)
+	}
+}
+
+func (e *Engine) circuitChanged(from, to circuit.State, reason string) {
+	entry := log.WithFields(logrus.Fields{"from": from, "to": to, "reason": reason})
+	if to == circuit.Open {
+		entry.Error("Exchange circuit opened, order placement suspended")
+	} else {
+		entry.Warn("Exchange circuit state changed")
+	}
+	e.Bus.Publish(events.CircuitEvent{From: string(from), To: string(to), Reason: reason, Time: e.clock.Now()})
+}
+
+func (e *Engine) publishDecision(d events.DecisionEvent) {
+	d.Time = e.clock.Now()
+	e.Bus.Publish(d)
+}
+
+func logError(ev events.Event) {
+	ee := ev.(events.ErrorEvent)
+	log.WithError(ee.Err).WithFields(logrus.Fields{"source": ee.Source, "pair": ee.Symbol}).Error("Error in trading cycle")
+}
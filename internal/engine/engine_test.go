@@ -0,0 +1,1024 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/allocation"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/circuit"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/correlation"
+	"tradingbot/internal/events"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/exchange/exchangetest"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/paper"
+	"tradingbot/internal/rules"
+	"tradingbot/internal/sizing"
+	"tradingbot/internal/strategy"
+)
+
+type fakeExchange struct {
+	price  string
+	err    error
+	placed []*models.Signal
+	// quote, when set, is returned instead of a quote of price.
+	quote *models.MarketData
+}
+
+func (f *fakeExchange) GetMarketData(stockCode string) (*models.MarketData, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.quote != nil {
+		return f.quote, nil
+	}
+	return &models.MarketData{StckPrpr: f.price}, nil
+}
+
+func (f *fakeExchange) PlaceOrder(signal *models.Signal) (*models.Order, error) {
+	f.placed = append(f.placed, signal)
+	return &models.Order{Pair: signal.Pair, Amount: signal.Amount, Status: "placed"}, nil
+}
+
+type fakeStore struct {
+	saved []*models.Order
+}
+
+func (f *fakeStore) SaveOrder(order *models.Order) error {
+	f.saved = append(f.saved, order)
+	return nil
+}
+
+// fixedStrategy always returns the same signal type.
+type fixedStrategy struct {
+	signal models.SignalType
+}
+
+func (s fixedStrategy) Analyze(data *models.MarketData) *models.Signal {
+	return &models.Signal{Type: s.signal, Amount: 1}
+}
+
+// recordingStrategy holds and remembers the prices it was given.
+type recordingStrategy struct {
+	prices []string
+}
+
+func (s *recordingStrategy) Analyze(data *models.MarketData) *models.Signal {
+	s.prices = append(s.prices, data.StckPrpr)
+	return &models.Signal{Type: models.HoldSignal}
+}
+
+func TestRunCyclePlacesAndStoresOrder(t *testing.T) {
+	exch := &fakeExchange{price: "70000"}
+	store := &fakeStore{}
+	e := New(&config.Config{}, exch, store, map[string]strategy.Strategy{"005930": fixedStrategy{models.BuySignal}})
+
+	var kinds []events.Kind
+	e.Bus.Subscribe(func(ev events.Event) { kinds = append(kinds, ev.Kind()) },
+		events.KindMarketData, events.KindSignal, events.KindOrder, events.KindError)
+
+	if err := e.RunCycle("005930"); err != nil {
+		t.Fatalf("RunCycle returned error: %v", err)
+	}
+
+	if len(exch.placed) != 1 || exch.placed[0].Pair != "005930" {
+		t.Fatalf("placed = %+v, want one order for 005930", exch.placed)
+	}
+	if len(store.saved) != 1 {
+		t.Fatalf("saved %d orders, want 1", len(store.saved))
+	}
+	// Nested publishes are delivered depth-first, so only check each stage was seen once.
+	seen := map[events.Kind]int{}
+	for _, kind := range kinds {
+		seen[kind]++
+	}
+	for _, kind := range []events.Kind{events.KindMarketData, events.KindSignal, events.KindOrder} {
+		if seen[kind] != 1 {
+			t.Errorf("saw %d %s events, want 1 (all: %v)", seen[kind], kind, kinds)
+		}
+	}
+	if seen[events.KindError] != 0 {
+		t.Errorf("unexpected error events: %v", kinds)
+	}
+}
+
+func TestObserverRecordsSignalsWithoutOrders(t *testing.T) {
+	exch := &positionExchange{fakeExchange: fakeExchange{price: "70000"}, held: 7}
+	store := &fakeStore{}
+	cfg := &config.Config{Exchange: config.ExchangeConfig{Observe: true}}
+	e := New(cfg, exch, store, map[string]strategy.Strategy{"005930": fixedStrategy{models.SellSignal}})
+	var decisions []events.DecisionEvent
+	e.Bus.Subscribe(func(ev events.Event) { decisions = append(decisions, ev.(events.DecisionEvent)) }, events.KindDecision)
+
+	if err := e.RunCycle("005930"); err != nil {
+		t.Fatal(err)
+	}
+	if len(exch.placed) != 0 || len(store.saved) != 0 {
+		t.Fatalf("placed %+v, saved %+v; want no orders", exch.placed, store.saved)
+	}
+	if len(decisions) != 1 || decisions[0].Action != events.ActionObserved || decisions[0].Signal.Amount != 7 {
+		t.Fatalf("decisions = %+v, want the sale of 7 observed", decisions)
+	}
+	if err := e.ClosePosition("manual", "005930"); err == nil || len(exch.placed) != 0 {
+		t.Errorf("close = %v, placed %+v; want overrides refused", err, exch.placed)
+	}
+}
+
+func TestRunCycleRespectsMaxOrderAmount(t *testing.T) {
+	exch := &fakeExchange{price: "70000"}
+	cfg := &config.Config{Risk: config.RiskConfig{MaxOrderAmount: 0.5}}
+	e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": fixedStrategy{models.SellSignal}})
+
+	if err := e.RunCycle("005930"); err != nil {
+		t.Fatalf("RunCycle returned error: %v", err)
+	}
+	if len(exch.placed) != 0 {
+		t.Errorf("placed %d orders, want none above the limit", len(exch.placed))
+	}
+}
+
+func TestRunCyclePublishesErrors(t *testing.T) {
+	e := New(&config.Config{}, &fakeExchange{err: errors.New("timeout")}, &fakeStore{}, nil)
+
+	var got []events.ErrorEvent
+	e.Bus.Subscribe(func(ev events.Event) { got = append(got, ev.(events.ErrorEvent)) }, events.KindError)
+
+	if err := e.RunCycle("005930"); err == nil {
+		t.Fatalf("expected error from RunCycle")
+	}
+	if len(got) != 1 || got[0].Source != "market_data" {
+		t.Errorf("error events = %+v, want one market_data error", got)
+	}
+}
+
+func TestTimeframeAnalyzesCompletedCandles(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST))
+	exch := &fakeExchange{}
+	strat := &recordingStrategy{}
+	cfg := &config.Config{ParsedTimeframe: 5 * time.Minute}
+	e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": strat})
+	e.SetClock(clk)
+
+	var candles []events.CandleEvent
+	e.Bus.Subscribe(func(ev events.Event) { candles = append(candles, ev.(events.CandleEvent)) }, events.KindCandle)
+
+	for i, price := range []string{"100", "104", "97", "101", "102"} {
+		exch.price = price
+		if err := e.RunCycle("005930"); err != nil {
+			t.Fatalf("RunCycle %d returned error: %v", i, err)
+		}
+		clk.Advance(time.Minute)
+	}
+	if len(strat.prices) != 0 {
+		t.Fatalf("strategy saw %v before the candle completed", strat.prices)
+	}
+
+	// The cycle at 09:05 completes the 09:00 candle before polling.
+	exch.price = "110"
+	e.RunCycle("005930")
+	if len(candles) != 1 {
+		t.Fatalf("got %d candles, want 1", len(candles))
+	}
+	c := candles[0].Candle
+	if c.Open != 100 || c.High != 104 || c.Low != 97 || c.Close != 102 {
+		t.Errorf("candle = %+v", c)
+	}
+	if len(strat.prices) != 1 || strat.prices[0] != "102" {
+		t.Errorf("strategy saw %v, want the candle close 102", strat.prices)
+	}
+}
+
+func TestBarCloseSkipsPartialCandle(t *testing.T) {
+	// Started at 09:03, three minutes into the 09:00 candle.
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 3, 0, 0, market.KST))
+	exch := &fakeExchange{}
+	strat := &recordingStrategy{}
+	cfg := &config.Config{ParsedTimeframe: 5 * time.Minute, ParsedInterval: time.Minute, BarClose: true}
+	e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": strat})
+	e.SetClock(clk)
+
+	for _, price := range []string{"100", "101", "102", "103", "104", "105", "106"} {
+		exch.price = price
+		e.RunCycle("005930")
+		clk.Advance(time.Minute)
+	}
+	// At 09:10 the 09:05 candle completes; it is the first the bot saw whole.
+	e.RunCycle("005930")
+	if len(strat.prices) != 1 || strat.prices[0] != "106" {
+		t.Errorf("strategy saw %v, want only the close 106 of the 09:05 candle", strat.prices)
+	}
+}
+
+// candleStore also keeps candles.
+type candleStore struct {
+	fakeStore
+	candles []candle.Candle
+}
+
+func (s *candleStore) SaveCandles(candles []candle.Candle) error {
+	s.candles = append(s.candles, candles...)
+	return nil
+}
+
+func TestResumeCatchesUpWithoutTrading(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 12, 0, 0, market.KST))
+	exch := &fakeExchange{price: "130"}
+	strat := &recordingStrategy{}
+	store := &candleStore{}
+	e := New(&config.Config{ParsedTimeframe: 5 * time.Minute}, exch, store, map[string]strategy.Strategy{"005930": strat})
+	e.SetClock(clk)
+
+	// The 1m candles from 09:00 to 09:11 missed while stopped.
+	var missed []candle.Candle
+	for i := 0; i < 12; i++ {
+		price := float64(100 + i)
+		missed = append(missed, candle.Candle{Symbol: "005930", Start: time.Date(2026, 10, 16, 9, i, 0, 0, market.KST), Timeframe: time.Minute, Open: price, High: price, Low: price, Close: price})
+	}
+	e.Resume(missed)
+
+	if got := strings.Join(strat.prices, " "); got != "104 109" {
+		t.Errorf("strategy caught up on %q, want the closes of the 09:00 and 09:05 candles", got)
+	}
+	if len(store.candles) != 2 || store.candles[1].Open != 105 || store.candles[1].Close != 109 {
+		t.Errorf("stored candles = %+v", store.candles)
+	}
+	if len(exch.placed) != 0 {
+		t.Errorf("placed %d orders on past candles", len(exch.placed))
+	}
+
+	// The 09:10 candle continues with the polls.
+	e.RunCycle("005930")
+	clk.Advance(3 * time.Minute)
+	e.RunCycle("005930")
+	if len(store.candles) != 3 || store.candles[2].Open != 110 || store.candles[2].High != 130 {
+		t.Errorf("candle after resuming = %+v", store.candles[len(store.candles)-1])
+	}
+}
+
+func TestCircuitBreakerSuspendsOrders(t *testing.T) {
+	exch := &fakeExchange{price: "70000", err: errors.New("503 Service Unavailable")}
+	cfg := &config.Config{CircuitBreaker: config.CircuitBreakerConfig{Enabled: true, FailureThreshold: 2, Cooldown: "1h"}}
+	e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": fixedStrategy{models.BuySignal}})
+
+	var circuits []events.CircuitEvent
+	var decisions []events.DecisionEvent
+	e.Bus.Subscribe(func(ev events.Event) { circuits = append(circuits, ev.(events.CircuitEvent)) }, events.KindCircuit)
+	e.Bus.Subscribe(func(ev events.Event) { decisions = append(decisions, ev.(events.DecisionEvent)) }, events.KindDecision)
+
+	e.RunCycle("005930")
+	e.RunCycle("005930")
+	if len(circuits) != 1 || circuits[0].To != "open" {
+		t.Fatalf("circuit events = %+v, want one transition to open", circuits)
+	}
+
+	// Market data keeps being polled, but no order is placed while the circuit is open.
+	exch.err = nil
+	if err := e.RunCycle("005930"); err != nil {
+		t.Fatalf("RunCycle returned error: %v", err)
+	}
+	if len(exch.placed) != 0 {
+		t.Errorf("placed %d orders with the circuit open", len(exch.placed))
+	}
+	last := decisions[len(decisions)-1]
+	if last.Action != events.ActionRejected || len(last.Checks) != 1 || last.Checks[0].Name != "circuit_breaker" {
+		t.Errorf("last decision = %+v, want rejected by circuit_breaker", last)
+	}
+}
+
+// disabledExchange refuses to send orders, like an exchange not yet armed.
+type disabledExchange struct {
+	fakeExchange
+}
+
+func (d *disabledExchange) PlaceOrder(signal *models.Signal) (*models.Order, error) {
+	return nil, fmt.Errorf("live orders are not approved: %w", models.ErrOrdersDisabled)
+}
+
+func TestDisabledOrdersDoNotOpenCircuit(t *testing.T) {
+	exch := &disabledExchange{fakeExchange{price: "70000"}}
+	cfg := &config.Config{CircuitBreaker: config.CircuitBreakerConfig{Enabled: true, FailureThreshold: 2, Cooldown: "1h"}}
+	e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": fixedStrategy{models.BuySignal}})
+
+	for i := 0; i < 3; i++ {
+		e.RunCycle("005930")
+	}
+	if state := e.CircuitState(); state != circuit.Closed {
+		t.Errorf("circuit %s after orders that were not sent, want closed", state)
+	}
+}
+
+func TestAllocatorSleevesTagOrders(t *testing.T) {
+	cfg := &config.Config{
+		TradingPair: "005930",
+		Strategy:    "moving_average",
+		Strategies: map[string]config.StrategyParams{
+			"moving_average": {"short_period": 1, "long_period": 2, "threshold": 0.0},
+		},
+		Allocation: config.AllocationConfig{Capital: 1000000, Sleeves: []config.SleeveConfig{
+			{Name: "a", Weight: 1},
+			{Name: "b", Weight: 1},
+		}},
+	}
+	alloc, err := allocation.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exch := &fakeExchange{price: "100"}
+	store := &fakeStore{}
+	e := New(cfg, exch, store, nil)
+	e.SetAllocator(alloc)
+
+	e.RunCycle("005930")
+	exch.price = "110"
+	e.RunCycle("005930")
+
+	if len(store.saved) != 2 || store.saved[0].Strategy != "a" || store.saved[1].Strategy != "b" {
+		t.Fatalf("saved = %+v, want one order per sleeve", store.saved)
+	}
+	for _, b := range alloc.Books() {
+		if b.Positions["005930"].Quantity != 4545 {
+			t.Errorf("book %s = %+v, want 4545 shares", b.Strategy, b)
+		}
+	}
+}
+
+// positionExchange also reports the held positions, the credit shares in a
+// row of their own.
+type positionExchange struct {
+	fakeExchange
+	held   float64
+	credit float64
+}
+
+func (p *positionExchange) GetPositions() ([]models.Position, error) {
+	var positions []models.Position
+	if p.held != 0 {
+		positions = append(positions, models.Position{StockCode: "005930", Quantity: p.held})
+	}
+	if p.credit != 0 {
+		positions = append(positions, models.Position{StockCode: "005930", Quantity: p.credit, Loan: 70000 * p.credit})
+	}
+	return positions, nil
+}
+
+func TestSignalsSizedToPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		position config.PositionConfig
+		signal   models.SignalType
+		held     float64
+		credit   float64
+		want     float64 // 0 means no order
+	}{
+		{"buy when flat", config.PositionConfig{}, models.BuySignal, 0, 0, 1},
+		{"buy at target", config.PositionConfig{}, models.BuySignal, 1, 0, 0},
+		{"buy up to target", config.PositionConfig{TargetQuantity: 10}, models.BuySignal, 4, 0, 6},
+		{"scale in", config.PositionConfig{TargetQuantity: 10, ScaleIn: 3}, models.BuySignal, 8, 0, 2},
+		{"sell closes position", config.PositionConfig{}, models.SellSignal, 7, 0, 7},
+		{"scale out", config.PositionConfig{ScaleOut: 2}, models.SellSignal, 7, 0, 2},
+		{"sell when flat", config.PositionConfig{}, models.SellSignal, 0, 0, 0},
+		{"target notional", config.PositionConfig{TargetNotional: 1000000}, models.BuySignal, 4, 0, 10},
+		{"scale in notional", config.PositionConfig{TargetQuantity: 10, ScaleInNotional: 150000}, models.BuySignal, 0, 0, 2},
+		{"cash and credit held", config.PositionConfig{TargetQuantity: 10}, models.BuySignal, 4, 3, 3},
+		{"sell closes cash and credit", config.PositionConfig{}, models.SellSignal, 4, 3, 7},
+	}
+	for _, tt := range tests {
+		exch := &positionExchange{fakeExchange: fakeExchange{price: "70000"}, held: tt.held, credit: tt.credit}
+		e := New(&config.Config{Position: tt.position}, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": fixedStrategy{tt.signal}})
+
+		var decision events.DecisionEvent
+		e.Bus.Subscribe(func(ev events.Event) { decision = ev.(events.DecisionEvent) }, events.KindDecision)
+		e.RunCycle("005930")
+
+		switch {
+		case tt.want == 0 && len(exch.placed) != 0:
+			t.Errorf("%s: placed %+v, want no order", tt.name, exch.placed[0])
+		case tt.want == 0 && decision.Action != events.ActionHold:
+			t.Errorf("%s: action %s, want hold", tt.name, decision.Action)
+		case tt.want > 0 && (len(exch.placed) != 1 || exch.placed[0].Amount != tt.want):
+			t.Errorf("%s: placed %+v, want %g shares", tt.name, exch.placed, tt.want)
+		}
+	}
+}
+
+// balanceExchange also reports the cash balance.
+type balanceExchange struct {
+	positionExchange
+	balance string
+}
+
+func (b *balanceExchange) GetBalance() (string, error) { return b.balance, nil }
+
+func TestKellySizesBuysOfEquity(t *testing.T) {
+	exch := &balanceExchange{positionExchange: positionExchange{fakeExchange: fakeExchange{price: "70000"}}, balance: "10000000"}
+	cfg := &config.Config{Strategy: "moving_average", Position: config.PositionConfig{TargetQuantity: 1}}
+	e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": fixedStrategy{models.BuySignal}})
+	e.SetKelly(sizing.NewKelly(config.KellyConfig{Strategies: []string{"moving_average"}, Cap: 0.1}, cfg.Strategy,
+		map[string]sizing.Edge{"moving_average": {Trades: 30, WinRate: 0.6, AvgWin: 2, AvgLoss: 1}}))
+
+	// Half Kelly is 20% of equity, capped at 10%: ₩1,000,000 buys 14 shares.
+	e.RunCycle("005930")
+	if len(exch.placed) != 1 || exch.placed[0].Amount != 14 {
+		t.Fatalf("placed %+v, want a buy of 14 shares", exch.placed)
+	}
+}
+
+func TestSignalRulesApplyBeforeSizing(t *testing.T) {
+	exch := &positionExchange{fakeExchange: fakeExchange{price: "70000"}}
+	e := New(&config.Config{Position: config.PositionConfig{TargetQuantity: 10}}, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": fixedStrategy{models.SellSignal}})
+	e.SetRules(rules.New([]config.SignalRule{
+		{Rule: config.SignalRuleInvert},
+		{Rule: config.SignalRuleTranches, Tranches: 3},
+	}))
+
+	// The inverted sell buys the first third of the target, rounded up.
+	e.RunCycle("005930")
+	if len(exch.placed) != 1 || exch.placed[0].Type != models.BuySignal || exch.placed[0].Amount != 4 {
+		t.Fatalf("placed %+v, want a buy of 4 shares", exch.placed)
+	}
+}
+
+func TestMarginOrdersWithinLeverage(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST))
+	exch := paper.New(1000000, 0.001, clk)
+	exch.SetMarginRequirement(0.5)
+	exch.SetPrice("005930", 70000)
+	cfg := &config.Config{Margin: config.MarginConfig{Enabled: true, Requirement: 0.5, MaxLeverage: 1.5}}
+	e := New(cfg, exch, &fakeStore{}, nil)
+	e.SetClock(clk)
+
+	// 1,400,000 of stock on 1,000,000 of equity, half of it borrowed.
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 20})
+	positions, _ := exch.GetPositions()
+	if len(positions) != 1 || positions[0].Loan != 700000 || positions[0].LoanDate != "20261016" {
+		t.Fatalf("positions %+v, want a credit buy borrowing half", positions)
+	}
+
+	var decision events.DecisionEvent
+	e.Bus.Subscribe(func(ev events.Event) { decision = ev.(events.DecisionEvent) }, events.KindDecision)
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 5})
+	if decision.Action != events.ActionRejected || decision.Checks[len(decision.Checks)-1].Name != "max_leverage" {
+		t.Errorf("decision %+v, want the buy over the leverage limit rejected", decision)
+	}
+
+	e.Submit("tradingview", &models.Signal{Type: models.SellSignal, Pair: "005930", Amount: 20})
+	if decision.Action != events.ActionOrdered {
+		t.Fatalf("decision %+v, want the sell to repay the loan", decision)
+	}
+	if got, want := exch.Cash(), 1000000-2*1400.0; got != want {
+		t.Errorf("cash = %v, want %v after repaying the loan", got, want)
+	}
+}
+
+func TestNotionalSignalsBuyWholeLots(t *testing.T) {
+	exch := &fakeExchange{price: "70000"}
+	e := New(&config.Config{}, exch, &fakeStore{}, nil)
+	e.SetLotSizes(map[string]int{"000660": 10})
+
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Notional: 1000000})
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "000660", Notional: 1000000})
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Notional: 50000})
+
+	if len(exch.placed) != 2 {
+		t.Fatalf("placed %d orders, want 2", len(exch.placed))
+	}
+	if exch.placed[0].Amount != 14 || exch.placed[1].Amount != 10 {
+		t.Errorf("placed %g and %g shares, want 14 and 10", exch.placed[0].Amount, exch.placed[1].Amount)
+	}
+}
+
+func TestOrdersRoundedToLotsAndTicks(t *testing.T) {
+	exch := &fakeExchange{price: "70000"}
+	e := New(&config.Config{}, exch, &fakeStore{}, nil)
+	e.SetLotSizes(map[string]int{"000660": 10})
+
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "000660", Amount: 27, LimitPrice: 70050})
+	e.Submit("tradingview", &models.Signal{Type: models.SellSignal, Pair: "005930", Amount: 3, LimitPrice: 70050})
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "000660", Amount: 5})
+
+	if len(exch.placed) != 2 {
+		t.Fatalf("placed %d orders, want 2", len(exch.placed))
+	}
+	if got := exch.placed[0]; got.Amount != 20 || got.LimitPrice != 70000 {
+		t.Errorf("buy placed %g at %g, want 20 at 70000", got.Amount, got.LimitPrice)
+	}
+	if got := exch.placed[1]; got.Amount != 3 || got.LimitPrice != 70100 {
+		t.Errorf("sell placed %g at %g, want 3 at 70100", got.Amount, got.LimitPrice)
+	}
+}
+
+func TestHaltedLimitUpAndStaleQuoteOrdersRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		quote  models.MarketData
+		margin float64
+		signal models.SignalType
+		check  string // empty means the order is placed
+	}{
+		{"normal", models.MarketData{StckPrpr: "70000", StckMxpr: "91000", TrhtYn: "N", TempStopYn: "N"}, 0, models.BuySignal, ""},
+		{"halted", models.MarketData{StckPrpr: "70000", TrhtYn: "Y"}, 0, models.SellSignal, "trading_halt"},
+		{"temporarily stopped", models.MarketData{StckPrpr: "70000", TempStopYn: "Y"}, 0, models.BuySignal, "trading_halt"},
+		{"buy at limit up", models.MarketData{StckPrpr: "91000", StckMxpr: "91000"}, 0, models.BuySignal, "price_limit"},
+		{"buy near limit up", models.MarketData{StckPrpr: "90000", StckMxpr: "91000"}, 0.02, models.BuySignal, "price_limit"},
+		{"sell at limit up", models.MarketData{StckPrpr: "91000", StckMxpr: "91000"}, 0, models.SellSignal, ""},
+		{"stale quote", models.MarketData{StckPrpr: "70000", QuoteTime: time.Now().Add(-5 * time.Minute)}, 0, models.SellSignal, "quote_age"},
+		{"wide spread", models.MarketData{StckPrpr: "70000", Askp1: "70500", Bidp1: "70000"}, 0, models.BuySignal, "spread"},
+		{"tight spread", models.MarketData{StckPrpr: "70000", Askp1: "70100", Bidp1: "70000"}, 0, models.BuySignal, ""},
+	}
+	for _, tt := range tests {
+		quote := tt.quote
+		exch := &fakeExchange{quote: &quote}
+		risk := config.RiskConfig{LimitUpMargin: tt.margin, MaxQuoteAge: "1m", MaxSpreadBps: 20}
+		e := New(&config.Config{Risk: risk}, exch, &fakeStore{}, nil)
+		var halts []events.HaltEvent
+		e.Bus.Subscribe(func(ev events.Event) { halts = append(halts, ev.(events.HaltEvent)) }, events.KindHalt)
+
+		e.Submit("tradingview", &models.Signal{Type: tt.signal, Pair: "005930", Amount: 1})
+
+		switch {
+		case tt.check == "" && (len(exch.placed) != 1 || len(halts) != 0):
+			t.Errorf("%s: placed %d orders with %d halt events, want an order", tt.name, len(exch.placed), len(halts))
+		case tt.check != "" && (len(exch.placed) != 0 || len(halts) != 1 || halts[0].Check != tt.check):
+			t.Errorf("%s: placed %d orders with halt events %+v, want refused by %s", tt.name, len(exch.placed), halts, tt.check)
+		}
+	}
+}
+
+// TestQuoteGuardsOnKIS checks the quote age and spread of quotes from KIS,
+// whose current price carries neither, against its asking price.
+func TestQuoteGuardsOnKIS(t *testing.T) {
+	kis := exchangetest.NewServer(10000000)
+	defer kis.Close()
+	kis.SetPrice("005930", 70000)
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST))
+	exch, err := exchange.New(config.ExchangeConfig{BaseURL: kis.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exch.Clock = clk
+
+	tests := []struct {
+		name  string
+		book  models.AskingPrice
+		check string // empty means the order is placed
+	}{
+		{"stale book", models.AskingPrice{Ask: 70100, Bid: 70000, Time: clk.Now().Add(-2 * time.Minute)}, "quote_age"},
+		{"wide spread", models.AskingPrice{Ask: 70500, Bid: 70000, Time: clk.Now()}, "spread"},
+		{"fresh and tight", models.AskingPrice{Ask: 70100, Bid: 70000, Time: clk.Now()}, ""},
+	}
+	for _, tt := range tests {
+		kis.SetAskingPrice("005930", tt.book)
+		cfg := &config.Config{Risk: config.RiskConfig{MaxQuoteAge: "1m", MaxSpreadBps: 20}}
+		e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": fixedStrategy{models.BuySignal}})
+		e.SetClock(clk)
+		var halts []events.HaltEvent
+		e.Bus.Subscribe(func(ev events.Event) { halts = append(halts, ev.(events.HaltEvent)) }, events.KindHalt)
+		before := len(kis.Orders())
+
+		e.RunCycle("005930")
+		placed := len(kis.Orders()) - before
+		switch {
+		case tt.check == "" && (placed != 1 || len(halts) != 0):
+			t.Errorf("%s: placed %d orders with halt events %+v, want an order", tt.name, placed, halts)
+		case tt.check != "" && (placed != 0 || len(halts) == 0 || halts[0].Check != tt.check):
+			t.Errorf("%s: placed %d orders with halt events %+v, want refused by %s", tt.name, placed, halts, tt.check)
+		}
+	}
+}
+
+func TestKISQuotesCarrySpreadForAdaptivePolling(t *testing.T) {
+	kis := exchangetest.NewServer(10000000)
+	defer kis.Close()
+	kis.SetPrice("005930", 70000)
+	kis.SetAskingPrice("005930", models.AskingPrice{Ask: 70100, Bid: 70000, Time: time.Now()})
+	exch, err := exchange.New(config.ExchangeConfig{BaseURL: kis.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{AdaptivePolling: config.AdaptivePollingConfig{Enabled: true, TargetSpread: 0.001}}
+	e := New(cfg, exch, &fakeStore{}, nil)
+	var quotes []*models.MarketData
+	e.Bus.Subscribe(func(ev events.Event) { quotes = append(quotes, ev.(events.MarketDataEvent).Data) }, events.KindMarketData)
+
+	e.RunCycle("005930")
+	if len(quotes) != 1 {
+		t.Fatalf("%d quotes published", len(quotes))
+	}
+	if spread, ok := quotes[0].Spread(); !ok || spread < 0.0014 || spread > 0.0015 {
+		t.Errorf("spread %v, %v, want the 100 won between ask and bid", spread, ok)
+	}
+}
+
+func TestPausedStrategyOnlySells(t *testing.T) {
+	exch := &fakeExchange{price: "70000"}
+	strat := &fixedStrategy{models.BuySignal}
+	e := New(&config.Config{Strategy: "moving_average"}, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": strat})
+	e.PauseStrategy("moving_average")
+
+	e.RunCycle("005930")
+	if len(exch.placed) != 0 {
+		t.Fatalf("placed %+v, want the buy of a paused strategy rejected", exch.placed)
+	}
+	// External signals are not the strategy's and still trade.
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1})
+	if len(exch.placed) != 1 {
+		t.Fatalf("placed %d orders, want the external buy", len(exch.placed))
+	}
+	strat.signal = models.SellSignal
+	e.RunCycle("005930")
+	if len(exch.placed) != 2 {
+		t.Fatalf("placed %d orders, want the strategy's sell", len(exch.placed))
+	}
+
+	e.ResumeStrategy("moving_average")
+	strat.signal = models.BuySignal
+	e.RunCycle("005930")
+	if len(exch.placed) != 3 {
+		t.Errorf("placed %d orders, want a buy after resuming", len(exch.placed))
+	}
+}
+
+func TestSeparateMarketDataSource(t *testing.T) {
+	exch := &fakeExchange{err: errors.New("quotes unavailable")}
+	e := New(&config.Config{}, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": &fixedStrategy{models.BuySignal}})
+	e.SetMarketData(&fakeExchange{price: "70000"})
+
+	e.RunCycle("005930")
+	if len(exch.placed) != 1 {
+		t.Fatalf("placed %d orders, want quotes from the data source and the order on the exchange", len(exch.placed))
+	}
+}
+
+type fakeEarnings map[string]time.Time
+
+func (f fakeEarnings) Upcoming(symbol string, at time.Time, days int) (time.Time, bool) {
+	date, ok := f[symbol]
+	return date, ok && !date.Before(at) && date.Sub(at) <= time.Duration(days)*24*time.Hour
+}
+
+func TestEarningsBlackoutRejectsBuys(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	exch := &fakeExchange{price: "70000"}
+	strat := &fixedStrategy{models.BuySignal}
+	cfg := &config.Config{Strategy: "moving_average", Earnings: config.EarningsConfig{
+		BlackoutDays: 3,
+		Strategies:   map[string]int{"moving_average": 0},
+	}}
+	e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": strat})
+	e.SetClock(clock.NewSimulated(now))
+	e.SetEarnings(fakeEarnings{"005930": now.AddDate(0, 0, 2)})
+
+	// The strategy is exempt; external signals use the default blackout.
+	e.RunCycle("005930")
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1})
+	if len(exch.placed) != 1 {
+		t.Fatalf("placed %d orders, want only the exempt strategy's buy", len(exch.placed))
+	}
+
+	cfg.Earnings.Strategies["moving_average"] = 5
+	e.RunCycle("005930")
+	strat.signal = models.SellSignal
+	e.RunCycle("005930")
+	if len(exch.placed) != 2 || exch.placed[1].Type != models.SellSignal {
+		t.Errorf("placed %+v, want the buy rejected and the sell placed", exch.placed)
+	}
+}
+
+type fixedSentiment float64
+
+func (f fixedSentiment) Sentiment(symbol string) (float64, bool) {
+	return float64(f), true
+}
+
+func TestSentimentFedToStrategies(t *testing.T) {
+	minBuy := 0.0
+	ma := strategy.NewMovingAverage(models.MovingAverageConfig{ShortPeriod: 1, LongPeriod: 2, MinBuySentiment: &minBuy})
+	exch := &fakeExchange{price: "70000"}
+	e := New(&config.Config{}, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": ma})
+	e.SetSentiment(fixedSentiment(-0.5))
+
+	e.RunCycle("005930")
+	exch.price = "71000"
+	e.RunCycle("005930")
+	if len(exch.placed) != 0 {
+		t.Fatalf("placed %+v, want the crossover held back by negative news", exch.placed)
+	}
+	if got := ma.Indicators()["sentiment"]; got != -0.5 {
+		t.Errorf("sentiment indicator = %v, want -0.5", got)
+	}
+
+	e.SetSentiment(fixedSentiment(0.3))
+	exch.price = "72000"
+	e.RunCycle("005930")
+	if len(exch.placed) != 1 || exch.placed[0].Type != models.BuySignal {
+		t.Errorf("placed %+v, want a buy once sentiment recovered", exch.placed)
+	}
+}
+
+func TestOrdersTaggedWithSession(t *testing.T) {
+	tests := []struct {
+		at      time.Time
+		session string
+		limit   float64
+		placed  bool
+	}{
+		{time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST), "", 0, true},
+		{time.Date(2026, 10, 16, 15, 45, 0, 0, market.KST), config.SessionAfterHoursClose, 0, true},
+		{time.Date(2026, 10, 16, 16, 30, 0, 0, market.KST), config.SessionAfterHoursSingle, 70000, true},
+		{time.Date(2026, 10, 16, 8, 35, 0, 0, market.KST), "", 0, false}, // pre-market not enabled
+		{time.Date(2026, 10, 16, 20, 0, 0, 0, market.KST), "", 0, false},
+	}
+	for _, tt := range tests {
+		exch := &fakeExchange{price: "70000"}
+		cfg := &config.Config{Market: config.MarketConfig{
+			Enabled:          true,
+			ExtendedSessions: []string{config.SessionAfterHoursClose, config.SessionAfterHoursSingle},
+		}}
+		e := New(cfg, exch, &fakeStore{}, nil)
+		e.SetClock(clock.NewSimulated(tt.at))
+
+		e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1})
+
+		switch {
+		case !tt.placed && len(exch.placed) != 0:
+			t.Errorf("%s: placed %+v, want no order", tt.at, exch.placed[0])
+		case tt.placed && len(exch.placed) != 1:
+			t.Errorf("%s: placed %d orders, want 1", tt.at, len(exch.placed))
+		case tt.placed && (exch.placed[0].Session != tt.session || exch.placed[0].LimitPrice != tt.limit):
+			t.Errorf("%s: placed %+v, want session %q at %g", tt.at, exch.placed[0], tt.session, tt.limit)
+		}
+	}
+}
+
+// navExchange publishes a fixed NAV for ETFs.
+type navExchange struct {
+	fakeExchange
+	nav float64
+}
+
+func (n *navExchange) GetNAV(stockCode string) (float64, error) {
+	return n.nav, nil
+}
+
+func TestETFTradesOnNAV(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 2, 0, 0, market.KST))
+	exch := &navExchange{fakeExchange: fakeExchange{price: "9950"}, nav: 10000}
+	cfg := &config.Config{ETF: config.ETFConfig{NAV: true, LPGuard: true, MaxBuyPremium: 0.01}}
+	strat := strategy.NewNAVDeviation(models.NAVDeviationConfig{EntryDiscount: 0.004, ExitPremium: 0.001})
+	e := New(cfg, exch, &fakeStore{}, map[string]strategy.Strategy{"069500": strat})
+	e.SetClock(clk)
+	e.SetInstruments(map[string]models.InstrumentType{"069500": models.InstrumentETF})
+
+	// LPs need not quote in the first five minutes.
+	e.RunCycle("069500")
+	if len(exch.placed) != 0 {
+		t.Fatalf("placed %+v, want no order before LPs quote", exch.placed)
+	}
+	if got := strat.Indicators()["premium"]; got > -0.0049 || got < -0.0051 {
+		t.Errorf("premium indicator = %v, want -0.005", got)
+	}
+
+	clk.Set(time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST))
+	e.RunCycle("069500")
+	if len(exch.placed) != 1 || exch.placed[0].Type != models.BuySignal {
+		t.Fatalf("placed %+v, want a buy at a discount to NAV", exch.placed)
+	}
+
+	exch.price = "10200"
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "069500", Amount: 1})
+	if len(exch.placed) != 1 {
+		t.Errorf("placed %d orders, want the buy at a 2%% premium rejected", len(exch.placed))
+	}
+
+	// Stocks are not subject to the ETF rules.
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1})
+	if len(exch.placed) != 2 {
+		t.Errorf("placed %d orders, want the stock buy placed", len(exch.placed))
+	}
+}
+
+func TestManualTradesAudited(t *testing.T) {
+	exch := &positionExchange{fakeExchange: fakeExchange{price: "70000"}, held: 7}
+	store := &fakeStore{}
+	e := New(&config.Config{Risk: config.RiskConfig{MaxOrderAmount: 100000}}, exch, store, nil)
+	var decisions []events.DecisionEvent
+	e.Bus.Subscribe(func(ev events.Event) { decisions = append(decisions, ev.(events.DecisionEvent)) }, events.KindDecision)
+
+	e.Record("manual", &models.Order{Pair: "000660", Side: models.OrderSideBuy, Amount: 3, Price: 150000, Strategy: ManualStrategy})
+	if len(exch.placed) != 0 || len(store.saved) != 1 || store.saved[0].Timestamp.IsZero() {
+		t.Fatalf("placed %+v, saved %+v; want the trade only stored, with a time", exch.placed, store.saved)
+	}
+	if d := decisions[0]; d.Action != events.ActionRecorded || d.Source != "manual" {
+		t.Errorf("decision = %+v, want a recorded manual trade", d)
+	}
+
+	// The ₩490,000 sale is over the order limit but the override bypasses it.
+	if err := e.ClosePosition("manual", "005930"); err != nil {
+		t.Fatal(err)
+	}
+	if len(exch.placed) != 1 || exch.placed[0].Type != models.SellSignal || exch.placed[0].Amount != 7 {
+		t.Fatalf("placed %+v, want the 7 shares sold", exch.placed)
+	}
+	if len(store.saved) != 2 || store.saved[1].Strategy != ManualStrategy || decisions[1].Action != events.ActionOrdered {
+		t.Errorf("saved %+v, decision %+v; want a stored and audited manual order", store.saved[1], decisions[1])
+	}
+
+	exch.held = 0
+	if err := e.ClosePosition("manual", "005930"); err == nil {
+		t.Error("closed a position that is not held")
+	}
+}
+
+// slowExchange takes a second per quote and two per order on its clock.
+type slowExchange struct {
+	fakeExchange
+	clock *clock.Simulated
+}
+
+func (s *slowExchange) GetMarketData(stockCode string) (*models.MarketData, error) {
+	s.clock.Advance(time.Second)
+	return s.fakeExchange.GetMarketData(stockCode)
+}
+
+func (s *slowExchange) PlaceOrder(signal *models.Signal) (*models.Order, error) {
+	s.clock.Advance(2 * time.Second)
+	return s.fakeExchange.PlaceOrder(signal)
+}
+
+func TestCyclePhasesTimed(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST))
+	exch := &slowExchange{fakeExchange: fakeExchange{price: "70000"}, clock: clk}
+	e := New(&config.Config{}, exch, &fakeStore{}, map[string]strategy.Strategy{"005930": fixedStrategy{models.BuySignal}})
+	e.SetClock(clk)
+
+	var cycles []events.CycleEvent
+	e.Bus.Subscribe(func(ev events.Event) { cycles = append(cycles, ev.(events.CycleEvent)) }, events.KindCycle)
+	if err := e.RunCycle("005930"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycle events, want 1", len(cycles))
+	}
+	c := cycles[0]
+	if c.Symbol != "005930" || c.Duration != 3*time.Second {
+		t.Errorf("cycle = %+v, want 3s for 005930", c)
+	}
+	want := map[string]time.Duration{events.PhaseData: time.Second, events.PhaseAnalysis: 0, events.PhaseRisk: 0, events.PhaseOrder: 2 * time.Second, events.PhaseStore: 0}
+	for phase, d := range want {
+		if got, ok := c.Phases[phase]; !ok || got != d {
+			t.Errorf("phase %s = %v (timed %v), want %v", phase, got, ok, d)
+		}
+	}
+}
+
+// fixedVolumes serves daily candles of the same volume before today.
+type fixedVolumes float64
+
+func (v fixedVolumes) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	candles := make([]candle.Candle, days)
+	for i := range candles {
+		candles[i] = candle.Candle{Symbol: stockCode, Start: time.Date(2026, 10, 15-days+i, 0, 0, 0, 0, market.KST), Volume: float64(v)}
+	}
+	return candles, nil
+}
+
+func TestOrdersSlicedToLiquidity(t *testing.T) {
+	for _, reject := range []bool{false, true} {
+		clk := clock.NewSimulated(time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST))
+		exch := &fakeExchange{price: "5000"}
+		cfg := &config.Config{Liquidity: config.LiquidityConfig{MaxADVFraction: 0.05, Reject: reject}}
+		e := New(cfg, exch, &fakeStore{}, nil)
+		e.SetClock(clk)
+		e.SetLotSizes(map[string]int{"900100": 10})
+		liquidity := sizing.NewLiquidity(cfg.Liquidity, fixedVolumes(2500))
+		liquidity.Subscribe(e.Bus)
+		e.SetLiquidity(liquidity)
+		var decisions []events.DecisionEvent
+		e.Bus.Subscribe(func(ev events.Event) { decisions = append(decisions, ev.(events.DecisionEvent)) }, events.KindDecision)
+
+		// 5% of 2,500 shares a day is 125, 120 in lots of ten.
+		e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "900100", Amount: 300})
+		e.Submit("tradingview", &models.Signal{Type: models.SellSignal, Pair: "900100", Amount: 300})
+		if reject {
+			if decisions[0].Action != events.ActionRejected || len(exch.placed) != 1 || exch.placed[0].Amount != 120 {
+				t.Fatalf("reject: %s, placed %+v, want the buy refused and a sell of 120", decisions[0].Action, exch.placed)
+			}
+			continue
+		}
+		if len(exch.placed) != 1 || exch.placed[0].Amount != 120 {
+			t.Fatalf("placed %+v, want a buy of 120", exch.placed)
+		}
+		// The buy used up the day's limit.
+		if d := decisions[1]; d.Action != events.ActionHold || d.Checks[len(d.Checks)-1].Name != "liquidity" {
+			t.Errorf("sell: %s, checks %+v", d.Action, d.Checks)
+		}
+
+		clk.Advance(24 * time.Hour)
+		e.Submit("tradingview", &models.Signal{Type: models.SellSignal, Pair: "900100", Amount: 60})
+		if len(exch.placed) != 2 || exch.placed[1].Amount != 60 {
+			t.Errorf("next day placed %+v, want a sell of 60", exch.placed)
+		}
+	}
+}
+
+func TestSectorExposureRefusesBuys(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST))
+	exch := paper.New(10000000, 0, clk)
+	for symbol, price := range map[string]float64{"005930": 70000, "000660": 100000, "035720": 40000} {
+		exch.SetPrice(symbol, price)
+	}
+	cfg := &config.Config{Exposure: config.ExposureConfig{SectorLimits: map[string]float64{"반도체": 0.3}}}
+	e := New(cfg, exch, &fakeStore{}, nil)
+	e.SetClock(clk)
+	e.SetSectors(map[string]string{"005930": "반도체", "000660": "반도체", "035720": "서비스업"})
+	var decisions []events.DecisionEvent
+	e.Bus.Subscribe(func(ev events.Event) { decisions = append(decisions, ev.(events.DecisionEvent)) }, events.KindDecision)
+
+	// ₩2,800,000 of semiconductors on ₩10,000,000 of equity.
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 40})
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "000660", Amount: 10})
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "000660", Amount: 2})
+	e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "035720", Amount: 100})
+	e.Submit("tradingview", &models.Signal{Type: models.SellSignal, Pair: "005930", Amount: 10})
+
+	want := []string{events.ActionOrdered, events.ActionRejected, events.ActionOrdered, events.ActionOrdered, events.ActionOrdered}
+	for i, d := range decisions {
+		if d.Action != want[i] {
+			t.Errorf("decision %d on %s: %s, want %s (checks %+v)", i, d.Symbol, d.Action, want[i], d.Checks)
+		}
+	}
+	if d := decisions[1].Checks[len(decisions[1].Checks)-1]; d.Name != "sector_exposure" || d.Detail != "반도체 at 38.0% of equity after the buy, limit 30.0%" {
+		t.Errorf("check %+v", d)
+	}
+}
+
+// returnCandles serves daily candles up to yesterday of closes compounded
+// from daily returns.
+type returnCandles map[string][]float64
+
+func (r returnCandles) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	returns := r[stockCode]
+	candles := make([]candle.Candle, len(returns)+1)
+	price := 10000.0
+	for i := range candles {
+		if i > 0 {
+			price *= 1 + returns[i-1]
+		}
+		candles[i] = candle.Candle{Symbol: stockCode, Start: time.Date(2026, 10, 15+i-len(returns), 0, 0, 0, 0, market.KST), Close: price}
+	}
+	if len(candles) > days {
+		candles = candles[len(candles)-days:]
+	}
+	return candles, nil
+}
+
+func TestCorrelatedConcentrationLimited(t *testing.T) {
+	semis := make([]float64, 60)
+	internet := make([]float64, 60)
+	for i := range semis {
+		semis[i] = 0.01 * float64(i%5-2)
+		internet[i] = 0.01 * float64(i%3-1)
+	}
+	candles := returnCandles{"005930": semis, "000660": semis, "035720": internet}
+
+	for _, reject := range []bool{true, false} {
+		clk := clock.NewSimulated(time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST))
+		exch := paper.New(10000000, 0, clk)
+		for symbol, price := range map[string]float64{"005930": 70000, "000660": 100000, "035720": 40000} {
+			exch.SetPrice(symbol, price)
+		}
+		cfg := &config.Config{Correlation: config.CorrelationConfig{MaxConcentration: 0.4, Reject: reject}}
+		e := New(cfg, exch, &fakeStore{}, nil)
+		e.SetClock(clk)
+		e.SetCorrelations(correlation.New(cfg.Correlation, candles))
+		var decisions []events.DecisionEvent
+		e.Bus.Subscribe(func(ev events.Event) { decisions = append(decisions, ev.(events.DecisionEvent)) }, events.KindDecision)
+
+		// ₩2,100,000 of 005930 and ₩2,000,000 of 000660, which moves with it,
+		// would be 41% of the equity; 035720 does not.
+		e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 30})
+		e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "000660", Amount: 20})
+		e.Submit("tradingview", &models.Signal{Type: models.BuySignal, Pair: "035720", Amount: 100})
+
+		want := []string{events.ActionOrdered, events.ActionRejected, events.ActionOrdered}
+		if !reject {
+			want[1] = events.ActionOrdered
+		}
+		for i, d := range decisions {
+			if d.Action != want[i] {
+				t.Errorf("reject %v, decision %d on %s: %s, want %s (checks %+v)", reject, i, d.Symbol, d.Action, want[i], d.Checks)
+			}
+		}
+		check := decisions[1].Checks[len(decisions[1].Checks)-1]
+		if wantDetail := "41.0% of equity in 000660 and names correlated by 0.70 or more: 005930 (1.00) after the buy, limit 40.0%"; check.Name != "correlation" || check.Detail != wantDetail {
+			t.Errorf("check %+v, want %q", check, wantDetail)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package events
+
+import (
+	"sync"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/models"
+)
+
+// Kind identifies the type of an event and is the key subscribers register on.
+type Kind string
+
+const (
+	KindMarketData  Kind = "market_data"
+	KindCandle      Kind = "candle"
+	KindSignal      Kind = "signal"
+	KindOrder       Kind = "order"
+	KindFill        Kind = "fill"
+	KindError       Kind = "error"
+	KindDecision    Kind = "decision"
+	KindCircuit     Kind = "circuit"
+	KindScreen      Kind = "screen"
+	KindHalt        Kind = "halt"
+	KindDegradation Kind = "degradation"
+	KindWatchdog    Kind = "watchdog"
+	KindCycle       Kind = "cycle"
+	KindTuning      Kind = "tuning"
+	KindKill        Kind = "kill"
+)
+
+// Event is anything published on the bus.
+type Event interface {
+	Kind() Kind
+}
+
+// MarketDataEvent carries a new price observation for a symbol.
+type MarketDataEvent struct {
+	Symbol string
+	Data   *models.MarketData
+	Time   time.Time
+}
+
+// CandleEvent carries a completed candle of the configured timeframe.
+type CandleEvent struct {
+	Candle candle.Candle
+	Time   time.Time
+}
+
+// SignalEvent carries a trading decision for a symbol, including holds. Source
+// is "strategy" for the configured strategy or names the external origin; only
+// strategy signals carry the market data and indicator values behind them.
+type SignalEvent struct {
+	Symbol     string
+	Signal     *models.Signal
+	Source     string
+	MarketData *models.MarketData
+	Indicators map[string]float64
+	Time       time.Time
+}
+
+// OrderEvent is published after an order was accepted by the exchange.
+type OrderEvent struct {
+	Order  *models.Order
+	Signal *models.Signal
+	Time   time.Time
+}
+
+// FillEvent is published when (part of) an order is executed.
+type FillEvent struct {
+	Order    *models.Order
+	Quantity float64
+	Price    float64
+	Time     time.Time
+}
+
+// ErrorEvent reports a failure in one of the components.
+type ErrorEvent struct {
+	Source string
+	Symbol string
+	Err    error
+	Time   time.Time
+}
+
+// CircuitEvent is published when the exchange circuit breaker changes state,
+// e.g. from "closed" to "open" when order placement is suspended.
+type CircuitEvent struct {
+	From   string
+	To     string
+	Reason string
+	Time   time.Time
+}
+
+// ScreenEvent carries the symbols that passed the stock screener, best first.
+type ScreenEvent struct {
+	Universe string
+	Symbols  []string
+	Time     time.Time
+}
+
+// HaltEvent is published when an order is refused because its symbol is
+// halted or trading at its price limit, or its quote is stale or too wide.
+// Check names the failed risk check.
+type HaltEvent struct {
+	Symbol string
+	Signal *models.Signal
+	Check  string
+	Detail string
+	Time   time.Time
+}
+
+// DegradationEvent is published when the live performance of a strategy over
+// its recent closed trades falls too far below its backtest. Expectancy is the
+// average return per trade in percent. Paused tells whether the strategy was
+// stopped from opening new positions.
+type DegradationEvent struct {
+	Strategy           string
+	Trades             int
+	WinRate            float64
+	Expectancy         float64
+	BaselineWinRate    float64
+	BaselineExpectancy float64
+	Reason             string
+	Paused             bool
+	Time               time.Time
+}
+
+// WatchdogEvent is published when a component stops responding: the market
+// data feed, the exchange token or the main loop. Restarted tells whether the
+// component was restarted.
+type WatchdogEvent struct {
+	Component string
+	Detail    string
+	Restarted bool
+	Time      time.Time
+}
+
+// Phases of a trading cycle timed in a CycleEvent.
+const (
+	PhaseData     = "data"
+	PhaseAnalysis = "analysis"
+	PhaseRisk     = "risk"
+	PhaseOrder    = "order"
+	PhaseStore    = "db"
+)
+
+// CycleEvent is published when the trading cycle of a symbol has finished,
+// with the time spent in each of its phases.
+type CycleEvent struct {
+	Symbol   string
+	Duration time.Duration
+	Phases   map[string]time.Duration
+	Time     time.Time
+}
+
+// TuningEvent is published when new strategy parameters are proposed, and
+// again once they are applied. Profits are those of backtests over the last
+// Days bars of Symbol.
+type TuningEvent struct {
+	Symbol         string
+	Days           int
+	Current        models.MovingAverageConfig
+	Proposed       models.MovingAverageConfig
+	CurrentProfit  float64
+	ProposedProfit float64
+	Applied        bool
+	Time           time.Time
+}
+
+// KillEvent is published when the kill switch stops trading. Source is what
+// triggered it: the file, the API or Telegram. Cancelled and Failed count the
+// open orders cancelled and those that could not be; Err is set when the open
+// orders could not be listed.
+type KillEvent struct {
+	Source    string
+	Reason    string
+	Cancelled int
+	Failed    int
+	Err       error
+	Time      time.Time
+}
+
+// Final actions recorded in a DecisionEvent.
+const (
+	ActionHold     = "hold"
+	ActionRejected = "rejected"
+	ActionOrdered  = "ordered"
+	ActionFailed   = "failed"
+	ActionError    = "error"
+	// ActionRecorded books a trade made outside the bot.
+	ActionRecorded = "recorded"
+	// ActionObserved is a signal that passed every check but was not sent
+	// because the bot only observes; see config.ExchangeConfig.Observe.
+	ActionObserved = "observed"
+)
+
+// RiskCheck is the outcome of a single pre-trade check.
+type RiskCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// DecisionEvent summarizes how one signal, or a cycle that failed before
+// producing one, was handled: its inputs, the risk checks and the final action.
+// It is published once handling is complete.
+type DecisionEvent struct {
+	Symbol     string
+	Source     string
+	MarketData *models.MarketData
+	Indicators map[string]float64
+	Signal     *models.Signal
+	Checks     []RiskCheck
+	Action     string
+	Order      *models.Order
+	Err        error
+	Time       time.Time
+}
+
+func (MarketDataEvent) Kind() Kind  { return KindMarketData }
+func (CandleEvent) Kind() Kind      { return KindCandle }
+func (SignalEvent) Kind() Kind      { return KindSignal }
+func (OrderEvent) Kind() Kind       { return KindOrder }
+func (FillEvent) Kind() Kind        { return KindFill }
+func (ErrorEvent) Kind() Kind       { return KindError }
+func (DecisionEvent) Kind() Kind    { return KindDecision }
+func (CircuitEvent) Kind() Kind     { return KindCircuit }
+func (ScreenEvent) Kind() Kind      { return KindScreen }
+func (HaltEvent) Kind() Kind        { return KindHalt }
+func (DegradationEvent) Kind() Kind { return KindDegradation }
+func (WatchdogEvent) Kind() Kind    { return KindWatchdog }
+func (CycleEvent) Kind() Kind       { return KindCycle }
+func (TuningEvent) Kind() Kind      { return KindTuning }
+func (KillEvent) Kind() Kind        { return KindKill }
+
+// Handler processes an event. Handlers may publish further events.
+type Handler func(Event)
+
+// Bus delivers events to subscribers. Handlers registered with Subscribe run
+// synchronously in registration order, so when Publish returns every event it
+// caused has been fully processed; this keeps a trading cycle deterministic and
+// lets callers know when it is finished.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Kind][]Handler
+	dropped  func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[Kind][]Handler{}}
+}
+
+// Subscribe registers handler for events of the given kinds.
+func (b *Bus) Subscribe(handler Handler, kinds ...Kind) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, kind := range kinds {
+		b.handlers[kind] = append(b.handlers[kind], handler)
+	}
+}
+
+// Channel returns a channel receiving events of the given kinds, for slow
+// consumers such as notifiers that must not hold up trading. Events are dropped
+// when the channel buffer is full.
+func (b *Bus) Channel(buffer int, kinds ...Kind) <-chan Event {
+	ch := make(chan Event, buffer)
+	b.Subscribe(func(e Event) {
+		select {
+		case ch <- e:
+		default:
+			b.mu.RLock()
+			dropped := b.dropped
+			b.mu.RUnlock()
+			if dropped != nil {
+				dropped(e)
+			}
+		}
+	}, kinds...)
+	return ch
+}
+
+// OnDropped sets a callback invoked when a Channel subscriber misses an event.
+func (b *Bus) OnDropped(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dropped = fn
+}
+
+// Publish delivers e to all handlers subscribed to its kind.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	handlers := b.handlers[e.Kind()]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(e)
+	}
+}
@@ -0,0 +1,123 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// maxPooledBuffer is the capacity above which a buffer is dropped instead of
+// going back to the pool, so that one large response, e.g. a long candle
+// history, does not stay allocated for every quote after it.
+const maxPooledBuffer = 1 << 20
+
+// buffers holds the buffers request bodies are encoded into and responses
+// read into, so that polling many symbols does not allocate one per call.
+var buffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		buffers.Put(buf)
+	}
+}
+
+// jsonBody is a request body encoded into a pooled buffer. The transport may
+// still be writing the body after the response has arrived, so the buffer
+// goes back to the pool when the transport closes it, not when Do returns.
+type jsonBody struct {
+	*bytes.Reader
+	buf    *bytes.Buffer
+	closed int32
+}
+
+func (b *jsonBody) Close() error {
+	if atomic.CompareAndSwapInt32(&b.closed, 0, 1) {
+		putBuffer(b.buf)
+	}
+	return nil
+}
+
+// newJSONRequest returns a request with v encoded as its JSON body.
+func newJSONRequest(method, url string, v interface{}) (*http.Request, error) {
+	buf := getBuffer()
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		putBuffer(buf)
+		return nil, fmt.Errorf("failed to marshal request data: %v", err)
+	}
+	body := &jsonBody{Reader: bytes.NewReader(buf.Bytes()), buf: buf}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		putBuffer(buf)
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.ContentLength = int64(buf.Len())
+	req.Header["Content-Type"] = jsonContentType
+	return req, nil
+}
+
+// jsonContentType is shared by every request. Header values are never
+// modified, only replaced, so the same slices can be set on concurrent
+// requests.
+var jsonContentType = []string{"application/json"}
+
+// authHeaders are the headers of authorized requests, built once per token or
+// credentials change. The keys are in canonical form so that they can be
+// copied without going through Header.Set.
+type authHeaders struct {
+	authorization []string
+	appKey        []string
+	appSecret     []string
+}
+
+func newAuthHeaders(token, appKey, appSecret string) authHeaders {
+	return authHeaders{
+		authorization: []string{"Bearer " + token},
+		appKey:        []string{appKey},
+		appSecret:     []string{appSecret},
+	}
+}
+
+// header returns a new header with the authorization headers and room for
+// the tr_id and custtype set by most callers.
+func (h authHeaders) header() http.Header {
+	header := make(http.Header, 6)
+	header["Content-Type"] = jsonContentType
+	header["Authorization"] = h.authorization
+	header["Appkey"] = h.appKey
+	header["Appsecret"] = h.appSecret
+	return header
+}
+
+// do sends an authorized request and decodes the JSON body of a 200 response
+// into out; other responses return an *Error. The body is read into a pooled buffer, which out must not keep a
+// reference to; json.Unmarshal copies strings, so plain structs are fine.
+func (e *KISExchange) do(req *http.Request, what string, out interface{}) error {
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %v", what, err)
+	}
+	defer resp.Body.Close()
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if _, err := io.Copy(buf, resp.Body); err != nil {
+		return fmt.Errorf("failed to read %s response: %v", what, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(what, resp.StatusCode, buf.Bytes())
+	}
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %v", what, err)
+	}
+	return nil
+}
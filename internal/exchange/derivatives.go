@@ -0,0 +1,291 @@
+package exchange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"tradingbot/internal/models"
+)
+
+// derivativesProduct is the account product code (ACNT_PRDT_CD) of futures
+// and options accounts.
+const derivativesProduct = "03"
+
+// derivativesMarket returns the market division (FID_COND_MRKT_DIV_CODE) of a
+// contract: index futures and options, or stock futures and options, whose
+// underlying part of the code is a letter-digit pair rather than 01-09.
+func derivativesMarket(code string) string {
+	kind, _ := models.ContractKindOf(code)
+	stock := len(code) >= 3 && code[1] >= 'A' && code[1] <= 'Z'
+	switch {
+	case kind == models.ContractFuture && stock:
+		return "JF" // 주식선물
+	case kind == models.ContractFuture:
+		return "F" // 지수선물
+	case stock:
+		return "JO" // 주식옵션
+	}
+	return "O" // 지수옵션
+}
+
+func (e *KISExchange) derivativesAccount() string {
+	if e.DerivativesAccountNo != "" {
+		return e.DerivativesAccountNo
+	}
+	return e.AccountNo
+}
+
+// GetDerivativeQuote returns the current quote of a futures or options
+// contract.
+func (e *KISExchange) GetDerivativeQuote(code string) (*models.DerivativeQuote, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-futureoption/v1/quotations/inquire-price", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("tr_id", "FHMIF10000000")
+	req.Header.Set("custtype", "P")
+
+	q := req.URL.Query()
+	q.Add("FID_COND_MRKT_DIV_CODE", derivativesMarket(code))
+	q.Add("FID_INPUT_ISCD", code)
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		Output1 *struct {
+			FutsPrpr       string `json:"futs_prpr"`
+			FutsMxpr       string `json:"futs_mxpr"`
+			FutsLlam       string `json:"futs_llam"`
+			HtsOtstStplQty string `json:"hts_otst_stpl_qty"`
+		} `json:"output1"`
+	}
+	if err := e.do(req, "derivative quote", &result); err != nil {
+		return nil, err
+	}
+	out := result.Output1
+	if out == nil || out.FutsPrpr == "" {
+		return nil, fmt.Errorf("derivative quote not found in response")
+	}
+	quote := &models.DerivativeQuote{Code: code}
+	if quote.Price, err = parseNumber(out.FutsPrpr); err != nil {
+		return nil, fmt.Errorf("invalid price %q for %s", out.FutsPrpr, code)
+	}
+	quote.UpperLimit, _ = parseNumber(out.FutsMxpr)
+	quote.LowerLimit, _ = parseNumber(out.FutsLlam)
+	quote.OpenInterest, _ = parseNumber(out.HtsOtstStplQty)
+	return quote, nil
+}
+
+type kisOptionRow struct {
+	OptnShrnIscd string `json:"optn_shrn_iscd"`
+	Acpr         string `json:"acpr"`
+	OptnPrpr     string `json:"optn_prpr"`
+	DeltaVal     string `json:"delta_val"`
+	HtsIntsVltl  string `json:"hts_ints_vltl"`
+}
+
+func (r kisOptionRow) quote(kind models.ContractKind) models.OptionQuote {
+	q := models.OptionQuote{Code: r.OptnShrnIscd, Kind: kind}
+	q.Strike, _ = parseNumber(r.Acpr)
+	q.Price, _ = parseNumber(r.OptnPrpr)
+	q.Delta, _ = parseNumber(r.DeltaVal)
+	q.ImpliedVol, _ = parseNumber(r.HtsIntsVltl)
+	return q
+}
+
+// GetOptionChain returns the KOSPI200 option chain expiring in maturity,
+// YYYYMM: the calls, then the puts, each by strike.
+func (e *KISExchange) GetOptionChain(maturity string) ([]models.OptionQuote, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-futureoption/v1/quotations/display-board-callput", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("tr_id", "FHPIF05030100")
+	req.Header.Set("custtype", "P")
+
+	q := req.URL.Query()
+	q.Add("FID_COND_MRKT_DIV_CODE", "O")
+	q.Add("FID_COND_SCR_DIV_CODE", "20503")
+	q.Add("FID_MRKT_CLS_CODE", "CO")
+	q.Add("FID_MTRT_CNT", maturity)
+	q.Add("FID_COND_MRKT_CLS_CODE", "")
+	q.Add("FID_MRKT_CLS_CODE1", "PO")
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		Output1 []kisOptionRow `json:"output1"`
+		Output2 []kisOptionRow `json:"output2"`
+	}
+	if err := e.do(req, "option chain", &result); err != nil {
+		return nil, err
+	}
+	chain := make([]models.OptionQuote, 0, len(result.Output1)+len(result.Output2))
+	for _, row := range result.Output1 {
+		chain = append(chain, row.quote(models.ContractCall))
+	}
+	for _, row := range result.Output2 {
+		chain = append(chain, row.quote(models.ContractPut))
+	}
+	return chain, nil
+}
+
+// GetDerivativePositions returns the open futures and options positions.
+func (e *KISExchange) GetDerivativePositions() ([]models.DerivativePosition, error) {
+	positions, _, err := e.derivativesBalance()
+	return positions, err
+}
+
+// GetDerivativesMargin returns the deposit and margin status of the futures
+// and options account.
+func (e *KISExchange) GetDerivativesMargin() (*models.DerivativesMargin, error) {
+	_, margin, err := e.derivativesBalance()
+	return margin, err
+}
+
+func (e *KISExchange) derivativesBalance() ([]models.DerivativePosition, *models.DerivativesMargin, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-futureoption/v1/trading/inquire-balance", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("tr_id", e.trID("CTFO6118R", "VTFO6118R"))
+
+	q := req.URL.Query()
+	q.Add("CANO", e.derivativesAccount())
+	q.Add("ACNT_PRDT_CD", derivativesProduct)
+	q.Add("MGNA_DVSN", "01")   // 개시증거금
+	q.Add("EXCC_STAT_CD", "1") // 정산
+	q.Add("CTX_AREA_FK200", "")
+	q.Add("CTX_AREA_NK200", "")
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		RtCd    string `json:"rt_cd"`
+		MsgCd   string `json:"msg_cd"`
+		Msg1    string `json:"msg1"`
+		Output1 []struct {
+			Pdno         string `json:"pdno"`
+			PrdtName     string `json:"prdt_name"`
+			SllBuyDvsnCd string `json:"sll_buy_dvsn_cd"`
+			CblcQty      string `json:"cblc_qty"`
+			CcldAvgUnpr1 string `json:"ccld_avg_unpr1"`
+			IdxClpr      string `json:"idx_clpr"`
+			EvluPflsAmt  string `json:"evlu_pfls_amt"`
+		} `json:"output1"`
+		Output2 *struct {
+			DncaCash        string `json:"dnca_cash"`
+			TotDnclAmt      string `json:"tot_dncl_amt"`
+			OrdPsblTotaAmt  string `json:"ord_psbl_tota_amt"`
+			BrkgMgnaTotlAmt string `json:"brkg_mgna_totl_amt"`
+			MntnMgnaTotlAmt string `json:"mntn_mgna_totl_amt"`
+		} `json:"output2"`
+	}
+	if err := e.do(req, "derivatives balance", &result); err != nil {
+		return nil, nil, err
+	}
+	if result.RtCd != "" && result.RtCd != "0" {
+		return nil, nil, resultError("derivatives balance", result.MsgCd, result.Msg1)
+	}
+
+	positions := make([]models.DerivativePosition, 0, len(result.Output1))
+	for _, item := range result.Output1 {
+		p := models.DerivativePosition{
+			Code: item.Pdno,
+			Name: strings.TrimSpace(item.PrdtName),
+			Side: models.PositionLong,
+		}
+		p.Kind, _ = models.ContractKindOf(item.Pdno)
+		if item.SllBuyDvsnCd == "01" {
+			p.Side = models.PositionShort
+		}
+		p.Quantity, _ = parseNumber(item.CblcQty)
+		if p.Quantity == 0 {
+			continue
+		}
+		p.AvgPrice, _ = parseNumber(item.CcldAvgUnpr1)
+		p.CurrentPrice, _ = parseNumber(item.IdxClpr)
+		p.ProfitLoss, _ = parseNumber(item.EvluPflsAmt)
+		positions = append(positions, p)
+	}
+
+	margin := &models.DerivativesMargin{}
+	if out := result.Output2; out != nil {
+		margin.Deposit, _ = parseNumber(out.TotDnclAmt)
+		if margin.Deposit == 0 {
+			margin.Deposit, _ = parseNumber(out.DncaCash)
+		}
+		margin.Orderable, _ = parseNumber(out.OrdPsblTotaAmt)
+		margin.Initial, _ = parseNumber(out.BrkgMgnaTotlAmt)
+		margin.Maintenance, _ = parseNumber(out.MntnMgnaTotlAmt)
+	}
+	return positions, margin, nil
+}
+
+// derivativeOrderRequest is the body of a futures or options order.
+type derivativeOrderRequest struct {
+	Processing     string `json:"ORD_PRCS_DVSN_CD"`
+	AccountNo      string `json:"CANO"`
+	Product        string `json:"ACNT_PRDT_CD"`
+	Side           string `json:"SLL_BUY_DVSN_CD"`
+	Code           string `json:"SHTN_PDNO"`
+	Quantity       string `json:"ORD_QTY"`
+	Price          string `json:"UNIT_PRICE"`
+	PriceType      string `json:"NMPR_TYPE_CD"`
+	PriceCondition string `json:"KRX_NMPR_CNDT_CD"`
+	Division       string `json:"ORD_DVSN_CD"`
+}
+
+// PlaceDerivativeOrder places a day order for a futures or options contract,
+// at its price or, without one, at the market.
+func (e *KISExchange) PlaceDerivativeOrder(order models.DerivativeOrder) (string, error) {
+	if err := e.checkOrders(); err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("%s/uapi/domestic-futureoption/v1/trading/order", e.BaseURL)
+
+	side := "02" // 매수
+	if order.Side == models.OrderSideSell {
+		side = "01" // 매도
+	}
+	division, priceType, price := "02", "02", "0" // 시장가
+	if order.Price > 0 {
+		division, priceType = "01", "01" // 지정가
+		price = strconv.FormatFloat(order.Price, 'f', -1, 64)
+	}
+	req, err := e.newAuthorizedRequest("POST", url, derivativeOrderRequest{
+		Processing:     "02",
+		AccountNo:      e.derivativesAccount(),
+		Product:        derivativesProduct,
+		Side:           side,
+		Code:           order.Code,
+		Quantity:       strconv.FormatFloat(order.Quantity, 'f', 0, 64),
+		Price:          price,
+		PriceType:      priceType,
+		PriceCondition: "0", // 없음
+		Division:       division,
+	})
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("tr_id", e.trID("TTTO1101U", "VTTO1101U"))
+
+	var result struct {
+		RtCd   string `json:"rt_cd"`
+		MsgCd  string `json:"msg_cd"`
+		Msg1   string `json:"msg1"`
+		Output struct {
+			Odno string `json:"ODNO"`
+		} `json:"output"`
+	}
+	if err := e.do(req, "derivative order", &result); err != nil {
+		return "", rejectedOrder(err)
+	}
+	if result.RtCd != "0" {
+		return "", rejectedOrder(resultError("derivative order for "+order.Code, result.MsgCd, result.Msg1))
+	}
+	return result.Output.Odno, nil
+}
@@ -0,0 +1,130 @@
+package exchange
+
+import (
+	"fmt"
+	"time"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+// kisCashDividend is the right type (권리유형) of cash dividends in the
+// account's rights history.
+const kisCashDividend = "03"
+
+// GetDividends returns the cash dividends credited to the account with a
+// record date between from and to, read from its rights history (기간별계좌권리현황).
+func (e *KISExchange) GetDividends(from, to time.Time) ([]models.Dividend, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/trading/period-rights", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("tr_id", "CTRGA011R")
+	req.Header.Set("custtype", "P")
+
+	q := req.URL.Query()
+	q.Add("INQR_DVSN", "03") // 조회구분: 기준일
+	q.Add("CUST_RNCNO25", "")
+	q.Add("HMID", "")
+	q.Add("CANO", e.AccountNo)
+	q.Add("ACNT_PRDT_CD", "01")
+	q.Add("INQR_STRT_DT", from.In(market.KST).Format("20060102"))
+	q.Add("INQR_END_DT", to.In(market.KST).Format("20060102"))
+	q.Add("RGHT_TYPE_CD", kisCashDividend)
+	q.Add("PDNO", "")
+	q.Add("PRDT_TYPE_CD", "")
+	q.Add("CTX_AREA_NK100", "")
+	q.Add("CTX_AREA_FK100", "")
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		Output []struct {
+			BassDt       string `json:"bass_dt"`        // 기준일
+			RghtTypeCd   string `json:"rght_type_cd"`   // 권리유형
+			Pdno         string `json:"pdno"`           // 종목코드
+			AcplBassDt   string `json:"acpl_bass_dt"`   // 지급일
+			CashAlctUnpr string `json:"cash_alct_unpr"` // 주당 배당금
+			CblcQty      string `json:"cblc_qty"`       // 배정 기준 잔고수량
+			LastAlctAmt  string `json:"last_alct_amt"`  // 배당금 (세전)
+			WthtAmt      string `json:"wtht_amt"`       // 원천징수세액
+		} `json:"output"`
+	}
+	if err := e.do(req, "dividends", &result); err != nil {
+		return nil, err
+	}
+
+	var dividends []models.Dividend
+	for _, item := range result.Output {
+		if item.RghtTypeCd != kisCashDividend {
+			continue
+		}
+		d := models.Dividend{Symbol: item.Pdno}
+		d.RecordDate, _ = time.ParseInLocation("20060102", item.BassDt, market.KST)
+		d.PayDate, _ = time.ParseInLocation("20060102", item.AcplBassDt, market.KST)
+		if d.PayDate.IsZero() {
+			d.PayDate = d.RecordDate
+		}
+		d.PerShare, _ = parseNumber(item.CashAlctUnpr)
+		d.Quantity, _ = parseNumber(item.CblcQty)
+		d.Amount, _ = parseNumber(item.LastAlctAmt)
+		d.Tax, _ = parseNumber(item.WthtAmt)
+		if d.Amount <= 0 {
+			continue
+		}
+		dividends = append(dividends, d)
+	}
+	return dividends, nil
+}
+
+// GetDividendHistory returns the cash dividends of a stock with a record date
+// between from and to, as published by the Korea Securities Depository
+// (예탁원정보 배당일정).
+func (e *KISExchange) GetDividendHistory(stockCode string, from, to time.Time) ([]models.Dividend, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/ksdinfo/dividend", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("tr_id", "HHKDB669102C0")
+	req.Header.Set("custtype", "P")
+
+	q := req.URL.Query()
+	q.Add("CTS", "")
+	q.Add("GB1", "0") // 전체 (결산, 중간배당)
+	q.Add("F_DT", from.In(market.KST).Format("20060102"))
+	q.Add("T_DT", to.In(market.KST).Format("20060102"))
+	q.Add("SHT_CD", stockCode)
+	q.Add("HIGH_GB", "")
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		Output1 []struct {
+			RecordDate    string `json:"record_date"`
+			ShtCd         string `json:"sht_cd"`
+			PerStoDiviAmt string `json:"per_sto_divi_amt"`
+			DiviPayDt     string `json:"divi_pay_dt"`
+		} `json:"output1"`
+	}
+	if err := e.do(req, "dividend history", &result); err != nil {
+		return nil, err
+	}
+
+	var dividends []models.Dividend
+	for _, item := range result.Output1 {
+		d := models.Dividend{Symbol: item.ShtCd}
+		var err error
+		if d.RecordDate, err = time.ParseInLocation("20060102", item.RecordDate, market.KST); err != nil {
+			continue
+		}
+		// Pay dates come as YYYY/MM/DD and are blank until announced.
+		d.PayDate, _ = time.ParseInLocation("2006/01/02", item.DiviPayDt, market.KST)
+		d.PerShare, _ = parseNumber(item.PerStoDiviAmt)
+		if d.PerShare <= 0 {
+			continue
+		}
+		dividends = append(dividends, d)
+	}
+	return dividends, nil
+}
@@ -0,0 +1,97 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"tradingbot/internal/models"
+)
+
+// Error is a failed KIS request: an HTTP error status, or a response whose
+// rt_cd is not "0". It wraps the models error of the failure when it is one
+// callers handle, so that errors.Is(err, models.ErrRateLimited) and the like
+// work on whatever the client returns.
+type Error struct {
+	// Op is what was requested, e.g. "market data".
+	Op string
+	// Status is the HTTP status code, http.StatusOK for a failure reported
+	// by rt_cd.
+	Status int
+	// Code and Message are the KIS message code and message (msg_cd and
+	// msg1, or error_code and error_description for tokens). Message is the
+	// body when it could not be parsed.
+	Code    string
+	Message string
+	kind    error
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if e.Code != "" {
+		msg = e.Code + " " + msg
+	}
+	if e.Status != http.StatusOK {
+		return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.Status, msg)
+	}
+	return fmt.Sprintf("%s failed: %s", e.Op, msg)
+}
+
+// Unwrap returns the models error of the failure, or nil.
+func (e *Error) Unwrap() error { return e.kind }
+
+// kinds are the models errors of the KIS message codes callers handle.
+var kinds = map[string]error{
+	"EGW00121": models.ErrUnauthorized,      // 유효하지 않은 token
+	"EGW00123": models.ErrUnauthorized,      // 기간이 만료된 token
+	"EGW00133": models.ErrRateLimited,       // 접근토큰 발급 잠시 후 다시 시도 (1분당 1회)
+	"EGW00201": models.ErrRateLimited,       // 초당 거래건수 초과
+	"40570000": models.ErrMarketClosed,      // 모의투자 장시작전
+	"40580000": models.ErrMarketClosed,      // 모의투자 장종료
+	"APBK0952": models.ErrInsufficientFunds, // 주문가능금액 초과
+}
+
+// newError returns the error of a failed request.
+func newError(op string, status int, code, message string) *Error {
+	e := &Error{Op: op, Status: status, Code: code, Message: message}
+	switch kind, ok := kinds[code]; {
+	case ok:
+		e.kind = kind
+	case status == http.StatusUnauthorized:
+		e.kind = models.ErrUnauthorized
+	case status == http.StatusTooManyRequests:
+		e.kind = models.ErrRateLimited
+	}
+	return e
+}
+
+// responseError returns the error of a response with an error status.
+func responseError(op string, status int, body []byte) *Error {
+	var result struct {
+		MsgCd            string `json:"msg_cd"`
+		Msg1             string `json:"msg1"`
+		ErrorCode        string `json:"error_code"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.MsgCd == "" && result.ErrorCode == "" {
+		return newError(op, status, "", string(body))
+	}
+	if result.MsgCd != "" {
+		return newError(op, status, result.MsgCd, result.Msg1)
+	}
+	return newError(op, status, result.ErrorCode, result.ErrorDescription)
+}
+
+// rejectedOrder marks the failure of an order request that is of no known
+// kind as models.ErrOrderRejected, unless the server failed (5xx), which says
+// nothing about the order.
+func rejectedOrder(err error) error {
+	if e, ok := err.(*Error); ok && e.kind == nil && e.Status < http.StatusInternalServerError {
+		e.kind = models.ErrOrderRejected
+	}
+	return err
+}
+
+// resultError returns the error of a 200 response whose rt_cd is not "0".
+func resultError(op, code, message string) *Error {
+	return newError(op, http.StatusOK, code, message)
+}
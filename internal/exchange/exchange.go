@@ -4,43 +4,103 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	neturl "net/url"
+	"sync"
+	"sync/atomic"
 	"time"
+	"tradingbot/internal/clock"
 	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
 	"tradingbot/internal/models"
 
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 )
 
-var log = logrus.New()
+var log = logging.New()
+
+// ErrNotArmed is returned for orders on the live environment before Arm. It
+// wraps models.ErrOrdersDisabled, as do ErrObserving and ErrHalted.
+var ErrNotArmed = fmt.Errorf("live orders are not approved: %w", models.ErrOrdersDisabled)
+
+// ErrObserving is returned for every order and cancellation once Observe is
+// called.
+var ErrObserving = fmt.Errorf("observer mode: %w", models.ErrOrdersDisabled)
+
+// ErrHalted is returned for every order once Halt is called.
+var ErrHalted = fmt.Errorf("orders are halted by the kill switch: %w", models.ErrOrdersDisabled)
 
 const (
 	maxRetries = 3
 	retryDelay = 5 * time.Second
 )
 
+// KISExchange is the client of the KIS Open API. It is safe for concurrent
+// use once its exported fields are set.
 type KISExchange struct {
-	APIKey          string
-	APISecret       string
-	BaseURL         string
-	AuthToken       string
-	AuthTokenExpiry time.Time
-	AccountNo       string
+	BaseURL   string
+	AccountNo string
+	// DerivativesAccountNo is the futures and options account, when it
+	// differs from AccountNo.
+	DerivativesAccountNo string
+	Paper                bool
+	Clock                clock.Clock
+
+	client *http.Client
+
+	// mu guards the credentials and the auth token, which are replaced while
+	// requests are in flight. refresh makes concurrent refreshes wait for
+	// the first one instead of each requesting a token.
+	mu          sync.RWMutex
+	appKey      string
+	appSecret   string
+	authToken   string
+	tokenExpiry time.Time
+	headers     authHeaders
+	refresh     sync.Mutex
+
+	// armed is set by Arm; until then live orders are refused.
+	armed int32
+	// observing is set by Observe; it refuses all orders for good.
+	observing int32
+	// halted is set by Halt; it refuses new orders for good.
+	halted int32
 }
 
 type AuthResponse struct {
 	AccessToken string `json:"access_token"`
+	// ErrorCode and ErrorDescription are set instead when no token is
+	// issued.
+	ErrorCode        string `json:"error_code"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// tokenRequest is the body of a token request.
+type tokenRequest struct {
+	GrantType string `json:"grant_type"`
+	AppKey    string `json:"appkey"`
+	AppSecret string `json:"appsecret"`
 }
 
 func New(cfg config.ExchangeConfig) (*KISExchange, error) {
+	client, err := NewHTTPClient(cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
 	ex := &KISExchange{
-		APIKey:    cfg.APIKey,
-		APISecret: cfg.APISecret,
-		BaseURL:   "https://openapivts.koreainvestment.com:29443",
+		client:    client,
+		appKey:    cfg.AppKey,
+		appSecret: cfg.AppSecret,
+		BaseURL:   cfg.BaseURL,
 		AccountNo: cfg.AccountNo,
+		Paper:     cfg.Mode != config.ModeLive,
+		Clock:     clock.Real,
+	}
+	if cfg.Observe {
+		ex.Observe()
 	}
 
 	if err := ex.refreshAuthToken(); err != nil {
@@ -50,61 +110,124 @@ func New(cfg config.ExchangeConfig) (*KISExchange, error) {
 	return ex, nil
 }
 
+// SetCredentials replaces the app key and secret, e.g. after a secret rotation,
+// and obtains a new auth token with them.
+func (e *KISExchange) SetCredentials(appKey, appSecret string) error {
+	e.mu.Lock()
+	e.appKey, e.appSecret = appKey, appSecret
+	e.tokenExpiry = time.Time{}
+	e.headers = newAuthHeaders(e.authToken, appKey, appSecret)
+	e.mu.Unlock()
+	return e.refreshAuthToken()
+}
+
+// RefreshToken obtains a new auth token when the current one has expired.
+func (e *KISExchange) RefreshToken() error {
+	return e.refreshAuthToken()
+}
+
+// credentials returns the auth token and the app key and secret to send.
+func (e *KISExchange) credentials() (token, appKey, appSecret string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.authToken, e.appKey, e.appSecret
+}
+
 func (e *KISExchange) refreshAuthToken() error {
-	if time.Now().Before(e.AuthTokenExpiry) {
+	e.refresh.Lock()
+	defer e.refresh.Unlock()
+	e.mu.RLock()
+	expiry := e.tokenExpiry
+	e.mu.RUnlock()
+	if e.Clock.Now().Before(expiry) {
 		return nil
 	}
 
+	var lastErr error
 	for retries := 0; retries < maxRetries; retries++ {
 		token, expiry, err := e.getAuthToken()
 		if err == nil {
-			e.AuthToken = token
-			e.AuthTokenExpiry = expiry
+			e.mu.Lock()
+			e.authToken, e.tokenExpiry = token, expiry
+			e.headers = newAuthHeaders(token, e.appKey, e.appSecret)
+			e.mu.Unlock()
 			return nil
 		}
 
-		if strings.Contains(err.Error(), "접근토큰 발급 잠시 후 다시 시도하세요") {
-			time.Sleep(1 * time.Minute) // 1분 대기 후 다시 시도
-		} else {
+		// 토큰은 1분에 한 번만 발급됩니다
+		if !errors.Is(err, models.ErrRateLimited) {
 			return err
 		}
+		lastErr = err
+		e.Clock.Sleep(1 * time.Minute) // 1분 대기 후 다시 시도
 	}
 
-	return fmt.Errorf("failed to refresh auth token after retries")
+	return fmt.Errorf("failed to refresh auth token after retries: %w", lastErr)
 }
 
 func (e *KISExchange) getAuthToken() (string, time.Time, error) {
 	url := fmt.Sprintf("%s/oauth2/tokenP", e.BaseURL)
-	data := map[string]string{
-		"grant_type": "client_credentials",
-		"appkey":     e.APIKey,
-		"appsecret":  e.APISecret,
-	}
+	_, appKey, appSecret := e.credentials()
+	data := tokenRequest{GrantType: "client_credentials", AppKey: appKey, AppSecret: appSecret}
 
-	respBody, err := e.sendRequest("POST", url, data)
-	if err != nil {
-		return "", time.Time{}, fmt.Errorf("failed to get auth token: %v", err)
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", time.Time{}, fmt.Errorf("failed to parse auth token response: %v", err)
+	var result AuthResponse
+	if err := e.sendRequest("POST", url, "auth token", data, &result); err != nil {
+		return "", time.Time{}, err
 	}
 
-	if errorDescription, exists := result["error_description"].(string); exists {
-		return "", time.Time{}, fmt.Errorf("error_description: %s", errorDescription)
+	if result.ErrorDescription != "" {
+		return "", time.Time{}, resultError("auth token", result.ErrorCode, result.ErrorDescription)
 	}
 
-	token, ok := result["access_token"].(string)
-	if !ok {
+	if result.AccessToken == "" {
 		return "", time.Time{}, fmt.Errorf("access token not found in response")
 	}
 
-	expiry := time.Now().Add(1 * time.Hour)
-	return token, expiry, nil
+	expiry := e.Clock.Now().Add(1 * time.Hour)
+	return result.AccessToken, expiry, nil
+}
+
+// Arm lets the exchange send orders on the live environment, once a live run
+// has been confirmed. Paper orders need no arming.
+func (e *KISExchange) Arm() { atomic.StoreInt32(&e.armed, 1) }
+
+// Armed tells whether orders are sent: always in paper mode, after Arm live.
+func (e *KISExchange) Armed() bool { return e.Paper || atomic.LoadInt32(&e.armed) == 1 }
+
+// Observe disables orders and cancellations for the lifetime of the exchange,
+// whether armed or not, as New does for config.ExchangeConfig.Observe. Market
+// data and account queries keep working.
+func (e *KISExchange) Observe() { atomic.StoreInt32(&e.observing, 1) }
+
+// Observing tells whether Observe was called.
+func (e *KISExchange) Observing() bool { return atomic.LoadInt32(&e.observing) == 1 }
+
+// Halt refuses new orders for the lifetime of the exchange, e.g. when the
+// kill switch is triggered. Unlike Observe, open orders can still be
+// cancelled.
+func (e *KISExchange) Halt() { atomic.StoreInt32(&e.halted, 1) }
+
+// Halted tells whether Halt was called.
+func (e *KISExchange) Halted() bool { return atomic.LoadInt32(&e.halted) == 1 }
+
+// checkOrders returns why orders cannot be sent, if they cannot.
+func (e *KISExchange) checkOrders() error {
+	if e.Observing() {
+		return ErrObserving
+	}
+	if e.Halted() {
+		return ErrHalted
+	}
+	if !e.Armed() {
+		return ErrNotArmed
+	}
+	return nil
 }
 
 func (e *KISExchange) PlaceOrder(signal *models.Signal) (*models.Order, error) {
+	if err := e.checkOrders(); err != nil {
+		return nil, err
+	}
 	var err error
 	var order *models.Order
 
@@ -114,15 +237,18 @@ func (e *KISExchange) PlaceOrder(signal *models.Signal) (*models.Order, error) {
 			return order, nil
 		}
 
-		if strings.Contains(err.Error(), "unauthorized request") {
+		if errors.Is(err, models.ErrUnauthorized) {
 			if refreshErr := e.refreshAuthToken(); refreshErr != nil {
-				return nil, fmt.Errorf("failed to refresh auth token: %v", refreshErr)
+				return nil, fmt.Errorf("failed to refresh auth token: %w", refreshErr)
 			}
 			continue
 		}
+		if models.OrderRefused(err) {
+			return nil, err
+		}
 
 		log.WithError(err).Warnf("Failed to place order, retrying in %v...", retryDelay)
-		time.Sleep(retryDelay)
+		e.Clock.Sleep(retryDelay)
 	}
 
 	return nil, errors.Wrap(err, "failed to place order after multiple retries")
@@ -130,27 +256,83 @@ func (e *KISExchange) PlaceOrder(signal *models.Signal) (*models.Order, error) {
 
 func (e *KISExchange) placeOrderInternal(signal *models.Signal) (*models.Order, error) {
 	url := fmt.Sprintf("%s/v1/orders", e.BaseURL)
-	orderData := map[string]interface{}{
-		"pair":       signal.Pair,
-		"amount":     signal.Amount,
-		"side":       signal.Type,
-		"account_no": e.AccountNo,
+	orderData := orderRequest{
+		Pair:      signal.Pair,
+		Amount:    signal.Amount,
+		Side:      signal.Type,
+		AccountNo: e.AccountNo,
+		Division:  orderDivision(signal),
 	}
-
-	respBody, err := e.sendRequest("POST", url, orderData)
-	if err != nil {
-		return nil, err
+	if signal.LimitPrice > 0 {
+		orderData.Type = models.OrderTypeLimit
+		orderData.Price = signal.LimitPrice
+	}
+	if signal.Credit != "" {
+		orderData.CreditType = creditType(signal.Credit, signal.Type)
+		orderData.LoanDate = signal.LoanDate
 	}
 
 	var order models.Order
-	if err := json.Unmarshal(respBody, &order); err != nil {
-		return nil, fmt.Errorf("failed to parse order response: %v", err)
+	if err := e.sendRequest("POST", url, "order", orderData, &order); err != nil {
+		return nil, rejectedOrder(err)
 	}
 
 	order.Status = "placed"
+	// The response does not tell when the order was placed; it is stored with
+	// the time it was sent.
+	if order.Timestamp.IsZero() {
+		order.Timestamp = e.Clock.Now()
+	}
 	return &order, nil
 }
 
+// orderRequest is the body of an order.
+type orderRequest struct {
+	Pair       string            `json:"pair"`
+	Amount     float64           `json:"amount"`
+	Side       models.SignalType `json:"side"`
+	AccountNo  string            `json:"account_no"`
+	Type       models.OrderType  `json:"type,omitempty"`
+	Price      float64           `json:"price,omitempty"`
+	Division   string            `json:"ord_dvsn"`
+	CreditType string            `json:"crdt_type,omitempty"`
+	LoanDate   string            `json:"loan_dt,omitempty"`
+}
+
+// orderDivision returns the KIS order division (ORD_DVSN) for signal. The
+// off-hours sessions have their own divisions and are only accepted during
+// their session.
+func orderDivision(signal *models.Signal) string {
+	switch signal.Session {
+	case config.SessionPreMarket:
+		return "05" // 장전 시간외
+	case config.SessionAfterHoursClose:
+		return "06" // 장후 시간외
+	case config.SessionAfterHoursSingle:
+		return "07" // 시간외 단일가
+	}
+	if signal.LimitPrice > 0 {
+		return "00" // 지정가
+	}
+	return "01" // 시장가
+}
+
+// creditType returns the KIS credit type (CRDT_TYPE) of a credit order: new
+// loans for buys and repayments for sells, financed by the broker or by a
+// securities finance company.
+func creditType(financing string, side models.SignalType) string {
+	distribution := financing == config.CreditDistribution
+	switch {
+	case side == models.BuySignal && distribution:
+		return "23" // 유통융자신규
+	case side == models.BuySignal:
+		return "21" // 자기융자신규
+	case distribution:
+		return "27" // 유통융자상환
+	}
+	return "25" // 자기융자상환
+}
+
 func (e *KISExchange) GetMarketDataWithRetry(pair string) (*models.MarketData, error) {
 	var marketData *models.MarketData
 	var err error
@@ -161,61 +343,145 @@ func (e *KISExchange) GetMarketDataWithRetry(pair string) (*models.MarketData, e
 			return marketData, nil
 		}
 
-		if strings.Contains(err.Error(), "unauthorized request") {
+		if errors.Is(err, models.ErrUnauthorized) {
 			if refreshErr := e.refreshAuthToken(); refreshErr != nil {
-				return nil, fmt.Errorf("failed to refresh auth token: %v", refreshErr)
+				return nil, fmt.Errorf("failed to refresh auth token: %w", refreshErr)
 			}
 			continue
 		}
 
 		log.WithError(err).Warnf("Failed to get market data, retrying in %v...", retryDelay)
-		time.Sleep(retryDelay)
+		e.Clock.Sleep(retryDelay)
 	}
 	return nil, errors.Wrap(err, "failed to get market data after multiple retries")
 }
 
 func (e *KISExchange) GetMarketData(stockCode string) (*models.MarketData, error) {
-	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/quotations/inquire-price", e.BaseURL)
+	// The query is appended by hand: going through url.Values allocates a
+	// map and sorts its keys on every quote.
+	url := e.BaseURL + "/uapi/domestic-stock/v1/quotations/inquire-price?fid_cond_mrkt_div_code=J&fid_input_iscd=" + neturl.QueryEscape(stockCode)
 
 	req, err := e.newAuthorizedRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	var result quoteResponse
+	if err := e.do(req, "market data", &result); err != nil {
+		return nil, err
+	}
+	out := result.Output
+	if out == nil || out.StckPrpr == "" {
+		return nil, fmt.Errorf("market data not found in response")
+	}
+	// The prices are handed on as strings, which every consumer parses with
+	// strconv; they are checked and normalized once here.
+	if _, err := parseNumber(out.StckPrpr); err != nil {
+		return nil, fmt.Errorf("invalid price %q for %s", out.StckPrpr, stockCode)
+	}
+	return &models.MarketData{
+		StckPrpr:   normalizeNumber(out.StckPrpr),
+		StckMxpr:   normalizeNumber(out.StckMxpr),
+		StckLlam:   normalizeNumber(out.StckLlam),
+		TempStopYn: out.TempStopYn,
+		TrhtYn:     out.TrhtYn,
+	}, nil
+}
+
+// quoteResponse is the part of the inquire-price response the bot uses. The
+// output also has the day's open, high and low, which are left out as
+// MarketData only has them for candles.
+type quoteResponse struct {
+	Output *struct {
+		StckPrpr   string `json:"stck_prpr"`
+		StckMxpr   string `json:"stck_mxpr"`
+		StckLlam   string `json:"stck_llam"`
+		TempStopYn string `json:"temp_stop_yn"`
+		TrhtYn     string `json:"trht_yn"`
+	} `json:"output"`
+}
+
+// GetNAV returns the indicative NAV of an ETF, or the indicative value of an
+// ETN, as published during the session.
+func (e *KISExchange) GetNAV(stockCode string) (float64, error) {
+	url := fmt.Sprintf("%s/uapi/etfetn/v1/quotations/inquire-price", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("tr_id", "FHPST02400000")
+	req.Header.Set("custtype", "P")
+
 	q := req.URL.Query()
 	q.Add("fid_cond_mrkt_div_code", "J")
 	q.Add("fid_input_iscd", stockCode)
 	req.URL.RawQuery = q.Encode()
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get market data: %v", err)
+	var result struct {
+		Output *struct {
+			Nav string `json:"nav"`
+		} `json:"output"`
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get market data, status code: %d", resp.StatusCode)
+	if err := e.do(req, "NAV", &result); err != nil {
+		return 0, err
+	}
+	if result.Output == nil {
+		return 0, fmt.Errorf("NAV not found in response")
+	}
+	nav, err := parseNumber(result.Output.Nav)
+	if err != nil || nav <= 0 {
+		return 0, fmt.Errorf("invalid NAV %q for %s", result.Output.Nav, stockCode)
 	}
+	return nav, nil
+}
+
+// GetAskingPrice returns the best ask and bid of stockCode and the time of
+// the order book (호가 접수 시간). The current price has no time of its own,
+// so this is how old a quote is.
+func (e *KISExchange) GetAskingPrice(stockCode string) (models.AskingPrice, error) {
+	url := e.BaseURL + "/uapi/domestic-stock/v1/quotations/inquire-asking-price-exp-ccn?fid_cond_mrkt_div_code=J&fid_input_iscd=" + neturl.QueryEscape(stockCode)
 
-	respBody, err := ioutil.ReadAll(resp.Body)
+	req, err := e.newAuthorizedRequest("GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read market data response: %v", err)
+		return models.AskingPrice{}, err
 	}
+	req.Header.Set("tr_id", "FHKST01010200")
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse market data response: %v", err)
+	var result struct {
+		Output1 *struct {
+			AsprAcptHour string `json:"aspr_acpt_hour"`
+			Askp1        string `json:"askp1"`
+			Bidp1        string `json:"bidp1"`
+		} `json:"output1"`
 	}
-
-	var marketData models.MarketData
-	if data, ok := result["output"].(map[string]interface{}); ok {
-		marketData.StckPrpr = data["stck_prpr"].(string)
-	} else {
-		return nil, fmt.Errorf("market data not found in response")
+	if err := e.do(req, "asking price", &result); err != nil {
+		return models.AskingPrice{}, err
 	}
-
-	return &marketData, nil
+	out := result.Output1
+	if out == nil {
+		return models.AskingPrice{}, fmt.Errorf("asking price not found in response")
+	}
+	ask, err := parseNumber(out.Askp1)
+	if err != nil {
+		return models.AskingPrice{}, fmt.Errorf("invalid ask %q for %s", out.Askp1, stockCode)
+	}
+	bid, err := parseNumber(out.Bidp1)
+	if err != nil {
+		return models.AskingPrice{}, fmt.Errorf("invalid bid %q for %s", out.Bidp1, stockCode)
+	}
+	hour, err := time.Parse("150405", out.AsprAcptHour)
+	if err != nil {
+		return models.AskingPrice{}, fmt.Errorf("invalid asking price time %q for %s", out.AsprAcptHour, stockCode)
+	}
+	// The time has no date: it is of today, or of yesterday when that puts
+	// it in the future, e.g. just after midnight.
+	now := e.Clock.Now().In(market.KST)
+	at := time.Date(now.Year(), now.Month(), now.Day(), hour.Hour(), hour.Minute(), hour.Second(), 0, market.KST)
+	if at.After(now) {
+		at = at.AddDate(0, 0, -1)
+	}
+	return models.AskingPrice{Ask: ask, Bid: bid, Time: at}, nil
 }
 
 func (e *KISExchange) GetSamsungPrice() (*models.MarketData, error) {
@@ -235,39 +501,84 @@ func (e *KISExchange) GetBalance() (string, error) {
 	q.Add("ACNT_PRDT_CD", "01")
 	req.URL.RawQuery = q.Encode()
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to get balance: %v", err)
+	var result struct {
+		Output2 []struct {
+			DnclAmt string `json:"dncl_amt"`
+		} `json:"output2"`
+	}
+	if err := e.do(req, "balance", &result); err != nil {
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get balance, status code: %d", resp.StatusCode)
+	if len(result.Output2) > 0 && result.Output2[0].DnclAmt != "" {
+		return result.Output2[0].DnclAmt, nil
 	}
 
-	respBody, err := ioutil.ReadAll(resp.Body)
+	return "", fmt.Errorf("balance information not found in response")
+}
+
+// GetPositions returns the stocks currently held in the account.
+func (e *KISExchange) GetPositions() ([]models.Position, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/trading/inquire-balance", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to read balance response: %v", err)
+		return nil, err
 	}
+	req.Header.Set("tr_id", e.trID("TTTC8434R", "VTTC8434R"))
+
+	q := req.URL.Query()
+	q.Add("CANO", e.AccountNo)
+	q.Add("ACNT_PRDT_CD", "01")
+	q.Add("AFHR_FLPR_YN", "N")
+	q.Add("OFL_YN", "")
+	q.Add("INQR_DVSN", "02")
+	q.Add("UNPR_DVSN", "01")
+	q.Add("FUND_STTL_ICLD_YN", "N")
+	q.Add("FNCG_AMT_AUTO_RDPT_YN", "N")
+	q.Add("PRCS_DVSN", "00")
+	q.Add("CTX_AREA_FK100", "")
+	q.Add("CTX_AREA_NK100", "")
+	req.URL.RawQuery = q.Encode()
 
-	var balanceData map[string]interface{}
-	if err := json.Unmarshal(respBody, &balanceData); err != nil {
-		return "", fmt.Errorf("failed to parse balance response: %v", err)
+	var result struct {
+		Output1 []struct {
+			Pdno        string `json:"pdno"`
+			PrdtName    string `json:"prdt_name"`
+			HldgQty     string `json:"hldg_qty"`
+			PchsAvgPric string `json:"pchs_avg_pric"`
+			Prpr        string `json:"prpr"`
+			EvluPflsAmt string `json:"evlu_pfls_amt"`
+			LoanAmt     string `json:"loan_amt"`
+			LoanDt      string `json:"loan_dt"`
+		} `json:"output1"`
+	}
+	if err := e.do(req, "positions", &result); err != nil {
+		return nil, err
 	}
 
-	if output2, ok := balanceData["output2"].([]interface{}); ok && len(output2) > 0 {
-		if dnclAmt, ok := output2[0].(map[string]interface{})["dncl_amt"].(string); ok {
-			return dnclAmt, nil
+	positions := make([]models.Position, 0, len(result.Output1))
+	for _, item := range result.Output1 {
+		position := models.Position{StockCode: item.Pdno, Name: item.PrdtName}
+		position.Quantity, _ = parseNumber(item.HldgQty)
+		position.AvgPrice, _ = parseNumber(item.PchsAvgPric)
+		position.CurrentPrice, _ = parseNumber(item.Prpr)
+		position.ProfitLoss, _ = parseNumber(item.EvluPflsAmt)
+		position.Loan, _ = parseNumber(item.LoanAmt)
+		if position.Loan > 0 {
+			position.LoanDate = item.LoanDt
 		}
+		if position.Quantity == 0 {
+			continue
+		}
+		positions = append(positions, position)
 	}
-
-	return "", fmt.Errorf("balance information not found in response")
+	return positions, nil
 }
 
 func (e *KISExchange) GetHistoricalData(stockCode string, days int) ([]models.MarketData, error) {
 	var historicalData []models.MarketData
-	end := time.Now()
+	end := e.Clock.Now()
 	start := end.AddDate(0, 0, -days)
 
 	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/quotations/inquire-daily-price", e.BaseURL)
@@ -278,10 +589,11 @@ func (e *KISExchange) GetHistoricalData(stockCode string, days int) ([]models.Ma
 		return nil, err
 	}
 
+	token, appKey, appSecret := e.credentials()
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", e.AuthToken))
-	req.Header.Set("appkey", e.APIKey)
-	req.Header.Set("appsecret", e.APISecret)
+	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("appkey", appKey)
+	req.Header.Set("appsecret", appSecret)
 	req.Header.Set("tr_id", "FHKST01010400")
 
 	q := req.URL.Query()
@@ -293,8 +605,7 @@ func (e *KISExchange) GetHistoricalData(stockCode string, days int) ([]models.Ma
 	q.Add("EN_DT", end.Format("20060102"))   // 종료일 (YYYYMMDD 형식)
 	req.URL.RawQuery = q.Encode()
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := e.client.Do(req)
 	if err != nil {
 		log.WithError(err).Error("Failed to get historical data from API")
 		return nil, err
@@ -307,28 +618,23 @@ func (e *KISExchange) GetHistoricalData(stockCode string, days int) ([]models.Ma
 		return nil, err
 	}
 
-	// 응답 본문 디버깅
-	var result map[string]interface{}
+	var result candlesResponse
 	if err := json.Unmarshal(body, &result); err != nil {
 		log.WithError(err).Error("Failed to unmarshal response body")
 		return nil, err
 	}
 
-	output, ok := result["output"].([]interface{})
-	if !ok {
+	if result.Output == nil {
 		log.Error("Unexpected response format: 'output' field not found")
 		return nil, fmt.Errorf("unexpected response format")
 	}
 
-	for _, item := range output {
-		data, ok := item.(map[string]interface{})
-		if !ok {
-			log.Warn("Unexpected item format in output")
-			continue
-		}
-
+	for _, item := range *result.Output {
 		marketData := models.MarketData{
-			StckPrpr: data["stck_clpr"].(string), // 종가 사용
+			StckPrpr: item.StckClpr, // 종가 사용
+			StckOprc: item.StckOprc,
+			StckHgpr: item.StckHgpr,
+			StckLwpr: item.StckLwpr,
 		}
 
 		historicalData = append(historicalData, marketData)
@@ -340,6 +646,17 @@ func (e *KISExchange) GetHistoricalData(stockCode string, days int) ([]models.Ma
 	return historicalData, nil
 }
 
+// candlesResponse is the response of the daily and minute price queries.
+// Output is a pointer to tell a missing output from an empty one.
+type candlesResponse struct {
+	Output *[]struct {
+		StckClpr string `json:"stck_clpr"`
+		StckOprc string `json:"stck_oprc"`
+		StckHgpr string `json:"stck_hgpr"`
+		StckLwpr string `json:"stck_lwpr"`
+	} `json:"output"`
+}
+
 func (e *KISExchange) GetMinuteData(stockCode string) ([]models.MarketData, error) {
 	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/quotations/inquire-time-itemchartprice", e.BaseURL)
 
@@ -349,10 +666,11 @@ func (e *KISExchange) GetMinuteData(stockCode string) ([]models.MarketData, erro
 		return nil, err
 	}
 
+	token, appKey, appSecret := e.credentials()
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", e.AuthToken))
-	req.Header.Set("appkey", e.APIKey)
-	req.Header.Set("appsecret", e.APISecret)
+	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("appkey", appKey)
+	req.Header.Set("appsecret", appSecret)
 	req.Header.Set("tr_id", "FHKST01010400") // API 엔드포인트에 따라 이 값이 달라질 수 있습니다.
 
 	q := req.URL.Query()
@@ -361,12 +679,10 @@ func (e *KISExchange) GetMinuteData(stockCode string) ([]models.MarketData, erro
 	q.Add("FID_PERIOD_DIV_CODE", "M1") // 1분봉 데이터 요청
 	req.URL.RawQuery = q.Encode()
 
-	// 요청한 URL과 헤더를 로그로 출력
+	// 요청한 URL을 로그로 출력 (인증 헤더는 남기지 않음)
 	log.Infof("Requesting minute data with URL: %s", req.URL.String())
-	log.Infof("Request headers: Authorization: %s, AppKey: %s, AppSecret: %s", e.AuthToken, e.APIKey, e.APISecret)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := e.client.Do(req)
 	if err != nil {
 		log.WithError(err).Error("Failed to get minute data from API")
 		return nil, err
@@ -387,14 +703,13 @@ func (e *KISExchange) GetMinuteData(stockCode string) ([]models.MarketData, erro
 
 	log.Infof("Minute data response body: %s", string(body))
 
-	var result map[string]interface{}
+	var result candlesResponse
 	if err := json.Unmarshal(body, &result); err != nil {
 		log.WithError(err).Error("Failed to unmarshal response body")
 		return nil, err
 	}
 
-	output, ok := result["output"].([]interface{})
-	if !ok {
+	if result.Output == nil {
 		log.Error("Unexpected response format: 'output' field not found")
 		return nil, fmt.Errorf("unexpected response format")
 	}
@@ -404,16 +719,10 @@ func (e *KISExchange) GetMinuteData(stockCode string) ([]models.MarketData, erro
 		return nil, fmt.Errorf("failed to get minute data, status code: %d", resp.StatusCode)
 	}
 
-	var minuteData []models.MarketData
-	for _, item := range output {
-		data, ok := item.(map[string]interface{})
-		if !ok {
-			log.Warn("Unexpected item format in output")
-			continue
-		}
-
+	minuteData := make([]models.MarketData, 0, len(*result.Output))
+	for _, item := range *result.Output {
 		minuteData = append(minuteData, models.MarketData{
-			StckPrpr: data["stck_clpr"].(string), // 종가 사용
+			StckPrpr: item.StckClpr, // 종가 사용
 		})
 	}
 
@@ -422,65 +731,60 @@ func (e *KISExchange) GetMinuteData(stockCode string) ([]models.MarketData, erro
 	return minuteData, nil
 }
 
-func (e *KISExchange) sendRequest(method, url string, data interface{}) ([]byte, error) {
-	var reqBody []byte
-	var err error
-
-	if data != nil {
-		reqBody, err = json.Marshal(data)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request data: %v", err)
-		}
-	}
-
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
+func (e *KISExchange) sendRequest(method, url, what string, data, out interface{}) error {
+	req, err := newJSONRequest(method, url, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", e.AuthToken))
+	token, _, _ := e.credentials()
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := e.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send HTTP request: %v", err)
+		return fmt.Errorf("failed to send HTTP request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if _, err := io.Copy(buf, resp.Body); err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d, body: %s", resp.StatusCode, string(respBody))
+		return responseError(what, resp.StatusCode, buf.Bytes())
 	}
 
-	return respBody, nil
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	return nil
 }
 
-func (e *KISExchange) newAuthorizedRequest(method, url string, body []byte) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+// newAuthorizedRequest returns a request with the auth headers and, unless
+// body is nil, body encoded as JSON.
+func (e *KISExchange) newAuthorizedRequest(method, url string, body interface{}) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = newJSONRequest(method, url, body)
+	} else if req, err = http.NewRequest(method, url, nil); err != nil {
+		err = fmt.Errorf("failed to create HTTP request: %v", err)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", e.AuthToken))
-	req.Header.Set("appKey", e.APIKey)
-	req.Header.Set("appSecret", e.APISecret)
-
+	e.mu.RLock()
+	req.Header = e.headers.header()
+	e.mu.RUnlock()
 	return req, nil
 }
 
-func GetAccessToken(appKey, appSecret string) (string, error) {
-	url := "https://openapi.koreainvestment.com:9443/oauth2/tokenP"
+func GetAccessToken(cfg config.ExchangeConfig) (string, error) {
+	url := fmt.Sprintf("%s/oauth2/tokenP", cfg.BaseURL)
 
-	data := map[string]string{
-		"grant_type": "client_credentials",
-		"appkey":     appKey,
-		"appsecret":  appSecret,
-	}
+	data := tokenRequest{GrantType: "client_credentials", AppKey: cfg.AppKey, AppSecret: cfg.AppSecret}
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -494,7 +798,10 @@ func GetAccessToken(appKey, appSecret string) (string, error) {
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client, err := NewHTTPClient(cfg.HTTP)
+	if err != nil {
+		return "", err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request: %v", err)
@@ -507,7 +814,7 @@ func GetAccessToken(appKey, appSecret string) (string, error) {
 	}
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
+		return "", responseError("access token", resp.StatusCode, body)
 	}
 
 	var authResponse AuthResponse
@@ -0,0 +1,350 @@
+package exchange
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+)
+
+// newTestServer serves auth tokens, numbered in the order they are issued,
+// and quotes to requests carrying one of them.
+func newTestServer(t *testing.T) (*httptest.Server, *int32) {
+	var issued int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/tokenP", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&issued, 1)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": fmt.Sprintf("token-%d", n)})
+	})
+	mux.HandleFunc("/uapi/domestic-stock/v1/quotations/inquire-price", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-") || r.Header.Get("appKey") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"output": map[string]string{"stck_prpr": "70000"}})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &issued
+}
+
+// TestConcurrentUse is meant to be run with -race: quotes are requested while
+// the credentials are rotated and the token refreshed.
+func TestConcurrentUse(t *testing.T) {
+	srv, _ := newTestServer(t)
+	e, err := New(config.ExchangeConfig{BaseURL: srv.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	e.Clock = clk
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := e.GetMarketData("005930"); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 5; j++ {
+			if err := e.SetCredentials(fmt.Sprintf("key-%d", j), "secret"); err != nil {
+				errs <- err
+				return
+			}
+			clk.Advance(2 * time.Hour)
+			if err := e.RefreshToken(); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConcurrentRefreshRequestsOneToken(t *testing.T) {
+	srv, issued := newTestServer(t)
+	e, err := New(config.ExchangeConfig{BaseURL: srv.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewSimulated(time.Now().Add(2 * time.Hour))
+	e.Clock = clk
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := e.RefreshToken(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(issued); n != 2 {
+		t.Errorf("issued %d tokens, want 2: one at start and one for the expiry", n)
+	}
+	if token, _, _ := e.credentials(); token != "token-2" {
+		t.Errorf("token = %q, want token-2", token)
+	}
+}
+
+// TestPooledBodies is meant to be run with -race: concurrent cancellations
+// must each send their own body although the buffers are reused.
+func TestPooledBodies(t *testing.T) {
+	srv, _ := newTestServer(t)
+	var mu sync.Mutex
+	received := map[string]string{}
+	mux := srv.Config.Handler.(*http.ServeMux)
+	mux.HandleFunc("/uapi/domestic-stock/v1/trading/order-rvsecncl", func(w http.ResponseWriter, r *http.Request) {
+		var body cancelOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received[body.OrderNo] = body.BranchNo
+		mu.Unlock()
+		fmt.Fprint(w, `{"rt_cd":"0"}`)
+	})
+	e, err := New(config.ExchangeConfig{BaseURL: srv.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order := models.OpenOrder{OrderNo: fmt.Sprint(i), BranchNo: fmt.Sprint("branch-", i)}
+			if err := e.CancelOrder(order); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(received) != 50 {
+		t.Fatalf("received %d cancellations, want 50", len(received))
+	}
+	for orderNo, branchNo := range received {
+		if branchNo != "branch-"+orderNo {
+			t.Errorf("order %s was sent with branch %s", orderNo, branchNo)
+		}
+	}
+}
+
+func TestErrorsWrapDomainErrors(t *testing.T) {
+	var orders int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/tokenP":
+			fmt.Fprint(w, `{"access_token":"token"}`)
+		case "/v1/orders":
+			w.WriteHeader(http.StatusBadRequest)
+			if atomic.AddInt32(&orders, 1) == 1 {
+				fmt.Fprint(w, `{"rt_cd":"1","msg_cd":"APBK0952","msg1":"주문가능금액을 초과 했습니다"}`)
+			} else {
+				fmt.Fprint(w, `{"rt_cd":"1","msg_cd":"APBK9999","msg1":"주문이 거부되었습니다"}`)
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"rt_cd":"1","msg_cd":"EGW00201","msg1":"초당 거래건수를 초과하였습니다."}`)
+		}
+	}))
+	defer srv.Close()
+	e, err := New(config.ExchangeConfig{BaseURL: srv.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = e.GetMarketData("005930")
+	var kisErr *Error
+	if !errors.Is(err, models.ErrRateLimited) || !errors.As(err, &kisErr) || kisErr.Code != "EGW00201" || kisErr.Status != http.StatusInternalServerError {
+		t.Errorf("quote error %v, want the rate limit of EGW00201", err)
+	}
+
+	signal := &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1}
+	if _, err := e.PlaceOrder(signal); !errors.Is(err, models.ErrInsufficientFunds) || !models.OrderRefused(err) {
+		t.Errorf("order error %v, want insufficient funds", err)
+	}
+	if _, err := e.PlaceOrder(signal); !errors.Is(err, models.ErrOrderRejected) {
+		t.Errorf("order error %v, want a rejection", err)
+	}
+	// Refused orders are not sent again.
+	if n := atomic.LoadInt32(&orders); n != 2 {
+		t.Errorf("sent %d orders, want 2", n)
+	}
+}
+
+func TestOpenOrdersAreCancelled(t *testing.T) {
+	var mu sync.Mutex
+	var cancels []cancelOrderRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/tokenP":
+			fmt.Fprint(w, `{"access_token":"token"}`)
+		case "/uapi/domestic-stock/v1/trading/inquire-psbl-rvsecncl":
+			if r.Header.Get("tr_id") != "VTTC8036R" || r.URL.Query().Get("CANO") != "50000000" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			http.ServeFile(w, r, filepath.Join("testdata", "kis", "open_orders", "ok.json"))
+		case "/uapi/domestic-stock/v1/trading/order-rvsecncl":
+			var body cancelOrderRequest
+			if r.Header.Get("tr_id") != "VTTC0803U" || json.NewDecoder(r.Body).Decode(&body) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			cancels = append(cancels, body)
+			mu.Unlock()
+			if body.OrderNo == "0000117112" {
+				fmt.Fprint(w, `{"rt_cd":"1","msg_cd":"APBK0917","msg1":"정정/취소할 수량이 없습니다"}`)
+				return
+			}
+			fmt.Fprint(w, `{"rt_cd":"0","msg_cd":"APBK0013","msg1":"주문 전송 완료 되었습니다."}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	e, err := New(config.ExchangeConfig{BaseURL: srv.URL, AppKey: "key", AppSecret: "secret", AccountNo: "50000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := e.GetOpenOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.OpenOrder{
+		{OrderNo: "0000117057", BranchNo: "06010", StockCode: "005930", Side: models.OrderSideBuy, Quantity: 10, RemainingQty: 6, Price: 70000},
+		{OrderNo: "0000117112", BranchNo: "06010", StockCode: "000660", Side: models.OrderSideSell, Quantity: 2, RemainingQty: 2, Price: 185000},
+	}
+	if len(orders) != len(want) {
+		t.Fatalf("open orders %+v, want %+v", orders, want)
+	}
+	for i := range want {
+		if orders[i] != want[i] {
+			t.Errorf("open order %d = %+v, want %+v", i, orders[i], want[i])
+		}
+	}
+
+	if err := e.CancelOrder(orders[0]); err != nil {
+		t.Errorf("cancel: %v", err)
+	}
+	if err := e.CancelOrder(orders[1]); err == nil || !strings.Contains(err.Error(), "APBK0917") {
+		t.Errorf("cancel of a filled order: %v, want the KIS refusal", err)
+	}
+	mu.Lock()
+	sent := cancels
+	mu.Unlock()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d cancels, want 2", len(sent))
+	}
+	if c := sent[0]; c.AccountNo != "50000000" || c.BranchNo != "06010" || c.OrderNo != "0000117057" || c.Action != "02" || c.AllQuantity != "Y" {
+		t.Errorf("cancel request %+v", c)
+	}
+
+	e.Observe()
+	if err := e.CancelOrder(orders[0]); !errors.Is(err, ErrObserving) {
+		t.Errorf("cancel while observing: %v, want ErrObserving", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(cancels) != 2 {
+		t.Errorf("sent %d cancels while observing, want none", len(cancels)-2)
+	}
+}
+
+// BenchmarkGetMarketData measures the quote polling hot path, request and
+// response handling included, against a local server.
+func BenchmarkGetMarketData(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/tokenP" {
+			fmt.Fprint(w, `{"access_token":"token"}`)
+			return
+		}
+		fmt.Fprint(w, `{"rt_cd":"0","output":{"stck_prpr":"70000","stck_oprc":"69500","stck_hgpr":"70500","stck_lwpr":"69000","stck_mxpr":"91000","stck_llam":"49000","temp_stop_yn":"N","trht_yn":"N"}}`)
+	}))
+	defer srv.Close()
+	e, err := New(config.ExchangeConfig{BaseURL: srv.URL, AppKey: "key", AppSecret: "secret"})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := e.GetMarketData("005930"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func TestHTTPClientTrustsCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.UserAgent())
+	}))
+	defer srv.Close()
+
+	client, err := NewHTTPClient(config.HTTPConfig{UserAgent: "tradingbot/1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("trusted the test server without its CA")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err = NewHTTPClient(config.HTTPConfig{UserAgent: "tradingbot/1.0", CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "tradingbot/1.0" {
+		t.Errorf("user agent = %q, want tradingbot/1.0", body)
+	}
+
+	if _, err := NewHTTPClient(config.HTTPConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("accepted a missing CA file")
+	}
+}
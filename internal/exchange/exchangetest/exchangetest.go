@@ -0,0 +1,299 @@
+// Package exchangetest provides a fake KIS Open API server for tests of the
+// exchange client and of the bot as a whole.
+package exchangetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+// Token is the access token the server issues and expects.
+const Token = "test-token"
+
+// Order is an order the server accepted.
+type Order struct {
+	Pair      string
+	Side      models.SignalType
+	Amount    float64
+	Price     float64
+	AccountNo string
+}
+
+// Server is a fake KIS server holding one account. It serves tokens, quotes,
+// asking prices, the cash balance, positions and open orders, and fills market orders at
+// once at the current price. Orders exceeding the cash or the position are
+// refused with the message code of KIS. Server is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	prices    map[string]float64
+	books     map[string]models.AskingPrice
+	cash      float64
+	positions map[string]*position
+	orders    []Order
+	requests  map[string]int
+}
+
+type position struct {
+	quantity float64
+	avgPrice float64
+}
+
+// NewServer starts a server with an account holding cash. Close it when done.
+func NewServer(cash float64) *Server {
+	s := &Server{
+		prices:    map[string]float64{},
+		books:     map[string]models.AskingPrice{},
+		cash:      cash,
+		positions: map[string]*position{},
+		requests:  map[string]int{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/tokenP", s.token)
+	mux.HandleFunc("/uapi/domestic-stock/v1/quotations/inquire-price", s.authorized(s.quote))
+	mux.HandleFunc("/uapi/domestic-stock/v1/quotations/inquire-asking-price-exp-ccn", s.authorized(s.askingPrice))
+	mux.HandleFunc("/v1/orders", s.authorized(s.order))
+	mux.HandleFunc("/uapi/domestic-stock/v1/trading/inquire-account-balance", s.authorized(s.balance))
+	mux.HandleFunc("/uapi/domestic-stock/v1/trading/inquire-balance", s.authorized(s.holdings))
+	mux.HandleFunc("/uapi/domestic-stock/v1/trading/inquire-psbl-rvsecncl", s.authorized(s.openOrders))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetPrice sets the current price of symbol. Symbols without a price are
+// unknown to the server.
+func (s *Server) SetPrice(symbol string, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices[symbol] = price
+}
+
+// SetAskingPrice sets the best ask and bid of symbol and the time of its
+// order book. Without it, both are the current price as of the request.
+func (s *Server) SetAskingPrice(symbol string, book models.AskingPrice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.books[symbol] = book
+}
+
+// Orders returns the orders accepted so far, oldest first.
+func (s *Server) Orders() []Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Order(nil), s.orders...)
+}
+
+// Cash returns the cash balance of the account.
+func (s *Server) Cash() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cash
+}
+
+// Position returns the quantity of symbol held.
+func (s *Server) Position(symbol string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.positions[symbol]; ok {
+		return p.quantity
+	}
+	return 0
+}
+
+// Requests returns how many requests were made to path.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	s.count(r)
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": Token, "token_type": "Bearer"})
+}
+
+// authorized refuses requests without the token, as KIS does with EGW00123.
+func (s *Server) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.count(r)
+		if r.Header.Get("Authorization") != "Bearer "+Token {
+			writeError(w, http.StatusUnauthorized, "EGW00123", "기간이 만료된 token 입니다.")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) count(r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[r.URL.Path]++
+}
+
+func (s *Server) quote(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("fid_input_iscd")
+	s.mu.Lock()
+	price, ok := s.prices[symbol]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "EGW00202", "종목코드 오류입니다.")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rt_cd": "0",
+		"output": map[string]string{
+			"stck_prpr":    formatNumber(price),
+			"stck_mxpr":    formatNumber(price * 1.3),
+			"stck_llam":    formatNumber(price * 0.7),
+			"temp_stop_yn": "N",
+			"trht_yn":      "N",
+		},
+	})
+}
+
+func (s *Server) askingPrice(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("fid_input_iscd")
+	s.mu.Lock()
+	price, ok := s.prices[symbol]
+	book, hasBook := s.books[symbol]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "EGW00202", "종목코드 오류입니다.")
+		return
+	}
+	if !hasBook {
+		book = models.AskingPrice{Ask: price, Bid: price, Time: time.Now()}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rt_cd": "0",
+		"output1": map[string]string{
+			"aspr_acpt_hour": book.Time.In(market.KST).Format("150405"),
+			"askp1":          formatNumber(book.Ask),
+			"bidp1":          formatNumber(book.Bid),
+		},
+	})
+}
+
+func (s *Server) order(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Pair      string            `json:"pair"`
+		Amount    float64           `json:"amount"`
+		Side      models.SignalType `json:"side"`
+		AccountNo string            `json:"account_no"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, "APBK0001", "주문 요청이 올바르지 않습니다.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	price, ok := s.prices[req.Pair]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "APBK0002", "종목코드 오류입니다.")
+		return
+	}
+	p := s.positions[req.Pair]
+	if p == nil {
+		p = &position{}
+	}
+	cost := price * req.Amount
+	var side models.OrderSide
+	switch req.Side {
+	case models.BuySignal:
+		if cost > s.cash {
+			writeError(w, http.StatusBadRequest, "APBK0952", "주문가능금액을 초과 했습니다")
+			return
+		}
+		s.cash -= cost
+		p.avgPrice = (p.avgPrice*p.quantity + cost) / (p.quantity + req.Amount)
+		p.quantity += req.Amount
+		side = models.OrderSideBuy
+	case models.SellSignal:
+		if req.Amount > p.quantity {
+			writeError(w, http.StatusBadRequest, "APBK0952", "주문가능수량을 초과 했습니다")
+			return
+		}
+		s.cash += cost
+		p.quantity -= req.Amount
+		side = models.OrderSideSell
+	default:
+		writeError(w, http.StatusBadRequest, "APBK0001", "주문 요청이 올바르지 않습니다.")
+		return
+	}
+	s.positions[req.Pair] = p
+	s.orders = append(s.orders, Order{Pair: req.Pair, Side: req.Side, Amount: req.Amount, Price: price, AccountNo: req.AccountNo})
+	writeJSON(w, http.StatusOK, models.Order{
+		ID:     int64(len(s.orders)),
+		Pair:   req.Pair,
+		Type:   models.OrderTypeMarket,
+		Side:   side,
+		Amount: req.Amount,
+		Price:  price,
+	})
+}
+
+func (s *Server) balance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	cash := s.cash
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rt_cd":   "0",
+		"output2": []map[string]string{{"dncl_amt": formatNumber(cash)}},
+	})
+}
+
+func (s *Server) holdings(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []map[string]string{}
+	for symbol, p := range s.positions {
+		if p.quantity == 0 {
+			continue
+		}
+		price := s.prices[symbol]
+		out = append(out, map[string]string{
+			"pdno":          symbol,
+			"hldg_qty":      formatNumber(p.quantity),
+			"pchs_avg_pric": formatNumber(p.avgPrice),
+			"prpr":          formatNumber(price),
+			"evlu_pfls_amt": formatNumber((price - p.avgPrice) * p.quantity),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rt_cd": "0", "output1": out})
+}
+
+// openOrders serves no open orders: every order is filled at once.
+func (s *Server) openOrders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rt_cd": "0", "output": []struct{}{}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a KIS error: rt_cd 1 with the message code and message.
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, map[string]string{"rt_cd": "1", "msg_cd": code, "msg1": msg})
+}
+
+// formatNumber formats v the way KIS does: without exponent or trailing
+// zeros.
+func formatNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package exchangetest_test
+
+import (
+	"errors"
+	"testing"
+	"tradingbot/internal/config"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/exchange/exchangetest"
+	"tradingbot/internal/models"
+)
+
+func TestClientTradesAgainstServer(t *testing.T) {
+	srv := exchangetest.NewServer(1000000)
+	defer srv.Close()
+	srv.SetPrice("005930", 70000)
+	e, err := exchange.New(config.ExchangeConfig{BaseURL: srv.URL, AppKey: "key", AppSecret: "secret", AccountNo: "50000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := e.GetMarketData("005930")
+	if err != nil || md.StckPrpr != "70000" || md.StckMxpr != "91000" {
+		t.Fatalf("quote %+v, %v", md, err)
+	}
+	order, err := e.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 10})
+	if err != nil || order.Price != 70000 || order.Side != models.OrderSideBuy {
+		t.Fatalf("order %+v, %v", order, err)
+	}
+	if _, err := e.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 10}); !errors.Is(err, models.ErrInsufficientFunds) {
+		t.Errorf("err = %v, want insufficient funds", err)
+	}
+
+	if cash, err := e.GetBalance(); err != nil || cash != "300000" {
+		t.Errorf("balance %q, %v", cash, err)
+	}
+	positions, err := e.GetPositions()
+	if err != nil || len(positions) != 1 || positions[0].Quantity != 10 || positions[0].AvgPrice != 70000 {
+		t.Errorf("positions %+v, %v", positions, err)
+	}
+	if orders := srv.Orders(); len(orders) != 1 || orders[0].AccountNo != "50000000" {
+		t.Errorf("orders %+v", orders)
+	}
+}
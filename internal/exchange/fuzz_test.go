@@ -0,0 +1,84 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"tradingbot/internal/clock"
+)
+
+// roundTripFunc answers the client's requests without a server, which is
+// too slow for fuzzing.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// FuzzParseResponses feeds arbitrary bodies, starting from the recorded
+// responses of TestParseResponses, to every endpoint of the client. It must
+// return an error for what it cannot parse instead of panicking, and what it
+// returns must encode as JSON, which fails on the NaN and infinities strconv
+// accepts.
+func FuzzParseResponses(f *testing.F) {
+	for i, tc := range responseCases {
+		body, err := ioutil.ReadFile(filepath.Join("testdata", "kis", tc.name+".json"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(uint8(i), body)
+	}
+	f.Add(uint8(3), []byte(`{"rt_cd":"0","output":{"stck_prpr":"71,200","stck_mxpr":" 92900","stck_llam":"NaN"}}`))
+	f.Add(uint8(16), []byte(`{"rt_cd":"0","output1":[{"pdno":"005930","hldg_qty":"1e309","pchs_avg_pric":"-Inf"}]}`))
+
+	f.Fuzz(func(t *testing.T, i uint8, body []byte) {
+		tc := responseCases[int(i)%len(responseCases)]
+		e := &KISExchange{
+			client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: tc.status,
+					Header:     http.Header{"Content-Type": {"application/json; charset=UTF-8"}},
+					Body:       ioutil.NopCloser(bytes.NewReader(body)),
+					Request:    req,
+				}, nil
+			})},
+			BaseURL:   "https://kis.test",
+			AccountNo: "50000000",
+			Paper:     true,
+			Clock:     clock.NewSimulated(goldenTime),
+		}
+		result, err := tc.call(e)
+		if err != nil {
+			return
+		}
+		if _, err := json.Marshal(result); err != nil {
+			t.Errorf("%s: result %+v does not encode: %v", tc.name, result, err)
+		}
+	})
+}
+
+// FuzzParseNumber checks that parseNumber returns only finite numbers and
+// agrees with strconv on the numbers strconv reads.
+func FuzzParseNumber(f *testing.F) {
+	for _, s := range []string{"71200", "-300", "0.42", "71,200", " 352.45 ", "", "-", "NaN", "+Inf", "1e309", "1_000"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		v, err := parseNumber(s)
+		if err != nil {
+			return
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			t.Fatalf("parseNumber(%q) = %v", s, v)
+		}
+		if want, err := strconv.ParseFloat(s, 64); err == nil && want != v {
+			t.Errorf("parseNumber(%q) = %v, strconv reads %v", s, v, want)
+		}
+		if got, err := strconv.ParseFloat(normalizeNumber(s), 64); err != nil || got != v {
+			t.Errorf("normalizeNumber(%q) = %q, which parses as %v, %v", s, normalizeNumber(s), got, err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package exchange
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+	"tradingbot/internal/config"
+)
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// NewHTTPClient returns the HTTP client to talk to the exchange with, going
+// through the configured proxy and trusting the configured CAs.
+func NewHTTPClient(cfg config.HTTPConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.MinTLSVersion == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.InsecureSkipVerify {
+		log.Warn("TLS certificate verification of the exchange is disabled")
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := defaultHTTPTimeout
+	if cfg.Timeout != "" {
+		timeout, _ = time.ParseDuration(cfg.Timeout)
+	}
+	var rt http.RoundTripper = transport
+	if cfg.UserAgent != "" {
+		rt = userAgentTransport{userAgent: cfg.UserAgent, next: transport}
+	}
+	return &http.Client{Transport: rt, Timeout: timeout}, nil
+}
+
+// userAgentTransport sets the User-Agent header of every request.
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.next.RoundTrip(req)
+}
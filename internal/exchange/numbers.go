@@ -0,0 +1,36 @@
+package exchange
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseNumber parses a number of a KIS response. Some fields come padded with
+// spaces or, from the web exports, with thousands separators; both are
+// accepted. NaN and infinities, which strconv takes, are not numbers.
+func parseNumber(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v, err = strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 64)
+	}
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return v, nil
+}
+
+// normalizeNumber returns s in the form strconv.ParseFloat takes, for the
+// MarketData fields parsed downstream; s is returned as is when it is no
+// number at all, for the caller to report.
+func normalizeNumber(s string) string {
+	if v, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) {
+		return s
+	}
+	v, err := parseNumber(s)
+	if err != nil {
+		return s
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,118 @@
+package exchange
+
+import (
+	"fmt"
+	"tradingbot/internal/models"
+)
+
+// GetOpenOrders returns the orders that are still (partially) unfilled and can be cancelled.
+func (e *KISExchange) GetOpenOrders() ([]models.OpenOrder, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/trading/inquire-psbl-rvsecncl", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("tr_id", e.trID("TTTC8036R", "VTTC8036R"))
+
+	q := req.URL.Query()
+	q.Add("CANO", e.AccountNo)
+	q.Add("ACNT_PRDT_CD", "01")
+	q.Add("CTX_AREA_FK100", "")
+	q.Add("CTX_AREA_NK100", "")
+	q.Add("INQR_DVSN_1", "0")
+	q.Add("INQR_DVSN_2", "0")
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		Output []struct {
+			Odno         string `json:"odno"`
+			OrdGnoBrno   string `json:"ord_gno_brno"`
+			Pdno         string `json:"pdno"`
+			SllBuyDvsnCd string `json:"sll_buy_dvsn_cd"`
+			OrdQty       string `json:"ord_qty"`
+			PsblQty      string `json:"psbl_qty"`
+			OrdUnpr      string `json:"ord_unpr"`
+		} `json:"output"`
+	}
+	if err := e.do(req, "open orders", &result); err != nil {
+		return nil, err
+	}
+
+	orders := make([]models.OpenOrder, 0, len(result.Output))
+	for _, item := range result.Output {
+		order := models.OpenOrder{
+			OrderNo:   item.Odno,
+			BranchNo:  item.OrdGnoBrno,
+			StockCode: item.Pdno,
+			Side:      models.OrderSideBuy,
+		}
+		if item.SllBuyDvsnCd == "01" {
+			order.Side = models.OrderSideSell
+		}
+		order.Quantity, _ = parseNumber(item.OrdQty)
+		order.RemainingQty, _ = parseNumber(item.PsblQty)
+		order.Price, _ = parseNumber(item.OrdUnpr)
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// cancelOrderRequest is the body of an order correction or cancellation.
+type cancelOrderRequest struct {
+	AccountNo   string `json:"CANO"`
+	Product     string `json:"ACNT_PRDT_CD"`
+	BranchNo    string `json:"KRX_FWDG_ORD_ORGNO"`
+	OrderNo     string `json:"ORGN_ODNO"`
+	Division    string `json:"ORD_DVSN"`
+	Action      string `json:"RVSE_CNCL_DVSN_CD"`
+	Quantity    string `json:"ORD_QTY"`
+	Price       string `json:"ORD_UNPR"`
+	AllQuantity string `json:"QTY_ALL_ORD_YN"`
+}
+
+// CancelOrder cancels the whole remaining quantity of an open order.
+func (e *KISExchange) CancelOrder(order models.OpenOrder) error {
+	if e.Observing() {
+		return ErrObserving
+	}
+	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/trading/order-rvsecncl", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("POST", url, cancelOrderRequest{
+		AccountNo:   e.AccountNo,
+		Product:     "01",
+		BranchNo:    order.BranchNo,
+		OrderNo:     order.OrderNo,
+		Division:    "00",
+		Action:      "02", // 취소
+		Quantity:    "0",
+		Price:       "0",
+		AllQuantity: "Y",
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("tr_id", e.trID("TTTC0803U", "VTTC0803U"))
+
+	var result struct {
+		RtCd  string `json:"rt_cd"`
+		MsgCd string `json:"msg_cd"`
+		Msg1  string `json:"msg1"`
+	}
+	if err := e.do(req, "cancel order", &result); err != nil {
+		return err
+	}
+	if result.RtCd != "0" {
+		return resultError("cancel of order "+order.OrderNo, result.MsgCd, result.Msg1)
+	}
+	return nil
+}
+
+// trID picks the transaction ID for the account mode; KIS uses different IDs
+// for paper (모의투자) and live trading.
+func (e *KISExchange) trID(live, paper string) string {
+	if e.Paper {
+		return paper
+	}
+	return live
+}
@@ -0,0 +1,231 @@
+package exchange
+
+import (
+	"fmt"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownSymbol is returned by GetSymbolInfo for codes KIS does not know.
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// kisMarkets maps KIS market ID codes to KRX market names.
+var kisMarkets = map[string]string{
+	"STK": "KOSPI",
+	"KSQ": "KOSDAQ",
+	"KNX": "KONEX",
+}
+
+// kisInstrumentTypes maps KIS security group IDs to instrument types; other
+// groups, e.g. ST for shares, are stocks.
+var kisInstrumentTypes = map[string]models.InstrumentType{
+	"EF": models.InstrumentETF,
+	"EN": models.InstrumentETN,
+}
+
+// GetSymbolInfo looks up the master data of a stock.
+func (e *KISExchange) GetSymbolInfo(stockCode string) (*models.Symbol, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/quotations/search-stock-info", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("tr_id", "CTPF1002R")
+	req.Header.Set("custtype", "P")
+
+	q := req.URL.Query()
+	q.Add("PRDT_TYPE_CD", "300")
+	q.Add("PDNO", stockCode)
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		Output *struct {
+			PrdtAbrvName        string `json:"prdt_abrv_name"`
+			MketIDCd            string `json:"mket_id_cd"`
+			StdIdstClsfCdName   string `json:"std_idst_clsf_cd_name"`
+			FrmlMrktDealQtyUnit string `json:"frml_mrkt_deal_qty_unit"`
+			TrStopYn            string `json:"tr_stop_yn"`
+			AdmnItemYn          string `json:"admn_item_yn"`
+			LstgAbolDt          string `json:"lstg_abol_dt"`
+			Kospi200ItemYn      string `json:"kospi200_item_yn"`
+			SctyGrpIDCd         string `json:"scty_grp_id_cd"`
+		} `json:"output"`
+	}
+	if err := e.do(req, "symbol info", &result); err != nil {
+		return nil, err
+	}
+	out := result.Output
+	if out == nil || out.PrdtAbrvName == "" {
+		return nil, ErrUnknownSymbol
+	}
+
+	symbol := &models.Symbol{
+		Code:    stockCode,
+		Name:    out.PrdtAbrvName,
+		Market:  kisMarkets[out.MketIDCd],
+		Sector:  out.StdIdstClsfCdName,
+		LotSize: 1,
+		Status:  models.SymbolStatusNormal,
+		Type:    models.InstrumentStock,
+	}
+	if t, ok := kisInstrumentTypes[out.SctyGrpIDCd]; ok {
+		symbol.Type = t
+	}
+	if lot, err := parseNumber(out.FrmlMrktDealQtyUnit); err == nil && lot >= 1 {
+		symbol.LotSize = int(lot)
+	}
+	switch {
+	case out.LstgAbolDt != "":
+		symbol.Status = models.SymbolStatusDelisted
+	case out.TrStopYn == "Y":
+		symbol.Status = models.SymbolStatusHalted
+	case out.AdmnItemYn == "Y":
+		symbol.Status = models.SymbolStatusAdministrative
+	}
+	if out.Kospi200ItemYn == "Y" {
+		symbol.Indexes = []string{"KOSPI200"}
+	}
+	return symbol, nil
+}
+
+// GetDailyCandles returns up to days daily candles of a stock, oldest first,
+// ending with the latest session. KIS returns at most 100 candles per request.
+func (e *KISExchange) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	// Weekends and holidays take up about a third of the calendar.
+	end := e.Clock.Now().In(market.KST)
+	candles, err := e.GetDailyCandlesBetween(stockCode, end.AddDate(0, 0, -days*3/2-7), end)
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) > days {
+		candles = candles[len(candles)-days:]
+	}
+	return candles, nil
+}
+
+// GetDailyCandlesBetween returns the daily candles of a stock from the
+// session on from to the one on to, oldest first. KIS returns at most the
+// latest 100 of them; older ones take another request ending before the
+// oldest candle returned.
+func (e *KISExchange) GetDailyCandlesBetween(stockCode string, from, to time.Time) ([]candle.Candle, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/quotations/inquire-daily-itemchartprice", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("tr_id", "FHKST03010100")
+
+	q := req.URL.Query()
+	q.Add("FID_COND_MRKT_DIV_CODE", "J")
+	q.Add("FID_INPUT_ISCD", stockCode)
+	q.Add("FID_INPUT_DATE_1", from.In(market.KST).Format("20060102"))
+	q.Add("FID_INPUT_DATE_2", to.In(market.KST).Format("20060102"))
+	q.Add("FID_PERIOD_DIV_CODE", "D")
+	q.Add("FID_ORG_ADJ_PRC", "0")
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		RtCd    string `json:"rt_cd"`
+		MsgCd   string `json:"msg_cd"`
+		Msg1    string `json:"msg1"`
+		Output2 []struct {
+			StckBsopDate string `json:"stck_bsop_date"`
+			StckOprc     string `json:"stck_oprc"`
+			StckHgpr     string `json:"stck_hgpr"`
+			StckLwpr     string `json:"stck_lwpr"`
+			StckClpr     string `json:"stck_clpr"`
+			AcmlVol      string `json:"acml_vol"`
+		} `json:"output2"`
+	}
+	if err := e.do(req, "daily candles", &result); err != nil {
+		return nil, err
+	}
+	if result.RtCd != "" && result.RtCd != "0" {
+		return nil, resultError("daily candles", result.MsgCd, result.Msg1)
+	}
+
+	// The response is newest first, and holds an empty item when there are
+	// no sessions in the range.
+	var candles []candle.Candle
+	for i := len(result.Output2) - 1; i >= 0; i-- {
+		item := result.Output2[i]
+		date, err := time.ParseInLocation("20060102", item.StckBsopDate, market.KST)
+		if err != nil {
+			continue
+		}
+		c := candle.Candle{Symbol: stockCode, Start: date, Timeframe: 24 * time.Hour}
+		c.Open, _ = parseNumber(item.StckOprc)
+		c.High, _ = parseNumber(item.StckHgpr)
+		c.Low, _ = parseNumber(item.StckLwpr)
+		c.Close, _ = parseNumber(item.StckClpr)
+		c.Volume, _ = parseNumber(item.AcmlVol)
+		candles = append(candles, c)
+	}
+	return candles, nil
+}
+
+// GetMinuteCandles returns the 1m candles of a stock ending with the minute of
+// until, oldest first. KIS returns at most 30 per request, and keeps those of
+// the current day only.
+func (e *KISExchange) GetMinuteCandles(stockCode string, until time.Time) ([]candle.Candle, error) {
+	url := fmt.Sprintf("%s/uapi/domestic-stock/v1/quotations/inquire-time-itemchartprice", e.BaseURL)
+
+	req, err := e.newAuthorizedRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("tr_id", "FHKST03010200")
+
+	q := req.URL.Query()
+	q.Add("FID_ETC_CLS_CODE", "")
+	q.Add("FID_COND_MRKT_DIV_CODE", "J")
+	q.Add("FID_INPUT_ISCD", stockCode)
+	q.Add("FID_INPUT_HOUR_1", until.In(market.KST).Format("150405"))
+	q.Add("FID_PW_DATA_INCU_YN", "N")
+	req.URL.RawQuery = q.Encode()
+
+	var result struct {
+		RtCd    string `json:"rt_cd"`
+		MsgCd   string `json:"msg_cd"`
+		Msg1    string `json:"msg1"`
+		Output2 []struct {
+			StckBsopDate string `json:"stck_bsop_date"`
+			StckCntgHour string `json:"stck_cntg_hour"`
+			StckOprc     string `json:"stck_oprc"`
+			StckHgpr     string `json:"stck_hgpr"`
+			StckLwpr     string `json:"stck_lwpr"`
+			StckPrpr     string `json:"stck_prpr"`
+			CntgVol      string `json:"cntg_vol"`
+		} `json:"output2"`
+	}
+	if err := e.do(req, "minute candles", &result); err != nil {
+		return nil, err
+	}
+	if result.RtCd != "" && result.RtCd != "0" {
+		return nil, resultError("minute candles", result.MsgCd, result.Msg1)
+	}
+
+	// The response is newest first.
+	var candles []candle.Candle
+	for i := len(result.Output2) - 1; i >= 0; i-- {
+		item := result.Output2[i]
+		start, err := time.ParseInLocation("20060102150405", item.StckBsopDate+item.StckCntgHour, market.KST)
+		if err != nil {
+			continue
+		}
+		c := candle.Candle{Symbol: stockCode, Start: start, Timeframe: time.Minute}
+		c.Open, _ = parseNumber(item.StckOprc)
+		c.High, _ = parseNumber(item.StckHgpr)
+		c.Low, _ = parseNumber(item.StckLwpr)
+		c.Close, _ = parseNumber(item.StckPrpr)
+		c.Volume, _ = parseNumber(item.CntgVol)
+		candles = append(candles, c)
+	}
+	return candles, nil
+}
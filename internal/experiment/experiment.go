@@ -0,0 +1,298 @@
+package experiment
+
+import (
+	"math"
+	"sync"
+	"tradingbot/internal/allocation"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const (
+	defaultDays         = 20
+	defaultSignificance = 0.05
+	// tradingDays annualizes the Sharpe ratio.
+	tradingDays = 252
+)
+
+// Source provides the books and daily returns of the allocation sleeves, e.g.
+// the allocator.
+type Source interface {
+	Books() []allocation.Book
+	DailyReturns() map[string][]float64
+}
+
+// Variant is the performance of one sleeve of the test over the days
+// evaluated. Return is compounded from the daily returns, Volatility is their
+// standard deviation and Sharpe their annualized mean over it.
+type Variant struct {
+	Sleeve      string  `json:"sleeve"`
+	Weight      float64 `json:"weight"`
+	Equity      float64 `json:"equity"`
+	Orders      int     `json:"orders"`
+	Return      float64 `json:"return"`
+	MeanDaily   float64 `json:"mean_daily"`
+	Volatility  float64 `json:"volatility"`
+	Sharpe      float64 `json:"sharpe"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+}
+
+// Report compares the variants over the first Days of Required trading days.
+// Difference is the mean daily return of A minus that of B, and T and PValue
+// are its paired t statistic and two-sided p-value. Significant and Winner are
+// only set once the report is Complete, so that an early lead does not end
+// the test.
+type Report struct {
+	A           Variant `json:"a"`
+	B           Variant `json:"b"`
+	Days        int     `json:"days"`
+	Required    int     `json:"required"`
+	Complete    bool    `json:"complete"`
+	Difference  float64 `json:"difference"`
+	T           float64 `json:"t"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+	Winner      string  `json:"winner,omitempty"`
+}
+
+// Experiment runs the A/B test of config.ExperimentConfig on the sleeves of
+// source. Only the days and orders of the evaluation period count; the report
+// is fixed once it is complete. Experiment is safe for concurrent use.
+type Experiment struct {
+	cfg    config.ExperimentConfig
+	source Source
+
+	mu     sync.Mutex
+	orders map[string]int
+	final  *Report
+}
+
+// New creates the test configured in cfg.
+func New(cfg config.ExperimentConfig, source Source) *Experiment {
+	if cfg.Days == 0 {
+		cfg.Days = defaultDays
+	}
+	if cfg.Significance == 0 {
+		cfg.Significance = defaultSignificance
+	}
+	return &Experiment{cfg: cfg, source: source, orders: map[string]int{}}
+}
+
+// Subscribe counts the orders of the variants on bus and checks after every
+// trading cycle whether the evaluation period is over.
+func (e *Experiment) Subscribe(bus *events.Bus) {
+	bus.Subscribe(e.record, events.KindDecision)
+	bus.Subscribe(func(events.Event) { e.check() }, events.KindCycle)
+}
+
+func (e *Experiment) record(ev events.Event) {
+	d := ev.(events.DecisionEvent)
+	if d.Action != events.ActionOrdered || d.Signal == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.final == nil && (d.Signal.Strategy == e.cfg.A || d.Signal.Strategy == e.cfg.B) {
+		e.orders[d.Signal.Strategy]++
+	}
+}
+
+// check finalizes and logs the report once both variants have the required
+// days of returns.
+func (e *Experiment) check() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.final != nil {
+		return
+	}
+	report := e.report()
+	if !report.Complete {
+		return
+	}
+	e.final = &report
+	log.WithFields(logrus.Fields{
+		"a":           report.A.Sleeve,
+		"b":           report.B.Sleeve,
+		"days":        report.Days,
+		"a_return":    report.A.Return * 100,
+		"b_return":    report.B.Return * 100,
+		"p_value":     report.PValue,
+		"significant": report.Significant,
+		"winner":      report.Winner,
+	}).Info("A/B test complete")
+}
+
+// Report returns the comparison so far, or the final one once the evaluation
+// period is over.
+func (e *Experiment) Report() Report {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.final != nil {
+		return *e.final
+	}
+	return e.report()
+}
+
+func (e *Experiment) report() Report {
+	returns := e.source.DailyReturns()
+	a, b := returns[e.cfg.A], returns[e.cfg.B]
+	days := len(a)
+	if len(b) < days {
+		days = len(b)
+	}
+	if days > e.cfg.Days {
+		days = e.cfg.Days
+	}
+	a, b = a[:days], b[:days]
+
+	r := Report{
+		A:        variant(e.cfg.A, a),
+		B:        variant(e.cfg.B, b),
+		Days:     days,
+		Required: e.cfg.Days,
+		Complete: days >= e.cfg.Days,
+		PValue:   1,
+	}
+	for _, book := range e.source.Books() {
+		switch book.Strategy {
+		case e.cfg.A:
+			r.A.Weight, r.A.Equity = book.Weight, book.Equity
+		case e.cfg.B:
+			r.B.Weight, r.B.Equity = book.Weight, book.Equity
+		}
+	}
+	r.A.Orders, r.B.Orders = e.orders[e.cfg.A], e.orders[e.cfg.B]
+
+	if days >= 2 {
+		diff := make([]float64, days)
+		for i := range diff {
+			diff[i] = a[i] - b[i]
+		}
+		r.Difference = mean(diff)
+		r.T, r.PValue = pairedT(diff)
+	}
+	if r.Complete && r.PValue < e.cfg.Significance {
+		r.Significant = true
+		r.Winner = r.A.Sleeve
+		if r.Difference < 0 {
+			r.Winner = r.B.Sleeve
+		}
+	}
+	return r
+}
+
+func variant(sleeve string, returns []float64) Variant {
+	v := Variant{Sleeve: sleeve}
+	if len(returns) == 0 {
+		return v
+	}
+	equity, peak := 1.0, 1.0
+	for _, r := range returns {
+		equity *= 1 + r
+		if equity > peak {
+			peak = equity
+		}
+		if dd := (peak - equity) / peak; dd > v.MaxDrawdown {
+			v.MaxDrawdown = dd
+		}
+	}
+	v.Return = equity - 1
+	v.MeanDaily = mean(returns)
+	if len(returns) > 1 {
+		v.Volatility = stddev(returns)
+	}
+	if v.Volatility > 0 {
+		v.Sharpe = v.MeanDaily / v.Volatility * math.Sqrt(tradingDays)
+	}
+	return v
+}
+
+// pairedT returns the t statistic of the mean of diff and its two-sided
+// p-value under Student's t distribution with len(diff)-1 degrees of freedom.
+func pairedT(diff []float64) (t, p float64) {
+	n := float64(len(diff))
+	m, sd := mean(diff), stddev(diff)
+	if sd == 0 {
+		if m == 0 {
+			return 0, 1
+		}
+		return math.Copysign(math.Inf(1), m), 0
+	}
+	t = m / (sd / math.Sqrt(n))
+	df := n - 1
+	return t, incompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// incompleteBeta returns the regularized incomplete beta function I_x(a, b),
+// evaluated by its continued fraction.
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lab, _ := math.Lgamma(a + b)
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges quickly only below this point; above
+	// it the symmetry I_x(a, b) = 1 - I_1-x(b, a) is used.
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(a, b, x) / a
+	}
+	return 1 - front*betaFraction(b, a, 1-x)/b
+}
+
+func betaFraction(a, b, x float64) float64 {
+	const (
+		epsilon    = 1e-14
+		tiny       = 1e-300
+		iterations = 300
+	)
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+		return v
+	}
+	c, d := 1.0, 1/clamp(1-(a+b)*x/(a+1))
+	h := d
+	for m := 1.0; m <= iterations; m++ {
+		num := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 / clamp(1+num*d)
+		c = clamp(1 + num/c)
+		h *= d * c
+		num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 / clamp(1+num*d)
+		c = clamp(1 + num/c)
+		step := d * c
+		h *= step
+		if math.Abs(step-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
+
+func mean(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+func stddev(values []float64) float64 {
+	m := mean(values)
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - m) * (v - m)
+	}
+	return math.Sqrt(variance / float64(len(values)-1))
+}
@@ -0,0 +1,76 @@
+package experiment
+
+import (
+	"math"
+	"testing"
+	"tradingbot/internal/allocation"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+func TestPairedTPValue(t *testing.T) {
+	for _, tc := range []struct {
+		t, df, want float64
+	}{
+		{1, 1, 0.5},       // Cauchy
+		{2.228, 10, 0.05}, // the 5% critical value
+		{0, 5, 1},
+	} {
+		if got := incompleteBeta(tc.df/2, 0.5, tc.df/(tc.df+tc.t*tc.t)); math.Abs(got-tc.want) > 1e-3 {
+			t.Errorf("p(t=%v, df=%v) = %v, want %v", tc.t, tc.df, got, tc.want)
+		}
+	}
+}
+
+type fakeSource struct {
+	returns map[string][]float64
+}
+
+func (f *fakeSource) Books() []allocation.Book {
+	return []allocation.Book{
+		{Strategy: "fast", Weight: 0.5, Equity: 510000},
+		{Strategy: "slow", Weight: 0.5, Equity: 495000},
+	}
+}
+
+func (f *fakeSource) DailyReturns() map[string][]float64 {
+	return f.returns
+}
+
+func TestReportFinalAfterEvaluationPeriod(t *testing.T) {
+	source := &fakeSource{returns: map[string][]float64{
+		"fast": {0.011, 0.009, 0.012},
+		"slow": {-0.001, 0.001, -0.002},
+	}}
+	bus := events.NewBus()
+	e := New(config.ExperimentConfig{Enabled: true, A: "fast", B: "slow", Days: 4}, source)
+	e.Subscribe(bus)
+	bus.Publish(events.DecisionEvent{Action: events.ActionOrdered, Signal: &models.Signal{Strategy: "fast"}})
+
+	bus.Publish(events.CycleEvent{})
+	r := e.Report()
+	if r.Complete || r.Significant || r.Winner != "" || r.Days != 3 {
+		t.Fatalf("report %+v, want an open test after 3 of 4 days", r)
+	}
+	if r.A.Orders != 1 || r.A.Equity != 510000 {
+		t.Errorf("variant a %+v, want 1 order and the book's equity", r.A)
+	}
+
+	source.returns["fast"] = append(source.returns["fast"], 0.010, 0.5)
+	source.returns["slow"] = append(source.returns["slow"], 0.000)
+	bus.Publish(events.CycleEvent{})
+	source.returns["slow"] = append(source.returns["slow"], -0.5)
+	bus.Publish(events.CycleEvent{})
+
+	r = e.Report()
+	if !r.Complete || r.Days != 4 || !r.Significant || r.Winner != "fast" {
+		t.Fatalf("report %+v, want fast to win after 4 days", r)
+	}
+	if math.Abs(r.Difference-0.011) > 1e-9 || r.PValue >= 0.05 {
+		t.Errorf("difference %v, p %v, want 0.011 at p < 0.05", r.Difference, r.PValue)
+	}
+	if math.Abs(r.A.Return-(1.011*1.009*1.012*1.010-1)) > 1e-12 || r.B.MaxDrawdown <= 0 {
+		t.Errorf("variants %+v / %+v, want compounded returns of the first 4 days", r.A, r.B)
+	}
+}
@@ -0,0 +1,244 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Table is history to export: a header and rows whose values are strings,
+// float64s, int64s or times. Times are written in KST, as dates when they are
+// at midnight.
+type Table struct {
+	Name   string
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Trades lists orders, one row each.
+func Trades(orders []models.Order) Table {
+	t := Table{Name: "trades", Header: []string{"id", "time", "symbol", "side", "type", "quantity", "price", "value", "status", "strategy", "signal_price"}}
+	for _, o := range orders {
+		t.Rows = append(t.Rows, []interface{}{o.ID, o.Timestamp, o.Pair, string(o.Side), string(o.Type), o.Amount, o.Price, o.Amount * o.Price, string(o.Status), o.Strategy, o.SignalPrice})
+	}
+	return t
+}
+
+// Candles lists OHLCV candles, one row each.
+func Candles(candles []candle.Candle) Table {
+	t := Table{Name: "candles", Header: []string{"symbol", "start", "timeframe", "open", "high", "low", "close", "volume"}}
+	for _, c := range candles {
+		t.Rows = append(t.Rows, []interface{}{c.Symbol, c.Start, c.Timeframe.String(), c.Open, c.High, c.Low, c.Close, c.Volume})
+	}
+	return t
+}
+
+// Equity lists the account value at the end of each snapshot day: cash plus
+// the positions at their current prices.
+func Equity(snapshots []models.AccountSnapshot) (Table, error) {
+	t := Table{Name: "equity", Header: []string{"date", "cash", "positions_value", "equity", "positions"}}
+	for _, s := range snapshots {
+		cash, err := strconv.ParseFloat(s.Cash, 64)
+		if err != nil {
+			return Table{}, fmt.Errorf("invalid cash balance %q on %s", s.Cash, s.Date.Format("2006-01-02"))
+		}
+		value := 0.0
+		for _, p := range s.Positions {
+			value += p.Quantity * p.CurrentPrice
+		}
+		t.Rows = append(t.Rows, []interface{}{s.Date, cash, value, cash + value, int64(len(s.Positions))})
+	}
+	return t, nil
+}
+
+// Write writes t to w in format, FormatCSV or FormatXLSX.
+func Write(w io.Writer, t Table, format string) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, t)
+	case FormatXLSX:
+		return WriteXLSX(w, t)
+	}
+	return fmt.Errorf("unknown format %q (want %s or %s)", format, FormatCSV, FormatXLSX)
+}
+
+// WriteCSV writes t as CSV with a header line.
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	cw.Write(t.Header)
+	record := make([]string, len(t.Header))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			record[i] = text(v)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		if isDate(v) {
+			return v.In(market.KST).Format("2006-01-02")
+		}
+		return v.In(market.KST).Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(v)
+}
+
+func isDate(t time.Time) bool {
+	t = t.In(market.KST)
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// Cell styles of xlsxStyles.
+const (
+	styleDateTime = 1
+	styleDate     = 2
+)
+
+// WriteXLSX writes t as an Excel workbook with a single sheet. Numbers and
+// times are stored as such, so spreadsheets can compute with them.
+func WriteXLSX(w io.Writer, t Table) error {
+	var sheet bytes.Buffer
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	writeRow(&sheet, 1, stringRow(t.Header))
+	for i, row := range t.Rows {
+		writeRow(&sheet, i+2, row)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	name := t.Name
+	if name == "" {
+		name = "Sheet1"
+	}
+	var workbook bytes.Buffer
+	workbook.WriteString(xml.Header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`)
+	xml.EscapeText(&workbook, []byte(name))
+	workbook.WriteString(`" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+
+	zw := zip.NewWriter(w)
+	for _, part := range []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(xlsxContentTypes)},
+		{"_rels/.rels", []byte(xlsxRels)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", []byte(xlsxWorkbookRels)},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+		{"xl/worksheets/sheet1.xml", sheet.Bytes()},
+	} {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(part.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func stringRow(values []string) []interface{} {
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		row[i] = v
+	}
+	return row
+}
+
+func writeRow(b *bytes.Buffer, n int, row []interface{}) {
+	fmt.Fprintf(b, `<row r="%d">`, n)
+	for i, v := range row {
+		ref := column(i) + strconv.Itoa(n)
+		switch v := v.(type) {
+		case float64:
+			fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'g', -1, 64))
+		case int64:
+			fmt.Fprintf(b, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case time.Time:
+			style := styleDateTime
+			if isDate(v) {
+				style = styleDate
+			}
+			fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(serial(v), 'f', -1, 64))
+		default:
+			fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t>`, ref)
+			xml.EscapeText(b, []byte(text(v)))
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+// column returns the letters of the i-th column, counted from 0: A, ..., Z,
+// AA, ....
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// serial returns the Excel serial date of t's KST wall clock: days since
+// 1899-12-30.
+func serial(t time.Time) float64 {
+	t = t.In(market.KST)
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return float64(wall.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC))/time.Second) / 86400
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// xlsxStyles has the default style and, at styleDateTime and styleDate, the
+// formats of times and dates.
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd"/></numFmts>` +
+	`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`</styleSheet>`
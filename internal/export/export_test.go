@@ -0,0 +1,79 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+func TestTradesAsCSVAndXLSX(t *testing.T) {
+	table := Trades([]models.Order{{
+		ID: 7, Pair: "005930", Side: models.OrderSideBuy, Type: models.OrderTypeLimit,
+		Amount: 3, Price: 70000, Status: models.OrderStatusClosed, Strategy: "a&b",
+		Timestamp: time.Date(2024, 3, 4, 9, 30, 0, 0, market.KST),
+	}})
+
+	var out bytes.Buffer
+	if err := Write(&out, table, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,time,symbol,side,type,quantity,price,value,status,strategy,signal_price\n" +
+		"7,2024-03-04 09:30:00,005930,buy,limit,3,70000,210000,closed,a&b,0\n"
+	if out.String() != want {
+		t.Errorf("csv\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := Write(&out, table, FormatXLSX); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			data, _ := io.ReadAll(r)
+			sheet = string(data)
+		}
+	}
+	for _, cell := range []string{
+		`<c r="K1" t="inlineStr"><is><t>signal_price</t></is></c>`,
+		`<c r="A2"><v>7</v></c>`,
+		// 2024-03-04 09:30 is day 45355 plus 0.395833 of a day.
+		`<c r="B2" s="1"><v>45355.395833333336</v></c>`,
+		`<c r="C2" t="inlineStr"><is><t>005930</t></is></c>`,
+		`<c r="H2"><v>210000</v></c>`,
+		`<t>a&amp;b</t>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("sheet has no %s:\n%s", cell, sheet)
+		}
+	}
+}
+
+func TestEquityValuesPositions(t *testing.T) {
+	table, err := Equity([]models.AccountSnapshot{{
+		Date:      time.Date(2024, 3, 4, 0, 0, 0, 0, market.KST),
+		Cash:      "1000000",
+		Positions: []models.Position{{Quantity: 2, CurrentPrice: 50000}, {Quantity: 1, CurrentPrice: 300000}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	WriteCSV(&out, table)
+	if got := strings.Split(out.String(), "\n")[1]; got != "2024-03-04,1000000,400000,1400000,2" {
+		t.Errorf("row %q", got)
+	}
+	if _, err := Equity([]models.AccountSnapshot{{Cash: "n/a"}}); err == nil {
+		t.Error("an unreadable cash balance was accepted")
+	}
+}
@@ -0,0 +1,137 @@
+package hedge
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+)
+
+var log = logging.New()
+
+// tradingDaysPerYear annualizes the volatility of daily closes.
+const tradingDaysPerYear = 252
+
+// Hedger decides when the portfolio is hedged and by how much, as configured
+// in config.HedgerConfig. It is fed the portfolio equity and the daily closes
+// of the index; see Runner for placing the hedge.
+type Hedger struct {
+	cfg    config.HedgerConfig
+	peak   float64
+	equity float64
+	closes []float64
+	active bool
+	reason string
+}
+
+// New creates a hedger that is not hedged.
+func New(cfg config.HedgerConfig) *Hedger {
+	return &Hedger{cfg: cfg}
+}
+
+// SetCloses replaces the daily closes of the index, oldest first.
+func (h *Hedger) SetCloses(closes []float64) {
+	h.closes = append(h.closes[:0], closes...)
+	h.trim()
+}
+
+// Observe records the equity and index close of a day and tells whether the
+// portfolio is to be hedged.
+func (h *Hedger) Observe(equity, close float64) bool {
+	if close > 0 {
+		h.closes = append(h.closes, close)
+		h.trim()
+	}
+	return h.Update(equity)
+}
+
+func (h *Hedger) trim() {
+	if keep := h.cfg.VolatilityWindow + 1; len(h.closes) > keep {
+		h.closes = h.closes[len(h.closes)-keep:]
+	}
+}
+
+// Update records the equity and tells whether the portfolio is to be hedged.
+// A hedge starts when either trigger is exceeded and is lifted once both are
+// back below the release level.
+func (h *Hedger) Update(equity float64) bool {
+	h.equity = equity
+	if equity > h.peak {
+		h.peak = equity
+	}
+	drawdown, vol := h.Drawdown(), h.Volatility()
+
+	var exceeded []string
+	if limit := h.cfg.MaxDrawdown; limit > 0 && drawdown > limit {
+		exceeded = append(exceeded, fmt.Sprintf("drawdown %.3f > %g", drawdown, limit))
+	}
+	if limit := h.cfg.MaxVolatility; limit > 0 && vol > limit {
+		exceeded = append(exceeded, fmt.Sprintf("volatility %.3f > %g", vol, limit))
+	}
+	if len(exceeded) > 0 {
+		h.active = true
+		h.reason = strings.Join(exceeded, ", ")
+		return true
+	}
+
+	release := h.cfg.Release
+	if release == 0 {
+		release = 0.5
+	}
+	if h.active && drawdown <= h.cfg.MaxDrawdown*release && vol <= h.cfg.MaxVolatility*release {
+		h.active = false
+		h.reason = ""
+	}
+	return h.active
+}
+
+// Active tells whether the portfolio is hedged, and why.
+func (h *Hedger) Active() (bool, string) {
+	return h.active, h.reason
+}
+
+// Drawdown returns how far the equity is below its peak, as a fraction of
+// the peak.
+func (h *Hedger) Drawdown() float64 {
+	if h.peak <= 0 {
+		return 0
+	}
+	return (h.peak - h.equity) / h.peak
+}
+
+// Volatility returns the annualized standard deviation of the index's daily
+// log returns over the volatility window, or zero before it has filled.
+func (h *Hedger) Volatility() float64 {
+	n := h.cfg.VolatilityWindow
+	if n < 2 || len(h.closes) < n+1 {
+		return 0
+	}
+	returns := make([]float64, 0, n)
+	for i := len(h.closes) - n; i < len(h.closes); i++ {
+		if h.closes[i-1] <= 0 || h.closes[i] <= 0 {
+			return 0
+		}
+		returns = append(returns, math.Log(h.closes[i]/h.closes[i-1]))
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(n)
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(n - 1)
+	return math.Sqrt(variance * tradingDaysPerYear)
+}
+
+// Target returns the KRW exposure to short against holdings: Ratio of them
+// while hedged, zero otherwise.
+func (h *Hedger) Target(holdings float64) float64 {
+	if !h.active || holdings <= 0 {
+		return 0
+	}
+	return holdings * h.cfg.Ratio
+}
@@ -0,0 +1,167 @@
+package hedge
+
+import (
+	"testing"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+)
+
+func TestHedgerTriggersOnDrawdownAndReleases(t *testing.T) {
+	h := New(config.HedgerConfig{MaxDrawdown: 0.1, Ratio: 0.5})
+	if h.Update(1000) || h.Update(950) {
+		t.Fatal("hedged within the drawdown limit")
+	}
+	if !h.Update(880) {
+		t.Fatal("not hedged at a 12% drawdown")
+	}
+	if _, reason := h.Active(); reason == "" {
+		t.Error("active hedge without a reason")
+	}
+	if got := h.Target(800); got != 400 {
+		t.Errorf("target = %g, want half of the holdings", got)
+	}
+	// The hedge stays on until the drawdown is back below half the limit.
+	if !h.Update(930) {
+		t.Error("hedge lifted at a 7% drawdown")
+	}
+	if h.Update(960) || h.Target(800) != 0 {
+		t.Error("hedge kept at a 4% drawdown")
+	}
+}
+
+func TestHedgerVolatility(t *testing.T) {
+	h := New(config.HedgerConfig{MaxVolatility: 0.3, VolatilityWindow: 4, Ratio: 1})
+	h.SetCloses([]float64{100, 101, 100, 101, 100})
+	if vol := h.Volatility(); vol < 0.17 || vol > 0.19 {
+		t.Errorf("volatility = %g, want about 0.18", vol)
+	}
+	if h.Update(1000) {
+		t.Error("hedged below the volatility limit")
+	}
+	for _, c := range []float64{104, 99, 105, 98} {
+		h.Observe(1000, c)
+	}
+	if vol := h.Volatility(); vol <= 0.3 || !h.Update(1000) {
+		t.Errorf("volatility %g did not trigger the hedge", vol)
+	}
+}
+
+type fakeAccount struct {
+	cash      string
+	positions []models.Position
+	prices    map[string]string
+}
+
+func (f *fakeAccount) GetBalance() (string, error) { return f.cash, nil }
+
+func (f *fakeAccount) GetPositions() ([]models.Position, error) { return f.positions, nil }
+
+func (f *fakeAccount) GetMarketData(stockCode string) (*models.MarketData, error) {
+	return &models.MarketData{StckPrpr: f.prices[stockCode]}, nil
+}
+
+type fakeCandles []float64
+
+func (f fakeCandles) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	out := make([]candle.Candle, len(f))
+	for i, c := range f {
+		out[i] = candle.Candle{Symbol: stockCode, Close: c}
+	}
+	return out, nil
+}
+
+type submitted []*models.Signal
+
+func (s *submitted) Submit(source string, signal *models.Signal) {
+	*s = append(*s, signal)
+}
+
+type fakeFutures struct {
+	price     float64
+	positions []models.DerivativePosition
+	placed    []models.DerivativeOrder
+}
+
+func (f *fakeFutures) GetDerivativeQuote(code string) (*models.DerivativeQuote, error) {
+	return &models.DerivativeQuote{Code: code, Price: f.price}, nil
+}
+
+func (f *fakeFutures) GetDerivativePositions() ([]models.DerivativePosition, error) {
+	return f.positions, nil
+}
+
+func (f *fakeFutures) PlaceDerivativeOrder(order models.DerivativeOrder) (string, error) {
+	f.placed = append(f.placed, order)
+	return "1", nil
+}
+
+func TestRunnerBuysInverseETF(t *testing.T) {
+	cfg := &config.Config{
+		CashSweep: config.CashSweepConfig{Symbol: "357870"},
+		Hedger: config.HedgerConfig{
+			Instrument: config.HedgeInverseETF, InverseETF: "114800", Leverage: 1,
+			MaxDrawdown: 0.1, Ratio: 0.5,
+		},
+	}
+	acct := &fakeAccount{
+		cash: "0",
+		positions: []models.Position{
+			{StockCode: "005930", Quantity: 100, CurrentPrice: 100000},
+			{StockCode: "357870", Quantity: 10, CurrentPrice: 50000},
+		},
+		prices: map[string]string{"114800": "5000"},
+	}
+	var orders submitted
+	r := NewRunner(cfg, acct, nil, &orders, nil, []string{"357870"})
+
+	if err := r.Check(); err != nil || len(orders) != 0 {
+		t.Fatalf("Check() = %v with %d orders, want no hedge at the peak", err, len(orders))
+	}
+
+	// A 20% fall of the stock is a 19% drawdown; half of the remaining
+	// ₩8,000,000 is hedged with 800 inverse ETF shares.
+	acct.positions[0].CurrentPrice = 80000
+	if err := r.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Type != models.BuySignal || orders[0].Pair != "114800" || orders[0].Amount != 800 || orders[0].Strategy != Strategy {
+		t.Fatalf("orders = %+v, want 800 inverse ETF shares bought", orders)
+	}
+
+	// Recovered, the inverse ETF is sold again.
+	acct.positions[0].CurrentPrice = 100000
+	acct.positions = append(acct.positions, models.Position{StockCode: "114800", Quantity: 800, CurrentPrice: 4000})
+	if err := r.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[1].Type != models.SellSignal || orders[1].Amount != 800 {
+		t.Errorf("orders = %+v, want the hedge sold", orders)
+	}
+}
+
+func TestRunnerSellsFutures(t *testing.T) {
+	cfg := &config.Config{
+		Derivatives: config.DerivativesConfig{Future: "101W12"},
+		Hedger: config.HedgerConfig{
+			Instrument: config.HedgeFuture, Index: "069500",
+			MaxVolatility: 0.2, VolatilityWindow: 3, Ratio: 1,
+		},
+	}
+	acct := &fakeAccount{cash: "0", positions: []models.Position{{StockCode: "069500", Quantity: 50000, CurrentPrice: 40000}}}
+	futures := &fakeFutures{price: 400, positions: []models.DerivativePosition{
+		{Code: "101W12", Kind: models.ContractFuture, Side: models.PositionShort, Quantity: 5},
+	}}
+	r := NewRunner(cfg, acct, fakeCandles{400, 420, 390, 425}, nil, futures, nil)
+
+	// ₩2,000,000,000 at 400 points is 20 contracts, 5 of them already sold.
+	if err := r.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if len(futures.placed) != 1 {
+		t.Fatalf("placed %+v, want one futures order", futures.placed)
+	}
+	if o := futures.placed[0]; o.Side != models.OrderSideSell || o.Quantity != 15 {
+		t.Errorf("order = %+v, want 15 futures sold", o)
+	}
+}
@@ -0,0 +1,228 @@
+package hedge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/derivatives"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Strategy tags the orders of the inverse ETF hedge.
+const Strategy = "hedge"
+
+// Account is the part of the exchange client the runner needs.
+type Account interface {
+	GetBalance() (string, error)
+	GetPositions() ([]models.Position, error)
+	GetMarketData(stockCode string) (*models.MarketData, error)
+}
+
+// Candles provides the daily closes of the index.
+type Candles interface {
+	GetDailyCandles(stockCode string, days int) ([]candle.Candle, error)
+}
+
+// Submitter executes stock orders, e.g. the engine, which applies its risk
+// checks and records them.
+type Submitter interface {
+	Submit(source string, signal *models.Signal)
+}
+
+// Futures trades the futures hedge.
+type Futures interface {
+	GetDerivativeQuote(code string) (*models.DerivativeQuote, error)
+	GetDerivativePositions() ([]models.DerivativePosition, error)
+	PlaceDerivativeOrder(order models.DerivativeOrder) (string, error)
+}
+
+// Runner checks the account periodically and trades the hedge instrument to
+// the hedger's target.
+type Runner struct {
+	hedger  *Hedger
+	cfg     config.HedgerConfig
+	acct    Account
+	candles Candles
+	orders  Submitter
+	futures Futures
+	// future and multiplier are the contract and index point value of the
+	// futures hedge.
+	future     string
+	multiplier float64
+	// exclude lists symbols that are not hedged, e.g. the cash sweep ETF.
+	exclude []string
+}
+
+// NewRunner creates a runner for the hedge configured in cfg. futures is only
+// used for the futures instrument; exclude lists holdings left unhedged.
+func NewRunner(cfg *config.Config, acct Account, candles Candles, orders Submitter, futures Futures, exclude []string) *Runner {
+	multiplier := cfg.Derivatives.Multiplier
+	if multiplier == 0 {
+		multiplier = derivatives.DefaultMultiplier
+	}
+	return &Runner{
+		hedger:     New(cfg.Hedger),
+		cfg:        cfg.Hedger,
+		acct:       acct,
+		candles:    candles,
+		orders:     orders,
+		futures:    futures,
+		future:     cfg.Derivatives.Future,
+		multiplier: multiplier,
+		exclude:    exclude,
+	}
+}
+
+// Check updates the hedger with the account and the index, and trades the
+// hedge instrument to its target.
+func (r *Runner) Check() error {
+	if r.cfg.MaxVolatility > 0 {
+		candles, err := r.candles.GetDailyCandles(r.cfg.Index, r.cfg.VolatilityWindow+1)
+		if err != nil {
+			return fmt.Errorf("failed to get index candles: %v", err)
+		}
+		closes := make([]float64, len(candles))
+		for i, c := range candles {
+			closes[i] = c.Close
+		}
+		r.hedger.SetCloses(closes)
+	}
+
+	balance, err := r.acct.GetBalance()
+	if err != nil {
+		return fmt.Errorf("failed to get balance: %v", err)
+	}
+	cash, err := strconv.ParseFloat(balance, 64)
+	if err != nil {
+		return fmt.Errorf("invalid balance %q", balance)
+	}
+	positions, err := r.acct.GetPositions()
+	if err != nil {
+		return fmt.Errorf("failed to get positions: %v", err)
+	}
+	equity, holdings := cash, 0.0
+	inverse := 0.0
+	for _, p := range positions {
+		value := p.Quantity * p.CurrentPrice
+		equity += value - p.Loan
+		switch {
+		case p.StockCode == r.cfg.InverseETF && r.cfg.Instrument == config.HedgeInverseETF:
+			inverse = p.Quantity
+		case !contains(r.exclude, p.StockCode):
+			holdings += value
+		}
+	}
+
+	wasActive, _ := r.hedger.Active()
+	active := r.hedger.Update(equity)
+	_, reason := r.hedger.Active()
+	if active != wasActive {
+		entry := log.WithFields(logrus.Fields{"drawdown": r.hedger.Drawdown(), "volatility": r.hedger.Volatility()})
+		if active {
+			entry.WithField("reason", reason).Warn("Hedging portfolio")
+		} else {
+			entry.Info("Lifting portfolio hedge")
+		}
+	}
+	target := r.hedger.Target(holdings)
+
+	if r.cfg.Instrument == config.HedgeFuture {
+		return r.hedgeWithFutures(target)
+	}
+	return r.hedgeWithInverseETF(target, inverse)
+}
+
+// hedgeWithInverseETF buys or sells the inverse ETF so that its leveraged
+// exposure matches target.
+func (r *Runner) hedgeWithInverseETF(target, held float64) error {
+	md, err := r.acct.GetMarketData(r.cfg.InverseETF)
+	if err != nil {
+		return fmt.Errorf("failed to get %s price: %v", r.cfg.InverseETF, err)
+	}
+	price, err := strconv.ParseFloat(md.StckPrpr, 64)
+	if err != nil || price <= 0 {
+		return fmt.Errorf("invalid price %q for %s", md.StckPrpr, r.cfg.InverseETF)
+	}
+	leverage := r.cfg.Leverage
+	if leverage == 0 {
+		leverage = 1
+	}
+	wanted := math.Floor(target / leverage / price)
+	switch {
+	case wanted > held:
+		r.orders.Submit(Strategy, &models.Signal{Type: models.BuySignal, Pair: r.cfg.InverseETF, Amount: wanted - held, Strategy: Strategy})
+	case wanted < held:
+		r.orders.Submit(Strategy, &models.Signal{Type: models.SellSignal, Pair: r.cfg.InverseETF, Amount: held - wanted, Strategy: Strategy})
+	}
+	return nil
+}
+
+// hedgeWithFutures sells or buys back futures so that the short position
+// matches target.
+func (r *Runner) hedgeWithFutures(target float64) error {
+	quote, err := r.futures.GetDerivativeQuote(r.future)
+	if err != nil {
+		return fmt.Errorf("failed to quote %s: %v", r.future, err)
+	}
+	if quote.Price <= 0 {
+		return fmt.Errorf("no price for %s", r.future)
+	}
+	positions, err := r.futures.GetDerivativePositions()
+	if err != nil {
+		return fmt.Errorf("failed to get derivatives positions: %v", err)
+	}
+	short := 0.0
+	for _, p := range positions {
+		if p.Code == r.future {
+			short -= p.Signed()
+		}
+	}
+	wanted := math.Round(target / (quote.Price * r.multiplier))
+
+	order := models.DerivativeOrder{Code: r.future, Price: quote.Price, Reason: "portfolio hedge"}
+	switch {
+	case wanted > short:
+		order.Side, order.Quantity = models.OrderSideSell, wanted-short
+	case wanted < short:
+		order.Side, order.Quantity = models.OrderSideBuy, short-wanted
+	default:
+		return nil
+	}
+	orderNo, err := r.futures.PlaceDerivativeOrder(order)
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{"code": order.Code, "side": order.Side, "quantity": order.Quantity, "order_no": orderNo}).Info("Hedge order placed")
+	return nil
+}
+
+// Run checks every interval until ctx is cancelled, starting at once.
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Check(); err != nil {
+			log.WithError(err).Warn("Hedge check failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
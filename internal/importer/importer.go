@@ -0,0 +1,203 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/market"
+	"unicode/utf8"
+)
+
+// Options completes what a file leaves out. Symbol is the stock code of files
+// without a code column, e.g. a Yahoo Finance download, and Date the day of
+// files without a date column, e.g. a KRX export of all stocks on one day.
+// Prices are multiplied by FX, the KRW value of the file's currency (default
+// 1). Unless Raw is set, prices are scaled to the adjusted close when the file
+// has one, so splits and dividends do not show as jumps.
+type Options struct {
+	Symbol string
+	Date   time.Time
+	FX     float64
+	Raw    bool
+}
+
+// Result is what a file held: the daily candles read and the rows skipped for
+// a missing or non-positive price, e.g. Yahoo's "null" rows or KRX trading
+// halts.
+type Result struct {
+	Candles []candle.Candle
+	Skipped int
+}
+
+type field int
+
+const (
+	fieldDate field = iota
+	fieldSymbol
+	fieldOpen
+	fieldHigh
+	fieldLow
+	fieldClose
+	fieldAdjClose
+	fieldVolume
+)
+
+// columns maps the normalized column names of Yahoo Finance and KRX (Korean
+// and English) exports to fields. KRX serves EUC-KR files; the header names
+// are matched in that encoding too, so they need no decoding.
+var columns = map[field][]string{
+	fieldDate:     {"date", "일자", "\xc0\xcf\xc0\xda"},
+	fieldSymbol:   {"symbol", "code", "issue code", "종목코드", "\xc1\xbe\xb8\xf1\xc4\xda\xb5\xe5"},
+	fieldOpen:     {"open", "시가", "\xbd\xc3\xb0\xa1"},
+	fieldHigh:     {"high", "고가", "\xb0\xed\xb0\xa1"},
+	fieldLow:      {"low", "저가", "\xc0\xfa\xb0\xa1"},
+	fieldClose:    {"close", "종가", "\xc1\xbe\xb0\xa1"},
+	fieldAdjClose: {"adj close"},
+	fieldVolume:   {"volume", "거래량", "\xb0\xc5\xb7\xa1\xb7\xae"},
+}
+
+var dateLayouts = []string{"2006-01-02", "2006/01/02", "2006.01.02", "20060102"}
+
+// Read reads the daily candles of a Yahoo Finance or KRX CSV file, telling
+// them apart by their column names.
+func Read(r io.Reader, opts Options) (Result, error) {
+	if opts.FX == 0 {
+		opts.FX = 1
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read header: %v", err)
+	}
+	index := map[field]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if utf8.ValidString(name) {
+			// Lowering would mangle EUC-KR names.
+			name = strings.ToLower(name)
+		}
+		for f, names := range columns {
+			if _, dup := index[f]; !dup && contains(names, name) {
+				index[f] = i
+			}
+		}
+	}
+	for _, f := range []field{fieldOpen, fieldHigh, fieldLow, fieldClose} {
+		if _, ok := index[f]; !ok {
+			return Result{}, fmt.Errorf("no open, high, low and close columns in header %q; want a Yahoo Finance or KRX export", header)
+		}
+	}
+	if _, ok := index[fieldSymbol]; !ok && opts.Symbol == "" {
+		return Result{}, fmt.Errorf("the file has no stock code column; set the symbol")
+	}
+	if _, ok := index[fieldDate]; !ok && opts.Date.IsZero() {
+		return Result{}, fmt.Errorf("the file has no date column; set the date")
+	}
+
+	var result Result
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("line %d: %v", line, err)
+		}
+		value := func(f field) string {
+			if i, ok := index[f]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		c := candle.Candle{Symbol: opts.Symbol, Timeframe: 24 * time.Hour, Start: opts.Date}
+		if code := value(fieldSymbol); code != "" {
+			c.Symbol = code
+		}
+		if _, ok := index[fieldDate]; ok {
+			if c.Start, err = parseDate(value(fieldDate)); err != nil {
+				return result, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		c.Start = time.Date(c.Start.Year(), c.Start.Month(), c.Start.Day(), 0, 0, 0, 0, market.KST)
+
+		prices := []*float64{&c.Open, &c.High, &c.Low, &c.Close}
+		valid := true
+		for i, f := range []field{fieldOpen, fieldHigh, fieldLow, fieldClose} {
+			v, ok := parseNumber(value(f))
+			if !ok || v <= 0 {
+				valid = false
+				break
+			}
+			*prices[i] = v
+		}
+		if !valid {
+			result.Skipped++
+			continue
+		}
+		if v, ok := parseNumber(value(fieldVolume)); ok {
+			c.Volume = v
+		}
+
+		factor := opts.FX
+		if adj, ok := parseNumber(value(fieldAdjClose)); ok && adj > 0 && !opts.Raw {
+			factor *= adj / c.Close
+		}
+		for _, p := range prices {
+			*p *= factor
+		}
+		// Rounding and adjustment can leave the open or close just outside
+		// the range.
+		c.High = maxFloat(c.High, c.Open, c.Close)
+		c.Low = minFloat(c.Low, c.Open, c.Close)
+		result.Candles = append(result.Candles, c)
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, market.KST); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseNumber parses a number with thousands separators, as KRX writes them.
+// Empty values and placeholders such as "null" or "-" are not numbers.
+func parseNumber(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	return v, err == nil
+}
+
+func maxFloat(v float64, rest ...float64) float64 {
+	for _, r := range rest {
+		if r > v {
+			v = r
+		}
+	}
+	return v
+}
+
+func minFloat(v float64, rest ...float64) float64 {
+	for _, r := range rest {
+		if r < v {
+			v = r
+		}
+	}
+	return v
+}
@@ -0,0 +1,60 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/market"
+)
+
+func TestReadYahooAdjustsPrices(t *testing.T) {
+	file := "Date,Open,High,Low,Close,Adj Close,Volume\n" +
+		"2024-03-04,100,110,90,100,50,1000\n" +
+		"2024-03-05,null,null,null,null,null,null\n"
+	got, err := Read(strings.NewReader(file), Options{Symbol: "005930", FX: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Candles) != 1 || got.Skipped != 1 {
+		t.Fatalf("%d candles, %d skipped, want 1 and 1", len(got.Candles), got.Skipped)
+	}
+	c := got.Candles[0]
+	// Halved to the adjusted close, doubled by the exchange rate.
+	if c.Symbol != "005930" || c.Open != 100 || c.High != 110 || c.Low != 90 || c.Close != 100 || c.Volume != 1000 {
+		t.Errorf("candle %+v", c)
+	}
+	if !c.Start.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, market.KST)) || c.Timeframe != 24*time.Hour {
+		t.Errorf("start %v, timeframe %v, want a daily candle at midnight KST", c.Start, c.Timeframe)
+	}
+
+	raw, _ := Read(strings.NewReader(file), Options{Symbol: "005930", Raw: true})
+	if raw.Candles[0].Close != 100 || raw.Candles[0].High != 110 {
+		t.Errorf("raw candle %+v, want the file's prices", raw.Candles[0])
+	}
+	if _, err := Read(strings.NewReader(file), Options{}); err == nil {
+		t.Error("a file without a code column was read without a symbol")
+	}
+}
+
+func TestReadKRXInEUCKR(t *testing.T) {
+	// 종목코드, 종목명, 종가, 시가, 고가, 저가, 거래량 in EUC-KR, the second
+	// stock halted.
+	file := "\xc1\xbe\xb8\xf1\xc4\xda\xb5\xe5,\xc1\xbe\xb8\xf1\xb8\xed,\xc1\xbe\xb0\xa1,\xbd\xc3\xb0\xa1,\xb0\xed\xb0\xa1,\xc0\xfa\xb0\xa1,\xb0\xc5\xb7\xa1\xb7\xae\n" +
+		"\"005930\",\"\xbb\xef\xbc\xba\xc0\xfc\xc0\xda\",\"71,000\",\"70,500\",\"71,200\",\"70,100\",\"12,345,678\"\n" +
+		"\"000660\",\"SK\",\"150,000\",\"0\",\"0\",\"0\",\"0\"\n"
+	day := time.Date(2024, 3, 4, 15, 30, 0, 0, market.KST)
+	got, err := Read(strings.NewReader(file), Options{Date: day})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Candles) != 1 || got.Skipped != 1 {
+		t.Fatalf("%d candles, %d skipped, want 1 and 1", len(got.Candles), got.Skipped)
+	}
+	c := got.Candles[0]
+	if c.Symbol != "005930" || c.Open != 70500 || c.High != 71200 || c.Low != 70100 || c.Close != 71000 || c.Volume != 12345678 {
+		t.Errorf("candle %+v", c)
+	}
+	if c.Start.Hour() != 0 || c.Start.Day() != 4 {
+		t.Errorf("start %v, want midnight of the day", c.Start)
+	}
+}
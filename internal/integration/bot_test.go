@@ -0,0 +1,234 @@
+//go:build integration
+
+package integration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/engine"
+	"tradingbot/internal/events"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/exchange/exchangetest"
+	"tradingbot/internal/market"
+	"tradingbot/internal/metrics"
+	"tradingbot/internal/models"
+	"tradingbot/internal/scheduler"
+	"tradingbot/internal/strategy"
+	"tradingbot/internal/supervisor"
+)
+
+// configTemplate is the configuration of the bot under test: two symbols
+// traded on 1-minute candles by a fast moving average crossover, taking the
+// database URL and the fake server's URL.
+const configTemplate = `
+database_url: %q
+exchange:
+  mode: "paper"
+  account_no: "50000000"
+  base_url: %q
+strategy: "moving_average"
+timeframe: "1m"
+strategies:
+  moving_average:
+    short_period: 2
+    long_period: 3
+    threshold: 0
+symbols: ["005930", "000660"]
+polling_interval: "1m"
+max_parallel: 2
+`
+
+// bot is the trading loop of `tradingbot run`, wired as it is there, on a
+// simulated clock so that each cycle is a minute later.
+type bot struct {
+	cfg     *config.Config
+	eng     *engine.Engine
+	sup     *supervisor.Supervisor
+	metrics *metrics.Registry
+	clock   *clock.Simulated
+
+	mu     sync.Mutex
+	errors []events.ErrorEvent
+}
+
+// startBot loads the configuration, connects to the exchange at kis and
+// starts the engine on store, at the opening of a trading day.
+func startBot(t *testing.T, databaseURL string, kis *exchangetest.Server, store engine.OrderStore) *bot {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(fmt.Sprintf(configTemplate, databaseURL, kis.URL)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EXCHANGE_API_KEY", "key")
+	t.Setenv("EXCHANGE_API_SECRET", "secret")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &bot{cfg: cfg, clock: clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST))}
+	exch, err := exchange.New(cfg.Exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exch.Clock = b.clock
+
+	params, err := cfg.StrategyParamsFor(cfg.Strategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strategies := make(map[string]strategy.Strategy)
+	for _, symbol := range cfg.TradingSymbols() {
+		if strategies[symbol], err = strategy.New(cfg.Strategy, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b.eng = engine.New(cfg, exch, store, strategies)
+	b.eng.SetClock(b.clock)
+	b.sup = supervisor.New(b.eng.Bus)
+	b.metrics = metrics.NewRegistry()
+	b.metrics.Subscribe(b.eng.Bus)
+	b.eng.Bus.Subscribe(func(ev events.Event) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.errors = append(b.errors, ev.(events.ErrorEvent))
+	}, events.KindError)
+	return b
+}
+
+// cycle runs the cycles of all symbols, then moves the clock on to the next.
+func (b *bot) cycle() {
+	scheduler.ForEach(b.cfg.TradingSymbols(), b.cfg.MaxParallel, func(symbol string) {
+		b.sup.Do("cycle", symbol, func() { b.eng.RunCycle(symbol) })
+	})
+	b.sup.Do("sweep", "", b.eng.Sweep)
+	b.clock.Advance(b.cfg.ParsedInterval)
+}
+
+// TestBotTradesEndToEnd runs the bot through a rise and a fall of the price
+// and checks that the crossovers were traded at the exchange, stored and
+// counted.
+func TestBotTradesEndToEnd(t *testing.T) {
+	url, db := openDatabase(t)
+	kis := exchangetest.NewServer(10000000)
+	defer kis.Close()
+	b := startBot(t, url, kis, db)
+
+	prices := []float64{70000, 70000, 70000, 77000, 84000, 91000, 84000, 70000, 63000, 63000}
+	for _, p := range prices {
+		kis.SetPrice("005930", p)
+		kis.SetPrice("000660", p/2)
+		b.cycle()
+	}
+	if len(b.errors) > 0 {
+		t.Errorf("errors: %+v", b.errors)
+	}
+
+	// The exchange received the orders of the crossovers: the strategy
+	// buys once the rise shows in a completed candle and sells once the fall
+	// does, at the market price of the next cycle.
+	var got []string
+	for _, o := range kis.Orders() {
+		got = append(got, fmt.Sprintf("%s %s %v@%v", o.Pair, o.Side, o.Amount, o.Price))
+		if o.AccountNo != "50000000" {
+			t.Errorf("order on account %q", o.AccountNo)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"000660 buy 1@42000", "000660 sell 1@31500", "005930 buy 1@84000", "005930 sell 1@63000"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("orders %q, want %q", got, want)
+	}
+	if cash := kis.Cash(); cash != 10000000-84000+63000-42000+31500 {
+		t.Errorf("cash %v after the round trips", cash)
+	}
+
+	// Every order and every completed candle was stored.
+	orders, err := db.ListOrders(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, o := range orders {
+		got = append(got, fmt.Sprintf("%s %s %v@%v signal %v at %s", o.Pair, o.Side, o.Amount, o.Price, o.SignalPrice, o.Timestamp.Format("15:04")))
+	}
+	sort.Strings(got)
+	want = []string{
+		"000660 buy 1@42000 signal 38500 at 09:04",
+		"000660 sell 1@31500 signal 35000 at 09:08",
+		"005930 buy 1@84000 signal 77000 at 09:04",
+		"005930 sell 1@63000 signal 70000 at 09:08",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("stored orders %q, want %q", got, want)
+	}
+	from := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	candles, err := db.ListCandles("005930", time.Minute, from, from.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The candle of the last cycle is still open.
+	if len(candles) != len(prices)-1 {
+		t.Fatalf("%d candles stored, want %d", len(candles), len(prices)-1)
+	}
+	for i, c := range candles {
+		if !c.Start.Equal(from.Add(time.Duration(i)*time.Minute)) || c.Close != prices[i] {
+			t.Errorf("candle %d: %+v, want close %v", i, c, prices[i])
+		}
+	}
+
+	// The metrics count them.
+	var text strings.Builder
+	if err := b.metrics.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`tradingbot_orders_total{symbol="005930",side="buy"} 1`,
+		`tradingbot_orders_total{symbol="005930",side="sell"} 1`,
+		`tradingbot_orders_total{symbol="000660",side="buy"} 1`,
+		`tradingbot_orders_total{symbol="000660",side="sell"} 1`,
+		`tradingbot_signals_total{symbol="005930",type="buy"} 4`,
+		`tradingbot_decisions_total{action="ordered"} 4`,
+	} {
+		if !strings.Contains(text.String(), line+"\n") {
+			t.Errorf("metrics lack %s:\n%s", line, text.String())
+		}
+	}
+	if strings.Contains(text.String(), "tradingbot_errors_total") {
+		t.Errorf("errors counted:\n%s", text.String())
+	}
+}
+
+// TestSaveOrderOnce saves orders again, as the outbox does when the answer
+// to a save is lost, and checks that each is stored once.
+func TestSaveOrderOnce(t *testing.T) {
+	_, db := openDatabase(t)
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	keyed := models.Order{Pair: "005930", Side: models.OrderSideBuy, Amount: 1, Price: 70000, Timestamp: at, Key: models.NewOrderKey()}
+	unkeyed := models.Order{Pair: "000660", Side: models.OrderSideBuy, Amount: 1, Price: 35000, Timestamp: at}
+	for _, o := range []models.Order{keyed, keyed, unkeyed, unkeyed} {
+		if err := db.SaveOrder(&o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orders, err := db.ListOrders(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, o := range orders {
+		counts[o.Pair]++
+	}
+	if counts["005930"] != 1 || counts["000660"] != 2 {
+		t.Errorf("stored %v, want the keyed order once and each unkeyed one", counts)
+	}
+}
@@ -0,0 +1,14 @@
+// Package integration holds the end-to-end tests of the bot: the trading
+// loop runs for several simulated cycles against a fake KIS server (see
+// exchangetest) and a real MySQL database, and the tests check the orders
+// the exchange received, the rows stored and the exported metrics.
+//
+// The tests need Docker, or a database to use instead, and are left out of
+// the default build:
+//
+//	go test -tags integration ./internal/integration
+//
+// TRADINGBOT_TEST_DATABASE_URL, e.g. "root:secret@tcp(localhost:3306)/test",
+// runs them against an existing database, whose tables they recreate.
+// Without it a MySQL container is started for the run.
+package integration
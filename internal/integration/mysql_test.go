@@ -0,0 +1,163 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/database"
+)
+
+const (
+	mysqlImage    = "mysql:8.0"
+	mysqlPassword = "integration"
+	// mysqlStartup is how long a new container may take to accept
+	// connections.
+	mysqlStartup = 2 * time.Minute
+)
+
+// schema is the tables the bot writes, as documented in package database.
+var schema = []string{
+	`DROP TABLE IF EXISTS orders, candles, daily_candles`,
+	`CREATE TABLE orders (
+	  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	  pair VARCHAR(16) NOT NULL,
+	  type VARCHAR(16) NOT NULL,
+	  side VARCHAR(8) NOT NULL,
+	  amount DOUBLE NOT NULL,
+	  price DOUBLE NOT NULL,
+	  status VARCHAR(16) NOT NULL,
+	  timestamp DATETIME NOT NULL,
+	  strategy VARCHAR(64) NOT NULL DEFAULT '',
+	  signal_price DOUBLE NOT NULL DEFAULT 0,
+	  order_key CHAR(36) NULL,
+	  UNIQUE KEY (order_key)
+	)`,
+	`CREATE TABLE candles (
+	  symbol VARCHAR(16) NOT NULL,
+	  timeframe INT NOT NULL,
+	  start DATETIME NOT NULL,
+	  open DOUBLE NOT NULL,
+	  high DOUBLE NOT NULL,
+	  low DOUBLE NOT NULL,
+	  close DOUBLE NOT NULL,
+	  volume DOUBLE NOT NULL,
+	  PRIMARY KEY (symbol, timeframe, start)
+	)`,
+	`CREATE TABLE daily_candles (
+	  symbol VARCHAR(16) NOT NULL,
+	  date DATE NOT NULL,
+	  open DOUBLE NOT NULL,
+	  high DOUBLE NOT NULL,
+	  low DOUBLE NOT NULL,
+	  close DOUBLE NOT NULL,
+	  volume DOUBLE NOT NULL,
+	  PRIMARY KEY (symbol, date)
+	)`,
+}
+
+// openDatabase returns the URL of an empty database with the bot's tables
+// and a connection to it: TRADINGBOT_TEST_DATABASE_URL, or else a MySQL
+// container removed when the test ends. The test is skipped without either.
+func openDatabase(t *testing.T) (string, *database.DB) {
+	t.Helper()
+	url := os.Getenv("TRADINGBOT_TEST_DATABASE_URL")
+	deadline := time.Now()
+	if url == "" {
+		url = startMySQL(t)
+		deadline = deadline.Add(mysqlStartup)
+	}
+
+	db, err := database.NewConnection(url)
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+		db, err = database.NewConnection(url)
+	}
+	if err != nil {
+		t.Fatalf("database not available: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to create tables: %v", err)
+		}
+	}
+	return url, db
+}
+
+// startMySQL starts a MySQL container and returns the URL of its database.
+func startMySQL(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker not found; set TRADINGBOT_TEST_DATABASE_URL to use an existing database")
+	}
+	id, err := docker("run", "-d", "--rm",
+		"-e", "MYSQL_ROOT_PASSWORD="+mysqlPassword,
+		"-e", "MYSQL_DATABASE=tradingbot",
+		"-p", "127.0.0.1::3306",
+		mysqlImage)
+	if err != nil {
+		t.Skipf("cannot start MySQL: %v", err)
+	}
+	t.Cleanup(func() { docker("rm", "-f", id) })
+
+	addr, err := docker("port", id, "3306/tcp")
+	if err != nil {
+		t.Fatalf("cannot find the MySQL port: %v", err)
+	}
+	// Docker lists a line per address family; the first is enough.
+	addr = strings.SplitN(addr, "\n", 2)[0]
+	return fmt.Sprintf("root:%s@tcp(%s)/tradingbot", mysqlPassword, addr)
+}
+
+// docker runs the docker command and returns its trimmed output.
+func docker(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// TestLockRetakenAfterReconnect swaps the database of a held lock, as a
+// password rotation does, closes the old one and drops the lock's
+// connection, and checks that the lock is taken again on the new database.
+func TestLockRetakenAfterReconnect(t *testing.T) {
+	url, db := openDatabase(t)
+	const name = "tradingbot:integration"
+	l, err := db.AcquireLock(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+
+	next, err := database.NewConnection(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.Close()
+	l.SetDB(next)
+	db.Close()
+
+	var holder int64
+	if err := next.QueryRow(`SELECT IS_USED_LOCK(?)`, name).Scan(&holder); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next.Exec(fmt.Sprintf("KILL %d", holder)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check(); err != nil {
+		t.Fatalf("check after losing the connection = %v, want the lock taken again", err)
+	}
+	var retaken int64
+	if err := next.QueryRow(`SELECT IS_USED_LOCK(?)`, name).Scan(&retaken); err != nil || retaken == holder {
+		t.Errorf("lock held by connection %d (%v), want a new one", retaken, err)
+	}
+}
@@ -0,0 +1,253 @@
+package intraday
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+)
+
+var log = logging.New()
+
+const defaultBins = 20
+
+// MinuteSource returns the latest 1m candles of a stock up to the minute of
+// until it can in one request, oldest first, e.g. the KIS client.
+type MinuteSource interface {
+	GetMinuteCandles(stockCode string, until time.Time) ([]candle.Candle, error)
+}
+
+// Bin is one price range of a volume profile, [Low, High), and the volume
+// traded in it.
+type Bin struct {
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+	Volume float64 `json:"volume"`
+}
+
+// Profile is the volume-weighted average price and volume profile of one
+// symbol's session so far. Each minute's volume is attributed to its typical
+// price, the average of its high, low and close.
+type Profile struct {
+	Symbol  string    `json:"symbol"`
+	Updated time.Time `json:"updated"`
+	Minutes int       `json:"minutes"`
+	Volume  float64   `json:"volume"`
+	VWAP    float64   `json:"vwap"`
+	Low     float64   `json:"low"`
+	High    float64   `json:"high"`
+	// POC is the point of control, the middle of the bin with the most
+	// volume.
+	POC  float64 `json:"poc"`
+	Bins []Bin   `json:"bins"`
+}
+
+// Compute returns the profile of the 1m candles of a session, split into bins
+// price bins between the session's low and high. Candles without volume only
+// widen the range.
+func Compute(symbol string, candles []candle.Candle, bins int) Profile {
+	p := Profile{Symbol: symbol, Minutes: len(candles)}
+	if len(candles) == 0 {
+		return p
+	}
+	if bins <= 0 {
+		bins = defaultBins
+	}
+	p.Low, p.High = candles[0].Low, candles[0].High
+	var value float64
+	for _, c := range candles {
+		if c.Low < p.Low {
+			p.Low = c.Low
+		}
+		if c.High > p.High {
+			p.High = c.High
+		}
+		p.Volume += c.Volume
+		value += typical(c) * c.Volume
+		if end := c.End(); end.After(p.Updated) {
+			p.Updated = end
+		}
+	}
+	if p.Volume > 0 {
+		p.VWAP = value / p.Volume
+	}
+
+	width := (p.High - p.Low) / float64(bins)
+	if width <= 0 {
+		p.Bins = []Bin{{Low: p.Low, High: p.High, Volume: p.Volume}}
+		p.POC = p.Low
+		return p
+	}
+	p.Bins = make([]Bin, bins)
+	for i := range p.Bins {
+		p.Bins[i] = Bin{Low: p.Low + float64(i)*width, High: p.Low + float64(i+1)*width}
+	}
+	for _, c := range candles {
+		i := int((typical(c) - p.Low) / width)
+		if i >= bins {
+			i = bins - 1
+		}
+		p.Bins[i].Volume += c.Volume
+	}
+	poc := 0
+	for i, b := range p.Bins {
+		if b.Volume > p.Bins[poc].Volume {
+			poc = i
+		}
+	}
+	p.POC = (p.Bins[poc].Low + p.Bins[poc].High) / 2
+	return p
+}
+
+func typical(c candle.Candle) float64 {
+	return (c.High + c.Low + c.Close) / 3
+}
+
+// session holds the 1m candles of a symbol's current KST day by start time.
+type session struct {
+	day     string
+	candles map[time.Time]candle.Candle
+}
+
+// Tracker polls the 1m candles of symbols and keeps the profile of their
+// current session. It implements strategy.VWAP and is safe for concurrent use.
+type Tracker struct {
+	source MinuteSource
+	bins   int
+	clock  clock.Clock
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// NewTracker creates a tracker reading candles from source as configured in
+// cfg.
+func NewTracker(cfg config.IntradayConfig, source MinuteSource) *Tracker {
+	return &Tracker{source: source, bins: cfg.ProfileBins, clock: clock.Real, sessions: map[string]*session{}}
+}
+
+// SetClock replaces the clock that decides the current session.
+func (t *Tracker) SetClock(c clock.Clock) {
+	t.clock = c
+}
+
+// Poll fetches the candles of symbols since their latest known minute, paging
+// back to the start of the day after a restart. Symbols that fail are logged
+// and skipped.
+func (t *Tracker) Poll(symbols []string) {
+	now := t.clock.Now()
+	for _, symbol := range symbols {
+		if err := t.poll(symbol, now); err != nil {
+			log.WithError(err).WithField("symbol", symbol).Warn("Failed to fetch minute candles")
+		}
+	}
+}
+
+func (t *Tracker) poll(symbol string, now time.Time) error {
+	day := now.In(market.KST).Format("2006-01-02")
+	t.mu.Lock()
+	s := t.sessions[symbol]
+	if s == nil || s.day != day {
+		s = &session{day: day, candles: map[time.Time]candle.Candle{}}
+		t.sessions[symbol] = s
+	}
+	var latest time.Time
+	for start := range s.candles {
+		if start.After(latest) {
+			latest = start
+		}
+	}
+	t.mu.Unlock()
+
+	until := now
+	for {
+		page, err := t.source.GetMinuteCandles(symbol, until)
+		if err != nil {
+			return err
+		}
+		added := 0
+		t.mu.Lock()
+		for _, c := range page {
+			if c.Start.In(market.KST).Format("2006-01-02") != day {
+				continue
+			}
+			// The latest minute may still have been in progress when last
+			// fetched, so it is replaced.
+			s.candles[c.Start] = c
+			added++
+		}
+		t.mu.Unlock()
+		if added == 0 || !latest.IsZero() && !page[0].Start.After(latest) || !page[0].Start.Before(until) {
+			return nil
+		}
+		until = page[0].Start.Add(-time.Second)
+	}
+}
+
+// Profile returns the profile of symbol's current session; ok is false when
+// no candles of it are known.
+func (t *Tracker) Profile(symbol string) (Profile, bool) {
+	day := t.clock.Now().In(market.KST).Format("2006-01-02")
+	t.mu.Lock()
+	s := t.sessions[symbol]
+	if s == nil || s.day != day || len(s.candles) == 0 {
+		t.mu.Unlock()
+		return Profile{}, false
+	}
+	candles := make([]candle.Candle, 0, len(s.candles))
+	for _, c := range s.candles {
+		candles = append(candles, c)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(candles, func(i, j int) bool { return candles[i].Start.Before(candles[j].Start) })
+	return Compute(symbol, candles, t.bins), true
+}
+
+// Profiles returns the profiles of every symbol with candles in its current
+// session, sorted by symbol.
+func (t *Tracker) Profiles() []Profile {
+	t.mu.Lock()
+	symbols := make([]string, 0, len(t.sessions))
+	for symbol := range t.sessions {
+		symbols = append(symbols, symbol)
+	}
+	t.mu.Unlock()
+	sort.Strings(symbols)
+
+	var profiles []Profile
+	for _, symbol := range symbols {
+		if p, ok := t.Profile(symbol); ok {
+			profiles = append(profiles, p)
+		}
+	}
+	return profiles
+}
+
+// VWAP returns the session VWAP of symbol; ok is false when there is none yet.
+func (t *Tracker) VWAP(symbol string) (float64, bool) {
+	p, ok := t.Profile(symbol)
+	if !ok || p.Volume <= 0 {
+		return 0, false
+	}
+	return p.VWAP, true
+}
+
+// Run polls symbols every interval until ctx is cancelled, starting at once.
+func (t *Tracker) Run(ctx context.Context, symbols []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.Poll(symbols)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
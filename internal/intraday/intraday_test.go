@@ -0,0 +1,94 @@
+package intraday
+
+import (
+	"testing"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+)
+
+func minute(start time.Time, price, volume float64) candle.Candle {
+	return candle.Candle{Symbol: "005930", Start: start, Timeframe: time.Minute, Open: price, High: price, Low: price, Close: price, Volume: volume}
+}
+
+func TestCompute(t *testing.T) {
+	open := time.Date(2026, 3, 3, 9, 0, 0, 0, market.KST)
+	candles := []candle.Candle{
+		minute(open, 100, 10),
+		minute(open.Add(time.Minute), 110, 30),
+		minute(open.Add(2*time.Minute), 120, 10),
+	}
+
+	p := Compute("005930", candles, 2)
+	if p.VWAP != 110 || p.Volume != 50 || p.Low != 100 || p.High != 120 {
+		t.Fatalf("profile %+v, want VWAP 110 over volume 50 between 100 and 120", p)
+	}
+	// 110 falls into the upper of the two bins, together with 120.
+	if len(p.Bins) != 2 || p.Bins[0].Volume != 10 || p.Bins[1].Volume != 40 {
+		t.Fatalf("bins %+v, want volumes 10 and 40", p.Bins)
+	}
+	if p.POC != 115 {
+		t.Errorf("POC %v, want 115", p.POC)
+	}
+	if !p.Updated.Equal(open.Add(3 * time.Minute)) {
+		t.Errorf("updated %v, want the end of the last minute", p.Updated)
+	}
+}
+
+// fakeMinutes serves the day's 1m candles, at most two per request.
+type fakeMinutes struct {
+	candles  []candle.Candle
+	requests int
+}
+
+func (f *fakeMinutes) GetMinuteCandles(stockCode string, until time.Time) ([]candle.Candle, error) {
+	f.requests++
+	var page []candle.Candle
+	for _, c := range f.candles {
+		if !c.Start.After(until) {
+			page = append(page, c)
+		}
+	}
+	if len(page) > 2 {
+		page = page[len(page)-2:]
+	}
+	return page, nil
+}
+
+func TestTrackerPagesBackThenFollows(t *testing.T) {
+	open := time.Date(2026, 3, 3, 9, 0, 0, 0, market.KST)
+	source := &fakeMinutes{candles: []candle.Candle{
+		minute(open.Add(-24*time.Hour), 500, 100),
+		minute(open, 100, 10),
+		minute(open.Add(time.Minute), 100, 10),
+		minute(open.Add(2*time.Minute), 100, 10),
+		minute(open.Add(3*time.Minute), 200, 10),
+	}}
+	c := clock.NewSimulated(open.Add(4 * time.Minute))
+	tr := NewTracker(config.IntradayConfig{}, source)
+	tr.SetClock(c)
+
+	tr.Poll([]string{"005930"})
+	vwap, ok := tr.VWAP("005930")
+	if !ok || vwap != 125 {
+		t.Fatalf("VWAP %v, %v, want 125 from the day's candles only", vwap, ok)
+	}
+
+	source.candles = append(source.candles, minute(open.Add(4*time.Minute), 200, 40))
+	c.Set(open.Add(5 * time.Minute))
+	source.requests = 0
+	tr.Poll([]string{"005930"})
+	if source.requests != 1 {
+		t.Errorf("%d requests, want 1 once caught up", source.requests)
+	}
+	if vwap, _ := tr.VWAP("005930"); vwap != 162.5 {
+		t.Errorf("VWAP %v, want 162.5", vwap)
+	}
+
+	c.Set(open.Add(24 * time.Hour))
+	if _, ok := tr.VWAP("005930"); ok {
+		t.Error("VWAP of the previous session reported on a new day")
+	}
+}
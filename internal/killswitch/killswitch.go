@@ -0,0 +1,117 @@
+package killswitch
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+// Sources of a kill.
+const (
+	SourceFile     = "file"
+	SourceAPI      = "api"
+	SourceTelegram = "telegram"
+)
+
+const defaultCheckInterval = 2 * time.Second
+
+// Exchange is the part of the exchange client a kill needs, e.g.
+// exchange.KISExchange.
+type Exchange interface {
+	Halt()
+	GetOpenOrders() ([]models.OpenOrder, error)
+	CancelOrder(order models.OpenOrder) error
+}
+
+// Switch stops trading when triggered; see config.KillSwitchConfig. It is safe
+// for concurrent use.
+type Switch struct {
+	exch   Exchange
+	bus    *events.Bus
+	onKill func()
+	clock  clock.Clock
+
+	mu     sync.Mutex
+	killed *events.KillEvent
+}
+
+// New creates a switch halting exch and publishing a KillEvent on bus. onKill,
+// if set, is called right after the exchange is halted, e.g. to pause the
+// trading loop.
+func New(exch Exchange, bus *events.Bus, onKill func()) *Switch {
+	return &Switch{exch: exch, bus: bus, onKill: onKill, clock: clock.Real}
+}
+
+// Kill halts new orders at once, then cancels the open orders. Only the first
+// call does so; later ones return its outcome.
+func (s *Switch) Kill(source, reason string) events.KillEvent {
+	s.mu.Lock()
+	if s.killed != nil {
+		defer s.mu.Unlock()
+		return *s.killed
+	}
+	s.exch.Halt()
+	if s.onKill != nil {
+		s.onKill()
+	}
+	log.WithFields(logrus.Fields{"source": source, "reason": reason}).Error("Kill switch triggered, new orders halted")
+
+	ev := events.KillEvent{Source: source, Reason: reason}
+	orders, err := s.exch.GetOpenOrders()
+	if err != nil {
+		log.WithError(err).Error("Kill switch could not list open orders; cancel them manually")
+		ev.Err = err
+	}
+	for _, order := range orders {
+		if err := s.exch.CancelOrder(order); err != nil {
+			ev.Failed++
+			log.WithError(err).WithField("order", order.OrderNo).Error("Kill switch failed to cancel order")
+			continue
+		}
+		ev.Cancelled++
+	}
+	ev.Time = s.clock.Now()
+	s.killed = &ev
+	s.mu.Unlock()
+
+	log.WithFields(logrus.Fields{"cancelled": ev.Cancelled, "failed": ev.Failed}).Error("Trading stopped by the kill switch until restart")
+	s.bus.Publish(ev)
+	return ev
+}
+
+// Killed tells whether Kill was called.
+func (s *Switch) Killed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.killed != nil
+}
+
+// WatchFile kills when a file appears at path, checking every interval (default
+// 2s) until ctx is done.
+func (s *Switch) WatchFile(ctx context.Context, path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := os.Stat(path); err == nil {
+			s.Kill(SourceFile, "kill file "+path+" created")
+			return
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package killswitch
+
+import (
+	"errors"
+	"testing"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+type fakeExchange struct {
+	halted    bool
+	open      []models.OpenOrder
+	cancelled []string
+}
+
+func (f *fakeExchange) Halt() { f.halted = true }
+
+func (f *fakeExchange) GetOpenOrders() ([]models.OpenOrder, error) { return f.open, nil }
+
+func (f *fakeExchange) CancelOrder(order models.OpenOrder) error {
+	if order.OrderNo == "2" {
+		return errors.New("order already filled")
+	}
+	f.cancelled = append(f.cancelled, order.OrderNo)
+	return nil
+}
+
+func TestKillHaltsAndCancelsOnce(t *testing.T) {
+	exch := &fakeExchange{open: []models.OpenOrder{{OrderNo: "1"}, {OrderNo: "2"}, {OrderNo: "3"}}}
+	bus := events.NewBus()
+	var published []events.KillEvent
+	bus.Subscribe(func(ev events.Event) { published = append(published, ev.(events.KillEvent)) }, events.KindKill)
+	paused := false
+	s := New(exch, bus, func() {
+		if !exch.halted {
+			t.Error("trading loop paused before the exchange was halted")
+		}
+		paused = true
+	})
+
+	ev := s.Kill(SourceAPI, "runaway strategy")
+	if !exch.halted || !paused || !s.Killed() {
+		t.Fatalf("halted %v, paused %v, killed %v, want all set", exch.halted, paused, s.Killed())
+	}
+	if ev.Cancelled != 2 || ev.Failed != 1 || len(exch.cancelled) != 2 {
+		t.Errorf("event %+v, cancelled %v, want 2 cancelled and 1 failed", ev, exch.cancelled)
+	}
+
+	again := s.Kill(SourceFile, "")
+	if again.Source != SourceAPI || len(exch.cancelled) != 2 || len(published) != 1 {
+		t.Errorf("second kill %+v with %d events, want the first one's outcome and nothing redone", again, len(published))
+	}
+}
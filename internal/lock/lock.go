@@ -0,0 +1,181 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"tradingbot/internal/logging"
+)
+
+var log = logging.New()
+
+// Lock is held by the one instance allowed to trade an account; see
+// config.LockConfig.
+type Lock interface {
+	// Check returns an error if the lock is no longer held, after trying to
+	// take it back.
+	Check() error
+	// Release gives the lock up.
+	Release() error
+}
+
+// Owner describes the instance holding a file lock.
+type Owner struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Account string    `json:"account"`
+	Started time.Time `json:"started"`
+}
+
+// File is a lock file holding its owner. A lock file left behind by a process
+// that is gone, e.g. after a crash, is taken over.
+type File struct {
+	path  string
+	owner Owner
+}
+
+// AcquireFile creates the lock file at path for account, creating its
+// directory if needed. It fails, naming the owner, if another live process
+// holds it.
+func AcquireFile(path, account string) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %v", err)
+	}
+	host, _ := os.Hostname()
+	l := &File{path: path, owner: Owner{PID: os.Getpid(), Host: host, Account: account, Started: time.Now()}}
+	if err := l.create(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *File) create() error {
+	// Two attempts: the second follows the removal of a stale lock.
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			werr := json.NewEncoder(f).Encode(l.owner)
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				os.Remove(l.path)
+				return fmt.Errorf("failed to write lock file: %v", werr)
+			}
+			return nil
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to create lock file: %v", err)
+		}
+
+		owner, err := l.read()
+		if os.IsNotExist(err) {
+			// Released in the meantime.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if alive(owner) {
+			return fmt.Errorf("account %s is already traded by pid %d on %s since %s (lock file %s)",
+				owner.Account, owner.PID, owner.Host, owner.Started.Format(time.RFC3339), l.path)
+		}
+		log.WithField("pid", owner.PID).WithField("path", l.path).Warn("Taking over the lock of a process that is gone")
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale lock file: %v", err)
+		}
+	}
+	return fmt.Errorf("lock file %s keeps being recreated by another process", l.path)
+}
+
+func (l *File) read() (Owner, error) {
+	var owner Owner
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return owner, err
+	}
+	if err != nil {
+		return owner, fmt.Errorf("failed to read lock file: %v", err)
+	}
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return owner, fmt.Errorf("lock file %s is corrupt, remove it if no other instance runs: %v", l.path, err)
+	}
+	return owner, nil
+}
+
+// alive tells whether the owner of a lock may still be running. Processes on
+// other hosts cannot be checked and are taken to be.
+func alive(owner Owner) bool {
+	if host, _ := os.Hostname(); owner.Host != host {
+		return true
+	}
+	p, err := os.FindProcess(owner.PID)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Check verifies the lock file is still ours and recreates it if it was
+// removed.
+func (l *File) Check() error {
+	owner, err := l.read()
+	if os.IsNotExist(err) {
+		return l.create()
+	}
+	if err != nil {
+		return err
+	}
+	if owner.PID != l.owner.PID || owner.Host != l.owner.Host {
+		return fmt.Errorf("lock file %s was taken by pid %d on %s", l.path, owner.PID, owner.Host)
+	}
+	return nil
+}
+
+// Release removes the lock file if it is still ours.
+func (l *File) Release() error {
+	owner, err := l.read()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if owner.PID != l.owner.PID || owner.Host != l.owner.Host {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil {
+		return fmt.Errorf("failed to remove lock file: %v", err)
+	}
+	return nil
+}
+
+// Watch checks l every interval until ctx is done. onLost is called when a
+// check fails after succeeding, and onRegained when it succeeds again.
+func Watch(ctx context.Context, l Lock, interval time.Duration, onLost func(error), onRegained func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	held := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := l.Check()
+		switch {
+		case err != nil && held:
+			held = false
+			onLost(err)
+		case err == nil && !held:
+			held = true
+			onRegained()
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package lock
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileLockRefusesSecondInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "tradingbot.lock")
+	first, err := AcquireFile(path, "12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = AcquireFile(path, "12345678")
+	if err == nil || !strings.Contains(err.Error(), "already traded by pid") {
+		t.Fatalf("second acquire = %v, want it refused naming the owner", err)
+	}
+	if err := first.Check(); err != nil {
+		t.Errorf("check = %v, want the lock still held", err)
+	}
+
+	// Recreated by the owner if removed behind its back.
+	os.Remove(path)
+	if err := first.Check(); err != nil {
+		t.Errorf("check after removal = %v, want the lock recreated", err)
+	}
+	if err := first.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("lock file still there after release: %v", err)
+	}
+
+	// A lock left by a process that is gone is taken over.
+	host, _ := os.Hostname()
+	stale, _ := json.Marshal(Owner{PID: 1 << 22, Host: host, Account: "12345678", Started: time.Now()})
+	os.WriteFile(path, stale, 0644)
+	second, err := AcquireFile(path, "12345678")
+	if err != nil {
+		t.Fatalf("acquire over a stale lock = %v", err)
+	}
+	second.Release()
+}
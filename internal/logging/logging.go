@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+	"tradingbot/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	mu      sync.Mutex
+	loggers []*logrus.Logger
+	sinks   []*sink
+	level   = logrus.InfoLevel
+)
+
+// New returns a logger that follows the sinks and levels set with Configure and
+// SetLevel. Packages declare theirs as `var log = logging.New()`.
+func New() *logrus.Logger {
+	l := logrus.New()
+
+	mu.Lock()
+	defer mu.Unlock()
+	loggers = append(loggers, l)
+	apply(l)
+	return l
+}
+
+// Configure replaces the sinks of every logger. defaultLevel is used by sinks
+// without their own level; an empty value keeps the current one. Previously
+// configured sinks are closed.
+func Configure(cfg config.LoggingConfig, defaultLevel string) error {
+	next := make([]*sink, 0, len(cfg.Sinks))
+	for _, sc := range cfg.Sinks {
+		s, err := newSink(sc)
+		if err != nil {
+			closeSinks(next)
+			return err
+		}
+		next = append(next, s)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if defaultLevel != "" {
+		parsed, err := logrus.ParseLevel(defaultLevel)
+		if err != nil {
+			closeSinks(next)
+			return err
+		}
+		level = parsed
+	}
+	old := sinks
+	sinks = next
+	for _, l := range loggers {
+		apply(l)
+	}
+	closeSinks(old)
+	return nil
+}
+
+// SetLevel changes the default level, e.g. after a config reload.
+func SetLevel(defaultLevel string) error {
+	parsed, err := logrus.ParseLevel(defaultLevel)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	level = parsed
+	for _, l := range loggers {
+		apply(l)
+	}
+	return nil
+}
+
+// Close flushes and closes the configured sinks; loggers fall back to stdout.
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	old := sinks
+	sinks = nil
+	for _, l := range loggers {
+		apply(l)
+	}
+	closeSinks(old)
+}
+
+// apply points l at the current sinks. The logger's own level is the most
+// verbose sink level so that every sink receives what it asked for; each sink
+// drops the rest. Must be called with mu held.
+func apply(l *logrus.Logger) {
+	hooks := make(logrus.LevelHooks)
+	if len(sinks) == 0 {
+		l.ReplaceHooks(hooks)
+		l.SetOutput(os.Stdout)
+		l.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+		l.SetLevel(level)
+		return
+	}
+
+	lowest := logrus.PanicLevel
+	for _, s := range sinks {
+		hooks.Add(s)
+		s.mu.Lock()
+		s.defaultLevel = level
+		s.mu.Unlock()
+		if lvl := s.effectiveLevel(level); lvl > lowest {
+			lowest = lvl
+		}
+	}
+	l.ReplaceHooks(hooks)
+	l.SetOutput(ioutil.Discard)
+	l.SetLevel(lowest)
+}
+
+func closeSinks(list []*sink) {
+	for _, s := range list {
+		if s.closer != nil {
+			s.closer.Close()
+		}
+	}
+}
+
+// sink is a logrus hook writing entries at or above its level to one destination.
+type sink struct {
+	level        *logrus.Level
+	defaultLevel logrus.Level
+	formatter    logrus.Formatter
+	write        func(level logrus.Level, line []byte) error
+	closer       io.Closer
+
+	mu sync.Mutex
+}
+
+func newSink(cfg config.LogSinkConfig) (*sink, error) {
+	s := &sink{formatter: &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}}
+	if cfg.Format == "json" {
+		s.formatter = &logrus.JSONFormatter{}
+	}
+	if cfg.Level != "" {
+		parsed, err := logrus.ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, err
+		}
+		s.level = &parsed
+	}
+
+	switch cfg.Type {
+	case config.LogSinkStdout:
+		s.write = writerFunc(os.Stdout)
+	case config.LogSinkStderr:
+		s.write = writerFunc(os.Stderr)
+	case config.LogSinkFile:
+		var maxAge time.Duration
+		if cfg.MaxAge != "" {
+			var err error
+			if maxAge, err = time.ParseDuration(cfg.MaxAge); err != nil {
+				return nil, fmt.Errorf("invalid max_age %q: %v", cfg.MaxAge, err)
+			}
+		}
+		f, err := openRotatingFile(cfg.Path, int64(cfg.MaxSizeMB)<<20, maxAge, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		s.write = writerFunc(f)
+		s.closer = f
+	case config.LogSinkSyslog:
+		w, err := dialSyslog(cfg.Network, cfg.Address, cfg.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog: %v", err)
+		}
+		// Syslog records its own timestamp and severity.
+		s.formatter = &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true}
+		if cfg.Format == "json" {
+			s.formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+		}
+		s.write = w.write
+		s.closer = w
+	default:
+		return nil, fmt.Errorf("unknown log sink %q", cfg.Type)
+	}
+	return s, nil
+}
+
+func writerFunc(w io.Writer) func(logrus.Level, []byte) error {
+	return func(_ logrus.Level, line []byte) error {
+		_, err := w.Write(line)
+		return err
+	}
+}
+
+func (s *sink) effectiveLevel(defaultLevel logrus.Level) logrus.Level {
+	if s.level != nil {
+		return *s.level
+	}
+	return defaultLevel
+}
+
+// Levels registers the sink for every level; Fire filters, so that level changes
+// take effect without re-adding hooks.
+func (s *sink) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (s *sink) Fire(entry *logrus.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.Level > s.effectiveLevel(s.defaultLevel) {
+		return nil
+	}
+	line, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	return s.write(entry.Level, line)
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+)
+
+func TestSinkLevels(t *testing.T) {
+	dir := t.TempDir()
+	debugPath := filepath.Join(dir, "debug.log")
+	warnPath := filepath.Join(dir, "warn.log")
+
+	err := Configure(config.LoggingConfig{Sinks: []config.LogSinkConfig{
+		{Type: config.LogSinkFile, Path: debugPath, Level: "debug"},
+		{Type: config.LogSinkFile, Path: warnPath, Format: "json"},
+	}}, "warn")
+	if err != nil {
+		t.Fatalf("Configure returned error: %v", err)
+	}
+	defer Close()
+
+	log := New()
+	log.Debug("debug message")
+	log.Warn("warn message")
+
+	// The second sink follows the default level, which can change at runtime.
+	if err := SetLevel("info"); err != nil {
+		t.Fatalf("SetLevel returned error: %v", err)
+	}
+	log.Info("info message")
+
+	debugLog, _ := ioutil.ReadFile(debugPath)
+	for _, want := range []string{"debug message", "warn message", "info message"} {
+		if !strings.Contains(string(debugLog), want) {
+			t.Errorf("debug sink is missing %q:\n%s", want, debugLog)
+		}
+	}
+	warnLog, _ := ioutil.ReadFile(warnPath)
+	if strings.Contains(string(warnLog), "debug message") {
+		t.Errorf("warn sink received a debug message:\n%s", warnLog)
+	}
+	for _, want := range []string{`"msg":"warn message"`, `"msg":"info message"`} {
+		if !strings.Contains(string(warnLog), want) {
+			t.Errorf("warn sink is missing %s:\n%s", want, warnLog)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bot.log")
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	f, err := openRotatingFile(path, 10, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned error: %v", err)
+	}
+	defer f.Close()
+	f.now = func() time.Time { return now }
+	f.opened = now
+
+	write := func(s string) {
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	write("12345678\n")
+	write("abcdefgh\n") // exceeds 10 bytes -> rotate
+	now = now.Add(time.Minute)
+	write("ABCDEFGH\n") // rotate again
+	now = now.Add(2 * time.Hour)
+	write("x\n") // too old -> rotate, oldest backup pruned
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "bot-*.log"))
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2", backups)
+	}
+	oldest, _ := ioutil.ReadFile(backups[0])
+	if string(oldest) != "abcdefgh\n" {
+		t.Errorf("oldest kept backup = %q, want %q", oldest, "abcdefgh\n")
+	}
+	current, _ := ioutil.ReadFile(path)
+	if string(current) != "x\n" {
+		t.Errorf("current file = %q, want %q", current, "x\n")
+	}
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupTimeLayout = "20060102T150405"
+
+// rotatingFile is an append-only log file that is renamed to
+// "<name>-<timestamp><ext>" once it grows past maxSize bytes or has been open
+// longer than maxAge. Zero disables the respective limit. Only the newest
+// maxBackups rotated files are kept, or all of them when it is zero. It is not
+// safe for concurrent use; sinks serialize writes.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	r.opened = r.now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size > 0 && r.due(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) due(next int64) bool {
+	if r.maxSize > 0 && r.size+next > r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && r.now().Sub(r.opened) >= r.maxAge
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %v", err)
+	}
+
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(r.path, ext)
+	backup := fmt.Sprintf("%s-%s%s", base, r.now().Format(backupTimeLayout), ext)
+	for i := 1; fileExists(backup); i++ {
+		backup = fmt.Sprintf("%s-%s.%d%s", base, r.now().Format(backupTimeLayout), i, ext)
+	}
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %v", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune(base, ext)
+	return nil
+}
+
+// prune removes the oldest backups beyond maxBackups. Backup names sort by
+// their timestamp.
+func (r *rotatingFile) prune(base, ext string) {
+	if r.maxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(base + "-*" + ext)
+	if err != nil || len(backups) <= r.maxBackups {
+		return
+	}
+	sort.Strings(backups)
+	for _, old := range backups[:len(backups)-r.maxBackups] {
+		os.Remove(old)
+	}
+}
+
+func (r *rotatingFile) Close() error {
+	return r.file.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
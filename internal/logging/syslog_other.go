@@ -0,0 +1,19 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+type syslogWriter struct{}
+
+func dialSyslog(network, address, tag string) (*syslogWriter, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (s *syslogWriter) write(level logrus.Level, line []byte) error { return nil }
+
+func (s *syslogWriter) Close() error { return nil }
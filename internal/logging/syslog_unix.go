@@ -0,0 +1,47 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"log/syslog"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultSyslogTag = "tradingbot"
+
+// syslogWriter sends each entry with the syslog severity matching its level.
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func dialSyslog(network, address, tag string) (*syslogWriter, error) {
+	if tag == "" {
+		tag = defaultSyslogTag
+	}
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) write(level logrus.Level, line []byte) error {
+	msg := string(line)
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return s.w.Crit(msg)
+	case logrus.ErrorLevel:
+		return s.w.Err(msg)
+	case logrus.WarnLevel:
+		return s.w.Warning(msg)
+	case logrus.InfoLevel:
+		return s.w.Info(msg)
+	default:
+		return s.w.Debug(msg)
+	}
+}
+
+func (s *syslogWriter) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,290 @@
+package market
+
+import (
+	"fmt"
+	"time"
+	"tradingbot/internal/config"
+)
+
+// KST is Korea Standard Time. Korea observes no daylight saving, so a fixed zone
+// avoids depending on the system tz database.
+var KST = time.FixedZone("KST", 9*60*60)
+
+const dateLayout = "2006-01-02"
+
+// Regular KRX continuous session; there is no lunch break.
+var regularHours = Hours{Open: 9 * time.Hour, Close: 15*time.Hour + 30*time.Minute}
+
+// annualHolidays are fixed-date closures observed every year, including the
+// year-end closing day (Dec 31).
+var annualHolidays = []string{"01-01", "03-01", "05-01", "05-05", "06-06", "08-15", "10-03", "10-09", "12-25", "12-31"}
+
+// krxHolidays are the remaining closures (lunar holidays, substitute holidays and
+// elections) published by KRX for each year.
+var krxHolidays = []string{
+	// 2024
+	"2024-02-09", "2024-02-12", "2024-04-10", "2024-05-06", "2024-05-15", "2024-09-16",
+	"2024-09-17", "2024-09-18", "2024-10-01",
+	// 2025
+	"2025-01-27", "2025-01-28", "2025-01-29", "2025-01-30", "2025-03-03", "2025-05-06",
+	"2025-06-03", "2025-10-06", "2025-10-07", "2025-10-08",
+	// 2026
+	"2026-02-16", "2026-02-17", "2026-02-18", "2026-03-02", "2026-05-25", "2026-06-03",
+	"2026-08-17", "2026-09-24", "2026-09-25", "2026-10-05",
+}
+
+// The first trading day of every year opens an hour late.
+var yearOpeningHours = Hours{Open: 10 * time.Hour, Close: regularHours.Close}
+
+// krxSpecialSessions are other days with shifted hours, such as the college
+// entrance exam (수능) day, which opens and closes an hour late.
+var krxSpecialSessions = map[string]Hours{
+	"2024-11-14": {Open: 10 * time.Hour, Close: 16*time.Hour + 30*time.Minute},
+	"2025-11-13": {Open: 10 * time.Hour, Close: 16*time.Hour + 30*time.Minute},
+	"2026-11-19": {Open: 10 * time.Hour, Close: 16*time.Hour + 30*time.Minute},
+}
+
+// Hours is a trading session expressed as offsets from midnight KST.
+type Hours struct {
+	Open  time.Duration
+	Close time.Duration
+}
+
+// Session is a concrete trading session on a given day.
+type Session struct {
+	Open  time.Time
+	Close time.Time
+}
+
+// Calendar knows when the KRX equity market is open.
+type Calendar struct {
+	holidays map[string]bool
+	special  map[string]Hours
+}
+
+// NewCalendar builds the KRX calendar, adding the holidays and special sessions
+// from the config on top of the built-in ones.
+func NewCalendar(cfg config.MarketConfig) (*Calendar, error) {
+	c := &Calendar{holidays: map[string]bool{}, special: map[string]Hours{}}
+	for _, day := range krxHolidays {
+		c.holidays[day] = true
+	}
+	for day, hours := range krxSpecialSessions {
+		c.special[day] = hours
+	}
+
+	for _, day := range cfg.ExtraHolidays {
+		if _, err := time.Parse(dateLayout, day); err != nil {
+			return nil, fmt.Errorf("invalid holiday %q: %v", day, err)
+		}
+		c.holidays[day] = true
+	}
+	for day, session := range cfg.SpecialSessions {
+		if _, err := time.Parse(dateLayout, day); err != nil {
+			return nil, fmt.Errorf("invalid special session date %q: %v", day, err)
+		}
+		hours, err := parseHours(session)
+		if err != nil {
+			return nil, fmt.Errorf("invalid special session on %s: %v", day, err)
+		}
+		c.special[day] = hours
+	}
+	return c, nil
+}
+
+// SessionOn returns the session on the calendar day of t in KST, or false when
+// the market is closed all day.
+func (c *Calendar) SessionOn(t time.Time) (Session, bool) {
+	t = t.In(KST)
+	if !c.isTradingDay(t) {
+		return Session{}, false
+	}
+
+	hours := regularHours
+	if special, ok := c.special[t.Format(dateLayout)]; ok {
+		hours = special
+	} else if c.isFirstTradingDayOfYear(t) {
+		hours = yearOpeningHours
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, KST)
+	return Session{Open: midnight.Add(hours.Open), Close: midnight.Add(hours.Close)}, true
+}
+
+func (c *Calendar) isTradingDay(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	if c.holidays[t.Format(dateLayout)] {
+		return false
+	}
+	monthDay := t.Format("01-02")
+	for _, holiday := range annualHolidays {
+		if holiday == monthDay {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Calendar) isFirstTradingDayOfYear(t time.Time) bool {
+	for d := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, KST); d.YearDay() < t.YearDay(); d = d.AddDate(0, 0, 1) {
+		if c.isTradingDay(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// NextSession returns the session in progress at t or, outside trading hours,
+// the next one to start. It returns false when there is no session within a month.
+func (c *Calendar) NextSession(t time.Time) (Session, bool) {
+	day := t.In(KST)
+	for i := 0; i < 30; i++ {
+		if session, ok := c.SessionOn(day); ok && t.Before(session.Close) {
+			return session, true
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, KST)
+	}
+	return Session{}, false
+}
+
+// IsOpen reports whether the market is in session at t.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	session, ok := c.SessionOn(t)
+	return ok && !t.Before(session.Open) && t.Before(session.Close)
+}
+
+// NextOpen returns the start of the next session after t, or t itself when the
+// market is already open.
+func (c *Calendar) NextOpen(t time.Time) time.Time {
+	if c.IsOpen(t) {
+		return t
+	}
+	day := t.In(KST)
+	for i := 0; i < 30; i++ {
+		if session, ok := c.SessionOn(day); ok && t.Before(session.Open) {
+			return session.Open
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, KST)
+	}
+	// No session within a month means the calendar is misconfigured; retry tomorrow.
+	return t.Add(24 * time.Hour)
+}
+
+func parseHours(s config.SessionHours) (Hours, error) {
+	open, err := parseClock(s.Open)
+	if err != nil {
+		return Hours{}, err
+	}
+	close, err := parseClock(s.Close)
+	if err != nil {
+		return Hours{}, err
+	}
+	if close <= open {
+		return Hours{}, fmt.Errorf("close %s is not after open %s", s.Close, s.Open)
+	}
+	return Hours{Open: open, Close: close}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// sessionWindow is a session relative to the regular session of its day.
+type sessionWindow struct {
+	name        string
+	start, end  time.Duration
+	fromOpening bool // offsets from the open instead of the close
+}
+
+// krxSessions are the sessions around the regular one, in KRX order. They
+// shift with the regular session on special days.
+var krxSessions = []sessionWindow{
+	{config.SessionPreMarket, -30 * time.Minute, -20 * time.Minute, true},
+	{config.SessionClosingAuction, -10 * time.Minute, 0, false},
+	{config.SessionAfterHoursClose, 10 * time.Minute, 30 * time.Minute, false},
+	{config.SessionAfterHoursSingle, 30 * time.Minute, 150 * time.Minute, false},
+}
+
+// SessionAt returns the session in progress at t: config.SessionRegular, the
+// closing auction or one of the off-hours sessions. It returns false when none
+// is.
+func (c *Calendar) SessionAt(t time.Time) (string, bool) {
+	session, ok := c.SessionOn(t)
+	if !ok {
+		return "", false
+	}
+	for _, w := range krxSessions {
+		start, end := w.bounds(session)
+		if !t.Before(start) && t.Before(end) {
+			return w.name, true
+		}
+	}
+	if !t.Before(session.Open) && t.Before(session.Close) {
+		return config.SessionRegular, true
+	}
+	return "", false
+}
+
+// IsTrading reports whether the regular session or one of the extended
+// sessions is in progress at t.
+func (c *Calendar) IsTrading(t time.Time, extended []string) bool {
+	name, ok := c.SessionAt(t)
+	if !ok {
+		return false
+	}
+	if name == config.SessionRegular || name == config.SessionClosingAuction {
+		return true
+	}
+	for _, e := range extended {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NextTrading returns the start of the next regular or extended session after
+// t, or t itself when one is in progress.
+func (c *Calendar) NextTrading(t time.Time, extended []string) time.Time {
+	if c.IsTrading(t, extended) {
+		return t
+	}
+	day := t.In(KST)
+	for i := 0; i < 30; i++ {
+		if session, ok := c.SessionOn(day); ok {
+			starts := []time.Time{session.Open}
+			for _, w := range krxSessions {
+				for _, e := range extended {
+					if e == w.name {
+						start, _ := w.bounds(session)
+						starts = append(starts, start)
+					}
+				}
+			}
+			var next time.Time
+			for _, start := range starts {
+				if t.Before(start) && (next.IsZero() || start.Before(next)) {
+					next = start
+				}
+			}
+			if !next.IsZero() {
+				return next
+			}
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, KST)
+	}
+	return t.Add(24 * time.Hour)
+}
+
+func (w sessionWindow) bounds(session Session) (time.Time, time.Time) {
+	base := session.Close
+	if w.fromOpening {
+		base = session.Open
+	}
+	return base.Add(w.start), base.Add(w.end)
+}
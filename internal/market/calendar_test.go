@@ -0,0 +1,130 @@
+package market
+
+import (
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+)
+
+func kst(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, KST)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestCalendarIsOpen(t *testing.T) {
+	cal, err := NewCalendar(config.MarketConfig{ExtraHolidays: []string{"2026-10-21"}})
+	if err != nil {
+		t.Fatalf("NewCalendar returned error: %v", err)
+	}
+
+	tests := []struct {
+		at   string
+		open bool
+	}{
+		{"2026-10-16 08:59", false},
+		{"2026-10-16 09:00", true},
+		{"2026-10-16 12:00", true}, // no lunch break
+		{"2026-10-16 15:30", false},
+		{"2026-10-17 10:00", false}, // Saturday
+		{"2026-10-09 10:00", false}, // Hangul day
+		{"2026-10-21 10:00", false}, // extra holiday
+		{"2026-01-02 09:30", false}, // first trading day opens at 10:00
+		{"2026-11-19 16:00", true},  // exam day closes at 16:30
+	}
+	for _, tt := range tests {
+		if got := cal.IsOpen(kst(tt.at)); got != tt.open {
+			t.Errorf("IsOpen(%s) = %v, want %v", tt.at, got, tt.open)
+		}
+	}
+}
+
+func TestCalendarNextOpen(t *testing.T) {
+	cal, err := NewCalendar(config.MarketConfig{})
+	if err != nil {
+		t.Fatalf("NewCalendar returned error: %v", err)
+	}
+
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{"2026-10-16 16:00", "2026-10-19 09:00"}, // Friday evening -> Monday
+		{"2026-10-08 16:00", "2026-10-12 09:00"}, // skips Hangul day and the weekend
+		{"2026-10-16 07:00", "2026-10-16 09:00"},
+		{"2026-12-30 16:00", "2027-01-04 10:00"}, // year-end closing, New Year, weekend; late first open
+	}
+	for _, tt := range tests {
+		if got := cal.NextOpen(kst(tt.at)); !got.Equal(kst(tt.want)) {
+			t.Errorf("NextOpen(%s) = %s, want %s", tt.at, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+func TestCalendarNextSession(t *testing.T) {
+	cal, err := NewCalendar(config.MarketConfig{})
+	if err != nil {
+		t.Fatalf("NewCalendar returned error: %v", err)
+	}
+
+	tests := []struct {
+		at    string
+		close string
+	}{
+		{"2026-10-16 12:00", "2026-10-16 15:30"}, // in session
+		{"2026-10-16 15:30", "2026-10-19 15:30"}, // at the close -> Monday
+		{"2026-11-19 07:00", "2026-11-19 16:30"}, // exam day
+	}
+	for _, tt := range tests {
+		session, ok := cal.NextSession(kst(tt.at))
+		if !ok || !session.Close.Equal(kst(tt.close)) {
+			t.Errorf("NextSession(%s) closes at %s, want %s", tt.at, session.Close.Format("2006-01-02 15:04"), tt.close)
+		}
+	}
+}
+
+func TestCalendarExtendedSessions(t *testing.T) {
+	cal, err := NewCalendar(config.MarketConfig{})
+	if err != nil {
+		t.Fatalf("NewCalendar returned error: %v", err)
+	}
+
+	sessions := []struct {
+		at, name string
+	}{
+		{"2026-10-16 08:29", ""},
+		{"2026-10-16 08:35", config.SessionPreMarket},
+		{"2026-10-16 08:45", ""},
+		{"2026-10-16 10:00", config.SessionRegular},
+		{"2026-10-16 15:25", config.SessionClosingAuction},
+		{"2026-10-16 15:35", ""},
+		{"2026-10-16 15:50", config.SessionAfterHoursClose},
+		{"2026-10-16 17:00", config.SessionAfterHoursSingle},
+		{"2026-10-16 18:00", ""},
+		{"2026-11-19 16:50", config.SessionAfterHoursClose}, // shifted an hour on exam day
+	}
+	for _, tt := range sessions {
+		if got, _ := cal.SessionAt(kst(tt.at)); got != tt.name {
+			t.Errorf("SessionAt(%s) = %q, want %q", tt.at, got, tt.name)
+		}
+	}
+
+	extended := []string{config.SessionAfterHoursClose}
+	if cal.IsTrading(kst("2026-10-16 17:00"), extended) || !cal.IsTrading(kst("2026-10-16 15:50"), extended) {
+		t.Error("IsTrading does not follow the enabled sessions")
+	}
+	next := []struct {
+		at, want string
+	}{
+		{"2026-10-16 15:35", "2026-10-16 15:40"},
+		{"2026-10-16 16:10", "2026-10-19 09:00"},
+		{"2026-10-16 10:00", "2026-10-16 10:00"},
+	}
+	for _, tt := range next {
+		if got := cal.NextTrading(kst(tt.at), extended); !got.Equal(kst(tt.want)) {
+			t.Errorf("NextTrading(%s) = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package marketdata
+
+import (
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+)
+
+const (
+	defaultCacheCandles = 100000
+	defaultCacheTTL     = time.Minute
+)
+
+// daily is the timeframe daily candles are cached under.
+const daily = 24 * time.Hour
+
+// Cached is a Provider serving daily candles from a candle.Cache while they
+// are fresher than the configured TTL; see config.CandleCacheConfig. Quotes
+// and bars are passed through.
+type Cached struct {
+	Provider
+	cache *candle.Cache
+	ttl   time.Duration
+	clock clock.Clock
+}
+
+// NewCached wraps p with a cache configured by cfg.
+func NewCached(p Provider, cfg config.CandleCacheConfig) *Cached {
+	c := &Cached{Provider: p, ttl: defaultCacheTTL, clock: clock.Real}
+	if cfg.TTL != "" {
+		c.ttl, _ = time.ParseDuration(cfg.TTL)
+	}
+	max := cfg.MaxCandles
+	if max == 0 {
+		max = defaultCacheCandles
+	}
+	c.cache = candle.NewCache(max)
+	return c
+}
+
+// SetClock replaces the clock the freshness of cached candles is timed by.
+func (c *Cached) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// Cache returns the underlying cache, e.g. for the API.
+func (c *Cached) Cache() *candle.Cache {
+	return c.cache
+}
+
+// Subscribe adds the completed candles of the trading loop to the cache.
+func (c *Cached) Subscribe(bus *events.Bus) {
+	bus.Subscribe(func(ev events.Event) {
+		c.cache.Add(ev.(events.CandleEvent).Candle)
+	}, events.KindCandle)
+}
+
+// GetDailyCandles returns the cached candles when the cache has days of them
+// fetched within the TTL, and otherwise fetches and caches them.
+func (c *Cached) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	now := c.clock.Now()
+	if candles, fetched, ok := c.cache.Last(stockCode, daily, days); ok && now.Sub(fetched) < c.ttl {
+		return candles, nil
+	}
+	candles, err := c.Provider.GetDailyCandles(stockCode, days)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Put(stockCode, daily, candles, len(candles) < days, now)
+	return candles, nil
+}
@@ -0,0 +1,171 @@
+package marketdata
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+)
+
+// Fallback is a Provider serving from a secondary provider while the primary
+// one fails, and cross-checking the primary's quotes against the secondary;
+// see config.MarketDataConfig.
+type Fallback struct {
+	primary       Provider
+	secondary     Provider
+	retryAfter    time.Duration
+	maxDeviation  float64
+	checkInterval time.Duration
+	clock         clock.Clock
+
+	mu        sync.Mutex
+	failing   bool
+	downUntil time.Time
+	checked   map[string]time.Time
+}
+
+// NewFallback wraps primary with secondary as configured in cfg.
+func NewFallback(primary, secondary Provider, cfg config.MarketDataConfig) *Fallback {
+	f := &Fallback{
+		primary:      primary,
+		secondary:    secondary,
+		maxDeviation: cfg.MaxDeviation,
+		clock:        clock.Real,
+		checked:      make(map[string]time.Time),
+	}
+	if cfg.RetryAfter != "" {
+		f.retryAfter, _ = time.ParseDuration(cfg.RetryAfter)
+	}
+	if cfg.CheckInterval != "" {
+		f.checkInterval, _ = time.ParseDuration(cfg.CheckInterval)
+	}
+	return f
+}
+
+// SetClock replaces the clock used to time retries and cross-checks.
+func (f *Fallback) SetClock(c clock.Clock) {
+	f.clock = c
+}
+
+// GetMarketData returns the primary's quote, or the secondary's while the
+// primary fails. A primary quote too far from the secondary's is rejected.
+func (f *Fallback) GetMarketData(stockCode string) (*models.MarketData, error) {
+	if f.usePrimary() {
+		data, err := f.primary.GetMarketData(stockCode)
+		if err == nil {
+			f.primaryRecovered()
+			if err := f.crossCheck(stockCode, data); err != nil {
+				return nil, err
+			}
+			return data, nil
+		}
+		f.primaryFailed(err)
+	}
+	data, err := f.secondary.GetMarketData(stockCode)
+	if err != nil {
+		return nil, fmt.Errorf("fallback market data failed: %v", err)
+	}
+	return data, nil
+}
+
+// GetHistoricalData returns the primary's bars, or the secondary's while the
+// primary fails.
+func (f *Fallback) GetHistoricalData(stockCode string, days int) ([]models.MarketData, error) {
+	if f.usePrimary() {
+		bars, err := f.primary.GetHistoricalData(stockCode, days)
+		if err == nil {
+			f.primaryRecovered()
+			return bars, nil
+		}
+		f.primaryFailed(err)
+	}
+	bars, err := f.secondary.GetHistoricalData(stockCode, days)
+	if err != nil {
+		return nil, fmt.Errorf("fallback market data failed: %v", err)
+	}
+	return bars, nil
+}
+
+// GetDailyCandles returns the primary's candles, or the secondary's while the
+// primary fails.
+func (f *Fallback) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	if f.usePrimary() {
+		candles, err := f.primary.GetDailyCandles(stockCode, days)
+		if err == nil {
+			f.primaryRecovered()
+			return candles, nil
+		}
+		f.primaryFailed(err)
+	}
+	candles, err := f.secondary.GetDailyCandles(stockCode, days)
+	if err != nil {
+		return nil, fmt.Errorf("fallback market data failed: %v", err)
+	}
+	return candles, nil
+}
+
+func (f *Fallback) usePrimary() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.clock.Now().Before(f.downUntil)
+}
+
+func (f *Fallback) primaryFailed(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.downUntil = f.clock.Now().Add(f.retryAfter)
+	if !f.failing {
+		f.failing = true
+		log.WithError(err).WithField("retry_after", f.retryAfter).Warn("Market data provider failed, using fallback")
+	}
+}
+
+func (f *Fallback) primaryRecovered() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing {
+		f.failing = false
+		log.Info("Market data provider recovered")
+	}
+}
+
+// crossCheck compares a primary quote with the secondary's, at most once per
+// check interval per symbol. Quotes the secondary cannot confirm are accepted.
+func (f *Fallback) crossCheck(stockCode string, data *models.MarketData) error {
+	if f.maxDeviation <= 0 {
+		return nil
+	}
+	now := f.clock.Now()
+	f.mu.Lock()
+	last, ok := f.checked[stockCode]
+	f.mu.Unlock()
+	if ok && now.Sub(last) < f.checkInterval {
+		return nil
+	}
+
+	ref, err := f.secondary.GetMarketData(stockCode)
+	if err != nil {
+		log.WithError(err).WithField("symbol", stockCode).Warn("Failed to cross-check quote with fallback")
+		return nil
+	}
+	price, err := strconv.ParseFloat(data.StckPrpr, 64)
+	if err != nil {
+		return fmt.Errorf("invalid price %q for %s", data.StckPrpr, stockCode)
+	}
+	refPrice, err := strconv.ParseFloat(ref.StckPrpr, 64)
+	if err != nil || refPrice <= 0 {
+		return nil
+	}
+	if deviation := math.Abs(price-refPrice) / refPrice; deviation > f.maxDeviation {
+		return fmt.Errorf("price %v of %s differs from fallback price %v by %.1f%%", price, stockCode, refPrice, deviation*100)
+	}
+	f.mu.Lock()
+	f.checked[stockCode] = now
+	f.mu.Unlock()
+	return nil
+}
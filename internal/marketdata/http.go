@@ -0,0 +1,161 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+const defaultHTTPTimeout = 10 * time.Second
+
+// HTTP is a Provider querying a data vendor's API; see config.MarketDataConfig.
+// Quotes are read from GET <url>/quotes/<symbol>:
+//
+//	{"price": 70000, "open": 69500, "high": 70500, "low": 69000,
+//	 "upper_limit": 90300, "lower_limit": 48700, "halted": false,
+//	 "ask": 70100, "bid": 70000, "time": "2026-10-16T10:15:02+09:00"}
+//
+// and daily candles from GET <url>/candles/<symbol>?days=N:
+//
+//	[{"date": "2026-10-16", "open": 69500, "high": 70500, "low": 69000, "close": 70000, "volume": 1234567}]
+//
+// Only price is required.
+type HTTP struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewHTTP creates a provider for the vendor API configured in cfg.
+func NewHTTP(cfg config.MarketDataConfig) *HTTP {
+	timeout := defaultHTTPTimeout
+	if cfg.Timeout != "" {
+		timeout, _ = time.ParseDuration(cfg.Timeout)
+	}
+	return &HTTP{
+		baseURL: strings.TrimSuffix(cfg.URL, "/"),
+		apiKey:  cfg.APIKey,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+type vendorQuote struct {
+	Price      float64   `json:"price"`
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	UpperLimit float64   `json:"upper_limit"`
+	LowerLimit float64   `json:"lower_limit"`
+	Halted     bool      `json:"halted"`
+	Ask        float64   `json:"ask"`
+	Bid        float64   `json:"bid"`
+	Time       time.Time `json:"time"`
+}
+
+type vendorCandle struct {
+	Date   string  `json:"date"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume float64 `json:"volume"`
+}
+
+// GetMarketData returns the current quote of a stock.
+func (h *HTTP) GetMarketData(stockCode string) (*models.MarketData, error) {
+	var q vendorQuote
+	if err := h.get("/quotes/"+url.PathEscape(stockCode), &q); err != nil {
+		return nil, err
+	}
+	if q.Price <= 0 {
+		return nil, fmt.Errorf("no price for %s in vendor quote", stockCode)
+	}
+	halted := "N"
+	if q.Halted {
+		halted = "Y"
+	}
+	return &models.MarketData{
+		StckPrpr:  formatPrice(q.Price),
+		StckOprc:  formatPrice(q.Open),
+		StckHgpr:  formatPrice(q.High),
+		StckLwpr:  formatPrice(q.Low),
+		StckMxpr:  formatPrice(q.UpperLimit),
+		StckLlam:  formatPrice(q.LowerLimit),
+		TrhtYn:    halted,
+		Askp1:     formatPrice(q.Ask),
+		Bidp1:     formatPrice(q.Bid),
+		QuoteTime: q.Time,
+	}, nil
+}
+
+// GetDailyCandles returns up to days daily candles of a stock, oldest first.
+func (h *HTTP) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	var items []vendorCandle
+	if err := h.get("/candles/"+url.PathEscape(stockCode)+"?days="+strconv.Itoa(days), &items); err != nil {
+		return nil, err
+	}
+	candles := make([]candle.Candle, 0, len(items))
+	for _, item := range items {
+		date, err := time.ParseInLocation("2006-01-02", item.Date, market.KST)
+		if err != nil {
+			return nil, fmt.Errorf("invalid candle date %q for %s", item.Date, stockCode)
+		}
+		candles = append(candles, candle.Candle{
+			Symbol:    stockCode,
+			Start:     date,
+			Timeframe: 24 * time.Hour,
+			Open:      item.Open,
+			High:      item.High,
+			Low:       item.Low,
+			Close:     item.Close,
+			Volume:    item.Volume,
+		})
+	}
+	sort.Slice(candles, func(i, j int) bool { return candles[i].Start.Before(candles[j].Start) })
+	if len(candles) > days {
+		candles = candles[len(candles)-days:]
+	}
+	return candles, nil
+}
+
+// GetHistoricalData returns the daily candles as bars, oldest first.
+func (h *HTTP) GetHistoricalData(stockCode string, days int) ([]models.MarketData, error) {
+	return Candles{h}.GetHistoricalData(stockCode, days)
+}
+
+func (h *HTTP) get(path string, v interface{}) error {
+	req, err := http.NewRequest("GET", h.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("market data request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read market data response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("market data request failed, status code: %d, body: %s", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse market data response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package marketdata
+
+import (
+	"fmt"
+	"strconv"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+)
+
+var log = logging.New()
+
+// Provider supplies quotes and price history. It is configured apart from the
+// exchange orders are executed on; see config.MarketDataConfig. The KIS client
+// is one.
+type Provider interface {
+	GetMarketData(stockCode string) (*models.MarketData, error)
+	// GetHistoricalData returns the daily bars of the last days.
+	GetHistoricalData(stockCode string, days int) ([]models.MarketData, error)
+	// GetDailyCandles returns up to days daily candles, oldest first.
+	GetDailyCandles(stockCode string, days int) ([]candle.Candle, error)
+}
+
+// CandleSource provides daily candles, oldest first, e.g. the database.
+type CandleSource interface {
+	GetDailyCandles(stockCode string, days int) ([]candle.Candle, error)
+}
+
+// Candles is a Provider backed only by daily candles: the quote of a symbol is
+// its latest candle.
+type Candles struct {
+	CandleSource
+}
+
+// GetMarketData returns the latest candle as a quote.
+func (c Candles) GetMarketData(stockCode string) (*models.MarketData, error) {
+	candles, err := c.GetDailyCandles(stockCode, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("no candles for %s", stockCode)
+	}
+	data := FromCandle(candles[len(candles)-1])
+	return &data, nil
+}
+
+// GetHistoricalData returns the daily candles as bars, oldest first.
+func (c Candles) GetHistoricalData(stockCode string, days int) ([]models.MarketData, error) {
+	candles, err := c.GetDailyCandles(stockCode, days)
+	if err != nil {
+		return nil, err
+	}
+	bars := make([]models.MarketData, len(candles))
+	for i, cd := range candles {
+		bars[i] = FromCandle(cd)
+	}
+	return bars, nil
+}
+
+// FromCandle converts a candle into a bar priced at its close, current at its
+// end.
+func FromCandle(c candle.Candle) models.MarketData {
+	return models.MarketData{
+		StckPrpr:  formatPrice(c.Close),
+		StckOprc:  formatPrice(c.Open),
+		StckHgpr:  formatPrice(c.High),
+		StckLwpr:  formatPrice(c.Low),
+		QuoteTime: c.End(),
+	}
+}
+
+func formatPrice(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
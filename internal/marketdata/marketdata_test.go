@@ -0,0 +1,297 @@
+package marketdata
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer vendor-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/quotes/005930":
+			w.Write([]byte(`{"price": 70000, "upper_limit": 91000, "halted": true}`))
+		case "/v1/candles/005930":
+			if r.URL.Query().Get("days") != "2" {
+				t.Errorf("days = %q, want 2", r.URL.Query().Get("days"))
+			}
+			w.Write([]byte(`[
+				{"date": "2026-10-16", "open": 69500, "high": 70500, "low": 69000, "close": 70000, "volume": 100},
+				{"date": "2026-10-14", "close": 68000},
+				{"date": "2026-10-15", "close": 69000}
+			]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewHTTP(config.MarketDataConfig{URL: srv.URL + "/v1/", APIKey: "vendor-key"})
+	quote, err := p.GetMarketData("005930")
+	if err != nil {
+		t.Fatalf("GetMarketData returned error: %v", err)
+	}
+	if quote.StckPrpr != "70000" || quote.UpperLimit() != 91000 || !quote.Halted() {
+		t.Errorf("quote = %+v", quote)
+	}
+
+	bars, err := p.GetHistoricalData("005930", 2)
+	if err != nil {
+		t.Fatalf("GetHistoricalData returned error: %v", err)
+	}
+	if len(bars) != 2 || bars[0].StckPrpr != "69000" || bars[1].StckPrpr != "70000" || bars[1].StckHgpr != "70500" {
+		t.Errorf("bars = %+v, want the last two days oldest first", bars)
+	}
+
+	if _, err := p.GetMarketData("000660"); err == nil {
+		t.Error("GetMarketData of an unknown symbol succeeded")
+	}
+}
+
+type fakeCandles []candle.Candle
+
+func (f fakeCandles) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	if len(f) > days {
+		return f[len(f)-days:], nil
+	}
+	return f, nil
+}
+
+func TestCandlesQuoteLatestClose(t *testing.T) {
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	p := Candles{fakeCandles{
+		{Start: day, Close: 69000},
+		{Start: day.AddDate(0, 0, 1), Open: 69500, Close: 70000},
+	}}
+	quote, err := p.GetMarketData("005930")
+	if err != nil {
+		t.Fatalf("GetMarketData returned error: %v", err)
+	}
+	if quote.StckPrpr != "70000" || quote.StckOprc != "69500" || quote.StckHgpr != "" {
+		t.Errorf("quote = %+v", quote)
+	}
+	if _, err := (Candles{fakeCandles{}}).GetMarketData("005930"); err == nil {
+		t.Error("GetMarketData without candles succeeded")
+	}
+}
+
+func TestNaverProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/quote":
+			w.Write([]byte(`{"resultCode":"success","result":{"areas":[{"name":"SERVICE_ITEM","datas":[
+				{"cd":"005930","nv":70000,"ov":69500,"hv":70500,"lv":69000,"ul":91000,"ll":49000}]}]}}`))
+		case "/chart":
+			if r.URL.Query().Get("symbol") != "005930" || r.URL.Query().Get("count") != "2" {
+				t.Errorf("chart query = %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`<?xml version="1.0" encoding="EUC-KR" ?>
+<protocol>
+	<chartdata symbol="005930" count="2" timeframe="day">
+		<item data="20261015|68500|69500|68000|69000|1200" />
+		<item data="20261016|69500|70500|69000|70000|1500" />
+	</chartdata>
+</protocol>`))
+		}
+	}))
+	defer srv.Close()
+
+	n := NewNaver(config.MarketDataConfig{})
+	n.quoteURL, n.chartURL = srv.URL+"/quote", srv.URL+"/chart"
+	quote, err := n.GetMarketData("005930")
+	if err != nil {
+		t.Fatalf("GetMarketData returned error: %v", err)
+	}
+	if quote.StckPrpr != "70000" || quote.UpperLimit() != 91000 {
+		t.Errorf("quote = %+v", quote)
+	}
+	if _, err := n.GetMarketData("000660"); err == nil {
+		t.Error("GetMarketData of a symbol missing from the response succeeded")
+	}
+
+	candles, err := n.GetDailyCandles("005930", 2)
+	if err != nil {
+		t.Fatalf("GetDailyCandles returned error: %v", err)
+	}
+	if len(candles) != 2 || candles[0].Close != 69000 || candles[1].High != 70500 || candles[1].Volume != 1500 {
+		t.Errorf("candles = %+v", candles)
+	}
+}
+
+type stubProvider struct {
+	Candles
+	price string
+	err   error
+	calls int
+}
+
+func (s *stubProvider) GetMarketData(stockCode string) (*models.MarketData, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.MarketData{StckPrpr: s.price}, nil
+}
+
+func TestFallbackServesWhilePrimaryFails(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC))
+	primary := &stubProvider{err: errors.New("rate limited")}
+	secondary := &stubProvider{price: "70100"}
+	f := NewFallback(primary, secondary, config.MarketDataConfig{RetryAfter: "1m"})
+	f.SetClock(clk)
+
+	for i := 0; i < 2; i++ {
+		quote, err := f.GetMarketData("005930")
+		if err != nil || quote.StckPrpr != "70100" {
+			t.Fatalf("GetMarketData = %+v, %v, want the fallback quote", quote, err)
+		}
+	}
+	if primary.calls != 1 {
+		t.Errorf("primary called %d times, want 1 within retry_after", primary.calls)
+	}
+
+	primary.err, primary.price = nil, "70000"
+	clk.Advance(time.Minute)
+	if quote, err := f.GetMarketData("005930"); err != nil || quote.StckPrpr != "70000" {
+		t.Errorf("GetMarketData = %+v, %v, want the primary quote after retry_after", quote, err)
+	}
+}
+
+func TestFallbackCrossChecksQuotes(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC))
+	primary := &stubProvider{price: "70000"}
+	secondary := &stubProvider{price: "70500"}
+	f := NewFallback(primary, secondary, config.MarketDataConfig{MaxDeviation: 0.05, CheckInterval: "5m"})
+	f.SetClock(clk)
+
+	if _, err := f.GetMarketData("005930"); err != nil {
+		t.Fatalf("GetMarketData returned error: %v", err)
+	}
+	primary.price = "7000"
+	if _, err := f.GetMarketData("005930"); err != nil {
+		t.Errorf("GetMarketData returned error %v, want no check within the interval", err)
+	}
+	clk.Advance(5 * time.Minute)
+	if _, err := f.GetMarketData("005930"); err == nil {
+		t.Error("GetMarketData accepted a price 90% off the fallback")
+	}
+	if secondary.calls != 2 {
+		t.Errorf("fallback called %d times, want 2 checks", secondary.calls)
+	}
+}
+
+// dirtyWeek has a duplicate on Tuesday, no Wednesday, a zero close on
+// Thursday and a spike on Friday.
+func dirtyWeek() fakeCandles {
+	day := func(d int, close float64) candle.Candle {
+		return candle.Candle{Symbol: "005930", Start: time.Date(2026, 10, d, 0, 0, 0, 0, market.KST), Timeframe: 24 * time.Hour, Open: close, High: close, Low: close, Close: close}
+	}
+	return fakeCandles{day(12, 70000), day(13, 70500), day(13, 71000), day(15, 0), day(16, 120000), day(19, 71500)}
+}
+
+func TestCheckerRepairsCandles(t *testing.T) {
+	cal, err := market.NewCalendar(config.MarketConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked, report := NewChecker(config.MarketDataConfig{Quality: config.QualityRepair}, cal).Check("005930", dirtyWeek())
+
+	for _, kind := range []string{IssueDuplicate, IssueMissing, IssueBadPrice, IssueJump} {
+		if n := report.Count(kind); n != 1 {
+			t.Errorf("%d %s issues, want 1: %v", n, kind, report.Issues)
+		}
+	}
+	want := []float64{70000, 71000, 71000, 71000, 71000, 71500}
+	if len(checked) != len(want) {
+		t.Fatalf("got %d candles, want %d", len(checked), len(want))
+	}
+	for i, c := range checked {
+		if c.Close != want[i] || c.Start.Day() != []int{12, 13, 14, 15, 16, 19}[i] {
+			t.Errorf("candle %d = %s %g, want %g", i, c.Start.Format("01-02"), c.Close, want[i])
+		}
+	}
+
+	// Flagging reports the same issues and leaves the candles alone.
+	flagged, report := NewChecker(config.MarketDataConfig{Quality: config.QualityFlag}, cal).Check("005930", dirtyWeek())
+	if len(flagged) != len(dirtyWeek()) || len(report.Issues) != 4 {
+		t.Errorf("flagging returned %d candles and issues %v", len(flagged), report.Issues)
+	}
+}
+
+func TestCheckedRejectsDirtyData(t *testing.T) {
+	cal, _ := market.NewCalendar(config.MarketConfig{})
+	p := NewChecked(Candles{dirtyWeek()}, config.MarketDataConfig{Quality: config.QualityReject}, cal)
+	if _, err := p.GetDailyCandles("005930", 10); err == nil {
+		t.Error("GetDailyCandles accepted dirty candles")
+	}
+
+	// Bars carry no dates: only the prices and the spike are repaired.
+	p = NewChecked(Candles{dirtyWeek()}, config.MarketDataConfig{Quality: config.QualityRepair}, cal)
+	bars, err := p.GetHistoricalData("005930", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closes []string
+	for _, b := range bars {
+		closes = append(closes, b.StckPrpr)
+	}
+	if got := strings.Join(closes, " "); got != "70000 70500 71000 71000 71000 71500" {
+		t.Errorf("closes = %s", got)
+	}
+}
+
+type countingCandles struct {
+	fakeCandles
+	calls int
+}
+
+func (c *countingCandles) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	c.calls++
+	return c.fakeCandles.GetDailyCandles(stockCode, days)
+}
+
+func TestCachedServesFreshCandles(t *testing.T) {
+	day := time.Date(2026, 10, 13, 0, 0, 0, 0, market.KST)
+	source := &countingCandles{fakeCandles: fakeCandles{
+		{Start: day, Close: 68000},
+		{Start: day.AddDate(0, 0, 1), Close: 69000},
+		{Start: day.AddDate(0, 0, 2), Close: 70000},
+	}}
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC))
+	c := NewCached(Candles{source}, config.CandleCacheConfig{TTL: "1m"})
+	c.SetClock(clk)
+
+	for _, days := range []int{3, 2, 3} {
+		candles, err := c.GetDailyCandles("005930", days)
+		if err != nil || len(candles) != days || candles[days-1].Close != 70000 {
+			t.Fatalf("GetDailyCandles(%d) = %+v, %v", days, candles, err)
+		}
+	}
+	if source.calls != 1 {
+		t.Errorf("source called %d times, want 1 within the TTL", source.calls)
+	}
+	// The source has only 3 candles, which the cache remembers.
+	if _, err := c.GetDailyCandles("005930", 10); err != nil || source.calls != 2 {
+		t.Errorf("GetDailyCandles(10): %v, %d calls", err, source.calls)
+	}
+	if _, err := c.GetDailyCandles("005930", 10); err != nil || source.calls != 2 {
+		t.Errorf("GetDailyCandles(10) again: %v, %d calls, want no new call", err, source.calls)
+	}
+
+	clk.Advance(time.Minute)
+	if _, err := c.GetDailyCandles("005930", 2); err != nil || source.calls != 3 {
+		t.Errorf("after the TTL: %v, %d calls, want a new fetch", err, source.calls)
+	}
+}
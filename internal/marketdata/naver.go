@@ -0,0 +1,177 @@
+package marketdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+const (
+	naverQuoteURL = "https://polling.finance.naver.com/api/realtime"
+	naverChartURL = "https://fchart.stock.naver.com/sise.nhn"
+)
+
+// Naver is a Provider reading the public Naver Finance endpoints: the
+// realtime polling API for quotes and the chart API for daily candles. It
+// needs no credentials, has no service guarantees and is meant as a fallback.
+type Naver struct {
+	quoteURL string
+	chartURL string
+	client   *http.Client
+}
+
+// NewNaver creates a Naver Finance provider using the timeout in cfg.
+func NewNaver(cfg config.MarketDataConfig) *Naver {
+	timeout := defaultHTTPTimeout
+	if cfg.Timeout != "" {
+		timeout, _ = time.ParseDuration(cfg.Timeout)
+	}
+	return &Naver{quoteURL: naverQuoteURL, chartURL: naverChartURL, client: &http.Client{Timeout: timeout}}
+}
+
+// naverQuote is an item of the realtime API: current, open, high and low
+// price and the daily price limits.
+type naverQuote struct {
+	Code       string  `json:"cd"`
+	Price      float64 `json:"nv"`
+	Open       float64 `json:"ov"`
+	High       float64 `json:"hv"`
+	Low        float64 `json:"lv"`
+	UpperLimit float64 `json:"ul"`
+	LowerLimit float64 `json:"ll"`
+}
+
+type naverQuoteResponse struct {
+	ResultCode string `json:"resultCode"`
+	Result     struct {
+		Areas []struct {
+			Datas []naverQuote `json:"datas"`
+		} `json:"areas"`
+	} `json:"result"`
+}
+
+// naverChart is the chart API response; each item is
+// "YYYYMMDD|open|high|low|close|volume".
+type naverChart struct {
+	Items []struct {
+		Data string `xml:"data,attr"`
+	} `xml:"chartdata>item"`
+}
+
+// GetMarketData returns the current quote of a stock.
+func (n *Naver) GetMarketData(stockCode string) (*models.MarketData, error) {
+	body, err := n.get(n.quoteURL + "?query=SERVICE_ITEM:" + url.QueryEscape(stockCode))
+	if err != nil {
+		return nil, err
+	}
+	var resp naverQuoteResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse naver quote: %v", err)
+	}
+	if resp.ResultCode != "success" {
+		return nil, fmt.Errorf("naver quote failed: %s", resp.ResultCode)
+	}
+	for _, area := range resp.Result.Areas {
+		for _, q := range area.Datas {
+			if q.Code != stockCode || q.Price <= 0 {
+				continue
+			}
+			return &models.MarketData{
+				StckPrpr: formatPrice(q.Price),
+				StckOprc: formatPrice(q.Open),
+				StckHgpr: formatPrice(q.High),
+				StckLwpr: formatPrice(q.Low),
+				StckMxpr: formatPrice(q.UpperLimit),
+				StckLlam: formatPrice(q.LowerLimit),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no naver quote for %s", stockCode)
+}
+
+// GetDailyCandles returns up to days daily candles of a stock, oldest first.
+func (n *Naver) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	query := url.Values{
+		"symbol":      {stockCode},
+		"timeframe":   {"day"},
+		"count":       {strconv.Itoa(days)},
+		"requestType": {"0"},
+	}
+	body, err := n.get(n.chartURL + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	// The response is declared EUC-KR; the fields read here are ASCII.
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	var chart naverChart
+	if err := dec.Decode(&chart); err != nil {
+		return nil, fmt.Errorf("failed to parse naver chart: %v", err)
+	}
+
+	candles := make([]candle.Candle, 0, len(chart.Items))
+	for _, item := range chart.Items {
+		fields := strings.Split(item.Data, "|")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("invalid naver chart item %q for %s", item.Data, stockCode)
+		}
+		date, err := time.ParseInLocation("20060102", fields[0], market.KST)
+		if err != nil {
+			return nil, fmt.Errorf("invalid naver chart date %q for %s", fields[0], stockCode)
+		}
+		var values [5]float64
+		for i, f := range fields[1:] {
+			if values[i], err = strconv.ParseFloat(f, 64); err != nil {
+				return nil, fmt.Errorf("invalid naver chart item %q for %s", item.Data, stockCode)
+			}
+		}
+		candles = append(candles, candle.Candle{
+			Symbol:    stockCode,
+			Start:     date,
+			Timeframe: 24 * time.Hour,
+			Open:      values[0],
+			High:      values[1],
+			Low:       values[2],
+			Close:     values[3],
+			Volume:    values[4],
+		})
+	}
+	if len(candles) > days {
+		candles = candles[len(candles)-days:]
+	}
+	return candles, nil
+}
+
+// GetHistoricalData returns the daily candles as bars, oldest first.
+func (n *Naver) GetHistoricalData(stockCode string, days int) ([]models.MarketData, error) {
+	return Candles{n}.GetHistoricalData(stockCode, days)
+}
+
+func (n *Naver) get(u string) ([]byte, error) {
+	resp, err := n.client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("naver request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read naver response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("naver request failed, status code: %d", resp.StatusCode)
+	}
+	return body, nil
+}
@@ -0,0 +1,247 @@
+package marketdata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The kinds of data quality issues Check finds.
+const (
+	IssueMissing   = "missing_session"
+	IssueBadPrice  = "bad_price"
+	IssueDuplicate = "duplicate"
+	IssueJump      = "jump"
+)
+
+// defaultMaxJump is the KRX daily price limit; a larger move between two
+// closes is an error in the data, or a corporate action it is not adjusted for.
+const defaultMaxJump = 0.3
+
+// Issue is a problem found in a symbol's candles.
+type Issue struct {
+	Date   time.Time
+	Kind   string
+	Detail string
+	// Repaired tells whether the candle was dropped or rebuilt.
+	Repaired bool
+}
+
+func (i Issue) String() string {
+	if i.Date.IsZero() {
+		return i.Kind + ": " + i.Detail
+	}
+	return i.Date.In(market.KST).Format("2006-01-02") + " " + i.Kind + ": " + i.Detail
+}
+
+// Report lists the issues found in a symbol's candles.
+type Report struct {
+	Symbol  string
+	Candles int
+	Issues  []Issue
+}
+
+// Count returns the number of issues of a kind.
+func (r Report) Count(kind string) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Log logs a summary of the issues, if any.
+func (r Report) Log() {
+	if len(r.Issues) == 0 {
+		return
+	}
+	log.WithFields(logrus.Fields{
+		"symbol":       r.Symbol,
+		"candles":      r.Candles,
+		IssueMissing:   r.Count(IssueMissing),
+		IssueBadPrice:  r.Count(IssueBadPrice),
+		IssueDuplicate: r.Count(IssueDuplicate),
+		IssueJump:      r.Count(IssueJump),
+		"first":        r.Issues[0].String(),
+	}).Warn("Data quality issues in candles")
+}
+
+// Checker finds missing sessions, non-positive prices, duplicate dates and
+// extreme jumps in daily candles, and with config.QualityRepair repairs them:
+// duplicates are dropped in favour of the last, missing sessions and bad
+// candles are filled forward from the previous close, and a jump that reverts
+// on the next candle is filled forward as a spike. Other jumps are only
+// flagged, since they may be real. Sessions are those of the calendar, so
+// holidays are not gaps; without a calendar missing sessions are not checked.
+type Checker struct {
+	cal     *market.Calendar
+	repair  bool
+	maxJump float64
+}
+
+// NewChecker creates a checker with the settings of cfg.
+func NewChecker(cfg config.MarketDataConfig, cal *market.Calendar) *Checker {
+	c := &Checker{cal: cal, repair: cfg.Quality == config.QualityRepair, maxJump: cfg.MaxJump}
+	if c.maxJump == 0 {
+		c.maxJump = defaultMaxJump
+	}
+	return c
+}
+
+// Check returns the candles, repaired when the checker repairs, oldest first,
+// and the issues found in them. Candles without a start time, such as bars
+// converted from models.MarketData, are checked only for prices and jumps.
+func (c *Checker) Check(symbol string, candles []candle.Candle) ([]candle.Candle, Report) {
+	report := Report{Symbol: symbol, Candles: len(candles)}
+	sorted := append([]candle.Candle(nil), candles...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	out := make([]candle.Candle, 0, len(sorted))
+	// ref is the close jumps are measured from: the latest positive one that
+	// was not a spike.
+	var ref float64
+	for i, cd := range sorted {
+		dated := !cd.Start.IsZero()
+		if n := len(out); n > 0 && dated && sameDay(out[n-1].Start, cd.Start) {
+			report.Issues = append(report.Issues, Issue{Date: cd.Start, Kind: IssueDuplicate, Detail: "more than one candle", Repaired: c.repair})
+			if c.repair {
+				out[n-1] = cd
+				continue
+			}
+		}
+		if badPrice(cd) {
+			report.Issues = append(report.Issues, Issue{Date: cd.Start, Kind: IssueBadPrice, Detail: fmt.Sprintf("open %g high %g low %g close %g", cd.Open, cd.High, cd.Low, cd.Close), Repaired: c.repair})
+			if c.repair {
+				if len(out) == 0 {
+					continue
+				}
+				cd = filled(out[len(out)-1], cd.Start)
+			}
+		}
+		if n := len(out); n > 0 && dated && c.cal != nil && cd.Timeframe == 24*time.Hour {
+			prev := out[n-1]
+			for day := prev.Start.AddDate(0, 0, 1); day.Before(cd.Start) && !sameDay(day, cd.Start); day = day.AddDate(0, 0, 1) {
+				if _, ok := c.cal.SessionOn(day); !ok {
+					continue
+				}
+				report.Issues = append(report.Issues, Issue{Date: day, Kind: IssueMissing, Detail: "no candle for the session", Repaired: c.repair})
+				if c.repair {
+					out = append(out, filled(prev, day))
+				}
+			}
+		}
+		spike := false
+		if ref > 0 && cd.Close > 0 {
+			if move := cd.Close/ref - 1; math.Abs(move) > c.maxJump {
+				// A spike reverts on the next candle; a real move, e.g. a
+				// split, does not.
+				spike = i+1 < len(sorted) && sorted[i+1].Close > 0 && math.Abs(sorted[i+1].Close/ref-1) <= c.maxJump
+				report.Issues = append(report.Issues, Issue{Date: cd.Start, Kind: IssueJump, Detail: fmt.Sprintf("close moved %+.1f%% from %g to %g", move*100, ref, cd.Close), Repaired: c.repair && spike})
+				if c.repair && spike {
+					cd = filled(out[len(out)-1], cd.Start)
+				}
+			}
+		}
+		if cd.Close > 0 && !spike {
+			ref = cd.Close
+		}
+		out = append(out, cd)
+	}
+
+	if !c.repair {
+		return sorted, report
+	}
+	return out, report
+}
+
+// badPrice reports whether a candle has no close, a negative price or a high
+// below its low.
+func badPrice(c candle.Candle) bool {
+	return c.Close <= 0 || c.Open < 0 || c.High < 0 || c.Low < 0 || c.High > 0 && c.Low > 0 && c.High < c.Low
+}
+
+// filled returns a candle starting at start, flat at the close of prev and
+// without volume.
+func filled(prev candle.Candle, start time.Time) candle.Candle {
+	return candle.Candle{Symbol: prev.Symbol, Start: start, Timeframe: prev.Timeframe, Open: prev.Close, High: prev.Close, Low: prev.Close, Close: prev.Close}
+}
+
+func sameDay(a, b time.Time) bool {
+	a, b = a.In(market.KST), b.In(market.KST)
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// Checked is a Provider whose candles and bars are checked, and repaired or
+// rejected, before they are returned; see config.MarketDataConfig.Quality.
+// Quotes are passed through.
+type Checked struct {
+	Provider
+	checker *Checker
+	reject  bool
+}
+
+// NewChecked wraps p with the quality checks of cfg.
+func NewChecked(p Provider, cfg config.MarketDataConfig, cal *market.Calendar) *Checked {
+	return &Checked{Provider: p, checker: NewChecker(cfg, cal), reject: cfg.Quality == config.QualityReject}
+}
+
+// GetDailyCandles returns the checked daily candles.
+func (c *Checked) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	candles, err := c.Provider.GetDailyCandles(stockCode, days)
+	if err != nil {
+		return nil, err
+	}
+	checked, report := c.checker.Check(stockCode, candles)
+	if err := c.result(report); err != nil {
+		return nil, err
+	}
+	return checked, nil
+}
+
+// GetHistoricalData returns the checked bars. Bars carry no dates, so they
+// are checked only for prices and jumps.
+func (c *Checked) GetHistoricalData(stockCode string, days int) ([]models.MarketData, error) {
+	bars, err := c.Provider.GetHistoricalData(stockCode, days)
+	if err != nil {
+		return nil, err
+	}
+	candles := make([]candle.Candle, len(bars))
+	for i, bar := range bars {
+		candles[i] = candle.Candle{Symbol: stockCode}
+		candles[i].Open, _ = strconv.ParseFloat(bar.StckOprc, 64)
+		candles[i].High, _ = strconv.ParseFloat(bar.StckHgpr, 64)
+		candles[i].Low, _ = strconv.ParseFloat(bar.StckLwpr, 64)
+		candles[i].Close, _ = strconv.ParseFloat(bar.StckPrpr, 64)
+	}
+	checked, report := c.checker.Check(stockCode, candles)
+	if err := c.result(report); err != nil {
+		return nil, err
+	}
+	if len(report.Issues) == 0 || !c.checker.repair {
+		return bars, nil
+	}
+	out := make([]models.MarketData, len(checked))
+	for i, cd := range checked {
+		out[i] = FromCandle(cd)
+	}
+	return out, nil
+}
+
+// result logs the report and, when rejecting, turns issues into an error.
+func (c *Checked) result(report Report) error {
+	report.Log()
+	if c.reject && len(report.Issues) > 0 {
+		return fmt.Errorf("rejected candles of %s: %d data quality issues, first %s", report.Symbol, len(report.Issues), report.Issues[0])
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// datasource is the Prometheus data source input of the dashboard, chosen
+// when it is imported into Grafana.
+const datasource = "${DS_PROMETHEUS}"
+
+// Dashboard returns the Grafana dashboard JSON with a panel for every metric
+// of the catalog, ready for Dashboards > Import.
+func Dashboard() ([]byte, error) {
+	type target struct {
+		Expr         string `json:"expr"`
+		LegendFormat string `json:"legendFormat"`
+		RefID        string `json:"refId"`
+	}
+	type panel struct {
+		ID          int                    `json:"id"`
+		Type        string                 `json:"type"`
+		Title       string                 `json:"title"`
+		Description string                 `json:"description"`
+		Datasource  map[string]string      `json:"datasource"`
+		GridPos     map[string]int         `json:"gridPos"`
+		FieldConfig map[string]interface{} `json:"fieldConfig"`
+		Targets     []target               `json:"targets"`
+	}
+
+	ds := map[string]string{"type": "prometheus", "uid": datasource}
+	var panels []panel
+	// Single-valued gauges are shown as stats, four to a row, above the
+	// time series, two to a row.
+	x, y := 0, 0
+	for _, m := range Catalog {
+		if m.Type != Gauge || len(m.Labels) > 0 {
+			continue
+		}
+		panels = append(panels, panel{
+			Type: "stat", Title: m.Title, Description: m.Help, Datasource: ds,
+			GridPos:     map[string]int{"x": x, "y": y, "w": 6, "h": 4},
+			FieldConfig: map[string]interface{}{"defaults": map[string]string{"unit": m.Unit}},
+			Targets:     []target{{Expr: m.Name, RefID: "A"}},
+		})
+		if x += 6; x == 24 {
+			x, y = 0, y+4
+		}
+	}
+	if x > 0 {
+		x, y = 0, y+4
+	}
+	for _, m := range Catalog {
+		if m.Type == Gauge && len(m.Labels) == 0 {
+			continue
+		}
+		expr, legend := query(m)
+		panels = append(panels, panel{
+			Type: "timeseries", Title: m.Title, Description: m.Help, Datasource: ds,
+			GridPos:     map[string]int{"x": x, "y": y, "w": 12, "h": 8},
+			FieldConfig: map[string]interface{}{"defaults": map[string]string{"unit": m.Unit}},
+			Targets:     []target{{Expr: expr, LegendFormat: legend, RefID: "A"}},
+		})
+		if x += 12; x == 24 {
+			x, y = 0, y+8
+		}
+	}
+	for i := range panels {
+		panels[i].ID = i + 1
+	}
+
+	dashboard := map[string]interface{}{
+		"__inputs": []map[string]string{{
+			"name": "DS_PROMETHEUS", "label": "Prometheus", "type": "datasource",
+			"pluginId": "prometheus", "pluginName": "Prometheus",
+		}},
+		"uid":           "tradingbot",
+		"title":         "tradingbot",
+		"tags":          []string{"tradingbot"},
+		"timezone":      "Asia/Seoul",
+		"schemaVersion": 39,
+		"version":       1,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-1d", "to": "now"},
+		"panels":        panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+// query returns the PromQL expression and legend of m's time series panel:
+// per-second rates of counters, averages of summaries and the values of
+// gauges, by label.
+func query(m Metric) (expr, legend string) {
+	by := ""
+	if len(m.Labels) > 0 {
+		by = " by (" + strings.Join(m.Labels, ", ") + ")"
+		legends := make([]string, len(m.Labels))
+		for i, l := range m.Labels {
+			legends[i] = "{{" + l + "}}"
+		}
+		legend = strings.Join(legends, " ")
+	}
+	switch m.Type {
+	case Counter:
+		expr = fmt.Sprintf("sum%s (rate(%s[5m]))", by, m.Name)
+	case Summary:
+		expr = fmt.Sprintf("sum%s (rate(%s_sum[5m])) / sum%s (rate(%s_count[5m]))", by, m.Name, by, m.Name)
+	default:
+		expr = m.Name
+	}
+	return expr, legend
+}
+
+// AlertRules returns the Prometheus rule file with the alerts of the
+// catalog, ready to be listed in rule_files.
+func AlertRules() ([]byte, error) {
+	type rule struct {
+		Alert       string            `yaml:"alert"`
+		Expr        string            `yaml:"expr"`
+		For         string            `yaml:"for,omitempty"`
+		Labels      map[string]string `yaml:"labels"`
+		Annotations map[string]string `yaml:"annotations"`
+	}
+	type group struct {
+		Name  string `yaml:"name"`
+		Rules []rule `yaml:"rules"`
+	}
+
+	g := group{Name: "tradingbot"}
+	for _, m := range Catalog {
+		for _, a := range m.Alerts {
+			g.Rules = append(g.Rules, rule{
+				Alert:       a.Name,
+				Expr:        a.Expr,
+				For:         a.For,
+				Labels:      map[string]string{"severity": a.Severity},
+				Annotations: map[string]string{"summary": a.Summary, "description": m.Help},
+			})
+		}
+	}
+	return yaml.Marshal(map[string][]group{"groups": {g}})
+}
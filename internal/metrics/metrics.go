@@ -0,0 +1,257 @@
+// Package metrics keeps the bot's Prometheus metrics, serves them in the text
+// exposition format and generates the Grafana dashboard and alert rules that
+// go with them. Every metric is declared in Catalog, so the dashboard and the
+// rules cannot drift from what is exported.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tradingbot/internal/events"
+)
+
+// Metric types, as in the exposition format. A summary only has the _sum
+// and _count series, no quantiles.
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+	Summary = "summary"
+)
+
+// Metric describes one exported metric. Alerts are the alert rules on it.
+type Metric struct {
+	Name   string
+	Type   string
+	Help   string
+	Labels []string
+	// Title and Unit are those of the dashboard panel; Unit is a Grafana
+	// unit such as "s" or "short".
+	Title  string
+	Unit   string
+	Alerts []Alert
+}
+
+// Alert is a Prometheus alert rule. Expr is a PromQL expression; For is how
+// long it must hold before the alert fires.
+type Alert struct {
+	Name     string
+	Expr     string
+	For      string
+	Severity string
+	Summary  string
+}
+
+// Alert severities.
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Catalog lists every metric the bot exports at /metrics.
+var Catalog = []Metric{
+	{Name: "tradingbot_up", Title: "Up", Type: Gauge, Help: "Always 1 while the bot is running.", Unit: "short", Alerts: []Alert{
+		{Name: "TradingbotDown", Expr: `absent(tradingbot_up) or tradingbot_up == 0`, For: "2m", Severity: SeverityCritical, Summary: "The bot is not running or cannot be scraped."},
+	}},
+	{Name: "tradingbot_paused", Title: "Paused", Type: Gauge, Help: "1 while the trading loop is paused.", Unit: "short", Alerts: []Alert{
+		{Name: "TradingbotPaused", Expr: `tradingbot_paused == 1`, For: "30m", Severity: SeverityWarning, Summary: "Trading has been paused for 30 minutes."},
+	}},
+	{Name: "tradingbot_killed", Title: "Kill switch", Type: Gauge, Help: "1 once the kill switch has stopped trading.", Unit: "short", Alerts: []Alert{
+		{Name: "TradingbotKilled", Expr: `tradingbot_killed == 1`, Severity: SeverityCritical, Summary: "The kill switch stopped trading; restart the bot to resume."},
+	}},
+	{Name: "tradingbot_circuit_open", Title: "Circuit breaker open", Type: Gauge, Help: "1 while the exchange circuit breaker suspends orders.", Unit: "short", Alerts: []Alert{
+		{Name: "TradingbotCircuitOpen", Expr: `tradingbot_circuit_open == 1`, For: "5m", Severity: SeverityCritical, Summary: "Orders have been suspended by the circuit breaker for 5 minutes."},
+	}},
+	{Name: "tradingbot_equity_krw", Title: "Equity", Type: Gauge, Help: "Cash plus holdings at the current prices, in KRW.", Unit: "currencyKRW"},
+	{Name: "tradingbot_cash_krw", Title: "Cash", Type: Gauge, Help: "Cash balance, in KRW.", Unit: "currencyKRW"},
+	{Name: "tradingbot_sector_exposure_ratio", Title: "Sector exposure", Type: Gauge, Help: "Value of the positions in each sector as a fraction of the equity.", Labels: []string{"sector"}, Unit: "percentunit", Alerts: []Alert{
+		{Name: "TradingbotSectorOverLimit", Expr: `tradingbot_sector_exposure_ratio > on(sector) tradingbot_sector_limit_ratio`, For: "15m", Severity: SeverityWarning, Summary: "Exposure to sector {{ $labels.sector }} is above its limit; buys in it are refused."},
+	}},
+	{Name: "tradingbot_sector_limit_ratio", Title: "Sector limits", Type: Gauge, Help: "Configured limit of the exposure to each sector.", Labels: []string{"sector"}, Unit: "percentunit"},
+	{Name: "tradingbot_last_cycle_timestamp_seconds", Title: "Last cycle", Type: Gauge, Help: "Unix time of the last completed trading cycle.", Labels: []string{"symbol"}, Unit: "dateTimeAsIso"},
+	{Name: "tradingbot_cycle_duration_seconds", Title: "Cycle latency", Type: Summary, Help: "Time spent in each phase of the trading cycles; phase cycle is the whole cycle.", Labels: []string{"phase"}, Unit: "s", Alerts: []Alert{
+		{Name: "TradingbotSlowCycles", Expr: `rate(tradingbot_cycle_duration_seconds_sum{phase="cycle"}[10m]) / rate(tradingbot_cycle_duration_seconds_count{phase="cycle"}[10m]) > 5`, For: "10m", Severity: SeverityWarning, Summary: "Trading cycles take more than 5 seconds on average."},
+	}},
+	{Name: "tradingbot_signals_total", Title: "Signals", Type: Counter, Help: "Signals emitted, holds included.", Labels: []string{"symbol", "type"}, Unit: "short"},
+	{Name: "tradingbot_decisions_total", Title: "Decisions", Type: Counter, Help: "Signals handled, by final action.", Labels: []string{"action"}, Unit: "short", Alerts: []Alert{
+		{Name: "TradingbotOrdersFailing", Expr: `increase(tradingbot_decisions_total{action="failed"}[15m]) > 3`, Severity: SeverityCritical, Summary: "More than 3 orders failed in 15 minutes."},
+	}},
+	{Name: "tradingbot_orders_total", Title: "Orders", Type: Counter, Help: "Orders accepted by the exchange.", Labels: []string{"symbol", "side"}, Unit: "short"},
+	{Name: "tradingbot_fills_total", Title: "Fills", Type: Counter, Help: "Order executions.", Labels: []string{"symbol", "side"}, Unit: "short"},
+	{Name: "tradingbot_halts_total", Title: "Refused orders", Type: Counter, Help: "Orders refused because of a halt, price limit, stale quote or wide spread.", Labels: []string{"check"}, Unit: "short"},
+	{Name: "tradingbot_errors_total", Title: "Errors", Type: Counter, Help: "Errors raised by the bot's components.", Labels: []string{"source"}, Unit: "short", Alerts: []Alert{
+		{Name: "TradingbotErrors", Expr: `sum(rate(tradingbot_errors_total[5m])) > 0.1`, For: "10m", Severity: SeverityWarning, Summary: "The bot has been raising errors for 10 minutes."},
+	}},
+	{Name: "tradingbot_watchdog_events_total", Title: "Unresponsive components", Type: Counter, Help: "Components found unresponsive by the watchdog.", Labels: []string{"component"}, Unit: "short", Alerts: []Alert{
+		{Name: "TradingbotUnresponsive", Expr: `increase(tradingbot_watchdog_events_total[15m]) > 0`, Severity: SeverityWarning, Summary: "A component stopped responding: {{ $labels.component }}."},
+	}},
+	{Name: "tradingbot_degradations_total", Title: "Strategy degradations", Type: Counter, Help: "Strategies found performing below their backtest.", Labels: []string{"strategy"}, Unit: "short", Alerts: []Alert{
+		{Name: "TradingbotStrategyDegraded", Expr: `increase(tradingbot_degradations_total[1h]) > 0`, Severity: SeverityWarning, Summary: "Strategy {{ $labels.strategy }} is performing below its backtest."},
+	}},
+}
+
+// Lookup returns the catalog entry of the metric called name.
+func Lookup(name string) (Metric, bool) {
+	for _, m := range Catalog {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// Registry holds the current values of the catalog's metrics. It is safe for
+// concurrent use.
+type Registry struct {
+	mu     sync.Mutex
+	values map[string]map[string]float64 // series name -> label pairs -> value
+}
+
+// NewRegistry creates a registry with tradingbot_up set.
+func NewRegistry() *Registry {
+	r := &Registry{values: map[string]map[string]float64{}}
+	r.Set("tradingbot_up", 1)
+	return r
+}
+
+// Add adds v to a counter; labels are the values of its labels, in order.
+func (r *Registry) Add(name string, v float64, labels ...string) {
+	key := labelPairs(mustLookup(name, Counter), labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name)[key] += v
+}
+
+// Set sets a gauge.
+func (r *Registry) Set(name string, v float64, labels ...string) {
+	key := labelPairs(mustLookup(name, Gauge), labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name)[key] = v
+}
+
+// Observe adds an observation to a summary.
+func (r *Registry) Observe(name string, v float64, labels ...string) {
+	key := labelPairs(mustLookup(name, Summary), labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name + "_sum")[key] += v
+	r.series(name + "_count")[key]++
+}
+
+func (r *Registry) series(name string) map[string]float64 {
+	s := r.values[name]
+	if s == nil {
+		s = map[string]float64{}
+		r.values[name] = s
+	}
+	return s
+}
+
+// mustLookup panics if name is not a metric of type typ in the catalog,
+// which is a programming error.
+func mustLookup(name, typ string) Metric {
+	m, ok := Lookup(name)
+	if !ok || m.Type != typ {
+		panic(fmt.Sprintf("metrics: %s is not a %s in the catalog", name, typ))
+	}
+	return m
+}
+
+func labelPairs(m Metric, values []string) string {
+	if len(values) != len(m.Labels) {
+		panic(fmt.Sprintf("metrics: %s takes labels %v, got %d values", m.Name, m.Labels, len(values)))
+	}
+	pairs := make([]string, len(values))
+	for i, v := range values {
+		pairs[i] = m.Labels[i] + "=" + strconv.Quote(v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Subscribe records the bus events behind the metrics.
+func (r *Registry) Subscribe(bus *events.Bus) {
+	bus.Subscribe(r.record, events.KindSignal, events.KindDecision, events.KindOrder, events.KindFill, events.KindHalt,
+		events.KindError, events.KindWatchdog, events.KindDegradation, events.KindCircuit, events.KindCycle, events.KindKill)
+}
+
+func (r *Registry) record(ev events.Event) {
+	switch e := ev.(type) {
+	case events.SignalEvent:
+		if e.Signal != nil {
+			r.Add("tradingbot_signals_total", 1, e.Symbol, string(e.Signal.Type))
+		}
+	case events.DecisionEvent:
+		r.Add("tradingbot_decisions_total", 1, e.Action)
+	case events.OrderEvent:
+		r.Add("tradingbot_orders_total", 1, e.Order.Pair, string(e.Order.Side))
+	case events.FillEvent:
+		r.Add("tradingbot_fills_total", 1, e.Order.Pair, string(e.Order.Side))
+	case events.HaltEvent:
+		r.Add("tradingbot_halts_total", 1, e.Check)
+	case events.ErrorEvent:
+		r.Add("tradingbot_errors_total", 1, e.Source)
+	case events.WatchdogEvent:
+		r.Add("tradingbot_watchdog_events_total", 1, e.Component)
+	case events.DegradationEvent:
+		r.Add("tradingbot_degradations_total", 1, e.Strategy)
+	case events.CircuitEvent:
+		open := 0.0
+		if e.To != "closed" {
+			open = 1
+		}
+		r.Set("tradingbot_circuit_open", open)
+	case events.CycleEvent:
+		r.Set("tradingbot_last_cycle_timestamp_seconds", float64(e.Time.UnixNano())/float64(time.Second), e.Symbol)
+		r.Observe("tradingbot_cycle_duration_seconds", e.Duration.Seconds(), "cycle")
+		for phase, d := range e.Phases {
+			r.Observe("tradingbot_cycle_duration_seconds", d.Seconds(), phase)
+		}
+	case events.KillEvent:
+		r.Set("tradingbot_killed", 1)
+	}
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format, in
+// catalog order. Metrics without a value yet are left out.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	for _, m := range Catalog {
+		names := []string{m.Name}
+		if m.Type == Summary {
+			names = []string{m.Name + "_sum", m.Name + "_count"}
+		}
+		if len(r.values[names[0]]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.Name, m.Help, m.Name, m.Type)
+		for _, name := range names {
+			series := r.values[name]
+			keys := make([]string, 0, len(series))
+			for k := range series {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if k == "" {
+					fmt.Fprintf(&b, "%s %s\n", name, formatValue(series[k]))
+				} else {
+					fmt.Fprintf(&b, "%s{%s} %s\n", name, k, formatValue(series[k]))
+				}
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
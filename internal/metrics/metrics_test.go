@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestRegistryRecordsEvents(t *testing.T) {
+	bus := events.NewBus()
+	r := NewRegistry()
+	r.Subscribe(bus)
+
+	order := &models.Order{Pair: "005930", Side: models.OrderSideBuy}
+	bus.Publish(events.OrderEvent{Order: order})
+	bus.Publish(events.OrderEvent{Order: order})
+	bus.Publish(events.ErrorEvent{Source: "exchange", Err: errors.New("timeout")})
+	bus.Publish(events.CircuitEvent{From: "closed", To: "open"})
+	bus.Publish(events.CycleEvent{Symbol: "005930", Duration: 2 * time.Second, Phases: map[string]time.Duration{events.PhaseData: time.Second}, Time: time.Unix(1700000000, 0)})
+	bus.Publish(events.CycleEvent{Symbol: "005930", Duration: time.Second, Time: time.Unix(1700000060, 0)})
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE tradingbot_up gauge\ntradingbot_up 1\n",
+		`tradingbot_orders_total{symbol="005930",side="buy"} 2`,
+		`tradingbot_errors_total{source="exchange"} 1`,
+		"tradingbot_circuit_open 1\n",
+		`tradingbot_last_cycle_timestamp_seconds{symbol="005930"} 1.70000006e+09`,
+		"# TYPE tradingbot_cycle_duration_seconds summary\n",
+		`tradingbot_cycle_duration_seconds_sum{phase="cycle"} 3`,
+		`tradingbot_cycle_duration_seconds_count{phase="cycle"} 2`,
+		`tradingbot_cycle_duration_seconds_count{phase="data"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("exposition lacks %q:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "tradingbot_killed") {
+		t.Error("metrics without a value should be left out")
+	}
+}
+
+func TestRegistryRejectsUnknownMetrics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Add of a gauge did not panic")
+		}
+	}()
+	NewRegistry().Add("tradingbot_paused", 1)
+}
+
+var seriesName = regexp.MustCompile(`tradingbot_[a-z_]+`)
+
+func TestDashboardAndAlertsUseCatalog(t *testing.T) {
+	known := func(name string) bool {
+		for _, m := range Catalog {
+			if name == m.Name || m.Type == Summary && (name == m.Name+"_sum" || name == m.Name+"_count") {
+				return true
+			}
+		}
+		return false
+	}
+
+	data, err := Dashboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dashboard struct {
+		Panels []struct {
+			Title   string `json:"title"`
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		t.Fatalf("dashboard is not valid JSON: %v", err)
+	}
+	if len(dashboard.Panels) != len(Catalog) {
+		t.Errorf("dashboard has %d panels, want one per metric (%d)", len(dashboard.Panels), len(Catalog))
+	}
+	for _, p := range dashboard.Panels {
+		for _, name := range seriesName.FindAllString(p.Targets[0].Expr, -1) {
+			if !known(name) {
+				t.Errorf("panel %q queries %s, which is not in the catalog", p.Title, name)
+			}
+		}
+	}
+
+	data, err = AlertRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules struct {
+		Groups []struct {
+			Rules []struct {
+				Alert string `yaml:"alert"`
+				Expr  string `yaml:"expr"`
+			} `yaml:"rules"`
+		} `yaml:"groups"`
+	}
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		t.Fatalf("alert rules are not valid YAML: %v", err)
+	}
+	if len(rules.Groups) != 1 || len(rules.Groups[0].Rules) == 0 {
+		t.Fatalf("alert rules = %+v, want one group of rules", rules)
+	}
+	for _, r := range rules.Groups[0].Rules {
+		for _, name := range seriesName.FindAllString(r.Expr, -1) {
+			if !known(name) {
+				t.Errorf("alert %s uses %s, which is not in the catalog", r.Alert, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// BacktestRun is a stored backtest: what was run, on which data, and how it did.
+type BacktestRun struct {
+	ID        int64     `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	Symbol    string    `json:"symbol" db:"symbol"`
+	Strategy  string    `json:"strategy" db:"strategy"`
+	// Params holds the strategy parameters and the backtester settings,
+	// including the seed, stored as JSON.
+	Params   map[string]interface{} `json:"params" db:"params"`
+	DataFrom time.Time              `json:"data_from" db:"data_from"`
+	DataTo   time.Time              `json:"data_to" db:"data_to"`
+	// Metrics holds the results by name, e.g. "total_profit", stored as JSON.
+	Metrics map[string]float64 `json:"metrics" db:"metrics"`
+	// GitHash is the VCS revision the binary was built from and ConfigHash
+	// identifies the configuration that shaped the run.
+	GitHash    string `json:"git_hash" db:"git_hash"`
+	ConfigHash string `json:"config_hash" db:"config_hash"`
+}
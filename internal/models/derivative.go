@@ -0,0 +1,98 @@
+package models
+
+// ContractKind is the kind of a listed derivative.
+type ContractKind string
+
+const (
+	ContractFuture ContractKind = "future"
+	ContractCall   ContractKind = "call"
+	ContractPut    ContractKind = "put"
+)
+
+// ContractKindOf tells the kind of a KRX derivative from its short code,
+// whose first digit is 1 for futures, 2 for calls and 3 for puts.
+func ContractKindOf(code string) (ContractKind, bool) {
+	if code == "" {
+		return "", false
+	}
+	switch code[0] {
+	case '1':
+		return ContractFuture, true
+	case '2':
+		return ContractCall, true
+	case '3':
+		return ContractPut, true
+	}
+	return "", false
+}
+
+// DerivativeQuote is the current quote of a futures or options contract.
+type DerivativeQuote struct {
+	Code         string  `json:"code"`
+	Price        float64 `json:"price"`
+	UpperLimit   float64 `json:"upper_limit,omitempty"`
+	LowerLimit   float64 `json:"lower_limit,omitempty"`
+	OpenInterest float64 `json:"open_interest,omitempty"`
+}
+
+// OptionQuote is a row of an option chain.
+type OptionQuote struct {
+	Code       string       `json:"code"`
+	Kind       ContractKind `json:"kind"`
+	Strike     float64      `json:"strike"`
+	Price      float64      `json:"price"`
+	Delta      float64      `json:"delta"`
+	ImpliedVol float64      `json:"implied_vol"`
+}
+
+// PositionSide is the direction of a derivatives position.
+type PositionSide string
+
+const (
+	PositionLong  PositionSide = "long"
+	PositionShort PositionSide = "short"
+)
+
+// DerivativePosition is an open futures or options position. Unlike stock
+// holdings it can be short; Quantity is the number of contracts either way.
+type DerivativePosition struct {
+	Code         string       `json:"code"`
+	Name         string       `json:"name"`
+	Kind         ContractKind `json:"kind"`
+	Side         PositionSide `json:"side"`
+	Quantity     float64      `json:"quantity"`
+	AvgPrice     float64      `json:"avg_price"`
+	CurrentPrice float64      `json:"current_price"`
+	ProfitLoss   float64      `json:"profit_loss"`
+}
+
+// Signed returns the quantity, negative for short positions.
+func (p DerivativePosition) Signed() float64 {
+	if p.Side == PositionShort {
+		return -p.Quantity
+	}
+	return p.Quantity
+}
+
+// DerivativeOrder is an order for a futures or options contract. A zero
+// Price is a market order.
+type DerivativeOrder struct {
+	Code     string    `json:"code"`
+	Side     OrderSide `json:"side"`
+	Quantity float64   `json:"quantity"`
+	Price    float64   `json:"price,omitempty"`
+	// Reason explains why a plan proposed the order.
+	Reason string `json:"reason,omitempty"`
+}
+
+// DerivativesMargin is the margin status of a futures and options account.
+type DerivativesMargin struct {
+	// Deposit is the cash and collateral deposited (예탁총액).
+	Deposit float64 `json:"deposit"`
+	// Orderable is what remains for new orders (주문가능총액).
+	Orderable float64 `json:"orderable"`
+	// Initial and Maintenance are the margins required by the open
+	// positions (위탁증거금, 유지증거금).
+	Initial     float64 `json:"initial"`
+	Maintenance float64 `json:"maintenance"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Dividend is a cash dividend on a stock. Holders of record at RecordDate are
+// paid PerShare on PayDate. Dividends credited to the account also carry the
+// Quantity paid on, the gross Amount and the Tax withheld.
+type Dividend struct {
+	Symbol     string    `json:"symbol"`
+	RecordDate time.Time `json:"record_date"`
+	PayDate    time.Time `json:"pay_date"`
+	PerShare   float64   `json:"per_share"`
+	Quantity   float64   `json:"quantity,omitempty"`
+	Amount     float64   `json:"amount,omitempty"`
+	Tax        float64   `json:"tax,omitempty"`
+}
+
+// Net returns the amount credited, after the withholding tax.
+func (d Dividend) Net() float64 {
+	return d.Amount - d.Tax
+}
@@ -0,0 +1,33 @@
+package models
+
+import "errors"
+
+// Failures that callers handle differently, wrapped by the errors of the
+// exchange clients, the paper exchange and the engine. Tell them apart with
+// errors.Is rather than by message: KIS writes its messages in Korean and
+// changes them without notice.
+var (
+	// ErrUnauthorized is an access token that was refused, e.g. because it
+	// expired; a new token can succeed.
+	ErrUnauthorized = errors.New("unauthorized request")
+	// ErrRateLimited is a request refused for exceeding the request rate;
+	// the same request can succeed later.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrMarketClosed is an order refused outside trading hours.
+	ErrMarketClosed = errors.New("market is closed")
+	// ErrInsufficientFunds is an order exceeding the cash or the position
+	// available to it.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrOrderRejected is an order refused by the broker for another reason.
+	ErrOrderRejected = errors.New("order rejected")
+	// ErrOrdersDisabled is an order the client did not send because orders
+	// are switched off locally, e.g. not yet approved or halted.
+	ErrOrdersDisabled = errors.New("orders are disabled")
+)
+
+// OrderRefused tells whether err is an order the broker answered by refusing
+// it: ErrOrderRejected, ErrInsufficientFunds or ErrMarketClosed. Sending the
+// same order again fails the same way.
+func OrderRefused(err error) bool {
+	return errors.Is(err, ErrOrderRejected) || errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrMarketClosed)
+}
@@ -1,6 +1,83 @@
 package models
 
+import (
+	"strconv"
+	"time"
+)
+
 type MarketData struct {
 	StckPrpr string `json:"stck_prpr"`
+	// 시가, 고가, 저가. 일봉 등 봉 데이터에만 있습니다.
+	StckOprc string `json:"stck_oprc,omitempty"`
+	StckHgpr string `json:"stck_hgpr,omitempty"`
+	StckLwpr string `json:"stck_lwpr,omitempty"`
+	// 상한가, 하한가와 거래정지 여부. 시세 조회 응답에 없으면 비어 있습니다.
+	StckMxpr   string `json:"stck_mxpr,omitempty"`
+	StckLlam   string `json:"stck_llam,omitempty"`
+	TempStopYn string `json:"temp_stop_yn,omitempty"`
+	TrhtYn     string `json:"trht_yn,omitempty"`
+	// ETF의 장중 추정 NAV(iNAV), ETN의 지표가치(IIV). ETF/ETN에만 있습니다.
+	Nav string `json:"nav,omitempty"`
+	// 매도, 매수 1호가. 호가를 주는 시세 소스에만 있습니다.
+	Askp1 string `json:"askp1,omitempty"`
+	Bidp1 string `json:"bidp1,omitempty"`
+	// QuoteTime is when the price was current: the source's timestamp when it
+	// has one, otherwise when it was fetched, or the end of the candle it
+	// closes.
+	QuoteTime time.Time `json:"quote_time"`
 	// 필요한 다른 필드들을 추가합니다.
 }
+
+// AskingPrice is the top of the order book (호가) of a symbol: the best ask
+// and bid, and when the book was last updated.
+type AskingPrice struct {
+	Ask, Bid float64
+	Time     time.Time
+}
+
+// UpperLimit returns the day's upper price limit (상한가), or zero when unknown.
+func (m *MarketData) UpperLimit() float64 {
+	v, _ := strconv.ParseFloat(m.StckMxpr, 64)
+	return v
+}
+
+// LowerLimit returns the day's lower price limit (하한가), or zero when unknown.
+func (m *MarketData) LowerLimit() float64 {
+	v, _ := strconv.ParseFloat(m.StckLlam, 64)
+	return v
+}
+
+// Halted reports whether trading in the symbol is suspended (거래정지) or
+// temporarily stopped (임시정지).
+func (m *MarketData) Halted() bool {
+	return m.TrhtYn == "Y" || m.TempStopYn == "Y"
+}
+
+// NAV returns the indicative net asset value of an ETF or ETN, or zero when
+// unknown.
+func (m *MarketData) NAV() float64 {
+	v, _ := strconv.ParseFloat(m.Nav, 64)
+	return v
+}
+
+// Premium returns how far the price is above the NAV, as a fraction of it;
+// negative values are a discount. ok is false when either is unknown.
+func (m *MarketData) Premium() (premium float64, ok bool) {
+	nav := m.NAV()
+	price, err := strconv.ParseFloat(m.StckPrpr, 64)
+	if nav <= 0 || err != nil || price <= 0 {
+		return 0, false
+	}
+	return price/nav - 1, true
+}
+
+// Spread returns the difference between the best ask and bid as a fraction of
+// their mid price; ok is false when either is unknown.
+func (m *MarketData) Spread() (spread float64, ok bool) {
+	ask, errAsk := strconv.ParseFloat(m.Askp1, 64)
+	bid, errBid := strconv.ParseFloat(m.Bidp1, 64)
+	if errAsk != nil || errBid != nil || ask <= 0 || bid <= 0 || ask < bid {
+		return 0, false
+	}
+	return (ask - bid) / ((ask + bid) / 2), true
+}
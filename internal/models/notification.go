@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type NotificationStatus string
+
+const (
+	NotificationPending   NotificationStatus = "pending"
+	NotificationDelivered NotificationStatus = "delivered"
+	// NotificationDead marks a notification that gave up after its last
+	// attempt; it is kept for inspection but no longer delivered.
+	NotificationDead NotificationStatus = "dead"
+)
+
+// Notification is a message waiting in the notification outbox. Channel names
+// where it goes, e.g. "email" or "webhook <url>", and Body holds what is sent.
+type Notification struct {
+	ID          int64              `json:"id" db:"id"`
+	CreatedAt   time.Time          `json:"created_at" db:"created_at"`
+	Channel     string             `json:"channel" db:"channel"`
+	Kind        string             `json:"kind" db:"kind"`
+	Subject     string             `json:"subject,omitempty" db:"subject"`
+	Body        string             `json:"body" db:"body"`
+	Status      NotificationStatus `json:"status" db:"status"`
+	Attempts    int                `json:"attempts" db:"attempts"`
+	NextAttempt time.Time          `json:"next_attempt" db:"next_attempt"`
+	LastError   string             `json:"last_error,omitempty" db:"last_error"`
+}
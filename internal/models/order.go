@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+)
 
 type OrderType string
 type OrderSide string
@@ -27,4 +31,34 @@ type Order struct {
 	Price     float64     `json:"price" db:"price"`
 	Status    OrderStatus `json:"status" db:"status"`
 	Timestamp time.Time   `json:"timestamp" db:"timestamp"`
+	// Strategy names the allocation sleeve the order belongs to, if any.
+	Strategy string `json:"strategy,omitempty" db:"strategy"`
+	// SignalPrice is the market price the order's signal was acted on at;
+	// zero when unknown, e.g. for manual orders.
+	SignalPrice float64 `json:"signal_price,omitempty" db:"signal_price"`
+	// Key identifies the order record across retries of saving it, so that
+	// a record saved twice is stored once; see NewOrderKey.
+	Key string `json:"key,omitempty" db:"order_key"`
+}
+
+// NewOrderKey returns a new random (version 4) UUID for Order.Key.
+func NewOrderKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// OpenOrder is an order resting at the exchange that has not been completely filled.
+type OpenOrder struct {
+	OrderNo      string    `json:"order_no"`
+	BranchNo     string    `json:"branch_no"`
+	StockCode    string    `json:"stock_code"`
+	Side         OrderSide `json:"side"`
+	Quantity     float64   `json:"quantity"`
+	RemainingQty float64   `json:"remaining_qty"`
+	Price        float64   `json:"price"`
 }
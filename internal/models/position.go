@@ -0,0 +1,15 @@
+package models
+
+// Position is a holding in the trading account.
+type Position struct {
+	StockCode    string  `json:"stock_code"`
+	Name         string  `json:"name"`
+	Quantity     float64 `json:"quantity"`
+	AvgPrice     float64 `json:"avg_price"`
+	CurrentPrice float64 `json:"current_price"`
+	ProfitLoss   float64 `json:"profit_loss"`
+	// Loan is the credit (margin) loan outstanding on the position and
+	// LoanDate, YYYYMMDD, the day it was taken out.
+	Loan     float64 `json:"loan,omitempty"`
+	LoanDate string  `json:"loan_date,omitempty"`
+}
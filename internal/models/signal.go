@@ -12,4 +12,23 @@ type Signal struct {
 	Type   SignalType `json:"type"`
 	Pair   string     `json:"pair"`
 	Amount float64    `json:"amount"`
+	// Notional is the order size in KRW, used instead of Amount when Amount is
+	// zero. It is converted to whole lots at the current price.
+	Notional float64 `json:"notional,omitempty"`
+	// LimitPrice makes the order a limit order at that price; zero places a
+	// market order.
+	LimitPrice float64 `json:"limit_price,omitempty"`
+	// Session is the KRX session the order is placed in, e.g. "after_hours_close";
+	// empty means the regular session.
+	Session string `json:"session,omitempty"`
+	// Strategy names the allocation sleeve that generated the signal, if any.
+	Strategy string `json:"strategy,omitempty"`
+	// Credit, when set, places a credit (margin) order financed as named by
+	// the config.MarginConfig credit type: a buy borrows part of its cost, a
+	// sell repays the loan taken out on LoanDate (YYYYMMDD).
+	Credit   string `json:"credit,omitempty"`
+	LoanDate string `json:"loan_date,omitempty"`
+	// Fraction, when set, makes a buy build the position only up to that
+	// fraction of its target, for entering in tranches.
+	Fraction float64 `json:"fraction,omitempty"`
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// AccountSnapshot is the account at the end of a day: the cash balance as
+// reported by the broker and the positions held.
+type AccountSnapshot struct {
+	Date      time.Time  `json:"date"`
+	Cash      string     `json:"cash"`
+	Positions []Position `json:"positions"`
+}
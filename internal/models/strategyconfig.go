@@ -1,7 +1,25 @@
 package models
 
-type StrategyConfig struct {
-	ShortPeriod int     `yaml:"short_period"`
-	LongPeriod  int     `yaml:"long_period"`
-	Threshold   float64 `yaml:"threshold"`
+// MovingAverageConfig holds the parameters of the moving average crossover strategy.
+// With news sentiment available, buy crossovers are held back while it is below
+// MinBuySentiment, and sentiment at or below SellSentiment signals a sell.
+// With BuyBelowVWAP and the session VWAP available, buys are held back while
+// the price is at or above it.
+type MovingAverageConfig struct {
+	ShortPeriod     int      `yaml:"short_period"`
+	LongPeriod      int      `yaml:"long_period"`
+	Threshold       float64  `yaml:"threshold"`
+	MinBuySentiment *float64 `yaml:"min_buy_sentiment"`
+	SellSentiment   *float64 `yaml:"sell_sentiment"`
+	BuyBelowVWAP    bool     `yaml:"buy_below_vwap"`
+}
+
+// NAVDeviationConfig holds the parameters of the NAV deviation strategy for
+// ETFs and ETNs: it buys when the price trades at a discount of at least
+// EntryDiscount to the indicative NAV and sells once it trades at a premium
+// of ExitPremium or more, both as fractions of the NAV. A negative
+// ExitPremium sells while the discount has narrowed to that much.
+type NAVDeviationConfig struct {
+	EntryDiscount float64 `yaml:"entry_discount"`
+	ExitPremium   float64 `yaml:"exit_premium"`
 }
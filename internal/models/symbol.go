@@ -0,0 +1,45 @@
+package models
+
+// SymbolStatus is the trading status of a listed symbol.
+type SymbolStatus string
+
+const (
+	SymbolStatusNormal SymbolStatus = "normal"
+	// SymbolStatusAdministrative marks 관리종목, which still trade.
+	SymbolStatusAdministrative SymbolStatus = "administrative"
+	SymbolStatusHalted         SymbolStatus = "halted"
+	SymbolStatusDelisted       SymbolStatus = "delisted"
+)
+
+// InstrumentType is the kind of product a symbol lists.
+type InstrumentType string
+
+const (
+	InstrumentStock InstrumentType = "stock"
+	InstrumentETF   InstrumentType = "etf"
+	InstrumentETN   InstrumentType = "etn"
+)
+
+// Symbol is the master data of a KRX-listed stock.
+type Symbol struct {
+	Code    string         `json:"code"`
+	Name    string         `json:"name"`
+	Market  string         `json:"market"`
+	Sector  string         `json:"sector"`
+	LotSize int            `json:"lot_size"`
+	Status  SymbolStatus   `json:"status"`
+	Type    InstrumentType `json:"type"`
+	// Indexes lists the indexes the symbol is a constituent of, e.g. KOSPI200.
+	Indexes []string `json:"indexes,omitempty"`
+}
+
+// Tradable reports whether orders for the symbol can currently be placed.
+func (s Symbol) Tradable() bool {
+	return s.Status != SymbolStatusHalted && s.Status != SymbolStatusDelisted
+}
+
+// ExchangeTraded reports whether the symbol is an ETF or ETN, which track an
+// indicative value (iNAV/IIV) and are quoted by liquidity providers.
+func (s Symbol) ExchangeTraded() bool {
+	return s.Type == InstrumentETF || s.Type == InstrumentETN
+}
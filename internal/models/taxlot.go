@@ -0,0 +1,10 @@
+package models
+
+// LotSelection assigns shares of a sell order to the tax lot of an earlier
+// buy, for specific identification of the lots sold. Both orders are given by
+// their stored IDs.
+type LotSelection struct {
+	SellID   int64   `json:"sell_id" db:"sell_id"`
+	LotID    int64   `json:"lot_id" db:"lot_id"`
+	Quantity float64 `json:"quantity" db:"quantity"`
+}
@@ -0,0 +1,234 @@
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const defaultWindow = 20
+
+// Baseline is the backtested performance a strategy is held to. Expectancy is
+// the average return per trade in percent.
+type Baseline struct {
+	RunID      int64
+	WinRate    float64
+	Expectancy float64
+}
+
+// BaselineFromRun reads the baseline from the metrics of a stored backtest run.
+func BaselineFromRun(run *models.BacktestRun) Baseline {
+	return Baseline{RunID: run.ID, WinRate: run.Metrics["win_rate"], Expectancy: run.Metrics["avg_profit_per_trade"]}
+}
+
+// Pauser stops a strategy from opening positions, e.g. the engine.
+type Pauser interface {
+	PauseStrategy(name string)
+}
+
+// Stats is the live performance of a strategy over its recent closed trades.
+type Stats struct {
+	Trades     int
+	WinRate    float64
+	Expectancy float64
+}
+
+type key struct{ strategy, symbol string }
+
+type position struct {
+	quantity, avgPrice float64
+}
+
+// Monitor follows the orders of every strategy, keeps the returns of their
+// last closed trades and compares them with the strategies' baselines; see
+// config.MonitorConfig. A sell of a held position closes a trade. Monitor is
+// safe for concurrent use.
+type Monitor struct {
+	cfg             config.MonitorConfig
+	defaultStrategy string
+	baselines       map[string]Baseline
+	pauser          Pauser
+	bus             *events.Bus
+
+	mu        sync.Mutex
+	positions map[key]*position
+	returns   map[string][]float64
+	degraded  map[string]bool
+}
+
+// New creates a monitor holding each strategy to its baseline. Strategies
+// without one are tracked but never judged. Orders without a strategy belong
+// to defaultStrategy. pauser is only used with the pause action.
+func New(cfg config.MonitorConfig, defaultStrategy string, baselines map[string]Baseline, pauser Pauser) *Monitor {
+	if cfg.Window == 0 {
+		cfg.Window = defaultWindow
+	}
+	if cfg.MinTrades == 0 {
+		cfg.MinTrades = cfg.Window
+	}
+	if cfg.Action == "" {
+		cfg.Action = config.MonitorActionAlert
+	}
+	return &Monitor{
+		cfg:             cfg,
+		defaultStrategy: defaultStrategy,
+		baselines:       baselines,
+		pauser:          pauser,
+		positions:       map[key]*position{},
+		returns:         map[string][]float64{},
+		degraded:        map[string]bool{},
+	}
+}
+
+// Subscribe follows the orders placed for strategy signals on bus and
+// publishes degradations there.
+func (m *Monitor) Subscribe(bus *events.Bus) {
+	m.bus = bus
+	bus.Subscribe(m.record, events.KindDecision)
+}
+
+// Stats returns the live performance of a strategy.
+func (m *Monitor) Stats(strategy string) Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return stats(m.returns[strategy])
+}
+
+func (m *Monitor) record(ev events.Event) {
+	d := ev.(events.DecisionEvent)
+	if d.Action != events.ActionOrdered || d.Source != "strategy" {
+		return
+	}
+	name := d.Signal.Strategy
+	if name == "" {
+		name = m.defaultStrategy
+	}
+	quantity, price := d.Order.Amount, d.Order.Price
+	if quantity == 0 {
+		quantity = d.Signal.Amount
+	}
+	if price == 0 && d.MarketData != nil {
+		price, _ = strconv.ParseFloat(d.MarketData.StckPrpr, 64)
+	}
+	if quantity <= 0 || price <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	k := key{name, d.Symbol}
+	p := m.positions[k]
+	if p == nil {
+		p = &position{}
+		m.positions[k] = p
+	}
+	closed := false
+	switch d.Signal.Type {
+	case models.BuySignal:
+		p.avgPrice = (p.avgPrice*p.quantity + price*quantity) / (p.quantity + quantity)
+		p.quantity += quantity
+	case models.SellSignal:
+		if p.quantity > 0 {
+			m.addReturn(name, (price-p.avgPrice)/p.avgPrice*100)
+			closed = true
+			p.quantity -= quantity
+			if p.quantity <= 0 {
+				*p = position{}
+			}
+		}
+	}
+	var degradation *events.DegradationEvent
+	if closed {
+		degradation = m.evaluate(name)
+	}
+	m.mu.Unlock()
+
+	if degradation != nil {
+		if degradation.Paused {
+			m.pauser.PauseStrategy(name)
+		}
+		degradation.Time = d.Time
+		if m.bus != nil {
+			m.bus.Publish(*degradation)
+		}
+	}
+}
+
+// addReturn appends a closed trade's return to the strategy's window. Must be
+// called with mu held.
+func (m *Monitor) addReturn(name string, r float64) {
+	returns := append(m.returns[name], r)
+	if len(returns) > m.cfg.Window {
+		returns = returns[len(returns)-m.cfg.Window:]
+	}
+	m.returns[name] = returns
+}
+
+// evaluate judges a strategy against its baseline and returns the event to
+// publish when it has just degraded. Must be called with mu held.
+func (m *Monitor) evaluate(name string) *events.DegradationEvent {
+	base, ok := m.baselines[name]
+	s := stats(m.returns[name])
+	if !ok || s.Trades < m.cfg.MinTrades {
+		return nil
+	}
+
+	var reasons []string
+	if m.cfg.MaxWinRateDrop > 0 && s.WinRate < base.WinRate-m.cfg.MaxWinRateDrop {
+		reasons = append(reasons, fmt.Sprintf("win rate %.1f%% vs %.1f%% backtested", s.WinRate*100, base.WinRate*100))
+	}
+	if m.cfg.MaxExpectancyDrop > 0 && s.Expectancy < base.Expectancy-m.cfg.MaxExpectancyDrop {
+		reasons = append(reasons, fmt.Sprintf("expectancy %.2f%% vs %.2f%% backtested", s.Expectancy, base.Expectancy))
+	}
+	fields := logrus.Fields{"strategy": name, "trades": s.Trades, "win_rate": s.WinRate, "expectancy": s.Expectancy, "baseline": base.RunID}
+
+	if len(reasons) == 0 {
+		if m.degraded[name] {
+			m.degraded[name] = false
+			log.WithFields(fields).Info("Strategy performance back within baseline")
+		}
+		return nil
+	}
+	if m.degraded[name] {
+		return nil
+	}
+	m.degraded[name] = true
+	paused := m.cfg.Action == config.MonitorActionPause && m.pauser != nil
+	reason := strings.Join(reasons, ", ")
+	log.WithFields(fields).WithField("paused", paused).Warnf("Strategy performance degraded: %s", reason)
+	return &events.DegradationEvent{
+		Strategy:           name,
+		Trades:             s.Trades,
+		WinRate:            s.WinRate,
+		Expectancy:         s.Expectancy,
+		BaselineWinRate:    base.WinRate,
+		BaselineExpectancy: base.Expectancy,
+		Reason:             reason,
+		Paused:             paused,
+	}
+}
+
+func stats(returns []float64) Stats {
+	s := Stats{Trades: len(returns)}
+	if s.Trades == 0 {
+		return s
+	}
+	wins := 0
+	for _, r := range returns {
+		if r > 0 {
+			wins++
+		}
+		s.Expectancy += r
+	}
+	s.WinRate = float64(wins) / float64(s.Trades)
+	s.Expectancy /= float64(s.Trades)
+	return s
+}
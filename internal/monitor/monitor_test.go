@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"testing"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+type fakePauser struct{ paused []string }
+
+func (p *fakePauser) PauseStrategy(name string) { p.paused = append(p.paused, name) }
+
+func trade(bus *events.Bus, strategy string, side models.SignalType, price float64) {
+	bus.Publish(events.DecisionEvent{
+		Symbol: "005930",
+		Source: "strategy",
+		Signal: &models.Signal{Type: side, Pair: "005930", Amount: 10, Strategy: strategy},
+		Action: events.ActionOrdered,
+		Order:  &models.Order{Pair: "005930", Amount: 10, Price: price},
+	})
+}
+
+func TestDegradedStrategyPaused(t *testing.T) {
+	cfg := config.MonitorConfig{Window: 4, MinTrades: 3, MaxWinRateDrop: 0.2, Action: config.MonitorActionPause}
+	baselines := map[string]Baseline{"moving_average": {RunID: 7, WinRate: 0.6, Expectancy: 1.5}}
+	pauser := &fakePauser{}
+	m := New(cfg, "moving_average", baselines, pauser)
+	bus := events.NewBus()
+	m.Subscribe(bus)
+	var degraded []events.DegradationEvent
+	bus.Subscribe(func(ev events.Event) { degraded = append(degraded, ev.(events.DegradationEvent)) }, events.KindDegradation)
+
+	// A win and a loss are too few trades to judge.
+	trade(bus, "", models.BuySignal, 100)
+	trade(bus, "", models.SellSignal, 110)
+	trade(bus, "", models.BuySignal, 100)
+	trade(bus, "", models.SellSignal, 95)
+	if len(degraded) != 0 {
+		t.Fatalf("degraded after 2 trades: %+v", degraded)
+	}
+
+	// A second loss puts the win rate at 33%, more than 20 points below 60%.
+	trade(bus, "", models.BuySignal, 100)
+	trade(bus, "", models.SellSignal, 98)
+	if len(degraded) != 1 {
+		t.Fatalf("degradations = %+v, want 1", degraded)
+	}
+	d := degraded[0]
+	if d.Strategy != "moving_average" || d.Trades != 3 || !d.Paused || d.BaselineWinRate != 0.6 {
+		t.Errorf("degradation = %+v", d)
+	}
+	if len(pauser.paused) != 1 || pauser.paused[0] != "moving_average" {
+		t.Errorf("paused = %v, want [moving_average]", pauser.paused)
+	}
+
+	// Further losses are not reported again.
+	trade(bus, "", models.BuySignal, 100)
+	trade(bus, "", models.SellSignal, 90)
+	if len(degraded) != 1 {
+		t.Errorf("degradations = %d, want 1", len(degraded))
+	}
+	if s := m.Stats("moving_average"); s.Trades != 4 || s.WinRate != 0.25 {
+		t.Errorf("stats = %+v, want 4 trades at 25%%", s)
+	}
+}
+
+func TestStrategyWithoutBaselineNotJudged(t *testing.T) {
+	cfg := config.MonitorConfig{Window: 2, MaxExpectancyDrop: 0.5}
+	m := New(cfg, "rsi", map[string]Baseline{"fast": {WinRate: 0.5, Expectancy: 2}}, nil)
+	bus := events.NewBus()
+	m.Subscribe(bus)
+	var degraded []events.DegradationEvent
+	bus.Subscribe(func(ev events.Event) { degraded = append(degraded, ev.(events.DegradationEvent)) }, events.KindDegradation)
+
+	for i := 0; i < 2; i++ {
+		trade(bus, "", models.BuySignal, 100)
+		trade(bus, "", models.SellSignal, 90)
+		trade(bus, "fast", models.BuySignal, 100)
+		trade(bus, "fast", models.SellSignal, 101)
+	}
+	if len(degraded) != 1 || degraded[0].Strategy != "fast" || degraded[0].Paused {
+		t.Fatalf("degradations = %+v, want an alert for fast only", degraded)
+	}
+	if degraded[0].Expectancy != 1 {
+		t.Errorf("expectancy = %g, want 1", degraded[0].Expectancy)
+	}
+}
@@ -0,0 +1,184 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+)
+
+var log = logging.New()
+
+// Headline is a news item about a symbol.
+type Headline struct {
+	Title     string
+	Link      string
+	Published time.Time
+}
+
+// Source searches news headlines.
+type Source interface {
+	Headlines(query string) ([]Headline, error)
+}
+
+// NewSources builds the sources configured in cfg.
+func NewSources(cfg config.NewsConfig) ([]Source, error) {
+	var sources []Source
+	for _, name := range cfg.Sources {
+		switch name {
+		case config.NewsSourceRSS:
+			sources = append(sources, NewRSS(cfg.RSSURL))
+		case config.NewsSourceNaver:
+			sources = append(sources, NewNaver(cfg.NaverClientID, cfg.NaverClientSecret))
+		default:
+			return nil, fmt.Errorf("unknown news source: %s", name)
+		}
+	}
+	return sources, nil
+}
+
+type scored struct {
+	published time.Time
+	score     float64
+}
+
+// Tracker polls the news of symbols and keeps their recent sentiment. It
+// implements strategy.Sentiment.
+type Tracker struct {
+	sources []Source
+	scorer  Scorer
+	queries map[string]string
+	window  time.Duration
+	clock   clock.Clock
+
+	mu sync.Mutex
+	// headlines holds the scored headlines of each symbol within the window,
+	// keyed by link or title so repeated results count once.
+	headlines map[string]map[string]scored
+}
+
+// NewTracker creates a tracker searching sources as configured in cfg.
+func NewTracker(cfg config.NewsConfig, sources []Source, scorer Scorer) *Tracker {
+	window, _ := time.ParseDuration(cfg.Window)
+	return &Tracker{
+		sources:   sources,
+		scorer:    scorer,
+		queries:   cfg.Queries,
+		window:    window,
+		clock:     clock.Real,
+		headlines: map[string]map[string]scored{},
+	}
+}
+
+// SetClock replaces the clock that headline ages are measured with.
+func (t *Tracker) SetClock(c clock.Clock) {
+	t.clock = c
+}
+
+// Poll searches the headlines of symbols and scores the new ones. Sources
+// that fail are logged and skipped.
+func (t *Tracker) Poll(symbols []string) {
+	for _, symbol := range symbols {
+		query := t.queries[symbol]
+		if query == "" {
+			query = symbol
+		}
+		for _, source := range t.sources {
+			headlines, err := source.Headlines(query)
+			if err != nil {
+				log.WithError(err).WithField("symbol", symbol).Warn("Failed to fetch news")
+				continue
+			}
+			t.add(symbol, headlines)
+		}
+	}
+	t.prune()
+}
+
+func (t *Tracker) add(symbol string, headlines []Headline) {
+	now := t.clock.Now()
+	for _, h := range headlines {
+		key := h.Link
+		if key == "" {
+			key = h.Title
+		}
+		published := h.Published
+		if published.IsZero() {
+			published = now
+		}
+		if now.Sub(published) > t.window {
+			continue
+		}
+		t.mu.Lock()
+		_, seen := t.headlines[symbol][key]
+		t.mu.Unlock()
+		if seen {
+			continue
+		}
+
+		score, err := t.scorer.Score(h.Title)
+		if err != nil {
+			log.WithError(err).WithField("symbol", symbol).Warn("Failed to score headline")
+			continue
+		}
+		t.mu.Lock()
+		if t.headlines[symbol] == nil {
+			t.headlines[symbol] = map[string]scored{}
+		}
+		t.headlines[symbol][key] = scored{published: published, score: score}
+		t.mu.Unlock()
+	}
+}
+
+func (t *Tracker) prune() {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for symbol, headlines := range t.headlines {
+		for key, h := range headlines {
+			if now.Sub(h.published) > t.window {
+				delete(headlines, key)
+			}
+		}
+		if len(headlines) == 0 {
+			delete(t.headlines, symbol)
+		}
+	}
+}
+
+// Sentiment returns the average score of the symbol's headlines published
+// within the window; ok is false when there are none.
+func (t *Tracker) Sentiment(symbol string) (float64, bool) {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sum, n := 0.0, 0
+	for _, h := range t.headlines[symbol] {
+		if now.Sub(h.published) > t.window {
+			continue
+		}
+		sum += h.score
+		n++
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// Run polls symbols every interval until ctx is cancelled, starting at once.
+func (t *Tracker) Run(ctx context.Context, symbols []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.Poll(symbols)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package news
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+)
+
+type fakeSource map[string][]Headline
+
+func (f fakeSource) Headlines(query string) ([]Headline, error) {
+	return f[query], nil
+}
+
+func TestTrackerAveragesRecentHeadlines(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	clk := clock.NewSimulated(now)
+	source := fakeSource{"삼성전자": {
+		{Title: "삼성전자, 3분기 호실적", Link: "a", Published: now.Add(-time.Hour)},
+		{Title: "삼성전자 주가 하락", Link: "b", Published: now.Add(-2 * time.Hour)},
+		{Title: "삼성전자 신제품 발표", Link: "c", Published: now.Add(-3 * time.Hour)},
+		{Title: "삼성전자 급락", Link: "old", Published: now.Add(-48 * time.Hour)},
+	}}
+	cfg := config.NewsConfig{Window: "24h", Queries: map[string]string{"005930": "삼성전자"}}
+	tr := NewTracker(cfg, []Source{source}, NewLexicon(nil))
+	tr.SetClock(clk)
+
+	if _, ok := tr.Sentiment("005930"); ok {
+		t.Error("Sentiment reported before any poll")
+	}
+	tr.Poll([]string{"005930", "000660"})
+	tr.Poll([]string{"005930"})
+	score, ok := tr.Sentiment("005930")
+	if !ok || score < 0.16 || score > 0.17 {
+		t.Errorf("Sentiment = %v, %v, want (1 - 0.5 + 0) / 3", score, ok)
+	}
+	if _, ok := tr.Sentiment("000660"); ok {
+		t.Error("Sentiment reported for a symbol without news")
+	}
+
+	clk.Advance(22*time.Hour + 30*time.Minute)
+	if score, ok := tr.Sentiment("005930"); !ok || score != 1 {
+		t.Errorf("Sentiment = %v, %v, want only the latest headline", score, ok)
+	}
+}
+
+func TestLexiconExtraWords(t *testing.T) {
+	l := NewLexicon(map[string]float64{"HBM": 0.8, "상승": 0})
+	if s, _ := l.Score("SK하이닉스 hbm 공급 계약"); s != 0.8 {
+		t.Errorf("Score = %v, want the extra word's weight", s)
+	}
+	if s, _ := l.Score("코스피 상승 마감"); s != 0 {
+		t.Errorf("Score = %v, want the reweighted word to be neutral", s)
+	}
+}
+
+func TestSources(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss":
+			if r.URL.Query().Get("q") != "삼성전자" {
+				t.Errorf("rss query = %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`<?xml version="1.0"?><rss><channel>
+				<item><title> 삼성전자 신고가 </title><link>https://a</link><pubDate>Fri, 16 Oct 2026 09:00:00 +0900</pubDate></item>
+			</channel></rss>`))
+		case "/naver":
+			if r.Header.Get("X-Naver-Client-Secret") != "secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"items":[{"title":"<b>삼성전자</b> &quot;최대 실적&quot;","originallink":"https://b","link":"https://n","pubDate":"Fri, 16 Oct 2026 08:00:00 +0900"}]}`))
+		case "/score":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["text"] != "good news" {
+				t.Errorf("scored text = %q", req["text"])
+			}
+			w.Write([]byte(`{"score": 1.7}`))
+		}
+	}))
+	defer srv.Close()
+
+	rss, err := NewRSS(srv.URL + "/rss?q={query}").Headlines("삼성전자")
+	if err != nil || len(rss) != 1 || rss[0].Title != "삼성전자 신고가" || rss[0].Published.Hour() != 9 {
+		t.Errorf("RSS headlines = %+v, %v", rss, err)
+	}
+
+	n := NewNaver("id", "secret")
+	n.url = srv.URL + "/naver"
+	naver, err := n.Headlines("삼성전자")
+	if err != nil || len(naver) != 1 || naver[0].Title != `삼성전자 "최대 실적"` || naver[0].Link != "https://b" {
+		t.Errorf("naver headlines = %+v, %v", naver, err)
+	}
+	n.clientSecret = "wrong"
+	if _, err := n.Headlines("삼성전자"); err == nil {
+		t.Error("naver search with a wrong secret succeeded")
+	}
+
+	scorer, _ := NewScorer(config.NewsConfig{Scorer: config.NewsScorerHTTP, ScorerURL: srv.URL + "/score"})
+	if s, err := scorer.Score("good news"); err != nil || s != 1 {
+		t.Errorf("Score = %v, %v, want the clamped service score", s, err)
+	}
+}
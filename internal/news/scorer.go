@@ -0,0 +1,107 @@
+package news
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"tradingbot/internal/config"
+)
+
+// Scorer rates the sentiment of a headline from -1 (negative) to 1 (positive).
+type Scorer interface {
+	Score(text string) (float64, error)
+}
+
+// NewScorer builds the scorer configured in cfg.
+func NewScorer(cfg config.NewsConfig) (Scorer, error) {
+	switch cfg.Scorer {
+	case "", config.NewsScorerLexicon:
+		return NewLexicon(cfg.Lexicon), nil
+	case config.NewsScorerHTTP:
+		return &HTTPScorer{url: cfg.ScorerURL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+	}
+	return nil, fmt.Errorf("unknown news scorer: %s", cfg.Scorer)
+}
+
+// defaultLexicon weighs common market news words.
+var defaultLexicon = map[string]float64{
+	"급등": 1, "상승": 0.5, "신고가": 0.8, "호실적": 1, "최대 실적": 1, "흑자": 0.7,
+	"수주": 0.6, "상향": 0.6, "호재": 0.8, "돌파": 0.5, "성장": 0.4, "배당 확대": 0.6,
+	"급락": -1, "하락": -0.5, "신저가": -0.8, "어닝쇼크": -1, "적자": -0.7, "부진": -0.6,
+	"하향": -0.6, "악재": -0.8, "소송": -0.6, "리콜": -0.8, "횡령": -1, "압수수색": -1,
+	"상장폐지": -1, "유상증자": -0.5, "우려": -0.4,
+	"surge": 1, "beat": 0.7, "upgrade": 0.6, "record high": 0.8, "profit": 0.4,
+	"plunge": -1, "miss": -0.7, "downgrade": -0.6, "lawsuit": -0.6, "loss": -0.4,
+	"recall": -0.8, "fraud": -1,
+}
+
+// Lexicon scores a headline by the average weight of the words it contains;
+// headlines without any score 0.
+type Lexicon struct {
+	words map[string]float64
+}
+
+// NewLexicon returns the built-in lexicon with extra words added or
+// reweighted.
+func NewLexicon(extra map[string]float64) *Lexicon {
+	words := make(map[string]float64, len(defaultLexicon)+len(extra))
+	for w, v := range defaultLexicon {
+		words[w] = v
+	}
+	for w, v := range extra {
+		words[strings.ToLower(w)] = v
+	}
+	return &Lexicon{words: words}
+}
+
+// Score returns the average weight of the lexicon words in text.
+func (l *Lexicon) Score(text string) (float64, error) {
+	text = strings.ToLower(text)
+	sum, n := 0.0, 0
+	for word, weight := range l.words {
+		if strings.Contains(text, word) {
+			sum += weight
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return sum / float64(n), nil
+}
+
+// HTTPScorer asks an external service, e.g. a language model, to score
+// headlines; see config.NewsConfig.
+type HTTPScorer struct {
+	url    string
+	client *http.Client
+}
+
+// Score posts text to the service and returns its score, clamped to [-1, 1].
+func (h *HTTPScorer) Score(text string) (float64, error) {
+	body, _ := json.Marshal(map[string]string{"text": text})
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("scorer request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read scorer response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("scorer request failed, status code: %d, body: %s", resp.StatusCode, data)
+	}
+	var result struct {
+		Score *float64 `json:"score"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || result.Score == nil {
+		return 0, fmt.Errorf("invalid scorer response: %s", data)
+	}
+	return math.Max(-1, math.Min(1, *result.Score)), nil
+}
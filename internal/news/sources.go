@@ -0,0 +1,149 @@
+package news
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	naverNewsURL     = "https://openapi.naver.com/v1/search/news.json"
+	naverNewsDisplay = 30
+	sourceTimeout    = 10 * time.Second
+)
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// RSS searches a feed whose URL contains {query}, e.g. Google News search.
+type RSS struct {
+	url    string
+	client *http.Client
+}
+
+// NewRSS creates a source for the feed URL template.
+func NewRSS(urlTemplate string) *RSS {
+	return &RSS{url: urlTemplate, client: &http.Client{Timeout: sourceTimeout}}
+}
+
+type rssFeed struct {
+	Items []struct {
+		Title   string `xml:"title"`
+		Link    string `xml:"link"`
+		PubDate string `xml:"pubDate"`
+	} `xml:"channel>item"`
+}
+
+// Headlines returns the items of the feed for query.
+func (r *RSS) Headlines(query string) ([]Headline, error) {
+	body, err := get(r.client, strings.Replace(r.url, "{query}", url.QueryEscape(query), -1), nil)
+	if err != nil {
+		return nil, err
+	}
+	var feed rssFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse RSS feed: %v", err)
+	}
+	headlines := make([]Headline, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		headlines = append(headlines, Headline{
+			Title:     strings.TrimSpace(item.Title),
+			Link:      strings.TrimSpace(item.Link),
+			Published: parseDate(item.PubDate),
+		})
+	}
+	return headlines, nil
+}
+
+// Naver searches the Naver news search API, newest first.
+type Naver struct {
+	url          string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+}
+
+// NewNaver creates a source using the API credentials of a Naver developer
+// application.
+func NewNaver(clientID, clientSecret string) *Naver {
+	return &Naver{url: naverNewsURL, clientID: clientID, clientSecret: clientSecret, client: &http.Client{Timeout: sourceTimeout}}
+}
+
+type naverNews struct {
+	Items []struct {
+		Title        string `json:"title"`
+		OriginalLink string `json:"originallink"`
+		Link         string `json:"link"`
+		PubDate      string `json:"pubDate"`
+	} `json:"items"`
+}
+
+// Headlines returns the latest news for query. Titles come with highlighting
+// markup and HTML entities, which are removed.
+func (n *Naver) Headlines(query string) ([]Headline, error) {
+	q := url.Values{"query": {query}, "display": {fmt.Sprint(naverNewsDisplay)}, "sort": {"date"}}
+	body, err := get(n.client, n.url+"?"+q.Encode(), map[string]string{
+		"X-Naver-Client-Id":     n.clientID,
+		"X-Naver-Client-Secret": n.clientSecret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp naverNews
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse naver news: %v", err)
+	}
+	headlines := make([]Headline, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		link := item.OriginalLink
+		if link == "" {
+			link = item.Link
+		}
+		headlines = append(headlines, Headline{
+			Title:     html.UnescapeString(tagPattern.ReplaceAllString(item.Title, "")),
+			Link:      link,
+			Published: parseDate(item.PubDate),
+		})
+	}
+	return headlines, nil
+}
+
+// parseDate reads an RFC 822 date as used by feeds, or returns the zero time.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func get(client *http.Client, u string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("news request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read news response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("news request failed, status code: %d, body: %s", resp.StatusCode, body)
+	}
+	return body, nil
+}
@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"tradingbot/internal/events"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/report"
+)
+
+// maxReportErrors caps the errors listed in a report; the rest are only counted.
+const maxReportErrors = 50
+
+// upcomingDays is how far ahead the report looks for closures and special sessions.
+const upcomingDays = 7
+
+// Account is the part of the exchange client needed to value the portfolio.
+type Account interface {
+	GetBalance() (string, error)
+	GetPositions() ([]models.Position, error)
+	GetMarketData(stockCode string) (*models.MarketData, error)
+}
+
+type costBasis struct {
+	quantity float64
+	avgPrice float64
+}
+
+// dailyCollector accumulates the events of one reporting period and values the
+// account at its start and end. It is only used from the notifier goroutine.
+type dailyCollector struct {
+	account   Account
+	benchmark string
+
+	since          time.Time
+	startEquity    float64
+	benchmarkStart float64
+	costs          map[string]costBasis
+	lastPrices     map[string]float64
+	trades         []report.Trade
+	realized       float64
+	errors         []report.Error
+	missedErrors   int
+}
+
+func newDailyCollector(account Account, benchmark string) *dailyCollector {
+	return &dailyCollector{account: account, benchmark: benchmark, lastPrices: map[string]float64{}}
+}
+
+// reset starts a new reporting period at now, taking the current equity, cost
+// basis and benchmark price as the baseline.
+func (d *dailyCollector) reset(now time.Time) {
+	d.since = now
+	d.trades = nil
+	d.realized = 0
+	d.errors = nil
+	d.missedErrors = 0
+	d.costs = map[string]costBasis{}
+
+	positions, equity, err := d.valuation()
+	if err != nil {
+		d.addError(now, "report", "", err)
+	}
+	d.startEquity = equity
+	for _, p := range positions {
+		d.costs[p.StockCode] = costBasis{quantity: p.Quantity, avgPrice: p.AvgPrice}
+	}
+
+	d.benchmarkStart = 0
+	if d.benchmark != "" {
+		price, err := d.price(d.benchmark)
+		if err != nil {
+			d.addError(now, "report", d.benchmark, err)
+		}
+		d.benchmarkStart = price
+	}
+}
+
+func (d *dailyCollector) record(ev events.Event) {
+	switch e := ev.(type) {
+	case events.MarketDataEvent:
+		if price, err := strconv.ParseFloat(e.Data.StckPrpr, 64); err == nil {
+			d.lastPrices[e.Symbol] = price
+		}
+	case events.OrderEvent:
+		d.recordOrder(e)
+	case events.ErrorEvent:
+		d.addError(e.Time, e.Source, e.Symbol, e.Err)
+	case events.CircuitEvent:
+		if e.To == "open" {
+			d.addError(e.Time, "circuit", "", fmt.Errorf("order placement suspended: %s", e.Reason))
+		}
+	}
+}
+
+// recordOrder adds a trade and tracks the average cost per symbol, so sells can
+// be attributed a realized PnL. Market orders carry no price, in which case the
+// last observed price is used.
+func (d *dailyCollector) recordOrder(e events.OrderEvent) {
+	order := e.Order
+	price := order.Price
+	if price == 0 {
+		price = d.lastPrices[order.Pair]
+	}
+	side := order.Side
+	if side == "" && e.Signal != nil {
+		side = models.OrderSide(e.Signal.Type)
+	}
+
+	trade := report.Trade{Time: e.Time, Symbol: order.Pair, Side: side, Quantity: order.Amount, Price: price}
+	cost := d.costs[order.Pair]
+	switch side {
+	case models.OrderSideBuy:
+		total := cost.quantity + order.Amount
+		if total > 0 {
+			cost.avgPrice = (cost.quantity*cost.avgPrice + order.Amount*price) / total
+		}
+		cost.quantity = total
+	case models.OrderSideSell:
+		trade.RealizedPnL = (price - cost.avgPrice) * order.Amount
+		d.realized += trade.RealizedPnL
+		cost.quantity -= order.Amount
+	}
+	d.costs[order.Pair] = cost
+	d.trades = append(d.trades, trade)
+}
+
+func (d *dailyCollector) addError(t time.Time, source, symbol string, err error) {
+	if len(d.errors) >= maxReportErrors {
+		d.missedErrors++
+		return
+	}
+	d.errors = append(d.errors, report.Error{Time: t, Source: source, Symbol: symbol, Message: err.Error()})
+}
+
+// build values the account at now and assembles the report for the period.
+func (d *dailyCollector) build(now time.Time, cal *market.Calendar) report.Daily {
+	positions, equity, err := d.valuation()
+	if err != nil {
+		d.addError(now, "report", "", err)
+	}
+
+	unrealized := 0.0
+	for _, p := range positions {
+		unrealized += (p.CurrentPrice - p.AvgPrice) * p.Quantity
+	}
+
+	daily := report.Daily{
+		Date:          now.In(market.KST),
+		Since:         d.since.In(market.KST),
+		Trades:        d.trades,
+		RealizedPnL:   d.realized,
+		UnrealizedPnL: unrealized,
+		StartEquity:   d.startEquity,
+		Equity:        equity,
+		Positions:     positions,
+		Upcoming:      upcoming(now, cal),
+	}
+	if d.benchmark != "" {
+		daily.Benchmark = d.benchmark
+		if price, err := d.price(d.benchmark); err != nil {
+			d.addError(now, "report", d.benchmark, err)
+		} else if d.benchmarkStart > 0 {
+			daily.BenchmarkReturn = price/d.benchmarkStart - 1
+		}
+	}
+	daily.Errors = d.errors
+	daily.MissedErrors = d.missedErrors
+	return daily
+}
+
+func (d *dailyCollector) valuation() ([]models.Position, float64, error) {
+	balance, err := d.account.GetBalance()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get balance: %v", err)
+	}
+	cash, _ := strconv.ParseFloat(balance, 64)
+
+	positions, err := d.account.GetPositions()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get positions: %v", err)
+	}
+	equity := cash
+	for _, p := range positions {
+		equity += p.Quantity * p.CurrentPrice
+	}
+	return positions, equity, nil
+}
+
+func (d *dailyCollector) price(symbol string) (float64, error) {
+	data, err := d.account.GetMarketData(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get price of %s: %v", symbol, err)
+	}
+	return strconv.ParseFloat(data.StckPrpr, 64)
+}
+
+// upcoming lists the next session and any weekday closures or unusual hours in
+// the coming week.
+func upcoming(now time.Time, cal *market.Calendar) []string {
+	var out []string
+	if session, ok := cal.NextSession(now); ok {
+		out = append(out, fmt.Sprintf("Next session: %s", formatSession(session)))
+	}
+
+	today := now.In(market.KST)
+	for i := 1; i <= upcomingDays; i++ {
+		day := time.Date(today.Year(), today.Month(), today.Day()+i, 0, 0, 0, 0, market.KST)
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		session, ok := cal.SessionOn(day)
+		switch {
+		case !ok:
+			out = append(out, fmt.Sprintf("Market closed: %s", day.Format("2006-01-02 (Mon)")))
+		case session.Open.Format("15:04") != "09:00" || session.Close.Format("15:04") != "15:30":
+			out = append(out, fmt.Sprintf("Special hours: %s", formatSession(session)))
+		}
+	}
+	return out
+}
+
+func formatSession(s market.Session) string {
+	return fmt.Sprintf("%s %s–%s KST", s.Open.Format("2006-01-02 (Mon)"), s.Open.Format("15:04"), s.Close.Format("15:04"))
+}
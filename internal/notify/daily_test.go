@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+type fakeAccount struct {
+	cash      string
+	positions []models.Position
+	prices    map[string]string
+}
+
+func (a *fakeAccount) GetBalance() (string, error)              { return a.cash, nil }
+func (a *fakeAccount) GetPositions() ([]models.Position, error) { return a.positions, nil }
+func (a *fakeAccount) GetMarketData(code string) (*models.MarketData, error) {
+	return &models.MarketData{StckPrpr: a.prices[code]}, nil
+}
+
+func TestDailyCollector(t *testing.T) {
+	cal, err := market.NewCalendar(config.MarketConfig{})
+	if err != nil {
+		t.Fatalf("NewCalendar returned error: %v", err)
+	}
+	account := &fakeAccount{
+		cash:      "1000000",
+		positions: []models.Position{{StockCode: "005930", Quantity: 10, AvgPrice: 60000, CurrentPrice: 60000}},
+		prices:    map[string]string{"069500": "30000"},
+	}
+	d := newDailyCollector(account, "069500")
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	d.reset(start)
+
+	d.record(events.MarketDataEvent{Symbol: "005930", Data: &models.MarketData{StckPrpr: "70000"}})
+	d.record(events.OrderEvent{Order: &models.Order{Pair: "005930", Side: models.OrderSideBuy, Amount: 10}, Time: start})
+	d.record(events.OrderEvent{Order: &models.Order{Pair: "005930", Side: models.OrderSideSell, Amount: 5, Price: 72000}, Time: start})
+	d.record(events.ErrorEvent{Source: "execution", Symbol: "005930", Err: errors.New("rejected"), Time: start})
+
+	account.cash = "1010000"
+	account.positions = []models.Position{{StockCode: "005930", Quantity: 15, AvgPrice: 65000, CurrentPrice: 66000}}
+	account.prices["069500"] = "30300"
+	daily := d.build(time.Date(2026, 10, 16, 15, 40, 0, 0, market.KST), cal)
+
+	// Average cost after the buy is (10*60000 + 10*70000) / 20 = 65000.
+	if daily.RealizedPnL != 35000 {
+		t.Errorf("RealizedPnL = %v, want 35000", daily.RealizedPnL)
+	}
+	if daily.UnrealizedPnL != 15000 {
+		t.Errorf("UnrealizedPnL = %v, want 15000", daily.UnrealizedPnL)
+	}
+	if daily.StartEquity != 1600000 || daily.Equity != 2000000 {
+		t.Errorf("equity = %v -> %v, want 1600000 -> 2000000", daily.StartEquity, daily.Equity)
+	}
+	if r := daily.BenchmarkReturn; r < 0.0099 || r > 0.0101 {
+		t.Errorf("BenchmarkReturn = %v, want 0.01", r)
+	}
+	if len(daily.Trades) != 2 || daily.Trades[0].Price != 70000 {
+		t.Errorf("Trades = %+v, want 2 with the buy at the last price 70000", daily.Trades)
+	}
+	if len(daily.Errors) != 1 {
+		t.Errorf("Errors = %+v, want 1", daily.Errors)
+	}
+	if len(daily.Upcoming) == 0 || !strings.HasPrefix(daily.Upcoming[0], "Next session: 2026-10-19") {
+		t.Errorf("Upcoming = %v, want next session on 2026-10-19 first", daily.Upcoming)
+	}
+}
+
+func TestNextReport(t *testing.T) {
+	cal, err := market.NewCalendar(config.MarketConfig{})
+	if err != nil {
+		t.Fatalf("NewCalendar returned error: %v", err)
+	}
+	n := &EmailNotifier{cal: cal, sendDelay: 10 * time.Minute}
+
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 10, 16, 12, 0, 0, 0, market.KST), time.Date(2026, 10, 16, 15, 40, 0, 0, market.KST)},
+		{time.Date(2026, 10, 16, 15, 35, 0, 0, market.KST), time.Date(2026, 10, 16, 15, 40, 0, 0, market.KST)},
+		{time.Date(2026, 10, 16, 15, 41, 0, 0, market.KST), time.Date(2026, 10, 19, 15, 40, 0, 0, market.KST)},
+	}
+	for _, tt := range tests {
+		if got := n.nextReport(tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextReport(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
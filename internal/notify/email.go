@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/report"
+)
+
+var log = logging.New()
+
+const (
+	defaultSMTPPort  = 587
+	defaultSendDelay = 10 * time.Minute
+	eventBuffer      = 256
+)
+
+// EmailNotifier emails the end-of-day report a short while after each session
+// closes.
+type EmailNotifier struct {
+	cfg       config.EmailConfig
+	cal       *market.Calendar
+	events    <-chan events.Event
+	collector *dailyCollector
+	sendDelay time.Duration
+	send      func(subject string, body []byte) error
+	// manual disables the timer; reports are sent on SendNow only.
+	manual  bool
+	trigger chan struct{}
+	outbox  *Outbox
+}
+
+// emailChannel names emailed reports in the outbox.
+const emailChannel = "email"
+
+// NewEmail creates an email notifier and subscribes it to bus. Call Run to start
+// collecting and sending reports.
+func NewEmail(cfg config.EmailConfig, cal *market.Calendar, bus *events.Bus, account Account) *EmailNotifier {
+	n := &EmailNotifier{
+		cfg:       cfg,
+		cal:       cal,
+		events:    bus.Channel(eventBuffer, events.KindMarketData, events.KindOrder, events.KindError, events.KindCircuit),
+		collector: newDailyCollector(account, cfg.Benchmark),
+		sendDelay: defaultSendDelay,
+		trigger:   make(chan struct{}, 1),
+	}
+	if cfg.SendDelay != "" {
+		n.sendDelay, _ = time.ParseDuration(cfg.SendDelay)
+	}
+	n.send = n.sendMail
+	return n
+}
+
+// SendOnDemand stops the notifier from sending a report after every session;
+// it then sends one only when SendNow is called. Call it before Run.
+func (n *EmailNotifier) SendOnDemand() {
+	n.manual = true
+}
+
+// SetOutbox makes Run queue reports in o, which sends them with retries.
+// Call it before Run.
+func (n *EmailNotifier) SetOutbox(o *Outbox) {
+	n.outbox = o
+	o.Register(emailChannel, func(ctx context.Context, msg models.Notification) error {
+		return n.send(msg.Subject, []byte(msg.Body))
+	})
+}
+
+// SendNow makes Run send the report of the events collected since the last
+// one. It does not wait for the report to be sent.
+func (n *EmailNotifier) SendNow() {
+	select {
+	case n.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run collects events and sends a report after every session, or on SendNow,
+// until ctx is done.
+func (n *EmailNotifier) Run(ctx context.Context) {
+	n.collector.reset(time.Now())
+	for {
+		var due <-chan time.Time
+		stop := func() {}
+		if !n.manual {
+			at := n.nextReport(time.Now())
+			log.WithField("at", at).Debug("Next daily report scheduled")
+			timer := time.NewTimer(time.Until(at))
+			due, stop = timer.C, func() { timer.Stop() }
+		}
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				stop()
+				return
+			case ev := <-n.events:
+				n.collector.record(ev)
+			case <-due:
+				break wait
+			case <-n.trigger:
+				stop()
+				break wait
+			}
+		}
+
+		now := time.Now()
+		daily := n.collector.build(now, n.cal)
+		if err := n.deliver(daily); err != nil {
+			log.WithError(err).Error("Failed to send daily report")
+		} else {
+			log.WithField("to", n.cfg.To).Info("Daily report sent")
+		}
+		n.collector.reset(now)
+	}
+}
+
+// nextReport returns when the report for the current or next session is due.
+func (n *EmailNotifier) nextReport(now time.Time) time.Time {
+	session, ok := n.cal.NextSession(now.Add(-n.sendDelay))
+	if !ok {
+		return now.Add(24 * time.Hour)
+	}
+	return session.Close.Add(n.sendDelay)
+}
+
+func (n *EmailNotifier) deliver(daily report.Daily) error {
+	var body bytes.Buffer
+	if err := report.WriteDaily(&body, daily); err != nil {
+		return fmt.Errorf("failed to render report: %v", err)
+	}
+	if n.outbox != nil {
+		err := n.outbox.Enqueue(emailChannel, "daily_report", daily.Subject(), body.Bytes())
+		if err == nil {
+			return nil
+		}
+		log.WithError(err).Warn("Failed to queue daily report, sending directly")
+	}
+	return n.send(daily.Subject(), body.Bytes())
+}
+
+func (n *EmailNotifier) sendMail(subject string, body []byte) error {
+	port := n.cfg.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	msg.Write(body)
+
+	if err := smtp.SendMail(addr, auth, n.cfg.From, n.cfg.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send mail via %s: %v", addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOutboxMaxAttempts = 10
+	defaultOutboxRetryDelay  = 10 * time.Second
+	defaultOutboxMaxDelay    = 30 * time.Minute
+	// outboxPollInterval is how often due retries are looked for; new
+	// notifications are delivered right away.
+	outboxPollInterval = 5 * time.Second
+	outboxBatch        = 100
+)
+
+// OutboxStore keeps notifications until they are delivered, e.g. the
+// database.
+type OutboxStore interface {
+	SaveNotification(n *models.Notification) error
+	PendingNotifications(limit int) ([]models.Notification, error)
+	UpdateNotification(n *models.Notification) error
+}
+
+// Sender makes one delivery attempt of a notification of its channel.
+type Sender func(ctx context.Context, n models.Notification) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// Permanent marks a delivery error that retrying cannot fix, e.g. a rejected
+// request; the notification is dead-lettered at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Outbox delivers notifications through a store; see
+// config.NotifyOutboxConfig. It is safe for concurrent use.
+type Outbox struct {
+	maxAttempts int
+	retryDelay  time.Duration
+	maxDelay    time.Duration
+	clock       clock.Clock
+	wake        chan struct{}
+
+	mu      sync.Mutex
+	store   OutboxStore
+	senders map[string]Sender
+}
+
+// NewOutbox creates an outbox keeping notifications in store. Register the
+// channels' senders and call Run to start delivering.
+func NewOutbox(cfg config.NotifyOutboxConfig, store OutboxStore) *Outbox {
+	o := &Outbox{
+		maxAttempts: cfg.MaxAttempts,
+		retryDelay:  defaultOutboxRetryDelay,
+		maxDelay:    defaultOutboxMaxDelay,
+		clock:       clock.Real,
+		wake:        make(chan struct{}, 1),
+		store:       store,
+		senders:     map[string]Sender{},
+	}
+	if o.maxAttempts == 0 {
+		o.maxAttempts = defaultOutboxMaxAttempts
+	}
+	if cfg.RetryDelay != "" {
+		o.retryDelay, _ = time.ParseDuration(cfg.RetryDelay)
+	}
+	if cfg.MaxDelay != "" {
+		o.maxDelay, _ = time.ParseDuration(cfg.MaxDelay)
+	}
+	return o
+}
+
+// SetClock replaces the clock that decides when retries are due.
+func (o *Outbox) SetClock(c clock.Clock) {
+	o.clock = c
+}
+
+// SetStore replaces the store, e.g. after a database reconnect.
+func (o *Outbox) SetStore(store OutboxStore) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.store = store
+}
+
+// Register sets how notifications of channel are sent. Pending notifications
+// of channels nobody registers, e.g. a webhook removed from the config, are
+// dead-lettered.
+func (o *Outbox) Register(channel string, send Sender) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.senders[channel] = send
+}
+
+// Enqueue stores a notification for channel and wakes Run to deliver it.
+func (o *Outbox) Enqueue(channel, kind, subject string, body []byte) error {
+	now := o.clock.Now()
+	n := &models.Notification{
+		CreatedAt:   now,
+		Channel:     channel,
+		Kind:        kind,
+		Subject:     subject,
+		Body:        string(body),
+		Status:      models.NotificationPending,
+		NextAttempt: now,
+	}
+	o.mu.Lock()
+	store := o.store
+	o.mu.Unlock()
+	if err := store.SaveNotification(n); err != nil {
+		return err
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers pending notifications until ctx is done.
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := o.Flush(ctx); err != nil {
+			log.WithError(err).Warn("Failed to deliver notifications from the outbox")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush makes one pass over the pending notifications, oldest first, and
+// attempts those that are due. A channel whose notification is not delivered
+// is skipped for the rest of the pass, so each channel stays in order.
+func (o *Outbox) Flush(ctx context.Context) error {
+	o.mu.Lock()
+	store := o.store
+	o.mu.Unlock()
+	pending, err := store.PendingNotifications(outboxBatch)
+	if err != nil {
+		return err
+	}
+
+	blocked := map[string]bool{}
+	for i := range pending {
+		n := &pending[i]
+		if blocked[n.Channel] {
+			continue
+		}
+		if o.clock.Now().Before(n.NextAttempt) {
+			blocked[n.Channel] = true
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !o.attempt(ctx, n) {
+			blocked[n.Channel] = true
+		}
+		if err := store.UpdateNotification(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attempt sends n and updates its delivery state. It reports whether later
+// notifications of the channel may go ahead.
+func (o *Outbox) attempt(ctx context.Context, n *models.Notification) bool {
+	o.mu.Lock()
+	send, ok := o.senders[n.Channel]
+	o.mu.Unlock()
+
+	var err error
+	if ok {
+		n.Attempts++
+		err = send(ctx, *n)
+	} else {
+		err = Permanent(errors.New("channel is no longer configured"))
+	}
+	fields := logrus.Fields{"id": n.ID, "channel": n.Channel, "kind": n.Kind, "attempts": n.Attempts}
+	if err == nil {
+		n.Status = models.NotificationDelivered
+		n.LastError = ""
+		return true
+	}
+
+	n.LastError = err.Error()
+	var permanent permanentError
+	if errors.As(err, &permanent) || n.Attempts >= o.maxAttempts {
+		n.Status = models.NotificationDead
+		log.WithError(err).WithFields(fields).Error("Notification dead-lettered")
+		return true
+	}
+	delay := o.retryDelay
+	for i := 1; i < n.Attempts && delay < o.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > o.maxDelay {
+		delay = o.maxDelay
+	}
+	n.NextAttempt = o.clock.Now().Add(delay)
+	log.WithError(err).WithFields(fields).Warnf("Notification delivery failed, retrying in %v", delay)
+	return false
+}
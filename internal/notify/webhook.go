@@ -0,0 +1,358 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWebhookTimeout    = 10 * time.Second
+	defaultWebhookMaxRetries = 3
+	webhookRetryDelay        = time.Second
+
+	// SignatureHeader carries "sha256=<hex>", the HMAC-SHA256 of
+	// "<timestamp>.<body>" keyed with the webhook secret. Receivers should check
+	// it and reject stale timestamps to prevent replays.
+	SignatureHeader = "X-Tradingbot-Signature"
+	TimestampHeader = "X-Tradingbot-Timestamp"
+	EventHeader     = "X-Tradingbot-Event"
+)
+
+// webhookKinds maps the event names accepted in the config to bus event kinds.
+var webhookKinds = map[string]events.Kind{
+	"signal":      events.KindSignal,
+	"order":       events.KindOrder,
+	"fill":        events.KindFill,
+	"error":       events.KindError,
+	"circuit":     events.KindCircuit,
+	"screen":      events.KindScreen,
+	"halt":        events.KindHalt,
+	"degradation": events.KindDegradation,
+	"watchdog":    events.KindWatchdog,
+	"tuning":      events.KindTuning,
+	"kill":        events.KindKill,
+}
+
+// Payload is the JSON body posted to webhooks.
+type Payload struct {
+	Event events.Kind `json:"event"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+type signalData struct {
+	Symbol string        `json:"symbol"`
+	Signal models.Signal `json:"signal"`
+}
+
+type orderData struct {
+	Order  models.Order   `json:"order"`
+	Signal *models.Signal `json:"signal,omitempty"`
+}
+
+type fillData struct {
+	Order    models.Order `json:"order"`
+	Quantity float64      `json:"quantity"`
+	Price    float64      `json:"price"`
+}
+
+type circuitData struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+type screenData struct {
+	Universe string   `json:"universe"`
+	Symbols  []string `json:"symbols"`
+}
+
+type haltData struct {
+	Symbol string        `json:"symbol"`
+	Signal models.Signal `json:"signal"`
+	Check  string        `json:"check"`
+	Detail string        `json:"detail"`
+}
+
+type degradationData struct {
+	Strategy           string  `json:"strategy"`
+	Trades             int     `json:"trades"`
+	WinRate            float64 `json:"win_rate"`
+	Expectancy         float64 `json:"expectancy"`
+	BaselineWinRate    float64 `json:"baseline_win_rate"`
+	BaselineExpectancy float64 `json:"baseline_expectancy"`
+	Reason             string  `json:"reason"`
+	Paused             bool    `json:"paused"`
+}
+
+type watchdogData struct {
+	Component string `json:"component"`
+	Detail    string `json:"detail"`
+	Restarted bool   `json:"restarted"`
+}
+
+type killData struct {
+	Source    string `json:"source"`
+	Reason    string `json:"reason,omitempty"`
+	Cancelled int    `json:"cancelled"`
+	Failed    int    `json:"failed"`
+	Error     string `json:"error,omitempty"`
+}
+
+type tuningData struct {
+	Symbol         string      `json:"symbol"`
+	Days           int         `json:"days"`
+	Current        periodsData `json:"current"`
+	Proposed       periodsData `json:"proposed"`
+	CurrentProfit  float64     `json:"current_profit"`
+	ProposedProfit float64     `json:"proposed_profit"`
+	Applied        bool        `json:"applied"`
+}
+
+type periodsData struct {
+	ShortPeriod int `json:"short_period"`
+	LongPeriod  int `json:"long_period"`
+}
+
+type errorData struct {
+	Source  string `json:"source"`
+	Symbol  string `json:"symbol,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewPayload converts a bus event into its webhook payload.
+func NewPayload(ev events.Event) (Payload, error) {
+	switch e := ev.(type) {
+	case events.SignalEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: signalData{Symbol: e.Symbol, Signal: *e.Signal}}, nil
+	case events.OrderEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: orderData{Order: *e.Order, Signal: e.Signal}}, nil
+	case events.FillEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: fillData{Order: *e.Order, Quantity: e.Quantity, Price: e.Price}}, nil
+	case events.ErrorEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: errorData{Source: e.Source, Symbol: e.Symbol, Message: e.Err.Error()}}, nil
+	case events.CircuitEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: circuitData{From: e.From, To: e.To, Reason: e.Reason}}, nil
+	case events.ScreenEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: screenData{Universe: e.Universe, Symbols: e.Symbols}}, nil
+	case events.HaltEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: haltData{Symbol: e.Symbol, Signal: *e.Signal, Check: e.Check, Detail: e.Detail}}, nil
+	case events.DegradationEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: degradationData{
+			Strategy:           e.Strategy,
+			Trades:             e.Trades,
+			WinRate:            e.WinRate,
+			Expectancy:         e.Expectancy,
+			BaselineWinRate:    e.BaselineWinRate,
+			BaselineExpectancy: e.BaselineExpectancy,
+			Reason:             e.Reason,
+			Paused:             e.Paused,
+		}}, nil
+	case events.WatchdogEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: watchdogData{Component: e.Component, Detail: e.Detail, Restarted: e.Restarted}}, nil
+	case events.TuningEvent:
+		return Payload{Event: e.Kind(), Time: e.Time, Data: tuningData{
+			Symbol:         e.Symbol,
+			Days:           e.Days,
+			Current:        periodsData{ShortPeriod: e.Current.ShortPeriod, LongPeriod: e.Current.LongPeriod},
+			Proposed:       periodsData{ShortPeriod: e.Proposed.ShortPeriod, LongPeriod: e.Proposed.LongPeriod},
+			CurrentProfit:  e.CurrentProfit,
+			ProposedProfit: e.ProposedProfit,
+			Applied:        e.Applied,
+		}}, nil
+	case events.KillEvent:
+		data := killData{Source: e.Source, Reason: e.Reason, Cancelled: e.Cancelled, Failed: e.Failed}
+		if e.Err != nil {
+			data.Error = e.Err.Error()
+		}
+		return Payload{Event: e.Kind(), Time: e.Time, Data: data}, nil
+	default:
+		return Payload{}, fmt.Errorf("unsupported event kind %s", ev.Kind())
+	}
+}
+
+// Sign returns the value of SignatureHeader for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Webhook posts events to a single URL, one at a time and in order, retrying
+// failed deliveries with exponential backoff.
+type Webhook struct {
+	cfg        config.WebhookConfig
+	kinds      []events.Kind
+	events     <-chan events.Event
+	client     *http.Client
+	maxRetries int
+	retryDelay time.Duration
+	outbox     *Outbox
+}
+
+// NewWebhook creates a webhook and subscribes it to the configured events on bus.
+// Call Run to start delivering.
+func NewWebhook(cfg config.WebhookConfig, bus *events.Bus) *Webhook {
+	var kinds []events.Kind
+	for _, name := range cfg.Events {
+		kinds = append(kinds, webhookKinds[name])
+	}
+	if len(kinds) == 0 {
+		kinds = []events.Kind{events.KindSignal, events.KindOrder, events.KindFill, events.KindError, events.KindCircuit, events.KindScreen, events.KindHalt, events.KindDegradation, events.KindWatchdog, events.KindKill}
+	}
+
+	timeout := defaultWebhookTimeout
+	if cfg.Timeout != "" {
+		timeout, _ = time.ParseDuration(cfg.Timeout)
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultWebhookMaxRetries
+	}
+
+	return &Webhook{
+		cfg:        cfg,
+		kinds:      kinds,
+		events:     bus.Channel(eventBuffer, kinds...),
+		client:     &http.Client{Timeout: timeout},
+		maxRetries: maxRetries,
+		retryDelay: webhookRetryDelay,
+	}
+}
+
+// SetOutbox makes Run queue events in o, which delivers them with its own
+// retries instead of max_retries. Call it before Run.
+func (w *Webhook) SetOutbox(o *Outbox) {
+	w.outbox = o
+	o.Register(w.channel(), func(ctx context.Context, n models.Notification) error {
+		retry, err := w.post(ctx, events.Kind(n.Kind), []byte(n.Body))
+		if err != nil && !retry {
+			return Permanent(err)
+		}
+		return err
+	})
+}
+
+// channel names the webhook's notifications in the outbox.
+func (w *Webhook) channel() string {
+	return "webhook " + w.cfg.URL
+}
+
+// Run delivers events until ctx is done.
+func (w *Webhook) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-w.events:
+			if w.outbox != nil {
+				err := w.enqueue(ev)
+				if err == nil {
+					continue
+				}
+				log.WithError(err).WithField("url", w.cfg.URL).Warn("Failed to queue webhook, delivering directly")
+			}
+			if err := w.deliver(ctx, ev); err != nil {
+				log.WithError(err).WithFields(logrus.Fields{"url": w.cfg.URL, "event": ev.Kind()}).Error("Failed to deliver webhook")
+			}
+		}
+	}
+}
+
+func (w *Webhook) enqueue(ev events.Event) error {
+	payload, err := NewPayload(ev)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	return w.outbox.Enqueue(w.channel(), string(payload.Event), "", body)
+}
+
+// Send delivers ev right away if the webhook is configured for its kind, for
+// one-shot commands that exit before Run would get to it.
+func (w *Webhook) Send(ctx context.Context, ev events.Event) error {
+	for _, kind := range w.kinds {
+		if kind == ev.Kind() {
+			return w.deliver(ctx, ev)
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) deliver(ctx context.Context, ev events.Event) error {
+	payload, err := NewPayload(ev)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+
+	delay := w.retryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, payload.Event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.maxRetries {
+			return err
+		}
+		log.WithError(err).WithField("url", w.cfg.URL).Warnf("Webhook delivery failed, retrying in %v...", delay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post sends one delivery attempt and reports whether a failure is worth retrying.
+func (w *Webhook) post(ctx context.Context, kind events.Kind, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %v", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(kind))
+	req.Header.Set(TimestampHeader, timestamp)
+	if w.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post webhook: %v", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook rejected delivery with status %d", resp.StatusCode)
+	}
+}
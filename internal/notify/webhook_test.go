@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+func TestWebhookSignsAndRetries(t *testing.T) {
+	const secret = "webhook-secret"
+	attempts := 0
+	received := make(chan Payload, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := ioutil.ReadAll(r.Body)
+		if got, want := r.Header.Get(SignatureHeader), Sign(secret, r.Header.Get(TimestampHeader), body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		received <- p
+	}))
+	defer srv.Close()
+
+	bus := events.NewBus()
+	hook := NewWebhook(config.WebhookConfig{URL: srv.URL, Secret: secret, Events: []string{"error"}}, bus)
+	hook.retryDelay = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hook.Run(ctx)
+
+	bus.Publish(events.SignalEvent{Symbol: "005930"}) // not subscribed
+	bus.Publish(events.ErrorEvent{Source: "execution", Symbol: "005930", Err: errors.New("rejected"), Time: time.Now()})
+
+	select {
+	case p := <-received:
+		if p.Event != events.KindError {
+			t.Errorf("event = %s, want error", p.Event)
+		}
+		data := p.Data.(map[string]interface{})
+		if data["message"] != "rejected" || data["symbol"] != "005930" {
+			t.Errorf("data = %v", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	hook := NewWebhook(config.WebhookConfig{URL: srv.URL}, events.NewBus())
+	hook.retryDelay = time.Millisecond
+
+	err := hook.deliver(context.Background(), events.ErrorEvent{Source: "store", Err: errors.New("down")})
+	if err == nil {
+		t.Fatal("deliver succeeded, want error")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
+
+// memoryOutbox is an OutboxStore in memory.
+type memoryOutbox struct {
+	notifications []models.Notification
+}
+
+func (m *memoryOutbox) SaveNotification(n *models.Notification) error {
+	n.ID = int64(len(m.notifications) + 1)
+	m.notifications = append(m.notifications, *n)
+	return nil
+}
+
+func (m *memoryOutbox) PendingNotifications(limit int) ([]models.Notification, error) {
+	var out []models.Notification
+	for _, n := range m.notifications {
+		if n.Status == models.NotificationPending && len(out) < limit {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryOutbox) UpdateNotification(n *models.Notification) error {
+	m.notifications[n.ID-1] = *n
+	return nil
+}
+
+func TestOutboxRetriesInOrderAndDeadLetters(t *testing.T) {
+	var delivered []string
+	down := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p struct {
+			Data struct{ Message string }
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &p)
+		switch {
+		case down:
+			w.WriteHeader(http.StatusBadGateway)
+		case p.Data.Message == "malformed":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			delivered = append(delivered, p.Data.Message)
+		}
+	}))
+	defer srv.Close()
+
+	store := &memoryOutbox{}
+	c := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	outbox := NewOutbox(config.NotifyOutboxConfig{Enabled: true, RetryDelay: "10s"}, store)
+	outbox.SetClock(c)
+	hook := NewWebhook(config.WebhookConfig{URL: srv.URL}, events.NewBus())
+	hook.SetOutbox(outbox)
+
+	for _, msg := range []string{"first", "second"} {
+		if err := hook.enqueue(events.ErrorEvent{Source: "store", Err: errors.New(msg)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	outbox.Flush(ctx)
+	if n := store.notifications[0]; n.Attempts != 1 || !n.NextAttempt.Equal(c.Now().Add(10*time.Second)) {
+		t.Fatalf("first = %+v, want a retry in 10s", n)
+	}
+	if n := store.notifications[1]; n.Attempts != 0 {
+		t.Fatalf("second attempted %d times while the first waits", n.Attempts)
+	}
+
+	down = false
+	outbox.Flush(ctx)
+	if len(delivered) != 0 {
+		t.Fatalf("delivered %v before the retry was due", delivered)
+	}
+	c.Advance(10 * time.Second)
+	outbox.Flush(ctx)
+	if len(delivered) != 2 || delivered[0] != "first" || delivered[1] != "second" {
+		t.Fatalf("delivered %v, want [first second]", delivered)
+	}
+
+	hook.enqueue(events.ErrorEvent{Source: "store", Err: errors.New("malformed")})
+	outbox.Enqueue("webhook https://removed.example.com", "error", "", []byte("{}"))
+	outbox.Flush(ctx)
+	for _, n := range store.notifications[2:] {
+		if n.Status != models.NotificationDead || n.LastError == "" {
+			t.Errorf("notification %+v, want dead-lettered", n)
+		}
+	}
+}
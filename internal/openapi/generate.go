@@ -0,0 +1,284 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// initialisms are spelt in upper case in Go names, as golint wants.
+var initialisms = map[string]string{"id": "ID", "poc": "POC", "pnl": "PnL", "url": "URL", "vwap": "VWAP"}
+
+// Generate returns the source of a Go file in package pkg with a type for
+// every component schema and a method on Client for every operation with a
+// JSON response. Client, with its do method, is expected to be written by
+// hand in the same package:
+//
+//	func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error
+func (d *Document) Generate(pkg, source string) ([]byte, error) {
+	g := &generator{doc: d, imports: map[string]bool{}}
+
+	names := make([]string, 0, len(d.Components.Schemas))
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := g.writeType(name, d.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %v", name, err)
+		}
+	}
+	for _, op := range d.Operations() {
+		if err := g.writeMethod(op); err != nil {
+			return nil, fmt.Errorf("%s %s: %v", op.Method, op.Path, err)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for imp := range g.imports {
+			imports = append(imports, imp)
+		}
+		sort.Strings(imports)
+		out.WriteString("import (\n")
+		for _, imp := range imports {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+		out.WriteString(")\n")
+	}
+	out.Write(g.body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+type generator struct {
+	doc     *Document
+	imports map[string]bool
+	body    bytes.Buffer
+}
+
+func (g *generator) writeType(name string, s *Schema) error {
+	if s.Type != "object" || len(s.Properties) == 0 {
+		return fmt.Errorf("only objects with properties are supported, not %q", s.Type)
+	}
+	g.comment(name, s.Description)
+	fmt.Fprintf(&g.body, "type %s struct {\n", name)
+
+	props := make([]string, 0, len(s.Properties))
+	for prop := range s.Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	for _, prop := range props {
+		ps := s.Properties[prop]
+		required := contains(s.Required, prop)
+		typ, err := g.goType(ps, required)
+		if err != nil {
+			return fmt.Errorf("property %s: %v", prop, err)
+		}
+		tag := prop
+		if !required {
+			tag += ",omitempty"
+		}
+		doc := ps.Description
+		if len(ps.Enum) > 0 {
+			doc = strings.TrimSpace(doc + " One of " + strings.Join(ps.Enum, ", ") + ".")
+		}
+		if doc != "" {
+			fmt.Fprintf(&g.body, "\t// %s\n", doc)
+		}
+		fmt.Fprintf(&g.body, "\t%s %s `json:%q`\n", goName(prop), typ, tag)
+	}
+	g.body.WriteString("}\n\n")
+	return nil
+}
+
+func (g *generator) writeMethod(op *Operation) error {
+	respSchema := g.doc.JSONResponse(op, "200")
+	if respSchema == nil {
+		return nil
+	}
+	respType, err := g.goType(respSchema, true)
+	if err != nil {
+		return fmt.Errorf("response: %v", err)
+	}
+	byPointer := respSchema.Ref != ""
+
+	params := []string{"ctx context.Context"}
+	g.imports["context"] = true
+	var query []Parameter
+	for _, p := range op.Parameters {
+		if p.In != "query" || p.Schema == nil || p.Schema.Type != "string" {
+			return fmt.Errorf("parameter %s: only string query parameters are supported", p.Name)
+		}
+		query = append(query, p)
+		params = append(params, lowerFirst(goName(p.Name))+" string")
+	}
+	body := op.JSONBody()
+	if body != nil {
+		if body.Ref == "" {
+			return fmt.Errorf("request body: only references to component schemas are supported")
+		}
+		typ, err := g.goType(body, false)
+		if err != nil {
+			return fmt.Errorf("request body: %v", err)
+		}
+		params = append(params, "req "+typ)
+	}
+
+	summary := op.Summary
+	if summary == "" {
+		summary = "Calls " + op.Path + "."
+	}
+	g.comment(op.OperationID, summary)
+	role := op.Role
+	if role == "" {
+		role = "none"
+	}
+	fmt.Fprintf(&g.body, "//\n// %s %s, role %s.\n", op.Method, op.Path, role)
+	for _, p := range query {
+		if p.Description != "" {
+			fmt.Fprintf(&g.body, "// %s: %s\n", lowerFirst(goName(p.Name)), p.Description)
+		}
+	}
+
+	result := respType
+	if byPointer {
+		result = "*" + respType
+	}
+	fmt.Fprintf(&g.body, "func (c *Client) %s(%s) (%s, error) {\n", op.OperationID, strings.Join(params, ", "), result)
+
+	queryArg := "nil"
+	if len(query) > 0 {
+		g.imports["net/url"] = true
+		queryArg = "query"
+		g.body.WriteString("\tquery := url.Values{}\n")
+		for _, p := range query {
+			arg := lowerFirst(goName(p.Name))
+			fmt.Fprintf(&g.body, "\tif %s != \"\" {\n\t\tquery.Set(%q, %s)\n\t}\n", arg, p.Name, arg)
+		}
+	}
+	bodyArg := "nil"
+	if body != nil {
+		bodyArg = "body"
+		g.body.WriteString("\tvar body interface{}\n\tif req != nil {\n\t\tbody = req\n\t}\n")
+	}
+	fmt.Fprintf(&g.body, "\tvar out %s\n", respType)
+	fmt.Fprintf(&g.body, "\tif err := c.do(ctx, %q, %q, %s, %s, &out); err != nil {\n", op.Method, op.Path, queryArg, bodyArg)
+	if byPointer {
+		g.body.WriteString("\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n\n")
+	} else {
+		g.body.WriteString("\t\treturn nil, err\n\t}\n\treturn out, nil\n}\n\n")
+	}
+	return nil
+}
+
+// goType returns the Go type of values of s. Optional structs and times are
+// pointers so that they can be left out.
+func (g *generator) goType(s *Schema, required bool) (string, error) {
+	if s.Ref != "" {
+		if _, err := g.doc.Resolve(s); err != nil {
+			return "", err
+		}
+		name := strings.TrimPrefix(s.Ref, schemaRefPrefix)
+		if !required {
+			return "*" + name, nil
+		}
+		return name, nil
+	}
+	if len(s.OneOf) > 0 {
+		g.imports["encoding/json"] = true
+		return "json.RawMessage", nil
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			if !required {
+				return "*time.Time", nil
+			}
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "number":
+		return "float64", nil
+	case "integer":
+		return "int", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(s.Items, true)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if s.AdditionalProperties != nil {
+			value, err := g.goType(s.AdditionalProperties, true)
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + value, nil
+		}
+		if len(s.Properties) == 0 {
+			g.imports["encoding/json"] = true
+			return "json.RawMessage", nil
+		}
+		return "", fmt.Errorf("inline objects are not supported; move it to the component schemas")
+	}
+	return "", fmt.Errorf("unsupported schema type %q", s.Type)
+}
+
+// comment writes a doc comment for name from a description, which is
+// either a sentence about name or starts with a verb, e.g. "Lists ...".
+func (g *generator) comment(name, description string) {
+	if description == "" {
+		return
+	}
+	text := strings.TrimSpace(description)
+	if !strings.HasPrefix(text, name+" ") {
+		text = name + " " + lowerFirst(text)
+	}
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(&g.body, "// %s\n", line)
+	}
+}
+
+// goName converts a snake_case JSON name into an exported Go name.
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		if init, ok := initialisms[part]; ok {
+			b.WriteString(init)
+			continue
+		}
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	// Leave initialisms such as "PnL" or "VWAP" alone.
+	if len(r) > 1 && unicode.IsUpper(r[1]) {
+		return s
+	}
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
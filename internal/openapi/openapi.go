@@ -0,0 +1,216 @@
+// Package openapi reads the subset of OpenAPI 3 used to describe the bot's
+// API, checks JSON documents against its schemas and generates a Go client
+// from it.
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	schemaRefPrefix   = "#/components/schemas/"
+	responseRefPrefix = "#/components/responses/"
+)
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                           `yaml:"openapi"`
+	Paths      map[string]map[string]*Operation `yaml:"paths"`
+	Components Components                       `yaml:"components"`
+}
+
+// Components holds the schemas and responses referred to by the paths.
+type Components struct {
+	Schemas   map[string]*Schema   `yaml:"schemas"`
+	Responses map[string]*Response `yaml:"responses"`
+}
+
+// Operation is one method on a path. Role is the x-role extension, the least
+// API role allowed to call it.
+type Operation struct {
+	OperationID string               `yaml:"operationId"`
+	Summary     string               `yaml:"summary"`
+	Description string               `yaml:"description"`
+	Role        string               `yaml:"x-role"`
+	Parameters  []Parameter          `yaml:"parameters"`
+	RequestBody *RequestBody         `yaml:"requestBody"`
+	Responses   map[string]*Response `yaml:"responses"`
+
+	// Method and Path are filled in by Load.
+	Method string `yaml:"-"`
+	Path   string `yaml:"-"`
+}
+
+// Parameter is a query or path parameter.
+type Parameter struct {
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description"`
+	Required    bool    `yaml:"required"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+// RequestBody is the body an operation takes.
+type RequestBody struct {
+	Required bool                  `yaml:"required"`
+	Content  map[string]*MediaType `yaml:"content"`
+}
+
+// Response is a response of an operation, or a reference to one.
+type Response struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+// MediaType gives the schema of a body in one content type.
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Schema is a JSON schema, or a reference to one of the components.
+type Schema struct {
+	Ref                  string             `yaml:"$ref"`
+	Type                 string             `yaml:"type"`
+	Format               string             `yaml:"format"`
+	Description          string             `yaml:"description"`
+	Properties           map[string]*Schema `yaml:"properties"`
+	Required             []string           `yaml:"required"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties"`
+	Items                *Schema            `yaml:"items"`
+	OneOf                []*Schema          `yaml:"oneOf"`
+	Enum                 []string           `yaml:"enum"`
+}
+
+// Load parses an OpenAPI document and checks that its references resolve.
+func Load(data []byte) (*Document, error) {
+	var doc Document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", doc.OpenAPI)
+	}
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			op.Method = strings.ToUpper(method)
+			op.Path = path
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("%s %s: operationId is missing", op.Method, path)
+			}
+			if err := doc.checkRefs(op.JSONBody()); err != nil {
+				return nil, fmt.Errorf("%s %s: request body: %v", op.Method, path, err)
+			}
+			for code, resp := range op.Responses {
+				if _, err := doc.Response(resp); err != nil {
+					return nil, fmt.Errorf("%s %s: response %s: %v", op.Method, path, code, err)
+				}
+				if err := doc.checkRefs(doc.JSONResponse(op, code)); err != nil {
+					return nil, fmt.Errorf("%s %s: response %s: %v", op.Method, path, code, err)
+				}
+			}
+		}
+	}
+	for name, s := range doc.Components.Schemas {
+		if err := doc.checkRefs(s); err != nil {
+			return nil, fmt.Errorf("schema %s: %v", name, err)
+		}
+	}
+	return &doc, nil
+}
+
+func (d *Document) checkRefs(s *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		_, err := d.Resolve(s)
+		return err
+	}
+	children := append([]*Schema{s.Items, s.AdditionalProperties}, s.OneOf...)
+	for _, p := range s.Properties {
+		children = append(children, p)
+	}
+	for _, c := range children {
+		if err := d.checkRefs(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Operations returns the operations sorted by path and method.
+func (d *Document) Operations() []*Operation {
+	var ops []*Operation
+	for _, methods := range d.Paths {
+		for _, op := range methods {
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops
+}
+
+// Resolve follows s if it refers to a component schema.
+func (d *Document) Resolve(s *Schema) (*Schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+	name := strings.TrimPrefix(s.Ref, schemaRefPrefix)
+	target, ok := d.Components.Schemas[name]
+	if !ok || name == s.Ref {
+		return nil, fmt.Errorf("unknown schema %q", s.Ref)
+	}
+	return target, nil
+}
+
+// Response follows r if it refers to a component response.
+func (d *Document) Response(r *Response) (*Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+	name := strings.TrimPrefix(r.Ref, responseRefPrefix)
+	target, ok := d.Components.Responses[name]
+	if !ok || name == r.Ref {
+		return nil, fmt.Errorf("unknown response %q", r.Ref)
+	}
+	return target, nil
+}
+
+// JSONResponse returns the schema of the JSON body of op's response with the
+// given status code, or nil if it has none.
+func (d *Document) JSONResponse(op *Operation, code string) *Schema {
+	resp, ok := op.Responses[code]
+	if !ok {
+		return nil
+	}
+	resp, err := d.Response(resp)
+	if err != nil {
+		return nil
+	}
+	if mt := resp.Content["application/json"]; mt != nil {
+		return mt.Schema
+	}
+	return nil
+}
+
+// JSONBody returns the schema of op's JSON request body, or nil if it takes
+// none.
+func (op *Operation) JSONBody() *Schema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	if mt := op.RequestBody.Content["application/json"]; mt != nil {
+		return mt.Schema
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package openapi
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSpec = `
+openapi: 3.0.3
+paths:
+  /items:
+    get:
+      operationId: ListItems
+      summary: Lists the items.
+      x-role: read
+      parameters:
+        - name: kind
+          in: query
+          schema:
+            type: string
+      responses:
+        "200":
+          description: Items.
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Item"
+components:
+  schemas:
+    Item:
+      description: Item is a thing.
+      type: object
+      required: [id, created]
+      properties:
+        id:
+          type: integer
+        created:
+          type: string
+          format: date-time
+        color:
+          type: string
+          enum: [red, blue]
+`
+
+func TestLoadRejectsUnknownReferences(t *testing.T) {
+	bad := strings.Replace(testSpec, "schemas/Item", "schemas/Thing", 1)
+	if _, err := Load([]byte(bad)); err == nil || !strings.Contains(err.Error(), "Thing") {
+		t.Errorf("Load = %v, want an unknown schema error", err)
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	doc, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := doc.JSONResponse(doc.Paths["/items"]["get"], "200")
+
+	tests := []struct {
+		body string
+		ok   bool
+	}{
+		{`[{"id": 1, "created": "2024-01-02T09:00:00+09:00", "color": "red"}]`, true},
+		{`[]`, true},
+		{`null`, false},
+		{`[{"id": 1.5, "created": "2024-01-02T09:00:00+09:00"}]`, false},
+		{`[{"id": 1}]`, false},
+		{`[{"id": 1, "created": "yesterday"}]`, false},
+		{`[{"id": 1, "created": "2024-01-02T09:00:00Z", "color": "green"}]`, false},
+		{`[{"id": 1, "created": "2024-01-02T09:00:00Z", "size": 3}]`, false},
+	}
+	for _, tt := range tests {
+		if err := doc.ValidateJSON(schema, []byte(tt.body)); (err == nil) != tt.ok {
+			t.Errorf("ValidateJSON(%s) = %v, want ok %v", tt.body, err, tt.ok)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	doc, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := doc.Generate("client", "items.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// Code generated from items.yaml; DO NOT EDIT.",
+		"// Item is a thing.\ntype Item struct {",
+		"// One of red, blue.\n\tColor   string    `json:\"color,omitempty\"`",
+		"Created time.Time `json:\"created\"`",
+		"ID      int       `json:\"id\"`",
+		"func (c *Client) ListItems(ctx context.Context, kind string) ([]Item, error) {",
+		`query.Set("kind", kind)`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code lacks %q:\n%s", want, src)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+)
+
+// Fold is a held-out period. Its first Warmup bars belong to the period before
+// it and only prime the strategy.
+type Fold struct {
+	Data   []models.MarketData
+	Warmup int
+}
+
+// HoldOut splits data, oldest first, into the in-sample bars to search on and
+// the last fraction of it as folds consecutive held-out periods, each preceded
+// by up to warmup bars before it.
+func HoldOut(data []models.MarketData, fraction float64, folds, warmup int) ([]models.MarketData, []Fold, error) {
+	if fraction <= 0 || fraction >= 1 {
+		return nil, nil, fmt.Errorf("held-out fraction must be in (0, 1), got %v", fraction)
+	}
+	if folds <= 0 {
+		return nil, nil, fmt.Errorf("number of folds must be positive, got %d", folds)
+	}
+	split := len(data) - int(float64(len(data))*fraction)
+	size := (len(data) - split) / folds
+	if size < 2 {
+		return nil, nil, fmt.Errorf("%d bars held out of %d are too few for %d folds", len(data)-split, len(data), folds)
+	}
+
+	held := make([]Fold, folds)
+	for i := range held {
+		start := split + i*size
+		end := start + size
+		if i == folds-1 {
+			end = len(data)
+		}
+		w := warmup
+		if w > start {
+			w = start
+		}
+		held[i] = Fold{Data: data[start-w : end], Warmup: w}
+	}
+	return data[:split], held, nil
+}
+
+// Stability is how a parameter set chosen on the in-sample bars did on the
+// held-out folds. Sharpe ratios are compared because they do not depend on the
+// length of a period.
+type Stability struct {
+	InSampleSharpe float64
+	// Folds are the backtests of the held-out periods, oldest first.
+	Folds []backtesting.BacktestResult
+	// MeanSharpe and SharpeStdDev are the mean and standard deviation of the
+	// folds' Sharpe ratios, and WorstProfit the lowest profit of a fold.
+	MeanSharpe   float64
+	SharpeStdDev float64
+	WorstProfit  float64
+	// Overfit is set when the set did well in sample but its mean held-out
+	// Sharpe ratio fell below the collapse fraction of the in-sample one.
+	Overfit bool
+}
+
+// CrossValidate backtests the named strategy with params on every fold and
+// compares the outcome with the in-sample backtest.
+func CrossValidate(name string, params config.StrategyParams, inSample backtesting.BacktestResult, folds []Fold, initialBalance, commissionRate, collapse float64) (Stability, error) {
+	s := Stability{InSampleSharpe: inSample.SharpeRatio, WorstProfit: math.Inf(1)}
+	var sum, sumSquares float64
+	for _, fold := range folds {
+		result, err := backtestStrategy(name, params, fold.Data, fold.Warmup, initialBalance, commissionRate)
+		if err != nil {
+			return Stability{}, err
+		}
+		s.Folds = append(s.Folds, result)
+		sum += result.SharpeRatio
+		sumSquares += result.SharpeRatio * result.SharpeRatio
+		s.WorstProfit = math.Min(s.WorstProfit, result.TotalProfit)
+	}
+	if n := float64(len(folds)); n > 0 {
+		s.MeanSharpe = sum / n
+		if n > 1 {
+			s.SharpeStdDev = math.Sqrt(math.Max(0, (sumSquares-n*s.MeanSharpe*s.MeanSharpe)/(n-1)))
+		}
+	} else {
+		s.WorstProfit = 0
+	}
+	s.Overfit = s.InSampleSharpe > 0 && s.MeanSharpe < collapse*s.InSampleSharpe
+	return s, nil
+}
@@ -0,0 +1,280 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+	"tradingbot/internal/strategy"
+)
+
+// Param is a strategy parameter searched by the genetic optimizer, from Min to
+// Max; Integer parameters take whole values only.
+type Param struct {
+	Name     string
+	Min, Max float64
+	Integer  bool
+}
+
+// ParseParam parses "name=min:max". The parameter is an integer unless min or
+// max has a decimal point, e.g. "short_period=2:30" or "threshold=0:0.02".
+func ParseParam(s string) (Param, error) {
+	eq := strings.Index(s, "=")
+	if eq <= 0 {
+		return Param{}, fmt.Errorf("invalid parameter %q, want name=min:max", s)
+	}
+	bounds := strings.Split(s[eq+1:], ":")
+	if len(bounds) != 2 {
+		return Param{}, fmt.Errorf("invalid parameter %q, want name=min:max", s)
+	}
+	p := Param{Name: s[:eq], Integer: !strings.Contains(s[eq+1:], ".")}
+	var err1, err2 error
+	p.Min, err1 = strconv.ParseFloat(bounds[0], 64)
+	p.Max, err2 = strconv.ParseFloat(bounds[1], 64)
+	if err1 != nil || err2 != nil || p.Max < p.Min {
+		return Param{}, fmt.Errorf("invalid range in %q, want min <= max", s)
+	}
+	return p, nil
+}
+
+// Fitness scores a backtest of bars daily bars started with initialBalance;
+// higher is better.
+type Fitness func(r backtesting.BacktestResult, initialBalance float64, bars int) float64
+
+// minDrawdown keeps the Calmar ratio of runs without a drawdown finite.
+const minDrawdown = 0.01
+
+// FitnessSharpe scores runs by their Sharpe ratio.
+func FitnessSharpe(r backtesting.BacktestResult, initialBalance float64, bars int) float64 {
+	return r.SharpeRatio
+}
+
+// FitnessCalmar scores runs by their annualized return over their maximum
+// drawdown.
+func FitnessCalmar(r backtesting.BacktestResult, initialBalance float64, bars int) float64 {
+	if bars == 0 || initialBalance <= 0 {
+		return 0
+	}
+	growth := 1 + r.TotalProfit/initialBalance
+	if growth <= 0 {
+		return -1 / math.Max(r.MaxDrawdown, minDrawdown)
+	}
+	annual := math.Pow(growth, 252/float64(bars)) - 1
+	return annual / math.Max(r.MaxDrawdown, minDrawdown)
+}
+
+// FitnessProfit scores runs by their return less penalty times their maximum
+// drawdown, e.g. a 10% return with a 4% drawdown scores 0.02 with penalty 2.
+func FitnessProfit(penalty float64) Fitness {
+	return func(r backtesting.BacktestResult, initialBalance float64, bars int) float64 {
+		if initialBalance <= 0 {
+			return 0
+		}
+		return r.TotalProfit/initialBalance - penalty*r.MaxDrawdown
+	}
+}
+
+// GeneticConfig configures Genetic. Zero values take the defaults.
+type GeneticConfig struct {
+	// Population is the number of parameter sets per generation (default 40).
+	Population int
+	// Generations is the most generations bred (default 30).
+	Generations int
+	// Patience stops the search after that many generations without a better
+	// best set (default 5); negative never stops early.
+	Patience int
+	// MutationRate is the chance of each parameter of a child being mutated
+	// (default 0.2).
+	MutationRate float64
+	// Elite is the number of best sets carried over unchanged (default 2).
+	Elite int
+	// Seed seeds the search, making it repeatable; zero uses 1.
+	Seed int64
+}
+
+const (
+	defaultPopulation   = 40
+	defaultGenerations  = 30
+	defaultPatience     = 5
+	defaultMutationRate = 0.2
+	defaultElite        = 2
+	tournamentSize      = 3
+	// mutationScale is the standard deviation of a mutation as a fraction of
+	// the parameter's range.
+	mutationScale = 0.1
+)
+
+// Candidate is an evaluated parameter set. Sets the strategy rejects, such as
+// a short period not below the long one, score negative infinity.
+type Candidate struct {
+	Params   config.StrategyParams
+	Fitness  float64
+	Backtest backtesting.BacktestResult
+}
+
+// GeneticResult is the outcome of a genetic search: every evaluated set, best
+// first, and the number of generations bred.
+type GeneticResult struct {
+	Candidates  []Candidate
+	Generations int
+}
+
+// Genetic searches space with a genetic algorithm: tournament selection,
+// uniform crossover, Gaussian mutation and elitism, scoring every set by
+// fitness of its evaluation. Sets are evaluated once; evaluate returns an
+// error for invalid ones.
+func Genetic(cfg GeneticConfig, space []Param, evaluate func(config.StrategyParams) (backtesting.BacktestResult, error), fitness Fitness, initialBalance float64, bars int) GeneticResult {
+	if cfg.Population <= 0 {
+		cfg.Population = defaultPopulation
+	}
+	if cfg.Generations <= 0 {
+		cfg.Generations = defaultGenerations
+	}
+	if cfg.Patience == 0 {
+		cfg.Patience = defaultPatience
+	}
+	if cfg.MutationRate <= 0 {
+		cfg.MutationRate = defaultMutationRate
+	}
+	if cfg.Elite <= 0 {
+		cfg.Elite = defaultElite
+	}
+	if cfg.Elite > cfg.Population {
+		cfg.Elite = cfg.Population
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = 1
+	}
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	seen := map[string]*Candidate{}
+	score := func(genes []float64) *Candidate {
+		key := fmt.Sprint(genes)
+		if c, ok := seen[key]; ok {
+			return c
+		}
+		c := &Candidate{Params: make(config.StrategyParams, len(space)), Fitness: math.Inf(-1)}
+		for i, p := range space {
+			if p.Integer {
+				c.Params[p.Name] = int(genes[i])
+			} else {
+				c.Params[p.Name] = genes[i]
+			}
+		}
+		if result, err := evaluate(c.Params); err == nil {
+			c.Backtest = result
+			c.Fitness = fitness(result, initialBalance, bars)
+		}
+		seen[key] = c
+		return c
+	}
+
+	type member struct {
+		genes []float64
+		c     *Candidate
+	}
+	population := make([]member, cfg.Population)
+	for i := range population {
+		genes := make([]float64, len(space))
+		for j, p := range space {
+			genes[j] = p.clamp(p.Min + rng.Float64()*(p.Max-p.Min))
+		}
+		population[i] = member{genes, score(genes)}
+	}
+
+	best, stall, generation := math.Inf(-1), 0, 0
+	for generation = 1; ; generation++ {
+		sort.SliceStable(population, func(i, j int) bool { return population[i].c.Fitness > population[j].c.Fitness })
+		if f := population[0].c.Fitness; f > best {
+			best, stall = f, 0
+		} else {
+			stall++
+		}
+		if generation >= cfg.Generations || cfg.Patience > 0 && stall >= cfg.Patience {
+			break
+		}
+
+		tournament := func() []float64 {
+			winner := population[rng.Intn(len(population))]
+			for i := 1; i < tournamentSize; i++ {
+				if m := population[rng.Intn(len(population))]; m.c.Fitness > winner.c.Fitness {
+					winner = m
+				}
+			}
+			return winner.genes
+		}
+		next := append([]member(nil), population[:cfg.Elite]...)
+		for len(next) < cfg.Population {
+			a, b := tournament(), tournament()
+			child := make([]float64, len(space))
+			for j, p := range space {
+				child[j] = a[j]
+				if rng.Intn(2) == 1 {
+					child[j] = b[j]
+				}
+				if rng.Float64() < cfg.MutationRate {
+					child[j] += rng.NormFloat64() * mutationScale * (p.Max - p.Min)
+				}
+				child[j] = p.clamp(child[j])
+			}
+			next = append(next, member{child, score(child)})
+		}
+		population = next
+	}
+
+	candidates := make([]Candidate, 0, len(seen))
+	for _, c := range seen {
+		candidates = append(candidates, *c)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Fitness != candidates[j].Fitness {
+			return candidates[i].Fitness > candidates[j].Fitness
+		}
+		return fmt.Sprint(candidates[i].Params) < fmt.Sprint(candidates[j].Params)
+	})
+	return GeneticResult{Candidates: candidates, Generations: generation}
+}
+
+// clamp keeps v within the range, rounded for integer parameters.
+func (p Param) clamp(v float64) float64 {
+	v = math.Max(p.Min, math.Min(p.Max, v))
+	if p.Integer {
+		v = math.Round(v)
+	}
+	return v
+}
+
+// BacktestStrategy returns an evaluation for Genetic backtesting the named
+// strategy on data with base overridden by the searched parameters.
+func BacktestStrategy(name string, base config.StrategyParams, data []models.MarketData, initialBalance, commissionRate float64) func(config.StrategyParams) (backtesting.BacktestResult, error) {
+	return func(searched config.StrategyParams) (backtesting.BacktestResult, error) {
+		params := make(config.StrategyParams, len(base)+len(searched))
+		for k, v := range base {
+			params[k] = v
+		}
+		for k, v := range searched {
+			params[k] = v
+		}
+		return backtestStrategy(name, params, data, 0, initialBalance, commissionRate)
+	}
+}
+
+// backtestStrategy backtests the named strategy with params on data, the first
+// warmup bars of which only prime it.
+func backtestStrategy(name string, params config.StrategyParams, data []models.MarketData, warmup int, initialBalance, commissionRate float64) (backtesting.BacktestResult, error) {
+	if err := config.ValidateStrategyParams(name, params); err != nil {
+		return backtesting.BacktestResult{}, err
+	}
+	strat, err := strategy.New(name, params)
+	if err != nil {
+		return backtesting.BacktestResult{}, err
+	}
+	b := backtesting.NewBacktester(strat, data, initialBalance, commissionRate)
+	b.Warmup = warmup
+	return b.Run(), nil
+}
@@ -0,0 +1,59 @@
+package optimizer
+
+import (
+	"sort"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/models"
+	"tradingbot/internal/strategy"
+)
+
+// Range is an inclusive integer parameter range scanned with the given step.
+type Range struct {
+	Min  int
+	Max  int
+	Step int
+}
+
+func (r Range) values() []int {
+	step := r.Step
+	if step <= 0 {
+		step = 1
+	}
+	var values []int
+	for v := r.Min; v <= r.Max; v += step {
+		values = append(values, v)
+	}
+	return values
+}
+
+// Result is the backtest outcome of one parameter set.
+type Result struct {
+	Params   models.MovingAverageConfig
+	Backtest backtesting.BacktestResult
+}
+
+// GridSearchMovingAverage backtests every valid short/long period combination on
+// data and returns the results ordered by total profit, best first.
+func GridSearchMovingAverage(data []models.MarketData, short, long Range, threshold, initialBalance, commissionRate float64) []Result {
+	var results []Result
+	for _, s := range short.values() {
+		for _, l := range long.values() {
+			if s <= 0 || s >= l {
+				continue
+			}
+			params := models.MovingAverageConfig{ShortPeriod: s, LongPeriod: l, Threshold: threshold}
+			results = append(results, Result{Params: params, Backtest: BacktestMovingAverage(data, params, initialBalance, commissionRate)})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Backtest.TotalProfit > results[j].Backtest.TotalProfit
+	})
+	return results
+}
+
+// BacktestMovingAverage backtests the moving average strategy with params on
+// data.
+func BacktestMovingAverage(data []models.MarketData, params models.MovingAverageConfig, initialBalance, commissionRate float64) backtesting.BacktestResult {
+	return backtesting.NewBacktester(strategy.NewMovingAverage(params), data, initialBalance, commissionRate).Run()
+}
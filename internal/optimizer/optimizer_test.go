@@ -0,0 +1,145 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+)
+
+func TestGridSearchMovingAverage(t *testing.T) {
+	var data []models.MarketData
+	for i := 0; i < 60; i++ {
+		price := 1000 + i*10
+		if i >= 30 {
+			price = 1300 - (i-30)*10
+		}
+		data = append(data, models.MarketData{StckPrpr: strconv.Itoa(price)})
+	}
+
+	results := GridSearchMovingAverage(data, Range{Min: 2, Max: 5}, Range{Min: 4, Max: 8, Step: 2}, 0, 1000000, 0)
+
+	// short 2..5 x long 4,6,8 minus combinations where short >= long.
+	if len(results) != 10 {
+		t.Fatalf("got %d results, want 10", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Backtest.TotalProfit > results[i-1].Backtest.TotalProfit {
+			t.Fatalf("results not sorted by profit at %d", i)
+		}
+	}
+	for _, r := range results {
+		if r.Params.ShortPeriod >= r.Params.LongPeriod {
+			t.Errorf("invalid combination %+v", r.Params)
+		}
+	}
+}
+
+func TestGeneticFindsValidParameters(t *testing.T) {
+	var data []models.MarketData
+	for i := 0; i < 120; i++ {
+		price := 1000 + i*10
+		if i%40 >= 20 {
+			price = 1200 + (i/40)*400 - (i%40-20)*10
+		}
+		data = append(data, models.MarketData{StckPrpr: strconv.Itoa(price)})
+	}
+	space := []Param{
+		{Name: "short_period", Min: 2, Max: 10, Integer: true},
+		{Name: "long_period", Min: 4, Max: 30, Integer: true},
+	}
+	base := config.StrategyParams{"threshold": 0.0}
+	evaluate := BacktestStrategy("moving_average", base, data, 1000000, 0)
+	cfg := GeneticConfig{Population: 12, Generations: 8, Patience: 3, Seed: 7}
+
+	result := Genetic(cfg, space, evaluate, FitnessProfit(1), 1000000, len(data))
+	if len(result.Candidates) == 0 {
+		t.Fatal("no candidates evaluated")
+	}
+	if result.Generations > cfg.Generations {
+		t.Errorf("bred %d generations, want at most %d", result.Generations, cfg.Generations)
+	}
+	best := result.Candidates[0]
+	short, long := best.Params["short_period"].(int), best.Params["long_period"].(int)
+	if short >= long || math.IsInf(best.Fitness, -1) {
+		t.Fatalf("best candidate %v is invalid", best.Params)
+	}
+	want := FitnessProfit(1)(BacktestMovingAverage(data, models.MovingAverageConfig{ShortPeriod: short, LongPeriod: long}, 1000000, 0), 1000000, len(data))
+	if best.Fitness != want {
+		t.Errorf("best fitness %v, backtest of %d/%d scores %v", best.Fitness, short, long, want)
+	}
+	for i := 1; i < len(result.Candidates); i++ {
+		if result.Candidates[i].Fitness > result.Candidates[i-1].Fitness {
+			t.Fatalf("candidates not sorted by fitness at %d", i)
+		}
+	}
+
+	again := Genetic(cfg, space, evaluate, FitnessProfit(1), 1000000, len(data))
+	if fmt.Sprint(again.Candidates[0].Params) != fmt.Sprint(best.Params) || again.Generations != result.Generations {
+		t.Errorf("same seed found %v in %d generations, first run %v in %d",
+			again.Candidates[0].Params, again.Generations, best.Params, result.Generations)
+	}
+}
+
+func TestParseParam(t *testing.T) {
+	p, err := ParseParam("short_period=2:30")
+	if err != nil || p != (Param{Name: "short_period", Min: 2, Max: 30, Integer: true}) {
+		t.Errorf("got %+v, %v", p, err)
+	}
+	p, err = ParseParam("threshold=0:0.02")
+	if err != nil || p != (Param{Name: "threshold", Min: 0, Max: 0.02}) {
+		t.Errorf("got %+v, %v", p, err)
+	}
+	for _, bad := range []string{"short_period", "=1:2", "x=1", "x=5:1", "x=a:b"} {
+		if _, err := ParseParam(bad); err == nil {
+			t.Errorf("ParseParam(%q) accepted", bad)
+		}
+	}
+}
+
+func TestCrossValidateFlagsCollapse(t *testing.T) {
+	prices := func(n int, step int) []models.MarketData {
+		var data []models.MarketData
+		for i := 0; i < n; i++ {
+			data = append(data, models.MarketData{StckPrpr: strconv.Itoa(1000 + i*step)})
+		}
+		return data
+	}
+
+	inSample, folds, err := HoldOut(prices(100, 10), 0.3, 2, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inSample) != 70 || len(folds) != 2 {
+		t.Fatalf("got %d in-sample bars and %d folds, want 70 and 2", len(inSample), len(folds))
+	}
+	if len(folds[0].Data) != 35 || folds[0].Warmup != 20 || folds[0].Data[20].StckPrpr != "1700" {
+		t.Errorf("first fold has %d bars, warmup %d, starting at %s", len(folds[0].Data), folds[0].Warmup, folds[0].Data[folds[0].Warmup].StckPrpr)
+	}
+	if _, _, err := HoldOut(prices(10, 10), 0.3, 2, 0); err == nil {
+		t.Error("held out 3 bars in 2 folds")
+	}
+
+	params := config.StrategyParams{"short_period": 3, "long_period": 10, "threshold": 0.0}
+	trained := backtesting.BacktestResult{SharpeRatio: 1}
+
+	s, err := CrossValidate("moving_average", params, trained, folds, 1000000, 0, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Folds) != 2 || s.MeanSharpe <= 1 || s.Overfit {
+		t.Errorf("uptrend folds: %+v", s)
+	}
+
+	_, flat, _ := HoldOut(prices(100, 0), 0.3, 2, 20)
+	s, err = CrossValidate("moving_average", params, trained, flat, 1000000, 0, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.MeanSharpe != 0 || !s.Overfit {
+		t.Errorf("flat folds: %+v", s)
+	}
+}
@@ -0,0 +1,233 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+)
+
+var log = logging.New()
+
+// Store saves order records, e.g. the database.
+type Store interface {
+	SaveOrder(order *models.Order) error
+}
+
+// candleStore is implemented by stores that also keep candles; see
+// engine.CandleStore.
+type candleStore interface {
+	SaveCandles(candles []candle.Candle) error
+}
+
+// Outbox saves order records to a store and keeps those it fails to save in a
+// file until a retry succeeds. While records are pending, new ones are queued
+// behind them, so the store receives them in order. It is safe for concurrent
+// use.
+type Outbox struct {
+	path string
+
+	mu      sync.Mutex
+	store   Store
+	pending []models.Order
+}
+
+// Open opens the outbox file at path, creating its directory if needed, and
+// loads the records still pending from a previous run.
+func Open(path string, store Store) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %v", err)
+	}
+	o := &Outbox{path: path, store: store}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var order models.Order
+		if err := json.Unmarshal(scanner.Bytes(), &order); err != nil {
+			return nil, fmt.Errorf("outbox %s line %d: %v", path, line, err)
+		}
+		o.pending = append(o.pending, order)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %v", err)
+	}
+	return o, nil
+}
+
+// SetStore replaces the store, e.g. after a database reconnect.
+func (o *Outbox) SetStore(store Store) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.store = store
+}
+
+// SaveOrder saves order to the store, or queues it when the store fails or
+// earlier records are still pending. It only fails when the order can be
+// neither saved nor queued.
+//
+// A record may reach the store more than once, e.g. when a save succeeds but
+// its answer is lost. An order without a Key is given one before the first
+// attempt, so that the store can tell the copies apart from new orders.
+func (o *Outbox) SaveOrder(order *models.Order) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if order.Key == "" {
+		order.Key = models.NewOrderKey()
+	}
+	if len(o.pending) == 0 {
+		err := o.store.SaveOrder(order)
+		if err == nil {
+			return nil
+		}
+		log.WithError(err).WithField("symbol", order.Pair).Warn("Failed to save order, queued for retry")
+	}
+	if err := o.append(*order); err != nil {
+		return fmt.Errorf("failed to queue order: %v", err)
+	}
+	o.pending = append(o.pending, *order)
+	return nil
+}
+
+// SaveCandles passes candles on to the store if it keeps candles. Candles are
+// not queued: a restart backfills the ones that are missing.
+func (o *Outbox) SaveCandles(candles []candle.Candle) error {
+	o.mu.Lock()
+	store, ok := o.store.(candleStore)
+	o.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return store.SaveCandles(candles)
+}
+
+// Pending returns the number of queued records.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+// Flush saves the queued records to the store, oldest first, until one fails,
+// and removes the saved ones from the file.
+func (o *Outbox) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	saved := 0
+	var err error
+	for saved < len(o.pending) {
+		if err = o.store.SaveOrder(&o.pending[saved]); err != nil {
+			break
+		}
+		saved++
+	}
+	if saved > 0 {
+		if werr := o.rewrite(o.pending[saved:]); werr != nil {
+			// The saved records stay queued and are saved again on the next
+			// retry rather than lost.
+			return werr
+		}
+		o.pending = o.pending[saved:]
+		log.WithField("saved", saved).WithField("pending", len(o.pending)).Info("Saved queued orders")
+	}
+	return err
+}
+
+// Run retries the queued records every interval until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if o.Pending() == 0 {
+				continue
+			}
+			if err := o.Flush(); err != nil {
+				log.WithError(err).WithField("pending", o.Pending()).Warn("Failed to save queued orders")
+			}
+		}
+	}
+}
+
+// append writes order to the end of the file and syncs it.
+func (o *Outbox) append(order models.Order) error {
+	line, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rewrite replaces the file with orders, atomically.
+func (o *Outbox) rewrite(orders []models.Order) error {
+	if len(orders) == 0 {
+		if err := os.Remove(o.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear outbox: %v", err)
+		}
+		return nil
+	}
+	tmp := o.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, order := range orders {
+		line, err := json.Marshal(order)
+		if err == nil {
+			_, err = w.Write(append(line, '\n'))
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to rewrite outbox: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		return fmt.Errorf("failed to rewrite outbox: %v", err)
+	}
+	return nil
+}
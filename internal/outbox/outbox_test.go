@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"tradingbot/internal/models"
+)
+
+// flakyStore fails while down and records the orders it saves.
+type flakyStore struct {
+	down  bool
+	saved []string
+}
+
+func (s *flakyStore) SaveOrder(order *models.Order) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	s.saved = append(s.saved, order.Pair)
+	return nil
+}
+
+func TestQueuedOrdersSurviveRestartInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox", "orders.jsonl")
+	store := &flakyStore{}
+	o, err := Open(path, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.SaveOrder(&models.Order{Pair: "A"}); err != nil || o.Pending() != 0 {
+		t.Fatalf("save = %v, %d pending, want it stored directly", err, o.Pending())
+	}
+
+	store.down = true
+	o.SaveOrder(&models.Order{Pair: "B"})
+	// Saved while B is pending, C must wait behind it.
+	store.down = false
+	o.SaveOrder(&models.Order{Pair: "C"})
+	if o.Pending() != 2 {
+		t.Fatalf("%d pending, want 2", o.Pending())
+	}
+
+	// A restart picks the queue up from the file.
+	store.down = true
+	reopened, err := Open(path, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Pending() != 2 {
+		t.Fatalf("%d pending after reopening, want 2", reopened.Pending())
+	}
+	if err := reopened.Flush(); err == nil {
+		t.Fatal("flush succeeded while the store is down")
+	}
+
+	store.down = false
+	if err := reopened.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.saved; len(got) != 3 || got[1] != "B" || got[2] != "C" {
+		t.Errorf("saved %v, want [A B C]", got)
+	}
+	if again, _ := Open(path, store); again.Pending() != 0 {
+		t.Errorf("%d pending after flushing, want the file cleared", again.Pending())
+	}
+}
+
+// lossyStore saves the orders it is given, keyed like the database, but
+// reports the first save of each as failed, like a commit whose answer is
+// lost.
+type lossyStore struct {
+	saves int
+	keys  map[string]bool
+}
+
+func (s *lossyStore) SaveOrder(order *models.Order) error {
+	s.saves++
+	if order.Key == "" {
+		return errors.New("order without key")
+	}
+	if !s.keys[order.Key] {
+		s.keys[order.Key] = true
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func TestFlushSavingRecordTwiceKeepsKey(t *testing.T) {
+	store := &lossyStore{keys: map[string]bool{}}
+	o, err := Open(filepath.Join(t.TempDir(), "orders.jsonl"), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.SaveOrder(&models.Order{Pair: "A"})
+	o.SaveOrder(&models.Order{Pair: "B", Key: "caller-key"})
+	if o.Pending() != 2 {
+		t.Fatalf("%d pending, want 2", o.Pending())
+	}
+
+	// The flush saves A a second time, with the key of its first save.
+	if err := o.Flush(); err == nil {
+		t.Fatal("flush succeeded saving B for the first time")
+	}
+	if err := o.Flush(); err != nil || o.Pending() != 0 {
+		t.Fatalf("flush = %v, %d pending", err, o.Pending())
+	}
+	if store.saves != 4 || len(store.keys) != 2 || !store.keys["caller-key"] {
+		t.Errorf("%d saves of keys %v, want each of the two records saved twice under one key", store.saves, store.keys)
+	}
+}
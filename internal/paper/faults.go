@@ -0,0 +1,170 @@
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+	"tradingbot/internal/models"
+)
+
+// The errors injected by Faults and FailNext. ErrRejected and
+// ErrUnauthorized wrap the same models errors as the KIS client's, so that the
+// order flow handles them the same way, e.g. retries after refreshing an
+// expired token.
+var (
+	ErrRejected     = fmt.Errorf("%w by the broker", models.ErrOrderRejected)
+	ErrServer       = errors.New("500 Internal Server Error")
+	ErrUnauthorized = fmt.Errorf("%w: token expired", models.ErrUnauthorized)
+)
+
+// Faults are the failures the exchange injects, to test how the order flow
+// copes with an unreliable broker. Rates are chances per order in [0, 1],
+// drawn from a generator seeded with Seed so that runs can be repeated.
+type Faults struct {
+	// RejectRate fails orders with ErrRejected, ServerErrorRate with
+	// ErrServer and StaleTokenRate with ErrUnauthorized, before anything is
+	// filled.
+	RejectRate      float64
+	ServerErrorRate float64
+	StaleTokenRate  float64
+	// PartialFillRate fills only a random part of an order of two shares or
+	// more, at least one share; the rest is canceled.
+	PartialFillRate float64
+	// FillDelay accepts orders as open and fills them, in full, at the first
+	// price known once the delay has passed on the clock. Limit orders wait
+	// until they are marketable. Until filled they are reported by
+	// GetOpenOrders and can be canceled.
+	FillDelay time.Duration
+	Seed      int64
+}
+
+// pendingOrder is an accepted order waiting for its delayed fill.
+type pendingOrder struct {
+	// index is the order's position in Exchange.orders.
+	index  int
+	signal models.Signal
+	due    time.Time
+}
+
+// SetFaults replaces the injected faults. Orders already waiting for a
+// delayed fill keep their due time.
+func (e *Exchange) SetFaults(f Faults) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.faults = f
+	e.rand = rand.New(rand.NewSource(f.Seed))
+}
+
+// FailNext makes the next len(errs) orders fail with errs, in order, before
+// any random fault is drawn.
+func (e *Exchange) FailNext(errs ...error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failing = append(e.failing, errs...)
+}
+
+// fault returns the error to fail the next order with, if any.
+func (e *Exchange) fault() error {
+	if len(e.failing) > 0 {
+		err := e.failing[0]
+		e.failing = e.failing[1:]
+		return err
+	}
+	for _, f := range []struct {
+		rate float64
+		err  error
+	}{
+		{e.faults.StaleTokenRate, ErrUnauthorized},
+		{e.faults.ServerErrorRate, ErrServer},
+		{e.faults.RejectRate, ErrRejected},
+	} {
+		if f.rate > 0 && e.rand.Float64() < f.rate {
+			return f.err
+		}
+	}
+	return nil
+}
+
+// hold accepts signal as an open order to be filled after the fill delay.
+func (e *Exchange) hold(signal *models.Signal) *models.Order {
+	order := models.Order{
+		ID:        int64(len(e.orders) + 1),
+		Pair:      signal.Pair,
+		Type:      models.OrderTypeMarket,
+		Side:      models.OrderSide(signal.Type),
+		Amount:    signal.Amount,
+		Price:     signal.LimitPrice,
+		Status:    models.OrderStatusOpen,
+		Timestamp: e.clock.Now(),
+	}
+	if signal.LimitPrice > 0 {
+		order.Type = models.OrderTypeLimit
+	}
+	e.orders = append(e.orders, order)
+	e.pending = append(e.pending, pendingOrder{index: len(e.orders) - 1, signal: *signal, due: order.Timestamp.Add(e.faults.FillDelay)})
+	return &order
+}
+
+// settle fills the pending orders that are due and marketable. An order that
+// can no longer be filled, e.g. for lack of cash, is canceled.
+func (e *Exchange) settle() {
+	if len(e.pending) == 0 {
+		return
+	}
+	now := e.clock.Now()
+	waiting := e.pending[:0]
+	for _, p := range e.pending {
+		price, ok := e.prices[p.signal.Pair]
+		limit := p.signal.LimitPrice
+		marketable := limit == 0 || p.signal.Type == models.BuySignal && limit >= price || p.signal.Type == models.SellSignal && limit <= price
+		if now.Before(p.due) || !ok || !marketable {
+			waiting = append(waiting, p)
+			continue
+		}
+		order, err := e.fill(&p.signal, p.signal.Amount, price)
+		if err != nil {
+			e.orders[p.index].Status = models.OrderStatusCanceled
+			continue
+		}
+		order.ID, order.Type = e.orders[p.index].ID, e.orders[p.index].Type
+		e.orders[p.index] = order
+	}
+	e.pending = waiting
+}
+
+// GetOpenOrders returns the orders waiting for a delayed fill.
+func (e *Exchange) GetOpenOrders() ([]models.OpenOrder, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	open := make([]models.OpenOrder, 0, len(e.pending))
+	for _, p := range e.pending {
+		o := e.orders[p.index]
+		open = append(open, models.OpenOrder{
+			OrderNo:      strconv.FormatInt(o.ID, 10),
+			StockCode:    o.Pair,
+			Side:         o.Side,
+			Quantity:     o.Amount,
+			RemainingQty: o.Amount,
+			Price:        o.Price,
+		})
+	}
+	return open, nil
+}
+
+// CancelOrder cancels an order waiting for a delayed fill.
+func (e *Exchange) CancelOrder(order models.OpenOrder) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	for i, p := range e.pending {
+		if strconv.FormatInt(e.orders[p.index].ID, 10) == order.OrderNo {
+			e.orders[p.index].Status = models.OrderStatusCanceled
+			e.pending = append(e.pending[:i], e.pending[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no open order %s", order.OrderNo)
+}
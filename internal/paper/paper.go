@@ -0,0 +1,234 @@
+package paper
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/universe"
+)
+
+// Exchange is an in-process simulated broker. Prices are set by the caller and
+// every order is filled immediately, in full, at the last price of its symbol
+// less commission. Limit orders must be priced on the KRX tick size and are
+// rejected unless marketable at the last price, as nothing rests on a book. It
+// is safe for concurrent use. SetFaults and FailNext make it misbehave like a
+// real broker would.
+type Exchange struct {
+	commission  float64
+	requirement float64
+	clock       clock.Clock
+
+	mu        sync.Mutex
+	cash      float64
+	prices    map[string]float64
+	positions map[string]*models.Position
+	orders    []models.Order
+
+	faults  Faults
+	rand    *rand.Rand
+	failing []error
+	pending []pendingOrder
+}
+
+// New creates an exchange holding cash and no positions. commission is the
+// fraction of the traded value charged on every fill.
+func New(cash, commission float64, clk clock.Clock) *Exchange {
+	return &Exchange{
+		commission: commission,
+		clock:      clk,
+		cash:       cash,
+		prices:     map[string]float64{},
+		positions:  map[string]*models.Position{},
+		rand:       rand.New(rand.NewSource(0)),
+	}
+}
+
+// SetMarginRequirement sets the fraction of a credit buy paid in cash; the
+// rest is lent and recorded as the position's loan. Zero, the default, pays
+// credit buys in full.
+func (e *Exchange) SetMarginRequirement(requirement float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requirement = requirement
+}
+
+// SetPrice records the latest price of symbol.
+func (e *Exchange) SetPrice(symbol string, price float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.prices[symbol] = price
+	if p, ok := e.positions[symbol]; ok {
+		p.CurrentPrice = price
+		p.ProfitLoss = (price - p.AvgPrice) * p.Quantity
+	}
+	e.settle()
+}
+
+func (e *Exchange) GetMarketData(stockCode string) (*models.MarketData, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	price, ok := e.prices[stockCode]
+	if !ok {
+		return nil, fmt.Errorf("no price for %s", stockCode)
+	}
+	return &models.MarketData{StckPrpr: strconv.FormatFloat(price, 'f', -1, 64)}, nil
+}
+
+// PlaceOrder fills signal.Amount shares at the last price, unless a fault is
+// injected. Buys fail when cash does not cover the cost and sells when the
+// position is too small.
+func (e *Exchange) PlaceOrder(signal *models.Signal) (*models.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	if err := e.fault(); err != nil {
+		return nil, err
+	}
+
+	price, ok := e.prices[signal.Pair]
+	if !ok {
+		return nil, fmt.Errorf("no price for %s", signal.Pair)
+	}
+	if signal.Amount <= 0 {
+		return nil, fmt.Errorf("invalid order quantity %g", signal.Amount)
+	}
+	if limit := signal.LimitPrice; limit > 0 {
+		if universe.RoundToTick(limit) != limit {
+			return nil, fmt.Errorf("limit price %g is not a multiple of the tick size %g", limit, universe.TickSize(limit))
+		}
+		if signal.Type == models.BuySignal && limit < price || signal.Type == models.SellSignal && limit > price {
+			return nil, fmt.Errorf("limit price %g not marketable at %g", limit, price)
+		}
+	}
+
+	if e.faults.FillDelay > 0 {
+		return e.hold(signal), nil
+	}
+	amount := signal.Amount
+	if amount >= 2 && e.faults.PartialFillRate > 0 && e.rand.Float64() < e.faults.PartialFillRate {
+		amount = float64(1 + e.rand.Intn(int(amount)-1))
+	}
+	order, err := e.fill(signal, amount, price)
+	if err != nil {
+		return nil, err
+	}
+	order.ID = int64(len(e.orders) + 1)
+	e.orders = append(e.orders, order)
+	return &order, nil
+}
+
+// fill trades amount shares of signal at price.
+func (e *Exchange) fill(signal *models.Signal, amount, price float64) (models.Order, error) {
+	value := amount * price
+	fee := value * e.commission
+	p := e.positions[signal.Pair]
+	switch signal.Type {
+	case models.BuySignal:
+		loan := 0.0
+		if signal.Credit != "" && e.requirement > 0 {
+			loan = value * (1 - e.requirement)
+		}
+		if value-loan+fee > e.cash {
+			return models.Order{}, fmt.Errorf("%w: need %.0f in cash, have %.0f", models.ErrInsufficientFunds, value-loan+fee, e.cash)
+		}
+		e.cash -= value - loan + fee
+		if p == nil {
+			p = &models.Position{StockCode: signal.Pair}
+			e.positions[signal.Pair] = p
+		}
+		p.AvgPrice = (p.AvgPrice*p.Quantity + value) / (p.Quantity + amount)
+		p.Quantity += amount
+		if loan > 0 {
+			p.Loan += loan
+			p.LoanDate = e.clock.Now().In(market.KST).Format("20060102")
+		}
+	case models.SellSignal:
+		if p == nil || p.Quantity < amount {
+			return models.Order{}, fmt.Errorf("%w: not enough %s to sell %g", models.ErrInsufficientFunds, signal.Pair, amount)
+		}
+		if p.Loan > 0 && signal.Credit == "" {
+			return models.Order{}, fmt.Errorf("position in %s carries a loan and must be sold as a credit order", signal.Pair)
+		}
+		// A credit sell repays the loan in proportion to the shares sold.
+		repay := p.Loan * amount / p.Quantity
+		p.Loan -= repay
+		e.cash += value - fee - repay
+		p.Quantity -= amount
+		if p.Quantity == 0 {
+			delete(e.positions, signal.Pair)
+		}
+	default:
+		return models.Order{}, fmt.Errorf("unsupported signal type %q", signal.Type)
+	}
+	if p.Quantity > 0 {
+		p.CurrentPrice = price
+		p.ProfitLoss = (price - p.AvgPrice) * p.Quantity
+	}
+
+	return models.Order{
+		Pair:      signal.Pair,
+		Type:      models.OrderTypeMarket,
+		Side:      models.OrderSide(signal.Type),
+		Amount:    amount,
+		Price:     price,
+		Status:    models.OrderStatusClosed,
+		Timestamp: e.clock.Now(),
+	}, nil
+}
+
+// GetBalance returns the cash balance, formatted like the KIS client does.
+func (e *Exchange) GetBalance() (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	return strconv.FormatFloat(e.cash, 'f', 0, 64), nil
+}
+
+// GetPositions returns the open positions sorted by stock code.
+func (e *Exchange) GetPositions() ([]models.Position, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	positions := make([]models.Position, 0, len(e.positions))
+	for _, p := range e.positions {
+		positions = append(positions, *p)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].StockCode < positions[j].StockCode })
+	return positions, nil
+}
+
+// Cash returns the cash balance.
+func (e *Exchange) Cash() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	return e.cash
+}
+
+// Equity returns cash plus the positions valued at their last price, less
+// their loans.
+func (e *Exchange) Equity() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	equity := e.cash
+	for symbol, p := range e.positions {
+		equity += p.Quantity*e.prices[symbol] - p.Loan
+	}
+	return equity
+}
+
+// Orders returns the orders in the order they were placed: filled, or with
+// delayed fills also open or canceled.
+func (e *Exchange) Orders() []models.Order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settle()
+	return append([]models.Order(nil), e.orders...)
+}
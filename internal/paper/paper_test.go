@@ -0,0 +1,152 @@
+package paper
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/models"
+)
+
+func TestExchangeFillsAtLastPrice(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	e := New(1000000, 0.001, clk)
+
+	if _, err := e.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1}); err == nil {
+		t.Fatal("expected an error when no price is known")
+	}
+
+	e.SetPrice("005930", 70000)
+	order, err := e.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 10})
+	if err != nil {
+		t.Fatalf("buy failed: %v", err)
+	}
+	if order.Price != 70000 || order.Side != models.OrderSideBuy || !order.Timestamp.Equal(clk.Now()) {
+		t.Errorf("unexpected order %+v", order)
+	}
+	if got, want := e.Cash(), 1000000-700000-700.0; got != want {
+		t.Errorf("cash = %v, want %v", got, want)
+	}
+
+	if _, err := e.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 10}); !errors.Is(err, models.ErrInsufficientFunds) {
+		t.Errorf("err = %v, want insufficient funds", err)
+	}
+	if _, err := e.PlaceOrder(&models.Signal{Type: models.SellSignal, Pair: "005930", Amount: 11}); !errors.Is(err, models.ErrInsufficientFunds) {
+		t.Errorf("err = %v when selling more than held, want insufficient funds", err)
+	}
+
+	e.SetPrice("005930", 72000)
+	if got, want := e.Equity(), 1000000-700700+720000.0; got != want {
+		t.Errorf("equity = %v, want %v", got, want)
+	}
+	positions, _ := e.GetPositions()
+	if len(positions) != 1 || positions[0].ProfitLoss != 20000 {
+		t.Errorf("unexpected positions %+v", positions)
+	}
+
+	if _, err := e.PlaceOrder(&models.Signal{Type: models.SellSignal, Pair: "005930", Amount: 10}); err != nil {
+		t.Fatalf("sell failed: %v", err)
+	}
+	if got, want := e.Cash(), 1000000-700700+720000-720.0; got != want {
+		t.Errorf("cash = %v, want %v", got, want)
+	}
+	if positions, _ := e.GetPositions(); len(positions) != 0 {
+		t.Errorf("expected no positions, got %+v", positions)
+	}
+	if n := len(e.Orders()); n != 2 {
+		t.Errorf("got %d orders, want 2", n)
+	}
+}
+
+func TestExchangeChecksLimitPrices(t *testing.T) {
+	e := New(1000000, 0, clock.Real)
+	e.SetPrice("005930", 70000)
+
+	tests := []struct {
+		signal models.Signal
+		ok     bool
+	}{
+		{models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1, LimitPrice: 70050}, false},
+		{models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1, LimitPrice: 69900}, false},
+		{models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1, LimitPrice: 70100}, true},
+		{models.Signal{Type: models.SellSignal, Pair: "005930", Amount: 1, LimitPrice: 70100}, false},
+		{models.Signal{Type: models.SellSignal, Pair: "005930", Amount: 1, LimitPrice: 69900}, true},
+	}
+	for _, tt := range tests {
+		signal := tt.signal
+		if _, err := e.PlaceOrder(&signal); (err == nil) != tt.ok {
+			t.Errorf("%s at %g: err = %v, want ok %v", signal.Type, signal.LimitPrice, err, tt.ok)
+		}
+	}
+}
+
+func TestExchangeInjectsFaults(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	e := New(10000000, 0, clk)
+	e.SetPrice("005930", 70000)
+	buy := func(amount float64) (*models.Order, error) {
+		return e.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: "005930", Amount: amount})
+	}
+
+	e.FailNext(ErrUnauthorized, ErrServer)
+	if _, err := buy(1); err != ErrUnauthorized {
+		t.Errorf("first order: err = %v, want ErrUnauthorized", err)
+	}
+	if _, err := buy(1); err != ErrServer {
+		t.Errorf("second order: err = %v, want ErrServer", err)
+	}
+
+	e.SetFaults(Faults{RejectRate: 1})
+	if _, err := buy(1); err != ErrRejected {
+		t.Errorf("err = %v, want ErrRejected", err)
+	}
+
+	e.SetFaults(Faults{PartialFillRate: 1, Seed: 7})
+	order, err := buy(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Amount < 1 || order.Amount >= 10 || order.Status != models.OrderStatusClosed {
+		t.Errorf("partial fill = %+v, want 1 to 9 shares", order)
+	}
+	filled := order.Amount
+
+	e.SetFaults(Faults{FillDelay: time.Minute})
+	order, err = buy(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != models.OrderStatusOpen {
+		t.Errorf("delayed order status = %s, want open", order.Status)
+	}
+	if open, _ := e.GetOpenOrders(); len(open) != 1 || open[0].RemainingQty != 5 {
+		t.Fatalf("open orders = %+v, want the delayed buy", open)
+	}
+	clk.Advance(time.Minute)
+	e.SetPrice("005930", 71000)
+	if open, _ := e.GetOpenOrders(); len(open) != 0 {
+		t.Errorf("open orders = %+v, want none once filled", open)
+	}
+	orders := e.Orders()
+	if got := orders[len(orders)-1]; got.Status != models.OrderStatusClosed || got.Price != 71000 {
+		t.Errorf("delayed fill = %+v, want closed at 71000", got)
+	}
+	if positions, _ := e.GetPositions(); positions[0].Quantity != filled+5 {
+		t.Errorf("position = %g, want %g", positions[0].Quantity, filled+5)
+	}
+
+	// A delayed order can be canceled before it fills.
+	buy(3)
+	open, _ := e.GetOpenOrders()
+	if err := e.CancelOrder(open[0]); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Hour)
+	orders = e.Orders()
+	if got := orders[len(orders)-1]; got.Status != models.OrderStatusCanceled {
+		t.Errorf("canceled order = %+v", got)
+	}
+	if positions, _ := e.GetPositions(); positions[0].Quantity != filled+5 {
+		t.Errorf("canceled order was filled: position = %g", positions[0].Quantity)
+	}
+}
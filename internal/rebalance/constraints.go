@@ -0,0 +1,105 @@
+package rebalance
+
+import (
+	"math"
+	"sort"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+)
+
+// Binding tells which constraints changed a rebalance.
+type Binding struct {
+	Positions bool
+	Sectors   bool
+	Turnover  bool
+	// Skipped counts the trades below the minimum trade value.
+	Skipped int
+}
+
+// ConstrainTargets returns the target weights within the position limit and
+// sector caps of c, see config.PortfolioConstraints. sectors maps symbols to
+// their sector; symbols without one are not capped.
+func ConstrainTargets(targets map[string]float64, c config.PortfolioConstraints, sectors map[string]string) (map[string]float64, Binding) {
+	var binding Binding
+	out := make(map[string]float64, len(targets))
+	for symbol, w := range targets {
+		out[symbol] = w
+	}
+
+	if c.MaxPositions > 0 && len(out) > c.MaxPositions {
+		symbols := make([]string, 0, len(out))
+		for symbol := range out {
+			symbols = append(symbols, symbol)
+		}
+		sort.Slice(symbols, func(i, j int) bool {
+			if out[symbols[i]] != out[symbols[j]] {
+				return out[symbols[i]] > out[symbols[j]]
+			}
+			return symbols[i] < symbols[j]
+		})
+		for _, symbol := range symbols[c.MaxPositions:] {
+			out[symbol] = 0
+		}
+		binding.Positions = true
+	}
+
+	totals := make(map[string]float64)
+	for symbol, w := range out {
+		if sector := sectors[symbol]; sector != "" {
+			totals[sector] += w
+		}
+	}
+	for symbol, w := range out {
+		sector := sectors[symbol]
+		if limit, ok := c.SectorCaps[sector]; ok && sector != "" && totals[sector] > limit {
+			out[symbol] = w * limit / totals[sector]
+			binding.Sectors = true
+		}
+	}
+	return out, binding
+}
+
+// ConstrainTrades scales the trades planned for p down to the turnover limit
+// of c and drops those below its minimum trade value, keeping sells before
+// buys. Buys are capped again at the cash the kept sells leave.
+func ConstrainTrades(p Portfolio, trades []Trade, c config.PortfolioConstraints, commission float64) ([]Trade, Binding) {
+	var binding Binding
+	if equity := p.Equity(); c.MaxTurnover > 0 && equity > 0 {
+		traded := 0.0
+		for _, t := range trades {
+			traded += t.Quantity * t.Price
+		}
+		if limit := c.MaxTurnover * equity; traded > limit {
+			scale := limit / traded
+			scaled := make([]Trade, len(trades))
+			for i, t := range trades {
+				t.Quantity = math.Floor(t.Quantity * scale)
+				scaled[i] = t
+			}
+			trades = scaled
+			binding.Turnover = true
+		}
+	}
+
+	var kept []Trade
+	cash := p.Cash
+	for _, t := range trades {
+		if t.Side == models.BuySignal {
+			t.Quantity = math.Min(t.Quantity, math.Floor(cash/(t.Price*(1+commission))))
+		}
+		switch {
+		case t.Quantity <= 0:
+			continue
+		case t.Quantity*t.Price < c.MinTradeValue:
+			binding.Skipped++
+			continue
+		}
+		if t.Side == models.SellSignal {
+			cash += t.Quantity * t.Price * (1 - commission)
+		} else {
+			cash -= t.Quantity * t.Price * (1 + commission)
+		}
+		kept = append(kept, t)
+	}
+	return kept, binding
+}
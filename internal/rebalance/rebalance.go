@@ -0,0 +1,144 @@
+package rebalance
+
+import (
+	"math"
+	"sort"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+var log = logging.New()
+
+// Trade is an order in whole shares that moves a holding towards its target
+// weight.
+type Trade struct {
+	Symbol   string
+	Side     models.SignalType
+	Quantity float64
+	Price    float64
+}
+
+// Portfolio is what is rebalanced: the cash and the shares held of the
+// weighted symbols, valued at Prices.
+type Portfolio struct {
+	Cash   float64
+	Shares map[string]float64
+	Prices map[string]float64
+}
+
+// Equity returns the cash plus the value of the shares.
+func (p Portfolio) Equity() float64 {
+	equity := p.Cash
+	for symbol, qty := range p.Shares {
+		equity += qty * p.Prices[symbol]
+	}
+	return equity
+}
+
+// Drift returns the largest difference between the weight of a symbol in p
+// and its target.
+func Drift(p Portfolio, targets map[string]float64) float64 {
+	equity := p.Equity()
+	if equity <= 0 {
+		return 0
+	}
+	drift := 0.0
+	for symbol, target := range targets {
+		weight := p.Shares[symbol] * p.Prices[symbol] / equity
+		drift = math.Max(drift, math.Abs(weight-target))
+	}
+	return drift
+}
+
+// Plan returns the trades bringing every symbol back to its target weight,
+// sells before buys. Buys are limited to the cash there is once the sells
+// have paid commission, so that they can all be filled; symbols without a
+// price are left alone.
+func Plan(p Portfolio, targets map[string]float64, commission float64) []Trade {
+	equity := p.Equity()
+	if equity <= 0 {
+		return nil
+	}
+	symbols := make([]string, 0, len(targets))
+	for symbol := range targets {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	var sells, buys []Trade
+	cash := p.Cash
+	for _, symbol := range symbols {
+		price := p.Prices[symbol]
+		if price <= 0 {
+			continue
+		}
+		wanted := math.Floor(equity * targets[symbol] / price)
+		held := p.Shares[symbol]
+		switch {
+		case wanted < held:
+			sells = append(sells, Trade{Symbol: symbol, Side: models.SellSignal, Quantity: held - wanted, Price: price})
+			cash += (held - wanted) * price * (1 - commission)
+		case wanted > held:
+			buys = append(buys, Trade{Symbol: symbol, Side: models.BuySignal, Quantity: wanted - held, Price: price})
+		}
+	}
+	for i := range buys {
+		affordable := math.Floor(cash / (buys[i].Price * (1 + commission)))
+		if buys[i].Quantity > affordable {
+			buys[i].Quantity = affordable
+		}
+		cash -= buys[i].Quantity * buys[i].Price * (1 + commission)
+	}
+	trades := sells
+	for _, b := range buys {
+		if b.Quantity > 0 {
+			trades = append(trades, b)
+		}
+	}
+	return trades
+}
+
+// Due tells whether a portfolio drifted by drift is rebalanced at now, when it
+// was last rebalanced at last: the threshold trigger rebalances whenever the
+// drift exceeds the band, the calendar trigger only in a period it has not
+// been rebalanced in yet.
+func Due(cfg config.RebalanceConfig, drift float64, last, now time.Time) bool {
+	if drift <= cfg.Band {
+		return false
+	}
+	if cfg.Trigger == config.RebalanceCalendar {
+		return last.IsZero() || PeriodStart(now, cfg.Period).After(last)
+	}
+	return true
+}
+
+// PeriodStart returns the start of the calendar period holding t, in Korean
+// time: the Monday of its week, or the first day of its month or quarter.
+func PeriodStart(t time.Time, period string) time.Time {
+	t = t.In(market.KST)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, market.KST)
+	switch period {
+	case config.RebalanceWeekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case config.RebalanceQuarterly:
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, market.KST)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, market.KST)
+	}
+}
+
+// PeriodBars returns the number of daily bars in a calendar period, used to
+// rebalance backtests.
+func PeriodBars(period string) int {
+	switch period {
+	case config.RebalanceWeekly:
+		return 5
+	case config.RebalanceQuarterly:
+		return 63
+	default:
+		return 21
+	}
+}
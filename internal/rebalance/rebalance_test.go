@@ -0,0 +1,154 @@
+package rebalance
+
+import (
+	"math"
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+func TestPlanSellsBeforeBuying(t *testing.T) {
+	p := Portfolio{
+		Cash:   10000,
+		Shares: map[string]float64{"069500": 80, "148070": 20},
+		Prices: map[string]float64{"069500": 1000, "148070": 1000},
+	}
+	targets := map[string]float64{"069500": 0.6, "148070": 0.4}
+	if drift := Drift(p, targets); drift < 0.218 || drift > 0.219 {
+		t.Errorf("drift = %g, want about 0.218", drift)
+	}
+
+	trades := Plan(p, targets, 0.01)
+	if len(trades) != 2 {
+		t.Fatalf("trades = %+v, want a sell and a buy", trades)
+	}
+	if s := trades[0]; s.Symbol != "069500" || s.Side != models.SellSignal || s.Quantity != 14 {
+		t.Errorf("sell = %+v, want 14 of 069500", s)
+	}
+	// 44 shares are wanted but the cash of 23,860 after commission buys 23.
+	if b := trades[1]; b.Symbol != "148070" || b.Side != models.BuySignal || b.Quantity != 23 {
+		t.Errorf("buy = %+v, want 23 of 148070", b)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	targets := map[string]float64{"005930": 0.3, "000660": 0.3, "069500": 0.2, "148070": 0.1}
+	sectors := map[string]string{"005930": "반도체", "000660": "반도체"}
+	c := config.PortfolioConstraints{MaxPositions: 3, SectorCaps: map[string]float64{"반도체": 0.4}, MaxTurnover: 0.5, MinTradeValue: 50000}
+
+	got, binding := ConstrainTargets(targets, c, sectors)
+	want := map[string]float64{"005930": 0.2, "000660": 0.2, "069500": 0.2, "148070": 0}
+	for symbol, w := range want {
+		if math.Abs(got[symbol]-w) > 1e-9 {
+			t.Errorf("target of %s = %g, want %g", symbol, got[symbol], w)
+		}
+	}
+	if !binding.Positions || !binding.Sectors {
+		t.Errorf("binding = %+v, want positions and sectors", binding)
+	}
+
+	// Trading 1,000,000 of an equity of 1,000,000 is halved; the 20 shares
+	// left of the small buy are skipped.
+	p := Portfolio{
+		Cash:   500000,
+		Shares: map[string]float64{"148070": 500},
+		Prices: map[string]float64{"148070": 1000, "005930": 1000, "069500": 1000},
+	}
+	trades := []Trade{
+		{Symbol: "148070", Side: models.SellSignal, Quantity: 500, Price: 1000},
+		{Symbol: "005930", Side: models.BuySignal, Quantity: 460, Price: 1000},
+		{Symbol: "069500", Side: models.BuySignal, Quantity: 40, Price: 1000},
+	}
+	kept, binding := ConstrainTrades(p, trades, c, 0)
+	if len(kept) != 2 || kept[0].Quantity != 250 || kept[1].Quantity != 230 {
+		t.Errorf("trades = %+v, want 250 sold and 230 bought", kept)
+	}
+	if !binding.Turnover || binding.Skipped != 1 {
+		t.Errorf("binding = %+v, want turnover and 1 skipped", binding)
+	}
+}
+
+func TestDue(t *testing.T) {
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 10, 0, 0, 0, market.KST)
+	}
+	threshold := config.RebalanceConfig{Trigger: config.RebalanceThreshold, Band: 0.05}
+	if Due(threshold, 0.05, time.Time{}, at(10, 16)) || !Due(threshold, 0.06, at(10, 16), at(10, 16)) {
+		t.Error("threshold trigger does not follow the band")
+	}
+
+	quarterly := config.RebalanceConfig{Trigger: config.RebalanceCalendar, Period: config.RebalanceQuarterly}
+	if !Due(quarterly, 0.01, time.Time{}, at(10, 16)) {
+		t.Error("never rebalanced portfolio not due")
+	}
+	if Due(quarterly, 0.01, at(10, 1), at(12, 31)) || !Due(quarterly, 0.01, at(12, 31), at(1, 2).AddDate(1, 0, 0)) {
+		t.Error("quarterly trigger does not follow the quarters")
+	}
+	if got := PeriodStart(at(10, 16), config.RebalanceWeekly); !got.Equal(at(10, 12).Add(-10 * time.Hour)) {
+		t.Errorf("week of Friday 16 October starts %s, want Monday the 12th", got)
+	}
+}
+
+type fakeAccount struct {
+	cash      string
+	positions []models.Position
+	prices    map[string]string
+}
+
+func (f *fakeAccount) GetBalance() (string, error) { return f.cash, nil }
+
+func (f *fakeAccount) GetPositions() ([]models.Position, error) { return f.positions, nil }
+
+func (f *fakeAccount) GetMarketData(stockCode string) (*models.MarketData, error) {
+	return &models.MarketData{StckPrpr: f.prices[stockCode]}, nil
+}
+
+type submitted []*models.Signal
+
+func (s *submitted) Submit(source string, signal *models.Signal) {
+	*s = append(*s, signal)
+}
+
+func TestRunnerRebalancesMonthly(t *testing.T) {
+	cfg := &config.Config{Rebalance: config.RebalanceConfig{
+		Weights: map[string]float64{"069500": 0.6, "148070": 0.4},
+		Trigger: config.RebalanceCalendar,
+		Period:  config.RebalanceMonthly,
+		Band:    0.02,
+	}}
+	acct := &fakeAccount{
+		cash:      "1000000",
+		positions: []models.Position{{StockCode: "005930", Quantity: 10, CurrentPrice: 70000}},
+		prices:    map[string]string{"069500": "10000", "148070": "20000"},
+	}
+	var orders submitted
+	r := NewRunner(cfg, acct, &orders)
+
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	if _, err := r.Check(now); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[0].Pair != "069500" || orders[0].Amount != 60 || orders[1].Pair != "148070" || orders[1].Amount != 20 || orders[0].Strategy != Strategy {
+		t.Fatalf("orders = %+v, want 60 and 20 shares bought", orders)
+	}
+
+	// Drifted within the month nothing is traded until November.
+	acct.cash = "0"
+	acct.positions = append(acct.positions,
+		models.Position{StockCode: "069500", Quantity: 60, CurrentPrice: 12000},
+		models.Position{StockCode: "148070", Quantity: 20, CurrentPrice: 20000})
+	if trades, err := r.Check(now.AddDate(0, 0, 7)); err != nil || trades != nil {
+		t.Fatalf("Check() = %+v, %v, want nothing within the month", trades, err)
+	}
+	trades, err := r.Check(now.AddDate(0, 0, 17))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Of the ₩1,120,000 now held, 56 shares of 069500 and 22 of 148070 are
+	// the targets.
+	if len(trades) != 2 || trades[0].Side != models.SellSignal || trades[0].Quantity != 4 || trades[1].Side != models.BuySignal || trades[1].Quantity != 2 {
+		t.Errorf("trades = %+v, want 4 of 069500 sold for 2 of 148070", trades)
+	}
+}
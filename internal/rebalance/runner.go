@@ -0,0 +1,112 @@
+package rebalance
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Strategy tags the orders of the rebalancer.
+const Strategy = "rebalance"
+
+// Account is the part of the exchange client the runner needs.
+type Account interface {
+	GetBalance() (string, error)
+	GetPositions() ([]models.Position, error)
+	GetMarketData(stockCode string) (*models.MarketData, error)
+}
+
+// Submitter executes orders, e.g. the engine, which applies its risk checks
+// and records them.
+type Submitter interface {
+	Submit(source string, signal *models.Signal)
+}
+
+// Runner checks the account periodically and trades it back to the target
+// weights when they are due.
+type Runner struct {
+	cfg        config.RebalanceConfig
+	commission float64
+	acct       Account
+	orders     Submitter
+	// last is when the account was last rebalanced. It is not persisted, so
+	// a restart may rebalance again within a calendar period.
+	last time.Time
+}
+
+// NewRunner creates a runner for the rebalancing configured in cfg.
+func NewRunner(cfg *config.Config, acct Account, orders Submitter) *Runner {
+	return &Runner{cfg: cfg.Rebalance, commission: cfg.Fees.CommissionRate, acct: acct, orders: orders}
+}
+
+// Check reads the account at now and, when a rebalance is due, submits the
+// trades bringing it back to the target weights. It returns the trades.
+func (r *Runner) Check(now time.Time) ([]Trade, error) {
+	balance, err := r.acct.GetBalance()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %v", err)
+	}
+	cash, err := strconv.ParseFloat(balance, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid balance %q", balance)
+	}
+	positions, err := r.acct.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %v", err)
+	}
+
+	p := Portfolio{Cash: cash, Shares: make(map[string]float64), Prices: make(map[string]float64)}
+	for _, pos := range positions {
+		if _, ok := r.cfg.Weights[pos.StockCode]; ok {
+			p.Shares[pos.StockCode] = pos.Quantity
+			p.Prices[pos.StockCode] = pos.CurrentPrice
+		}
+	}
+	for symbol := range r.cfg.Weights {
+		if _, ok := p.Prices[symbol]; ok {
+			continue
+		}
+		md, err := r.acct.GetMarketData(symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s price: %v", symbol, err)
+		}
+		price, err := strconv.ParseFloat(md.StckPrpr, 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("invalid price %q for %s", md.StckPrpr, symbol)
+		}
+		p.Prices[symbol] = price
+	}
+
+	drift := Drift(p, r.cfg.Weights)
+	if !Due(r.cfg, drift, r.last, now) {
+		return nil, nil
+	}
+	trades := Plan(p, r.cfg.Weights, r.commission)
+	log.WithFields(logrus.Fields{"drift": drift, "trades": len(trades)}).Info("Rebalancing portfolio")
+	for _, t := range trades {
+		r.orders.Submit(Strategy, &models.Signal{Type: t.Side, Pair: t.Symbol, Amount: t.Quantity, Strategy: Strategy})
+	}
+	r.last = now
+	return trades, nil
+}
+
+// Run checks every interval until ctx is cancelled, starting at once.
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Check(time.Now()); err != nil {
+			log.WithError(err).Warn("Rebalance check failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package reconcile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+// Strategy tags the orders imported for trades made outside the bot.
+const Strategy = "external"
+
+// Account is the part of the exchange client reconciled against.
+type Account interface {
+	GetPositions() ([]models.Position, error)
+	GetOpenOrders() ([]models.OpenOrder, error)
+}
+
+// Store holds the orders placed by the bot.
+type Store interface {
+	ListOrdersBefore(t time.Time) ([]models.Order, error)
+	SaveOrder(order *models.Order) error
+}
+
+// Difference is a symbol whose position at the broker differs from the one
+// the stored orders add up to.
+type Difference struct {
+	Symbol string  `json:"symbol"`
+	Local  float64 `json:"local"`
+	Broker float64 `json:"broker"`
+	// AvgPrice and Price are the broker's average and current price.
+	AvgPrice float64 `json:"avg_price"`
+	Price    float64 `json:"price"`
+}
+
+// Quantity is the shares bought (positive) or sold (negative) outside the
+// bot.
+func (d Difference) Quantity() float64 {
+	return d.Broker - d.Local
+}
+
+// Report compares the stored orders with the broker.
+type Report struct {
+	Time time.Time `json:"time"`
+	// Matched counts the symbols held the same at both.
+	Matched   int          `json:"matched"`
+	Positions []Difference `json:"positions"`
+	// OpenOrders rest at the broker; Unknown are those of them without a
+	// stored order of the day for the same symbol and side.
+	OpenOrders []models.OpenOrder `json:"open_orders"`
+	Unknown    []models.OpenOrder `json:"unknown"`
+	// Imported holds the orders stored to bring the local positions in line.
+	Imported []models.Order `json:"imported"`
+}
+
+// Clean tells whether the bot's records match the broker.
+func (r *Report) Clean() bool {
+	return len(r.Positions) == 0 && len(r.Unknown) == 0
+}
+
+// Run compares the positions the stored orders add up to and the orders
+// placed today with the broker's positions and open orders at now. With
+// importTrades, every difference is stored as an order of Strategy: a buy at
+// the broker's average price for shares the bot does not know of, a sell at
+// the current price for shares no longer held.
+func Run(acct Account, store Store, now time.Time, importTrades bool) (*Report, error) {
+	orders, err := store.ListOrdersBefore(now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %v", err)
+	}
+	positions, err := acct.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %v", err)
+	}
+	open, err := acct.GetOpenOrders()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open orders: %v", err)
+	}
+
+	report := &Report{Time: now, OpenOrders: open}
+	local := make(map[string]float64)
+	type key struct {
+		symbol string
+		side   models.OrderSide
+	}
+	today := make(map[key]bool)
+	y, m, d := now.In(market.KST).Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, market.KST)
+	for _, o := range orders {
+		switch o.Side {
+		case models.OrderSideBuy:
+			local[o.Pair] += o.Amount
+		case models.OrderSideSell:
+			local[o.Pair] -= o.Amount
+		}
+		if !o.Timestamp.Before(midnight) {
+			today[key{o.Pair, o.Side}] = true
+		}
+	}
+	for _, o := range open {
+		if !today[key{o.StockCode, o.Side}] {
+			report.Unknown = append(report.Unknown, o)
+		}
+	}
+
+	broker := make(map[string]models.Position, len(positions))
+	for _, p := range positions {
+		broker[p.StockCode] = p
+	}
+	for symbol, qty := range local {
+		if _, ok := broker[symbol]; !ok && qty != 0 {
+			broker[symbol] = models.Position{StockCode: symbol}
+		}
+	}
+	for symbol, p := range broker {
+		diff := Difference{Symbol: symbol, Local: local[symbol], Broker: p.Quantity, AvgPrice: p.AvgPrice, Price: p.CurrentPrice}
+		if math.Abs(diff.Quantity()) < 1e-9 {
+			report.Matched++
+			continue
+		}
+		report.Positions = append(report.Positions, diff)
+	}
+	sort.Slice(report.Positions, func(i, j int) bool { return report.Positions[i].Symbol < report.Positions[j].Symbol })
+
+	if importTrades {
+		for _, diff := range report.Positions {
+			order := models.Order{
+				Pair:      diff.Symbol,
+				Type:      models.OrderTypeMarket,
+				Side:      models.OrderSideBuy,
+				Amount:    diff.Quantity(),
+				Price:     diff.AvgPrice,
+				Status:    models.OrderStatusClosed,
+				Timestamp: now,
+				Strategy:  Strategy,
+			}
+			if diff.Quantity() < 0 {
+				order.Side, order.Amount, order.Price = models.OrderSideSell, -diff.Quantity(), diff.Price
+			}
+			if err := store.SaveOrder(&order); err != nil {
+				return report, fmt.Errorf("failed to import %s: %v", diff.Symbol, err)
+			}
+			report.Imported = append(report.Imported, order)
+		}
+	}
+	return report, nil
+}
+
+// Log writes the report to the log, a warning for every difference.
+func (r *Report) Log() {
+	for _, d := range r.Positions {
+		log.WithFields(logrus.Fields{"symbol": d.Symbol, "local": d.Local, "broker": d.Broker}).Warn("Position differs from the broker")
+	}
+	for _, o := range r.Unknown {
+		log.WithFields(logrus.Fields{"symbol": o.StockCode, "side": o.Side, "order_no": o.OrderNo, "remaining": o.RemainingQty}).Warn("Open order not placed by the bot")
+	}
+	for _, o := range r.Imported {
+		log.WithFields(logrus.Fields{"symbol": o.Pair, "side": o.Side, "quantity": o.Amount, "price": o.Price}).Info("Imported outside trade")
+	}
+	log.WithFields(logrus.Fields{
+		"matched":     r.Matched,
+		"differences": len(r.Positions),
+		"open_orders": len(r.OpenOrders),
+		"unknown":     len(r.Unknown),
+		"imported":    len(r.Imported),
+	}).Info("Reconciliation report")
+}
@@ -0,0 +1,84 @@
+package reconcile
+
+import (
+	"testing"
+	"time"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+type fakeAccount struct {
+	positions []models.Position
+	open      []models.OpenOrder
+}
+
+func (f *fakeAccount) GetPositions() ([]models.Position, error)   { return f.positions, nil }
+func (f *fakeAccount) GetOpenOrders() ([]models.OpenOrder, error) { return f.open, nil }
+
+type fakeStore struct {
+	orders []models.Order
+}
+
+func (f *fakeStore) ListOrdersBefore(t time.Time) ([]models.Order, error) { return f.orders, nil }
+
+func (f *fakeStore) SaveOrder(order *models.Order) error {
+	f.orders = append(f.orders, *order)
+	return nil
+}
+
+func TestRunImportsOutsideTrades(t *testing.T) {
+	now := time.Date(2026, 10, 16, 8, 30, 0, 0, market.KST)
+	store := &fakeStore{orders: []models.Order{
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Timestamp: now.AddDate(0, 0, -3)},
+		{Pair: "005930", Side: models.OrderSideSell, Amount: 4, Timestamp: now.AddDate(0, 0, -2)},
+		{Pair: "000660", Side: models.OrderSideBuy, Amount: 5, Timestamp: now.AddDate(0, 0, -2)},
+		{Pair: "035720", Side: models.OrderSideBuy, Amount: 3, Timestamp: now.Add(-time.Hour)},
+	}}
+	acct := &fakeAccount{
+		positions: []models.Position{
+			{StockCode: "005930", Quantity: 6, AvgPrice: 70000, CurrentPrice: 71000},
+			{StockCode: "035720", Quantity: 8, AvgPrice: 40000, CurrentPrice: 42000},
+			{StockCode: "000660", Quantity: 2, AvgPrice: 150000, CurrentPrice: 160000},
+		},
+		open: []models.OpenOrder{
+			{OrderNo: "1", StockCode: "035720", Side: models.OrderSideBuy, Quantity: 3},
+			{OrderNo: "2", StockCode: "005930", Side: models.OrderSideSell, Quantity: 6},
+		},
+	}
+
+	report, err := Run(acct, store, now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Matched != 1 || len(report.Positions) != 2 || report.Clean() {
+		t.Fatalf("report = %+v, want 005930 matched and two differences", report)
+	}
+	if d := report.Positions[0]; d.Symbol != "000660" || d.Quantity() != -3 {
+		t.Errorf("first difference = %+v, want 3 shares of 000660 sold outside", d)
+	}
+	if len(report.Unknown) != 1 || report.Unknown[0].OrderNo != "2" {
+		t.Errorf("unknown open orders = %+v, want the sell of 005930", report.Unknown)
+	}
+	if len(store.orders) != 4 {
+		t.Fatal("orders imported without import")
+	}
+
+	report, err = Run(acct, store, now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Imported) != 2 || len(store.orders) != 6 {
+		t.Fatalf("imported %+v, want two orders stored", report.Imported)
+	}
+	sell, buy := report.Imported[0], report.Imported[1]
+	if sell.Side != models.OrderSideSell || sell.Amount != 3 || sell.Price != 160000 || sell.Strategy != Strategy {
+		t.Errorf("sell = %+v, want 3 shares at the current price", sell)
+	}
+	if buy.Pair != "035720" || buy.Side != models.OrderSideBuy || buy.Amount != 5 || buy.Price != 40000 {
+		t.Errorf("buy = %+v, want 5 shares at the average price", buy)
+	}
+
+	if report, err = Run(acct, store, now, true); err != nil || len(report.Positions) != 0 || report.Matched != 3 {
+		t.Errorf("after the import report = %+v, %v; want every position matched", report, err)
+	}
+}
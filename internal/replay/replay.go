@@ -0,0 +1,186 @@
+package replay
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/market"
+)
+
+// Tick is one recorded price observation.
+type Tick struct {
+	Time   time.Time
+	Symbol string
+	Price  float64
+}
+
+// timeLayouts are the accepted formats of the time column. Times without a zone
+// are taken to be KST.
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// Load reads ticks from a CSV file with the columns time, symbol and price, and
+// an optional header row. The ticks are returned sorted by time; ticks with the
+// same time keep their file order.
+func Load(path string) ([]Tick, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	ticks, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return ticks, nil
+}
+
+// Parse reads ticks in the format described at Load.
+func Parse(r io.Reader) ([]Tick, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	var ticks []Tick
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], "time") {
+			continue
+		}
+
+		t, err := parseTime(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		price, err := strconv.ParseFloat(record[2], 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("line %d: invalid price %q", line, record[2])
+		}
+		ticks = append(ticks, Tick{Time: t, Symbol: record[1], Price: price})
+	}
+
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+	return ticks, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, market.KST); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// Engine runs one trading cycle for a symbol, e.g. *engine.Engine.
+type Engine interface {
+	RunCycle(symbol string) error
+}
+
+// PriceFeed receives the recorded prices, e.g. *paper.Exchange.
+type PriceFeed interface {
+	SetPrice(symbol string, price float64)
+}
+
+// Replayer feeds recorded ticks through an engine. Ticks with the same time form
+// one cycle: all their prices are set and the clock is moved to that time before
+// a cycle runs for each of their symbols, as the live loop does every interval.
+type Replayer struct {
+	Engine Engine
+	Feed   PriceFeed
+	// Clock is the simulated clock the engine and exchange read.
+	Clock *clock.Simulated
+	// Speed is how many times faster than recorded the replay runs. Zero or
+	// less replays as fast as possible.
+	Speed float64
+	// Wall paces the replay when Speed is set.
+	Wall clock.Clock
+	// Checkpoint, when set, is called between cycles at least CheckpointEvery
+	// of recorded time apart, and after the last cycle, also of an
+	// interrupted replay, with the recorded time, e.g. to save the strategies' state so that a later replay can
+	// carry on from it.
+	Checkpoint      func(at time.Time)
+	CheckpointEvery time.Duration
+}
+
+// New creates a replayer paced by the system clock.
+func New(eng Engine, feed PriceFeed, clk *clock.Simulated, speed float64) *Replayer {
+	return &Replayer{Engine: eng, Feed: feed, Clock: clk, Speed: speed, Wall: clock.Real}
+}
+
+// Run replays ticks until they are exhausted or ctx is done, and returns the
+// number of cycles run. Cycle errors are published by the engine and do not
+// stop the replay.
+func (r *Replayer) Run(ctx context.Context, ticks []Tick) (int, error) {
+	cycles := 0
+	var checkpointed time.Time
+	if len(ticks) > 0 {
+		checkpointed = ticks[0].Time
+	}
+	for i := 0; i < len(ticks); {
+		now := ticks[i].Time
+		j := i
+		for j < len(ticks) && ticks[j].Time.Equal(now) {
+			j++
+		}
+		batch := ticks[i:j]
+		i = j
+
+		if err := r.wait(ctx, now); err != nil {
+			if r.Checkpoint != nil && cycles > 0 && !r.Clock.Now().Equal(checkpointed) {
+				r.Checkpoint(r.Clock.Now())
+			}
+			return cycles, err
+		}
+		for _, tick := range batch {
+			r.Feed.SetPrice(tick.Symbol, tick.Price)
+		}
+		r.Clock.Set(now)
+		for _, tick := range batch {
+			r.Engine.RunCycle(tick.Symbol)
+			cycles++
+		}
+		if r.Checkpoint != nil && (i == len(ticks) || r.CheckpointEvery > 0 && now.Sub(checkpointed) >= r.CheckpointEvery) {
+			r.Checkpoint(now)
+			checkpointed = now
+		}
+	}
+	return cycles, nil
+}
+
+// wait sleeps on the wall clock for the recorded time until next, scaled by Speed.
+func (r *Replayer) wait(ctx context.Context, next time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.Speed <= 0 {
+		return nil
+	}
+	gap := next.Sub(r.Clock.Now())
+	if gap <= 0 {
+		return nil
+	}
+
+	timer := r.Wall.NewTimer(time.Duration(float64(gap) / r.Speed))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
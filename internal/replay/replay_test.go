@@ -0,0 +1,106 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/market"
+)
+
+func TestParse(t *testing.T) {
+	ticks, err := Parse(strings.NewReader(`time,symbol,price
+2026-10-16 09:01:00,000660,180000
+# comment
+2026-10-16T09:00:00+09:00,005930,70000
+2026-10-16 09:01:00,005930,70100
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Tick{
+		{time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST), "005930", 70000},
+		{time.Date(2026, 10, 16, 9, 1, 0, 0, market.KST), "000660", 180000},
+		{time.Date(2026, 10, 16, 9, 1, 0, 0, market.KST), "005930", 70100},
+	}
+	if len(ticks) != len(want) {
+		t.Fatalf("got %d ticks, want %d", len(ticks), len(want))
+	}
+	for i := range want {
+		if !ticks[i].Time.Equal(want[i].Time) || ticks[i].Symbol != want[i].Symbol || ticks[i].Price != want[i].Price {
+			t.Errorf("tick %d = %+v, want %+v", i, ticks[i], want[i])
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("2026-10-16 09:00:00,005930,abc\n")); err == nil {
+		t.Error("expected an error for an invalid price")
+	}
+}
+
+type recordingEngine struct {
+	clock  clock.Clock
+	feed   map[string]float64
+	cycles []string
+}
+
+func (e *recordingEngine) SetPrice(symbol string, price float64) { e.feed[symbol] = price }
+
+func (e *recordingEngine) RunCycle(symbol string) error {
+	e.cycles = append(e.cycles, e.clock.Now().Format("15:04")+" "+symbol)
+	return nil
+}
+
+func TestReplayerRunsCyclesAtRecordedTimes(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	clk := clock.NewSimulated(start)
+	eng := &recordingEngine{clock: clk, feed: map[string]float64{}}
+	ticks := []Tick{
+		{start, "005930", 70000},
+		{start.Add(time.Minute), "000660", 180000},
+		{start.Add(time.Minute), "005930", 70100},
+	}
+
+	cycles, err := New(eng, eng, clk, 0).Run(context.Background(), ticks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cycles != 3 {
+		t.Errorf("cycles = %d, want 3", cycles)
+	}
+	want := []string{"09:00 005930", "09:01 000660", "09:01 005930"}
+	if strings.Join(eng.cycles, ",") != strings.Join(want, ",") {
+		t.Errorf("cycles = %v, want %v", eng.cycles, want)
+	}
+	if eng.feed["005930"] != 70100 {
+		t.Errorf("last price = %v, want 70100", eng.feed["005930"])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(eng, eng, clk, 0).Run(ctx, ticks); err == nil {
+		t.Error("expected an error for a cancelled context")
+	}
+}
+
+func TestReplayerCheckpoints(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	clk := clock.NewSimulated(start)
+	eng := &recordingEngine{clock: clk, feed: map[string]float64{}}
+	var ticks []Tick
+	for i := 0; i < 7; i++ {
+		ticks = append(ticks, Tick{start.Add(time.Duration(i) * time.Minute), "005930", 70000})
+	}
+
+	r := New(eng, eng, clk, 0)
+	var at []string
+	r.Checkpoint = func(t time.Time) { at = append(at, t.Format("15:04")) }
+	r.CheckpointEvery = 3 * time.Minute
+	if _, err := r.Run(context.Background(), ticks); err != nil {
+		t.Fatal(err)
+	}
+	// Every three minutes of recorded time, and after the last tick.
+	if want := "09:03,09:06"; strings.Join(at, ",") != want {
+		t.Errorf("checkpoints at %v, want %s", at, want)
+	}
+}
@@ -0,0 +1,269 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+// Costs are the trading costs charged on stored orders, as fractions of the
+// traded value.
+type Costs struct {
+	CommissionRate float64
+	SellTaxRate    float64
+}
+
+// Attribution is the PnL, fees and turnover of one strategy and symbol, or a
+// sum of them. Fees and dividends are included in RealizedPnL.
+type Attribution struct {
+	Strategy      string  `json:"strategy,omitempty"`
+	Symbol        string  `json:"symbol,omitempty"`
+	Trades        int     `json:"trades"`
+	Turnover      float64 `json:"turnover"`
+	Fees          float64 `json:"fees"`
+	Dividends     float64 `json:"dividends"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	// Quantity is the position held at the end of the period.
+	Quantity float64 `json:"quantity"`
+}
+
+// TotalPnL is the realized plus unrealized PnL.
+func (a Attribution) TotalPnL() float64 {
+	return a.RealizedPnL + a.UnrealizedPnL
+}
+
+func (a *Attribution) add(b Attribution) {
+	a.Trades += b.Trades
+	a.Turnover += b.Turnover
+	a.Fees += b.Fees
+	a.Dividends += b.Dividends
+	a.RealizedPnL += b.RealizedPnL
+	a.UnrealizedPnL += b.UnrealizedPnL
+}
+
+// PnL attributes the trading of a period to strategies and symbols.
+type PnL struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Rows holds one entry per strategy and symbol traded or held in the
+	// period, sorted by strategy and symbol.
+	Rows       []Attribution `json:"rows"`
+	ByStrategy []Attribution `json:"by_strategy"`
+	BySymbol   []Attribution `json:"by_symbol"`
+	Total      Attribution   `json:"total"`
+	// Unpriced counts orders stored without a price, which are left out.
+	Unpriced int `json:"unpriced"`
+}
+
+type position struct {
+	quantity, avgPrice float64
+}
+
+// Attribute replays orders, oldest first, and attributes the orders placed in
+// [from, to) to their strategy and symbol. Earlier orders only establish the
+// cost of positions carried into the period; later ones are ignored. Orders
+// without a strategy are attributed to defaultStrategy. Positions held at to
+// are valued at prices for the unrealized PnL; symbols without a price have
+// none.
+//
+// Dividends paid in the period, after tax, are shared among the strategies by
+// the shares they held the day before the record date, taken as the
+// ex-dividend date; a dividend on shares no strategy held goes to
+// defaultStrategy.
+func Attribute(orders []models.Order, dividends []models.Dividend, from, to time.Time, prices map[string]float64, costs Costs, defaultStrategy string) PnL {
+	type key struct{ strategy, symbol string }
+	positions := make(map[key]*position)
+	rows := make(map[key]*Attribution)
+	pnl := PnL{From: from, To: to}
+	row := func(k key) *Attribution {
+		if rows[k] == nil {
+			rows[k] = &Attribution{Strategy: k.strategy, Symbol: k.symbol}
+		}
+		return rows[k]
+	}
+
+	dividends = append([]models.Dividend(nil), dividends...)
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].RecordDate.Before(dividends[j].RecordDate) })
+	// credit attributes the dividends that went ex before t.
+	credit := func(t time.Time) {
+		for len(dividends) > 0 && !exDate(dividends[0]).After(t) {
+			d := dividends[0]
+			dividends = dividends[1:]
+			if d.PayDate.Before(from) || !d.PayDate.Before(to) {
+				continue
+			}
+			held := 0.0
+			for k, p := range positions {
+				if k.symbol == d.Symbol {
+					held += p.quantity
+				}
+			}
+			if held <= 0 {
+				r := row(key{defaultStrategy, d.Symbol})
+				r.Dividends += d.Net()
+				r.RealizedPnL += d.Net()
+				continue
+			}
+			for k, p := range positions {
+				if k.symbol == d.Symbol && p.quantity > 0 {
+					r := row(k)
+					r.Dividends += d.Net() * p.quantity / held
+					r.RealizedPnL += d.Net() * p.quantity / held
+				}
+			}
+		}
+	}
+
+	for _, o := range orders {
+		if !o.Timestamp.Before(to) {
+			break
+		}
+		credit(o.Timestamp)
+		if o.Price == 0 {
+			if !o.Timestamp.Before(from) {
+				pnl.Unpriced++
+			}
+			continue
+		}
+		k := key{o.Strategy, o.Pair}
+		if k.strategy == "" {
+			k.strategy = defaultStrategy
+		}
+		p := positions[k]
+		if p == nil {
+			p = &position{}
+			positions[k] = p
+		}
+
+		value := o.Amount * o.Price
+		fee := value * costs.CommissionRate
+		realized := -fee
+		switch o.Side {
+		case models.OrderSideBuy:
+			p.avgPrice = (p.avgPrice*p.quantity + value) / (p.quantity + o.Amount)
+			p.quantity += o.Amount
+		case models.OrderSideSell:
+			fee += value * costs.SellTaxRate
+			realized = (o.Price-p.avgPrice)*o.Amount - fee
+			p.quantity -= o.Amount
+			if p.quantity <= 0 {
+				*p = position{}
+			}
+		}
+		if o.Timestamp.Before(from) {
+			continue
+		}
+
+		r := row(k)
+		r.Trades++
+		r.Turnover += value
+		r.Fees += fee
+		r.RealizedPnL += realized
+	}
+	credit(to)
+
+	for k, p := range positions {
+		if p.quantity <= 0 {
+			continue
+		}
+		r := row(k)
+		r.Quantity = p.quantity
+		if price, ok := prices[k.symbol]; ok {
+			r.UnrealizedPnL = (price - p.avgPrice) * p.quantity
+		}
+	}
+
+	byStrategy := make(map[string]*Attribution)
+	bySymbol := make(map[string]*Attribution)
+	for _, row := range rows {
+		pnl.Rows = append(pnl.Rows, *row)
+		if byStrategy[row.Strategy] == nil {
+			byStrategy[row.Strategy] = &Attribution{Strategy: row.Strategy}
+		}
+		byStrategy[row.Strategy].add(*row)
+		if bySymbol[row.Symbol] == nil {
+			bySymbol[row.Symbol] = &Attribution{Symbol: row.Symbol}
+		}
+		bySymbol[row.Symbol].add(*row)
+		bySymbol[row.Symbol].Quantity += row.Quantity
+		pnl.Total.add(*row)
+	}
+	sort.Slice(pnl.Rows, func(i, j int) bool {
+		if pnl.Rows[i].Strategy != pnl.Rows[j].Strategy {
+			return pnl.Rows[i].Strategy < pnl.Rows[j].Strategy
+		}
+		return pnl.Rows[i].Symbol < pnl.Rows[j].Symbol
+	})
+	pnl.ByStrategy = sorted(byStrategy)
+	pnl.BySymbol = sorted(bySymbol)
+	return pnl
+}
+
+// DividendsFrom returns the first record date of the dividends that can be
+// paid in a period starting at start: year-end dividends are paid months
+// after their record date. A period without a start begins at the first
+// order.
+func DividendsFrom(start time.Time, orders []models.Order) time.Time {
+	if start.IsZero() && len(orders) > 0 {
+		start = orders[0].Timestamp
+	}
+	return start.AddDate(0, -6, 0)
+}
+
+// exDate returns the day before the dividend's record date, from which on
+// bought shares no longer earn it.
+func exDate(d models.Dividend) time.Time {
+	record := d.RecordDate.In(market.KST)
+	return time.Date(record.Year(), record.Month(), record.Day()-1, 0, 0, 0, 0, market.KST)
+}
+
+func sorted(groups map[string]*Attribution) []Attribution {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]Attribution, len(names))
+	for i, name := range names {
+		out[i] = *groups[name]
+	}
+	return out
+}
+
+// Prices returns the current price of every position, for valuing the
+// positions of an attribution.
+func Prices(positions []models.Position) map[string]float64 {
+	prices := make(map[string]float64, len(positions))
+	for _, p := range positions {
+		prices[p.StockCode] = p.CurrentPrice
+	}
+	return prices
+}
+
+// ParsePeriod parses a reporting period given as KST dates ("2006-01-02"),
+// both inclusive. An empty from starts at the first order and an empty to ends
+// with the day of now. The returned to is exclusive.
+func ParsePeriod(from, to string, now time.Time) (time.Time, time.Time, error) {
+	var start time.Time
+	if from != "" {
+		var err error
+		if start, err = time.ParseInLocation("2006-01-02", from, market.KST); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from date %q, want YYYY-MM-DD", from)
+		}
+	}
+	if to == "" {
+		to = now.In(market.KST).Format("2006-01-02")
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, market.KST)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to date %q, want YYYY-MM-DD", to)
+	}
+	end = end.AddDate(0, 0, 1)
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from %s is after to %s", from, to)
+	}
+	return start, end, nil
+}
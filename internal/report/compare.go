@@ -0,0 +1,81 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"tradingbot/internal/models"
+)
+
+// MetricDiff is one metric of two backtest runs and how B differs from A.
+type MetricDiff struct {
+	Name string
+	A, B float64
+	Diff float64
+}
+
+// ParamDiff is a parameter that differs between two backtest runs. A value
+// missing from a run is empty.
+type ParamDiff struct {
+	Name string
+	A, B string
+}
+
+// Comparison sets two backtest runs side by side.
+type Comparison struct {
+	A, B    models.BacktestRun
+	Metrics []MetricDiff
+	Params  []ParamDiff
+}
+
+// CompareBacktests compares every metric of a and b, sorted by name, and lists
+// the parameters that differ.
+func CompareBacktests(a, b models.BacktestRun) Comparison {
+	c := Comparison{A: a, B: b}
+
+	for _, name := range keys(a.Metrics, b.Metrics) {
+		c.Metrics = append(c.Metrics, MetricDiff{Name: name, A: a.Metrics[name], B: b.Metrics[name], Diff: b.Metrics[name] - a.Metrics[name]})
+	}
+
+	params := make(map[string]bool)
+	for name := range a.Params {
+		params[name] = true
+	}
+	for name := range b.Params {
+		params[name] = true
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		av, bv := paramString(a.Params, name), paramString(b.Params, name)
+		if av != bv {
+			c.Params = append(c.Params, ParamDiff{Name: name, A: av, B: bv})
+		}
+	}
+	return c
+}
+
+func keys(a, b map[string]float64) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range []map[string]float64{a, b} {
+		for name := range m {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func paramString(params map[string]interface{}, name string) string {
+	v, ok := params[name]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
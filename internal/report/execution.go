@@ -0,0 +1,145 @@
+package report
+
+import (
+	"sort"
+	"time"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+// Shortfall is the implementation shortfall of the orders of one strategy and
+// symbol, or a sum of them: what the fills cost against the price the signal
+// was acted on at and against the day's close, where backtests fill. Costs are
+// in KRW and basis points of the compared value; positive is a loss, i.e.
+// buying above or selling below the reference.
+type Shortfall struct {
+	Strategy string  `json:"strategy,omitempty"`
+	Symbol   string  `json:"symbol,omitempty"`
+	Orders   int     `json:"orders"`
+	Value    float64 `json:"value"`
+	// SignalCost and SignalBps compare the orders with a signal price,
+	// CloseCost and CloseBps those with a close.
+	SignalCost float64 `json:"signal_cost"`
+	SignalBps  float64 `json:"signal_bps"`
+	CloseCost  float64 `json:"close_cost"`
+	CloseBps   float64 `json:"close_bps"`
+
+	signalValue, closeValue float64
+}
+
+func (s *Shortfall) add(b Shortfall) {
+	s.Orders += b.Orders
+	s.Value += b.Value
+	s.SignalCost += b.SignalCost
+	s.CloseCost += b.CloseCost
+	s.signalValue += b.signalValue
+	s.closeValue += b.closeValue
+	s.finish()
+}
+
+func (s *Shortfall) finish() {
+	s.SignalBps, s.CloseBps = 0, 0
+	if s.signalValue > 0 {
+		s.SignalBps = s.SignalCost / s.signalValue * 1e4
+	}
+	if s.closeValue > 0 {
+		s.CloseBps = s.CloseCost / s.closeValue * 1e4
+	}
+}
+
+// Execution is the execution quality of a period.
+type Execution struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Rows holds one entry per strategy and symbol traded in the period,
+	// sorted by strategy and symbol.
+	Rows       []Shortfall `json:"rows"`
+	ByStrategy []Shortfall `json:"by_strategy"`
+	BySymbol   []Shortfall `json:"by_symbol"`
+	Total      Shortfall   `json:"total"`
+	// Unpriced counts orders stored without a fill price, which are left out.
+	Unpriced int `json:"unpriced"`
+}
+
+// ExecutionQuality measures the fills of the orders placed in [from, to)
+// against their signal price and the close of their KST day in closes, keyed
+// by symbol and "2006-01-02". Orders without a strategy are attributed to
+// defaultStrategy.
+func ExecutionQuality(orders []models.Order, closes map[string]map[string]float64, from, to time.Time, defaultStrategy string) Execution {
+	type key struct{ strategy, symbol string }
+	rows := make(map[key]*Shortfall)
+	ex := Execution{From: from, To: to}
+
+	for _, o := range orders {
+		if o.Timestamp.Before(from) || !o.Timestamp.Before(to) {
+			continue
+		}
+		if o.Price == 0 {
+			ex.Unpriced++
+			continue
+		}
+		k := key{o.Strategy, o.Pair}
+		if k.strategy == "" {
+			k.strategy = defaultStrategy
+		}
+		r := rows[k]
+		if r == nil {
+			r = &Shortfall{Strategy: k.strategy, Symbol: k.symbol}
+			rows[k] = r
+		}
+
+		side := 1.0
+		if o.Side == models.OrderSideSell {
+			side = -1
+		}
+		r.Orders++
+		r.Value += o.Amount * o.Price
+		if o.SignalPrice > 0 {
+			r.SignalCost += side * (o.Price - o.SignalPrice) * o.Amount
+			r.signalValue += o.SignalPrice * o.Amount
+		}
+		day := o.Timestamp.In(market.KST).Format("2006-01-02")
+		if close := closes[o.Pair][day]; close > 0 {
+			r.CloseCost += side * (o.Price - close) * o.Amount
+			r.closeValue += close * o.Amount
+		}
+	}
+
+	byStrategy := make(map[string]*Shortfall)
+	bySymbol := make(map[string]*Shortfall)
+	for _, row := range rows {
+		row.finish()
+		ex.Rows = append(ex.Rows, *row)
+		if byStrategy[row.Strategy] == nil {
+			byStrategy[row.Strategy] = &Shortfall{Strategy: row.Strategy}
+		}
+		byStrategy[row.Strategy].add(*row)
+		if bySymbol[row.Symbol] == nil {
+			bySymbol[row.Symbol] = &Shortfall{Symbol: row.Symbol}
+		}
+		bySymbol[row.Symbol].add(*row)
+		ex.Total.add(*row)
+	}
+	sort.Slice(ex.Rows, func(i, j int) bool {
+		if ex.Rows[i].Strategy != ex.Rows[j].Strategy {
+			return ex.Rows[i].Strategy < ex.Rows[j].Strategy
+		}
+		return ex.Rows[i].Symbol < ex.Rows[j].Symbol
+	})
+	ex.ByStrategy = sortedShortfalls(byStrategy)
+	ex.BySymbol = sortedShortfalls(bySymbol)
+	return ex
+}
+
+func sortedShortfalls(groups map[string]*Shortfall) []Shortfall {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]Shortfall, len(names))
+	for i, name := range names {
+		out[i] = *groups[name]
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+//go:embed templates/*.html
+var files embed.FS
+
+// templates holds every report page. Pages share the "header" and "footer"
+// blocks from layout.html so backtest output and emailed reports look the same.
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"krw": FormatKRW,
+	"pct": FormatPercent,
+	"kst": FormatKST,
+}).ParseFS(files, "templates/*.html"))
+
+// Backtest is the data behind the HTML backtest report.
+type Backtest struct {
+	Symbol   string
+	Strategy string
+	Days     int
+	Balance  float64
+	Result   backtesting.BacktestResult
+}
+
+// WriteBacktest renders the backtest report as an HTML page.
+func WriteBacktest(w io.Writer, b Backtest) error {
+	return templates.ExecuteTemplate(w, "backtest.html", b)
+}
+
+// Trade is an order placed during the reporting period.
+type Trade struct {
+	Time        time.Time
+	Symbol      string
+	Side        models.OrderSide
+	Quantity    float64
+	Price       float64
+	RealizedPnL float64
+}
+
+// Error is a failure reported on the event bus during the reporting period.
+type Error struct {
+	Time    time.Time
+	Source  string
+	Symbol  string
+	Message string
+}
+
+// Daily is the data behind the end-of-day report.
+type Daily struct {
+	Date            time.Time
+	Since           time.Time
+	Trades          []Trade
+	RealizedPnL     float64
+	UnrealizedPnL   float64
+	StartEquity     float64
+	Equity          float64
+	Benchmark       string
+	BenchmarkReturn float64
+	Positions       []models.Position
+	Errors          []Error
+	MissedErrors    int
+	Upcoming        []string
+}
+
+// Return is the change in equity over the reporting period.
+func (d Daily) Return() float64 {
+	if d.StartEquity == 0 {
+		return 0
+	}
+	return d.Equity/d.StartEquity - 1
+}
+
+// Subject is the one-line summary used as the email subject.
+func (d Daily) Subject() string {
+	return fmt.Sprintf("[tradingbot] %s: %s, %d trades, %d errors",
+		FormatKST(d.Date, "2006-01-02"), FormatPercent(d.Return()), len(d.Trades), len(d.Errors)+d.MissedErrors)
+}
+
+// WriteDaily renders the end-of-day report as an HTML page.
+func WriteDaily(w io.Writer, d Daily) error {
+	return templates.ExecuteTemplate(w, "daily.html", d)
+}
+
+// FormatKRW formats an amount in won with thousands separators, e.g. "₩-1,234,567".
+func FormatKRW(amount float64) string {
+	digits := strconv.FormatFloat(math.Abs(math.Round(amount)), 'f', 0, 64)
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if math.Round(amount) < 0 {
+		return "₩-" + b.String()
+	}
+	return "₩" + b.String()
+}
+
+// FormatKST formats t in KST with layout, whatever the zone of t, so that
+// reports read the same wherever they are produced.
+func FormatKST(t time.Time, layout string) string {
+	return t.In(market.KST).Format(layout)
+}
+
+// FormatPercent formats a ratio as a signed percentage, e.g. 0.0123 as "+1.23%".
+func FormatPercent(ratio float64) string {
+	return fmt.Sprintf("%+.2f%%", ratio*100)
+}
@@ -0,0 +1,273 @@
+package report
+
+import (
+	"bytes"
+	"html"
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/backtesting"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+func TestFormatKRW(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "₩0"},
+		{999, "₩999"},
+		{1000, "₩1,000"},
+		{1234567.4, "₩1,234,567"},
+		{-70000, "₩-70,000"},
+	}
+	for _, tt := range tests {
+		if got := FormatKRW(tt.amount); got != tt.want {
+			t.Errorf("FormatKRW(%v) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestWriteDaily(t *testing.T) {
+	day := time.Date(2026, 10, 16, 15, 40, 0, 0, market.KST)
+	d := Daily{
+		Date:        day,
+		Since:       day.Add(-24 * time.Hour),
+		StartEquity: 1000000,
+		Equity:      1010000,
+		Benchmark:   "069500",
+		Trades: []Trade{
+			// Times are shown in KST whatever their zone.
+			{Time: day.UTC(), Symbol: "005930", Side: models.OrderSideSell, Quantity: 1, Price: 70000, RealizedPnL: 5000},
+		},
+		Errors:   []Error{{Time: day, Source: "execution", Symbol: "005930", Message: "rejected <script>"}},
+		Upcoming: []string{"Next session 2026-10-19"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDaily(&buf, d); err != nil {
+		t.Fatalf("WriteDaily returned error: %v", err)
+	}
+	out := html.UnescapeString(buf.String())
+	for _, want := range []string{"Daily report 2026-10-16", "15:40:00", "+1.00%", "₩5,000", "rejected <script>", "Next session 2026-10-19"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+	if got, want := d.Subject(), "[tradingbot] 2026-10-16: +1.00%, 1 trades, 1 errors"; got != want {
+		t.Errorf("Subject() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteBacktest(t *testing.T) {
+	b := Backtest{
+		Symbol:   "005930",
+		Strategy: "moving_average",
+		Days:     100,
+		Balance:  10000000,
+		Result:   backtesting.BacktestResult{TotalTrades: 4, WinRate: 0.5, TotalProfit: 250000},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBacktest(&buf, b); err != nil {
+		t.Fatalf("WriteBacktest returned error: %v", err)
+	}
+	out := html.UnescapeString(buf.String())
+	for _, want := range []string{"Backtest 005930 (moving_average)", "₩250,000", "+50.00%", "Generated by tradingbot"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 10, 0, 0, 0, time.UTC) }
+	orders := []models.Order{
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Price: 1000, Timestamp: day(1)},
+		{Pair: "005930", Side: models.OrderSideSell, Amount: 10, Price: 1200, Timestamp: day(5)},
+		{Pair: "000660", Side: models.OrderSideBuy, Amount: 2, Price: 5000, Timestamp: day(6), Strategy: "fast"},
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 1, Timestamp: day(7)},
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 5, Price: 1000, Timestamp: day(20)},
+	}
+	costs := Costs{CommissionRate: 0.001, SellTaxRate: 0.002}
+	pnl := Attribute(orders, nil, day(3), day(10), map[string]float64{"000660": 6000}, costs, "moving_average")
+
+	if len(pnl.Rows) != 2 || pnl.Unpriced != 1 {
+		t.Fatalf("rows = %+v, unpriced %d; want 2 rows and 1 unpriced order", pnl.Rows, pnl.Unpriced)
+	}
+	fast, ma := pnl.Rows[0], pnl.Rows[1]
+	if fast.Strategy != "fast" || fast.Turnover != 10000 || fast.RealizedPnL != -10 || fast.UnrealizedPnL != 2000 || fast.Quantity != 2 {
+		t.Errorf("fast row = %+v", fast)
+	}
+	// The buy before the period sets the cost; only the sale is attributed.
+	if ma.Strategy != "moving_average" || ma.Trades != 1 || ma.Fees != 36 || ma.RealizedPnL != 2000-36 {
+		t.Errorf("moving_average row = %+v", ma)
+	}
+	if pnl.Total.Trades != 2 || pnl.Total.TotalPnL() != 1964-10+2000 {
+		t.Errorf("total = %+v", pnl.Total)
+	}
+	if len(pnl.BySymbol) != 2 || pnl.BySymbol[0].Symbol != "000660" || len(pnl.ByStrategy) != 2 {
+		t.Errorf("groups = %+v / %+v", pnl.BySymbol, pnl.ByStrategy)
+	}
+}
+
+func TestAttributeDividends(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 10, 0, 0, 0, time.UTC) }
+	orders := []models.Order{
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 30, Price: 1000, Timestamp: day(1, 5), Strategy: "fast"},
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Price: 1000, Timestamp: day(3, 2)},
+		// Bought on the ex-dividend date, too late for the dividend.
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 60, Price: 1000, Timestamp: day(3, 30)},
+	}
+	dividends := []models.Dividend{
+		{Symbol: "005930", RecordDate: day(3, 31), PayDate: day(4, 20), Amount: 4000, Tax: 616},
+		// Paid after the period.
+		{Symbol: "005930", RecordDate: day(6, 30), PayDate: day(8, 20), Amount: 10000},
+		{Symbol: "000660", RecordDate: day(3, 31), PayDate: day(4, 20), Amount: 500},
+	}
+	pnl := Attribute(orders, dividends, day(4, 1), day(5, 1), nil, Costs{}, "moving_average")
+
+	got := map[string]float64{}
+	for _, row := range pnl.Rows {
+		got[row.Strategy+"/"+row.Symbol] = row.Dividends
+	}
+	want := map[string]float64{"fast/005930": 2538, "moving_average/005930": 846, "moving_average/000660": 500}
+	for k, v := range want {
+		if math.Abs(got[k]-v) > 0.01 {
+			t.Errorf("%s dividends = %g, want %g", k, got[k], v)
+		}
+	}
+	if math.Abs(pnl.Total.RealizedPnL-3884) > 0.01 {
+		t.Errorf("total realized %g, want the 3884 of dividends", pnl.Total.RealizedPnL)
+	}
+}
+
+func TestExecutionQuality(t *testing.T) {
+	at := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, market.KST) }
+	orders := []models.Order{
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Price: 1010, SignalPrice: 1000, Timestamp: at(5, 10)},
+		{Pair: "005930", Side: models.OrderSideSell, Amount: 10, Price: 1190, SignalPrice: 1200, Timestamp: at(6, 14)},
+		{Pair: "000660", Side: models.OrderSideBuy, Amount: 2, Price: 5000, Timestamp: at(6, 9), Strategy: "fast"},
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 1, Timestamp: at(7, 10)},
+		{Pair: "005930", Side: models.OrderSideBuy, Amount: 5, Price: 1000, Timestamp: at(20, 10)},
+	}
+	closes := map[string]map[string]float64{
+		"005930": {"2026-10-05": 1020},
+		"000660": {"2026-10-06": 4900},
+	}
+	ex := ExecutionQuality(orders, closes, at(1, 0), at(10, 0), "moving_average")
+
+	if len(ex.Rows) != 2 || ex.Unpriced != 1 {
+		t.Fatalf("rows = %+v, unpriced %d; want 2 rows and 1 unpriced order", ex.Rows, ex.Unpriced)
+	}
+	fast, ma := ex.Rows[0], ex.Rows[1]
+	// Bought 100 above the close of 9,800.
+	if fast.Strategy != "fast" || fast.SignalCost != 0 || fast.CloseCost != 200 || math.Abs(fast.CloseBps-204.08) > 0.01 {
+		t.Errorf("fast row = %+v", fast)
+	}
+	// 100 lost buying and 100 selling against 22,000 of signal value; the buy
+	// beat its close by 100 and the sell's day has no close.
+	if ma.Orders != 2 || ma.SignalCost != 200 || math.Abs(ma.SignalBps-90.91) > 0.01 || ma.CloseCost != -100 || math.Abs(ma.CloseBps+98.04) > 0.01 {
+		t.Errorf("moving_average row = %+v", ma)
+	}
+	if ex.Total.Orders != 3 || ex.Total.CloseCost != 100 || ex.Total.CloseBps != 50 {
+		t.Errorf("total = %+v", ex.Total)
+	}
+	if len(ex.BySymbol) != 2 || len(ex.ByStrategy) != 2 || ex.ByStrategy[1].SignalBps != ma.SignalBps {
+		t.Errorf("groups = %+v / %+v", ex.BySymbol, ex.ByStrategy)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC)
+	from, to, err := ParsePeriod("2026-10-01", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.Format(time.RFC3339) != "2026-10-01T00:00:00+09:00" || to.Format(time.RFC3339) != "2026-10-17T00:00:00+09:00" {
+		t.Errorf("period = %s – %s", from, to)
+	}
+	if _, _, err := ParsePeriod("2026-10-10", "2026-10-01", now); err == nil {
+		t.Error("expected an error for a reversed period")
+	}
+	if _, _, err := ParsePeriod("10/01", "", now); err == nil {
+		t.Error("expected an error for a malformed date")
+	}
+}
+
+func TestCompareBacktests(t *testing.T) {
+	a := models.BacktestRun{
+		Params:  map[string]interface{}{"short_period": 5, "long_period": 20, "seed": 1},
+		Metrics: map[string]float64{"total_profit": 100000, "win_rate": 0.5},
+	}
+	b := models.BacktestRun{
+		Params:  map[string]interface{}{"short_period": 10, "long_period": 20, "seed": 1, "slippage": 0.001},
+		Metrics: map[string]float64{"total_profit": 150000, "win_rate": 0.5},
+	}
+
+	c := CompareBacktests(a, b)
+	if len(c.Metrics) != 2 || c.Metrics[0].Name != "total_profit" || c.Metrics[0].Diff != 50000 || c.Metrics[1].Diff != 0 {
+		t.Errorf("metrics = %+v", c.Metrics)
+	}
+	want := []ParamDiff{{"short_period", "5", "10"}, {"slippage", "", "0.001"}}
+	if len(c.Params) != len(want) {
+		t.Fatalf("params = %+v, want %+v", c.Params, want)
+	}
+	for i := range want {
+		if c.Params[i] != want[i] {
+			t.Errorf("params[%d] = %+v, want %+v", i, c.Params[i], want[i])
+		}
+	}
+}
+
+func TestTrackLots(t *testing.T) {
+	at := func(year int, month time.Month) time.Time { return time.Date(year, month, 10, 10, 0, 0, 0, time.UTC) }
+	orders := []models.Order{
+		{ID: 1, Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Price: 1000, Timestamp: at(2025, 12)},
+		{ID: 2, Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Price: 2000, Timestamp: at(2026, 1)},
+		{ID: 3, Pair: "000660", Side: models.OrderSideSell, Amount: 3, Price: 100, Timestamp: at(2026, 2)},
+		{ID: 4, Pair: "005930", Side: models.OrderSideSell, Amount: 15, Price: 3000, Timestamp: at(2026, 3)},
+	}
+	costs := Costs{SellTaxRate: 0.002}
+
+	fifo, err := TrackLots(orders, config.LotFIFO, []models.LotSelection{{SellID: 4, LotID: 2, Quantity: 10}}, costs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fifo.Open) != 1 || fifo.Open[0].ID != 2 || fifo.Open[0].Quantity != 5 {
+		t.Errorf("open lots = %+v, want 5 shares of lot 2", fifo.Open)
+	}
+	gains := YearlyGains(fifo.Disposals, 2026)
+	if len(gains.Rows) != 2 || gains.Rows[0].Symbol != "000660" || gains.Rows[0].Unmatched != 3 {
+		t.Fatalf("rows = %+v, want 000660 with 3 unmatched shares first", gains.Rows)
+	}
+	if g := gains.Rows[1]; g.Quantity != 15 || g.Cost != 20000 || g.Expenses != 90 || g.Gain != 24910 {
+		t.Errorf("FIFO 005930 = %+v, want a gain of 24910", g)
+	}
+
+	specific, err := TrackLots(orders, config.LotSpecific, []models.LotSelection{{SellID: 4, LotID: 2, Quantity: 10}}, costs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := YearlyGains(specific.Disposals, 2026).Rows[1]; g.Cost != 25000 || g.Gain != 19910 {
+		t.Errorf("specific 005930 = %+v, want lot 2 and 5 shares of lot 1 sold", g)
+	}
+	if len(YearlyGains(specific.Disposals, 2025).Rows) != 0 {
+		t.Error("gains reported for a year without sells")
+	}
+
+	var buf bytes.Buffer
+	if err := gains.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "2026,005930,15,45000,20000,90,24910,0\n") {
+		t.Errorf("CSV = %q", buf.String())
+	}
+
+	if _, err := TrackLots(orders, config.LotSpecific, []models.LotSelection{{SellID: 4, LotID: 2, Quantity: 11}}, costs); err == nil {
+		t.Error("selection of more shares than the lot holds accepted")
+	}
+}
@@ -0,0 +1,260 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+// Lot is a tax lot: the shares of one buy that are still held.
+type Lot struct {
+	// ID is the stored ID of the buy order.
+	ID       int64     `json:"id"`
+	Symbol   string    `json:"symbol"`
+	Acquired time.Time `json:"acquired"`
+	Quantity float64   `json:"quantity"`
+	Price    float64   `json:"price"`
+	// Cost is the acquisition cost of a share, the price plus the buy
+	// commission.
+	Cost float64 `json:"cost"`
+}
+
+// Disposal is the sale of shares of one lot.
+type Disposal struct {
+	Symbol string `json:"symbol"`
+	SellID int64  `json:"sell_id"`
+	// LotID is zero for shares sold without a stored buy, whose acquisition
+	// cost is unknown and left at zero.
+	LotID    int64     `json:"lot_id"`
+	Acquired time.Time `json:"acquired"`
+	Sold     time.Time `json:"sold"`
+	Quantity float64   `json:"quantity"`
+	Proceeds float64   `json:"proceeds"`
+	Cost     float64   `json:"cost"`
+	// Expenses are the sell commission and transaction tax.
+	Expenses float64 `json:"expenses"`
+}
+
+// Gain is the proceeds less the acquisition cost and the expenses.
+func (d Disposal) Gain() float64 {
+	return d.Proceeds - d.Cost - d.Expenses
+}
+
+// TaxLots is the result of matching sells to tax lots.
+type TaxLots struct {
+	// Open holds the lots still held, by symbol and acquisition.
+	Open      []Lot      `json:"open"`
+	Disposals []Disposal `json:"disposals"`
+	// Unpriced counts orders stored without a price, which are left out.
+	Unpriced int `json:"unpriced"`
+}
+
+// TrackLots replays orders, oldest first, keeping every buy as a tax lot and
+// matching each sell to the lots it disposes of. With config.LotSpecific the
+// lots selected for a sell are used first, and an error is returned for a
+// selection that names no open lot of the symbol or more shares than the lot
+// holds; the rest is matched first in, first out as with config.LotFIFO.
+func TrackLots(orders []models.Order, method string, selections []models.LotSelection, costs Costs) (TaxLots, error) {
+	selected := make(map[int64][]models.LotSelection)
+	if method == config.LotSpecific {
+		for _, s := range selections {
+			selected[s.SellID] = append(selected[s.SellID], s)
+		}
+	}
+
+	var result TaxLots
+	open := make(map[string][]*Lot)
+	for _, o := range orders {
+		if o.Price == 0 {
+			result.Unpriced++
+			continue
+		}
+		switch o.Side {
+		case models.OrderSideBuy:
+			open[o.Pair] = append(open[o.Pair], &Lot{
+				ID:       o.ID,
+				Symbol:   o.Pair,
+				Acquired: o.Timestamp,
+				Quantity: o.Amount,
+				Price:    o.Price,
+				Cost:     o.Price * (1 + costs.CommissionRate),
+			})
+		case models.OrderSideSell:
+			expenseRate := costs.CommissionRate + costs.SellTaxRate
+			dispose := func(lot *Lot, qty float64) {
+				d := Disposal{
+					Symbol:   o.Pair,
+					SellID:   o.ID,
+					Sold:     o.Timestamp,
+					Quantity: qty,
+					Proceeds: qty * o.Price,
+					Expenses: qty * o.Price * expenseRate,
+				}
+				if lot != nil {
+					d.LotID, d.Acquired, d.Cost = lot.ID, lot.Acquired, qty*lot.Cost
+					lot.Quantity -= qty
+				}
+				result.Disposals = append(result.Disposals, d)
+			}
+
+			remaining := o.Amount
+			for _, s := range selected[o.ID] {
+				var lot *Lot
+				for _, l := range open[o.Pair] {
+					if l.ID == s.LotID {
+						lot = l
+					}
+				}
+				if lot == nil || lot.Quantity < s.Quantity || remaining < s.Quantity {
+					return TaxLots{}, fmt.Errorf("sell %d of %s cannot take %g shares from lot %d", o.ID, o.Pair, s.Quantity, s.LotID)
+				}
+				dispose(lot, s.Quantity)
+				remaining -= s.Quantity
+			}
+			for _, lot := range open[o.Pair] {
+				if remaining <= 0 {
+					break
+				}
+				if lot.Quantity <= 0 {
+					continue
+				}
+				qty := remaining
+				if lot.Quantity < qty {
+					qty = lot.Quantity
+				}
+				dispose(lot, qty)
+				remaining -= qty
+			}
+			if remaining > 0 {
+				dispose(nil, remaining)
+			}
+
+			held := open[o.Pair][:0]
+			for _, lot := range open[o.Pair] {
+				if lot.Quantity > 0 {
+					held = append(held, lot)
+				}
+			}
+			open[o.Pair] = held
+		}
+	}
+
+	symbols := make([]string, 0, len(open))
+	for symbol := range open {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		for _, lot := range open[symbol] {
+			result.Open = append(result.Open, *lot)
+		}
+	}
+	return result, nil
+}
+
+// RealizedGain sums the disposals of a symbol, or of all symbols.
+type RealizedGain struct {
+	Symbol   string  `json:"symbol,omitempty"`
+	Quantity float64 `json:"quantity"`
+	Proceeds float64 `json:"proceeds"`
+	Cost     float64 `json:"cost"`
+	Expenses float64 `json:"expenses"`
+	Gain     float64 `json:"gain"`
+	// Unmatched is the number of shares sold without a stored buy.
+	Unmatched float64 `json:"unmatched"`
+}
+
+func (g *RealizedGain) add(d Disposal) {
+	g.Quantity += d.Quantity
+	g.Proceeds += d.Proceeds
+	g.Cost += d.Cost
+	g.Expenses += d.Expenses
+	g.Gain += d.Gain()
+	if d.LotID == 0 {
+		g.Unmatched += d.Quantity
+	}
+}
+
+// GainReport is the realized gain and loss of a calendar year, for tax filing.
+type GainReport struct {
+	Year int `json:"year"`
+	// Rows holds one entry per symbol sold in the year, sorted by symbol.
+	Rows  []RealizedGain `json:"rows"`
+	Total RealizedGain   `json:"total"`
+	// Disposals are the lots sold in the year.
+	Disposals []Disposal `json:"disposals"`
+}
+
+// YearlyGains sums the disposals sold in year, in Korean time, per symbol.
+func YearlyGains(disposals []Disposal, year int) GainReport {
+	r := GainReport{Year: year}
+	bySymbol := make(map[string]*RealizedGain)
+	for _, d := range disposals {
+		if d.Sold.In(market.KST).Year() != year {
+			continue
+		}
+		r.Disposals = append(r.Disposals, d)
+		g := bySymbol[d.Symbol]
+		if g == nil {
+			g = &RealizedGain{Symbol: d.Symbol}
+			bySymbol[d.Symbol] = g
+		}
+		g.add(d)
+		r.Total.add(d)
+	}
+	for _, g := range bySymbol {
+		r.Rows = append(r.Rows, *g)
+	}
+	sort.Slice(r.Rows, func(i, j int) bool { return r.Rows[i].Symbol < r.Rows[j].Symbol })
+	return r
+}
+
+// WriteCSV writes the per-symbol gains of the report as CSV, amounts in
+// whole won.
+func (r GainReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"year", "symbol", "quantity", "proceeds", "acquisition_cost", "expenses", "gain", "unmatched_quantity"})
+	for _, g := range r.Rows {
+		cw.Write([]string{
+			strconv.Itoa(r.Year), g.Symbol, formatQuantity(g.Quantity),
+			formatWon(g.Proceeds), formatWon(g.Cost), formatWon(g.Expenses), formatWon(g.Gain),
+			formatQuantity(g.Unmatched),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteDisposalsCSV writes the lots sold in the report as CSV, one line per
+// lot and sell.
+func (r GainReport) WriteDisposalsCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"symbol", "sell_id", "lot_id", "acquired", "sold", "quantity", "proceeds", "acquisition_cost", "expenses", "gain"})
+	for _, d := range r.Disposals {
+		acquired := ""
+		if d.LotID != 0 {
+			acquired = d.Acquired.In(market.KST).Format("2006-01-02")
+		}
+		cw.Write([]string{
+			d.Symbol, strconv.FormatInt(d.SellID, 10), strconv.FormatInt(d.LotID, 10),
+			acquired, d.Sold.In(market.KST).Format("2006-01-02"), formatQuantity(d.Quantity),
+			formatWon(d.Proceeds), formatWon(d.Cost), formatWon(d.Expenses), formatWon(d.Gain()),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatWon(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 0, 64)
+}
+
+func formatQuantity(qty float64) string {
+	return strconv.FormatFloat(qty, 'f', -1, 64)
+}
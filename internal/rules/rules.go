@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"sync"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+)
+
+// state is what a rule remembers of the signals of one symbol and sleeve.
+type state struct {
+	// last and streak are the latest signal and the number of bars in a row
+	// it was given.
+	last   models.SignalType
+	streak int
+	// tranches is the number of tranches bought, since the number of bars
+	// since the latest one.
+	tranches int
+	since    int
+}
+
+type key struct {
+	symbol, strategy string
+}
+
+// Rules maps strategy signals to the signals acted on, applying the configured
+// rules in order, see config.SignalRule. Each symbol, and each allocation
+// sleeve trading it, is tracked on its own. It is safe for concurrent use.
+type Rules struct {
+	rules []config.SignalRule
+
+	mu     sync.Mutex
+	states map[key][]state
+}
+
+// New creates the rule chain of rules.
+func New(rules []config.SignalRule) *Rules {
+	return &Rules{rules: rules, states: make(map[key][]state)}
+}
+
+// Apply returns the signal to act on for signal, which the strategy gave for
+// symbol on the latest bar. signal itself is not modified.
+func (r *Rules) Apply(symbol string, signal *models.Signal) *models.Signal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := key{symbol, signal.Strategy}
+	states := r.states[k]
+	if states == nil {
+		states = make([]state, len(r.rules))
+		r.states[k] = states
+	}
+
+	out := *signal
+	for i, rule := range r.rules {
+		s := &states[i]
+		switch rule.Rule {
+		case config.SignalRuleInvert:
+			switch out.Type {
+			case models.BuySignal:
+				out.Type = models.SellSignal
+			case models.SellSignal:
+				out.Type = models.BuySignal
+			}
+
+		case config.SignalRuleConfirm:
+			if out.Type == s.last {
+				s.streak++
+			} else {
+				s.last, s.streak = out.Type, 1
+			}
+			if out.Type != models.HoldSignal && s.streak <= bars(rule) {
+				out = models.Signal{Type: models.HoldSignal, Pair: out.Pair, Strategy: out.Strategy}
+			}
+
+		case config.SignalRuleTranches:
+			switch out.Type {
+			case models.SellSignal:
+				s.tranches, s.since = 0, 0
+			case models.BuySignal:
+				if s.tranches == 0 || s.tranches < rule.Tranches && s.since >= bars(rule) {
+					s.tranches++
+					s.since = 0
+				}
+				if s.tranches < rule.Tranches {
+					out.Fraction = float64(s.tranches) / float64(rule.Tranches)
+				}
+				s.since++
+			default:
+				if s.tranches > 0 {
+					s.since++
+				}
+			}
+		}
+	}
+	return &out
+}
+
+// bars returns the bars setting of rule, by default 1.
+func bars(rule config.SignalRule) int {
+	if rule.Bars <= 0 {
+		return 1
+	}
+	return rule.Bars
+}
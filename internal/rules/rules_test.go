@@ -0,0 +1,52 @@
+package rules
+
+import (
+	"testing"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+)
+
+func run(r *Rules, symbol string, types ...models.SignalType) []models.Signal {
+	var out []models.Signal
+	for _, t := range types {
+		out = append(out, *r.Apply(symbol, &models.Signal{Type: t, Pair: symbol, Amount: 1}))
+	}
+	return out
+}
+
+func TestConfirmWaitsForNextBar(t *testing.T) {
+	r := New([]config.SignalRule{{Rule: config.SignalRuleConfirm}})
+	buy, sell, hold := models.BuySignal, models.SellSignal, models.HoldSignal
+
+	got := run(r, "005930", buy, sell, sell, sell, hold, buy, buy)
+	want := []models.SignalType{hold, hold, sell, sell, hold, hold, buy}
+	for i := range want {
+		if got[i].Type != want[i] {
+			t.Errorf("bar %d: %s, want %s", i, got[i].Type, want[i])
+		}
+	}
+	// Symbols are confirmed on their own.
+	if got := run(r, "000660", buy); got[0].Type != hold {
+		t.Errorf("first buy of another symbol acted on")
+	}
+}
+
+func TestInvertThenTranches(t *testing.T) {
+	r := New([]config.SignalRule{
+		{Rule: config.SignalRuleInvert},
+		{Rule: config.SignalRuleTranches, Tranches: 3, Bars: 2},
+	})
+	buy, sell, hold := models.BuySignal, models.SellSignal, models.HoldSignal
+
+	// Inverted, the sells build the position in thirds every other bar.
+	got := run(r, "114800", sell, sell, hold, sell, sell, sell, buy, sell)
+	want := []struct {
+		typ      models.SignalType
+		fraction float64
+	}{{buy, 1.0 / 3}, {buy, 1.0 / 3}, {hold, 0}, {buy, 2.0 / 3}, {buy, 2.0 / 3}, {buy, 0}, {sell, 0}, {buy, 1.0 / 3}}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Fraction != w.fraction {
+			t.Errorf("bar %d: %s with fraction %g, want %s with %g", i, got[i].Type, got[i].Fraction, w.typ, w.fraction)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"math"
+	"sync"
+	"time"
+	"tradingbot/internal/config"
+)
+
+const defaultAdaptiveWindow = 20
+
+type observation struct {
+	time  time.Time
+	price float64
+}
+
+// Adaptive adapts the polling interval to the activity of the polled symbols;
+// see config.AdaptivePollingConfig. It is safe for concurrent use.
+type Adaptive struct {
+	base, min, max time.Duration
+	window         int
+	targetVol      float64
+	targetSpread   float64
+
+	mu      sync.Mutex
+	prices  map[string][]observation
+	spreads map[string]float64
+}
+
+// NewAdaptive creates an adaptive interval around base, the configured polling
+// interval.
+func NewAdaptive(cfg config.AdaptivePollingConfig, base time.Duration) *Adaptive {
+	a := &Adaptive{
+		base:         base,
+		window:       cfg.Window,
+		targetVol:    cfg.TargetVolatility,
+		targetSpread: cfg.TargetSpread,
+		prices:       map[string][]observation{},
+		spreads:      map[string]float64{},
+	}
+	a.min, _ = time.ParseDuration(cfg.MinInterval)
+	a.max, _ = time.ParseDuration(cfg.MaxInterval)
+	if a.window == 0 {
+		a.window = defaultAdaptiveWindow
+	}
+	return a
+}
+
+// Observe records a polled price of symbol and, when hasSpread, its bid-ask
+// spread as a fraction of the mid price.
+func (a *Adaptive) Observe(symbol string, t time.Time, price, spread float64, hasSpread bool) {
+	if price <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	prices := append(a.prices[symbol], observation{t, price})
+	if len(prices) > a.window {
+		prices = prices[len(prices)-a.window:]
+	}
+	a.prices[symbol] = prices
+	if hasSpread {
+		a.spreads[symbol] = spread
+	} else {
+		delete(a.spreads, symbol)
+	}
+}
+
+// Volatility returns the standard deviation of the returns per minute of
+// symbol's observed prices; ok is false with fewer than three of them.
+func (a *Adaptive) Volatility(symbol string) (float64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return volatility(a.prices[symbol])
+}
+
+func volatility(prices []observation) (float64, bool) {
+	if len(prices) < 3 {
+		return 0, false
+	}
+	var sum, squares float64
+	n := 0
+	for i := 1; i < len(prices); i++ {
+		minutes := prices[i].time.Sub(prices[i-1].time).Minutes()
+		if minutes <= 0 {
+			continue
+		}
+		// Returns over longer polls are scaled down to a minute, assuming
+		// they add up like a random walk.
+		r := math.Log(prices[i].price/prices[i-1].price) / math.Sqrt(minutes)
+		sum += r
+		squares += r * r
+		n++
+	}
+	if n < 2 {
+		return 0, false
+	}
+	mean := sum / float64(n)
+	variance := (squares - float64(n)*mean*mean) / float64(n-1)
+	return math.Sqrt(math.Max(variance, 0)), true
+}
+
+// Interval returns the polling interval for the activity of the most active
+// symbol, rounded to whole seconds and within the configured bounds. Until
+// any activity is known it is the configured polling interval.
+func (a *Adaptive) Interval() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	activity, known := 0.0, false
+	for symbol, prices := range a.prices {
+		if vol, ok := volatility(prices); ok {
+			activity, known = math.Max(activity, vol/a.targetVol), true
+		}
+		if spread, ok := a.spreads[symbol]; ok && a.targetSpread > 0 {
+			activity, known = math.Max(activity, spread/a.targetSpread), true
+		}
+	}
+	if !known {
+		return a.base
+	}
+	interval := a.max
+	if scaled := float64(a.base) / activity; scaled < float64(a.max) {
+		interval = time.Duration(scaled).Round(time.Second)
+	}
+	if interval < a.min {
+		interval = a.min
+	}
+	if interval > a.max {
+		interval = a.max
+	}
+	return interval
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/cron"
+	"tradingbot/internal/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+// cronJob is a task of a CronRunner.
+type cronJob struct {
+	name     string
+	schedule *cron.Schedule
+	run      func() error
+}
+
+// CronRunner runs tasks on cron schedules, one at a time.
+type CronRunner struct {
+	clock clock.Clock
+	jobs  []cronJob
+}
+
+// NewCronRunner creates a runner timed by clk.
+func NewCronRunner(clk clock.Clock) *CronRunner {
+	return &CronRunner{clock: clk}
+}
+
+// Add schedules run under name. A task that returns an error is logged and
+// runs again at its next time.
+func (r *CronRunner) Add(name string, schedule *cron.Schedule, run func() error) {
+	r.jobs = append(r.jobs, cronJob{name: name, schedule: schedule, run: run})
+}
+
+// Run runs the tasks when they are due until ctx is done. Tasks due at the
+// same time run in the order they were added; a time passed while a task ran
+// is not made up for.
+func (r *CronRunner) Run(ctx context.Context) {
+	if len(r.jobs) == 0 {
+		return
+	}
+	for {
+		now := r.clock.Now()
+		var next time.Time
+		for _, job := range r.jobs {
+			if at := job.schedule.Next(now); next.IsZero() || at.Before(next) {
+				next = at
+			}
+		}
+		log.WithField("next_task", next).Debug("Maintenance task scheduled")
+		timer := r.clock.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		for _, job := range r.jobs {
+			if !job.schedule.Next(next.Add(-time.Nanosecond)).Equal(next) {
+				continue
+			}
+			start := r.clock.Now()
+			err := job.run()
+			fields := logrus.Fields{"task": job.name, "duration": r.clock.Now().Sub(start)}
+			if err != nil {
+				log.WithError(err).WithFields(fields).Error("Maintenance task failed")
+			} else {
+				log.WithFields(fields).Info("Maintenance task done")
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/market"
+)
+
+// NextBoundary returns the first candle boundary strictly after t for candles of
+// the given interval, counted from midnight KST whatever the zone of t, so that
+// e.g. 5-minute bars start at 09:00, 09:05, ... like the exchange's own candles.
+// The boundary is returned in KST.
+func NextBoundary(t time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return t
+	}
+	local := t.In(market.KST)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, market.KST)
+	elapsed := t.Sub(midnight)
+	next := midnight.Add((elapsed/interval + 1) * interval)
+
+	// Intervals that don't divide a day restart at the next midnight.
+	if tomorrow := midnight.AddDate(0, 0, 1); next.After(tomorrow) {
+		return tomorrow
+	}
+	return next
+}
+
+// NextTimer returns a timer on clk that fires at the next candle boundary, and
+// the boundary itself.
+func NextTimer(clk clock.Clock, interval time.Duration) (clock.Timer, time.Time) {
+	now := clk.Now()
+	next := NextBoundary(now, interval)
+	return clk.NewTimer(next.Sub(now)), next
+}
+
+// ForEach calls fn for every item with at most parallel calls running at once,
+// and returns when all calls have finished.
+func ForEach(items []string, parallel int, fn func(item string)) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(item)
+		}(item)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/cron"
+	"tradingbot/internal/market"
+)
+
+func TestNextBoundary(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, market.KST)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		now      string
+		interval time.Duration
+		want     string
+	}{
+		{"2026-10-16 09:00:00", time.Minute, "2026-10-16 09:01:00"},
+		{"2026-10-16 09:00:30", time.Minute, "2026-10-16 09:01:00"},
+		{"2026-10-16 09:03:10", 5 * time.Minute, "2026-10-16 09:05:00"},
+		{"2026-10-16 09:59:59", time.Hour, "2026-10-16 10:00:00"},
+		{"2026-10-16 23:50:00", 7 * time.Hour, "2026-10-17 00:00:00"},
+	}
+	for _, tt := range tests {
+		if got := NextBoundary(at(tt.now), tt.interval); !got.Equal(at(tt.want)) {
+			t.Errorf("NextBoundary(%s, %v) = %s, want %s", tt.now, tt.interval, got.In(market.KST), tt.want)
+		}
+	}
+
+	// Boundaries are KST whatever the zone of the input.
+	got := NextBoundary(at("2026-10-16 09:03:10").UTC(), 5*time.Minute)
+	if got.Location() != market.KST || got.Format("15:04") != "09:05" {
+		t.Errorf("NextBoundary of a UTC time = %s, want 09:05 KST", got)
+	}
+}
+
+func TestNextTimerFiresAtBoundary(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 3, 10, 0, market.KST))
+	timer, next := NextTimer(clk, 5*time.Minute)
+	if want := time.Date(2026, 10, 16, 9, 5, 0, 0, market.KST); !next.Equal(want) {
+		t.Fatalf("next = %s, want %s", next, want)
+	}
+
+	clk.Advance(time.Minute)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired before the boundary")
+	default:
+	}
+	clk.Advance(time.Minute)
+	select {
+	case got := <-timer.C():
+		if !got.Equal(next) {
+			t.Errorf("timer fired at %s, want %s", got, next)
+		}
+	default:
+		t.Fatal("timer did not fire at the boundary")
+	}
+}
+
+func TestForEachBoundsParallelism(t *testing.T) {
+	var running, peak int32
+	var mu sync.Mutex
+	seen := map[string]bool{}
+
+	ForEach([]string{"a", "b", "c", "d", "e", "f"}, 2, func(item string) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		mu.Lock()
+		seen[item] = true
+		mu.Unlock()
+	})
+
+	if peak > 2 {
+		t.Errorf("peak parallelism = %d, want <= 2", peak)
+	}
+	if len(seen) != 6 {
+		t.Errorf("processed %d items, want 6", len(seen))
+	}
+}
+
+func TestCronRunnerRunsDueTasks(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 8, 0, 0, 0, market.KST))
+	daily, _ := cron.Parse("30 8 * * *", market.KST)
+	hourly, _ := cron.Parse("0 * * * *", market.KST)
+	var mu sync.Mutex
+	var ran []string
+	record := func(name string) func() error {
+		return func() error {
+			mu.Lock()
+			ran = append(ran, name+" "+clk.Now().Format("15:04"))
+			mu.Unlock()
+			return nil
+		}
+	}
+	runner := NewCronRunner(clk)
+	runner.Add("daily", daily, record("daily"))
+	runner.Add("hourly", hourly, record("hourly"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(done)
+	}()
+	// Step from timer to timer so that every run happens.
+	for i := 0; i < 3; i++ {
+		next := waitForTimer(t, clk)
+		clk.Set(next)
+	}
+	waitForTimer(t, clk)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"daily 08:30", "hourly 09:00", "hourly 10:00"}
+	if len(ran) != len(want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+	for i := range want {
+		if ran[i] != want[i] {
+			t.Errorf("ran %v, want %v", ran, want)
+			break
+		}
+	}
+}
+
+// waitForTimer waits until the runner has set a timer and returns its deadline.
+func waitForTimer(t *testing.T, clk *clock.Simulated) time.Time {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if next, ok := clk.NextDeadline(); ok {
+			return next
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("runner set no timer")
+	return time.Time{}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	cfg := config.AdaptivePollingConfig{MinInterval: "15s", MaxInterval: "5m", Window: 5, TargetVolatility: 0.01, TargetSpread: 0.002}
+	a := NewAdaptive(cfg, time.Minute)
+	start := time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)
+	if got := a.Interval(); got != time.Minute {
+		t.Fatalf("interval %v without data, want polling_interval", got)
+	}
+
+	// Alternating 2% moves a minute apart: a volatility of about 0.02 per
+	// minute, twice the target.
+	price := 10000.0
+	for i := 0; i < 5; i++ {
+		a.Observe("005930", start.Add(time.Duration(i)*time.Minute), price, 0, false)
+		if i%2 == 0 {
+			price *= 1.02
+		} else {
+			price /= 1.02
+		}
+	}
+	if got := a.Interval(); got < 25*time.Second || got > 30*time.Second {
+		t.Errorf("interval %v when volatile, want about 27s", got)
+	}
+
+	// Flat prices lengthen polls to the maximum, unless the spread is wide.
+	for i := 5; i < 10; i++ {
+		a.Observe("005930", start.Add(time.Duration(i)*time.Minute), 10000, 0, false)
+	}
+	if got := a.Interval(); got != 5*time.Minute {
+		t.Errorf("interval %v when quiet, want max_interval", got)
+	}
+	a.Observe("005930", start.Add(10*time.Minute), 10000, 0.01, true)
+	if got := a.Interval(); got != 15*time.Second {
+		t.Errorf("interval %v with a wide spread, want min_interval", got)
+	}
+}
@@ -0,0 +1,279 @@
+package screener
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"tradingbot/internal/candle"
+)
+
+// operandPattern matches an indicator with an optional period, e.g. close or sma(20).
+var operandPattern = regexp.MustCompile(`^([a-z_]+)(?:\((\d+)\))?$`)
+
+// indicators lists the names usable in conditions and whether they need a period.
+var indicators = map[string]bool{
+	"close":      false,
+	"open":       false,
+	"high":       false,
+	"low":        false,
+	"volume":     false,
+	"sma":        true,
+	"ema":        true,
+	"rsi":        true,
+	"highest":    true,
+	"lowest":     true,
+	"avg_volume": true,
+	"return":     true,
+	"volatility": true,
+}
+
+// operators are tried in order, so two-character operators win over their prefixes.
+var operators = []string{">=", "<=", ">", "<"}
+
+type operand struct {
+	name   string
+	period int
+	value  float64
+}
+
+// lookback is the number of candles needed to evaluate the operand.
+func (o operand) lookback() int {
+	switch o.name {
+	case "":
+		return 0
+	case "rsi", "return", "volatility":
+		return o.period + 1
+	case "ema":
+		return o.period * 3
+	default:
+		if o.period > 0 {
+			return o.period
+		}
+		return 1
+	}
+}
+
+// Condition compares two operands, each an indicator over daily candles or a
+// number, e.g. "close > sma(20)" or "rsi(14) < 30".
+type Condition struct {
+	text        string
+	op          string
+	left, right operand
+}
+
+// ParseCondition parses a condition of the form "<operand> <op> <operand>",
+// where op is one of >, >=, < and <=.
+func ParseCondition(s string) (Condition, error) {
+	for _, op := range operators {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+		left, err := parseOperand(s[:i])
+		if err != nil {
+			return Condition{}, fmt.Errorf("condition %q: %v", s, err)
+		}
+		right, err := parseOperand(s[i+len(op):])
+		if err != nil {
+			return Condition{}, fmt.Errorf("condition %q: %v", s, err)
+		}
+		return Condition{text: s, op: op, left: left, right: right}, nil
+	}
+	return Condition{}, fmt.Errorf("condition %q: missing comparison operator (>, >=, <, <=)", s)
+}
+
+func parseOperand(s string) (operand, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return operand{value: v}, nil
+	}
+	m := operandPattern.FindStringSubmatch(s)
+	if m == nil {
+		return operand{}, fmt.Errorf("invalid operand %q", s)
+	}
+	needsPeriod, ok := indicators[m[1]]
+	if !ok {
+		return operand{}, fmt.Errorf("unknown indicator %q", m[1])
+	}
+	if needsPeriod != (m[2] != "") {
+		if needsPeriod {
+			return operand{}, fmt.Errorf("%s needs a period, e.g. %s(20)", m[1], m[1])
+		}
+		return operand{}, fmt.Errorf("%s takes no period", m[1])
+	}
+	o := operand{name: m[1]}
+	if m[2] != "" {
+		o.period, _ = strconv.Atoi(m[2])
+		if o.period < 1 {
+			return operand{}, fmt.Errorf("period of %s must be positive", m[1])
+		}
+	}
+	return o, nil
+}
+
+// String returns the condition as written.
+func (c Condition) String() string {
+	return c.text
+}
+
+// Lookback returns how many daily candles the condition needs.
+func (c Condition) Lookback() int {
+	l, r := c.left.lookback(), c.right.lookback()
+	if l > r {
+		return l
+	}
+	return r
+}
+
+// Eval evaluates the condition on daily candles, oldest first.
+func (c Condition) Eval(candles []candle.Candle) (bool, error) {
+	if len(candles) < c.Lookback() {
+		return false, fmt.Errorf("%s needs %d days of data, have %d", c.text, c.Lookback(), len(candles))
+	}
+	l, r := c.left.eval(candles), c.right.eval(candles)
+	switch c.op {
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	case "<":
+		return l < r, nil
+	default:
+		return l <= r, nil
+	}
+}
+
+func (o operand) eval(candles []candle.Candle) float64 {
+	last := candles[len(candles)-1]
+	switch o.name {
+	case "":
+		return o.value
+	case "close":
+		return last.Close
+	case "open":
+		return last.Open
+	case "high":
+		return last.High
+	case "low":
+		return last.Low
+	case "volume":
+		return last.Volume
+	case "sma":
+		return mean(closes(candles[len(candles)-o.period:]))
+	case "ema":
+		return ema(closes(candles), o.period)
+	case "rsi":
+		return rsi(closes(candles), o.period)
+	case "highest":
+		v := 0.0
+		for _, c := range candles[len(candles)-o.period:] {
+			v = math.Max(v, c.High)
+		}
+		return v
+	case "lowest":
+		v := math.Inf(1)
+		for _, c := range candles[len(candles)-o.period:] {
+			v = math.Min(v, c.Low)
+		}
+		return v
+	case "avg_volume":
+		return AvgVolume(candles, o.period)
+	case "return":
+		base := candles[len(candles)-1-o.period].Close
+		return last.Close/base - 1
+	default: // volatility
+		return Volatility(candles, o.period)
+	}
+}
+
+// AvgVolume returns the mean daily volume of the last days candles.
+func AvgVolume(candles []candle.Candle, days int) float64 {
+	if days > len(candles) {
+		days = len(candles)
+	}
+	total := 0.0
+	for _, c := range candles[len(candles)-days:] {
+		total += c.Volume
+	}
+	if days == 0 {
+		return 0
+	}
+	return total / float64(days)
+}
+
+// Volatility returns the standard deviation of the last days daily close-to-close
+// returns.
+func Volatility(candles []candle.Candle, days int) float64 {
+	if days >= len(candles) {
+		days = len(candles) - 1
+	}
+	if days < 2 {
+		return 0
+	}
+	returns := make([]float64, 0, days)
+	for i := len(candles) - days; i < len(candles); i++ {
+		returns = append(returns, candles[i].Close/candles[i-1].Close-1)
+	}
+	m := mean(returns)
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - m) * (r - m)
+	}
+	return math.Sqrt(variance / float64(len(returns)-1))
+}
+
+func closes(candles []candle.Candle) []float64 {
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = c.Close
+	}
+	return out
+}
+
+func mean(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+// ema seeds with the SMA of the first period values and smooths over the rest.
+func ema(values []float64, period int) float64 {
+	k := 2 / float64(period+1)
+	v := mean(values[:period])
+	for _, x := range values[period:] {
+		v = x*k + v*(1-k)
+	}
+	return v
+}
+
+// rsi is Wilder's relative strength index over the values.
+func rsi(values []float64, period int) float64 {
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		g, l := gainLoss(values[i] - values[i-1])
+		gain += g
+		loss += l
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	for i := period + 1; i < len(values); i++ {
+		g, l := gainLoss(values[i] - values[i-1])
+		gain = (gain*float64(period-1) + g) / float64(period)
+		loss = (loss*float64(period-1) + l) / float64(period)
+	}
+	if loss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+func gainLoss(change float64) (float64, float64) {
+	if change > 0 {
+		return change, 0
+	}
+	return 0, -change
+}
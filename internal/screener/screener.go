@@ -0,0 +1,204 @@
+package screener
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const (
+	defaultVolumeDays     = 20
+	defaultVolatilityDays = 20
+)
+
+// DailySource provides daily candles, oldest first, e.g. the KIS client.
+type DailySource interface {
+	GetDailyCandles(stockCode string, days int) ([]candle.Candle, error)
+}
+
+// Result is a symbol that passed the screen, with the figures it was judged on.
+type Result struct {
+	Symbol     models.Symbol
+	Close      float64
+	AvgVolume  float64
+	Volatility float64
+}
+
+// Screener filters symbols by the settings of a config.ScreenConfig.
+type Screener struct {
+	cfg        config.ScreenConfig
+	conditions []Condition
+	days       int
+}
+
+// New parses the screen conditions and works out how much history they need.
+func New(cfg config.ScreenConfig) (*Screener, error) {
+	if cfg.VolumeDays == 0 {
+		cfg.VolumeDays = defaultVolumeDays
+	}
+	if cfg.VolatilityDays == 0 {
+		cfg.VolatilityDays = defaultVolatilityDays
+	}
+
+	s := &Screener{cfg: cfg, days: cfg.VolumeDays}
+	if cfg.VolatilityDays+1 > s.days {
+		s.days = cfg.VolatilityDays + 1
+	}
+	for _, text := range cfg.Conditions {
+		c, err := ParseCondition(text)
+		if err != nil {
+			return nil, err
+		}
+		if c.Lookback() > s.days {
+			s.days = c.Lookback()
+		}
+		s.conditions = append(s.conditions, c)
+	}
+	return s, nil
+}
+
+// Run screens symbols and returns those that pass, ordered by average traded
+// value, most liquid first, and capped at max_results. Symbols whose data cannot
+// be fetched are skipped; Run only fails when none could be fetched.
+func (s *Screener) Run(source DailySource, symbols []models.Symbol) ([]Result, error) {
+	var results []Result
+	var failed int
+	var lastErr error
+	for _, symbol := range symbols {
+		candles, err := source.GetDailyCandles(symbol.Code, s.days)
+		if err != nil {
+			log.WithError(err).WithField("symbol", symbol.Code).Warn("Failed to get daily candles, skipping")
+			failed++
+			lastErr = err
+			continue
+		}
+		if r, ok := s.check(symbol, candles); ok {
+			results = append(results, r)
+		}
+	}
+	if failed > 0 && failed == len(symbols) {
+		return nil, fmt.Errorf("failed to get daily candles for all %d symbols: %v", failed, lastErr)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].AvgVolume*results[i].Close > results[j].AvgVolume*results[j].Close
+	})
+	if s.cfg.MaxResults > 0 && len(results) > s.cfg.MaxResults {
+		results = results[:s.cfg.MaxResults]
+	}
+	return results, nil
+}
+
+// check applies the filters to one symbol's candles.
+func (s *Screener) check(symbol models.Symbol, candles []candle.Candle) (Result, bool) {
+	fields := logrus.Fields{"symbol": symbol.Code}
+	if len(candles) == 0 {
+		log.WithFields(fields).Debug("No daily candles, skipping")
+		return Result{}, false
+	}
+
+	r := Result{
+		Symbol:     symbol,
+		Close:      candles[len(candles)-1].Close,
+		AvgVolume:  AvgVolume(candles, s.cfg.VolumeDays),
+		Volatility: Volatility(candles, s.cfg.VolatilityDays),
+	}
+	cfg := s.cfg
+	switch {
+	case cfg.MinPrice > 0 && r.Close < cfg.MinPrice, cfg.MaxPrice > 0 && r.Close > cfg.MaxPrice:
+		log.WithFields(fields).Debugf("Price %g out of range", r.Close)
+		return r, false
+	case r.AvgVolume < cfg.MinAvgVolume:
+		log.WithFields(fields).Debugf("Average volume %g too low", r.AvgVolume)
+		return r, false
+	case r.Volatility < cfg.MinVolatility, cfg.MaxVolatility > 0 && r.Volatility > cfg.MaxVolatility:
+		log.WithFields(fields).Debugf("Volatility %g out of range", r.Volatility)
+		return r, false
+	}
+	for _, c := range s.conditions {
+		ok, err := c.Eval(candles)
+		if err != nil || !ok {
+			log.WithFields(fields).WithError(err).Debugf("Condition %s not met", c)
+			return r, false
+		}
+	}
+	return r, true
+}
+
+// Codes returns the stock codes of results.
+func Codes(results []Result) []string {
+	codes := make([]string, len(results))
+	for i, r := range results {
+		codes[i] = r.Symbol.Code
+	}
+	return codes
+}
+
+// Update is delivered by Watch after every scheduled screen.
+type Update struct {
+	Results []Result
+	Err     error
+	Time    time.Time
+}
+
+// Watch calls run every trading day at the KST time of day at ("HH:MM") and
+// delivers the outcome. Without a calendar it runs every weekday. The channel
+// closes when ctx is done.
+func Watch(ctx context.Context, clk clock.Clock, at string, cal *market.Calendar, run func() ([]Result, error)) <-chan Update {
+	updates := make(chan Update, 1)
+	atTime, _ := time.Parse("15:04", at)
+
+	go func() {
+		defer close(updates)
+		for {
+			next := nextRun(clk.Now(), atTime, cal)
+			log.WithField("next_screen", next).Debug("Screen scheduled")
+			timer := clk.NewTimer(next.Sub(clk.Now()))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+
+			results, err := run()
+			select {
+			case updates <- Update{Results: results, Err: err, Time: clk.Now()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates
+}
+
+// nextRun returns the first time of day at, after now, on a trading day.
+func nextRun(now, at time.Time, cal *market.Calendar) time.Time {
+	local := now.In(market.KST)
+	for i := 0; ; i++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+i, at.Hour(), at.Minute(), 0, 0, market.KST)
+		if !day.After(now) {
+			continue
+		}
+		if cal != nil {
+			if _, ok := cal.SessionOn(day); !ok {
+				continue
+			}
+		} else if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		return day
+	}
+}
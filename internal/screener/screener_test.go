@@ -0,0 +1,141 @@
+package screener
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+// series builds daily candles closing at closes with a constant volume.
+func series(volume float64, closes ...float64) []candle.Candle {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, market.KST)
+	out := make([]candle.Candle, len(closes))
+	for i, c := range closes {
+		out[i] = candle.Candle{Start: start.AddDate(0, 0, i), Timeframe: 24 * time.Hour,
+			Open: c, High: c * 1.01, Low: c * 0.99, Close: c, Volume: volume}
+	}
+	return out
+}
+
+func TestParseCondition(t *testing.T) {
+	for _, bad := range []string{"close", "close > sma", "close(3) > 1", "foo(2) < 1", "close > sma(0)", "close >"} {
+		if _, err := ParseCondition(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+
+	candles := series(1000, 100, 101, 102, 103, 104, 110)
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"close > sma(5)", true},
+		{"close >= 110", true},
+		{"close < 110", false},
+		{"return(5) > 0.09", true},
+		{"highest(3) <= 111.1", true},
+		{"lowest(2) < 103", true},
+		{"avg_volume(3) >= 1000", true},
+		{"rsi(3) > 99", true},
+		{"ema(2) > sma(2)", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseCondition(tt.cond)
+		if err != nil {
+			t.Fatalf("ParseCondition(%q): %v", tt.cond, err)
+		}
+		got, err := c.Eval(candles)
+		if err != nil {
+			t.Fatalf("Eval(%q): %v", tt.cond, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+
+	c, _ := ParseCondition("sma(10) > 1")
+	if _, err := c.Eval(candles); err == nil {
+		t.Error("expected an error with too little data")
+	}
+}
+
+func TestVolatility(t *testing.T) {
+	// Returns alternate +10% and -10%: stddev ≈ 0.1 with the sample correction.
+	candles := series(0, 100, 110, 99, 108.9, 98.01)
+	if got := Volatility(candles, 4); math.Abs(got-0.11547) > 1e-4 {
+		t.Errorf("Volatility = %v, want ≈ 0.1155", got)
+	}
+	if got := Volatility(series(0, 100, 100, 100), 20); got != 0 {
+		t.Errorf("Volatility of a flat series = %v, want 0", got)
+	}
+}
+
+type fakeSource map[string][]candle.Candle
+
+func (f fakeSource) GetDailyCandles(code string, days int) ([]candle.Candle, error) {
+	candles, ok := f[code]
+	if !ok {
+		return nil, errors.New("no data")
+	}
+	if len(candles) > days {
+		candles = candles[len(candles)-days:]
+	}
+	return candles, nil
+}
+
+func TestRunFiltersAndRanks(t *testing.T) {
+	flat := make([]float64, 25)
+	for i := range flat {
+		flat[i] = 10000 + float64((i+1)%2)*100
+	}
+	cheap := make([]float64, 25)
+	for i := range cheap {
+		cheap[i] = 1000
+	}
+	source := fakeSource{
+		"000001": series(50000, flat...),
+		"000002": series(500000, flat...),
+		"000003": series(900000, cheap...),                          // below min_price
+		"000004": series(100, flat...),                              // too illiquid
+		"000005": series(900000, 10000, 20000, 10000, 20000, 10000), // too volatile
+	}
+	symbols := []models.Symbol{{Code: "000001"}, {Code: "000002"}, {Code: "000003"}, {Code: "000004"}, {Code: "000005"}, {Code: "000006"}}
+
+	scr, err := New(config.ScreenConfig{MinPrice: 5000, MinAvgVolume: 10000, MaxVolatility: 0.05, Conditions: []string{"close >= sma(20)"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := scr.Run(source, symbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(Codes(results), ","); got != "000002,000001" {
+		t.Errorf("results = %s, want 000002,000001", got)
+	}
+
+	scr, _ = New(config.ScreenConfig{MaxResults: 1})
+	if results, _ := scr.Run(source, symbols); len(results) != 1 {
+		t.Errorf("got %d results, want max_results 1", len(results))
+	}
+	if _, err := scr.Run(source, []models.Symbol{{Code: "000006"}}); err == nil {
+		t.Error("expected an error when no data could be fetched")
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	at, _ := time.Parse("15:04", "08:30")
+	friday := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	if got, want := nextRun(friday, at, nil), time.Date(2026, 10, 19, 8, 30, 0, 0, market.KST); !got.Equal(want) {
+		t.Errorf("nextRun after Friday's run = %s, want Monday %s", got, want)
+	}
+	early := time.Date(2026, 10, 16, 8, 0, 0, 0, market.KST)
+	if got, want := nextRun(early, at, nil), time.Date(2026, 10, 16, 8, 30, 0, 0, market.KST); !got.Equal(want) {
+		t.Errorf("nextRun = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,170 @@
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// PassphraseEnv holds the passphrase for the encrypted credentials file when no
+// keyfile is configured.
+const PassphraseEnv = "TRADINGBOT_CREDENTIALS_PASSPHRASE"
+
+const (
+	fileHeader     = "tradingbot-credentials-v1\n"
+	saltSize       = 16
+	kdfIterations  = 200000
+	encryptKeySize = 32
+)
+
+// File reads credentials from a local file encrypted with Encrypt.
+type File struct {
+	Path       string
+	Passphrase []byte
+}
+
+func (f *File) Fetch(ctx context.Context) (map[string]string, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %v", err)
+	}
+	return Decrypt(data, f.Passphrase)
+}
+
+// Encrypt seals the credentials with AES-256-GCM under a key derived from the
+// passphrase, returning the contents of a credentials file.
+func Encrypt(values map[string]string, passphrase []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credentials: %v", err)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	sealed := append(salt, nonce...)
+	sealed = gcm.Seal(sealed, nonce, plaintext, []byte(fileHeader))
+	return []byte(fileHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// Decrypt opens a credentials file produced by Encrypt.
+func Decrypt(data, passphrase []byte) (map[string]string, error) {
+	text := string(data)
+	if !strings.HasPrefix(text, fileHeader) {
+		return nil, fmt.Errorf("not a tradingbot credentials file")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(text, fileHeader)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode credentials file: %v", err)
+	}
+	if len(sealed) < saltSize {
+		return nil, fmt.Errorf("credentials file is truncated")
+	}
+
+	salt := sealed[:saltSize]
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < saltSize+gcm.NonceSize() {
+		return nil, fmt.Errorf("credentials file is truncated")
+	}
+	nonce := sealed[saltSize : saltSize+gcm.NonceSize()]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed[saltSize+gcm.NonceSize():], []byte(fileHeader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: wrong passphrase or corrupted file")
+	}
+	return decodePayload(plaintext)
+}
+
+// ReadPassphrase returns the passphrase from keyfile when set, otherwise from
+// PassphraseEnv, otherwise by prompting for a line on in.
+func ReadPassphrase(keyfile string, in *bufio.Reader) ([]byte, error) {
+	if keyfile != "" {
+		key, err := ioutil.ReadFile(keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keyfile: %v", err)
+		}
+		return []byte(strings.TrimSpace(string(key))), nil
+	}
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	passphrase, err := Prompt(in, "Credentials passphrase")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	return []byte(passphrase), nil
+}
+
+// Prompt prints label on stderr and reads one line from in.
+func Prompt(in *bufio.Reader, label string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func newGCM(passphrase, salt []byte) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+	block, err := aes.NewCipher(pbkdf2SHA256(passphrase, salt, kdfIterations, encryptKeySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key from the passphrase as specified in RFC 8018.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	var key []byte
+	buf := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
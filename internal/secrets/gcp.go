@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCP reads credentials from the latest version of a Google Secret Manager secret.
+// It authenticates with GOOGLE_OAUTH_ACCESS_TOKEN when set, otherwise with the
+// instance's service account through the metadata server.
+type GCP struct {
+	Project string
+	Secret  string
+
+	client *http.Client
+}
+
+func (g *GCP) Fetch(ctx context.Context) (map[string]string, error) {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://secretmanager.googleapis.com/v1/projects/%s/secrets/%s/versions/latest:access", g.Project, g.Secret)
+	var result struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := g.getJSON(ctx, url, map[string]string{"Authorization": "Bearer " + token}, &result); err != nil {
+		return nil, fmt.Errorf("failed to access gcp secret: %v", err)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(result.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode gcp secret payload: %v", err)
+	}
+	return decodePayload(payload)
+}
+
+func (g *GCP) accessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := g.getJSON(ctx, gcpMetadataTokenURL, map[string]string{"Metadata-Flavor": "Google"}, &result); err != nil {
+		return "", fmt.Errorf("failed to get gcp access token: %v", err)
+	}
+	return result.AccessToken, nil
+}
+
+func (g *GCP) getJSON(ctx context.Context, url string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,140 @@
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+	"tradingbot/internal/config"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Keys expected in the secret payload. The payload is a JSON object; keys that are
+// missing leave the corresponding setting as loaded from the environment.
+const (
+	KeyAppKey       = "app_key"
+	KeyAppSecret    = "app_secret"
+	KeyDBPassword   = "db_password"
+	KeyAPIToken     = "api_token"
+	KeyAPIJWTSecret = "api_jwt_secret"
+	// KeyAPIKeyPrefix followed by the name of one of api.keys sets its key.
+	KeyAPIKeyPrefix = "api_key_"
+)
+
+// Provider fetches the bot's credentials from an external secrets store.
+type Provider interface {
+	Fetch(ctx context.Context) (map[string]string, error)
+}
+
+// New returns the provider selected in the config, or nil when secrets come from
+// the environment / .env file.
+func New(cfg config.SecretsConfig) (Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	switch cfg.Provider {
+	case "", config.SecretsProviderEnv:
+		return nil, nil
+	case config.SecretsProviderVault:
+		return &Vault{Address: cfg.Vault.Address, Path: cfg.Vault.Path, Token: cfg.Vault.Token, client: client}, nil
+	case config.SecretsProviderAWS:
+		return &AWS{Region: cfg.AWS.Region, SecretID: cfg.AWS.SecretID, client: client}, nil
+	case config.SecretsProviderGCP:
+		return &GCP{Project: cfg.GCP.Project, Secret: cfg.GCP.Secret, client: client}, nil
+	case config.SecretsProviderFile:
+		passphrase, err := ReadPassphrase(cfg.File.Keyfile, bufio.NewReader(os.Stdin))
+		if err != nil {
+			return nil, err
+		}
+		return &File{Path: cfg.File.Path, Passphrase: passphrase}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider: %s", cfg.Provider)
+	}
+}
+
+// Apply copies the fetched credentials into the config.
+func Apply(cfg *config.Config, values map[string]string) error {
+	if v, ok := values[KeyAppKey]; ok {
+		cfg.Exchange.AppKey = v
+	}
+	if v, ok := values[KeyAppSecret]; ok {
+		cfg.Exchange.AppSecret = v
+	}
+	if v, ok := values[KeyAPIToken]; ok {
+		cfg.API.Token = v
+	}
+	if v, ok := values[KeyAPIJWTSecret]; ok {
+		cfg.API.JWT.Secret = v
+	}
+	for i, k := range cfg.API.Keys {
+		if v, ok := values[KeyAPIKeyPrefix+k.Name]; ok {
+			cfg.API.Keys[i].Key = v
+		}
+	}
+	if v, ok := values[KeyDBPassword]; ok {
+		dsn, err := mysql.ParseDSN(cfg.DatabaseURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse database url: %v", err)
+		}
+		dsn.Passwd = v
+		cfg.DatabaseURL = dsn.FormatDSN()
+	}
+	return nil
+}
+
+// Watch polls the provider every interval and delivers the credentials whenever
+// they differ from the previous fetch, so rotated secrets are picked up without a
+// restart. Fetch errors are delivered too; the channel closes when ctx is done.
+func Watch(ctx context.Context, p Provider, initial map[string]string, interval time.Duration) <-chan Update {
+	updates := make(chan Update, 1)
+
+	go func() {
+		defer close(updates)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		current := initial
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			values, err := p.Fetch(ctx)
+			if err == nil && reflect.DeepEqual(values, current) {
+				continue
+			}
+			if err == nil {
+				current = values
+			}
+
+			select {
+			case updates <- Update{Values: values, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates
+}
+
+// Update is delivered by Watch when the stored credentials change or cannot be fetched.
+type Update struct {
+	Values map[string]string
+	Err    error
+}
+
+func decodePayload(payload []byte) (map[string]string, error) {
+	var values map[string]string
+	if err := json.Unmarshal(payload, &values); err != nil {
+		return nil, fmt.Errorf("secret payload must be a JSON object of strings: %v", err)
+	}
+	return values, nil
+}
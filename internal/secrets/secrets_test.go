@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"tradingbot/internal/config"
+)
+
+func TestVaultFetchAndApply(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/tradingbot" || r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"app_key":"key","app_secret":"secret","db_password":"pw"}}}`))
+	}))
+	defer server.Close()
+
+	var sc config.SecretsConfig
+	sc.Provider = config.SecretsProviderVault
+	sc.Vault.Address = server.URL
+	sc.Vault.Path = "secret/data/tradingbot"
+	sc.Vault.Token = "root"
+
+	provider, err := New(sc)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	values, err := provider.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	cfg := &config.Config{DatabaseURL: "root:old@tcp(localhost:3306)/tradingbot"}
+	if err := Apply(cfg, values); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if cfg.Exchange.AppKey != "key" || cfg.Exchange.AppSecret != "secret" {
+		t.Errorf("credentials not applied: %+v", cfg.Exchange)
+	}
+	if want := "root:pw@tcp(localhost:3306)/tradingbot"; cfg.DatabaseURL != want {
+		t.Errorf("DatabaseURL = %q, want %q", cfg.DatabaseURL, want)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	values := map[string]string{KeyAppKey: "key", KeyAppSecret: "secret"}
+
+	data, err := Encrypt(values, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("credentials file contains plaintext: %s", data)
+	}
+
+	got, err := Decrypt(data, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("Decrypt = %v, want %v", got, values)
+	}
+
+	if _, err := Decrypt(data, []byte("wrong")); err == nil {
+		t.Errorf("expected error for wrong passphrase")
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11 test vector.
+	got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != want {
+		t.Errorf("pbkdf2SHA256 = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Vault reads credentials from a HashiCorp Vault KV v2 secret.
+type Vault struct {
+	Address string
+	Path    string // e.g. secret/data/tradingbot
+	Token   string // falls back to VAULT_TOKEN when empty
+
+	client *http.Client
+}
+
+func (v *Vault) Fetch(ctx context.Context) (map[string]string, error) {
+	token := v.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimRight(v.Address, "/"), strings.TrimLeft(v.Path, "/"))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status code: %d, body: %s", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse vault response: %v", err)
+	}
+	return result.Data.Data, nil
+}
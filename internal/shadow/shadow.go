@@ -0,0 +1,247 @@
+package shadow
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/engine"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+	"tradingbot/internal/paper"
+	"tradingbot/internal/rules"
+	"tradingbot/internal/strategy"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const (
+	defaultCapital = 10000000
+	// buffer is the number of live events queued for the paper books, so
+	// they never hold up trading.
+	buffer = 1000
+)
+
+// Roles of the compared strategies.
+const (
+	RoleIncumbent = "incumbent"
+	RoleCandidate = "candidate"
+)
+
+// Book is the paper performance of one of the compared strategies. Trades
+// counts the closed trades, a sell of a held position closing one, and
+// WinRate is the fraction of them closed above their average cost.
+type Book struct {
+	Strategy    string  `json:"strategy"`
+	Role        string  `json:"role"`
+	Capital     float64 `json:"capital"`
+	Equity      float64 `json:"equity"`
+	Return      float64 `json:"return"`
+	Orders      int     `json:"orders"`
+	Trades      int     `json:"trades"`
+	WinRate     float64 `json:"win_rate"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+}
+
+// Report compares the incumbent and candidate books since Since.
+type Report struct {
+	Since time.Time `json:"since"`
+	Books []Book    `json:"books"`
+}
+
+// discardStore stands in for the database; the paper exchange keeps the
+// orders.
+type discardStore struct{}
+
+func (discardStore) SaveOrder(order *models.Order) error { return nil }
+
+type book struct {
+	strategy   string
+	role       string
+	capital    float64
+	strategies map[string]strategy.Strategy
+	exch       *paper.Exchange
+	eng        *engine.Engine
+
+	peak, maxDrawdown float64
+}
+
+// Runner trades the traded strategy and the candidate of config.ShadowConfig
+// on paper, on the prices the live engine polls. It is safe for concurrent
+// use.
+type Runner struct {
+	mu    sync.Mutex
+	since time.Time
+	books []*book
+
+	subscribe sync.Once
+	events    <-chan events.Event
+}
+
+// New creates paper books for the traded strategy and the shadow candidate,
+// with fresh strategy state for every traded symbol.
+func New(cfg *config.Config) (*Runner, error) {
+	capital := cfg.Shadow.Capital
+	if capital == 0 {
+		capital = defaultCapital
+	}
+	r := &Runner{since: time.Now()}
+	for _, b := range []struct{ name, role string }{
+		{cfg.Strategy, RoleIncumbent},
+		{cfg.Shadow.Strategy, RoleCandidate},
+	} {
+		params, err := cfg.StrategyParamsFor(b.name)
+		if err != nil {
+			return nil, err
+		}
+		strategies := make(map[string]strategy.Strategy)
+		for _, symbol := range cfg.TradingSymbols() {
+			if strategies[symbol], err = strategy.New(b.name, params); err != nil {
+				return nil, fmt.Errorf("shadow %s: %v", b.role, err)
+			}
+		}
+		c := *cfg
+		c.Strategy = b.name
+		exch := paper.New(capital, cfg.Fees.CommissionRate, clock.Real)
+		eng := engine.New(&c, exch, discardStore{}, strategies)
+		if len(cfg.SignalRules) > 0 {
+			eng.SetRules(rules.New(cfg.SignalRules))
+		}
+		r.books = append(r.books, &book{strategy: b.name, role: b.role, capital: capital, strategies: strategies, exch: exch, eng: eng, peak: capital})
+	}
+	return r, nil
+}
+
+// Restore starts the books of the strategy of snap from its state, e.g. the
+// live strategies' at startup, so that they are compared on the same history
+// instead of the incumbent trading on one the candidate has to build first.
+// Books of other strategies keep fresh state.
+func (r *Runner) Restore(snap strategy.Snapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.books {
+		if b.strategy != snap.Strategy {
+			continue
+		}
+		if _, err := snap.Restore(b.strategy, b.strategies); err != nil {
+			return fmt.Errorf("shadow %s: %v", b.role, err)
+		}
+	}
+	return nil
+}
+
+// Run feeds the market data published on live to the paper books until ctx is
+// cancelled. Run may be called again after it returned, e.g. to restart it
+// after a panic; it then carries on with the market data published since.
+func (r *Runner) Run(ctx context.Context, live *events.Bus) {
+	r.subscribe.Do(func() { r.events = live.Channel(buffer, events.KindMarketData) })
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-r.events:
+			r.Observe(ev.(events.MarketDataEvent))
+		}
+	}
+}
+
+// Observe runs both books on a live price observation.
+func (r *Runner) Observe(md events.MarketDataEvent) {
+	price, err := strconv.ParseFloat(md.Data.StckPrpr, 64)
+	if err != nil || price <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.books {
+		b.exch.SetPrice(md.Symbol, price)
+		b.eng.Bus.Publish(md)
+		equity := b.exch.Equity()
+		if equity > b.peak {
+			b.peak = equity
+		}
+		if dd := (b.peak - equity) / b.peak; dd > b.maxDrawdown {
+			b.maxDrawdown = dd
+		}
+	}
+}
+
+// Report returns the performance of both books so far, incumbent first.
+func (r *Runner) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := Report{Since: r.since}
+	for _, b := range r.books {
+		orders := b.exch.Orders()
+		trades, wins := closedTrades(orders)
+		out := Book{
+			Strategy:    b.strategy,
+			Role:        b.role,
+			Capital:     b.capital,
+			Equity:      b.exch.Equity(),
+			Orders:      len(orders),
+			Trades:      trades,
+			MaxDrawdown: b.maxDrawdown,
+		}
+		out.Return = out.Equity/out.Capital - 1
+		if trades > 0 {
+			out.WinRate = float64(wins) / float64(trades)
+		}
+		report.Books = append(report.Books, out)
+	}
+	return report
+}
+
+// Log writes the report to the log, e.g. at shutdown.
+func (r *Runner) Log() {
+	for _, b := range r.Report().Books {
+		log.WithFields(logrus.Fields{
+			"strategy":     b.Strategy,
+			"role":         b.Role,
+			"equity":       math.Round(b.Equity),
+			"return":       b.Return * 100,
+			"trades":       b.Trades,
+			"win_rate":     b.WinRate * 100,
+			"max_drawdown": b.MaxDrawdown * 100,
+		}).Info("Shadow trading results")
+	}
+}
+
+// closedTrades counts the sells of held positions among orders and those
+// above the position's average cost.
+func closedTrades(orders []models.Order) (trades, wins int) {
+	type holding struct{ quantity, cost float64 }
+	held := map[string]*holding{}
+	for _, o := range orders {
+		h := held[o.Pair]
+		if h == nil {
+			h = &holding{}
+			held[o.Pair] = h
+		}
+		switch o.Side {
+		case models.OrderSideBuy:
+			h.quantity += o.Amount
+			h.cost += o.Amount * o.Price
+		case models.OrderSideSell:
+			if h.quantity <= 0 {
+				continue
+			}
+			avg := h.cost / h.quantity
+			trades++
+			if o.Price > avg {
+				wins++
+			}
+			sold := math.Min(o.Amount, h.quantity)
+			h.cost -= sold * avg
+			h.quantity -= sold
+		}
+	}
+	return trades, wins
+}
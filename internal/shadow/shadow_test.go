@@ -0,0 +1,87 @@
+package shadow
+
+import (
+	"math"
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+	"tradingbot/internal/strategy"
+)
+
+func TestRunnerComparesBooks(t *testing.T) {
+	cfg := &config.Config{
+		TradingPair: "069500",
+		Strategy:    "moving_average",
+		Strategies: map[string]config.StrategyParams{
+			"moving_average": {"short_period": 1, "long_period": 2},
+			"nav_deviation":  {"entry_discount": 0.05, "exit_premium": 0.0},
+		},
+		Position: config.PositionConfig{TargetQuantity: 10},
+		Shadow:   config.ShadowConfig{Enabled: true, Strategy: "nav_deviation"},
+	}
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)
+	for i, price := range []string{"100", "110", "120", "100"} {
+		r.Observe(events.MarketDataEvent{
+			Symbol: "069500",
+			Data:   &models.MarketData{StckPrpr: price, Nav: "110"},
+			Time:   start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	report := r.Report()
+	if len(report.Books) != 2 {
+		t.Fatalf("%d books, want 2", len(report.Books))
+	}
+	// The crossover buys at 110 and sells at 100; the NAV strategy buys at
+	// the 9% discount, sells at NAV and buys again.
+	want := []Book{
+		{Strategy: "moving_average", Role: RoleIncumbent, Equity: 9999900, Orders: 2, Trades: 1, WinRate: 0},
+		{Strategy: "nav_deviation", Role: RoleCandidate, Equity: 10000100, Orders: 3, Trades: 1, WinRate: 1},
+	}
+	for i, w := range want {
+		b := report.Books[i]
+		if b.Strategy != w.Strategy || b.Role != w.Role || math.Abs(b.Equity-w.Equity) > 1e-6 ||
+			b.Orders != w.Orders || b.Trades != w.Trades || b.WinRate != w.WinRate {
+			t.Errorf("book %d: %+v, want %+v", i, b, w)
+		}
+	}
+	if dd := report.Books[0].MaxDrawdown; dd <= 0 {
+		t.Errorf("incumbent max drawdown %v, want the loss of its trade", dd)
+	}
+}
+
+func TestRunnerRestoresLiveState(t *testing.T) {
+	cfg := &config.Config{
+		TradingPair: "069500",
+		Strategy:    "moving_average",
+		Strategies: map[string]config.StrategyParams{
+			"moving_average": {"short_period": 1, "long_period": 2},
+			"nav_deviation":  {"entry_discount": 0.05, "exit_premium": 0.0},
+		},
+		Position: config.PositionConfig{TargetQuantity: 10},
+		Shadow:   config.ShadowConfig{Enabled: true, Strategy: "nav_deviation"},
+	}
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := strategy.NewMovingAverage(models.MovingAverageConfig{ShortPeriod: 1, LongPeriod: 2})
+	live.Analyze(&models.MarketData{StckPrpr: "100"})
+	live.Analyze(&models.MarketData{StckPrpr: "100"})
+	if err := r.Restore(strategy.TakeSnapshot("moving_average", map[string]strategy.Strategy{"069500": live}, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Observe(events.MarketDataEvent{Symbol: "069500", Data: &models.MarketData{StckPrpr: "110"}, Time: time.Now()})
+	// With the live history the first price already crosses over.
+	if orders := r.Report().Books[0].Orders; orders != 1 {
+		t.Errorf("incumbent placed %d orders, want 1", orders)
+	}
+}
@@ -0,0 +1,205 @@
+package sizing
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+const (
+	defaultFraction  = 0.5
+	defaultCap       = 0.2
+	defaultLookback  = 50
+	defaultMinTrades = 20
+)
+
+// Edge is the trade statistics a Kelly fraction is estimated from. AvgWin and
+// AvgLoss are the average return in percent of the winning trades and the
+// average loss in percent of the others.
+type Edge struct {
+	Trades  int
+	WinRate float64
+	AvgWin  float64
+	AvgLoss float64
+}
+
+// EdgeFromRun reads the edge from the metrics of a stored backtest run.
+func EdgeFromRun(run *models.BacktestRun) Edge {
+	return Edge{
+		Trades:  int(run.Metrics["total_trades"]),
+		WinRate: run.Metrics["win_rate"],
+		AvgWin:  run.Metrics["avg_win"],
+		AvgLoss: run.Metrics["avg_loss"],
+	}
+}
+
+// Kelly returns the full Kelly fraction W - (1-W)/R of the edge, where R is
+// AvgWin over AvgLoss, or zero when it is negative. An edge without losses
+// returns its win rate.
+func (e Edge) Kelly() float64 {
+	if e.AvgWin <= 0 {
+		return 0
+	}
+	if e.AvgLoss <= 0 {
+		return e.WinRate
+	}
+	return math.Max(0, e.WinRate-(1-e.WinRate)*e.AvgLoss/e.AvgWin)
+}
+
+type key struct{ strategy, symbol string }
+
+type position struct {
+	quantity, avgPrice float64
+}
+
+// Kelly sizes the buys of the configured strategies by fractional Kelly; see
+// config.KellyConfig. It follows their orders to estimate their edge from
+// their recent closed trades, a sell of a held position closing a trade, and
+// falls back to their backtests. Kelly is safe for concurrent use.
+type Kelly struct {
+	cfg             config.KellyConfig
+	defaultStrategy string
+	selected        map[string]bool
+	baselines       map[string]Edge
+
+	mu        sync.Mutex
+	positions map[key]*position
+	returns   map[string][]float64
+}
+
+// NewKelly creates a sizer for the strategies listed in cfg, starting from
+// their backtested edge in baselines. Orders without a strategy belong to
+// defaultStrategy.
+func NewKelly(cfg config.KellyConfig, defaultStrategy string, baselines map[string]Edge) *Kelly {
+	if cfg.Fraction == 0 {
+		cfg.Fraction = defaultFraction
+	}
+	if cfg.Cap == 0 {
+		cfg.Cap = defaultCap
+	}
+	if cfg.Lookback == 0 {
+		cfg.Lookback = defaultLookback
+	}
+	if cfg.MinTrades == 0 {
+		cfg.MinTrades = defaultMinTrades
+	}
+	if cfg.MinTrades > cfg.Lookback {
+		cfg.MinTrades = cfg.Lookback
+	}
+	k := &Kelly{
+		cfg:             cfg,
+		defaultStrategy: defaultStrategy,
+		selected:        map[string]bool{},
+		baselines:       baselines,
+		positions:       map[key]*position{},
+		returns:         map[string][]float64{},
+	}
+	for _, name := range cfg.Strategies {
+		k.selected[name] = true
+	}
+	return k
+}
+
+// Subscribe follows the orders placed for strategy signals on bus.
+func (k *Kelly) Subscribe(bus *events.Bus) {
+	bus.Subscribe(k.record, events.KindDecision)
+}
+
+// Fraction returns the fraction of its equity strategy buys a position with
+// and the edge it is based on. ok is false when strategy is not sized by Kelly
+// or its edge is not known yet, and the position settings apply.
+func (k *Kelly) Fraction(strategy string) (fraction float64, edge Edge, ok bool) {
+	if strategy == "" {
+		strategy = k.defaultStrategy
+	}
+	if !k.selected[strategy] {
+		return 0, Edge{}, false
+	}
+	k.mu.Lock()
+	edge = edgeOf(k.returns[strategy])
+	k.mu.Unlock()
+	if edge.Trades < k.cfg.MinTrades {
+		base, found := k.baselines[strategy]
+		if !found || base.Trades == 0 {
+			return 0, edge, false
+		}
+		edge = base
+	}
+	return math.Min(k.cfg.Fraction*edge.Kelly(), k.cfg.Cap), edge, true
+}
+
+func (k *Kelly) record(ev events.Event) {
+	d := ev.(events.DecisionEvent)
+	if d.Action != events.ActionOrdered || d.Source != "strategy" {
+		return
+	}
+	name := d.Signal.Strategy
+	if name == "" {
+		name = k.defaultStrategy
+	}
+	if !k.selected[name] {
+		return
+	}
+	quantity, price := d.Order.Amount, d.Order.Price
+	if quantity == 0 {
+		quantity = d.Signal.Amount
+	}
+	if price == 0 && d.MarketData != nil {
+		price, _ = strconv.ParseFloat(d.MarketData.StckPrpr, 64)
+	}
+	if quantity <= 0 || price <= 0 {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	ky := key{name, d.Symbol}
+	p := k.positions[ky]
+	if p == nil {
+		p = &position{}
+		k.positions[ky] = p
+	}
+	switch d.Signal.Type {
+	case models.BuySignal:
+		p.avgPrice = (p.avgPrice*p.quantity + price*quantity) / (p.quantity + quantity)
+		p.quantity += quantity
+	case models.SellSignal:
+		if p.quantity > 0 {
+			returns := append(k.returns[name], (price-p.avgPrice)/p.avgPrice*100)
+			if len(returns) > k.cfg.Lookback {
+				returns = returns[len(returns)-k.cfg.Lookback:]
+			}
+			k.returns[name] = returns
+			p.quantity -= quantity
+			if p.quantity <= 0 {
+				*p = position{}
+			}
+		}
+	}
+}
+
+func edgeOf(returns []float64) Edge {
+	e := Edge{Trades: len(returns)}
+	wins := 0
+	for _, r := range returns {
+		if r > 0 {
+			wins++
+			e.AvgWin += r
+		} else {
+			e.AvgLoss -= r
+		}
+	}
+	if wins > 0 {
+		e.AvgWin /= float64(wins)
+	}
+	if losses := e.Trades - wins; losses > 0 {
+		e.AvgLoss /= float64(losses)
+	}
+	if e.Trades > 0 {
+		e.WinRate = float64(wins) / float64(e.Trades)
+	}
+	return e
+}
@@ -0,0 +1,61 @@
+package sizing
+
+import (
+	"math"
+	"testing"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+func TestEdgeKelly(t *testing.T) {
+	tests := []struct {
+		edge Edge
+		want float64
+	}{
+		{Edge{WinRate: 0.6, AvgWin: 2, AvgLoss: 1}, 0.4},
+		{Edge{WinRate: 0.5, AvgWin: 1, AvgLoss: 1}, 0},
+		{Edge{WinRate: 0.3, AvgWin: 1, AvgLoss: 2}, 0},
+		{Edge{WinRate: 1, AvgWin: 3}, 1},
+		{Edge{WinRate: 0, AvgLoss: 3}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.edge.Kelly(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Kelly of %+v = %v, want %v", tt.edge, got, tt.want)
+		}
+	}
+}
+
+func TestKellyFallsBackToBacktestUntilEnoughTrades(t *testing.T) {
+	cfg := config.KellyConfig{Strategies: []string{"moving_average"}, Fraction: 0.5, Cap: 0.15, Lookback: 4, MinTrades: 2}
+	k := NewKelly(cfg, "moving_average", map[string]Edge{"moving_average": {Trades: 40, WinRate: 0.6, AvgWin: 2, AvgLoss: 1}})
+	bus := events.NewBus()
+	k.Subscribe(bus)
+
+	if _, _, ok := k.Fraction("other"); ok {
+		t.Error("strategy not listed sized by Kelly")
+	}
+	// Half of the backtested Kelly fraction of 0.4, capped.
+	if f, edge, ok := k.Fraction(""); !ok || f != 0.15 || edge.Trades != 40 {
+		t.Fatalf("fraction %v of %+v, %v; want 0.15 from the backtest", f, edge, ok)
+	}
+
+	trade := func(signal models.SignalType, price float64) {
+		bus.Publish(events.DecisionEvent{
+			Symbol: "005930",
+			Source: "strategy",
+			Action: events.ActionOrdered,
+			Signal: &models.Signal{Type: signal, Amount: 1},
+			Order:  &models.Order{Amount: 1, Price: price},
+		})
+	}
+	// A 10% win and a 5% loss: W 0.5, R 2, Kelly 0.25.
+	trade(models.BuySignal, 100)
+	trade(models.SellSignal, 110)
+	trade(models.BuySignal, 100)
+	trade(models.SellSignal, 95)
+	f, edge, ok := k.Fraction("moving_average")
+	if !ok || edge.Trades != 2 || math.Abs(f-0.125) > 1e-9 {
+		t.Errorf("fraction %v of %+v, %v; want 0.125 from 2 live trades", f, edge, ok)
+	}
+}
@@ -0,0 +1,190 @@
+package sizing
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/screener"
+)
+
+const defaultADVDays = 20
+
+// Volumes is the source of the daily candles the average daily volume is
+// taken from, e.g. the daily_candles table of the database.
+type Volumes interface {
+	GetDailyCandles(stockCode string, days int) ([]candle.Candle, error)
+}
+
+// Liquidity limits the shares of a symbol ordered in a day to a fraction of
+// its average daily volume (ADV); see config.LiquidityConfig. It follows the
+// orders on the bus to count what was ordered in the day. Liquidity is safe
+// for concurrent use.
+type Liquidity struct {
+	mu      sync.Mutex
+	cfg     config.LiquidityConfig
+	volumes Volumes
+	day     string
+	// adv caches the average daily volume of each symbol for the day; it does
+	// not change until the next day's candle is stored.
+	adv     map[string]adv
+	ordered map[string]float64
+}
+
+// NewLiquidity creates a limit on the orders of the average daily volume in
+// volumes.
+func NewLiquidity(cfg config.LiquidityConfig, volumes Volumes) *Liquidity {
+	l := &Liquidity{volumes: volumes, adv: map[string]adv{}, ordered: map[string]float64{}}
+	l.SetConfig(cfg)
+	return l
+}
+
+// SetConfig replaces the configuration, e.g. on a reload. The orders counted
+// in the day are kept.
+func (l *Liquidity) SetConfig(cfg config.LiquidityConfig) {
+	if cfg.ADVDays == 0 {
+		cfg.ADVDays = defaultADVDays
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cfg.ADVDays != l.cfg.ADVDays {
+		l.adv = map[string]adv{}
+	}
+	l.cfg = cfg
+}
+
+// adv is an average daily volume over days days; days is zero for a symbol
+// without daily candles.
+type adv struct {
+	volume float64
+	days   int
+}
+
+// SetVolumes replaces the source of the daily candles, e.g. after a database
+// reconnect.
+func (l *Liquidity) SetVolumes(volumes Volumes) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.volumes = volumes
+}
+
+// Subscribe follows the orders placed on bus.
+func (l *Liquidity) Subscribe(bus *events.Bus) {
+	bus.Subscribe(func(ev events.Event) {
+		oe := ev.(events.OrderEvent)
+		l.record(oe.Order, oe.Time)
+	}, events.KindOrder)
+}
+
+// Restore counts orders placed earlier, e.g. those of the day stored before
+// a restart. Orders of other days than the latest are ignored.
+func (l *Liquidity) Restore(orders []models.Order) {
+	for i := range orders {
+		l.record(&orders[i], orders[i].Timestamp)
+	}
+}
+
+func (l *Liquidity) record(order *models.Order, at time.Time) {
+	if order.Amount <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.today(at) {
+		return
+	}
+	l.ordered[order.Pair] += order.Amount
+}
+
+// today moves the counts on to the KST day of at, and reports false for times
+// of a day already past. l.mu must be held.
+func (l *Liquidity) today(at time.Time) bool {
+	day := at.In(market.KST).Format("2006-01-02")
+	switch {
+	case day < l.day:
+		return false
+	case day > l.day:
+		l.day = day
+		l.adv = map[string]adv{}
+		l.ordered = map[string]float64{}
+	}
+	return true
+}
+
+// Limit is the room left for the orders of a symbol in a day.
+type Limit struct {
+	// ADV is the average daily volume of the Days days before, fewer than
+	// configured for recent listings.
+	ADV  float64
+	Days int
+	// Max is the shares that may be ordered in the day, Ordered those that
+	// were already.
+	Max, Ordered float64
+}
+
+// Room returns the shares that may still be ordered.
+func (l Limit) Room() float64 {
+	if l.Ordered >= l.Max {
+		return 0
+	}
+	return l.Max - l.Ordered
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%g of %g shares left, ADV %.0f over %d days", l.Room(), l.Max, l.ADV, l.Days)
+}
+
+// Limit returns the limit of symbol on the day of at. ok is false when the
+// symbol has no daily candles before that day and is not limited.
+func (l *Liquidity) Limit(symbol string, at time.Time) (limit Limit, ok bool, err error) {
+	l.mu.Lock()
+	l.today(at)
+	avg, cached := l.adv[symbol]
+	l.mu.Unlock()
+
+	if !cached {
+		if avg, err = l.average(symbol, at); err != nil {
+			return Limit{}, false, err
+		}
+		l.mu.Lock()
+		if l.today(at) {
+			l.adv[symbol] = avg
+		}
+		l.mu.Unlock()
+	}
+	if avg.days == 0 {
+		return Limit{}, false, nil
+	}
+
+	l.mu.Lock()
+	ordered := l.ordered[symbol]
+	fraction := l.cfg.MaxADVFraction
+	l.mu.Unlock()
+	return Limit{ADV: avg.volume, Days: avg.days, Max: fraction * avg.volume, Ordered: ordered}, true, nil
+}
+
+// average returns the average daily volume of symbol over the configured
+// days before the day of at. The candle of that day, when already stored, is
+// left out, so one more is fetched to make up for it.
+func (l *Liquidity) average(symbol string, at time.Time) (adv, error) {
+	l.mu.Lock()
+	volumes, days := l.volumes, l.cfg.ADVDays
+	l.mu.Unlock()
+	candles, err := volumes.GetDailyCandles(symbol, days+1)
+	if err != nil {
+		return adv{}, fmt.Errorf("failed to get daily candles: %w", err)
+	}
+	kst := at.In(market.KST)
+	start := time.Date(kst.Year(), kst.Month(), kst.Day(), 0, 0, 0, 0, market.KST)
+	for len(candles) > 0 && !candles[len(candles)-1].Start.Before(start) {
+		candles = candles[:len(candles)-1]
+	}
+	if len(candles) > days {
+		candles = candles[len(candles)-days:]
+	}
+	return adv{volume: screener.AvgVolume(candles, len(candles)), days: len(candles)}, nil
+}
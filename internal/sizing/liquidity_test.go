@@ -0,0 +1,108 @@
+package sizing
+
+import (
+	"testing"
+	"time"
+	"tradingbot/internal/candle"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+// dailyVolumes serves daily candles of the given volumes, the last one on
+// last.
+type dailyVolumes struct {
+	last    time.Time
+	volumes map[string][]float64
+	calls   int
+}
+
+func (d *dailyVolumes) GetDailyCandles(stockCode string, days int) ([]candle.Candle, error) {
+	d.calls++
+	volumes := d.volumes[stockCode]
+	if len(volumes) > days {
+		volumes = volumes[len(volumes)-days:]
+	}
+	candles := make([]candle.Candle, len(volumes))
+	for i, v := range volumes {
+		candles[i] = candle.Candle{Symbol: stockCode, Start: d.last.AddDate(0, 0, i-len(volumes)+1), Volume: v}
+	}
+	return candles, nil
+}
+
+func TestLiquidityLimitsDailyOrders(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	volumes := &dailyVolumes{last: time.Date(2026, 10, 16, 0, 0, 0, 0, market.KST), volumes: map[string][]float64{
+		// Today's partial candle is left out of the average.
+		"035720": {9000, 1000, 3000, 2000, 50},
+		"900100": {},
+	}}
+	l := NewLiquidity(config.LiquidityConfig{MaxADVFraction: 0.05, ADVDays: 3}, volumes)
+	bus := events.NewBus()
+	l.Subscribe(bus)
+
+	limit, ok, err := l.Limit("035720", now)
+	if err != nil || !ok {
+		t.Fatalf("Limit = %v, %v", ok, err)
+	}
+	if limit.ADV != 2000 || limit.Days != 3 || limit.Max != 100 || limit.Room() != 100 {
+		t.Errorf("limit %+v, want ADV 2000 over 3 days and room for 100", limit)
+	}
+
+	bus.Publish(events.OrderEvent{Order: &models.Order{Pair: "035720", Amount: 60}, Time: now})
+	bus.Publish(events.OrderEvent{Order: &models.Order{Pair: "035720", Amount: 50}, Time: now.Add(time.Hour)})
+	if limit, _, _ = l.Limit("035720", now.Add(time.Hour)); limit.Ordered != 110 || limit.Room() != 0 {
+		t.Errorf("after 110 shares: %+v", limit)
+	}
+	if volumes.calls != 1 {
+		t.Errorf("daily candles fetched %d times in a day", volumes.calls)
+	}
+
+	// The next day starts over, with the complete candle of the day before.
+	volumes.volumes["035720"] = append(volumes.volumes["035720"][:4], 4000)
+	if limit, _, _ = l.Limit("035720", now.AddDate(0, 0, 1)); limit.Ordered != 0 || limit.ADV != 3000 {
+		t.Errorf("next day: %+v", limit)
+	}
+	// Orders of the day before no longer count.
+	l.Restore([]models.Order{{Pair: "035720", Amount: 10, Timestamp: now}})
+	if limit, _, _ = l.Limit("035720", now.AddDate(0, 0, 1)); limit.Ordered != 0 {
+		t.Errorf("order of the day before counted: %+v", limit)
+	}
+
+	if _, ok, err := l.Limit("900100", now); ok || err != nil {
+		t.Errorf("symbol without candles limited: %v, %v", ok, err)
+	}
+}
+
+func TestLiquiditySetVolumes(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	last := time.Date(2026, 10, 15, 0, 0, 0, 0, market.KST)
+	old := &dailyVolumes{last: last, volumes: map[string][]float64{"035720": {1000}}}
+	l := NewLiquidity(config.LiquidityConfig{MaxADVFraction: 0.1, ADVDays: 1}, old)
+
+	reconnected := &dailyVolumes{last: last, volumes: map[string][]float64{"035720": {1000}, "005930": {2000}}}
+	l.SetVolumes(reconnected)
+	if limit, ok, err := l.Limit("005930", now); err != nil || !ok || limit.Max != 200 {
+		t.Errorf("Limit = %+v, %v, %v", limit, ok, err)
+	}
+	if old.calls != 0 || reconnected.calls != 1 {
+		t.Errorf("fetched %d times from the old source, %d from the new", old.calls, reconnected.calls)
+	}
+}
+
+func TestLiquiditySetConfig(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, market.KST)
+	volumes := &dailyVolumes{last: time.Date(2026, 10, 15, 0, 0, 0, 0, market.KST), volumes: map[string][]float64{"035720": {4000, 1000, 3000}}}
+	l := NewLiquidity(config.LiquidityConfig{MaxADVFraction: 0.05, ADVDays: 3}, volumes)
+	l.Restore([]models.Order{{Pair: "035720", Amount: 10, Timestamp: now}})
+
+	l.SetConfig(config.LiquidityConfig{MaxADVFraction: 0.1, ADVDays: 2})
+	limit, ok, err := l.Limit("035720", now)
+	if err != nil || !ok {
+		t.Fatalf("Limit = %v, %v", ok, err)
+	}
+	if limit.ADV != 2000 || limit.Days != 2 || limit.Max != 200 || limit.Ordered != 10 {
+		t.Errorf("limit %+v after a reload, want 10%% of ADV 2000 over 2 days with the day's orders kept", limit)
+	}
+}
@@ -0,0 +1,348 @@
+// Package soak drives the trading pipeline with synthetic prices at high rates
+// for long periods and measures how it holds up: the latency of the cycles,
+// the growth of the heap and the goroutines left running. It is meant to show
+// that the bot can trade many symbols intraday for a whole session before it
+// does so on a real account.
+package soak
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/scheduler"
+	"tradingbot/internal/universe"
+)
+
+// Defaults of the Options left zero.
+const (
+	DefaultInterval    = time.Minute
+	DefaultVolatility  = 0.002
+	DefaultStartPrice  = 50000
+	DefaultSampleEvery = 10 * time.Second
+)
+
+// Engine runs one trading cycle for a symbol, e.g. *engine.Engine.
+type Engine interface {
+	RunCycle(symbol string) error
+}
+
+// PriceFeed receives the synthetic prices, e.g. *paper.Exchange.
+type PriceFeed interface {
+	SetPrice(symbol string, price float64)
+}
+
+// Options configures a run. A run stops at Duration of wall time or after
+// Cycles cycles, whichever comes first; at least one of them must be set.
+type Options struct {
+	Symbols []string
+	// Rate is the cycles per second of wall time to run; zero or less runs
+	// them back to back. A cycle runs every symbol once, as the live loop
+	// does every polling interval.
+	Rate     float64
+	Duration time.Duration
+	Cycles   int
+	// Interval is the simulated time between cycles.
+	Interval time.Duration
+	// MaxParallel is how many symbols are run at once, as max_parallel.
+	MaxParallel int
+	// Volatility is the standard deviation of the log return of a price from
+	// one cycle to the next.
+	Volatility float64
+	StartPrice float64
+	Seed       int64
+	// Warmup is the number of cycles run before the baseline of the heap
+	// and goroutines is taken, for the strategies and caches to fill up.
+	Warmup int
+	// SampleEvery is the wall time between samples.
+	SampleEvery time.Duration
+}
+
+// Sample is the state of the process at a point of the run.
+type Sample struct {
+	Elapsed time.Duration
+	Cycles  int
+	// HeapAlloc is the live heap after a garbage collection.
+	HeapAlloc  uint64
+	Goroutines int
+	// P99 is the 99th percentile of the cycle latency so far.
+	P99 time.Duration
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Cycles  int
+	Elapsed time.Duration
+	// CycleErrors counts the symbol cycles that failed.
+	CycleErrors int
+	// Overruns counts the cycles that took longer than the time between
+	// cycles at Rate.
+	Overruns int
+	// P50, P90, P99 and Max are the percentiles of the latency of the
+	// cycles, accurate to 5%.
+	P50, P90, P99, Max time.Duration
+	// Baseline is taken after the warm-up, End after the last cycle. Samples
+	// are taken in between.
+	Baseline, End Sample
+	Samples       []Sample
+}
+
+// HeapGrowth returns how much the live heap grew after the warm-up.
+func (r *Report) HeapGrowth() int64 {
+	return int64(r.End.HeapAlloc) - int64(r.Baseline.HeapAlloc)
+}
+
+// GoroutineGrowth returns how many more goroutines run at the end than after
+// the warm-up.
+func (r *Report) GoroutineGrowth() int {
+	return r.End.Goroutines - r.Baseline.Goroutines
+}
+
+// HeapGrowthPerHour returns the trend of the live heap after the warm-up, in
+// bytes per hour of wall time, fitted to the samples by least squares. A leak
+// shows as a steady growth however long the run, while caches filling up
+// level off.
+func (r *Report) HeapGrowthPerHour() float64 {
+	var n, sx, sy, sxx, sxy float64
+	points := append([]Sample{r.Baseline}, r.Samples...)
+	for _, s := range append(points, r.End) {
+		if s.Cycles < r.Baseline.Cycles {
+			continue
+		}
+		x, y := s.Elapsed.Hours(), float64(s.HeapAlloc)
+		n++
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	if d := n*sxx - sx*sx; n >= 2 && d > 0 {
+		return (n*sxy - sx*sy) / d
+	}
+	return 0
+}
+
+// Harness runs the cycles. Clock is the simulated clock the engine and the
+// exchange read; it moves by Interval every cycle. Wall paces the run and
+// times the cycles.
+type Harness struct {
+	Engine Engine
+	Feed   PriceFeed
+	Clock  *clock.Simulated
+	Wall   clock.Clock
+	Options
+	// OnSample, when set, is called with every sample, e.g. to log progress.
+	OnSample func(Sample)
+}
+
+// New creates a harness timed by the system clock.
+func New(eng Engine, feed PriceFeed, clk *clock.Simulated, opts Options) *Harness {
+	return &Harness{Engine: eng, Feed: feed, Clock: clk, Wall: clock.Real, Options: opts}
+}
+
+// Run runs cycles until the run is over or ctx is done, and returns the
+// report of the cycles run, also when interrupted.
+func (h *Harness) Run(ctx context.Context) (*Report, error) {
+	if len(h.Symbols) == 0 {
+		return nil, fmt.Errorf("no symbols to trade")
+	}
+	if h.Duration <= 0 && h.Cycles <= 0 {
+		return nil, fmt.Errorf("neither a duration nor a number of cycles is set")
+	}
+	interval := orDefault(h.Interval, DefaultInterval)
+	sampleEvery := orDefault(h.SampleEvery, DefaultSampleEvery)
+	volatility := h.Volatility
+	if volatility <= 0 {
+		volatility = DefaultVolatility
+	}
+	startPrice := h.StartPrice
+	if startPrice <= 0 {
+		startPrice = DefaultStartPrice
+	}
+	prices := newPrices(h.Symbols, startPrice, volatility, h.Seed)
+	var period time.Duration
+	if h.Rate > 0 {
+		period = time.Duration(float64(time.Second) / h.Rate)
+	}
+
+	report := &Report{}
+	var latency histogram
+	start := h.Wall.Now()
+	sample := func() Sample {
+		s := takeSample(h.Wall.Now().Sub(start), report.Cycles, latency.quantile(0.99))
+		if h.OnSample != nil {
+			h.OnSample(s)
+		}
+		return s
+	}
+	if h.Warmup <= 0 {
+		report.Baseline = sample()
+	}
+
+	var err error
+	next, sampled := start, start
+	for h.Cycles <= 0 || report.Cycles < h.Cycles {
+		if h.Duration > 0 && h.Wall.Now().Sub(start) >= h.Duration {
+			break
+		}
+		if h.Rate > 0 {
+			next = next.Add(period)
+			if err = h.wait(ctx, next); err != nil {
+				break
+			}
+		} else if err = ctx.Err(); err != nil {
+			break
+		}
+
+		for _, symbol := range h.Symbols {
+			h.Feed.SetPrice(symbol, prices.next(symbol))
+		}
+		h.Clock.Advance(interval)
+		failed := make(chan struct{}, len(h.Symbols))
+		began := h.Wall.Now()
+		scheduler.ForEach(h.Symbols, h.MaxParallel, func(symbol string) {
+			if h.Engine.RunCycle(symbol) != nil {
+				failed <- struct{}{}
+			}
+		})
+		took := h.Wall.Now().Sub(began)
+		latency.add(took)
+		if h.Rate > 0 && took > period {
+			report.Overruns++
+		}
+		// Cycles missed while one overran are dropped, as the live loop's
+		// ticker does, instead of being run back to back.
+		if now := h.Wall.Now(); h.Rate > 0 && now.Sub(next) > period {
+			next = now
+		}
+		report.CycleErrors += len(failed)
+		report.Cycles++
+
+		if report.Cycles == h.Warmup {
+			report.Baseline = sample()
+		}
+		if now := h.Wall.Now(); now.Sub(sampled) >= sampleEvery {
+			report.Samples = append(report.Samples, sample())
+			sampled = now
+		}
+	}
+
+	if report.Cycles < h.Warmup {
+		report.Baseline = sample()
+	}
+	report.End = sample()
+	report.Elapsed = report.End.Elapsed
+	report.P50, report.P90, report.P99 = latency.quantile(0.5), latency.quantile(0.9), latency.quantile(0.99)
+	report.Max = latency.max
+	return report, err
+}
+
+// wait sleeps on the wall clock until at, unless ctx is done first.
+func (h *Harness) wait(ctx context.Context, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d := at.Sub(h.Wall.Now())
+	if d <= 0 {
+		return nil
+	}
+	timer := h.Wall.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// takeSample collects garbage first, so that HeapAlloc is the live heap.
+func takeSample(elapsed time.Duration, cycles int, p99 time.Duration) Sample {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return Sample{Elapsed: elapsed, Cycles: cycles, HeapAlloc: m.HeapAlloc, Goroutines: runtime.NumGoroutine(), P99: p99}
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// prices is the synthetic price stream: a geometric random walk per symbol
+// without drift, rounded down to the KRX tick size.
+type prices struct {
+	rand       *rand.Rand
+	volatility float64
+	last       map[string]float64
+}
+
+func newPrices(symbols []string, start, volatility float64, seed int64) *prices {
+	p := &prices{rand: rand.New(rand.NewSource(seed)), volatility: volatility, last: make(map[string]float64, len(symbols))}
+	for _, symbol := range symbols {
+		p.last[symbol] = start
+	}
+	return p
+}
+
+// next moves the price of symbol on by one cycle and returns it. The walk
+// itself is kept unrounded so that small moves add up.
+func (p *prices) next(symbol string) float64 {
+	price := p.last[symbol] * math.Exp(p.volatility*p.rand.NormFloat64())
+	p.last[symbol] = price
+	return math.Max(universe.RoundToTick(price), 1)
+}
+
+const (
+	histogramMin    = time.Microsecond
+	histogramGrowth = 1.05
+)
+
+// histogram counts latencies in buckets 5% apart from a microsecond up,
+// which keeps its size bounded however long the run: bucket i holds the
+// latencies up to histogramMin*histogramGrowth^i.
+type histogram struct {
+	counts []uint64
+	n      uint64
+	max    time.Duration
+}
+
+func (h *histogram) add(d time.Duration) {
+	i := 0
+	if d > histogramMin {
+		i = int(math.Ceil(math.Log(float64(d)/float64(histogramMin)) / math.Log(histogramGrowth)))
+	}
+	for len(h.counts) <= i {
+		h.counts = append(h.counts, 0)
+	}
+	h.counts[i]++
+	h.n++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// quantile returns the upper bound of the bucket holding the q quantile, or
+// the largest latency when that is less.
+func (h *histogram) quantile(q float64) time.Duration {
+	if h.n == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.n)))
+	var seen uint64
+	for i, c := range h.counts {
+		if seen += c; seen >= rank {
+			bound := time.Duration(float64(histogramMin) * math.Pow(histogramGrowth, float64(i)))
+			if bound > h.max {
+				return h.max
+			}
+			return bound
+		}
+	}
+	return h.max
+}
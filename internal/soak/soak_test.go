@@ -0,0 +1,111 @@
+package soak
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/engine"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+	"tradingbot/internal/paper"
+	"tradingbot/internal/strategy"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	var h histogram
+	for i := 1; i <= 1000; i++ {
+		h.add(time.Duration(i) * time.Microsecond)
+	}
+	for _, c := range []struct {
+		q    float64
+		want time.Duration
+	}{{0.5, 500 * time.Microsecond}, {0.9, 900 * time.Microsecond}, {0.99, 990 * time.Microsecond}, {1, time.Millisecond}} {
+		got := h.quantile(c.q)
+		if got < c.want || float64(got) > float64(c.want)*histogramGrowth {
+			t.Errorf("quantile(%v) = %v, want %v within 5%%", c.q, got, c.want)
+		}
+	}
+	if h.max != time.Millisecond {
+		t.Errorf("max = %v", h.max)
+	}
+}
+
+func TestPricesStayOnTicks(t *testing.T) {
+	p := newPrices([]string{"005930"}, 50000, 0.01, 1)
+	for i := 0; i < 10000; i++ {
+		price := p.next("005930")
+		if price < 1 || price != float64(int64(price)) {
+			t.Fatalf("price %v after %d steps", price, i)
+		}
+	}
+}
+
+// TestRunDrivesPaperPipeline soaks the engine and the paper exchange briefly:
+// the run must trade, advance the simulated clock by a minute a cycle and
+// leave no goroutines behind.
+func TestRunDrivesPaperPipeline(t *testing.T) {
+	symbols := []string{"005930", "000660", "035720", "035420"}
+	strategies := make(map[string]strategy.Strategy)
+	for _, symbol := range symbols {
+		s, err := strategy.New("moving_average", config.StrategyParams{"short_period": 2, "long_period": 5, "threshold": 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		strategies[symbol] = s
+	}
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST)
+	clk := clock.NewSimulated(start)
+	exch := paper.New(100000000, 0.00015, clk)
+	eng := engine.New(&config.Config{}, exch, discardStore{}, strategies)
+	eng.SetClock(clk)
+
+	var samples int
+	h := New(eng, exch, clk, Options{Symbols: symbols, Cycles: 300, MaxParallel: 2, Volatility: 0.01, Seed: 1, Warmup: 50, SampleEvery: time.Nanosecond})
+	h.OnSample = func(Sample) { samples++ }
+	report, err := h.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Cycles != 300 || report.CycleErrors != 0 {
+		t.Errorf("%d cycles, %d failed", report.Cycles, report.CycleErrors)
+	}
+	if got := clk.Now().Sub(start); got != 300*time.Minute {
+		t.Errorf("simulated time moved %v", got)
+	}
+	if len(exch.Orders()) == 0 {
+		t.Error("no orders placed")
+	}
+	if !(report.P50 > 0 && report.P50 <= report.P90 && report.P90 <= report.P99 && report.P99 <= report.Max) {
+		t.Errorf("latency percentiles %v %v %v %v", report.P50, report.P90, report.P99, report.Max)
+	}
+	if report.Baseline.Cycles != 50 || report.End.Cycles != 300 || len(report.Samples) != 300 || samples != 302 {
+		t.Errorf("baseline at %d, end at %d, %d samples, %d reported", report.Baseline.Cycles, report.End.Cycles, len(report.Samples), samples)
+	}
+	if n := report.GoroutineGrowth(); n > 0 {
+		t.Errorf("%d goroutines leaked", n)
+	}
+}
+
+func TestRunStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	eng := cycleFunc(func(string) error {
+		cancel()
+		return nil
+	})
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST))
+	report, err := New(eng, paper.New(0, 0, clk), clk, Options{Symbols: []string{"005930"}, Duration: time.Hour}).Run(ctx)
+	if err != context.Canceled || report.Cycles != 1 {
+		t.Errorf("%d cycles, %v", report.Cycles, err)
+	}
+}
+
+type cycleFunc func(symbol string) error
+
+func (f cycleFunc) RunCycle(symbol string) error { return f(symbol) }
+
+type discardStore struct{}
+
+func (discardStore) SaveOrder(order *models.Order) error { return nil }
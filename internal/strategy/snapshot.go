@@ -0,0 +1,239 @@
+package strategy
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshots written by this build.
+// Snapshots of a newer version are refused rather than half understood.
+const SnapshotVersion = 1
+
+// Snapshot encodings.
+const (
+	FormatGob  = "gob"
+	FormatJSON = "json"
+)
+
+// Snapshotter is implemented by strategies whose accumulated state, such as
+// their price history, can be saved and restored, so that a restarted bot, a
+// replay or a shadow book carries on from it instead of rebuilding it from
+// raw history.
+type Snapshotter interface {
+	SaveState() State
+	// RestoreState replaces the accumulated state with s, fitted to the
+	// current parameters, e.g. keeping the latest prices of a longer history.
+	RestoreState(s State) error
+}
+
+// State is the accumulated state of a strategy. Only the field of the
+// strategy's kind is set.
+type State struct {
+	MovingAverage *MovingAverageState `json:"moving_average,omitempty"`
+	NAVDeviation  *NAVDeviationState  `json:"nav_deviation,omitempty"`
+}
+
+// MovingAverageState is the state of a MovingAverage.
+type MovingAverageState struct {
+	// Prices are the prices the averages are computed over, oldest first.
+	Prices       []float64 `json:"prices"`
+	ShortSMA     float64   `json:"short_sma"`
+	LongSMA      float64   `json:"long_sma"`
+	Sentiment    float64   `json:"sentiment,omitempty"`
+	HasSentiment bool      `json:"has_sentiment,omitempty"`
+	VWAP         float64   `json:"vwap,omitempty"`
+	HasVWAP      bool      `json:"has_vwap,omitempty"`
+}
+
+// NAVDeviationState is the state of a NAVDeviation.
+type NAVDeviationState struct {
+	NAV     float64 `json:"nav"`
+	Premium float64 `json:"premium"`
+	HasNAV  bool    `json:"has_nav"`
+}
+
+// Snapshot is the state of the per-symbol strategies of a bot at a time.
+type Snapshot struct {
+	Version  int              `json:"version"`
+	Strategy string           `json:"strategy"`
+	Taken    time.Time        `json:"taken"`
+	Symbols  map[string]State `json:"symbols"`
+}
+
+// TakeSnapshot returns the state of the strategies, named name, that support
+// it.
+func TakeSnapshot(name string, strategies map[string]Strategy, now time.Time) Snapshot {
+	s := Snapshot{Version: SnapshotVersion, Strategy: name, Taken: now, Symbols: make(map[string]State, len(strategies))}
+	for symbol, strat := range strategies {
+		if st, ok := strat.(Snapshotter); ok {
+			s.Symbols[symbol] = st.SaveState()
+		}
+	}
+	return s
+}
+
+// Restore restores the state of the strategies, named name, of the symbols in
+// s, and returns those symbols, sorted. Strategies of other symbols keep their
+// state. A snapshot of another strategy is refused.
+func (s Snapshot) Restore(name string, strategies map[string]Strategy) ([]string, error) {
+	if s.Strategy != name {
+		return nil, fmt.Errorf("snapshot of %s, not %s", s.Strategy, name)
+	}
+	var restored []string
+	for symbol, strat := range strategies {
+		state, ok := s.Symbols[symbol]
+		if !ok {
+			continue
+		}
+		st, ok := strat.(Snapshotter)
+		if !ok {
+			return nil, fmt.Errorf("%s cannot restore state", name)
+		}
+		if err := st.RestoreState(state); err != nil {
+			return nil, fmt.Errorf("%s: %v", symbol, err)
+		}
+		restored = append(restored, symbol)
+	}
+	sort.Strings(restored)
+	return restored, nil
+}
+
+// Encode writes s to w in format, FormatGob or FormatJSON. A gob snapshot
+// starts with its version, so that a reader can refuse it before decoding the
+// rest.
+func (s Snapshot) Encode(w io.Writer, format string) error {
+	switch format {
+	case FormatGob, "":
+		enc := gob.NewEncoder(w)
+		if err := enc.Encode(s.Version); err != nil {
+			return err
+		}
+		return enc.Encode(s)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+	return fmt.Errorf("unknown snapshot format %q (want %s or %s)", format, FormatGob, FormatJSON)
+}
+
+// DecodeSnapshot reads a snapshot written by Encode in either format.
+func DecodeSnapshot(r io.Reader) (Snapshot, error) {
+	br := bufio.NewReader(r)
+	var s Snapshot
+	if first, err := br.Peek(1); err == nil && first[0] == '{' {
+		if err := json.NewDecoder(br).Decode(&s); err != nil {
+			return Snapshot{}, fmt.Errorf("invalid snapshot: %v", err)
+		}
+		return s, checkVersion(s.Version)
+	}
+
+	dec := gob.NewDecoder(br)
+	var version int
+	if err := dec.Decode(&version); err != nil {
+		return Snapshot{}, fmt.Errorf("invalid snapshot: %v", err)
+	}
+	if err := checkVersion(version); err != nil {
+		return Snapshot{}, err
+	}
+	if err := dec.Decode(&s); err != nil {
+		return Snapshot{}, fmt.Errorf("invalid snapshot: %v", err)
+	}
+	return s, nil
+}
+
+func checkVersion(version int) error {
+	if version < 1 || version > SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d, want at most %d", version, SnapshotVersion)
+	}
+	return nil
+}
+
+// SaveSnapshot writes s to path in format. The file is replaced at once, so
+// that a crash while saving leaves the previous snapshot.
+func SaveSnapshot(path, format string, s Snapshot) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	if err := s.Encode(w, format); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot reads the snapshot saved at path.
+func LoadSnapshot(path string) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+	s, err := DecodeSnapshot(f)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// SaveState returns the price history and the latest indicators.
+func (ma *MovingAverage) SaveState() State {
+	s := &MovingAverageState{
+		ShortSMA:     ma.ShortSMA,
+		LongSMA:      ma.LongSMA,
+		Sentiment:    ma.sentiment,
+		HasSentiment: ma.hasSentiment,
+		VWAP:         ma.vwap,
+		HasVWAP:      ma.hasVWAP,
+	}
+	if ma.history != nil {
+		s.Prices = ma.history.values()
+	}
+	return State{MovingAverage: s}
+}
+
+// RestoreState replaces the price history, keeping the latest LongPeriod
+// prices of s.
+func (ma *MovingAverage) RestoreState(s State) error {
+	if s.MovingAverage == nil {
+		return fmt.Errorf("no moving_average state")
+	}
+	st := s.MovingAverage
+	ma.history = newWindowOf(ma.LongPeriod, ma.ShortPeriod, st.Prices)
+	ma.ShortSMA, ma.LongSMA = st.ShortSMA, st.LongSMA
+	ma.sentiment, ma.hasSentiment = st.Sentiment, st.HasSentiment
+	ma.vwap, ma.hasVWAP = st.VWAP, st.HasVWAP
+	return nil
+}
+
+// SaveState returns the NAV and premium seen last.
+func (nd *NAVDeviation) SaveState() State {
+	return State{NAVDeviation: &NAVDeviationState{NAV: nd.nav, Premium: nd.premium, HasNAV: nd.hasNAV}}
+}
+
+// RestoreState replaces the NAV and premium seen last.
+func (nd *NAVDeviation) RestoreState(s State) error {
+	if s.NAVDeviation == nil {
+		return fmt.Errorf("no nav_deviation state")
+	}
+	nd.nav, nd.premium, nd.hasNAV = s.NAVDeviation.NAV, s.NAVDeviation.Premium, s.NAVDeviation.HasNAV
+	return nil
+}
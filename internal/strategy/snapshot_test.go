@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"bytes"
+	"encoding/gob"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+	"tradingbot/internal/models"
+)
+
+func TestSnapshotRestoresStrategies(t *testing.T) {
+	cfg := models.MovingAverageConfig{ShortPeriod: 2, LongPeriod: 4, Threshold: 0.01}
+	live := NewMovingAverage(cfg)
+	for _, p := range []float64{100, 102, 104, 103, 101} {
+		live.Analyze(&models.MarketData{StckPrpr: strconv.FormatFloat(p, 'f', -1, 64)})
+	}
+	taken := time.Date(2026, 10, 16, 15, 30, 0, 0, time.UTC)
+	snap := TakeSnapshot("moving_average", map[string]Strategy{"005930": live}, taken)
+	// Without the history the next price would only hold.
+	data := &models.MarketData{StckPrpr: "95"}
+	want := live.Analyze(data).Type
+
+	for _, format := range []string{FormatGob, FormatJSON} {
+		path := filepath.Join(t.TempDir(), "state")
+		if err := SaveSnapshot(path, format, snap); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		loaded, err := LoadSnapshot(path)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !loaded.Taken.Equal(taken) {
+			t.Errorf("%s: taken %s, want %s", format, loaded.Taken, taken)
+		}
+
+		restored := NewMovingAverage(cfg)
+		symbols, err := loaded.Restore("moving_average", map[string]Strategy{"005930": restored, "000660": NewMovingAverage(cfg)})
+		if err != nil || len(symbols) != 1 || symbols[0] != "005930" {
+			t.Fatalf("%s: restored %v, %v; want 005930", format, symbols, err)
+		}
+		if got := restored.Analyze(data).Type; got != want || restored.LongSMA != live.LongSMA {
+			t.Errorf("%s: restored signal %s at long SMA %g, want %s at %g", format, got, restored.LongSMA, want, live.LongSMA)
+		}
+	}
+
+	if _, err := snap.Restore("nav_deviation", map[string]Strategy{"005930": NewNAVDeviation(models.NAVDeviationConfig{})}); err == nil {
+		t.Error("snapshot of moving_average restored into nav_deviation")
+	}
+}
+
+func TestSnapshotOfNewerVersionRefused(t *testing.T) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	enc.Encode(SnapshotVersion + 1)
+	enc.Encode(struct{ Future string }{"field"})
+	if _, err := DecodeSnapshot(&buf); err == nil {
+		t.Error("gob snapshot of a newer version decoded")
+	}
+
+	json := `{"version": 2, "strategy": "moving_average", "symbols": {}}`
+	if _, err := DecodeSnapshot(bytes.NewBufferString(json)); err == nil {
+		t.Error("JSON snapshot of a newer version decoded")
+	}
+}
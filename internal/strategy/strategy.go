@@ -1,8 +1,10 @@
 package strategy
 
 import (
+	"fmt"
 	"log"
 	"strconv"
+	"tradingbot/internal/config"
 	"tradingbot/internal/models"
 )
 
@@ -16,24 +18,124 @@ type Strategy interface {
 	Analyze(data *models.MarketData) *models.Signal
 }
 
+// Reconfigurable is implemented by strategies that can take new parameters
+// without losing their accumulated state.
+type Reconfigurable interface {
+	Reconfigure(params config.StrategyParams) error
+}
+
+// Explainer is implemented by strategies that can report the indicator values
+// behind their latest decision, e.g. for the audit log.
+type Explainer interface {
+	Indicators() map[string]float64
+}
+
+// Sentiment reports the recent news sentiment of symbols, from -1 (negative)
+// to 1 (positive); ok is false when there is none.
+type Sentiment interface {
+	Sentiment(symbol string) (score float64, ok bool)
+}
+
+// SentimentUser is implemented by strategies that combine price signals with
+// news sentiment. SetSentiment is called before each Analyze with the score
+// of the analyzed symbol.
+type SentimentUser interface {
+	SetSentiment(score float64, ok bool)
+}
+
+// FeedSentiment passes the sentiment of symbol from source to strat when it
+// uses one.
+func FeedSentiment(strat Strategy, source Sentiment, symbol string) {
+	if user, ok := strat.(SentimentUser); ok && source != nil {
+		user.SetSentiment(source.Sentiment(symbol))
+	}
+}
+
+// VWAP reports the volume-weighted average price of the current session of
+// symbols; ok is false when there is none yet.
+type VWAP interface {
+	VWAP(symbol string) (vwap float64, ok bool)
+}
+
+// VWAPUser is implemented by strategies that trade around the session VWAP.
+// SetVWAP is called before each Analyze with the VWAP of the analyzed symbol.
+type VWAPUser interface {
+	SetVWAP(vwap float64, ok bool)
+}
+
+// FeedVWAP passes the session VWAP of symbol from source to strat when it uses
+// one.
+func FeedVWAP(strat Strategy, source VWAP, symbol string) {
+	if user, ok := strat.(VWAPUser); ok && source != nil {
+		user.SetVWAP(source.VWAP(symbol))
+	}
+}
+
+// New builds the strategy registered under name, decoding its settings from params.
+func New(name string, params config.StrategyParams) (Strategy, error) {
+	switch name {
+	case "moving_average":
+		var cfg models.MovingAverageConfig
+		if err := params.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("moving_average: %v", err)
+		}
+		return NewMovingAverage(cfg), nil
+	case "nav_deviation":
+		var cfg models.NAVDeviationConfig
+		if err := params.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("nav_deviation: %v", err)
+		}
+		return NewNAVDeviation(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown strategy: %s", name)
+	}
+}
+
 type MovingAverage struct {
-	ShortPeriod  int
-	LongPeriod   int
-	Threshold    float64
-	ShortSMA     float64
-	LongSMA      float64
-	PriceHistory []float64
+	ShortPeriod int
+	LongPeriod  int
+	Threshold   float64
+	ShortSMA    float64
+	LongSMA     float64
+	// history holds the last LongPeriod prices with their running sums.
+	history *window
+
+	// MinBuySentiment and SellSentiment, when set, combine the crossover with
+	// news sentiment; see models.MovingAverageConfig.
+	MinBuySentiment *float64
+	SellSentiment   *float64
+	sentiment       float64
+	hasSentiment    bool
+
+	// BuyBelowVWAP holds buys while the price is at or above the session
+	// VWAP, when known.
+	BuyBelowVWAP bool
+	vwap         float64
+	hasVWAP      bool
 }
 
-func NewMovingAverage(config models.StrategyConfig) *MovingAverage {
+func NewMovingAverage(config models.MovingAverageConfig) *MovingAverage {
 	return &MovingAverage{
-		ShortPeriod:  config.ShortPeriod,
-		LongPeriod:   config.LongPeriod,
-		Threshold:    config.Threshold,
-		PriceHistory: []float64{},
+		ShortPeriod:     config.ShortPeriod,
+		LongPeriod:      config.LongPeriod,
+		Threshold:       config.Threshold,
+		history:         newWindow(config.LongPeriod, config.ShortPeriod),
+		MinBuySentiment: config.MinBuySentiment,
+		SellSentiment:   config.SellSentiment,
+		BuyBelowVWAP:    config.BuyBelowVWAP,
 	}
 }
 
+// SetSentiment records the news sentiment of the symbol for the next Analyze.
+func (ma *MovingAverage) SetSentiment(score float64, ok bool) {
+	ma.sentiment, ma.hasSentiment = score, ok
+}
+
+// SetVWAP records the session VWAP of the symbol for the next Analyze.
+func (ma *MovingAverage) SetVWAP(vwap float64, ok bool) {
+	ma.vwap, ma.hasVWAP = vwap, ok
+}
+
 func (ma *MovingAverage) Analyze(data *models.MarketData) *models.Signal {
 	price, err := strconv.ParseFloat(data.StckPrpr, 64)
 	if err != nil {
@@ -41,25 +143,38 @@ func (ma *MovingAverage) Analyze(data *models.MarketData) *models.Signal {
 		return &models.Signal{Type: HoldSignal}
 	}
 
-	ma.PriceHistory = append(ma.PriceHistory, price)
-
-	// PriceHistory가 LongPeriod보다 길어질 경우 초과된 데이터를 제거
-	if len(ma.PriceHistory) > ma.LongPeriod {
-		ma.PriceHistory = ma.PriceHistory[1:]
+	if ma.history == nil {
+		ma.history = newWindow(ma.LongPeriod, ma.ShortPeriod)
 	}
+	// 가장 오래된 가격은 링 버퍼에서 자동으로 밀려납니다
+	ma.history.push(price)
 
 	// 충분한 데이터가 없으면 Hold 신호를 반환
-	if len(ma.PriceHistory) < ma.LongPeriod {
-		log.Printf("Not enough data to calculate moving averages. Data points: %d", len(ma.PriceHistory))
+	if !ma.history.full() {
+		log.Printf("Not enough data to calculate moving averages. Data points: %d", ma.history.len())
 		return &models.Signal{Type: HoldSignal}
 	}
 
-	ma.updateSMA()
+	ma.ShortSMA = ma.history.shortMean()
+	ma.LongSMA = ma.history.mean()
 
 	// 이동 평균 로그 추가
 	log.Printf("ShortSMA: %.2f, LongSMA: %.2f", ma.ShortSMA, ma.LongSMA)
 
+	if ma.hasSentiment && ma.SellSentiment != nil && ma.sentiment <= *ma.SellSentiment {
+		log.Printf("Sell signal triggered. Sentiment: %.2f <= %.2f", ma.sentiment, *ma.SellSentiment)
+		return &models.Signal{Type: SellSignal, Amount: 1.0}
+	}
+
 	if ma.ShortSMA > ma.LongSMA*(1+ma.Threshold) {
+		if ma.hasSentiment && ma.MinBuySentiment != nil && ma.sentiment < *ma.MinBuySentiment {
+			log.Printf("Buy signal held back. Sentiment: %.2f < %.2f", ma.sentiment, *ma.MinBuySentiment)
+			return &models.Signal{Type: HoldSignal}
+		}
+		if ma.BuyBelowVWAP && ma.hasVWAP && price >= ma.vwap {
+			log.Printf("Buy signal held back. Price: %.2f >= VWAP: %.2f", price, ma.vwap)
+			return &models.Signal{Type: HoldSignal}
+		}
 		log.Printf("Buy signal triggered. ShortSMA: %.2f > LongSMA: %.2f * (1 + %.2f)", ma.ShortSMA, ma.LongSMA, ma.Threshold)
 		return &models.Signal{Type: BuySignal, Amount: 1.0}
 	} else if ma.ShortSMA < ma.LongSMA*(1-ma.Threshold) {
@@ -71,20 +186,104 @@ func (ma *MovingAverage) Analyze(data *models.MarketData) *models.Signal {
 	return &models.Signal{Type: HoldSignal}
 }
 
-func (ma *MovingAverage) updateSMA() {
-	ma.ShortSMA = ma.calculateSMA(ma.ShortPeriod)
-	ma.LongSMA = ma.calculateSMA(ma.LongPeriod)
+// Reconfigure updates the periods and threshold while keeping the price history.
+func (ma *MovingAverage) Reconfigure(params config.StrategyParams) error {
+	var cfg models.MovingAverageConfig
+	if err := params.Decode(&cfg); err != nil {
+		return fmt.Errorf("moving_average: %v", err)
+	}
+
+	ma.ShortPeriod = cfg.ShortPeriod
+	ma.LongPeriod = cfg.LongPeriod
+	ma.Threshold = cfg.Threshold
+	ma.MinBuySentiment = cfg.MinBuySentiment
+	ma.SellSentiment = cfg.SellSentiment
+	ma.BuyBelowVWAP = cfg.BuyBelowVWAP
+	if ma.history != nil {
+		ma.history = ma.history.resize(ma.LongPeriod, ma.ShortPeriod)
+	}
+	return nil
+}
+
+// Indicators returns the moving averages computed by the latest Analyze call,
+// and the news sentiment and session VWAP it saw, if any.
+func (ma *MovingAverage) Indicators() map[string]float64 {
+	indicators := map[string]float64{
+		"short_sma":     ma.ShortSMA,
+		"long_sma":      ma.LongSMA,
+		"threshold":     ma.Threshold,
+		"history_count": float64(ma.historyCount()),
+	}
+	if ma.hasSentiment {
+		indicators["sentiment"] = ma.sentiment
+	}
+	if ma.hasVWAP {
+		indicators["vwap"] = ma.vwap
+	}
+	return indicators
+}
+
+func (ma *MovingAverage) historyCount() int {
+	if ma.history == nil {
+		return 0
+	}
+	return ma.history.len()
+}
+
+// NAVDeviation trades an ETF or ETN on the deviation of its price from the
+// indicative NAV carried by its quotes; see models.NAVDeviationConfig. Quotes
+// without a NAV, such as candles, hold.
+type NAVDeviation struct {
+	EntryDiscount float64
+	ExitPremium   float64
+	nav           float64
+	premium       float64
+	hasNAV        bool
+}
+
+func NewNAVDeviation(config models.NAVDeviationConfig) *NAVDeviation {
+	return &NAVDeviation{EntryDiscount: config.EntryDiscount, ExitPremium: config.ExitPremium}
 }
 
-func (ma *MovingAverage) calculateSMA(period int) float64 {
-	if len(ma.PriceHistory) < period {
-		return 0.0
+func (nd *NAVDeviation) Analyze(data *models.MarketData) *models.Signal {
+	nd.nav = data.NAV()
+	nd.premium, nd.hasNAV = data.Premium()
+	if !nd.hasNAV {
+		log.Printf("No NAV in market data, holding")
+		return &models.Signal{Type: HoldSignal}
+	}
+
+	if nd.premium <= -nd.EntryDiscount {
+		log.Printf("Buy signal triggered. Discount to NAV %.2f: %.4f >= %.4f", nd.nav, -nd.premium, nd.EntryDiscount)
+		return &models.Signal{Type: BuySignal, Amount: 1.0}
 	}
+	if nd.premium >= nd.ExitPremium {
+		log.Printf("Sell signal triggered. Premium to NAV %.2f: %.4f >= %.4f", nd.nav, nd.premium, nd.ExitPremium)
+		return &models.Signal{Type: SellSignal, Amount: 1.0}
+	}
+	return &models.Signal{Type: HoldSignal}
+}
 
-	sum := 0.0
-	for i := len(ma.PriceHistory) - period; i < len(ma.PriceHistory); i++ {
-		sum += ma.PriceHistory[i]
+// Reconfigure updates the entry and exit thresholds.
+func (nd *NAVDeviation) Reconfigure(params config.StrategyParams) error {
+	var cfg models.NAVDeviationConfig
+	if err := params.Decode(&cfg); err != nil {
+		return fmt.Errorf("nav_deviation: %v", err)
 	}
+	nd.EntryDiscount = cfg.EntryDiscount
+	nd.ExitPremium = cfg.ExitPremium
+	return nil
+}
 
-	return sum / float64(period)
+// Indicators returns the NAV and premium seen by the latest Analyze call.
+func (nd *NAVDeviation) Indicators() map[string]float64 {
+	indicators := map[string]float64{
+		"entry_discount": nd.EntryDiscount,
+		"exit_premium":   nd.ExitPremium,
+	}
+	if nd.hasNAV {
+		indicators["nav"] = nd.nav
+		indicators["premium"] = nd.premium
+	}
+	return indicators
 }
@@ -0,0 +1,118 @@
+package strategy
+
+// window is a ring buffer of the latest prices with running sums of all of
+// them and of the latest short ones, so that adding a price and reading both
+// moving averages take constant time whatever the periods.
+type window struct {
+	buf   []float64
+	next  int // index the next price goes to
+	n     int // prices held
+	short int
+
+	sum      float64
+	shortSum float64
+	// pushes counts the prices added since the sums were last recomputed;
+	// they are recomputed once per turn of the buffer so that rounding
+	// errors cannot accumulate over a long stream.
+	pushes int
+}
+
+func newWindow(size, short int) *window {
+	if size < 1 {
+		size = 1
+	}
+	if short > size {
+		short = size
+	}
+	return &window{buf: make([]float64, size), short: short}
+}
+
+// push adds a price, dropping the oldest once the window is full.
+func (w *window) push(price float64) {
+	size := len(w.buf)
+	if w.short > 0 && w.n >= w.short {
+		w.shortSum -= w.latest(w.short - 1)
+	}
+	if w.n == size {
+		w.sum -= w.buf[w.next]
+	} else {
+		w.n++
+	}
+	w.buf[w.next] = price
+	w.next = (w.next + 1) % size
+	w.sum += price
+	w.shortSum += price
+
+	if w.pushes++; w.pushes >= size {
+		w.recompute()
+	}
+}
+
+// latest returns the i-th latest price, 0 being the last one pushed.
+func (w *window) latest(i int) float64 {
+	size := len(w.buf)
+	return w.buf[(w.next-1-i+2*size)%size]
+}
+
+func (w *window) recompute() {
+	w.sum, w.shortSum = 0, 0
+	for i := 0; i < w.n; i++ {
+		p := w.latest(i)
+		w.sum += p
+		if i < w.short {
+			w.shortSum += p
+		}
+	}
+	w.pushes = 0
+}
+
+func (w *window) len() int {
+	return w.n
+}
+
+func (w *window) full() bool {
+	return w.n == len(w.buf)
+}
+
+// mean is the average of every price held.
+func (w *window) mean() float64 {
+	if w.n == 0 {
+		return 0
+	}
+	return w.sum / float64(w.n)
+}
+
+// shortMean is the average of the latest short prices, or 0 until there are
+// that many.
+func (w *window) shortMean() float64 {
+	if w.short == 0 || w.n < w.short {
+		return 0
+	}
+	return w.shortSum / float64(w.short)
+}
+
+// values returns the prices held, oldest first.
+func (w *window) values() []float64 {
+	out := make([]float64, w.n)
+	for i := range out {
+		out[i] = w.latest(w.n - 1 - i)
+	}
+	return out
+}
+
+// resize returns a window of the new sizes holding the latest prices of w.
+func (w *window) resize(size, short int) *window {
+	return newWindowOf(size, short, w.values())
+}
+
+// newWindowOf returns a window holding the latest of values, oldest first.
+func newWindowOf(size, short int, values []float64) *window {
+	w := newWindow(size, short)
+	if len(values) > len(w.buf) {
+		values = values[len(values)-len(w.buf):]
+	}
+	for _, p := range values {
+		w.push(p)
+	}
+	return w
+}
@@ -0,0 +1,74 @@
+package strategy
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"tradingbot/internal/models"
+)
+
+// naiveMean is the average of the last period prices, as the windows compute
+// it incrementally.
+func naiveMean(prices []float64, period int) float64 {
+	sum := 0.0
+	for _, p := range prices[len(prices)-period:] {
+		sum += p
+	}
+	return sum / float64(period)
+}
+
+func TestWindowMatchesRecomputation(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	w := newWindow(20, 5)
+	var prices []float64
+	for i := 0; i < 1000; i++ {
+		p := 50000 + rng.Float64()*1000
+		prices = append(prices, p)
+		w.push(p)
+
+		if i+1 >= 5 {
+			if got, want := w.shortMean(), naiveMean(prices, 5); math.Abs(got-want) > 1e-6 {
+				t.Fatalf("after %d prices: short mean = %v, want %v", i+1, got, want)
+			}
+		}
+		if i+1 >= 20 {
+			if got, want := w.mean(), naiveMean(prices, 20); math.Abs(got-want) > 1e-6 {
+				t.Fatalf("after %d prices: mean = %v, want %v", i+1, got, want)
+			}
+		}
+	}
+
+	resized := w.resize(10, 3)
+	if got, want := resized.mean(), naiveMean(prices, 10); math.Abs(got-want) > 1e-6 || !resized.full() {
+		t.Errorf("resized mean = %v, want %v over a full window", got, want)
+	}
+	if got, want := resized.shortMean(), naiveMean(prices, 3); math.Abs(got-want) > 1e-6 {
+		t.Errorf("resized short mean = %v, want %v", got, want)
+	}
+}
+
+func TestMovingAverageKeepsHistoryOnReconfigure(t *testing.T) {
+	ma := NewMovingAverage(models.MovingAverageConfig{ShortPeriod: 2, LongPeriod: 4})
+	for _, p := range []string{"100", "101", "102", "103", "104"} {
+		ma.Analyze(&models.MarketData{StckPrpr: p})
+	}
+	if ma.ShortSMA != 103.5 || ma.LongSMA != 102.5 {
+		t.Errorf("SMAs = %v, %v, want 103.5, 102.5", ma.ShortSMA, ma.LongSMA)
+	}
+
+	if err := ma.Reconfigure(map[string]interface{}{"short_period": 1, "long_period": 3}); err != nil {
+		t.Fatal(err)
+	}
+	ma.Analyze(&models.MarketData{StckPrpr: "105"})
+	if ma.ShortSMA != 105 || ma.LongSMA != 104 {
+		t.Errorf("SMAs after reconfiguring = %v, %v, want 105, 104", ma.ShortSMA, ma.LongSMA)
+	}
+}
+
+func BenchmarkMovingAverageAnalyze(b *testing.B) {
+	ma := NewMovingAverage(models.MovingAverageConfig{ShortPeriod: 20, LongPeriod: 200})
+	data := &models.MarketData{StckPrpr: "70000"}
+	for i := 0; i < b.N; i++ {
+		ma.Analyze(data)
+	}
+}
@@ -0,0 +1,205 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const (
+	// DefaultBackoff is how long a component is held back after its first
+	// panic. Every further panic in a row doubles it, up to DefaultMaxBackoff.
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+// PanicError is a panic recovered from a component.
+type PanicError struct {
+	Component string
+	Value     interface{}
+	Stack     []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Component, e.Value)
+}
+
+// ErrBackingOff is returned by Do for a component held back after a panic.
+var ErrBackingOff = errors.New("backing off after a panic")
+
+// Supervisor runs the components of the bot so that a panic in one of them,
+// such as a strategy failing on one symbol, is recovered and reported instead
+// of taking the whole bot down. A component that panics is restarted, or run
+// again, after a backoff that grows while it keeps panicking. Panics are
+// logged with their stack and published as an ErrorEvent whose source is the
+// component. Supervisor is safe for concurrent use.
+type Supervisor struct {
+	bus        *events.Bus
+	clock      clock.Clock
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu       sync.Mutex
+	failures map[string]failure
+}
+
+// failure is the panics in a row of a component.
+type failure struct {
+	count int
+	last  time.Time
+	until time.Time
+}
+
+// New creates a supervisor publishing the panics it recovers on bus, which
+// may be nil.
+func New(bus *events.Bus) *Supervisor {
+	return &Supervisor{
+		bus:        bus,
+		clock:      clock.Real,
+		backoff:    DefaultBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		failures:   map[string]failure{},
+	}
+}
+
+// SetClock replaces the clock backoffs are timed on, for tests.
+func (s *Supervisor) SetClock(clk clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clk
+}
+
+// SetBackoff sets the backoff after a first panic and its limit.
+func (s *Supervisor) SetBackoff(backoff, max time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backoff, s.maxBackoff = backoff, max
+}
+
+// Go runs run in a goroutine under the name component until ctx is done.
+// When run panics it is started again after the backoff; when it returns, the
+// component has finished and is not restarted.
+func (s *Supervisor) Go(ctx context.Context, component string, run func(ctx context.Context)) {
+	go func() {
+		for ctx.Err() == nil {
+			if s.protect(component, "", func() { run(ctx) }) == nil {
+				return
+			}
+			wait := s.delay(component)
+			log.WithFields(logrus.Fields{"component": component, "backoff": wait}).Warn("Restarting component after a panic")
+			timer := s.clk().NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+		}
+	}()
+}
+
+// Do calls fn for the component of symbol, which may be empty, and returns
+// the PanicError when it panicked. While the component backs off after a
+// panic fn is not called and ErrBackingOff is returned. A call that returns
+// normally ends the backoff.
+func (s *Supervisor) Do(component, symbol string, fn func()) error {
+	id := key(component, symbol)
+	if wait := s.delay(id); wait > 0 {
+		log.WithFields(logrus.Fields{"component": component, "symbol": symbol, "backoff": wait}).Debug("Component skipped after a panic")
+		return ErrBackingOff
+	}
+	if err := s.protect(component, symbol, fn); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.failures, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// protect calls fn and recovers from its panic, which it reports and counts
+// against the component.
+func (s *Supervisor) protect(component, symbol string, fn func()) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		perr := &PanicError{Component: component, Value: r, Stack: debug.Stack()}
+		now := s.record(key(component, symbol))
+		log.WithFields(logrus.Fields{"component": component, "symbol": symbol, "panic": r}).Errorf("Recovered from panic\n%s", perr.Stack)
+		if s.bus != nil {
+			s.bus.Publish(events.ErrorEvent{Source: component, Symbol: symbol, Err: perr, Time: now})
+		}
+		err = perr
+	}()
+	fn()
+	return nil
+}
+
+// key identifies the backoff of the component of symbol.
+func key(component, symbol string) string {
+	if symbol == "" {
+		return component
+	}
+	return component + " " + symbol
+}
+
+// record counts a panic of key and starts its backoff. Panics after a quiet
+// period of the maximum backoff start the count over.
+func (s *Supervisor) record(key string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	f := s.failures[key]
+	if !f.last.IsZero() && now.Sub(f.last) > s.maxBackoff {
+		f.count = 0
+	}
+	f.count++
+	f.last = now
+	f.until = now.Add(s.backoffAfter(f.count))
+	s.failures[key] = f
+	return now
+}
+
+// backoffAfter returns the backoff after count panics in a row.
+func (s *Supervisor) backoffAfter(count int) time.Duration {
+	d := s.backoff
+	for i := 1; i < count && d < s.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.maxBackoff {
+		d = s.maxBackoff
+	}
+	return d
+}
+
+// delay returns how long key still backs off.
+func (s *Supervisor) delay(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.failures[key]
+	if !ok {
+		return 0
+	}
+	if wait := f.until.Sub(s.clock.Now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (s *Supervisor) clk() clock.Clock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clock
+}
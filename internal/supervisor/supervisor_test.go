@@ -0,0 +1,95 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/events"
+)
+
+func TestDoRecoversAndBacksOff(t *testing.T) {
+	bus := events.NewBus()
+	var reported []events.ErrorEvent
+	bus.Subscribe(func(ev events.Event) { reported = append(reported, ev.(events.ErrorEvent)) }, events.KindError)
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	s := New(bus)
+	s.SetClock(clk)
+
+	calls := 0
+	panicking := func() { calls++; panic("index out of range") }
+	err := s.Do("cycle", "005930", panicking)
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "index out of range" || len(perr.Stack) == 0 {
+		t.Fatalf("err = %v, want the panic", err)
+	}
+	if len(reported) != 1 || reported[0].Source != "cycle" || reported[0].Symbol != "005930" || reported[0].Err != err {
+		t.Errorf("reported %+v", reported)
+	}
+
+	// The symbol backs off, others carry on.
+	if err := s.Do("cycle", "005930", panicking); err != ErrBackingOff || calls != 1 {
+		t.Errorf("err = %v after %d calls, want a backoff", err, calls)
+	}
+	if err := s.Do("cycle", "000660", func() {}); err != nil {
+		t.Errorf("other symbol: %v", err)
+	}
+
+	// The backoff doubles while the panics go on.
+	clk.Advance(DefaultBackoff)
+	s.Do("cycle", "005930", panicking)
+	clk.Advance(DefaultBackoff)
+	if err := s.Do("cycle", "005930", panicking); err != ErrBackingOff || calls != 2 {
+		t.Errorf("err = %v after %d calls, want a longer backoff", err, calls)
+	}
+
+	// A call that returns ends the backoff.
+	clk.Advance(DefaultBackoff)
+	if err := s.Do("cycle", "005930", func() {}); err != nil {
+		t.Fatal(err)
+	}
+	s.Do("cycle", "005930", panicking)
+	clk.Advance(DefaultBackoff)
+	if err := s.Do("cycle", "005930", func() {}); err != nil {
+		t.Errorf("err = %v, want the backoff to start over", err)
+	}
+}
+
+func TestGoRestartsAfterPanic(t *testing.T) {
+	s := New(nil)
+	s.SetBackoff(time.Millisecond, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	starts := make(chan int, 10)
+	n := 0
+	s.Go(ctx, "feed", func(ctx context.Context) {
+		n++
+		starts <- n
+		if n < 3 {
+			panic("feed failed")
+		}
+		<-ctx.Done()
+	})
+	for want := 1; want <= 3; want++ {
+		select {
+		case got := <-starts:
+			if got != want {
+				t.Fatalf("start %d, want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("component not restarted after %d starts", want-1)
+		}
+	}
+
+	// A component that returns has finished.
+	done := make(chan struct{})
+	s.Go(ctx, "once", func(ctx context.Context) { done <- struct{}{} })
+	<-done
+	select {
+	case <-done:
+		t.Error("finished component restarted")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,133 @@
+package sweep
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"tradingbot/internal/config"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+// Account is the part of the exchange client the sweeper needs.
+type Account interface {
+	GetBalance() (string, error)
+	GetPositions() ([]models.Position, error)
+	GetMarketData(stockCode string) (*models.MarketData, error)
+	PlaceOrder(signal *models.Signal) (*models.Order, error)
+}
+
+// Sweeper moves idle cash into and out of the money-market ETF configured in
+// config.CashSweepConfig.
+type Sweeper struct {
+	cfg  config.CashSweepConfig
+	acct Account
+}
+
+// New creates a sweeper trading the ETF through acct.
+func New(cfg config.CashSweepConfig, acct Account) *Sweeper {
+	return &Sweeper{cfg: cfg, acct: acct}
+}
+
+// Symbol returns the stock code of the sweep ETF.
+func (s *Sweeper) Symbol() string {
+	return s.cfg.Symbol
+}
+
+// Park buys the ETF with the cash above the reserve when no other position is
+// open. It returns a nil order when there is nothing to buy.
+func (s *Sweeper) Park() (*models.Order, error) {
+	positions, err := s.acct.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %v", err)
+	}
+	for _, p := range positions {
+		if p.StockCode != s.cfg.Symbol && p.Quantity > 0 {
+			return nil, nil
+		}
+	}
+
+	cash, err := s.cash()
+	if err != nil {
+		return nil, err
+	}
+	price, err := s.price(s.cfg.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	quantity := math.Floor((cash - s.cfg.Reserve) / price)
+	if quantity < 1 || quantity*price < s.cfg.MinAmount {
+		return nil, nil
+	}
+
+	log.WithFields(logrus.Fields{"symbol": s.cfg.Symbol, "quantity": quantity, "cash": cash}).Info("Parking idle cash")
+	return s.acct.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: s.cfg.Symbol, Amount: quantity})
+}
+
+// Free sells as much of the ETF as is needed for the cash to cover buying
+// signal, or all of it when that is not enough. It returns a nil order when the
+// cash already covers the signal or no ETF is held.
+func (s *Sweeper) Free(signal *models.Signal) (*models.Order, error) {
+	price, err := s.price(signal.Pair)
+	if err != nil {
+		return nil, err
+	}
+	cash, err := s.cash()
+	if err != nil {
+		return nil, err
+	}
+	shortfall := signal.Amount*price + s.cfg.Reserve - cash
+	if shortfall <= 0 {
+		return nil, nil
+	}
+
+	positions, err := s.acct.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %v", err)
+	}
+	held := 0.0
+	for _, p := range positions {
+		if p.StockCode == s.cfg.Symbol {
+			held = p.Quantity
+		}
+	}
+	if held == 0 {
+		return nil, nil
+	}
+	etfPrice, err := s.price(s.cfg.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	quantity := math.Min(math.Ceil(shortfall/etfPrice), held)
+
+	log.WithFields(logrus.Fields{"symbol": s.cfg.Symbol, "quantity": quantity, "for": signal.Pair}).Info("Selling swept cash for a buy")
+	return s.acct.PlaceOrder(&models.Signal{Type: models.SellSignal, Pair: s.cfg.Symbol, Amount: quantity})
+}
+
+func (s *Sweeper) cash() (float64, error) {
+	balance, err := s.acct.GetBalance()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %v", err)
+	}
+	cash, err := strconv.ParseFloat(balance, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid balance %q", balance)
+	}
+	return cash, nil
+}
+
+func (s *Sweeper) price(symbol string) (float64, error) {
+	data, err := s.acct.GetMarketData(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get price of %s: %v", symbol, err)
+	}
+	price, err := strconv.ParseFloat(data.StckPrpr, 64)
+	if err != nil || price <= 0 {
+		return 0, fmt.Errorf("invalid price %q for %s", data.StckPrpr, symbol)
+	}
+	return price, nil
+}
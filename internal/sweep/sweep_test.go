@@ -0,0 +1,69 @@
+package sweep
+
+import (
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/models"
+	"tradingbot/internal/paper"
+)
+
+const etf = "357870"
+
+func newAccount(cash float64) *paper.Exchange {
+	acct := paper.New(cash, 0, clock.NewSimulated(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)))
+	acct.SetPrice(etf, 100000)
+	acct.SetPrice("005930", 50000)
+	return acct
+}
+
+func TestParkBuysWithCashAboveReserve(t *testing.T) {
+	acct := newAccount(1000000)
+	s := New(config.CashSweepConfig{Enabled: true, Symbol: etf, Reserve: 150000, MinAmount: 50000}, acct)
+
+	order, err := s.Park()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order == nil || order.Pair != etf || order.Amount != 8 {
+		t.Fatalf("order = %+v, want a buy of 8 %s", order, etf)
+	}
+	if order, _ := s.Park(); order != nil {
+		t.Errorf("parked again with only the reserve left: %+v", order)
+	}
+}
+
+func TestParkSkipsWhilePositionOpen(t *testing.T) {
+	acct := newAccount(1000000)
+	acct.PlaceOrder(&models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 1})
+	s := New(config.CashSweepConfig{Enabled: true, Symbol: etf}, acct)
+
+	if order, err := s.Park(); err != nil || order != nil {
+		t.Errorf("Park() = %+v, %v; want nothing while a position is open", order, err)
+	}
+}
+
+func TestFreeSellsShortfall(t *testing.T) {
+	acct := newAccount(1000000)
+	s := New(config.CashSweepConfig{Enabled: true, Symbol: etf, Reserve: 20000}, acct)
+	buy := &models.Signal{Type: models.BuySignal, Pair: "005930", Amount: 5}
+	if order, _ := s.Free(buy); order != nil {
+		t.Errorf("sold although cash covers the buy: %+v", order)
+	}
+	if _, err := s.Park(); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := s.Free(buy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 250,000 needed plus the 20,000 reserve against 100,000 cash: 2 ETF shares.
+	if order == nil || order.Side != models.OrderSideSell || order.Amount != 2 {
+		t.Fatalf("order = %+v, want a sale of 2 %s", order, etf)
+	}
+	if _, err := acct.PlaceOrder(buy); err != nil {
+		t.Errorf("buy failed after freeing cash: %v", err)
+	}
+}
@@ -0,0 +1,371 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/killswitch"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+	"tradingbot/internal/report"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const (
+	telegramAPI = "https://api.telegram.org"
+	// pollTimeout is how long a getUpdates request waits for a message;
+	// retryDelay how long to wait after a failed one.
+	pollTimeout = 30 * time.Second
+	retryDelay  = 5 * time.Second
+)
+
+const help = `Commands:
+/status - mode, pause state and balance
+/positions - open positions
+/pnl [today|YYYY-MM-DD [YYYY-MM-DD]] - PnL of a period, today by default
+/pause, /resume - stop and restart automated trading
+/flatten SYMBOL - sell a whole position; /flatten all sells everything
+/kill [reason] - halt all orders and cancel open ones until restart`
+
+// Controller carries out the control commands, e.g. the API's controller.
+type Controller interface {
+	Pause()
+	Resume()
+	Paused() bool
+	Flatten() error
+	ClosePosition(symbol string) error
+	Kill(source, reason string) (events.KillEvent, error)
+}
+
+// Account is the read-only view of the brokerage account the bot reports on.
+type Account interface {
+	GetBalance() (string, error)
+	GetPositions() ([]models.Position, error)
+}
+
+// OrderHistory provides the stored orders behind /pnl, e.g. the database.
+type OrderHistory interface {
+	ListOrdersBefore(t time.Time) ([]models.Order, error)
+}
+
+// Bot takes commands sent to a Telegram bot; see config.TelegramConfig.
+type Bot struct {
+	cfg     *config.Config
+	control Controller
+	account Account
+	chats   map[int64]bool
+	users   map[int64]bool
+	baseURL string
+	client  *http.Client
+	// offset is the ID of the next update to poll. It outlives Run, so that
+	// a restarted bot does not handle again the command it panicked on.
+	offset int64
+
+	mu      sync.Mutex
+	history OrderHistory
+}
+
+type update struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		From *struct {
+			ID int64 `json:"id"`
+		} `json:"from"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// New creates the bot configured in cfg.Telegram. history may be nil, in
+// which case /pnl is unavailable.
+func New(cfg *config.Config, control Controller, account Account, history OrderHistory) *Bot {
+	b := &Bot{
+		cfg:     cfg,
+		control: control,
+		account: account,
+		history: history,
+		chats:   map[int64]bool{},
+		users:   map[int64]bool{},
+		baseURL: telegramAPI,
+		client:  &http.Client{Timeout: pollTimeout + 10*time.Second},
+	}
+	for _, id := range cfg.Telegram.ChatIDs {
+		b.chats[id] = true
+	}
+	for _, id := range cfg.Telegram.UserIDs {
+		b.users[id] = true
+	}
+	return b
+}
+
+// SetOrderHistory sets where /pnl reads orders from, e.g. after a database
+// reconnect.
+func (b *Bot) SetOrderHistory(h OrderHistory) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.history = h
+}
+
+// Run polls for commands until ctx is done.
+func (b *Bot) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		updates, err := b.updates(ctx, b.offset)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.WithError(err).Warn("Failed to poll Telegram for commands")
+				select {
+				case <-ctx.Done():
+				case <-time.After(retryDelay):
+				}
+			}
+			continue
+		}
+		for _, u := range updates {
+			b.offset = u.UpdateID + 1
+			if m := u.Message; m != nil {
+				var from int64
+				if m.From != nil {
+					from = m.From.ID
+				}
+				b.handle(ctx, m.Chat.ID, from, m.Text)
+			}
+		}
+	}
+}
+
+func (b *Bot) handle(ctx context.Context, chat, user int64, text string) {
+	fields := logrus.Fields{"chat": chat, "user": user}
+	if !b.chats[chat] || (len(b.users) > 0 && !b.users[user]) {
+		log.WithFields(fields).Warn("Ignoring Telegram message from an unauthorized sender")
+		return
+	}
+	args := strings.Fields(text)
+	if len(args) == 0 {
+		return
+	}
+	// In groups, commands may be addressed as /pause@botname.
+	command := args[0]
+	if i := strings.IndexByte(command, '@'); i >= 0 {
+		command = command[:i]
+	}
+	log.WithFields(fields).WithField("command", command).Info("Telegram command received")
+
+	reply := b.run(command, args[1:])
+	if err := b.send(ctx, chat, reply); err != nil {
+		log.WithError(err).Warn("Failed to reply on Telegram")
+	}
+}
+
+// run carries out a command and returns the reply.
+func (b *Bot) run(command string, args []string) string {
+	switch command {
+	case "/status":
+		return b.status()
+	case "/positions":
+		return b.positions()
+	case "/pnl":
+		return b.pnl(args)
+	case "/pause":
+		b.control.Pause()
+		log.Warn("Trading paused via Telegram")
+		return "Trading paused."
+	case "/resume":
+		b.control.Resume()
+		if b.control.Paused() {
+			return "Trading was stopped by the kill switch; restart to resume."
+		}
+		log.Warn("Trading resumed via Telegram")
+		return "Trading resumed."
+	case "/flatten":
+		return b.flatten(args)
+	case "/kill":
+		ev, err := b.control.Kill(killswitch.SourceTelegram, strings.Join(args, " "))
+		if err != nil {
+			return "Kill failed: " + err.Error()
+		}
+		reply := fmt.Sprintf("Trading stopped by %s: %d open orders cancelled, %d failed.", ev.Source, ev.Cancelled, ev.Failed)
+		if ev.Err != nil {
+			reply += " Open orders could not be listed: " + ev.Err.Error()
+		}
+		return reply
+	default:
+		return help
+	}
+}
+
+func (b *Bot) status() string {
+	state := "running"
+	if b.control.Paused() {
+		state = "paused"
+	}
+	lines := []string{
+		fmt.Sprintf("Mode %s, profile %s, trading %s", b.cfg.Exchange.Mode, b.cfg.Profile, state),
+		"Symbols: " + strings.Join(b.cfg.TradingSymbols(), ", "),
+	}
+	balance, err := b.account.GetBalance()
+	if err != nil {
+		lines = append(lines, "Balance: unavailable ("+err.Error()+")")
+	} else if cash, err := strconv.ParseFloat(balance, 64); err == nil {
+		lines = append(lines, "Cash: "+report.FormatKRW(cash))
+	} else {
+		lines = append(lines, "Cash: "+balance)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b *Bot) positions() string {
+	positions, err := b.account.GetPositions()
+	if err != nil {
+		return "Positions unavailable: " + err.Error()
+	}
+	if len(positions) == 0 {
+		return "No open positions."
+	}
+	lines := make([]string, 0, len(positions))
+	for _, p := range positions {
+		name := p.StockCode
+		if p.Name != "" {
+			name += " " + p.Name
+		}
+		lines = append(lines, fmt.Sprintf("%s: %g @ %s, now %s (%s)", name, p.Quantity,
+			report.FormatKRW(p.AvgPrice), report.FormatKRW(p.CurrentPrice), report.FormatKRW(p.ProfitLoss)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pnl reports the PnL of today, of a day, or of a range of days.
+func (b *Bot) pnl(args []string) string {
+	b.mu.Lock()
+	history := b.history
+	b.mu.Unlock()
+	if history == nil {
+		return "PnL unavailable: no order history."
+	}
+	var from, to string
+	switch {
+	case len(args) == 0 || (len(args) == 1 && args[0] == "today"):
+		from = report.FormatKST(time.Now(), "2006-01-02")
+	case len(args) == 1:
+		from, to = args[0], args[0]
+	case len(args) == 2:
+		from, to = args[0], args[1]
+	default:
+		return "Usage: /pnl [today|YYYY-MM-DD [YYYY-MM-DD]]"
+	}
+	start, end, err := report.ParsePeriod(from, to, time.Now())
+	if err != nil {
+		return err.Error()
+	}
+	orders, err := history.ListOrdersBefore(end)
+	if err != nil {
+		return "PnL unavailable: " + err.Error()
+	}
+	positions, err := b.account.GetPositions()
+	if err != nil {
+		return "PnL unavailable: " + err.Error()
+	}
+	costs := report.Costs{CommissionRate: b.cfg.Fees.CommissionRate, SellTaxRate: b.cfg.Fees.SellTaxRate}
+	pnl := report.Attribute(orders, nil, start, end, report.Prices(positions), costs, b.cfg.Strategy)
+
+	period := report.FormatKST(start, "2006-01-02")
+	if last := end.AddDate(0, 0, -1); report.FormatKST(last, "2006-01-02") != period {
+		period += " to " + report.FormatKST(last, "2006-01-02")
+	}
+	t := pnl.Total
+	lines := []string{
+		fmt.Sprintf("PnL %s: %s", period, report.FormatKRW(t.TotalPnL())),
+		fmt.Sprintf("Realized %s, unrealized %s, fees %s, %d trades",
+			report.FormatKRW(t.RealizedPnL), report.FormatKRW(t.UnrealizedPnL), report.FormatKRW(t.Fees), t.Trades),
+	}
+	for _, s := range pnl.BySymbol {
+		lines = append(lines, fmt.Sprintf("%s: %s", s.Symbol, report.FormatKRW(s.TotalPnL())))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// flatten sells one position, or all of them given "all".
+func (b *Bot) flatten(args []string) string {
+	if len(args) != 1 {
+		return "Usage: /flatten SYMBOL, or /flatten all"
+	}
+	if strings.EqualFold(args[0], "all") {
+		log.Warn("Flatten requested via Telegram")
+		if err := b.control.Flatten(); err != nil {
+			return "Flatten failed: " + err.Error()
+		}
+		return "All positions flattened."
+	}
+	symbol := strings.ToUpper(args[0])
+	log.WithField("pair", symbol).Warn("Position close requested via Telegram")
+	if err := b.control.ClosePosition(symbol); err != nil {
+		return "Close failed: " + err.Error()
+	}
+	return "Position in " + symbol + " closed."
+}
+
+func (b *Bot) updates(ctx context.Context, offset int64) ([]update, error) {
+	query := url.Values{
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"timeout":         {strconv.Itoa(int(pollTimeout / time.Second))},
+		"allowed_updates": {`["message"]`},
+	}
+	var updates []update
+	err := b.call(ctx, http.MethodGet, "getUpdates?"+query.Encode(), nil, &updates)
+	return updates, err
+}
+
+func (b *Bot) send(ctx context.Context, chat int64, text string) error {
+	form := url.Values{"chat_id": {strconv.FormatInt(chat, 10)}, "text": {text}}
+	return b.call(ctx, http.MethodPost, "sendMessage", form, nil)
+}
+
+// call invokes a Bot API method and decodes its result into out.
+func (b *Bot) call(ctx context.Context, httpMethod, method string, form url.Values, out interface{}) error {
+	body := strings.NewReader("")
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	token := b.cfg.Telegram.Token
+	req, err := http.NewRequestWithContext(ctx, httpMethod, b.baseURL+"/bot"+token+"/"+method, body)
+	if err != nil {
+		return err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The error names the URL, which holds the token.
+		return fmt.Errorf("telegram %s failed: %v", method, strings.ReplaceAll(err.Error(), token, "<token>"))
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram %s: invalid response (status %d): %v", method, resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram %s: %s", method, result.Description)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Result, out)
+}
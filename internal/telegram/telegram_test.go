@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/models"
+)
+
+type fakeController struct {
+	paused bool
+	closed []string
+	killed string
+}
+
+func (c *fakeController) Pause()         { c.paused = true }
+func (c *fakeController) Resume()        { c.paused = c.killed != "" }
+func (c *fakeController) Paused() bool   { return c.paused }
+func (c *fakeController) Flatten() error { return nil }
+func (c *fakeController) ClosePosition(symbol string) error {
+	c.closed = append(c.closed, symbol)
+	return nil
+}
+func (c *fakeController) Kill(source, reason string) (events.KillEvent, error) {
+	c.killed, c.paused = source, true
+	return events.KillEvent{Source: source, Reason: reason, Cancelled: 1}, nil
+}
+
+type fakeAccount struct{}
+
+func (fakeAccount) GetBalance() (string, error) { return "1500000", nil }
+func (fakeAccount) GetPositions() ([]models.Position, error) {
+	return []models.Position{{StockCode: "005930", Quantity: 10, AvgPrice: 70000, CurrentPrice: 72000, ProfitLoss: 20000}}, nil
+}
+
+type fakeHistory []models.Order
+
+func (h fakeHistory) ListOrdersBefore(t time.Time) ([]models.Order, error) { return h, nil }
+
+func TestCommandsOnlyFromAuthorizedSenders(t *testing.T) {
+	var replies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/botsecret/sendMessage" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		replies = append(replies, r.Form.Get("chat_id")+": "+r.Form.Get("text"))
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{Telegram: config.TelegramConfig{Enabled: true, Token: "secret", ChatIDs: []int64{42}, UserIDs: []int64{7}}}
+	ctl := &fakeController{}
+	history := fakeHistory{{Pair: "005930", Side: models.OrderSideBuy, Amount: 10, Price: 70000, Timestamp: time.Now()}}
+	b := New(cfg, ctl, fakeAccount{}, history)
+	b.baseURL = server.URL
+	ctx := context.Background()
+
+	b.handle(ctx, 99, 7, "/pause")
+	b.handle(ctx, 42, 8, "/pause")
+	if ctl.paused || len(replies) != 0 {
+		t.Fatalf("paused %v, replies %v, want unknown chats and users ignored", ctl.paused, replies)
+	}
+
+	b.handle(ctx, 42, 7, "/pause@tradingbot")
+	b.handle(ctx, 42, 7, "/flatten 005930")
+	b.handle(ctx, 42, 7, "/pnl today")
+	b.handle(ctx, 42, 7, "/kill fat finger")
+	b.handle(ctx, 42, 7, "/resume")
+	if !ctl.paused || len(ctl.closed) != 1 || ctl.closed[0] != "005930" || ctl.killed != "telegram" {
+		t.Errorf("controller %+v, want paused, 005930 closed and killed from telegram", ctl)
+	}
+	if len(replies) != 5 {
+		t.Fatalf("replies %v, want one per command", replies)
+	}
+	if !strings.HasPrefix(replies[2], "42: PnL") || !strings.Contains(replies[2], "₩20,000") {
+		t.Errorf("pnl reply %q, want today's unrealized ₩20,000", replies[2])
+	}
+	if !strings.Contains(replies[4], "kill switch") {
+		t.Errorf("resume reply %q, want it refused after the kill", replies[4])
+	}
+}
@@ -0,0 +1,197 @@
+package tuning
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/cron"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/models"
+	"tradingbot/internal/optimizer"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const (
+	defaultSchedule   = "0 18 * * 5"
+	defaultDays       = 120
+	defaultBalance    = 10000000
+	defaultCommission = 0.0025
+)
+
+var (
+	defaultShortPeriods = config.TuningRange{Min: 2, Max: 10, Step: 1}
+	defaultLongPeriods  = config.TuningRange{Min: 10, Max: 40, Step: 1}
+)
+
+// Source provides the daily bars the parameters are tuned on, oldest first.
+type Source interface {
+	GetHistoricalData(stockCode string, days int) ([]models.MarketData, error)
+}
+
+// Proposal is a set of moving average periods that did better than the
+// current ones on recent data.
+type Proposal struct {
+	Symbol         string
+	Days           int
+	Current        models.MovingAverageConfig
+	Proposed       models.MovingAverageConfig
+	CurrentProfit  float64
+	ProposedProfit float64
+	Time           time.Time
+}
+
+// Params returns the proposed periods as strategy parameters, keeping the
+// other parameters of current.
+func (p Proposal) Params(current config.StrategyParams) config.StrategyParams {
+	params := make(config.StrategyParams, len(current)+2)
+	for k, v := range current {
+		params[k] = v
+	}
+	params["short_period"] = p.Proposed.ShortPeriod
+	params["long_period"] = p.Proposed.LongPeriod
+	return params
+}
+
+// Tuner proposes moving average periods, see config.TuningConfig. It is safe
+// for concurrent use.
+type Tuner struct {
+	cfg    config.TuningConfig
+	symbol string
+	source Source
+
+	mu      sync.Mutex
+	current models.MovingAverageConfig
+}
+
+// New creates a tuner reading the bars of symbol, unless cfg names another,
+// from source.
+func New(cfg config.TuningConfig, symbol string, source Source) *Tuner {
+	if cfg.Symbol != "" {
+		symbol = cfg.Symbol
+	}
+	if cfg.Days == 0 {
+		cfg.Days = defaultDays
+	}
+	if cfg.ShortPeriods == (config.TuningRange{}) {
+		cfg.ShortPeriods = defaultShortPeriods
+	}
+	if cfg.LongPeriods == (config.TuningRange{}) {
+		cfg.LongPeriods = defaultLongPeriods
+	}
+	if cfg.Balance == 0 {
+		cfg.Balance = defaultBalance
+	}
+	if cfg.Commission == 0 {
+		cfg.Commission = defaultCommission
+	}
+	return &Tuner{cfg: cfg, symbol: symbol, source: source}
+}
+
+// SetCurrent sets the parameters the strategy trades with, which proposals
+// are measured against.
+func (t *Tuner) SetCurrent(params models.MovingAverageConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current = params
+}
+
+// Propose grid-searches the periods on the latest bars and returns the best
+// set, or nil when it does not beat the current one by the configured margin.
+// The threshold and sentiment settings are kept.
+func (t *Tuner) Propose(now time.Time) (*Proposal, error) {
+	t.mu.Lock()
+	current := t.current
+	t.mu.Unlock()
+
+	data, err := t.source.GetHistoricalData(t.symbol, t.cfg.Days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of %s: %v", t.symbol, err)
+	}
+	results := optimizer.GridSearchMovingAverage(data, optimizerRange(t.cfg.ShortPeriods), optimizerRange(t.cfg.LongPeriods),
+		current.Threshold, t.cfg.Balance, t.cfg.Commission)
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no valid period combinations")
+	}
+	best := results[0]
+	base := optimizer.BacktestMovingAverage(data, current, t.cfg.Balance, t.cfg.Commission)
+
+	fields := logrus.Fields{
+		"symbol":         t.symbol,
+		"current":        fmt.Sprintf("%d/%d", current.ShortPeriod, current.LongPeriod),
+		"current_profit": base.TotalProfit,
+		"best":           fmt.Sprintf("%d/%d", best.Params.ShortPeriod, best.Params.LongPeriod),
+		"best_profit":    best.Backtest.TotalProfit,
+	}
+	if best.Params.ShortPeriod == current.ShortPeriod && best.Params.LongPeriod == current.LongPeriod ||
+		best.Backtest.TotalProfit-base.TotalProfit <= t.cfg.MinImprovement*t.cfg.Balance {
+		log.WithFields(fields).Info("Strategy parameters still good, nothing proposed")
+		return nil, nil
+	}
+
+	proposed := current
+	proposed.ShortPeriod, proposed.LongPeriod = best.Params.ShortPeriod, best.Params.LongPeriod
+	log.WithFields(fields).Info("New strategy parameters proposed")
+	return &Proposal{
+		Symbol:         t.symbol,
+		Days:           len(data),
+		Current:        current,
+		Proposed:       proposed,
+		CurrentProfit:  base.TotalProfit,
+		ProposedProfit: best.Backtest.TotalProfit,
+		Time:           now,
+	}, nil
+}
+
+func optimizerRange(r config.TuningRange) optimizer.Range {
+	return optimizer.Range{Min: r.Min, Max: r.Max, Step: r.Step}
+}
+
+// Schedule returns the schedule of cfg in loc, by default Friday 18:00.
+func Schedule(cfg config.TuningConfig, loc *time.Location) (*cron.Schedule, error) {
+	spec := cfg.Schedule
+	if spec == "" {
+		spec = defaultSchedule
+	}
+	return cron.Parse(spec, loc)
+}
+
+// Update is the outcome of a scheduled tuning run. Proposal is nil when the
+// current parameters were kept.
+type Update struct {
+	Proposal *Proposal
+	Err      error
+}
+
+// Watch runs propose on schedule until ctx is done and delivers the outcomes
+// on the returned channel, which is closed when Watch stops.
+func Watch(ctx context.Context, clk clock.Clock, schedule *cron.Schedule, propose func(now time.Time) (*Proposal, error)) <-chan Update {
+	updates := make(chan Update, 1)
+	go func() {
+		defer close(updates)
+		for {
+			next := schedule.Next(clk.Now())
+			log.WithField("next_tuning", next).Debug("Tuning scheduled")
+			timer := clk.NewTimer(next.Sub(clk.Now()))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+
+			proposal, err := propose(clk.Now())
+			select {
+			case updates <- Update{Proposal: proposal, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates
+}
@@ -0,0 +1,105 @@
+package tuning
+
+import (
+	"context"
+	"io/ioutil"
+	stdlog "log"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/cron"
+	"tradingbot/internal/models"
+)
+
+type fakeSource []models.MarketData
+
+func (s fakeSource) GetHistoricalData(string, int) ([]models.MarketData, error) { return s, nil }
+
+// trend rises for 30 bars, then falls for 30.
+func trend() fakeSource {
+	var data fakeSource
+	for i := 0; i < 60; i++ {
+		price := 1000 + i*10
+		if i >= 30 {
+			price = 1300 - (i-30)*10
+		}
+		data = append(data, models.MarketData{StckPrpr: strconv.Itoa(price)})
+	}
+	return data
+}
+
+func TestProposeBeatsCurrentParameters(t *testing.T) {
+	stdlog.SetOutput(ioutil.Discard)
+	defer stdlog.SetOutput(os.Stderr)
+
+	cfg := config.TuningConfig{
+		ShortPeriods: config.TuningRange{Min: 2, Max: 5},
+		LongPeriods:  config.TuningRange{Min: 4, Max: 20, Step: 2},
+		Balance:      1000000,
+		Commission:   0.001,
+	}
+	tuner := New(cfg, "005930", trend())
+	tuner.SetCurrent(models.MovingAverageConfig{ShortPeriod: 5, LongPeriod: 20, Threshold: 0.001})
+
+	now := time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC)
+	p, err := tuner.Propose(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil {
+		t.Fatal("no proposal, want better periods than 5/20")
+	}
+	if p.ProposedProfit <= p.CurrentProfit {
+		t.Errorf("proposed profit %g does not beat current %g", p.ProposedProfit, p.CurrentProfit)
+	}
+	if p.Proposed.Threshold != 0.001 || p.Symbol != "005930" || p.Days != 60 || !p.Time.Equal(now) {
+		t.Errorf("proposal %+v does not keep the threshold or describe the run", p)
+	}
+	params := p.Params(config.StrategyParams{"short_period": 5, "long_period": 20, "threshold": 0.001})
+	if params["short_period"] != p.Proposed.ShortPeriod || params["long_period"] != p.Proposed.LongPeriod || params["threshold"] != 0.001 {
+		t.Errorf("params %v", params)
+	}
+
+	// Once applied, the same data proposes nothing.
+	tuner.SetCurrent(p.Proposed)
+	if again, err := tuner.Propose(now); err != nil || again != nil {
+		t.Errorf("second Propose = %+v, %v; want nothing", again, err)
+	}
+
+	// Neither does an improvement below the margin.
+	cfg.MinImprovement = 10
+	strict := New(cfg, "005930", trend())
+	strict.SetCurrent(models.MovingAverageConfig{ShortPeriod: 5, LongPeriod: 20, Threshold: 0.001})
+	if p, err := strict.Propose(now); err != nil || p != nil {
+		t.Errorf("Propose with a high margin = %+v, %v; want nothing", p, err)
+	}
+}
+
+func TestWatchRunsOnSchedule(t *testing.T) {
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC))
+	schedule, _ := cron.Parse("0 18 * * 5", time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := Watch(ctx, clk, schedule, func(now time.Time) (*Proposal, error) {
+		return &Proposal{Time: now}, nil
+	})
+	for i := 0; i < 1000; i++ {
+		if _, ok := clk.NextDeadline(); ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(time.Hour)
+	select {
+	case u := <-updates:
+		if want := time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC); u.Proposal == nil || !u.Proposal.Time.Equal(want) {
+			t.Errorf("update %+v, want a proposal at %s", u, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no update at the scheduled time")
+	}
+}
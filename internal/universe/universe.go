@@ -0,0 +1,346 @@
+package universe
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"tradingbot/internal/config"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/models"
+)
+
+// SymbolSource looks up the master data of a single symbol, e.g. the KIS client.
+type SymbolSource interface {
+	GetSymbolInfo(stockCode string) (*models.Symbol, error)
+}
+
+// Master is a set of symbol master data keyed by stock code.
+type Master struct {
+	symbols map[string]models.Symbol
+}
+
+// NewMaster creates a master holding symbols.
+func NewMaster(symbols []models.Symbol) *Master {
+	m := &Master{symbols: make(map[string]models.Symbol, len(symbols))}
+	for _, s := range symbols {
+		m.symbols[s.Code] = s
+	}
+	return m
+}
+
+// Load loads the master data from the configured source. The KIS source only
+// looks up codes and the symbols named in universe definitions; source is not
+// used for the file source.
+func Load(cfg config.UniverseConfig, source SymbolSource, codes []string) (*Master, error) {
+	switch cfg.Source {
+	case config.UniverseSourceFile:
+		return LoadFile(cfg.File)
+	case config.UniverseSourceKIS:
+		wanted := append([]string{}, codes...)
+		for _, def := range cfg.Definitions {
+			wanted = append(wanted, def.Symbols...)
+		}
+		return Fetch(source, wanted)
+	default:
+		return nil, fmt.Errorf("no universe source configured")
+	}
+}
+
+// Fetch looks up each code once from source. Codes the source reports as
+// exchange.ErrUnknownSymbol are left out, so Validate reports them.
+func Fetch(source SymbolSource, codes []string) (*Master, error) {
+	m := NewMaster(nil)
+	for _, code := range codes {
+		if _, ok := m.symbols[code]; ok {
+			continue
+		}
+		symbol, err := source.GetSymbolInfo(code)
+		if err != nil {
+			if err == exchange.ErrUnknownSymbol {
+				continue
+			}
+			return nil, fmt.Errorf("failed to look up %s: %v", code, err)
+		}
+		m.symbols[code] = *symbol
+	}
+	return m, nil
+}
+
+// LoadFile reads master data from a CSV file. The header row names the columns:
+// code is required; name, market, type, sector, lot_size, status and indexes
+// are optional. Multiple indexes are separated by "|". A missing status means
+// normal, a missing type stock and a missing lot size 1.
+func LoadFile(path string) (*Master, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open symbol master: %v", err)
+	}
+	defer f.Close()
+
+	symbols, err := parseCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return NewMaster(symbols), nil
+}
+
+func parseCSV(r io.Reader) ([]models.Symbol, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["code"]; !ok {
+		return nil, fmt.Errorf("missing code column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var symbols []models.Symbol
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s := models.Symbol{
+			Code:    field(record, "code"),
+			Name:    field(record, "name"),
+			Market:  strings.ToUpper(field(record, "market")),
+			Sector:  field(record, "sector"),
+			LotSize: 1,
+			Status:  models.SymbolStatus(strings.ToLower(field(record, "status"))),
+			Type:    models.InstrumentType(strings.ToLower(field(record, "type"))),
+		}
+		if s.Code == "" {
+			return nil, fmt.Errorf("line %d: missing code", line)
+		}
+		if lot := field(record, "lot_size"); lot != "" {
+			if s.LotSize, err = strconv.Atoi(lot); err != nil || s.LotSize < 1 {
+				return nil, fmt.Errorf("line %d: invalid lot size %q", line, lot)
+			}
+		}
+		switch s.Status {
+		case "":
+			s.Status = models.SymbolStatusNormal
+		case models.SymbolStatusNormal, models.SymbolStatusAdministrative, models.SymbolStatusHalted, models.SymbolStatusDelisted:
+		default:
+			return nil, fmt.Errorf("line %d: unknown status %q", line, s.Status)
+		}
+		switch s.Type {
+		case "":
+			s.Type = models.InstrumentStock
+		case models.InstrumentStock, models.InstrumentETF, models.InstrumentETN:
+		default:
+			return nil, fmt.Errorf("line %d: unknown type %q", line, s.Type)
+		}
+		if indexes := field(record, "indexes"); indexes != "" {
+			for _, index := range strings.Split(indexes, "|") {
+				s.Indexes = append(s.Indexes, strings.ToUpper(strings.TrimSpace(index)))
+			}
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols, nil
+}
+
+// Lookup returns the master data of code.
+func (m *Master) Lookup(code string) (models.Symbol, bool) {
+	s, ok := m.symbols[code]
+	return s, ok
+}
+
+// Len returns the number of symbols in the master.
+func (m *Master) Len() int {
+	return len(m.symbols)
+}
+
+// Validate checks that every code is listed and tradable, reporting all
+// problems at once.
+func (m *Master) Validate(codes []string) error {
+	var problems []string
+	for _, code := range codes {
+		s, ok := m.symbols[code]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s is not a listed symbol", code))
+		case !s.Tradable():
+			problems = append(problems, fmt.Sprintf("%s (%s) is %s", code, s.Name, s.Status))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid trading symbols: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Select returns the tradable symbols matching def, sorted by code.
+func (m *Master) Select(def config.UniverseDefinition) []models.Symbol {
+	candidates := def.Symbols
+	if len(candidates) == 0 {
+		for code := range m.symbols {
+			candidates = append(candidates, code)
+		}
+	}
+
+	var out []models.Symbol
+	seen := make(map[string]bool)
+	for _, code := range candidates {
+		s, ok := m.symbols[code]
+		if !ok || seen[code] || !s.Tradable() || contains(def.Exclude, code) {
+			continue
+		}
+		if len(def.Markets) > 0 && !contains(def.Markets, s.Market) {
+			continue
+		}
+		if len(def.Sectors) > 0 && !contains(def.Sectors, s.Sector) {
+			continue
+		}
+		if len(def.Indexes) > 0 && !overlaps(def.Indexes, s.Indexes) {
+			continue
+		}
+		seen[code] = true
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+// Universe returns the members of the named universe definition.
+func (m *Master) Universe(cfg config.UniverseConfig, name string) ([]models.Symbol, error) {
+	def, ok := cfg.Definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown universe %q", name)
+	}
+	return m.Select(def), nil
+}
+
+// TickSize returns the KRX price increment for a stock trading at price, per
+// the unified KOSPI/KOSDAQ schedule in effect since 2023.
+func TickSize(price float64) float64 {
+	switch {
+	case price < 2000:
+		return 1
+	case price < 5000:
+		return 5
+	case price < 20000:
+		return 10
+	case price < 50000:
+		return 50
+	case price < 200000:
+		return 100
+	case price < 500000:
+		return 500
+	default:
+		return 1000
+	}
+}
+
+// RoundToTick rounds price down to a valid KRX price.
+func RoundToTick(price float64) float64 {
+	tick := TickSize(price)
+	return float64(int64(price/tick)) * tick
+}
+
+// RoundUpToTick rounds price up to a valid KRX price.
+func RoundUpToTick(price float64) float64 {
+	tick := TickSize(price)
+	return math.Ceil(price/tick) * tick
+}
+
+// RoundLots rounds quantity down to whole lots of lot shares. A lot below one
+// counts as one share.
+func RoundLots(quantity float64, lot int) float64 {
+	if lot < 1 {
+		lot = 1
+	}
+	return math.Floor(quantity/float64(lot)) * float64(lot)
+}
+
+// Shares converts a KRW amount into the number of shares it buys at price,
+// rounded down to whole lots of lot shares; KRX does not trade fractional
+// shares. A lot below one counts as one share.
+func Shares(notional, price float64, lot int) float64 {
+	if price <= 0 || notional <= 0 {
+		return 0
+	}
+	return RoundLots(notional/price, lot)
+}
+
+// LotSizes returns the lot size of every symbol in the master.
+func (m *Master) LotSizes() map[string]int {
+	sizes := make(map[string]int, len(m.symbols))
+	for code, s := range m.symbols {
+		sizes[code] = s.LotSize
+	}
+	return sizes
+}
+
+// Types returns the instrument type of every symbol in the master.
+func (m *Master) Types() map[string]models.InstrumentType {
+	types := make(map[string]models.InstrumentType, len(m.symbols))
+	for code, s := range m.symbols {
+		types[code] = s.Type
+	}
+	return types
+}
+
+// Sectors returns the sector of every symbol in the master that has one.
+func (m *Master) Sectors() map[string]string {
+	sectors := make(map[string]string, len(m.symbols))
+	for code, s := range m.symbols {
+		if s.Sector != "" {
+			sectors[code] = s.Sector
+		}
+	}
+	return sectors
+}
+
+// SectorValues returns the value of positions held in each sector at their
+// current prices. Positions in symbols without a sector are left out.
+func SectorValues(positions []models.Position, sectors map[string]string) map[string]float64 {
+	values := map[string]float64{}
+	for _, p := range positions {
+		if sector := sectors[p.StockCode]; sector != "" {
+			values[sector] += p.Quantity * p.CurrentPrice
+		}
+	}
+	return values
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(a, b []string) bool {
+	for _, v := range a {
+		if contains(b, v) {
+			return true
+		}
+	}
+	return false
+}
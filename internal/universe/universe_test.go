@@ -0,0 +1,172 @@
+package universe
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"tradingbot/internal/config"
+	"tradingbot/internal/exchange"
+	"tradingbot/internal/models"
+)
+
+const masterCSV = `code,name,market,type,sector,lot_size,status,indexes
+005930,삼성전자,KOSPI,,전기전자,1,,KOSPI200|KRX300
+000660,SK하이닉스,kospi,stock,전기전자,1,normal,KOSPI200
+035720,카카오,KOSPI,,서비스업,,administrative,KOSPI200
+091990,셀트리온헬스케어,KOSDAQ,,의약품,1,delisted,
+247540,에코프로비엠,KOSDAQ,,전기전자,1,halted,
+069500,KODEX 200,KOSPI,ETF,,1,,
+`
+
+func testMaster(t *testing.T) *Master {
+	symbols, err := parseCSV(strings.NewReader(masterCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewMaster(symbols)
+}
+
+func TestParseCSV(t *testing.T) {
+	m := testMaster(t)
+	s, ok := m.Lookup("000660")
+	if !ok || s.Market != "KOSPI" || s.Status != models.SymbolStatusNormal || s.LotSize != 1 {
+		t.Errorf("000660 = %+v", s)
+	}
+	if s, _ := m.Lookup("005930"); len(s.Indexes) != 2 || s.Indexes[1] != "KRX300" {
+		t.Errorf("005930 indexes = %v", s.Indexes)
+	}
+	if s, _ := m.Lookup("005930"); s.Type != models.InstrumentStock || s.ExchangeTraded() {
+		t.Errorf("005930 type = %q", s.Type)
+	}
+	if s, _ := m.Lookup("069500"); s.Type != models.InstrumentETF || !s.ExchangeTraded() {
+		t.Errorf("069500 type = %q", s.Type)
+	}
+
+	for _, bad := range []string{
+		"name\nfoo\n",
+		"code,status\n005930,suspended\n",
+		"code,lot_size\n005930,0\n",
+		"code,type\n005930,reit\n",
+	} {
+		if _, err := parseCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	m := testMaster(t)
+	if err := m.Validate([]string{"005930", "035720"}); err != nil {
+		t.Errorf("Validate returned %v for tradable symbols", err)
+	}
+	err := m.Validate([]string{"005930", "123456", "247540", "091990"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"123456 is not a listed symbol", "247540 (에코프로비엠) is halted", "091990 (셀트리온헬스케어) is delisted"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	m := testMaster(t)
+	codes := func(symbols []models.Symbol) string {
+		var out []string
+		for _, s := range symbols {
+			out = append(out, s.Code)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		def  config.UniverseDefinition
+		want string
+	}{
+		{config.UniverseDefinition{Indexes: []string{"KOSPI200"}}, "000660,005930,035720"},
+		{config.UniverseDefinition{Indexes: []string{"kospi200"}, Exclude: []string{"035720"}}, "000660,005930"},
+		{config.UniverseDefinition{Markets: []string{"KOSDAQ"}}, ""},
+		{config.UniverseDefinition{Sectors: []string{"전기전자"}}, "000660,005930"},
+		{config.UniverseDefinition{Symbols: []string{"035720", "005930", "999999"}, Markets: []string{"KOSPI"}}, "005930,035720"},
+	}
+	for i, tt := range tests {
+		if got := codes(m.Select(tt.def)); got != tt.want {
+			t.Errorf("case %d: Select = %q, want %q", i, got, tt.want)
+		}
+	}
+}
+
+type fakeSource map[string]models.Symbol
+
+func (f fakeSource) GetSymbolInfo(code string) (*models.Symbol, error) {
+	if code == "000000" {
+		return nil, errors.New("timeout")
+	}
+	s, ok := f[code]
+	if !ok {
+		return nil, exchange.ErrUnknownSymbol
+	}
+	return &s, nil
+}
+
+func TestFetch(t *testing.T) {
+	source := fakeSource{"005930": {Code: "005930", Name: "삼성전자", Status: models.SymbolStatusNormal}}
+	m, err := Fetch(source, []string{"005930", "123456", "005930"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 1 {
+		t.Errorf("Len = %d, want 1", m.Len())
+	}
+	if err := m.Validate([]string{"123456"}); err == nil {
+		t.Error("expected the unknown symbol to fail validation")
+	}
+	if _, err := Fetch(source, []string{"000000"}); err == nil {
+		t.Error("expected lookup failures to be returned")
+	}
+}
+
+func TestTickSize(t *testing.T) {
+	tests := []struct{ price, tick, rounded, roundedUp float64 }{
+		{1999, 1, 1999, 1999},
+		{4999, 5, 4995, 5000},
+		{70150, 100, 70100, 70200},
+		{199999, 100, 199900, 200000},
+		{250300, 500, 250000, 250500},
+		{612345, 1000, 612000, 613000},
+	}
+	for _, tt := range tests {
+		if got := TickSize(tt.price); got != tt.tick {
+			t.Errorf("TickSize(%v) = %v, want %v", tt.price, got, tt.tick)
+		}
+		if got := RoundToTick(tt.price); got != tt.rounded {
+			t.Errorf("RoundToTick(%v) = %v, want %v", tt.price, got, tt.rounded)
+		}
+		if got := RoundUpToTick(tt.price); got != tt.roundedUp {
+			t.Errorf("RoundUpToTick(%v) = %v, want %v", tt.price, got, tt.roundedUp)
+		}
+	}
+}
+
+func TestShares(t *testing.T) {
+	tests := []struct {
+		notional, price float64
+		lot             int
+		want            float64
+	}{
+		{1000000, 70000, 1, 14},
+		{1000000, 70000, 0, 14},
+		{1000000, 70000, 10, 10},
+		{50000, 70000, 1, 0},
+		{1000000, 0, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := Shares(tt.notional, tt.price, tt.lot); got != tt.want {
+			t.Errorf("Shares(%v, %v, %d) = %v, want %v", tt.notional, tt.price, tt.lot, got, tt.want)
+		}
+	}
+	if got := RoundLots(27, 10); got != 20 {
+		t.Errorf("RoundLots(27, 10) = %v, want 20", got)
+	}
+}
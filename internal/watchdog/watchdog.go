@@ -0,0 +1,198 @@
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/logging"
+	"tradingbot/internal/market"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logging.New()
+
+const defaultCheckInterval = 30 * time.Second
+
+// Components the watchdog looks after.
+const (
+	MarketData = "market_data"
+	Token      = "token"
+	Loop       = "loop"
+)
+
+// TokenSource is the exchange client whose auth token is kept fresh.
+type TokenSource interface {
+	RefreshToken() error
+}
+
+// Watchdog checks that market data keeps arriving and the main loop keeps
+// cycling during market hours and that the exchange token can be refreshed,
+// publishing a WatchdogEvent when one of them fails; see
+// config.WatchdogConfig. Each failure is reported once until the component
+// recovers. Watchdog is safe for concurrent use.
+type Watchdog struct {
+	dataTimeout time.Duration
+	loopTimeout time.Duration
+	interval    time.Duration
+	restart     bool
+	cal         *market.Calendar
+	extended    []string
+	tokens      TokenSource
+	bus         *events.Bus
+
+	mu       sync.Mutex
+	clock    clock.Clock
+	restarts map[string]func() error
+	lastData time.Time
+	lastBeat time.Time
+	// tradingSince is when the current trading period began, as first seen by
+	// the watchdog; nothing can be overdue before it.
+	tradingSince time.Time
+	failing      map[string]bool
+}
+
+// New creates a watchdog of the market data published on bus and of tokens,
+// which may be nil. Without a calendar every time counts as market hours.
+func New(cfg config.WatchdogConfig, cal *market.Calendar, extended []string, tokens TokenSource, bus *events.Bus) *Watchdog {
+	w := &Watchdog{
+		interval: defaultCheckInterval,
+		restart:  cfg.Restart,
+		cal:      cal,
+		extended: extended,
+		tokens:   tokens,
+		bus:      bus,
+		clock:    clock.Real,
+		restarts: map[string]func() error{},
+		failing:  map[string]bool{},
+	}
+	w.dataTimeout, _ = time.ParseDuration(cfg.MarketDataTimeout)
+	w.loopTimeout, _ = time.ParseDuration(cfg.LoopTimeout)
+	if cfg.CheckInterval != "" {
+		w.interval, _ = time.ParseDuration(cfg.CheckInterval)
+	}
+	bus.Subscribe(w.observe, events.KindMarketData)
+	return w
+}
+
+// SetClock replaces the clock used to time the checks.
+func (w *Watchdog) SetClock(c clock.Clock) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.clock = c
+}
+
+// OnRestart sets how a failed component is restarted when restarts are
+// enabled. A component without one is only reported.
+func (w *Watchdog) OnRestart(component string, restart func() error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.restarts[component] = restart
+}
+
+// Beat tells the watchdog that the main loop completed a cycle.
+func (w *Watchdog) Beat() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastBeat = w.clock.Now()
+}
+
+func (w *Watchdog) observe(ev events.Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastData = w.clock.Now()
+}
+
+// Run checks the components every check interval until ctx is done.
+func (w *Watchdog) Run(ctx context.Context) {
+	for {
+		w.mu.Lock()
+		timer := w.clock.NewTimer(w.interval)
+		w.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		w.Check()
+	}
+}
+
+// Check runs all checks once.
+func (w *Watchdog) Check() {
+	w.mu.Lock()
+	now := w.clock.Now()
+	trading := w.cal == nil || w.cal.IsTrading(now, w.extended)
+	switch {
+	case !trading:
+		w.tradingSince = time.Time{}
+	case w.tradingSince.IsZero():
+		w.tradingSince = now
+	}
+	dataAge := now.Sub(latest(w.lastData, w.tradingSince))
+	loopAge := now.Sub(latest(w.lastBeat, w.tradingSince))
+	w.mu.Unlock()
+
+	if w.dataTimeout > 0 {
+		w.report(MarketData, trading && dataAge > w.dataTimeout, func() string {
+			return fmt.Sprintf("no market data for %s", dataAge.Round(time.Second))
+		})
+	}
+	if w.loopTimeout > 0 {
+		w.report(Loop, trading && loopAge > w.loopTimeout, func() string {
+			return fmt.Sprintf("no completed cycle for %s", loopAge.Round(time.Second))
+		})
+	}
+	if w.tokens != nil {
+		err := w.tokens.RefreshToken()
+		w.report(Token, err != nil, func() string {
+			return fmt.Sprintf("token refresh failed: %v", err)
+		})
+	}
+}
+
+// report publishes a component that has just failed, restarting it when
+// enabled, and logs one that has recovered.
+func (w *Watchdog) report(component string, failed bool, detail func() string) {
+	w.mu.Lock()
+	wasFailing := w.failing[component]
+	w.failing[component] = failed
+	restart := w.restarts[component]
+	now := w.clock.Now()
+	w.mu.Unlock()
+
+	fields := logrus.Fields{"component": component}
+	if !failed {
+		if wasFailing {
+			log.WithFields(fields).Info("Watchdog: component recovered")
+		}
+		return
+	}
+	if wasFailing {
+		return
+	}
+
+	ev := events.WatchdogEvent{Component: component, Detail: detail(), Time: now}
+	log.WithFields(fields).Warnf("Watchdog: %s", ev.Detail)
+	if w.restart && restart != nil {
+		if err := restart(); err != nil {
+			log.WithFields(fields).WithError(err).Error("Watchdog: restart failed")
+		} else {
+			ev.Restarted = true
+			log.WithFields(fields).Info("Watchdog: component restarted")
+		}
+	}
+	w.bus.Publish(ev)
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,89 @@
+package watchdog
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"tradingbot/internal/clock"
+	"tradingbot/internal/config"
+	"tradingbot/internal/events"
+	"tradingbot/internal/market"
+	"tradingbot/internal/models"
+)
+
+type fakeTokens struct{ err error }
+
+func (f *fakeTokens) RefreshToken() error { return f.err }
+
+func TestStaleDataAndStalledLoopReported(t *testing.T) {
+	cal, err := market.NewCalendar(config.MarketConfig{})
+	if err != nil {
+		t.Fatalf("NewCalendar returned error: %v", err)
+	}
+	clk := clock.NewSimulated(time.Date(2026, 10, 16, 8, 0, 0, 0, market.KST))
+	bus := events.NewBus()
+	cfg := config.WatchdogConfig{MarketDataTimeout: "5m", LoopTimeout: "10m", Restart: true}
+	w := New(cfg, cal, nil, nil, bus)
+	w.SetClock(clk)
+	var restarted []string
+	w.OnRestart(MarketData, func() error { restarted = append(restarted, MarketData); return nil })
+	var alerts []events.WatchdogEvent
+	bus.Subscribe(func(ev events.Event) { alerts = append(alerts, ev.(events.WatchdogEvent)) }, events.KindWatchdog)
+
+	// Nothing is overdue before the open, nor right after it.
+	w.Check()
+	clk.Set(time.Date(2026, 10, 16, 9, 0, 0, 0, market.KST))
+	w.Check()
+	if len(alerts) != 0 {
+		t.Fatalf("alerts at the open = %+v, want none", alerts)
+	}
+
+	// The loop keeps beating but no data arrives.
+	clk.Advance(6 * time.Minute)
+	w.Beat()
+	w.Check()
+	w.Check()
+	if len(alerts) != 1 || alerts[0].Component != MarketData || !alerts[0].Restarted {
+		t.Fatalf("alerts = %+v, want one restarted market data alert", alerts)
+	}
+	if len(restarted) != 1 {
+		t.Errorf("restarted = %v, want market data once", restarted)
+	}
+
+	// Data resumes, then the loop stops beating; it has no restart.
+	bus.Publish(events.MarketDataEvent{Symbol: "005930", Data: &models.MarketData{StckPrpr: "70000"}})
+	clk.Advance(4 * time.Minute)
+	bus.Publish(events.MarketDataEvent{Symbol: "005930", Data: &models.MarketData{StckPrpr: "70100"}})
+	clk.Advance(7 * time.Minute)
+	bus.Publish(events.MarketDataEvent{Symbol: "005930", Data: &models.MarketData{StckPrpr: "70200"}})
+	w.Check()
+	if len(alerts) != 2 || alerts[1].Component != Loop || alerts[1].Restarted {
+		t.Fatalf("alerts = %+v, want a loop alert without restart", alerts)
+	}
+
+	// After the close nothing is overdue.
+	clk.Set(time.Date(2026, 10, 16, 16, 0, 0, 0, market.KST))
+	w.Check()
+	if len(alerts) != 2 {
+		t.Errorf("alerts after the close = %+v", alerts[2:])
+	}
+}
+
+func TestTokenFailureReportedOnce(t *testing.T) {
+	bus := events.NewBus()
+	tokens := &fakeTokens{err: errors.New("403 Forbidden")}
+	w := New(config.WatchdogConfig{}, nil, nil, tokens, bus)
+	var alerts []events.WatchdogEvent
+	bus.Subscribe(func(ev events.Event) { alerts = append(alerts, ev.(events.WatchdogEvent)) }, events.KindWatchdog)
+
+	w.Check()
+	w.Check()
+	tokens.err = nil
+	w.Check()
+	tokens.err = errors.New("timeout")
+	w.Check()
+
+	if len(alerts) != 2 || alerts[0].Component != Token || alerts[1].Detail != "token refresh failed: timeout" {
+		t.Errorf("alerts = %+v, want two token alerts", alerts)
+	}
+}